@@ -16,15 +16,23 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
+	"github.com/gorilla/websocket"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/virtual-kubelet/azure-aci/pkg/auth"
 	"github.com/virtual-kubelet/azure-aci/pkg/client"
+	"github.com/virtual-kubelet/azure-aci/pkg/metrics"
 	azproviderv2 "github.com/virtual-kubelet/azure-aci/pkg/provider"
+	"github.com/virtual-kubelet/azure-aci/pkg/secrets"
 	azproviderv1 "github.com/virtual-kubelet/azure-aci/provider"
 	cli "github.com/virtual-kubelet/node-cli"
 	logruscli "github.com/virtual-kubelet/node-cli/logrus"
@@ -35,6 +43,8 @@ import (
 	logruslogger "github.com/virtual-kubelet/virtual-kubelet/log/logrus"
 	"github.com/virtual-kubelet/virtual-kubelet/trace"
 	"github.com/virtual-kubelet/virtual-kubelet/trace/opencensus"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
 )
 
 var (
@@ -45,7 +55,8 @@ var (
 )
 
 func main() {
-	ctx := cli.ContextWithCancelOnSignal(context.Background())
+	ctx, cancel := context.WithCancel(cli.ContextWithCancelOnSignal(context.Background()))
+	defer cancel()
 
 	logger := logrus.StandardLogger()
 	log.L = logruslogger.FromLogrus(logrus.NewEntry(logger))
@@ -76,6 +87,14 @@ func main() {
 	azConfig := auth.Config{}
 
 	if vkVersion {
+		// Credentials may be read from a secret provider, e.g. "keyvault=https://myvault.vault.azure.net,env"
+		if spec := os.Getenv("SECRET_PROVIDER"); spec != "" {
+			azConfig.SecretProvider, err = newSecretProvider(spec, &azConfig)
+			if err != nil {
+				log.G(ctx).Fatal(err)
+			}
+		}
+
 		//Setup config
 		err = azConfig.SetAuthConfig()
 		if err != nil {
@@ -84,13 +103,52 @@ func main() {
 
 		azACIAPIs = client.NewAzClientsAPIs(ctx, azConfig)
 	}
+	if addr := os.Getenv("ACI_PROMETHEUS_METRICS_ADDR"); addr != "" {
+		go metrics.ServePrometheus(ctx, addr)
+	}
+
 	run := func(ctx context.Context) error {
 		node, err := cli.New(ctx,
 			cli.WithBaseOpts(o),
 			cli.WithCLIVersion(buildVersion, buildTime),
 			cli.WithProvider("azure", func(cfg provider.InitConfig) (provider.Provider, error) {
 				if vkVersion {
-					return azproviderv2.NewACIProvider(ctx, cfg.ConfigPath, azConfig, azACIAPIs, cfg.ResourceManager, cfg.NodeName, cfg.OperatingSystem, cfg.InternalIP, cfg.DaemonPort, cfg.KubeClusterDomain)
+					dialer, err := newExecDialer()
+					if err != nil {
+						return nil, err
+					}
+					p, err := azproviderv2.NewACIProvider(ctx, cfg.ConfigPath, azConfig, azACIAPIs, cfg.ResourceManager, cfg.NodeName, cfg.OperatingSystem, cfg.InternalIP, cfg.DaemonPort, cfg.KubeClusterDomain,
+						azproviderv2.WithExecDialer(dialer))
+					if err != nil {
+						return nil, err
+					}
+					if addr := os.Getenv("ACI_ADMIN_ADDR"); addr != "" {
+						token, err := getAdminToken(ctx, &azConfig)
+						if err != nil {
+							return nil, err
+						}
+						certFile, keyFile, err := getTLSFiles("ACI_ADMIN")
+						if err != nil {
+							return nil, err
+						}
+						if err := azproviderv2.ValidateAdminAddr(addr, certFile, keyFile); err != nil {
+							return nil, err
+						}
+						go p.ServeAdmin(ctx, addr, token, certFile, keyFile)
+					}
+					if addr := os.Getenv("ACI_STATUS_NOTIFICATIONS_ADDR"); addr != "" {
+						key := os.Getenv("ACI_STATUS_NOTIFICATIONS_KEY")
+						if key == "" {
+							return nil, errors.New("ACI_STATUS_NOTIFICATIONS_ADDR is set but no ACI_STATUS_NOTIFICATIONS_KEY is configured")
+						}
+						certFile, keyFile, err := getTLSFiles("ACI_STATUS_NOTIFICATIONS")
+						if err != nil {
+							return nil, err
+						}
+						go p.ServeStatusNotifications(ctx, addr, key, certFile, keyFile)
+					}
+					go drainOnSignal(ctx, p, cancel)
+					return p, nil
 				} else {
 					return azproviderv1.NewACIProvider(cfg.ConfigPath, cfg.ResourceManager, cfg.NodeName, cfg.OperatingSystem, cfg.InternalIP, cfg.DaemonPort, cfg.KubeClusterDomain)
 				}
@@ -122,3 +180,110 @@ func main() {
 		log.G(ctx).Debug(err)
 	}
 }
+
+// drainOnSignal drains the provider on SIGUSR1 and stops the virtual kubelet once a drain, started
+// by the signal or the admin API, completed. ACI_DRAIN_TIMEOUT bounds the wait for the pods.
+func drainOnSignal(ctx context.Context, p *azproviderv2.ACIProvider, stop context.CancelFunc) {
+	timeout := azproviderv2.DefaultDrainTimeout
+	if value := os.Getenv("ACI_DRAIN_TIMEOUT"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			log.G(ctx).WithError(err).Warnf("invalid ACI_DRAIN_TIMEOUT %q, draining for up to %s", value, timeout)
+		} else {
+			timeout = parsed
+		}
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1)
+	defer signal.Stop(signals)
+	for {
+		select {
+		case <-signals:
+			if err := p.StartDrain(ctx, timeout); err != nil {
+				log.G(ctx).WithError(err).Error("failed to drain the node")
+			}
+		case <-p.Drained():
+			log.G(ctx).Info("node drained, shutting down")
+			stop()
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// newExecDialer returns the websocket dialer of the exec sessions. Like the default dialer it goes
+// through the proxy of HTTPS_PROXY, ACI_EXEC_CA_FILE adds the CA certificates of a TLS inspecting
+// proxy and ACI_EXEC_HANDSHAKE_TIMEOUT bounds the handshake, 45s by default.
+func newExecDialer() (*websocket.Dialer, error) {
+	dialer := *websocket.DefaultDialer
+	if value := os.Getenv("ACI_EXEC_HANDSHAKE_TIMEOUT"); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout <= 0 {
+			return nil, errors.Errorf("invalid ACI_EXEC_HANDSHAKE_TIMEOUT %q", value)
+		}
+		dialer.HandshakeTimeout = timeout
+	}
+	if file := os.Getenv("ACI_EXEC_CA_FILE"); file != "" {
+		pem, err := os.ReadFile(file)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to read ACI_EXEC_CA_FILE")
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("no certificate found in ACI_EXEC_CA_FILE %s", file)
+		}
+		dialer.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+	return &dialer, nil
+}
+
+// getAdminToken returns the bearer token of the admin API, from ACI_ADMIN_TOKEN or the secret
+// provider. The admin API is never served without a token.
+func getAdminToken(ctx context.Context, azConfig *auth.Config) (string, error) {
+	if token := os.Getenv("ACI_ADMIN_TOKEN"); token != "" {
+		return token, nil
+	}
+	if azConfig.SecretProvider != nil {
+		token, err := azConfig.SecretProvider.GetSecret(ctx, "ACI_ADMIN_TOKEN")
+		if err == nil {
+			return token, nil
+		}
+		if !secrets.IsNotFound(err) {
+			return "", errors.Wrap(err, "unable to read the admin API token")
+		}
+	}
+	return "", errors.New("ACI_ADMIN_ADDR is set but no ACI_ADMIN_TOKEN is configured")
+}
+
+// getTLSFiles returns the certificate and key file an endpoint of the virtual kubelet serves TLS
+// with, from the <prefix>_TLS_CERT_FILE and <prefix>_TLS_KEY_FILE environment variables. Both are
+// empty when the endpoint serves plain HTTP.
+func getTLSFiles(prefix string) (string, string, error) {
+	certFile, keyFile := os.Getenv(prefix+"_TLS_CERT_FILE"), os.Getenv(prefix+"_TLS_KEY_FILE")
+	if (certFile == "") != (keyFile == "") {
+		return "", "", errors.Errorf("%s_TLS_CERT_FILE and %s_TLS_KEY_FILE must be set together", prefix, prefix)
+	}
+	return certFile, keyFile, nil
+}
+
+func newSecretProvider(spec string, azConfig *auth.Config) (secrets.Provider, error) {
+	opts := secrets.Options{
+		KeyVaultAuthorizer: azConfig.KeyVaultAuthorizer,
+	}
+	if strings.Contains(spec, "kubernetes=") {
+		config, err := clientcmd.BuildConfigFromFlags("", os.Getenv("KUBECONFIG"))
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to load kubernetes client config for the secret provider")
+		}
+		opts.KubeClient, err = kubernetes.NewForConfig(config)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to create kubernetes client for the secret provider")
+		}
+	}
+	return secrets.New(spec, opts)
+}
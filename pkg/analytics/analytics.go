@@ -1,12 +1,17 @@
 package analytics
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
+	"strings"
 
 	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	"github.com/Azure/azure-sdk-for-go/services/operationalinsights/mgmt/2020-08-01/operationalinsights"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/virtual-kubelet/azure-aci/pkg/secrets"
 )
 
 // NewContainerGroupDiagnostics creates a container group diagnostics object
@@ -54,3 +59,74 @@ func NewContainerGroupDiagnosticsFromFile(filepath string) (*azaci.ContainerGrou
 		LogAnalytics: &logAnalytics,
 	}, err
 }
+
+// NewContainerGroupDiagnosticsFromSecretProvider creates a container group diagnostics object from
+// the LOG_ANALYTICS_ID and LOG_ANALYTICS_KEY secrets of the provider
+func NewContainerGroupDiagnosticsFromSecretProvider(ctx context.Context, provider secrets.Provider) (*azaci.ContainerGroupDiagnostics, error) {
+	logAnalyticsID, err := provider.GetSecret(ctx, "LOG_ANALYTICS_ID")
+	if err != nil {
+		return nil, err
+	}
+	logAnalyticsKey, err := provider.GetSecret(ctx, "LOG_ANALYTICS_KEY")
+	if err != nil {
+		return nil, err
+	}
+	return NewContainerGroupDiagnostics(logAnalyticsID, logAnalyticsKey)
+}
+
+// ParseWorkspaceResourceID splits the resource ID of a Log Analytics workspace, e.g.
+// /subscriptions/<id>/resourceGroups/<group>/providers/Microsoft.OperationalInsights/workspaces/<name>
+func ParseWorkspaceResourceID(resourceID string) (subscriptionID, resourceGroup, workspaceName string, err error) {
+	parts := strings.Split(strings.Trim(resourceID, "/"), "/")
+	if len(parts) != 8 ||
+		!strings.EqualFold(parts[0], "subscriptions") ||
+		!strings.EqualFold(parts[2], "resourceGroups") ||
+		!strings.EqualFold(parts[4], "providers") ||
+		!strings.EqualFold(parts[5], "Microsoft.OperationalInsights") ||
+		!strings.EqualFold(parts[6], "workspaces") {
+		return "", "", "", fmt.Errorf("%q is not the resource ID of a Log Analytics workspace", resourceID)
+	}
+	for _, part := range parts {
+		if part == "" {
+			return "", "", "", fmt.Errorf("%q is not the resource ID of a Log Analytics workspace", resourceID)
+		}
+	}
+	return parts[1], parts[3], parts[7], nil
+}
+
+// NewContainerGroupDiagnosticsFromWorkspaceResourceID creates a container group diagnostics object
+// for the workspace with the resource ID, e.g. a workspace linked to the cluster. The workspace ID
+// and key are looked up with the identity of the provider, so no shared key has to be configured.
+func NewContainerGroupDiagnosticsFromWorkspaceResourceID(ctx context.Context, resourceID string, authorizer autorest.Authorizer) (*azaci.ContainerGroupDiagnostics, error) {
+	subscriptionID, resourceGroup, workspaceName, err := ParseWorkspaceResourceID(resourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	workspaces := operationalinsights.NewWorkspacesClient(subscriptionID)
+	workspaces.Authorizer = authorizer
+	workspace, err := workspaces.Get(ctx, resourceGroup, workspaceName)
+	if err != nil {
+		return nil, fmt.Errorf("looking up Log Analytics workspace %q failed: %v", resourceID, err)
+	}
+	if workspace.WorkspaceProperties == nil || workspace.CustomerID == nil {
+		return nil, fmt.Errorf("log Analytics workspace %q has no workspace ID", resourceID)
+	}
+
+	sharedKeys := operationalinsights.NewSharedKeysClient(subscriptionID)
+	sharedKeys.Authorizer = authorizer
+	keys, err := sharedKeys.GetSharedKeys(ctx, resourceGroup, workspaceName)
+	if err != nil {
+		return nil, fmt.Errorf("reading the keys of Log Analytics workspace %q failed: %v", resourceID, err)
+	}
+	if keys.PrimarySharedKey == nil {
+		return nil, fmt.Errorf("log Analytics workspace %q has no shared key", resourceID)
+	}
+
+	diagnostics, err := NewContainerGroupDiagnostics(*workspace.CustomerID, *keys.PrimarySharedKey)
+	if err != nil {
+		return nil, err
+	}
+	diagnostics.LogAnalytics.WorkspaceResourceID = &resourceID
+	return diagnostics, nil
+}
@@ -63,3 +63,47 @@ func TestLogAnalyticsFileParsingFailure(t *testing.T) {
 	defer os.Remove(tempFile.Name())
 
 }
+
+func TestParseWorkspaceResourceID(t *testing.T) {
+	cases := []struct {
+		description   string
+		resourceID    string
+		expectedError bool
+	}{
+		{
+			description: "Valid resource ID",
+			resourceID:  "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.OperationalInsights/workspaces/ws",
+		},
+		{
+			description: "Case insensitive segments",
+			resourceID:  "/subscriptions/sub/resourcegroups/rg/providers/microsoft.operationalinsights/Workspaces/ws",
+		},
+		{
+			description:   "Workspace ID instead of resource ID",
+			resourceID:    "####-####-####-####",
+			expectedError: true,
+		},
+		{
+			description:   "Other resource type",
+			resourceID:    "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Network/virtualNetworks/ws",
+			expectedError: true,
+		},
+		{
+			description:   "Missing workspace name",
+			resourceID:    "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.OperationalInsights/workspaces/",
+			expectedError: true,
+		}}
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			subscriptionID, resourceGroup, workspaceName, err := ParseWorkspaceResourceID(tc.resourceID)
+			if tc.expectedError {
+				assert.Check(t, err != nil)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Equal(t, "sub", subscriptionID)
+			assert.Equal(t, "rg", resourceGroup)
+			assert.Equal(t, "ws", workspaceName)
+		})
+	}
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/Azure/go-autorest/autorest"
 	"github.com/Azure/go-autorest/autorest/adal"
 	"github.com/dimchansky/utfbom"
+	"github.com/virtual-kubelet/azure-aci/pkg/secrets"
 	"github.com/virtual-kubelet/virtual-kubelet/log"
 )
 
@@ -32,6 +33,8 @@ type Config struct {
 	AuthConfig    *Authentication
 	Cloud         cloud.Configuration
 	Authorizer    autorest.Authorizer
+	// SecretProvider resolves the client secret when no file or environment variable sets it.
+	SecretProvider secrets.Provider
 }
 
 // getAuthorizer return autorest authorizer.
@@ -121,6 +124,15 @@ func (c *Config) SetAuthConfig() error {
 		}
 
 		log.G(context.TODO()).Info("Using user identity for Authentication")
+	} else if secrets.UsesKeyVault(c.SecretProvider) {
+		// Key Vault is read with the credentials of the virtual kubelet, a service principal would need
+		// its client secret to read it.
+		return fmt.Errorf("the keyvault secret provider requires managed identity authentication, unset AZURE_CLIENT_ID and set VIRTUALNODE_USER_IDENTITY_CLIENTID, or use another secret provider")
+	} else if c.AuthConfig.ClientSecret == "" && c.SecretProvider != nil {
+		c.AuthConfig.ClientSecret, err = c.SecretProvider.GetSecret(context.TODO(), "AZURE_CLIENT_SECRET")
+		if err != nil {
+			return fmt.Errorf("failed to get the client secret from the secret provider: %v", err)
+		}
 	}
 
 	if tenantID := os.Getenv("AZURE_TENANT_ID"); tenantID != "" {
@@ -141,6 +153,14 @@ func (c *Config) SetAuthConfig() error {
 	return nil
 }
 
+// KeyVaultAuthorizer returns an authorizer for the Key Vault data plane of the cloud.
+func (c *Config) KeyVaultAuthorizer() (autorest.Authorizer, error) {
+	if c.AuthConfig == nil {
+		return nil, fmt.Errorf("authentication is not configured")
+	}
+	return c.getAuthorizer(getKeyVaultResource(c.Cloud))
+}
+
 // Authentication represents the Authentication file for Azure.
 type Authentication struct {
 	ClientID             string `json:"clientId,omitempty"`
@@ -238,6 +258,16 @@ func (a *Authentication) decode(b []byte) ([]byte, error) {
 	return ioutil.ReadAll(reader)
 }
 
+func getKeyVaultResource(config cloud.Configuration) string {
+	switch config.ActiveDirectoryAuthorityHost {
+	case cloud.AzureGovernment.ActiveDirectoryAuthorityHost:
+		return "https://vault.usgovcloudapi.net"
+	case cloud.AzureChina.ActiveDirectoryAuthorityHost:
+		return "https://vault.azure.cn"
+	}
+	return "https://vault.azure.net"
+}
+
 func getCloudConfiguration(cloudName string) cloud.Configuration {
 	switch cloudName {
 	case string(AzurePublicCloud):
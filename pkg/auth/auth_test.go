@@ -5,6 +5,7 @@ import (
 	"os"
 	"testing"
 
+	"github.com/virtual-kubelet/azure-aci/pkg/secrets"
 	"gotest.tools/assert"
 )
 
@@ -246,3 +247,25 @@ func TestSetAuthConfigWithEnvVariablesOnly(t *testing.T) {
 	}
 	assert.Check(t, azConfig.Authorizer != nil, "Authorizer should be nil")
 }
+
+func TestSetAuthConfigKeyVaultRequiresManagedIdentity(t *testing.T) {
+	os.Setenv("AZURE_AUTH_LOCATION", "")
+	os.Setenv("AKS_CREDENTIAL_LOCATION", "")
+	os.Setenv("AZURE_CLIENT_ID", "######-###-####-####-######")
+	os.Setenv("AZURE_CLIENT_SECRET", "")
+	defer os.Unsetenv("AZURE_CLIENT_ID")
+
+	azConfig := Config{}
+	var err error
+	azConfig.SecretProvider, err = secrets.New("keyvault=https://myvault.vault.azure.net,env", secrets.Options{
+		KeyVaultAuthorizer: azConfig.KeyVaultAuthorizer,
+	})
+	assert.NilError(t, err)
+	err = azConfig.SetAuthConfig()
+	assert.ErrorContains(t, err, "requires managed identity authentication")
+
+	os.Setenv("AZURE_CLIENT_ID", "")
+	os.Setenv("VIRTUALNODE_USER_IDENTITY_CLIENTID", "######-###-####-####-######")
+	defer os.Unsetenv("VIRTUALNODE_USER_IDENTITY_CLIENTID")
+	assert.NilError(t, azConfig.SetAuthConfig(), "managed identities should read Key Vault")
+}
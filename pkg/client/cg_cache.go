@@ -0,0 +1,148 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+)
+
+// cachedContainerGroup is the body of the last container group returned by ARM with its
+// validators. The body is kept rather than the decoded container group, so each read decodes a
+// copy its caller may change.
+type cachedContainerGroup struct {
+	etag         string
+	lastModified string
+	body         []byte
+}
+
+// containerGroupCache keeps the validators of polled container groups, so an unchanged
+// container group is answered by ARM with 304 Not Modified instead of the full instance view.
+type containerGroupCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedContainerGroup
+}
+
+func newContainerGroupCache() *containerGroupCache {
+	return &containerGroupCache{
+		entries: make(map[string]cachedContainerGroup),
+	}
+}
+
+func containerGroupCacheKey(resourceGroup, cgName string) string {
+	return resourceGroup + "/" + cgName
+}
+
+func (c *containerGroupCache) get(resourceGroup, cgName string) (cachedContainerGroup, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[containerGroupCacheKey(resourceGroup, cgName)]
+	return entry, ok
+}
+
+// update stores the body of the container group when the response carries a validator.
+func (c *containerGroupCache) update(resourceGroup, cgName string, body []byte, resp *http.Response) {
+	key := containerGroupCacheKey(resourceGroup, cgName)
+	etag := resp.Header.Get("ETag")
+	lastModified := resp.Header.Get("Last-Modified")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if etag == "" && lastModified == "" {
+		delete(c.entries, key)
+		return
+	}
+	c.entries[key] = cachedContainerGroup{
+		etag:         etag,
+		lastModified: lastModified,
+		body:         body,
+	}
+}
+
+func (c *containerGroupCache) invalidate(resourceGroup, cgName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, containerGroupCacheKey(resourceGroup, cgName))
+}
+
+// flush drops every cached container group.
+func (c *containerGroupCache) flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]cachedContainerGroup)
+}
+
+// FlushCache drops the cached container groups, the next reads get them in full from ARM.
+func (a *AzClientsAPIs) FlushCache() {
+	if a.cgCache != nil {
+		a.cgCache.flush()
+	}
+}
+
+// getContainerGroupConditional gets a container group, sending the validators of the cached copy
+// so ARM can skip the body when nothing changed. Servers that ignore the validators answer
+// with 200 and the container group is decoded as usual.
+func (a *AzClientsAPIs) getContainerGroupConditional(ctx context.Context, resourceGroup, cgName string) (azaci.ContainerGroup, error) {
+	cgClient := a.ContainerGroupClient.CGClient
+	if a.cgCache == nil {
+		return cgClient.Get(ctx, resourceGroup, cgName)
+	}
+
+	req, err := cgClient.GetPreparer(ctx, resourceGroup, cgName)
+	if err != nil {
+		return azaci.ContainerGroup{}, autorest.NewErrorWithError(err, "containerinstance.ContainerGroupsClient", "Get", nil, "Failure preparing request")
+	}
+
+	cached, hasCached := a.cgCache.get(resourceGroup, cgName)
+	if hasCached {
+		// The SDK preparers leave the headers of GET requests unset.
+		if req.Header == nil {
+			req.Header = http.Header{}
+		}
+		if cached.etag != "" {
+			req.Header.Set("If-None-Match", cached.etag)
+		}
+		if cached.lastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.lastModified)
+		}
+	}
+
+	resp, err := cgClient.GetSender(req)
+	if err != nil {
+		return azaci.ContainerGroup{Response: autorest.Response{Response: resp}}, autorest.NewErrorWithError(err, "containerinstance.ContainerGroupsClient", "Get", resp, "Failure sending request")
+	}
+
+	if resp.StatusCode == http.StatusNotModified && hasCached {
+		resp.Body.Close()
+		log.G(ctx).Debugf("container group %s is not modified, using the cached copy", cgName)
+		cg := azaci.ContainerGroup{Response: autorest.Response{Response: resp}}
+		if err := json.Unmarshal(cached.body, &cg); err != nil {
+			a.cgCache.invalidate(resourceGroup, cgName)
+			return cg, autorest.NewErrorWithError(err, "containerinstance.ContainerGroupsClient", "Get", resp, "Failure decoding the cached container group")
+		}
+		return cg, nil
+	}
+
+	var body []byte
+	if resp.Body != nil {
+		body, err = ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return azaci.ContainerGroup{Response: autorest.Response{Response: resp}}, autorest.NewErrorWithError(err, "containerinstance.ContainerGroupsClient", "Get", resp, "Failure reading response")
+		}
+		resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	cg, err := cgClient.GetResponder(resp)
+	if err != nil {
+		a.cgCache.invalidate(resourceGroup, cgName)
+		return cg, autorest.NewErrorWithError(err, "containerinstance.ContainerGroupsClient", "Get", resp, "Failure responding to request")
+	}
+	a.cgCache.update(resourceGroup, cgName, body, resp)
+	return cg, nil
+}
@@ -0,0 +1,54 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	"gotest.tools/assert"
+)
+
+func TestGetContainerGroupConditional(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"name":"ns-pod","properties":{"provisioningState":"Succeeded"}}`))
+	}))
+	defer server.Close()
+
+	a := &AzClientsAPIs{
+		ContainerGroupClient: ContainerGroupsClientWrapper{CGClient: azaci.NewContainerGroupsClientWithBaseURI(server.URL, "sub")},
+		cgCache:              newContainerGroupCache(),
+	}
+
+	cg, err := a.getContainerGroupConditional(context.Background(), "rg", "ns-pod")
+	assert.NilError(t, err)
+	assert.Equal(t, http.StatusOK, cg.StatusCode)
+	assert.Equal(t, "ns-pod", *cg.Name)
+
+	cg, err = a.getContainerGroupConditional(context.Background(), "rg", "ns-pod")
+	assert.NilError(t, err)
+	assert.Equal(t, http.StatusNotModified, cg.StatusCode)
+	assert.Equal(t, "ns-pod", *cg.Name, "the cached container group should be returned")
+	assert.Equal(t, "Succeeded", *cg.ProvisioningState)
+
+	state := "Deleting"
+	cg.ProvisioningState = &state
+	cg, err = a.getContainerGroupConditional(context.Background(), "rg", "ns-pod")
+	assert.NilError(t, err)
+	assert.Equal(t, "Succeeded", *cg.ProvisioningState, "changes to a returned container group should not reach the cache")
+
+	a.cgCache.invalidate("rg", "ns-pod")
+	cg, err = a.getContainerGroupConditional(context.Background(), "rg", "ns-pod")
+	assert.NilError(t, err)
+	assert.Equal(t, http.StatusOK, cg.StatusCode)
+	assert.Equal(t, 4, requests)
+}
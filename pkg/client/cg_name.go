@@ -0,0 +1,30 @@
+package client
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+const (
+	// maxContainerGroupNameLength is the longest container group name ACI accepts.
+	maxContainerGroupNameLength = 63
+	// containerGroupNameHashLength is the length of the hash suffix of truncated names.
+	containerGroupNameHashLength = 10
+)
+
+// ContainerGroupName returns the name of the container group of a pod, <namespace>-<name>. Names
+// longer than ACI allows are truncated and suffixed with a hash of the full name, so they stay
+// deterministic and unique. The pod of a container group is found from its PodName and Namespace
+// tags, never by parsing the name.
+func ContainerGroupName(podNS, podName string) string {
+	name := fmt.Sprintf("%s-%s", podNS, podName)
+	if len(name) <= maxContainerGroupNameLength {
+		return name
+	}
+	sum := sha256.Sum256([]byte(name))
+	hash := hex.EncodeToString(sum[:])[:containerGroupNameHashLength]
+	prefix := strings.TrimRight(name[:maxContainerGroupNameLength-containerGroupNameHashLength-1], "-.")
+	return prefix + "-" + hash
+}
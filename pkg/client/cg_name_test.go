@@ -0,0 +1,26 @@
+package client
+
+import (
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestContainerGroupName(t *testing.T) {
+	assert.Check(t, is.Equal("default-nginx", ContainerGroupName("default", "nginx")))
+
+	exact := strings.Repeat("p", maxContainerGroupNameLength-len("default-"))
+	assert.Check(t, is.Equal("default-"+exact, ContainerGroupName("default", exact)), "names at the limit should not change")
+
+	long := "my-very-long-deployment-name-7d4b9c8f5d-x2x9z"
+	name := ContainerGroupName("team-observability-production", long)
+	assert.Check(t, is.Len(name, maxContainerGroupNameLength))
+	assert.Check(t, strings.HasPrefix(name, "team-observability-production-my-very-long-deploymen"))
+	assert.Check(t, is.Equal(name, ContainerGroupName("team-observability-production", long)), "names should be deterministic")
+	assert.Check(t, name != ContainerGroupName("team-observability-production", long+"a"), "pods sharing a prefix should not collide")
+
+	name = ContainerGroupName(strings.Repeat("a", 51), "-b-"+strings.Repeat("c", 20))
+	assert.Check(t, !strings.Contains(name, "--"), "the hash should not follow a dash, got %s", name)
+}
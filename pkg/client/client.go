@@ -3,22 +3,48 @@ package client
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"net/http"
 
 	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
 	"github.com/Azure/go-autorest/autorest"
+	"github.com/virtual-kubelet/azure-aci/pkg/errcodes"
 	"github.com/virtual-kubelet/virtual-kubelet/log"
 )
 
 const (
 	APIVersion            = "2021-10-01"
 	containerGroupURLPath = "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.ContainerInstance/containerGroups/{containerGroupName}"
+
+	// ConfidentialAPIVersion is the first API version with confidential container groups, used
+	// instead of APIVersion to create them.
+	ConfidentialAPIVersion = "2023-05-01"
 )
 
+// ContainerGroupSkuConfidential is the SKU of confidential container groups, which run in a
+// hardware based trusted execution environment. APIVersion does not know it.
+const ContainerGroupSkuConfidential azaci.ContainerGroupSku = "Confidential"
+
+// ConfidentialComputeProperties are the properties of confidential container groups.
+type ConfidentialComputeProperties struct {
+	// CcePolicy is the base64 encoded confidential computing enforcement policy.
+	CcePolicy *string `json:"ccePolicy,omitempty"`
+}
+
 type ContainerGroupPropertiesWrapper struct {
-	ContainerGroupProperties *azaci.ContainerGroupProperties
-	Extensions               []*Extension `json:"extensions,omitempty"`
+	ContainerGroupProperties      *azaci.ContainerGroupProperties
+	Extensions                    []*Extension                   `json:"extensions,omitempty"`
+	ConfidentialComputeProperties *ConfidentialComputeProperties `json:"confidentialComputeProperties,omitempty"`
+}
+
+// isConfidential reports whether the container group is confidential and needs
+// ConfidentialAPIVersion.
+func (cg *ContainerGroupWrapper) isConfidential() bool {
+	properties := cg.ContainerGroupPropertiesWrapper
+	if properties == nil {
+		return false
+	}
+	return properties.ConfidentialComputeProperties != nil ||
+		(properties.ContainerGroupProperties != nil && properties.ContainerGroupProperties.Sku == ContainerGroupSkuConfidential)
 }
 
 type ContainerGroupWrapper struct {
@@ -54,6 +80,9 @@ func (c *ContainerGroupsClientWrapper) CreateCG(ctx context.Context, resourceGro
 	}
 
 	result, err := c.CGClient.CreateOrUpdateSender(addReq)
+	if rec := RecorderFromContext(ctx); rec != nil {
+		rec.Record(addReq, containerGroup, result.Response(), err)
+	}
 	logger.Infof("CreateCG status code: %s", result.Status())
 
 	if err != nil {
@@ -64,7 +93,7 @@ func (c *ContainerGroupsClientWrapper) CreateCG(ctx context.Context, resourceGro
 	// 200 (OK) and 201 (Created) are a successful responses.
 	if result.Response() != nil {
 		if result.Response().StatusCode != http.StatusOK && result.Response().StatusCode != http.StatusCreated {
-			return fmt.Errorf("failed to create container group %s, status code %d ", *containerGroup.Name, result.Response().StatusCode)
+			return errcodes.Errorf(errcodes.ContainerGroupCreateFailed, "failed to create container group %s, status code %d ", *containerGroup.Name, result.Response().StatusCode)
 		}
 	}
 
@@ -80,8 +109,12 @@ func (c *ContainerGroupsClientWrapper) createOrUpdatePreparerWrapper(ctx context
 		"subscriptionId":     autorest.Encode("path", c.CGClient.SubscriptionID),
 	}
 
+	apiVersion := APIVersion
+	if containerGroup.isConfidential() {
+		apiVersion = ConfidentialAPIVersion
+	}
 	queryParameters := map[string]interface{}{
-		"api-version": APIVersion,
+		"api-version": apiVersion,
 	}
 
 	preparer := autorest.CreatePreparer(
@@ -137,5 +170,8 @@ func (cg ContainerGroupPropertiesWrapper) MarshalJSON() ([]byte, error) {
 	if cg.Extensions != nil {
 		objectMap["extensions"] = cg.Extensions
 	}
+	if cg.ConfidentialComputeProperties != nil {
+		objectMap["confidentialComputeProperties"] = cg.ConfidentialComputeProperties
+	}
 	return json.Marshal(objectMap)
 }
@@ -6,11 +6,17 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
 	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	"github.com/Azure/azure-sdk-for-go/services/preview/monitor/mgmt/2021-07-01-preview/insights"
+	"github.com/Azure/azure-sdk-for-go/services/resourcehealth/mgmt/2020-05-01/resourcehealth"
+	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2020-10-01/resources"
 	"github.com/pkg/errors"
 	"github.com/virtual-kubelet/azure-aci/pkg/auth"
+	"github.com/virtual-kubelet/azure-aci/pkg/errcodes"
 	"github.com/virtual-kubelet/azure-aci/pkg/validation"
 	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
 	"github.com/virtual-kubelet/virtual-kubelet/log"
@@ -21,19 +27,28 @@ import (
 
 type AzClientsInterface interface {
 	ContainerGroupGetter
+	MetricsGetter
 	CreateContainerGroup(ctx context.Context, resourceGroup, podNS, podName string, cg *ContainerGroupWrapper) error
 	GetContainerGroupInfo(ctx context.Context, resourceGroup, namespace, name, nodeName string) (*azaci.ContainerGroup, error)
 	GetContainerGroupListResult(ctx context.Context, resourceGroup string) (*[]azaci.ContainerGroup, error)
+	ListContainerGroupsByNodeName(ctx context.Context, resourceGroup, nodeName string) (*[]azaci.ContainerGroup, error)
 	ListCapabilities(ctx context.Context, region string) (*[]azaci.Capabilities, error)
 	DeleteContainerGroup(ctx context.Context, resourceGroup, cgName string) error
+	UpdateContainerGroupTags(ctx context.Context, resourceGroup, cgName string, tags map[string]*string) error
 	ListLogs(ctx context.Context, resourceGroup, cgName, containerName string, opts api.ContainerLogOpts) (*string, error)
 	ExecuteContainerCommand(ctx context.Context, resourceGroup, cgName, containerName string, containerReq azaci.ContainerExecRequest) (*azaci.ContainerExecResponse, error)
+	ListAvailabilityStatuses(ctx context.Context, resourceGroup string) (*[]resourcehealth.AvailabilityStatus, error)
 }
 
 type AzClientsAPIs struct {
 	ContainersClient     azaci.ContainersClient
 	ContainerGroupClient ContainerGroupsClientWrapper
 	LocationClient       azaci.LocationClient
+	HealthClient         resourcehealth.AvailabilityStatusesClient
+	ResourcesClient      resources.Client
+	MetricsClient        insights.MetricsClient
+
+	cgCache *containerGroupCache
 }
 
 func NewAzClientsAPIs(ctx context.Context, azConfig auth.Config) *AzClientsAPIs {
@@ -55,6 +70,20 @@ func NewAzClientsAPIs(ctx context.Context, azConfig auth.Config) *AzClientsAPIs
 	lClient.Client.Authorizer = azConfig.Authorizer
 	obj.LocationClient = lClient
 
+	hClient := resourcehealth.NewAvailabilityStatusesClientWithBaseURI(azConfig.Cloud.Services[cloud.ResourceManager].Endpoint, azConfig.AuthConfig.SubscriptionID)
+	hClient.Authorizer = azConfig.Authorizer
+	obj.HealthClient = hClient
+
+	rClient := resources.NewClientWithBaseURI(azConfig.Cloud.Services[cloud.ResourceManager].Endpoint, azConfig.AuthConfig.SubscriptionID)
+	rClient.Authorizer = azConfig.Authorizer
+	obj.ResourcesClient = rClient
+
+	mClient := insights.NewMetricsClientWithBaseURI(azConfig.Cloud.Services[cloud.ResourceManager].Endpoint, azConfig.AuthConfig.SubscriptionID)
+	mClient.Authorizer = azConfig.Authorizer
+	obj.MetricsClient = mClient
+
+	obj.cgCache = newContainerGroupCache()
+
 	obj.setUserAgent(ctx)
 
 	return &obj
@@ -78,6 +107,21 @@ func (a *AzClientsAPIs) setUserAgent(ctx context.Context) {
 			log.G(ctx).Warnf("an error has occurred while setting user agent to LocationClient", err)
 			return
 		}
+		err = a.HealthClient.AddToUserAgent(ua)
+		if err != nil {
+			log.G(ctx).Warnf("an error has occurred while setting user agent to HealthClient", err)
+			return
+		}
+		err = a.ResourcesClient.AddToUserAgent(ua)
+		if err != nil {
+			log.G(ctx).Warnf("an error has occurred while setting user agent to ResourcesClient", err)
+			return
+		}
+		err = a.MetricsClient.AddToUserAgent(ua)
+		if err != nil {
+			log.G(ctx).Warnf("an error has occurred while setting user agent to MetricsClient", err)
+			return
+		}
 	}
 }
 
@@ -96,7 +140,7 @@ func (a *AzClientsAPIs) GetContainerGroup(ctx context.Context, resourceGroup, co
 	}
 
 	if result.Body == nil {
-		return nil, errors.New("get container group returned an empty body in the response")
+		return nil, errcodes.Errorf(errcodes.MalformedContainerGroup, "get container group returned an empty body in the response")
 	}
 
 	logger.Infof("GetContainerGroup status code: %d", result.StatusCode)
@@ -105,7 +149,7 @@ func (a *AzClientsAPIs) GetContainerGroup(ctx context.Context, resourceGroup, co
 	}
 	var cgw ContainerGroupWrapper
 	if err := json.NewDecoder(result.Body).Decode(&cgw); err != nil {
-		return nil, fmt.Errorf("decoding get container group response body failed: %v", err)
+		return nil, errcodes.Errorf(errcodes.MalformedContainerGroup, "decoding get container group response body failed: %v", err)
 	}
 	return &cgw, nil
 }
@@ -115,7 +159,7 @@ func (a *AzClientsAPIs) CreateContainerGroup(ctx context.Context, resourceGroup,
 	ctx, span := trace.StartSpan(ctx, "aci.CreateContainerGroup")
 	defer span.End()
 
-	cgName := containerGroupName(podNS, podName)
+	cgName := ContainerGroupName(podNS, podName)
 	cg.Name = &cgName
 	logger.Infof("creating container group with name: %s", *cg.Name)
 	err := a.ContainerGroupClient.CreateCG(ctx, resourceGroup, *cg)
@@ -131,12 +175,12 @@ func (a *AzClientsAPIs) GetContainerGroupInfo(ctx context.Context, resourceGroup
 	ctx, span := trace.StartSpan(ctx, "aci.GetContainerGroupInfo")
 	defer span.End()
 
-	cgName := containerGroupName(namespace, name)
+	cgName := ContainerGroupName(namespace, name)
 
-	cg, err := a.ContainerGroupClient.CGClient.Get(ctx, resourceGroup, cgName)
+	cg, err := a.getContainerGroupConditional(ctx, resourceGroup, cgName)
 	if err != nil {
 		if cg.StatusCode == http.StatusNotFound {
-			return nil, errdefs.NotFound(fmt.Sprintf("container group %s is not found", name))
+			return nil, errcodes.Wrap(errcodes.ContainerGroupNotFound, errdefs.NotFound(fmt.Sprintf("container group %s is not found", name)))
 		}
 		return nil, err
 	}
@@ -146,25 +190,91 @@ func (a *AzClientsAPIs) GetContainerGroupInfo(ctx context.Context, resourceGroup
 		return nil, err
 	}
 	if *cg.Tags["NodeName"] != nodeName {
-		return nil, errors.Wrapf(err, "container group %s found with mismatching node", name)
+		return nil, errcodes.Wrap(errcodes.ContainerGroupNotFound, errdefs.NotFoundf("container group %s found with mismatching node", name))
 	}
 
 	return &cg, nil
 }
 
+// GetContainerGroupListResult returns every container group of the resource group. ARM returns
+// the list in pages, the next links are followed until the last page.
 func (a *AzClientsAPIs) GetContainerGroupListResult(ctx context.Context, resourceGroup string) (*[]azaci.ContainerGroup, error) {
 	ctx, span := trace.StartSpan(ctx, "aci.GetContainerGroupListResult")
 	defer span.End()
 
-	cgs, err := a.ContainerGroupClient.CGClient.ListByResourceGroup(ctx, resourceGroup)
+	page, err := a.ContainerGroupClient.CGClient.ListByResourceGroup(ctx, resourceGroup)
 	if err != nil {
 		return nil, err
 	}
 
-	list := cgs.Values()
+	var list []azaci.ContainerGroup
+	for page.NotDone() {
+		list = append(list, page.Values()...)
+		if err := page.NextWithContext(ctx); err != nil {
+			return nil, errors.Wrapf(err, "unable to list the container groups of resource group %s after %d container groups", resourceGroup, len(list))
+		}
+	}
+	return &list, nil
+}
+
+// containerGroupResourceType is the ARM resource type of the container groups.
+const containerGroupResourceType = "Microsoft.ContainerInstance/containerGroups"
+
+// ListContainerGroupsByNodeName returns the container groups of the resource group tagged with the
+// node name. ARM filters the resources by tag, so the virtual nodes sharing a resource group do not
+// each list every container group. Only the ID, name, location and tags of the container groups
+// are set, GetContainerGroupInfo returns their properties.
+func (a *AzClientsAPIs) ListContainerGroupsByNodeName(ctx context.Context, resourceGroup, nodeName string) (*[]azaci.ContainerGroup, error) {
+	ctx, span := trace.StartSpan(ctx, "aci.ListContainerGroupsByNodeName")
+	defer span.End()
+
+	// A tag filter cannot be combined with a resource type filter, other resources tagged with the
+	// node name are skipped here.
+	filter := fmt.Sprintf("tagName eq 'NodeName' and tagValue eq '%s'", strings.ReplaceAll(nodeName, "'", "''"))
+	iter, err := a.ResourcesClient.ListByResourceGroupComplete(ctx, resourceGroup, filter, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	list := []azaci.ContainerGroup{}
+	for ; iter.NotDone(); err = iter.NextWithContext(ctx) {
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to list the container groups of node %s", nodeName)
+		}
+		resource := iter.Value()
+		if resource.Type == nil || !strings.EqualFold(*resource.Type, containerGroupResourceType) {
+			continue
+		}
+		list = append(list, azaci.ContainerGroup{
+			ID:       resource.ID,
+			Name:     resource.Name,
+			Type:     resource.Type,
+			Location: resource.Location,
+			Tags:     resource.Tags,
+		})
+	}
 	return &list, nil
 }
 
+// ListAvailabilityStatuses returns the Resource Health availability statuses of the resources of the
+// resource group, with the planned maintenance and the service issues impacting them.
+func (a *AzClientsAPIs) ListAvailabilityStatuses(ctx context.Context, resourceGroup string) (*[]resourcehealth.AvailabilityStatus, error) {
+	ctx, span := trace.StartSpan(ctx, "aci.ListAvailabilityStatuses")
+	defer span.End()
+
+	iter, err := a.HealthClient.ListByResourceGroupComplete(ctx, resourceGroup, "", "")
+	if err != nil {
+		return nil, err
+	}
+	var statuses []resourcehealth.AvailabilityStatus
+	for ; iter.NotDone(); err = iter.NextWithContext(ctx) {
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, iter.Value())
+	}
+	return &statuses, nil
+}
+
 func (a *AzClientsAPIs) ListCapabilities(ctx context.Context, region string) (*[]azaci.Capabilities, error) {
 	logger := log.G(ctx).WithField("method", "ListCapabilities")
 	ctx, span := trace.StartSpan(ctx, "aci.ListCapabilities")
@@ -195,6 +305,9 @@ func (a *AzClientsAPIs) DeleteContainerGroup(ctx context.Context, resourceGroup,
 	ctx, span := trace.StartSpan(ctx, "aci.DeleteContainerGroup")
 	defer span.End()
 
+	if a.cgCache != nil {
+		a.cgCache.invalidate(resourceGroup, cgName)
+	}
 	deleteFuture, err := a.ContainerGroupClient.CGClient.Delete(ctx, resourceGroup, cgName)
 	if err != nil {
 		logger.Errorf("failed to delete container group %v", cgName)
@@ -209,6 +322,37 @@ func (a *AzClientsAPIs) DeleteContainerGroup(ctx context.Context, resourceGroup,
 	return nil
 }
 
+// UpdateContainerGroupTags replaces the tags of a container group. Its other properties are left
+// unchanged and its containers keep running.
+func (a *AzClientsAPIs) UpdateContainerGroupTags(ctx context.Context, resourceGroup, cgName string, tags map[string]*string) error {
+	ctx, span := trace.StartSpan(ctx, "aci.UpdateContainerGroupTags")
+	defer span.End()
+
+	if a.cgCache != nil {
+		a.cgCache.invalidate(resourceGroup, cgName)
+	}
+	_, err := a.ContainerGroupClient.CGClient.Update(ctx, resourceGroup, cgName, azaci.Resource{Tags: tags})
+	return err
+}
+
+// GetContainerGroupMetrics returns the Azure Monitor metrics of a container group between start and
+// end, averaged per minute.
+func (a *AzClientsAPIs) GetContainerGroupMetrics(ctx context.Context, resourceGroup, containerGroupName string, metricNames []string, start, end time.Time) (*insights.Response, error) {
+	ctx, span := trace.StartSpan(ctx, "aci.GetContainerGroupMetrics")
+	defer span.End()
+
+	resourceURI := fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.ContainerInstance/containerGroups/%s",
+		a.MetricsClient.SubscriptionID, resourceGroup, containerGroupName)
+	timespan := start.UTC().Format(time.RFC3339) + "/" + end.UTC().Format(time.RFC3339)
+	interval := "PT1M"
+	result, err := a.MetricsClient.List(ctx, resourceURI, timespan, &interval, strings.Join(metricNames, ","),
+		"average", nil, "", "", insights.ResultTypeData, "")
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
 func (a *AzClientsAPIs) ListLogs(ctx context.Context, resourceGroup, cgName, containerName string, opts api.ContainerLogOpts) (*string, error) {
 	logger := log.G(ctx).WithField("method", "ListLogs")
 	ctx, span := trace.StartSpan(ctx, "aci.ListLogs")
@@ -258,7 +402,3 @@ func (a *AzClientsAPIs) ExecuteContainerCommand(ctx context.Context, resourceGro
 	logger.Infof("ExecuteContainerCommand status code: %d", result.StatusCode)
 	return &result, nil
 }
-
-func containerGroupName(podNS, podName string) string {
-	return fmt.Sprintf("%s-%s", podNS, podName)
-}
@@ -0,0 +1,66 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2020-10-01/resources"
+	"gotest.tools/assert"
+)
+
+func TestGetContainerGroupListResultFollowsNextLinks(t *testing.T) {
+	var server *httptest.Server
+	requests := 0
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Query().Get("page") {
+		case "":
+			fmt.Fprintf(w, `{"value":[{"name":"ns-a"},{"name":"ns-b"}],"nextLink":"%s/list?page=2"}`, server.URL)
+		case "2":
+			fmt.Fprintf(w, `{"value":[{"name":"ns-c"}],"nextLink":"%s/list?page=3"}`, server.URL)
+		default:
+			w.Write([]byte(`{"value":[{"name":"ns-d"}]}`))
+		}
+	}))
+	defer server.Close()
+
+	a := &AzClientsAPIs{
+		ContainerGroupClient: ContainerGroupsClientWrapper{CGClient: azaci.NewContainerGroupsClientWithBaseURI(server.URL, "sub")},
+	}
+
+	cgs, err := a.GetContainerGroupListResult(context.Background(), "rg")
+	assert.NilError(t, err)
+	var names []string
+	for _, cg := range *cgs {
+		names = append(names, *cg.Name)
+	}
+	assert.DeepEqual(t, []string{"ns-a", "ns-b", "ns-c", "ns-d"}, names)
+	assert.Equal(t, 3, requests)
+}
+
+func TestListContainerGroupsByNodeName(t *testing.T) {
+	var filter string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		filter = r.URL.Query().Get("$filter")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"value":[
+			{"id":"/subscriptions/sub/resourceGroups/rg/providers/Microsoft.ContainerInstance/containerGroups/ns-a","name":"ns-a","type":"Microsoft.ContainerInstance/containerGroups","tags":{"NodeName":"vk's node"}},
+			{"id":"/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/disks/disk","name":"disk","type":"Microsoft.Compute/disks","tags":{"NodeName":"vk's node"}}
+		]}`))
+	}))
+	defer server.Close()
+
+	a := &AzClientsAPIs{ResourcesClient: resources.NewClientWithBaseURI(server.URL, "sub")}
+
+	cgs, err := a.ListContainerGroupsByNodeName(context.Background(), "rg", "vk's node")
+	assert.NilError(t, err)
+	assert.Equal(t, "tagName eq 'NodeName' and tagValue eq 'vk''s node'", filter)
+	assert.Equal(t, 1, len(*cgs), "resources other than container groups should be skipped")
+	assert.Equal(t, "ns-a", *(*cgs)[0].Name)
+	assert.Equal(t, "vk's node", *(*cgs)[0].Tags["NodeName"])
+}
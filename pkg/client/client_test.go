@@ -0,0 +1,40 @@
+package client
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	"gotest.tools/assert"
+)
+
+func TestCreateConfidentialContainerGroupRequest(t *testing.T) {
+	c := &ContainerGroupsClientWrapper{CGClient: azaci.NewContainerGroupsClientWithBaseURI("https://management.azure.com", "sub")}
+	name := "ns-pod"
+	newContainerGroup := func() ContainerGroupWrapper {
+		return ContainerGroupWrapper{
+			Name: &name,
+			ContainerGroupPropertiesWrapper: &ContainerGroupPropertiesWrapper{
+				ContainerGroupProperties: &azaci.ContainerGroupProperties{Sku: azaci.ContainerGroupSkuStandard},
+			},
+		}
+	}
+
+	req, err := c.createOrUpdatePreparerWrapper(context.Background(), "rg", name, newContainerGroup())
+	assert.NilError(t, err)
+	assert.Equal(t, APIVersion, req.URL.Query().Get("api-version"))
+
+	policy := "cG9saWN5"
+	cg := newContainerGroup()
+	cg.ContainerGroupPropertiesWrapper.ContainerGroupProperties.Sku = ContainerGroupSkuConfidential
+	cg.ContainerGroupPropertiesWrapper.ConfidentialComputeProperties = &ConfidentialComputeProperties{CcePolicy: &policy}
+	req, err = c.createOrUpdatePreparerWrapper(context.Background(), "rg", name, cg)
+	assert.NilError(t, err)
+	assert.Equal(t, ConfidentialAPIVersion, req.URL.Query().Get("api-version"), "confidential container groups need a newer API version")
+	body, err := ioutil.ReadAll(req.Body)
+	assert.NilError(t, err)
+	assert.Assert(t, strings.Contains(string(body), `"confidentialComputeProperties":{"ccePolicy":"cG9saWN5"}`), string(body))
+	assert.Assert(t, strings.Contains(string(body), `"sku":"Confidential"`), string(body))
+}
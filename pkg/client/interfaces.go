@@ -2,7 +2,9 @@ package client
 
 import (
 	"context"
+	"time"
 
+	"github.com/Azure/azure-sdk-for-go/services/preview/monitor/mgmt/2021-07-01-preview/insights"
 	stats "github.com/virtual-kubelet/virtual-kubelet/node/api/statsv1alpha1"
 	v1 "k8s.io/api/core/v1"
 )
@@ -17,6 +19,11 @@ type ContainerGroupGetter interface {
 	GetContainerGroup(ctx context.Context, resourceGroup, containerGroupName string) (*ContainerGroupWrapper, error)
 }
 
+// MetricsGetter package dependency: query the Azure Monitor metrics of a Container Group, averaged per minute
+type MetricsGetter interface {
+	GetContainerGroupMetrics(ctx context.Context, resourceGroup, containerGroupName string, metricNames []string, start, end time.Time) (*insights.Response, error)
+}
+
 /*
 there are difference implementation of query Pod's statistics.
 this interface is for mocking in unit test
@@ -0,0 +1,167 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const redactedValue = "REDACTED"
+
+// sensitiveKeys are the JSON property names whose values are never written to a recording bundle.
+var sensitiveKeys = map[string]bool{
+	"password":          true,
+	"securevalue":       true,
+	"workspacekey":      true,
+	"storageaccountkey": true,
+	"protectedsettings": true,
+	"secret":            true,
+	"identitytoken":     true,
+	"registrytoken":     true,
+	"auth":              true,
+}
+
+type recorderKey struct{}
+
+// RecordedExchange is a single sanitized ARM request/response pair.
+type RecordedExchange struct {
+	Timestamp    time.Time       `json:"timestamp"`
+	Method       string          `json:"method"`
+	URL          string          `json:"url"`
+	RequestBody  json.RawMessage `json:"requestBody,omitempty"`
+	StatusCode   int             `json:"statusCode,omitempty"`
+	ResponseBody json.RawMessage `json:"responseBody,omitempty"`
+	Error        string          `json:"error,omitempty"`
+}
+
+// Recorder captures sanitized ARM request/response pairs so a failing operation
+// can be attached to a bug report without sharing credentials.
+type Recorder struct {
+	mu        sync.Mutex
+	Namespace string             `json:"namespace"`
+	PodName   string             `json:"podName"`
+	Exchanges []RecordedExchange `json:"exchanges"`
+}
+
+// NewRecorder creates an empty recorder for the given pod.
+func NewRecorder(namespace, podName string) *Recorder {
+	return &Recorder{
+		Namespace: namespace,
+		PodName:   podName,
+		Exchanges: make([]RecordedExchange, 0),
+	}
+}
+
+// WithRecorder returns a context that makes the ARM calls made with it record into r.
+func WithRecorder(ctx context.Context, r *Recorder) context.Context {
+	return context.WithValue(ctx, recorderKey{}, r)
+}
+
+// RecorderFromContext returns the recorder attached to ctx, or nil if there is none.
+func RecorderFromContext(ctx context.Context) *Recorder {
+	r, _ := ctx.Value(recorderKey{}).(*Recorder)
+	return r
+}
+
+// Record appends a sanitized exchange to the recorder. The response body is
+// restored after being read so callers can still consume it.
+func (r *Recorder) Record(req *http.Request, body interface{}, resp *http.Response, err error) {
+	exchange := RecordedExchange{
+		Timestamp: time.Now().UTC(),
+	}
+	if req != nil {
+		exchange.Method = req.Method
+		if req.URL != nil {
+			exchange.URL = req.URL.String()
+		}
+	}
+	if body != nil {
+		if raw, mErr := json.Marshal(body); mErr == nil {
+			exchange.RequestBody = sanitizeJSON(raw)
+		}
+	}
+	if resp != nil {
+		exchange.StatusCode = resp.StatusCode
+		if resp.Body != nil {
+			raw, rErr := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			resp.Body = ioutil.NopCloser(bytes.NewReader(raw))
+			if rErr == nil && len(raw) > 0 {
+				exchange.ResponseBody = sanitizeJSON(raw)
+			}
+		}
+	}
+	if err != nil {
+		exchange.Error = err.Error()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Exchanges = append(r.Exchanges, exchange)
+}
+
+// WriteBundle writes the recorded exchanges as a JSON bundle into dir and returns the file path.
+func (r *Recorder) WriteBundle(dir string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode recording bundle: %v", err)
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create recording directory %s: %v", dir, err)
+	}
+
+	fileName := fmt.Sprintf("%s-%s-%d.json", r.Namespace, r.PodName, time.Now().Unix())
+	path := filepath.Join(dir, fileName)
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		return "", fmt.Errorf("failed to write recording bundle %s: %v", path, err)
+	}
+	return path, nil
+}
+
+// sanitizeJSON redacts the values of sensitive properties. Payloads that are not
+// valid JSON are dropped entirely, since they cannot be inspected safely.
+func sanitizeJSON(raw []byte) json.RawMessage {
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		quoted, _ := json.Marshal(redactedValue)
+		return quoted
+	}
+	sanitized, err := json.Marshal(redact(v))
+	if err != nil {
+		return nil
+	}
+	return sanitized
+}
+
+func redact(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, val := range t {
+			if sensitiveKeys[strings.ToLower(k)] {
+				t[k] = redactedValue
+				continue
+			}
+			t[k] = redact(val)
+		}
+		return t
+	case []interface{}:
+		for i := range t {
+			t[i] = redact(t[i])
+		}
+		return t
+	default:
+		return v
+	}
+}
@@ -0,0 +1,35 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestSanitizeJSONRedactsSecrets(t *testing.T) {
+	raw := []byte(`{"properties":{"imageRegistryCredentials":[{"server":"myacr.azurecr.io","username":"user","password":"p@ss"}],"containers":[{"name":"c","properties":{"environmentVariables":[{"name":"TOKEN","secureValue":"s3cret"}]}}]}}`)
+
+	sanitized := string(sanitizeJSON(raw))
+
+	assert.Check(t, !strings.Contains(sanitized, "p@ss"), "password should be redacted")
+	assert.Check(t, !strings.Contains(sanitized, "s3cret"), "secure value should be redacted")
+	assert.Check(t, strings.Contains(sanitized, "myacr.azurecr.io"), "non-sensitive values should be kept")
+
+	var v interface{}
+	assert.NilError(t, json.Unmarshal([]byte(sanitized), &v), "sanitized output should be valid JSON")
+}
+
+func TestRecorderFromContext(t *testing.T) {
+	assert.Check(t, RecorderFromContext(context.Background()) == nil, "no recorder expected")
+
+	rec := NewRecorder("ns", "pod")
+	ctx := WithRecorder(context.Background(), rec)
+	assert.Equal(t, rec, RecorderFromContext(ctx))
+
+	rec.Record(nil, map[string]string{"password": "p"}, nil, nil)
+	assert.Equal(t, 1, len(rec.Exchanges))
+	assert.Check(t, !strings.Contains(string(rec.Exchanges[0].RequestBody), `"p"`), "request body should be sanitized")
+}
@@ -0,0 +1,140 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+
+// Package errcodes is the catalogue of the error codes of the provider. The codes prefix the
+// returned errors and the warning events, so users and support can look them up in
+// docs/error-codes.md.
+package errcodes
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Code identifies a class of provider errors. IDs are never reused once published.
+type Code struct {
+	ID   string
+	Name string
+}
+
+func (c Code) String() string {
+	return c.ID + " " + c.Name
+}
+
+// Message prefixes the message with the code, unless it already starts with a code, e.g. when it
+// is the message of an error with a code.
+func (c Code) Message(message string) string {
+	if prefixRegexp.MatchString(message) {
+		return message
+	}
+	return c.ID + ": " + message
+}
+
+var prefixRegexp = regexp.MustCompile(`^ACIP-\d{3}: `)
+
+var catalogue []Code
+
+func register(id, name string) Code {
+	c := Code{ID: id, Name: name}
+	catalogue = append(catalogue, c)
+	return c
+}
+
+// The codes of the provider, documented in docs/error-codes.md. New codes are appended.
+var (
+	InvalidRegion                   = register("ACIP-001", "InvalidRegion")
+	MissingResourceGroup            = register("ACIP-002", "MissingResourceGroup")
+	InvalidConfig                   = register("ACIP-003", "InvalidConfig")
+	NodeDraining                    = register("ACIP-004", "NodeDraining")
+	UnsupportedFields               = register("ACIP-005", "UnsupportedFields")
+	ImageNotPinned                  = register("ACIP-006", "ImageNotPinned")
+	RuntimeClassNotSupported        = register("ACIP-007", "RuntimeClassNotSupported")
+	ExceedsCapabilities             = register("ACIP-008", "ExceedsCapabilities")
+	IncompatibleWindowsImage        = register("ACIP-009", "IncompatibleWindowsImage")
+	GMSANotSupported                = register("ACIP-010", "GMSANotSupported")
+	AdmissionRejected               = register("ACIP-011", "AdmissionRejected")
+	NetworkPolicyNotSupported       = register("ACIP-012", "NetworkPolicyNotSupported")
+	SubnetNotAvailable              = register("ACIP-013", "SubnetNotAvailable")
+	SubnetFull                      = register("ACIP-014", "SubnetFull")
+	SubnetNotDelegated              = register("ACIP-015", "SubnetNotDelegated")
+	InvalidVolume                   = register("ACIP-016", "InvalidVolume")
+	InvalidImagePullSecret          = register("ACIP-017", "InvalidImagePullSecret")
+	GPUNotAvailable                 = register("ACIP-018", "GPUNotAvailable")
+	InvalidEnvironmentVariableNames = register("ACIP-019", "InvalidEnvironmentVariableNames")
+	DownwardAPIFieldUnavailable     = register("ACIP-020", "DownwardAPIFieldUnavailable")
+	InvalidProbe                    = register("ACIP-021", "InvalidProbe")
+	ContainerGroupNotFound          = register("ACIP-022", "ContainerGroupNotFound")
+	ContainerGroupCreateFailed      = register("ACIP-023", "ContainerGroupCreateFailed")
+	MalformedContainerGroup         = register("ACIP-024", "MalformedContainerGroup")
+	ImagePullFailed                 = register("ACIP-025", "ImagePullFailed")
+	ProvisioningFailed              = register("ACIP-026", "ProvisioningFailed")
+	ContainerGroupMigrationFailed   = register("ACIP-027", "ContainerGroupMigrationFailed")
+	StatusUnavailable               = register("ACIP-028", "StatusUnavailable")
+	DrainTimeout                    = register("ACIP-029", "DrainTimeout")
+	NoisyContainerLogs              = register("ACIP-030", "NoisyContainerLogs")
+	ServiceAdvisory                 = register("ACIP-031", "ServiceAdvisory")
+	CapabilityReduced               = register("ACIP-032", "CapabilityReduced")
+	UnsupportedPod                  = register("ACIP-033", "UnsupportedPod")
+	InvalidPod                      = register("ACIP-034", "InvalidPod")
+	ExecSessionLost                 = register("ACIP-035", "ExecSessionLost")
+)
+
+// All returns the codes in the order of their IDs.
+func All() []Code {
+	return append([]Code(nil), catalogue...)
+}
+
+// Error is an error with a code.
+type Error struct {
+	Code Code
+	err  error
+}
+
+func (e *Error) Error() string {
+	return e.Code.Message(e.err.Error())
+}
+
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// Cause lets the errdefs of virtual-kubelet classify the wrapped error, e.g. as invalid input.
+func (e *Error) Cause() error {
+	return e.err
+}
+
+// Errorf formats an error with the code.
+func Errorf(code Code, format string, args ...interface{}) error {
+	return &Error{Code: code, err: fmt.Errorf(format, args...)}
+}
+
+// Wrap attaches the code to the error, unless it has one already.
+func Wrap(code Code, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := Of(err); ok {
+		return err
+	}
+	return &Error{Code: code, err: err}
+}
+
+// Of returns the code of the error. It follows both the wrapped errors of the standard library and
+// the causes of the errdefs of virtual-kubelet.
+func Of(err error) (Code, bool) {
+	for err != nil {
+		switch e := err.(type) {
+		case *Error:
+			return e.Code, true
+		case interface{ Unwrap() error }:
+			err = e.Unwrap()
+		case interface{ Cause() error }:
+			err = e.Cause()
+		default:
+			return Code{}, false
+		}
+	}
+	return Code{}, false
+}
@@ -0,0 +1,62 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package errcodes
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestCatalogue(t *testing.T) {
+	docs, err := os.ReadFile("../../docs/error-codes.md")
+	assert.NilError(t, err)
+
+	idRegexp := regexp.MustCompile(`^ACIP-\d{3}$`)
+	ids := make(map[string]bool)
+	names := make(map[string]bool)
+	for i, code := range All() {
+		assert.Check(t, idRegexp.MatchString(code.ID), "code %s has an invalid ID", code)
+		assert.Check(t, is.Equal(fmt.Sprintf("ACIP-%03d", i+1), code.ID), "codes should be appended in order")
+		assert.Check(t, !ids[code.ID], "ID of code %s is used twice", code)
+		assert.Check(t, !names[code.Name], "name of code %s is used twice", code)
+		ids[code.ID] = true
+		names[code.Name] = true
+		assert.Check(t, strings.Contains(string(docs), fmt.Sprintf("| %s | %s |", code.ID, code.Name)), "code %s is not documented", code)
+	}
+}
+
+func TestWrap(t *testing.T) {
+	assert.Check(t, Wrap(SubnetFull, nil) == nil)
+
+	err := Wrap(InvalidVolume, errdefs.InvalidInput("secret foo is not found"))
+	assert.Check(t, is.Error(err, "ACIP-016: secret foo is not found"))
+	assert.Check(t, errdefs.IsInvalidInput(err), "errdefs should classify the wrapped error")
+	code, ok := Of(err)
+	assert.Check(t, ok)
+	assert.Check(t, is.Equal(InvalidVolume, code))
+
+	assert.Check(t, is.Error(Wrap(UnsupportedFields, err), "ACIP-016: secret foo is not found"), "errors should keep their first code")
+	code, ok = Of(errdefs.AsInvalidInput(fmt.Errorf("pod is invalid: %w", err)))
+	assert.Check(t, ok, "codes should be found through errdefs")
+	assert.Check(t, is.Equal(InvalidVolume, code))
+
+	_, ok = Of(errors.New("no code"))
+	assert.Check(t, !ok)
+}
+
+func TestMessage(t *testing.T) {
+	assert.Check(t, is.Equal("ACIP-014: no addresses left", SubnetFull.Message("no addresses left")))
+	assert.Check(t, is.Equal("ACIP-014: no addresses left", SubnetFull.Message("ACIP-014: no addresses left")))
+	assert.Check(t, is.Equal("ACIP-013: not available", SubnetFull.Message("ACIP-013: not available")), "messages should keep their first code")
+	assert.Check(t, is.Equal("ACIP-014 SubnetFull", SubnetFull.String()))
+}
@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Outcome and latency of the pod admission checks registered by embedders.
+var (
+	admissionChecks = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "aci",
+		Name:      "admission_checks_total",
+		Help:      "Number of pod admission check evaluations by check and result.",
+	}, []string{"check", "result"})
+
+	admissionCheckDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "aci",
+		Name:      "admission_check_duration_seconds",
+		Help:      "Latency of the pod admission checks.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"check"})
+)
+
+func init() {
+	prometheus.MustRegister(admissionChecks, admissionCheckDuration)
+}
+
+// RecordAdmissionCheck records the result and latency of a pod admission check.
+func RecordAdmissionCheck(check string, err error, duration time.Duration) {
+	result := "admitted"
+	if err != nil {
+		result = "rejected"
+	}
+	admissionChecks.WithLabelValues(check, result).Inc()
+	admissionCheckDuration.WithLabelValues(check).Observe(duration.Seconds())
+}
@@ -0,0 +1,76 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	ARMOperationRead  = "read"
+	ARMOperationWrite = "write"
+)
+
+// State of the limiter capping the concurrent ARM operations of the virtual node.
+var (
+	armOperationsQueued = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "aci",
+		Name:      "arm_operations_queued",
+		Help:      "Number of ARM operations waiting for a free slot.",
+	}, []string{"kind"})
+
+	armOperationsInFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "aci",
+		Name:      "arm_operations_in_flight",
+		Help:      "Number of ARM operations in flight.",
+	}, []string{"kind"})
+
+	armOperationWait = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "aci",
+		Name:      "arm_operation_wait_seconds",
+		Help:      "Time ARM operations waited for a free slot.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"kind"})
+
+	armNamespaceOperationsQueued = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "aci",
+		Name:      "arm_namespace_operations_queued",
+		Help:      "Number of ARM operations of a pod namespace waiting for a free slot.",
+	}, []string{"kind", "namespace"})
+
+	armNamespaceOperationWait = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "aci",
+		Name:      "arm_namespace_operation_wait_seconds_total",
+		Help:      "Total time the ARM operations of a pod namespace waited for a free slot.",
+	}, []string{"kind", "namespace"})
+)
+
+func init() {
+	prometheus.MustRegister(armOperationsQueued, armOperationsInFlight, armOperationWait, armNamespaceOperationsQueued, armNamespaceOperationWait)
+}
+
+// SetARMOperations records the number of queued and in flight ARM operations of a kind.
+func SetARMOperations(kind string, queued, inFlight int) {
+	armOperationsQueued.WithLabelValues(kind).Set(float64(queued))
+	armOperationsInFlight.WithLabelValues(kind).Set(float64(inFlight))
+}
+
+// ObserveARMOperationWait records how long an ARM operation waited for a free slot.
+func ObserveARMOperationWait(kind string, wait time.Duration) {
+	armOperationWait.WithLabelValues(kind).Observe(wait.Seconds())
+}
+
+// SetARMNamespaceOperationsQueued records the number of queued ARM operations of a kind for a pod
+// namespace. Namespaces without queued operations are dropped.
+func SetARMNamespaceOperationsQueued(kind, namespace string, queued int) {
+	if queued == 0 {
+		armNamespaceOperationsQueued.DeleteLabelValues(kind, namespace)
+		return
+	}
+	armNamespaceOperationsQueued.WithLabelValues(kind, namespace).Set(float64(queued))
+}
+
+// AddARMNamespaceWait adds the time an ARM operation of a pod namespace waited for a free slot.
+func AddARMNamespaceWait(kind, namespace string, wait time.Duration) {
+	armNamespaceOperationWait.WithLabelValues(kind, namespace).Add(wait.Seconds())
+}
@@ -0,0 +1,153 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+	"github.com/virtual-kubelet/azure-aci/pkg/client"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	stats "github.com/virtual-kubelet/virtual-kubelet/node/api/statsv1alpha1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	metricNetworkBytesReceivedPerSecond    = "NetworkBytesReceivedPerSecond"
+	metricNetworkBytesTransmittedPerSecond = "NetworkBytesTransmittedPerSecond"
+
+	// azureMonitorNetworkInterface is the interface the network metrics of Azure Monitor are reported
+	// for. They cover every interface of the container group.
+	azureMonitorNetworkInterface = "eth0"
+	// networkStatsBackfill is how far back the network bytes of a pod are counted when it is first
+	// reported, and how long the counters of a pod no longer reported are kept.
+	networkStatsBackfill = time.Hour
+)
+
+// networkCounters are the bytes a pod received and transmitted until a minute.
+type networkCounters struct {
+	rxBytes uint64
+	txBytes uint64
+	until   time.Time
+	// queried is the minute Azure Monitor was last queried in.
+	queried time.Time
+}
+
+// azureMonitorMetrics is the implementation of podStatsGetter interface for the container groups
+// without the Real-Time Metrics Extension. Azure Monitor only reports the bytes received and
+// transmitted per second, averaged per minute, so they are added up into the cumulative counters
+// of the kubelet. It has no network errors, which are left unset.
+type azureMonitorMetrics struct {
+	rgName        string
+	metricsGetter client.MetricsGetter
+	// counters caches the networkCounters of the pods by UID.
+	counters *cache.Cache
+	now      func() time.Time
+}
+
+func NewAzureMonitorMetrics(metricsGetter client.MetricsGetter, rgName string) *azureMonitorMetrics {
+	return &azureMonitorMetrics{
+		rgName:        rgName,
+		metricsGetter: metricsGetter,
+		counters:      cache.New(networkStatsBackfill, 10*time.Minute),
+		now:           time.Now,
+	}
+}
+
+// GetPodStats returns the network stats of the pod. Azure Monitor is queried for the minutes
+// completed since the last query, at most once a minute per pod. When the query fails, the last
+// counters are returned.
+func (m *azureMonitorMetrics) GetPodStats(ctx context.Context, pod *v1.Pod) (*stats.PodStats, error) {
+	now := m.now().UTC().Truncate(time.Minute)
+	cacheKey := string(pod.UID)
+	var counters networkCounters
+	if cached, found := m.counters.Get(cacheKey); found {
+		counters = cached.(networkCounters)
+	} else {
+		counters.until = now.Add(-networkStatsBackfill)
+		if pod.Status.StartTime != nil && pod.Status.StartTime.After(counters.until) {
+			counters.until = pod.Status.StartTime.UTC().Truncate(time.Minute)
+		}
+	}
+	if counters.until.Before(now.Add(-networkStatsBackfill)) {
+		// Minutes Azure Monitor never reported are not queried again and again.
+		counters.until = now.Add(-networkStatsBackfill)
+	}
+
+	if counters.until.Before(now) && counters.queried.Before(now) {
+		counters.queried = now
+		if err := m.addNetworkBytes(ctx, pod, &counters, now); err != nil {
+			log.G(ctx).WithError(err).Warnf("failed to query the network metrics of pod %s/%s from Azure Monitor", pod.Namespace, pod.Name)
+		}
+	}
+	m.counters.Set(cacheKey, counters, cache.DefaultExpiration)
+
+	rxBytes, txBytes := counters.rxBytes, counters.txBytes
+	return &stats.PodStats{
+		PodRef: stats.PodReference{
+			Name:      pod.Name,
+			Namespace: pod.Namespace,
+			UID:       string(pod.UID),
+		},
+		StartTime: pod.CreationTimestamp,
+		Network: &stats.NetworkStats{
+			Time: metav1.NewTime(counters.until),
+			InterfaceStats: stats.InterfaceStats{
+				Name:    azureMonitorNetworkInterface,
+				RxBytes: &rxBytes,
+				TxBytes: &txBytes,
+			},
+		},
+	}, nil
+}
+
+// addNetworkBytes adds the bytes of the minutes Azure Monitor reported between the counters and
+// now. Azure Monitor reports the minutes with a delay, the minutes not reported yet are queried
+// again next time.
+func (m *azureMonitorMetrics) addNetworkBytes(ctx context.Context, pod *v1.Pod, counters *networkCounters, now time.Time) error {
+	cgName := client.ContainerGroupName(pod.Namespace, pod.Name)
+	resp, err := m.metricsGetter.GetContainerGroupMetrics(ctx, m.rgName, cgName,
+		[]string{metricNetworkBytesReceivedPerSecond, metricNetworkBytesTransmittedPerSecond}, counters.until, now)
+	if err != nil {
+		return err
+	}
+	if resp == nil || resp.Value == nil {
+		return nil
+	}
+
+	until := counters.until
+	for _, metric := range *resp.Value {
+		if metric.Name == nil || metric.Name.Value == nil || metric.Timeseries == nil {
+			continue
+		}
+		var counter *uint64
+		switch *metric.Name.Value {
+		case metricNetworkBytesReceivedPerSecond:
+			counter = &counters.rxBytes
+		case metricNetworkBytesTransmittedPerSecond:
+			counter = &counters.txBytes
+		default:
+			continue
+		}
+		for _, timeseries := range *metric.Timeseries {
+			if timeseries.Data == nil {
+				continue
+			}
+			for _, value := range *timeseries.Data {
+				if value.TimeStamp == nil || value.Average == nil || *value.Average < 0 {
+					continue
+				}
+				minute := value.TimeStamp.UTC()
+				if minute.Before(counters.until) || !minute.Before(now) {
+					continue
+				}
+				*counter += uint64(*value.Average * time.Minute.Seconds())
+				if end := minute.Add(time.Minute); end.After(until) {
+					until = end
+				}
+			}
+		}
+	}
+	counters.until = until
+	return nil
+}
@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/services/preview/monitor/mgmt/2021-07-01-preview/insights"
+	"github.com/Azure/go-autorest/autorest/date"
+	"github.com/golang/mock/gomock"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func fakeNetworkMetrics(start time.Time, rx, tx []*float64) *insights.Response {
+	metric := func(name string, averages []*float64) insights.Metric {
+		data := make([]insights.MetricValue, 0, len(averages))
+		for i, average := range averages {
+			data = append(data, insights.MetricValue{
+				TimeStamp: &date.Time{Time: start.Add(time.Duration(i) * time.Minute)},
+				Average:   average,
+			})
+		}
+		return insights.Metric{
+			Name:       &insights.LocalizableString{Value: &name},
+			Timeseries: &[]insights.TimeSeriesElement{{Data: &data}},
+		}
+	}
+	return &insights.Response{Value: &[]insights.Metric{
+		metric(metricNetworkBytesReceivedPerSecond, rx),
+		metric(metricNetworkBytesTransmittedPerSecond, tx),
+	}}
+}
+
+func TestAzureMonitorNetworkStats(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	now := time.Date(2022, 1, 2, 3, 30, 20, 0, time.UTC)
+	start := now.Add(-10 * time.Minute).Truncate(time.Minute)
+	pod := fakePod([]string{"pod-1"})[0]
+	pod.Status.StartTime = &metav1.Time{Time: start}
+
+	f := func(v float64) *float64 { return &v }
+	mockedMetricsGetter := NewMockMetricsGetter(ctrl)
+	mockedMetricsGetter.EXPECT().GetContainerGroupMetrics(gomock.Any(), "rg", "ns-pod-1", gomock.Any(), start, now.Truncate(time.Minute)).
+		Return(fakeNetworkMetrics(start, []*float64{f(100), f(10), nil}, []*float64{f(50), f(5), nil}), nil).Times(1)
+
+	azureMonitor := NewAzureMonitorMetrics(mockedMetricsGetter, "rg")
+	azureMonitor.now = func() time.Time { return now }
+	podStats, err := azureMonitor.GetPodStats(context.Background(), pod)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(uint64(6600), *podStats.Network.RxBytes))
+	assert.Check(t, is.Equal(uint64(3300), *podStats.Network.TxBytes))
+	assert.Check(t, podStats.Network.RxErrors == nil, "Azure Monitor has no network errors")
+	assert.Check(t, is.Equal(start.Add(2*time.Minute), podStats.Network.Time.UTC()), "the minutes not reported yet should not be counted")
+
+	// Azure Monitor is queried once a minute.
+	podStats, err = azureMonitor.GetPodStats(context.Background(), pod)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(uint64(6600), *podStats.Network.RxBytes))
+
+	now = now.Add(time.Minute)
+	mockedMetricsGetter.EXPECT().GetContainerGroupMetrics(gomock.Any(), "rg", "ns-pod-1", gomock.Any(), start.Add(2*time.Minute), now.Truncate(time.Minute)).
+		Return(nil, errors.New("throttled")).Times(1)
+	podStats, err = azureMonitor.GetPodStats(context.Background(), pod)
+	assert.NilError(t, err, "failed queries should return the last counters")
+	assert.Check(t, is.Equal(uint64(6600), *podStats.Network.RxBytes))
+
+	now = now.Add(time.Minute)
+	mockedMetricsGetter.EXPECT().GetContainerGroupMetrics(gomock.Any(), "rg", "ns-pod-1", gomock.Any(), start.Add(2*time.Minute), now.Truncate(time.Minute)).
+		Return(fakeNetworkMetrics(start.Add(time.Minute), []*float64{f(1000), f(1)}, []*float64{f(1000), f(2)}), nil).Times(1)
+	podStats, err = azureMonitor.GetPodStats(context.Background(), pod)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(uint64(6660), *podStats.Network.RxBytes), "minutes already counted should not be counted again")
+	assert.Check(t, is.Equal(uint64(3420), *podStats.Network.TxBytes))
+}
+
+func TestPodStatsGetterDeciderAzureMonitor(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cg := fakeContainerGroupWrapper()
+	cg.ContainerGroupPropertiesWrapper.Extensions = cg.ContainerGroupPropertiesWrapper.Extensions[:1]
+	mockedAciCgGetter := NewMockContainerGroupGetter(ctrl)
+	mockedAciCgGetter.EXPECT().GetContainerGroup(gomock.Any(), gomock.Any(), gomock.Any()).Return(cg, nil).Times(1)
+
+	mockedRealtime := NewMockpodStatsGetter(ctrl)
+	mockedAzureMonitor := NewMockpodStatsGetter(ctrl)
+	mockedAzureMonitor.EXPECT().GetPodStats(gomock.Any(), gomock.Any()).Return(fakePodStatus("pod-1", 0), nil).Times(1)
+
+	decider := NewPodStatsGetterDecider(mockedRealtime, mockedAzureMonitor, "rg", mockedAciCgGetter)
+	podStats, err := decider.GetPodStats(context.Background(), fakePod([]string{"pod-1"})[0])
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("pod-1", podStats.PodRef.Name), "container groups without the Real-Time Metrics Extension should use Azure Monitor")
+}
@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Freshness of the ACI capabilities of the region, stale capabilities may reject or admit pods
+// the region no longer supports.
+var (
+	capabilitiesLastRefresh = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "aci",
+		Name:      "capabilities_last_refresh_timestamp_seconds",
+		Help:      "Unix time of the last successful refresh of the ACI capabilities.",
+	})
+
+	capabilitiesRefreshFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "aci",
+		Name:      "capabilities_refresh_failures_total",
+		Help:      "Number of failed refreshes of the ACI capabilities.",
+	})
+
+	capabilityChanges = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "aci",
+		Name:      "capability_changes_total",
+		Help:      "Number of changes of the ACI capabilities of the region by kind of change.",
+	}, []string{"change"})
+)
+
+func init() {
+	prometheus.MustRegister(capabilitiesLastRefresh, capabilitiesRefreshFailures, capabilityChanges)
+}
+
+// RecordCapabilitiesRefresh records the outcome of a refresh of the ACI capabilities.
+func RecordCapabilitiesRefresh(err error, now time.Time) {
+	if err != nil {
+		capabilitiesRefreshFailures.Inc()
+		return
+	}
+	capabilitiesLastRefresh.Set(float64(now.Unix()))
+}
+
+// RecordCapabilityChange records a capability the region gained, lost, reduced or increased.
+func RecordCapabilityChange(change string) {
+	capabilityChanges.WithLabelValues(change).Inc()
+}
@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Names of the CFS throttling metrics of the containers, as cAdvisor reports them.
+const (
+	cpuCFSPeriodsMetric          = "cpu_cfs_periods"
+	cpuCFSThrottledPeriodsMetric = "cpu_cfs_throttled_periods"
+	cpuCFSThrottledSecondsMetric = "cpu_cfs_throttled_seconds"
+)
+
+// Cgroup stats of the containers scraped from the realtime metrics extension, the counterparts of
+// the container_* metrics of cAdvisor. The extension reports cumulative values, which are exported
+// as they are.
+var (
+	containerCPUCFSPeriods = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "aci",
+		Name:      "container_" + cpuCFSPeriodsMetric,
+		Help:      "Cumulative number of elapsed CFS enforcement periods of the containers with a CPU limit.",
+	}, []string{"namespace", "pod", "container"})
+
+	containerCPUCFSThrottledPeriods = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "aci",
+		Name:      "container_" + cpuCFSThrottledPeriodsMetric,
+		Help:      "Cumulative number of CFS enforcement periods the containers were throttled in.",
+	}, []string{"namespace", "pod", "container"})
+
+	containerCPUCFSThrottledSeconds = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "aci",
+		Name:      "container_" + cpuCFSThrottledSecondsMetric,
+		Help:      "Cumulative time the containers were throttled for.",
+	}, []string{"namespace", "pod", "container"})
+
+	containerMemoryWorkingSet = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "aci",
+		Name:      "container_memory_working_set_bytes",
+		Help:      "Working set memory of the containers, the memory usage the OOM killer and the evictions are based on.",
+	}, []string{"namespace", "pod", "container"})
+
+	containerMemoryRSS = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "aci",
+		Name:      "container_memory_rss_bytes",
+		Help:      "Anonymous and swap cache memory of the containers.",
+	}, []string{"namespace", "pod", "container"})
+)
+
+func init() {
+	prometheus.MustRegister(containerCPUCFSPeriods, containerCPUCFSThrottledPeriods, containerCPUCFSThrottledSeconds, containerMemoryWorkingSet, containerMemoryRSS)
+}
+
+// containerSeries identifies the cgroup metrics of a container.
+type containerSeries struct {
+	namespace string
+	pod       string
+	container string
+}
+
+// setContainerCgroupStats exports the cgroup stats of a container. The throttling metrics are only
+// exported for the containers the extension reports it for, those with a CPU limit.
+func setContainerCgroupStats(series containerSeries, container *containerStats) {
+	labels := []string{series.namespace, series.pod, series.container}
+	containerMemoryWorkingSet.WithLabelValues(labels...).Set(float64(container.Memory.WorkingSetBytes))
+	containerMemoryRSS.WithLabelValues(labels...).Set(float64(container.Memory.RSSBytes))
+	if throttling := container.CPU.Throttling; throttling != nil {
+		containerCPUCFSPeriods.WithLabelValues(labels...).Set(float64(throttling.Periods))
+		containerCPUCFSThrottledPeriods.WithLabelValues(labels...).Set(float64(throttling.ThrottledPeriods))
+		containerCPUCFSThrottledSeconds.WithLabelValues(labels...).Set(float64(throttling.ThrottledTimeNanoSeconds) / float64(time.Second))
+	}
+}
+
+// forgetContainerCgroupStats drops the cgroup stats of a container that is no longer scraped.
+func forgetContainerCgroupStats(series containerSeries) {
+	labels := []string{series.namespace, series.pod, series.container}
+	for _, gauge := range []*prometheus.GaugeVec{containerCPUCFSPeriods, containerCPUCFSThrottledPeriods, containerCPUCFSThrottledSeconds, containerMemoryWorkingSet, containerMemoryRSS} {
+		gauge.DeleteLabelValues(labels...)
+	}
+}
@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	DeletionResultDeleted = "deleted"
+	DeletionResultFailed  = "failed"
+)
+
+// Progress of the queue of container group deletions, e.g. while a namespace is deleted.
+var (
+	podDeletionsPending = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "aci",
+		Name:      "pod_deletions_pending",
+		Help:      "Number of pod deletions waiting for their turn.",
+	})
+
+	podDeletions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "aci",
+		Name:      "pod_deletions_total",
+		Help:      "Number of container groups deleted for pods by result.",
+	}, []string{"result"})
+)
+
+func init() {
+	prometheus.MustRegister(podDeletionsPending, podDeletions)
+}
+
+// SetPodDeletionsPending records the number of pod deletions waiting for their turn.
+func SetPodDeletionsPending(pending int) {
+	podDeletionsPending.Set(float64(pending))
+}
+
+// RecordPodDeletion records the result of the deletion of the container group of a pod.
+func RecordPodDeletion(err error) {
+	result := DeletionResultDeleted
+	if err != nil {
+		result = DeletionResultFailed
+	}
+	podDeletions.WithLabelValues(result).Inc()
+}
@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+)
+
+const (
+	OperationExec        = "exec"
+	OperationLogs        = "logs"
+	OperationPortForward = "port-forward"
+)
+
+// Counters of the interactive calls proxied to ACI, labeled by pod namespace so the
+// teams driving exec and logs load through the virtual node can be identified.
+var (
+	interactiveRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "aci",
+		Name:      "interactive_requests_total",
+		Help:      "Number of exec sessions and log requests served by the virtual node.",
+	}, []string{"namespace", "operation"})
+
+	interactiveFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "aci",
+		Name:      "interactive_failures_total",
+		Help:      "Number of exec sessions and log requests that failed.",
+	}, []string{"namespace", "operation"})
+
+	interactiveBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "aci",
+		Name:      "interactive_streamed_bytes_total",
+		Help:      "Number of bytes streamed back to clients by exec sessions and log requests.",
+	}, []string{"namespace", "operation"})
+)
+
+func init() {
+	prometheus.MustRegister(interactiveRequests, interactiveFailures, interactiveBytes)
+}
+
+// RecordInteractiveRequest counts an exec session or log request and its failure.
+// A client going away is not a failure.
+func RecordInteractiveRequest(namespace, operation string, err error) {
+	interactiveRequests.WithLabelValues(namespace, operation).Inc()
+	if err != nil && !errors.Is(err, context.Canceled) {
+		interactiveFailures.WithLabelValues(namespace, operation).Inc()
+	}
+}
+
+// AddInteractiveBytes counts bytes streamed to the client of an exec session or log request.
+func AddInteractiveBytes(namespace, operation string, n int64) {
+	if n > 0 {
+		interactiveBytes.WithLabelValues(namespace, operation).Add(float64(n))
+	}
+}
+
+// ServePrometheus exposes the registered Prometheus metrics on addr until ctx is done.
+func ServePrometheus(ctx context.Context, addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	server := &http.Server{Addr: addr, Handler: mux}
+
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.G(ctx).WithError(err).Error("failed to serve prometheus metrics")
+	}
+}
@@ -0,0 +1,21 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gotest.tools/assert"
+)
+
+func TestRecordInteractiveRequest(t *testing.T) {
+	RecordInteractiveRequest("team-a", OperationExec, nil)
+	RecordInteractiveRequest("team-a", OperationExec, errors.New("websocket closed"))
+	RecordInteractiveRequest("team-a", OperationExec, context.Canceled)
+	AddInteractiveBytes("team-a", OperationLogs, 42)
+
+	assert.Equal(t, 3.0, testutil.ToFloat64(interactiveRequests.WithLabelValues("team-a", OperationExec)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(interactiveFailures.WithLabelValues("team-a", OperationExec)))
+	assert.Equal(t, 42.0, testutil.ToFloat64(interactiveBytes.WithLabelValues("team-a", OperationLogs)))
+}
@@ -0,0 +1,31 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Log volume of the containers, an estimate of what Log Analytics ingests for them, so the
+// containers driving the ingestion costs can be identified.
+var (
+	containerLogBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "aci",
+		Name:      "container_log_bytes_total",
+		Help:      "Estimated number of bytes written to stdout and stderr by the containers.",
+	}, []string{"namespace", "pod", "container"})
+)
+
+func init() {
+	prometheus.MustRegister(containerLogBytes)
+}
+
+// AddContainerLogBytes counts bytes written to the log of a container.
+func AddContainerLogBytes(namespace, pod, container string, n int64) {
+	if n > 0 {
+		containerLogBytes.WithLabelValues(namespace, pod, container).Add(float64(n))
+	}
+}
+
+// ForgetContainerLogBytes drops the log volume of a container that is gone.
+func ForgetContainerLogBytes(namespace, pod, container string) {
+	containerLogBytes.DeleteLabelValues(namespace, pod, container)
+}
@@ -2,7 +2,6 @@ package metrics
 
 import (
 	"context"
-	"fmt"
 	"net/http"
 	"sync"
 	"time"
@@ -29,7 +28,7 @@ type ACIPodMetricsProvider struct {
 	podStatsGetter client.PodStatsGetter
 }
 
-func NewACIPodMetricsProvider(nodeName, aciResourcegroup string, podGetter client.PodGetter, aciCGGetter client.ContainerGroupGetter) *ACIPodMetricsProvider {
+func NewACIPodMetricsProvider(nodeName, aciResourcegroup string, podGetter client.PodGetter, aciCGGetter client.ContainerGroupGetter, aciCGMetricsGetter client.MetricsGetter) *ACIPodMetricsProvider {
 	provider := ACIPodMetricsProvider{
 		nodeName:    nodeName,
 		podGetter:   podGetter,
@@ -39,7 +38,7 @@ func NewACIPodMetricsProvider(nodeName, aciResourcegroup string, podGetter clien
 	realTimeGetter := WrapCachedPodStatsGetter(
 		5,
 		NewRealTimeMetrics())
-	provider.podStatsGetter = NewPodStatsGetterDecider(realTimeGetter, aciResourcegroup, aciCGGetter)
+	provider.podStatsGetter = NewPodStatsGetterDecider(realTimeGetter, NewAzureMonitorMetrics(aciCGMetricsGetter, aciResourcegroup), aciResourcegroup, aciCGGetter)
 	return &provider
 }
 
@@ -96,7 +95,9 @@ func (p *ACIPodMetricsProvider) GetStatsSummary(ctx context.Context) (summary *s
 				return errors.Wrapf(err, "error fetching metrics for pods '%s'", pod.Name)
 			}
 
-			chResult <- *podMetrics
+			if podMetrics != nil {
+				chResult <- *podMetrics
+			}
 			return nil
 		})
 	}
@@ -122,18 +123,20 @@ func (p *ACIPodMetricsProvider) GetStatsSummary(ctx context.Context) (summary *s
 }
 
 type podStatsGetterDecider struct {
-	realTimeGetter client.PodStatsGetter
-	rgName         string
-	aciCGGetter    client.ContainerGroupGetter
-	cache          *cache.Cache
+	realTimeGetter     client.PodStatsGetter
+	azureMonitorGetter client.PodStatsGetter
+	rgName             string
+	aciCGGetter        client.ContainerGroupGetter
+	cache              *cache.Cache
 }
 
-func NewPodStatsGetterDecider(realTimeGetter client.PodStatsGetter, rgName string, aciCGGetter client.ContainerGroupGetter) *podStatsGetterDecider {
+func NewPodStatsGetterDecider(realTimeGetter, azureMonitorGetter client.PodStatsGetter, rgName string, aciCGGetter client.ContainerGroupGetter) *podStatsGetterDecider {
 	decider := &podStatsGetterDecider{
-		realTimeGetter: realTimeGetter,
-		rgName:         rgName,
-		aciCGGetter:    aciCGGetter,
-		cache:          cache.New(ContainerGroupCacheTTLSeconds*time.Second, 10*time.Minute),
+		realTimeGetter:     realTimeGetter,
+		azureMonitorGetter: azureMonitorGetter,
+		rgName:             rgName,
+		aciCGGetter:        aciCGGetter,
+		cache:              cache.New(ContainerGroupCacheTTLSeconds*time.Second, 10*time.Minute),
 	}
 	return decider
 }
@@ -161,6 +164,9 @@ func (decider *podStatsGetterDecider) GetPodStats(ctx context.Context, pod *v1.P
 	if useRealTime {
 		logger.Infof("use Real-Time Metrics Extension for pod '%s'", pod.Name)
 		return decider.realTimeGetter.GetPodStats(ctx, pod)
+	} else if decider.azureMonitorGetter != nil {
+		logger.Debugf("use Azure Monitor network metrics for pod '%s'", pod.Name)
+		return decider.azureMonitorGetter.GetPodStats(ctx, pod)
 	} else {
 		logger.Infof("no metrics has been setup for pod '%s'", pod.Name)
 		return nil, nil
@@ -168,7 +174,7 @@ func (decider *podStatsGetterDecider) GetPodStats(ctx context.Context, pod *v1.P
 }
 
 func (decider *podStatsGetterDecider) getContainerGroupFromPod(ctx context.Context, pod *v1.Pod) (*client.ContainerGroupWrapper, error) {
-	cgName := containerGroupName(pod.Namespace, pod.Name)
+	cgName := client.ContainerGroupName(pod.Namespace, pod.Name)
 	cacheKey := string(pod.UID)
 	aciContainerGroup, found := decider.cache.Get(cacheKey)
 	if found {
@@ -185,10 +191,6 @@ func (decider *podStatsGetterDecider) getContainerGroupFromPod(ctx context.Conte
 	return aciCG, nil
 }
 
-func containerGroupName(podNS, podName string) string {
-	return fmt.Sprintf("%s-%s", podNS, podName)
-}
-
 func newUInt64Pointer(value int) *uint64 {
 	var u = uint64(value)
 	return &u
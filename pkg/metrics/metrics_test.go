@@ -50,7 +50,7 @@ func TestGetStatsSummary(t *testing.T) {
 
 			mockedPodGetter := NewMockPodGetter(ctrl)
 			mockedPodStatsGetter := NewMockpodStatsGetter(ctrl)
-			podMetricsProvider := NewACIPodMetricsProvider("node-1", "rg", mockedPodGetter, nil)
+			podMetricsProvider := NewACIPodMetricsProvider("node-1", "rg", mockedPodGetter, nil, nil)
 			podMetricsProvider.podStatsGetter = mockedPodStatsGetter
 			mockedPodGetter.EXPECT().GetPods().Return(fakePod(getMapKeys(test))).AnyTimes()
 			for podName, cpu := range test {
@@ -81,7 +81,7 @@ func TestPodStatsGetterDecider(t *testing.T) {
 		mockedRealtime.EXPECT().GetPodStats(gomock.Any(), gomock.Any()).Return(fakePodStatus("pod-1", 0), nil).Times(1)
 		mockedRealtime.EXPECT().GetPodStats(gomock.Any(), gomock.Any()).Return(fakePodStatus("pod-1", 0), nil).Times(1)
 
-		decider := NewPodStatsGetterDecider(mockedRealtime, "rg", mockedAciCgGetter)
+		decider := NewPodStatsGetterDecider(mockedRealtime, nil, "rg", mockedAciCgGetter)
 		ctx := context.Background()
 		pod := fakePod([]string{"pod-1"})[0]
 		decider.GetPodStats(ctx, pod)
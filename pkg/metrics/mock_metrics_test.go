@@ -7,7 +7,9 @@ package metrics
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
+	insights "github.com/Azure/azure-sdk-for-go/services/preview/monitor/mgmt/2021-07-01-preview/insights"
 	gomock "github.com/golang/mock/gomock"
 	"github.com/virtual-kubelet/azure-aci/pkg/client"
 	statsv1alpha1 "github.com/virtual-kubelet/virtual-kubelet/node/api/statsv1alpha1"
@@ -74,6 +76,21 @@ func (m *MockMetricsGetter) EXPECT() *MockMetricsGetterMockRecorder {
 	return m.recorder
 }
 
+// GetContainerGroupMetrics mocks base method.
+func (m *MockMetricsGetter) GetContainerGroupMetrics(ctx context.Context, resourceGroup, containerGroupName string, metricNames []string, start, end time.Time) (*insights.Response, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetContainerGroupMetrics", ctx, resourceGroup, containerGroupName, metricNames, start, end)
+	ret0, _ := ret[0].(*insights.Response)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetContainerGroupMetrics indicates an expected call of GetContainerGroupMetrics.
+func (mr *MockMetricsGetterMockRecorder) GetContainerGroupMetrics(ctx, resourceGroup, containerGroupName, metricNames, start, end interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetContainerGroupMetrics", reflect.TypeOf((*MockMetricsGetter)(nil).GetContainerGroupMetrics), ctx, resourceGroup, containerGroupName, metricNames, start, end)
+}
+
 // MockContainerGroupGetter is a mock of ContainerGroupGetter interface.
 type MockContainerGroupGetter struct {
 	ctrl     *gomock.Controller
@@ -5,7 +5,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/patrickmn/go-cache"
@@ -27,6 +29,10 @@ type realtimeMetricsExtensionPodStats struct {
 	// Stats pertaining to network resources.
 	// +optional
 	Network networkStats `json:"network,omitempty"`
+	// Stats pertaining to the filesystem usage of the containers and the emptyDir volumes.
+	// Computed from the containers when the extension does not report it.
+	// +optional
+	EphemeralStorage *fsStats `json:"ephemeral-storage,omitempty"`
 }
 
 type containerStats struct {
@@ -38,11 +44,65 @@ type containerStats struct {
 	// Stats pertaining to memory (RAM) resources.
 	// +optional
 	Memory memoryStats `json:"memory"`
+	// Stats pertaining to the GPUs of GPU container groups.
+	// +optional
+	Accelerators []acceleratorStats `json:"accelerators,omitempty"`
+	// Stats pertaining to the filesystem of the container write layer.
+	// +optional
+	Rootfs *fsStats `json:"rootfs,omitempty"`
+	// Stats pertaining to the filesystem usage of the container logs.
+	// +optional
+	Logs *fsStats `json:"logs,omitempty"`
+}
+
+// fsStats contains data about filesystem usage.
+type fsStats struct {
+	// Storage space available (bytes) for the filesystem.
+	AvailableBytes *uint64 `json:"availableBytes,omitempty"`
+	// Total capacity (bytes) of the filesystem.
+	CapacityBytes *uint64 `json:"capacityBytes,omitempty"`
+	// Bytes used for the measured task on the filesystem.
+	UsedBytes *uint64 `json:"usedBytes,omitempty"`
+	// Free inodes in the filesystem.
+	InodesFree *uint64 `json:"inodesFree,omitempty"`
+	// Total inodes in the filesystem.
+	Inodes *uint64 `json:"inodes,omitempty"`
+	// Inodes used by the measured task.
+	InodesUsed *uint64 `json:"inodesUsed,omitempty"`
+}
+
+// acceleratorStats contains data about a GPU attached to the container.
+type acceleratorStats struct {
+	// Make of the accelerator, e.g. nvidia.
+	Make string `json:"make"`
+	// Model of the accelerator, e.g. tesla-v100.
+	Model string `json:"model"`
+	// ID of the accelerator.
+	ID string `json:"id"`
+	// Total accelerator memory in bytes.
+	MemoryTotal uint64 `json:"memoryTotal"`
+	// Accelerator memory allocated in bytes.
+	MemoryUsed uint64 `json:"memoryUsed"`
+	// Percent of time over the past sample period during which the accelerator was actively processing.
+	DutyCycle uint64 `json:"dutyCycle"`
 }
 
 type cpuStats struct {
 	// Cumulative CPU usage (sum across all cores) since object creation.
 	UsageCoreNanoSeconds uint64 `json:"usageCoreNanoSeconds"`
+	// CFS throttling of the container cgroup, from its cpu.stat.
+	// +optional
+	Throttling *cpuThrottlingStats `json:"throttling,omitempty"`
+}
+
+// cpuThrottlingStats contains data about the CFS bandwidth control of a cgroup with a CPU limit.
+type cpuThrottlingStats struct {
+	// Cumulative number of enforcement periods elapsed.
+	Periods uint64 `json:"periods"`
+	// Cumulative number of periods the cgroup was throttled in.
+	ThrottledPeriods uint64 `json:"throttledPeriods"`
+	// Cumulative time the cgroup was throttled for.
+	ThrottledTimeNanoSeconds uint64 `json:"throttledTimeNanoSeconds"`
 }
 
 // memoryStats contains data about memory usage.
@@ -55,6 +115,15 @@ type memoryStats struct {
 	// The amount of anonymous and swap cache memory (includes transparent
 	// hugepages).
 	RSSBytes uint64 `json:"rssBytes"`
+	// Available memory for use, the memory limit of the cgroup minus its working set.
+	// +optional
+	AvailableBytes *uint64 `json:"availableBytes,omitempty"`
+	// Cumulative number of minor page faults.
+	// +optional
+	PageFaults *uint64 `json:"pageFaults,omitempty"`
+	// Cumulative number of major page faults.
+	// +optional
+	MajorPageFaults *uint64 `json:"majorPageFaults,omitempty"`
 }
 
 // networkStats contains data about network resources.
@@ -81,6 +150,15 @@ type interfaceStats struct {
 	TxErrors uint64 `json:"txErrors"`
 }
 
+const (
+	// realTimeMetricsPort is the port the Real Time Metrics Extension serves the stats of the
+	// container group on, on its IP address.
+	realTimeMetricsPort = 18899
+	// realTimeMetricsTimeout bounds a scrape, so an unreachable container group does not hold the
+	// stats summary of the node.
+	realTimeMetricsTimeout = 5 * time.Second
+)
+
 type realTimeMetrics struct {
 	// this cache is for calculating UsageNanoCores. Real Time Metrics Extension
 	// only return cumulative UsageCoreNanoSeconds. However UsageNanoCores is a
@@ -88,36 +166,64 @@ type realTimeMetrics struct {
 	// So we need to cache the last value of UsageCoreNanoSeconds and calcuate the average during
 	// the last time windows
 	cpuStatsCache *cache.Cache
+	// cgroupSeries holds the containers whose cgroup stats are exported, so the stats of the
+	// containers no longer scraped are dropped once they expire.
+	cgroupSeries *cache.Cache
+	client       *http.Client
+	port         int
 }
 
 func NewRealTimeMetrics() *realTimeMetrics {
+	cgroupSeries := cache.New(time.Minute*10, time.Minute*10)
+	cgroupSeries.OnEvicted(func(_ string, series interface{}) {
+		forgetContainerCgroupStats(series.(containerSeries))
+	})
 	return &realTimeMetrics{
 		cpuStatsCache: cache.New(time.Minute*10, time.Minute*10),
+		cgroupSeries:  cgroupSeries,
+		client:        &http.Client{Timeout: realTimeMetricsTimeout},
+		port:          realTimeMetricsPort,
 	}
 }
 
 // GetPodStats the implementation of podStatsGetter interface base on ACI's Real-Time Metrics Extension
 func (realTime *realTimeMetrics) GetPodStats(ctx context.Context, pod *v1.Pod) (*stats.PodStats, error) {
-	realtimeExtensionPodStats, err := getRealTimeExtensionPodStats(ctx, pod)
+	realtimeExtensionPodStats, err := realTime.getRealTimeExtensionPodStats(ctx, pod)
 	if err != nil {
 		return nil, errors.Wrapf(err, "error fetching pod '%s' statsistics from Real Time Extension", pod.Name)
 	}
 	result := extensionPodStatsToKubeletPodStats(pod, realtimeExtensionPodStats)
 	realTime.populateUsageNanocores(pod, realtimeExtensionPodStats, result)
+	realTime.exportCgroupStats(pod, realtimeExtensionPodStats)
 	return result, nil
 }
 
-func getRealTimeExtensionPodStats(ctx context.Context, pod *v1.Pod) (*realtimeMetricsExtensionPodStats, error) {
+// exportCgroupStats exports the cgroup stats of the containers of the pod to Prometheus.
+func (realTime *realTimeMetrics) exportCgroupStats(pod *v1.Pod, realtimePodStats *realtimeMetricsExtensionPodStats) {
+	for i := range realtimePodStats.Containers {
+		container := &realtimePodStats.Containers[i]
+		series := containerSeries{namespace: pod.Namespace, pod: pod.Name, container: container.Name}
+		setContainerCgroupStats(series, container)
+		realTime.cgroupSeries.SetDefault(pod.Namespace+"/"+pod.Name+"/"+container.Name, series)
+	}
+}
+
+func (realTime *realTimeMetrics) getRealTimeExtensionPodStats(ctx context.Context, pod *v1.Pod) (*realtimeMetricsExtensionPodStats, error) {
 	if pod.Status.Phase != v1.PodRunning {
 		return nil, errors.Errorf("invalid parameter in getRealTimePodStats, only Running pod allow to query realtime statistics")
 	}
-	url := fmt.Sprintf("http://%s:18899/v1/stats", pod.Status.PodIP)
-	resp, err := http.Get(url)
+	url := fmt.Sprintf("http://%s/v1/stats", net.JoinHostPort(pod.Status.PodIP, strconv.Itoa(realTime.port)))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create the request to Real Time Metrics Extension endpoint %s", url)
+	}
+	resp, err := realTime.client.Do(req)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to request to Real Time Metrics Extension endpoint %s", url)
 	}
-	if resp.Body != nil {
-		defer resp.Body.Close()
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("Real Time Metrics Extension endpoint %s returned status code %d", url, resp.StatusCode)
 	}
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
@@ -146,12 +252,7 @@ func extensionPodStatsToKubeletPodStats(pod *v1.Pod, realtimePodStats *realtimeM
 			UsageNanoCores:       newUInt64Pointer(0),
 			UsageCoreNanoSeconds: &realtimePodStats.CPU.UsageCoreNanoSeconds,
 		},
-		Memory: &stats.MemoryStats{
-			Time:            statsTime,
-			UsageBytes:      &realtimePodStats.Memory.UsageBytes,
-			RSSBytes:        &realtimePodStats.Memory.RSSBytes,
-			WorkingSetBytes: &realtimePodStats.Memory.WorkingSetBytes,
-		},
+		Memory: extensionMemoryStatsToKubeletMemoryStats(statsTime, &realtimePodStats.Memory),
 		Network: &stats.NetworkStats{
 			Time: statsTime,
 			InterfaceStats: stats.InterfaceStats{
@@ -165,6 +266,7 @@ func extensionPodStatsToKubeletPodStats(pod *v1.Pod, realtimePodStats *realtimeM
 	}
 	result.Containers = make([]stats.ContainerStats, 0)
 	for _, extensionContainer := range realtimePodStats.Containers {
+		extensionContainer := extensionContainer
 		result.Containers = append(result.Containers, stats.ContainerStats{
 			Name:      extensionContainer.Name,
 			StartTime: pod.CreationTimestamp,
@@ -173,14 +275,17 @@ func extensionPodStatsToKubeletPodStats(pod *v1.Pod, realtimePodStats *realtimeM
 				UsageNanoCores:       newUInt64Pointer(0),
 				UsageCoreNanoSeconds: &extensionContainer.CPU.UsageCoreNanoSeconds,
 			},
-			Memory: &stats.MemoryStats{
-				Time:            statsTime,
-				UsageBytes:      &extensionContainer.Memory.UsageBytes,
-				RSSBytes:        &extensionContainer.Memory.RSSBytes,
-				WorkingSetBytes: &extensionContainer.Memory.WorkingSetBytes,
-			},
+			Memory:             extensionMemoryStatsToKubeletMemoryStats(statsTime, &extensionContainer.Memory),
+			Accelerators:       extensionAcceleratorsToKubeletAccelerators(extensionContainer.Accelerators),
+			Rootfs:             extensionFsStatsToKubeletFsStats(statsTime, extensionContainer.Rootfs),
+			Logs:               extensionFsStatsToKubeletFsStats(statsTime, extensionContainer.Logs),
+			UserDefinedMetrics: extensionThrottlingToUserDefinedMetrics(statsTime, extensionContainer.CPU.Throttling),
 		})
 	}
+	result.EphemeralStorage = extensionFsStatsToKubeletFsStats(statsTime, realtimePodStats.EphemeralStorage)
+	if result.EphemeralStorage == nil {
+		result.EphemeralStorage = ephemeralStorageOfContainers(statsTime, result.Containers)
+	}
 
 	result.Network.Interfaces = make([]stats.InterfaceStats, 0)
 	for _, extensionNetworkInterface := range realtimePodStats.Network.Interfaces {
@@ -196,6 +301,107 @@ func extensionPodStatsToKubeletPodStats(pod *v1.Pod, realtimePodStats *realtimeM
 	return &result
 }
 
+func extensionMemoryStatsToKubeletMemoryStats(statsTime metav1.Time, memory *memoryStats) *stats.MemoryStats {
+	return &stats.MemoryStats{
+		Time:            statsTime,
+		AvailableBytes:  memory.AvailableBytes,
+		UsageBytes:      &memory.UsageBytes,
+		RSSBytes:        &memory.RSSBytes,
+		WorkingSetBytes: &memory.WorkingSetBytes,
+		PageFaults:      memory.PageFaults,
+		MajorPageFaults: memory.MajorPageFaults,
+	}
+}
+
+// extensionThrottlingToUserDefinedMetrics converts the CFS throttling of a container, which has no
+// field in the summary, into the cumulative metrics cAdvisor reports it with.
+func extensionThrottlingToUserDefinedMetrics(statsTime metav1.Time, throttling *cpuThrottlingStats) []stats.UserDefinedMetric {
+	if throttling == nil {
+		return nil
+	}
+	metric := func(name, units string, value float64) stats.UserDefinedMetric {
+		return stats.UserDefinedMetric{
+			UserDefinedMetricDescriptor: stats.UserDefinedMetricDescriptor{
+				Name:  name,
+				Type:  stats.MetricCumulative,
+				Units: units,
+			},
+			Time:  statsTime,
+			Value: value,
+		}
+	}
+	return []stats.UserDefinedMetric{
+		metric(cpuCFSPeriodsMetric, "periods", float64(throttling.Periods)),
+		metric(cpuCFSThrottledPeriodsMetric, "periods", float64(throttling.ThrottledPeriods)),
+		metric(cpuCFSThrottledSecondsMetric, "seconds", float64(throttling.ThrottledTimeNanoSeconds)/float64(time.Second)),
+	}
+}
+
+// extensionAcceleratorsToKubeletAccelerators converts the GPU stats of a container, the summary
+// omits them for containers without GPU.
+func extensionAcceleratorsToKubeletAccelerators(accelerators []acceleratorStats) []stats.AcceleratorStats {
+	if len(accelerators) == 0 {
+		return nil
+	}
+	result := make([]stats.AcceleratorStats, 0, len(accelerators))
+	for _, accelerator := range accelerators {
+		result = append(result, stats.AcceleratorStats{
+			Make:        accelerator.Make,
+			Model:       accelerator.Model,
+			ID:          accelerator.ID,
+			MemoryTotal: accelerator.MemoryTotal,
+			MemoryUsed:  accelerator.MemoryUsed,
+			DutyCycle:   accelerator.DutyCycle,
+		})
+	}
+	return result
+}
+
+func extensionFsStatsToKubeletFsStats(statsTime metav1.Time, fs *fsStats) *stats.FsStats {
+	if fs == nil {
+		return nil
+	}
+	return &stats.FsStats{
+		Time:           statsTime,
+		AvailableBytes: fs.AvailableBytes,
+		CapacityBytes:  fs.CapacityBytes,
+		UsedBytes:      fs.UsedBytes,
+		InodesFree:     fs.InodesFree,
+		Inodes:         fs.Inodes,
+		InodesUsed:     fs.InodesUsed,
+	}
+}
+
+// ephemeralStorageOfContainers sums the rootfs and logs usage of the containers like the kubelet
+// does. The containers share the filesystem of the container group, whose capacity is the one of
+// their rootfs. It returns nil when no container reports its filesystem usage.
+func ephemeralStorageOfContainers(statsTime metav1.Time, containers []stats.ContainerStats) *stats.FsStats {
+	var result *stats.FsStats
+	for _, container := range containers {
+		for _, fs := range []*stats.FsStats{container.Rootfs, container.Logs} {
+			if fs == nil {
+				continue
+			}
+			if result == nil {
+				result = &stats.FsStats{Time: statsTime, UsedBytes: newUInt64Pointer(0), InodesUsed: newUInt64Pointer(0)}
+			}
+			if fs.UsedBytes != nil {
+				*result.UsedBytes += *fs.UsedBytes
+			}
+			if fs.InodesUsed != nil {
+				*result.InodesUsed += *fs.InodesUsed
+			}
+		}
+		if container.Rootfs != nil && result.CapacityBytes == nil {
+			result.AvailableBytes = container.Rootfs.AvailableBytes
+			result.CapacityBytes = container.Rootfs.CapacityBytes
+			result.InodesFree = container.Rootfs.InodesFree
+			result.Inodes = container.Rootfs.Inodes
+		}
+	}
+	return result
+}
+
 func (realTime *realTimeMetrics) populateUsageNanocores(pod *v1.Pod, realTimePodStats *realtimeMetricsExtensionPodStats, podStats *stats.PodStats) {
 	defer realTime.cpuStatsCache.Set(string(pod.UID), realTimePodStats, cache.DefaultExpiration)
 	lastRealtimePodStatus, found := realTime.cpuStatsCache.Get(string(pod.UID))
@@ -215,15 +421,13 @@ func calculateUsageNanoCores(containerName *string, lastPodStatus *realtimeMetri
 	if newPodStatus == nil {
 		return newUInt64Pointer(0)
 	}
-	timeWindowsNanoSeconds := newPodStatus.Timestamp - lastPodStatus.Timestamp
-	if timeWindowsNanoSeconds <= 0 {
+	if newPodStatus.Timestamp <= lastPodStatus.Timestamp {
 		return newUInt64Pointer(0)
 	}
-	var timeWindowsSeconds uint64 = timeWindowsNanoSeconds / 1000000000
+	timeWindowsNanoSeconds := newPodStatus.Timestamp - lastPodStatus.Timestamp
 	if containerName == nil {
 		// calculate for Pod
-		v := (newPodStatus.CPU.UsageCoreNanoSeconds - lastPodStatus.CPU.UsageCoreNanoSeconds) / timeWindowsSeconds
-		return &v
+		return usageNanoCores(lastPodStatus.CPU.UsageCoreNanoSeconds, newPodStatus.CPU.UsageCoreNanoSeconds, timeWindowsNanoSeconds)
 	} else {
 		// calcuate for specified container
 		var oldContainerUsageCoreNanoSeconds *uint64 = nil
@@ -244,9 +448,18 @@ func calculateUsageNanoCores(containerName *string, lastPodStatus *realtimeMetri
 		if newContainerUsageCoreNanoSeconds == nil {
 			return newUInt64Pointer(0)
 		}
-		v := (*newContainerUsageCoreNanoSeconds - *oldContainerUsageCoreNanoSeconds) / timeWindowsSeconds
-		return &v
+		return usageNanoCores(*oldContainerUsageCoreNanoSeconds, *newContainerUsageCoreNanoSeconds, timeWindowsNanoSeconds)
+	}
+}
+
+// usageNanoCores averages the CPU usage over a time window in nanoseconds. The cumulative usage goes
+// back to zero when a container restarts, the window is then reported idle.
+func usageNanoCores(lastUsageCoreNanoSeconds, newUsageCoreNanoSeconds, timeWindowsNanoSeconds uint64) *uint64 {
+	if newUsageCoreNanoSeconds < lastUsageCoreNanoSeconds {
+		return newUInt64Pointer(0)
 	}
+	v := uint64(float64(newUsageCoreNanoSeconds-lastUsageCoreNanoSeconds) / float64(timeWindowsNanoSeconds) * float64(time.Second))
+	return &v
 }
 
 // there are some containers in Real Time Metrics Extension but not in Pod
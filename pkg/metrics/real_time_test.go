@@ -0,0 +1,187 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	stats "github.com/virtual-kubelet/virtual-kubelet/node/api/statsv1alpha1"
+	"gotest.tools/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestExtensionPodStatsWithAccelerators(t *testing.T) {
+	body := `{
+		"timestamp": 1000000000,
+		"containers": [
+			{"name": "trainer", "accelerators": [{"make": "nvidia", "model": "tesla-v100", "id": "GPU-0", "memoryTotal": 17179869184, "memoryUsed": 4294967296, "dutyCycle": 87}]},
+			{"name": "sidecar"}
+		]
+	}`
+	var realtimeStats realtimeMetricsExtensionPodStats
+	assert.NilError(t, json.Unmarshal([]byte(body), &realtimeStats))
+
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "ns"}}
+	podStats := extensionPodStatsToKubeletPodStats(pod, &realtimeStats)
+	assert.Equal(t, 2, len(podStats.Containers))
+
+	accelerators := podStats.Containers[0].Accelerators
+	assert.Equal(t, 1, len(accelerators))
+	assert.Equal(t, "tesla-v100", accelerators[0].Model)
+	assert.Equal(t, uint64(4294967296), accelerators[0].MemoryUsed)
+	assert.Equal(t, uint64(87), accelerators[0].DutyCycle)
+
+	assert.Assert(t, podStats.Containers[1].Accelerators == nil, "containers without GPU should have no accelerator stats")
+}
+
+func TestExtensionPodStatsWithFilesystems(t *testing.T) {
+	body := `{
+		"timestamp": 1000000000,
+		"containers": [
+			{"name": "web", "rootfs": {"availableBytes": 1000, "capacityBytes": 5000, "usedBytes": 300, "inodesUsed": 10}, "logs": {"usedBytes": 200, "inodesUsed": 1}},
+			{"name": "sidecar", "rootfs": {"availableBytes": 1000, "capacityBytes": 5000, "usedBytes": 100, "inodesUsed": 5}},
+			{"name": "legacy"}
+		]
+	}`
+	var realtimeStats realtimeMetricsExtensionPodStats
+	assert.NilError(t, json.Unmarshal([]byte(body), &realtimeStats))
+
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "ns"}}
+	podStats := extensionPodStatsToKubeletPodStats(pod, &realtimeStats)
+	assert.Equal(t, uint64(300), *podStats.Containers[0].Rootfs.UsedBytes)
+	assert.Equal(t, uint64(5000), *podStats.Containers[0].Rootfs.CapacityBytes)
+	assert.Equal(t, uint64(200), *podStats.Containers[0].Logs.UsedBytes)
+	assert.Assert(t, podStats.Containers[1].Logs == nil)
+	assert.Assert(t, podStats.Containers[2].Rootfs == nil, "containers without filesystem stats should have none")
+
+	ephemeral := podStats.EphemeralStorage
+	assert.Assert(t, ephemeral != nil)
+	assert.Equal(t, uint64(600), *ephemeral.UsedBytes, "ephemeral storage should sum the rootfs and logs of the containers")
+	assert.Equal(t, uint64(16), *ephemeral.InodesUsed)
+	assert.Equal(t, uint64(5000), *ephemeral.CapacityBytes)
+	assert.Equal(t, uint64(1000), *ephemeral.AvailableBytes)
+
+	assert.NilError(t, json.Unmarshal([]byte(`{"timestamp": 1000000000, "ephemeral-storage": {"usedBytes": 42}}`), &realtimeStats))
+	podStats = extensionPodStatsToKubeletPodStats(pod, &realtimeStats)
+	assert.Equal(t, uint64(42), *podStats.EphemeralStorage.UsedBytes, "reported ephemeral storage should be kept")
+
+	podStats = extensionPodStatsToKubeletPodStats(pod, &realtimeMetricsExtensionPodStats{Containers: []containerStats{{Name: "legacy"}}})
+	assert.Assert(t, podStats.EphemeralStorage == nil)
+}
+
+func TestExtensionPodStatsWithCgroupStats(t *testing.T) {
+	body := `{
+		"timestamp": 1000000000,
+		"containers": [
+			{"name": "web", "cpu": {"usageCoreNanoSeconds": 10, "throttling": {"periods": 100, "throttledPeriods": 25, "throttledTimeNanoSeconds": 1500000000}},
+			 "memory": {"usageBytes": 300, "workingSetBytes": 200, "rssBytes": 150, "availableBytes": 800, "pageFaults": 7, "majorPageFaults": 1}},
+			{"name": "sidecar", "memory": {"usageBytes": 30, "workingSetBytes": 20, "rssBytes": 10}}
+		]
+	}`
+	var realtimeStats realtimeMetricsExtensionPodStats
+	assert.NilError(t, json.Unmarshal([]byte(body), &realtimeStats))
+
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "ns"}}
+	podStats := extensionPodStatsToKubeletPodStats(pod, &realtimeStats)
+	web, sidecar := podStats.Containers[0], podStats.Containers[1]
+	assert.Equal(t, uint64(200), *web.Memory.WorkingSetBytes)
+	assert.Equal(t, uint64(20), *sidecar.Memory.WorkingSetBytes, "each container should have its own stats")
+	assert.Equal(t, uint64(800), *web.Memory.AvailableBytes)
+	assert.Equal(t, uint64(1), *web.Memory.MajorPageFaults)
+	assert.Assert(t, sidecar.Memory.AvailableBytes == nil)
+
+	assert.Equal(t, 3, len(web.UserDefinedMetrics))
+	throttled := web.UserDefinedMetrics[2]
+	assert.Equal(t, cpuCFSThrottledSecondsMetric, throttled.Name)
+	assert.Equal(t, stats.MetricCumulative, throttled.Type)
+	assert.Equal(t, 1.5, throttled.Value)
+	assert.Assert(t, sidecar.UserDefinedMetrics == nil, "containers without a CPU limit should have no throttling stats")
+
+	realTime := NewRealTimeMetrics()
+	realTime.exportCgroupStats(pod, &realtimeStats)
+	assert.Equal(t, 25.0, testutil.ToFloat64(containerCPUCFSThrottledPeriods.WithLabelValues("ns", "pod", "web")))
+	assert.Equal(t, 150.0, testutil.ToFloat64(containerMemoryRSS.WithLabelValues("ns", "pod", "web")))
+	assert.Equal(t, 20.0, testutil.ToFloat64(containerMemoryWorkingSet.WithLabelValues("ns", "pod", "sidecar")))
+
+	realTime.cgroupSeries.Delete("ns/pod/web")
+	assert.Equal(t, 1, testutil.CollectAndCount(containerMemoryRSS), "expired containers should be dropped")
+}
+
+func TestRealTimeGetPodStats(t *testing.T) {
+	var mu sync.Mutex
+	timestamp, usage := uint64(1000000000), uint64(0)
+	setStats := func(newTimestamp, newUsage uint64) {
+		mu.Lock()
+		defer mu.Unlock()
+		timestamp, usage = newTimestamp, newUsage
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/stats" {
+			http.NotFound(w, r)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Fprintf(w, `{"timestamp": %d, "cpu": {"usageCoreNanoSeconds": %d}, "containers": [{"name": "web", "cpu": {"usageCoreNanoSeconds": %d}}, {"name": "infra"}]}`, timestamp, usage, usage)
+	}))
+	defer server.Close()
+	host, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	assert.NilError(t, err)
+
+	realTime := NewRealTimeMetrics()
+	realTime.port, _ = strconv.Atoi(port)
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "ns", UID: "uid"},
+		Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "web"}}},
+		Status:     v1.PodStatus{Phase: v1.PodRunning, PodIP: host},
+	}
+
+	podStats, err := realTime.GetPodStats(context.Background(), pod)
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(podStats.Containers), "containers not in the pod should be filtered out")
+	assert.Equal(t, uint64(0), *podStats.CPU.UsageNanoCores)
+
+	// Half a core over half a second.
+	setStats(1500000000, 250000000)
+	podStats, err = realTime.GetPodStats(context.Background(), pod)
+	assert.NilError(t, err)
+	assert.Equal(t, uint64(500000000), *podStats.CPU.UsageNanoCores)
+	assert.Equal(t, uint64(500000000), *podStats.Containers[0].CPU.UsageNanoCores)
+
+	// The usage of restarted containers starts over.
+	setStats(2000000000, 0)
+	podStats, err = realTime.GetPodStats(context.Background(), pod)
+	assert.NilError(t, err)
+	assert.Equal(t, uint64(0), *podStats.CPU.UsageNanoCores)
+
+	realTime.port = 1
+	_, err = realTime.GetPodStats(context.Background(), pod)
+	assert.Assert(t, err != nil)
+
+	pod.Status.Phase = v1.PodPending
+	_, err = realTime.GetPodStats(context.Background(), pod)
+	assert.Assert(t, err != nil, "only running pods should be scraped")
+}
+
+func TestRealTimeGetPodStatsStatusCode(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "starting", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	host, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	assert.NilError(t, err)
+
+	realTime := NewRealTimeMetrics()
+	realTime.port, _ = strconv.Atoi(port)
+	pod := &v1.Pod{Status: v1.PodStatus{Phase: v1.PodRunning, PodIP: host}}
+	_, err = realTime.GetPodStats(context.Background(), pod)
+	assert.ErrorContains(t, err, "returned status code 503")
+}
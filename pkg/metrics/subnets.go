@@ -0,0 +1,22 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Free IP addresses of the subnets container groups are spread across, an exhausted subnet fails
+// the pods selecting it.
+var subnetFreeIPs = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "aci",
+	Name:      "subnet_free_ip_addresses",
+	Help:      "Number of free IP addresses in the subnets of the virtual node.",
+}, []string{"subnet"})
+
+func init() {
+	prometheus.MustRegister(subnetFreeIPs)
+}
+
+// SetSubnetFreeIPs records the number of free IP addresses of the subnet.
+func SetSubnetFreeIPs(subnet string, free int) {
+	subnetFreeIPs.WithLabelValues(subnet).Set(float64(free))
+}
@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Outcome and latency of the resolution of the pod volumes into ACI volumes.
+var (
+	volumeResolutions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "aci",
+		Name:      "volume_resolutions_total",
+		Help:      "Number of pod volumes resolved by volume type and result.",
+	}, []string{"type", "result"})
+
+	volumeResolutionDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "aci",
+		Name:      "volume_resolution_duration_seconds",
+		Help:      "Latency of the resolution of the pod volumes.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"type"})
+)
+
+func init() {
+	prometheus.MustRegister(volumeResolutions, volumeResolutionDuration)
+}
+
+// RecordVolumeResolution records the result and latency of the resolution of a pod volume.
+func RecordVolumeResolution(volumeType string, err error, duration time.Duration) {
+	result := "resolved"
+	if err != nil {
+		result = "failed"
+	}
+	volumeResolutions.WithLabelValues(volumeType, result).Inc()
+	volumeResolutionDuration.WithLabelValues(volumeType).Observe(duration.Seconds())
+}
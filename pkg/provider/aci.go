@@ -10,9 +10,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
+	"net"
 	"os"
 	"reflect"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
@@ -22,7 +26,9 @@ import (
 	"github.com/virtual-kubelet/azure-aci/pkg/analytics"
 	"github.com/virtual-kubelet/azure-aci/pkg/auth"
 	client2 "github.com/virtual-kubelet/azure-aci/pkg/client"
+	"github.com/virtual-kubelet/azure-aci/pkg/errcodes"
 	"github.com/virtual-kubelet/azure-aci/pkg/metrics"
+	"github.com/virtual-kubelet/azure-aci/pkg/secrets"
 	"github.com/virtual-kubelet/azure-aci/pkg/validation"
 	"github.com/virtual-kubelet/node-cli/manager"
 	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
@@ -32,6 +38,9 @@ import (
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	utilvalidation "k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
 )
 
 const (
@@ -39,6 +48,10 @@ const (
 	serviceAccountSecretMountPath = "/var/run/secrets/kubernetes.io/serviceaccount"
 
 	virtualKubeletDNSNameLabel = "virtualkubelet.io/dnsnamelabel"
+	// recordRequestsAnnotation opts a pod into having its ARM calls recorded into a bundle file.
+	recordRequestsAnnotation = "virtual-kubelet.io/record-arm-requests"
+	// acrTokenUsername is the user ACR expects when a refresh token is used as the password.
+	acrTokenUsername = "00000000-0000-0000-0000-000000000000"
 
 	subnetDelegationService = "Microsoft.ContainerInstance/containerGroups"
 	// Parameter names defined in azure file CSI driver, refer to
@@ -46,7 +59,9 @@ const (
 	azureFileShareName  = "shareName"
 	azureFileSecretName = "secretName"
 	// AzureFileDriverName is the name of the CSI driver for Azure File
-	AzureFileDriverName         = "file.csi.azure.com"
+	AzureFileDriverName = "file.csi.azure.com"
+	// AzureBlobDriverName is the name of the CSI driver for Azure Blob (blobfuse)
+	AzureBlobDriverName         = "blob.csi.azure.com"
 	azureFileStorageAccountName = "azurestorageaccountname"
 	azureFileStorageAccountKey  = "azurestorageaccountkey"
 
@@ -88,9 +103,107 @@ type ACIProvider struct {
 	vnetSubscriptionID string
 	vnetName           string
 	vnetResourceGroup  string
+	subnetConfigs      []subnetConfig
+	subnets            map[string]*delegatedSubnet
+	subnetPool         *subnetPool
+	masterURI          string
+	clusterCIDR        string
 	clusterDomain      string
 	kubeDNSIP          string
+	dnsNdots           string
+	recordingDir       string
 	tracker            *PodsTracker
+	trackerMutex       sync.RWMutex
+	orphanGracePeriod  time.Duration
+
+	statusUpdatesInterval   time.Duration
+	statusUpdateParallelism int
+	cleanupInterval         time.Duration
+
+	migrations       *migrations
+	migrationZones   []string
+	migrationRegions []string
+
+	gpuZones *gpuZones
+
+	outagePolicy    string
+	outageThreshold time.Duration
+	outage          *outageHandling
+
+	logVolumeSampleInterval time.Duration
+	noisyLogBytesPerSecond  int64
+	logVolume               logVolume
+
+	unsupportedPodPolicy     string
+	unsupportedPodNamespaces []string
+
+	strictPodValidation       bool
+	allowPrivilegedContainers bool
+	allowVNetPublicIPs        bool
+	networkPolicyCheck        string
+
+	tags           map[string]string
+	annotationTags map[string]string
+	labelTags      map[string]string
+
+	containerGroupSKU           azaci.ContainerGroupSku
+	namespaceContainerGroupSKUs map[string]azaci.ContainerGroupSku
+	runtimeClasses              map[string]runtimeClassConfig
+
+	secretDeliveryPolicy     string
+	secretDeliveryNamespaces []string
+
+	requireImageDigests   bool
+	imageDigestNamespaces []string
+
+	acrIdentity                string
+	acrIdentityRegistries      []string
+	defaultRegistryCredentials []registryCredentialConfig
+
+	windowsExecShell string
+	windowsVersion   string
+
+	execIdleTimeout        time.Duration
+	execMaxSessionDuration time.Duration
+	execExitCodes          bool
+	execTerminalResize     bool
+	execKeepaliveInterval  time.Duration
+	execDialer             *websocket.Dialer
+	// portForwardDial connects forwarded ports, a net.Dialer when nil.
+	portForwardDial func(ctx context.Context, network, address string) (net.Conn, error)
+
+	realtimeMetrics   bool
+	deletionFinalizer bool
+
+	gpuMutex                  sync.RWMutex
+	capabilities              *capabilityService
+	capabilityRefreshInterval time.Duration
+
+	maxConcurrentARMReads  int
+	maxConcurrentARMWrites int
+	armWriteShares         map[string]int
+	maxConcurrentDeletions int
+	deletionsPerSecond     float64
+	deletions              *deletionQueue
+	pinnedIPs              pinnedIPs
+	drainMode              drainMode
+	advisories             advisories
+
+	health                   *aciHealthMonitor
+	nodeStatusUpdateInterval time.Duration
+	node                     *v1.Node
+	nodeMutex                sync.Mutex
+
+	kubeClient        kubernetes.Interface
+	serviceAccounts   *serviceAccountCache
+	eventRecorder     record.EventRecorder
+	aciEvents         *aciEvents
+	containerGroupIDs *containerGroupIDs
+
+	registryCredentials *registryCredentialCache
+	clientCache         cacheFlusher
+	volumeHandlers      []VolumeHandler
+	admissionChecks     []PodAdmissionCheck
 
 	*metrics.ACIPodMetricsProvider
 }
@@ -166,10 +279,20 @@ func isValidACIRegion(region string) bool {
 }
 
 // NewACIProvider creates a new ACIProvider.
-func NewACIProvider(ctx context.Context, config string, azConfig auth.Config, azAPIs client2.AzClientsInterface, rm *manager.ResourceManager, nodeName, operatingSystem string, internalIP string, daemonEndpointPort int32, clusterDomain string) (*ACIProvider, error) {
+func NewACIProvider(ctx context.Context, config string, azConfig auth.Config, azAPIs client2.AzClientsInterface, rm *manager.ResourceManager, nodeName, operatingSystem string, internalIP string, daemonEndpointPort int32, clusterDomain string, opts ...ProviderOption) (*ACIProvider, error) {
 	var p ACIProvider
 	var err error
 
+	p.orphanGracePeriod = defaultOrphanGracePeriod
+	p.statusUpdatesInterval = defaultStatusUpdatesInterval
+	p.statusUpdateParallelism = defaultStatusUpdateParallelism
+	p.cleanupInterval = defaultCleanupInterval
+	p.maxConcurrentARMReads = defaultMaxConcurrentARMReads
+	p.maxConcurrentARMWrites = defaultMaxConcurrentARMWrites
+	p.maxConcurrentDeletions = defaultMaxConcurrentDeletions
+	p.deletionsPerSecond = defaultDeletionsPerSecond
+	p.capabilityRefreshInterval = defaultCapabilityRefreshInterval
+	p.execKeepaliveInterval = defaultExecKeepaliveInterval
 	if config != "" {
 		f, err := os.Open(config)
 		if err != nil {
@@ -178,12 +301,35 @@ func NewACIProvider(ctx context.Context, config string, azConfig auth.Config, az
 		defer f.Close()
 
 		if err := p.loadConfig(f); err != nil {
-			return nil, err
+			return nil, errcodes.Wrap(errcodes.InvalidConfig, err)
 		}
 	}
+	for _, opt := range opts {
+		opt(&p)
+	}
+	if err := p.resolveDefaultRegistryPasswords(ctx, azConfig.SecretProvider); err != nil {
+		return nil, err
+	}
 
-	p.azClientsAPIs = azAPIs
+	p.health, err = newACIHealthMonitor()
+	if err != nil {
+		return nil, err
+	}
+	p.azClientsAPIs = &healthTrackingClient{
+		AzClientsInterface: newARMLimitedClient(azAPIs, p.maxConcurrentARMReads, p.maxConcurrentARMWrites, p.armWriteShares),
+		health:             p.health,
+	}
+	p.outage = newOutageHandling(p.outagePolicy, p.outageThreshold, p.health)
+	p.deletions = newDeletionQueue(p.maxConcurrentDeletions, p.deletionsPerSecond)
+	p.aciEvents = newACIEvents(time.Now())
+	p.containerGroupIDs = newContainerGroupIDs()
+	if flusher, ok := azAPIs.(cacheFlusher); ok {
+		p.clientCache = flusher
+	}
 	p.resourceManager = rm
+	p.registryCredentials = newRegistryCredentialCache()
+	p.setupVolumeHandlers()
+	p.setupAdmissionChecks()
 	p.clusterDomain = clusterDomain
 	p.operatingSystem = operatingSystem
 	p.nodeName = nodeName
@@ -220,6 +366,22 @@ func NewACIProvider(ctx context.Context, config string, azConfig auth.Config, az
 		}
 	}
 
+	// A workspace configured by resource ID is accessed with the identity of the provider instead
+	if workspaceResourceID := os.Getenv("LOG_ANALYTICS_WORKSPACE_RESOURCE_ID"); workspaceResourceID != "" && p.diagnostics == nil {
+		p.diagnostics, err = analytics.NewContainerGroupDiagnosticsFromWorkspaceResourceID(ctx, workspaceResourceID, azConfig.Authorizer)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Otherwise the workspace credentials may come from the secret provider of the deployment
+	if p.diagnostics == nil && azConfig.SecretProvider != nil {
+		p.diagnostics, err = analytics.NewContainerGroupDiagnosticsFromSecretProvider(ctx, azConfig.SecretProvider)
+		if err != nil && !secrets.IsNotFound(err) {
+			return nil, err
+		}
+	}
+
 	if clusterResourceID := os.Getenv("CLUSTER_RESOURCE_ID"); clusterResourceID != "" {
 		if p.diagnostics != nil && p.diagnostics.LogAnalytics != nil {
 			p.diagnostics.LogAnalytics.LogType = azaci.LogAnalyticsLogTypeContainerInsights
@@ -230,26 +392,55 @@ func NewACIProvider(ctx context.Context, config string, azConfig auth.Config, az
 		}
 	}
 
+	// Recording is only possible when the operator has chosen where bundles are written.
+	if recordingDir := os.Getenv("ACI_RECORDING_DIR"); recordingDir != "" {
+		p.recordingDir = recordingDir
+	}
+
 	if rg := os.Getenv("ACI_RESOURCE_GROUP"); rg != "" {
 		p.resourceGroup = rg
 	}
 	if p.resourceGroup == "" {
-		return nil, errors.New("Resource group can not be empty please set ACI_RESOURCE_GROUP")
+		return nil, errcodes.Errorf(errcodes.MissingResourceGroup, "Resource group can not be empty please set ACI_RESOURCE_GROUP")
 	}
 
 	if r := os.Getenv("ACI_REGION"); r != "" {
 		p.region = r
 	}
 	if p.region == "" {
-		return nil, errors.New("Region can not be empty please set ACI_REGION")
+		return nil, errcodes.Errorf(errcodes.InvalidRegion, "Region can not be empty please set ACI_REGION")
 	}
 
 	if r := p.region; !isValidACIRegion(r) {
 		unsupportedRegionMessage := fmt.Sprintf("Region %s is invalid. Current supported regions are: %s",
 			r, strings.Join(validAciRegions, ", "))
-		return nil, errors.New(unsupportedRegionMessage)
+		return nil, errcodes.Errorf(errcodes.InvalidRegion, "%s", unsupportedRegionMessage)
+	}
+
+	p.nodeStatusUpdateInterval = defaultNodeStatusUpdateInterval
+	if value := os.Getenv("ACI_NODE_STATUS_UPDATE_INTERVAL_IN_SECOND"); value != "" {
+		interval, err := strconv.Atoi(value)
+		if err != nil || interval <= 0 {
+			return nil, errcodes.Errorf(errcodes.InvalidConfig, "env ACI_NODE_STATUS_UPDATE_INTERVAL_IN_SECOND must be a positive integer, got %q", value)
+		}
+		p.nodeStatusUpdateInterval = time.Duration(interval) * time.Second
+	}
+	if value := os.Getenv("ACI_STATUS_UPDATES_INTERVAL_IN_SECOND"); value != "" {
+		interval, err := strconv.Atoi(value)
+		if err != nil || interval <= 0 {
+			return nil, errcodes.Errorf(errcodes.InvalidConfig, "env ACI_STATUS_UPDATES_INTERVAL_IN_SECOND must be a positive integer, got %q", value)
+		}
+		p.statusUpdatesInterval = time.Duration(interval) * time.Second
+	}
+	if value := os.Getenv("ACI_CLEANUP_INTERVAL_IN_SECOND"); value != "" {
+		interval, err := strconv.Atoi(value)
+		if err != nil || interval <= 0 {
+			return nil, errcodes.Errorf(errcodes.InvalidConfig, "env ACI_CLEANUP_INTERVAL_IN_SECOND must be a positive integer, got %q", value)
+		}
+		p.cleanupInterval = time.Duration(interval) * time.Second
 	}
 
+	p.capabilities = newCapabilityService(p.azClientsAPIs, p.region)
 	if err := p.setupNodeCapacity(ctx); err != nil {
 		return nil, err
 	}
@@ -258,7 +449,9 @@ func NewACIProvider(ctx context.Context, config string, azConfig auth.Config, az
 		return nil, err
 	}
 
-	p.ACIPodMetricsProvider = metrics.NewACIPodMetricsProvider(nodeName, p.resourceGroup, p.resourceManager, p.azClientsAPIs)
+	p.setupKubeClient(ctx)
+
+	p.ACIPodMetricsProvider = metrics.NewACIPodMetricsProvider(nodeName, p.resourceGroup, p.resourceManager, p.azClientsAPIs, p.azClientsAPIs)
 	return &p, err
 }
 
@@ -271,11 +464,54 @@ func addAzureAttributes(ctx context.Context, span trace.Span, p *ACIProvider) co
 
 // CreatePod accepts a Pod definition and creates
 // an ACI deployment
-func (p *ACIProvider) CreatePod(ctx context.Context, pod *v1.Pod) error {
-	var err error
+func (p *ACIProvider) CreatePod(ctx context.Context, pod *v1.Pod) (err error) {
 	ctx, span := trace.StartSpan(ctx, "aci.CreatePod")
 	defer span.End()
 	ctx = addAzureAttributes(ctx, span, p)
+	defer func() {
+		// Errors without a more specific code are either about the pod or from ARM.
+		if errdefs.IsInvalidInput(err) {
+			err = errcodes.Wrap(errcodes.InvalidPod, err)
+		} else {
+			err = errcodes.Wrap(errcodes.ContainerGroupCreateFailed, err)
+		}
+	}()
+
+	if err := p.checkDraining(pod); err != nil {
+		return err
+	}
+	if handled, err := p.handleUnsupportedPod(ctx, pod); handled {
+		return err
+	}
+	if err := p.admitPod(ctx, pod); err != nil {
+		return err
+	}
+	if err := p.validateSupportedFields(pod); err != nil {
+		p.recordEvent(pod, v1.EventTypeWarning, "UnsupportedFields", "%s", err.Error())
+		return err
+	}
+	if err := p.validateImageDigests(pod); err != nil {
+		p.recordEvent(pod, v1.EventTypeWarning, "ImageNotPinned", "%s", err.Error())
+		return err
+	}
+	if err := p.checkNetworkPolicies(ctx, pod); err != nil {
+		return err
+	}
+
+	var windowsVersion string
+	if p.isWindows() {
+		if windowsVersion, err = p.getWindowsVersion(pod); err != nil {
+			return err
+		}
+		if err := validateWindowsImages(pod, windowsVersion); err != nil {
+			p.recordEvent(pod, v1.EventTypeWarning, "IncompatibleWindowsImage", "%s", err.Error())
+			return err
+		}
+		if err := validateGMSA(pod); err != nil {
+			p.recordEvent(pod, v1.EventTypeWarning, "GMSANotSupported", "%s", err.Error())
+			return err
+		}
+	}
 
 	cg := &client2.ContainerGroupWrapper{
 		ContainerGroupPropertiesWrapper: &client2.ContainerGroupPropertiesWrapper{
@@ -284,24 +520,41 @@ func (p *ACIProvider) CreatePod(ctx context.Context, pod *v1.Pod) error {
 	}
 
 	cg.Location = &p.region
+	p.applyPlacement(pod, cg)
 	cg.ContainerGroupPropertiesWrapper.ContainerGroupProperties.RestartPolicy = azaci.ContainerGroupRestartPolicy(pod.Spec.RestartPolicy)
 	cg.ContainerGroupPropertiesWrapper.ContainerGroupProperties.OsType = azaci.OperatingSystemTypes(p.operatingSystem)
+	if err := p.setContainerGroupSKU(pod, cg.ContainerGroupPropertiesWrapper.ContainerGroupProperties); err != nil {
+		return err
+	}
+	if err := p.applyRuntimeClass(pod, cg); err != nil {
+		p.recordEvent(pod, v1.EventTypeWarning, "RuntimeClassNotSupported", "%s", err.Error())
+		return err
+	}
 
 	// get containers
 	containers, err := p.getContainers(pod)
 	if err != nil {
 		return err
 	}
+	if err := p.validateCapabilities(pod, *containers); err != nil {
+		p.recordEvent(pod, v1.EventTypeWarning, "ExceedsCapabilities", "%s", err.Error())
+		return err
+	}
+	if adjustments := getResourceAdjustments(pod, *containers); len(adjustments) > 0 {
+		p.recordEvent(pod, v1.EventTypeNormal, "ResourcesAdjusted", "ACI adjusted the requested resources: %s", strings.Join(adjustments, "; "))
+	}
+	if notes := getPlacementNotes(pod); len(notes) > 0 {
+		p.recordEvent(pod, v1.EventTypeNormal, "PlacementConstraintsIgnored", "Scheduling constraints are not applied by ACI: %s", strings.Join(notes, "; "))
+	}
 	// get registry creds
-	creds, err := p.getImagePullSecrets(pod)
+	creds, err := p.getImagePullSecrets(ctx, pod)
 	if err != nil {
-		return err
+		return errcodes.Wrap(errcodes.InvalidImagePullSecret, err)
 	}
 	// get volumes
 	volumes, err := p.getVolumes(ctx, pod)
 	if err != nil {
-		return err
-
+		return errcodes.Wrap(errcodes.InvalidVolume, err)
 	}
 
 	// get initContainers
@@ -316,8 +569,29 @@ func (p *ACIProvider) CreatePod(ctx context.Context, pod *v1.Pod) error {
 	cg.ContainerGroupPropertiesWrapper.ContainerGroupProperties.Volumes = &volumes
 	cg.ContainerGroupPropertiesWrapper.ContainerGroupProperties.ImageRegistryCredentials = creds
 	cg.ContainerGroupPropertiesWrapper.ContainerGroupProperties.Diagnostics = p.getDiagnostics(pod)
+	p.addDefaultRegistryCredentials(pod, cg)
+	p.addACRIdentityCredentials(pod, cg)
 
-	filterWindowsServiceAccountSecretVolume(ctx, p.operatingSystem, cg)
+	filterServiceAccountSecretVolume(ctx, pod, p.operatingSystem, cg)
+	resolveVolumeMounts(pod, cg)
+
+	if err := p.applySecretDeliveryPolicy(pod, cg); err != nil {
+		return err
+	}
+	// The blob downloads are added after the secret delivery policy, the Azure CLI reads the
+	// storage account key from its environment.
+	blobInitContainers, err := p.getBlobVolumeInitContainers(pod)
+	if err != nil {
+		return errcodes.Wrap(errcodes.InvalidVolume, err)
+	}
+	if len(blobInitContainers) > 0 {
+		initContainers = append(blobInitContainers, *cg.ContainerGroupPropertiesWrapper.ContainerGroupProperties.InitContainers...)
+		cg.ContainerGroupPropertiesWrapper.ContainerGroupProperties.InitContainers = &initContainers
+	}
+	if err := validateContainerCount(pod, cg.ContainerGroupPropertiesWrapper.ContainerGroupProperties); err != nil {
+		p.recordEvent(pod, v1.EventTypeWarning, "TooManyContainers", "%s", err.Error())
+		return err
+	}
 
 	// create ipaddress if containerPort is used
 	count := 0
@@ -334,33 +608,87 @@ func (p *ACIProvider) CreatePod(ctx context.Context, pod *v1.Pod) error {
 			})
 		}
 	}
-	if len(ports) > 0 && p.subnetName == "" {
+	publicIP, err := p.exposePublicIP(pod, ports)
+	if err != nil {
+		return err
+	}
+	if publicIP {
 		cg.ContainerGroupPropertiesWrapper.ContainerGroupProperties.IPAddress = &azaci.IPAddress{
 			Ports: &ports,
 			Type:  azaci.ContainerGroupIPAddressTypePublic,
 		}
 
-		if dnsNameLabel := pod.Annotations[virtualKubeletDNSNameLabel]; dnsNameLabel != "" {
-			cg.ContainerGroupPropertiesWrapper.ContainerGroupProperties.IPAddress.DNSNameLabel = &dnsNameLabel
+		if err := setDNSNameLabel(pod, cg.ContainerGroupPropertiesWrapper.ContainerGroupProperties.IPAddress); err != nil {
+			return err
 		}
 	}
+	// Container groups with a public IP address are deployed outside of the virtual network.
+	var subnet *delegatedSubnet
+	if !publicIP {
+		if subnet, err = p.getPodSubnet(ctx, pod); err != nil {
+			return err
+		}
+	} else if p.subnetName != "" {
+		p.recordEvent(pod, v1.EventTypeNormal, "PublicIPAddress", "the container group is deployed outside of the virtual network %s to get a public IP address", p.vnetName)
+	}
+	// The address reserved in a subnet of the pool is returned when no container group is created.
+	created := false
+	defer func() {
+		if !created {
+			p.subnetPool.release(pod)
+		}
+	}()
+	if err := p.setPrivateIP(pod, subnet, cg.ContainerGroupPropertiesWrapper.ContainerGroupProperties, ports); err != nil {
+		return err
+	}
 
-	podUID := string(pod.UID)
-	podCreationTimestamp := pod.CreationTimestamp.String()
-	cg.Tags = map[string]*string{
-		"PodName":           &pod.Name,
-		"ClusterName":       &pod.ClusterName,
-		"NodeName":          &pod.Spec.NodeName,
-		"Namespace":         &pod.Namespace,
-		"UID":               &podUID,
-		"CreationTimestamp": &podCreationTimestamp,
+	cg.Tags = podTags(pod)
+	if windowsVersion != "" {
+		cg.Tags[windowsVersionTag] = &windowsVersion
+	}
+	p.addWorkloadTags(ctx, pod, cg)
+	p.addMappedTags(ctx, pod, cg)
+	if err := p.addProviderTags(pod, cg); err != nil {
+		return err
+	}
+
+	p.amendVnetResources(ctx, *cg, pod, subnet)
+
+	if rec := p.getRecorder(pod); rec != nil {
+		ctx = client2.WithRecorder(ctx, rec)
+		defer p.writeRecording(ctx, rec)
 	}
 
-	p.amendVnetResources(ctx, *cg, pod)
+	if err := p.addDeletionFinalizer(ctx, pod); err != nil {
+		return err
+	}
 
 	log.G(ctx).Infof("start creating pod %v", pod.Name)
 	// TODO: Run in a go routine to not block workers, and use tracker.UpdatePodStatus() based on result.
-	return p.azClientsAPIs.CreateContainerGroup(ctx, p.resourceGroup, pod.Namespace, pod.Name, cg)
+	err = p.createContainerGroup(ctx, pod, cg)
+	created = err == nil
+	return err
+}
+
+// getRecorder returns a recorder when the pod asked for its ARM requests to be recorded
+// and the provider has a recording directory configured.
+func (p *ACIProvider) getRecorder(pod *v1.Pod) *client2.Recorder {
+	if p.recordingDir == "" {
+		return nil
+	}
+	if enabled, _ := strconv.ParseBool(pod.Annotations[recordRequestsAnnotation]); !enabled {
+		return nil
+	}
+	return client2.NewRecorder(pod.Namespace, pod.Name)
+}
+
+func (p *ACIProvider) writeRecording(ctx context.Context, rec *client2.Recorder) {
+	path, err := rec.WriteBundle(p.recordingDir)
+	if err != nil {
+		log.G(ctx).WithError(err).Errorf("failed to write ARM recording for pod %s", rec.PodName)
+		return
+	}
+	log.G(ctx).Infof("ARM recording for pod %s written to %s", rec.PodName, path)
 }
 
 func (p *ACIProvider) getDiagnostics(pod *v1.Pod) *azaci.ContainerGroupDiagnostics {
@@ -373,8 +701,30 @@ func (p *ACIProvider) getDiagnostics(pod *v1.Pod) *azaci.ContainerGroupDiagnosti
 	return p.diagnostics
 }
 
-func containerGroupName(podNS, podName string) string {
-	return fmt.Sprintf("%s-%s", podNS, podName)
+// checkDeletePreconditions verifies the container group against the UID tag written at creation. A
+// container group of another pod incarnation is reported as not found, the pod to delete is gone.
+func (p *ACIProvider) checkDeletePreconditions(ctx context.Context, podNS, podName string, preconditions *metav1.Preconditions) error {
+	if preconditions == nil || preconditions.UID == nil {
+		return nil
+	}
+
+	cg, err := p.azClientsAPIs.GetContainerGroupInfo(ctx, p.resourceGroup, podNS, podName, p.nodeName)
+	if err != nil {
+		return err
+	}
+	if cg == nil {
+		return nil
+	}
+	uid := cg.Tags["UID"]
+	if uid == nil || *uid == "" {
+		// Container groups created before the UID tag was written can not be checked.
+		return nil
+	}
+	if *uid != string(*preconditions.UID) {
+		log.G(ctx).Warnf("container group of pod %s/%s belongs to pod UID %s, not %s, skipping delete", podNS, podName, *uid, *preconditions.UID)
+		return errcodes.Wrap(errcodes.ContainerGroupNotFound, errdefs.NotFoundf("container group of pod %s/%s with UID %s is not found", podNS, podName, *preconditions.UID))
+	}
+	return nil
 }
 
 // UpdatePod is a noop, ACI currently does not support live updates of a pod.
@@ -389,16 +739,47 @@ func (p *ACIProvider) DeletePod(ctx context.Context, pod *v1.Pod) error {
 	ctx = addAzureAttributes(ctx, span, p)
 
 	log.G(ctx).Infof("start deleting pod %v", pod.Name)
+	// The container group must belong to this incarnation of the pod, a pod recreated with the same
+	// name reuses the container group name.
+	var preconditions *metav1.Preconditions
+	if pod.UID != "" {
+		preconditions = &metav1.Preconditions{UID: &pod.UID}
+	}
 	// TODO: Run in a go routine to not block workers.
-	return p.deleteContainerGroup(ctx, pod.Namespace, pod.Name)
+	if err := p.deletions.acquire(ctx, pod.CreationTimestamp.Time); err != nil {
+		return err
+	}
+	defer p.deletions.release()
+	err := p.deleteContainerGroup(ctx, pod.Namespace, pod.Name, preconditions)
+	metrics.RecordPodDeletion(err)
+	if err == nil {
+		p.rememberPinnedIP(pod)
+		p.subnetPool.release(pod)
+		p.aciEvents.forget(PodIdentifier{namespace: pod.Namespace, name: pod.Name})
+		p.containerGroupIDs.forget(PodIdentifier{namespace: pod.Namespace, name: pod.Name})
+		p.migrations.forget(PodIdentifier{namespace: pod.Namespace, name: pod.Name})
+		p.gpuZones.forget(client2.ContainerGroupName(pod.Namespace, pod.Name))
+	}
+	if err == nil || errdefs.IsNotFound(err) {
+		// The container group is gone, the pod object can go too.
+		if finalizerErr := p.removeDeletionFinalizer(ctx, pod); finalizerErr != nil {
+			return finalizerErr
+		}
+	}
+	return err
 }
 
-func (p *ACIProvider) deleteContainerGroup(ctx context.Context, podNS, podName string) error {
+func (p *ACIProvider) deleteContainerGroup(ctx context.Context, podNS, podName string, preconditions *metav1.Preconditions) error {
 	ctx, span := trace.StartSpan(ctx, "aci.deleteContainerGroup")
 	defer span.End()
 	ctx = addAzureAttributes(ctx, span, p)
+	ctx = withARMNamespace(ctx, podNS)
 
-	cgName := containerGroupName(podNS, podName)
+	cgName := client2.ContainerGroupName(podNS, podName)
+
+	if err := p.checkDeletePreconditions(ctx, podNS, podName, preconditions); err != nil {
+		return err
+	}
 
 	err := p.azClientsAPIs.DeleteContainerGroup(ctx, p.resourceGroup, cgName)
 	if err != nil {
@@ -406,9 +787,9 @@ func (p *ACIProvider) deleteContainerGroup(ctx context.Context, podNS, podName s
 		return err
 	}
 
-	if p.tracker != nil {
+	if tracker := p.podsTracker(); tracker != nil {
 		// Delete is not a sync API on ACI yet, but will assume with current implementation that termination is completed. Also, till gracePeriod is supported.
-		updateErr := p.tracker.UpdatePodStatus(ctx,
+		updateErr := tracker.UpdatePodStatus(ctx,
 			podNS,
 			podName,
 			func(podStatus *v1.PodStatus) {
@@ -460,23 +841,48 @@ func (p *ACIProvider) GetPod(ctx context.Context, namespace, name string) (*v1.P
 }
 
 // GetContainerLogs returns the logs of a pod by name that is running inside ACI.
-func (p *ACIProvider) GetContainerLogs(ctx context.Context, namespace, podName, containerName string, opts api.ContainerLogOpts) (io.ReadCloser, error) {
+func (p *ACIProvider) GetContainerLogs(ctx context.Context, namespace, podName, containerName string, opts api.ContainerLogOpts) (_ io.ReadCloser, err error) {
 	ctx, span := trace.StartSpan(ctx, "aci.GetContainerLogs")
 	defer span.End()
 	ctx = addAzureAttributes(ctx, span, p)
+	defer func() {
+		metrics.RecordInteractiveRequest(namespace, metrics.OperationLogs, err)
+	}()
 
 	cg, err := p.azClientsAPIs.GetContainerGroupInfo(ctx, p.resourceGroup, namespace, podName, p.nodeName)
 	if err != nil {
 		return nil, err
 	}
 
+	if opts.Previous {
+		logs, err := p.getPreviousContainerLogs(ctx, cg, containerName, opts)
+		if err != nil {
+			return nil, err
+		}
+		metrics.AddInteractiveBytes(namespace, metrics.OperationLogs, int64(len(logs)))
+		return io.NopCloser(strings.NewReader(logs)), nil
+	}
+	if opts.Follow {
+		cgName := *cg.Name
+		return followLogs(ctx, namespace, opts.Tail, logFollowInterval, func(ctx context.Context, tail int) (string, error) {
+			pollOpts := opts
+			pollOpts.Tail = tail
+			content, err := p.azClientsAPIs.ListLogs(ctx, p.resourceGroup, cgName, containerName, pollOpts)
+			if err != nil || content == nil {
+				return "", err
+			}
+			return p.normalizeLogLineEndings(*content), nil
+		}), nil
+	}
+
 	// get logs from cg
 	logContent, err := p.azClientsAPIs.ListLogs(ctx, p.resourceGroup, *cg.Name, containerName, opts)
 	if err != nil {
 		return nil, err
 	}
 	if logContent != nil {
-		logStr := *logContent
+		logStr := p.normalizeLogLineEndings(*logContent)
+		metrics.AddInteractiveBytes(namespace, metrics.OperationLogs, int64(len(logStr)))
 		return io.NopCloser(strings.NewReader(logStr)), nil
 	}
 	return nil, nil
@@ -489,13 +895,30 @@ func (p *ACIProvider) GetPodFullName(namespace string, pod string) string {
 
 // RunInContainer executes a command in a container in the pod, copying data
 // between in/out/err and the container's stdin/stdout/stderr.
-func (p *ACIProvider) RunInContainer(ctx context.Context, namespace, name, container string, cmd []string, attach api.AttachIO) error {
+func (p *ACIProvider) RunInContainer(ctx context.Context, namespace, name, container string, cmd []string, attach api.AttachIO) (err error) {
 	logger := log.G(ctx).WithField("method", "RunInContainer")
 	ctx, span := trace.StartSpan(ctx, "aci.RunInContainer")
 	defer span.End()
 	ctx = addAzureAttributes(ctx, span, p)
+	ctx = withARMNamespace(ctx, namespace)
+	defer func() {
+		metrics.RecordInteractiveRequest(namespace, metrics.OperationExec, err)
+	}()
 
 	out := attach.Stdout()
+	if out != nil && p.isWindows() && !attach.TTY() {
+		out = newCRLFWriter(out)
+	}
+	var exitCodes *exitCodeWriter
+	if out != nil && p.execExitCodes && !p.isWindows() && !attach.TTY() {
+		exitCodes = newExitCodeWriter(out)
+		out = exitCodes
+	}
+	var tty *ttyWriter
+	if out != nil && p.execTerminalResize && !p.isWindows() && attach.TTY() {
+		tty = newTTYWriter(out)
+		out = tty
+	}
 	if out != nil {
 		defer out.Close()
 	}
@@ -505,10 +928,14 @@ func (p *ACIProvider) RunInContainer(ctx context.Context, namespace, name, conta
 		return err
 	}
 
-	// Set default terminal size
-	cols := int32(60)
-	rows := int32(120)
-	cmdParam := strings.Join(cmd, " ")
+	cols, rows := execTerminalSize(ctx, attach, execTerminalSizeWait)
+	cmdParam := p.getExecCommand(cmd)
+	if exitCodes != nil {
+		cmdParam = getExitCodeExecCommand(cmd)
+	}
+	if tty != nil {
+		cmdParam = getResizableExecCommand(cmd)
+	}
 	req := azaci.ContainerExecRequest{
 		Command: &cmdParam,
 		TerminalSize: &azaci.ContainerExecRequestTerminalSize{
@@ -517,25 +944,36 @@ func (p *ACIProvider) RunInContainer(ctx context.Context, namespace, name, conta
 		},
 	}
 
-	xcrsp, err := p.azClientsAPIs.ExecuteContainerCommand(ctx, p.resourceGroup, *cg.Name, container, req)
-	if err != nil {
-		return err
-	}
-
-	wsURI := *xcrsp.WebSocketURI
-	password := *xcrsp.Password
-
-	c, _, err := websocket.DefaultDialer.Dial(wsURI, nil)
+	c, err := p.connectExec(ctx, *cg.Name, container, req)
 	if err != nil {
 		return err
 	}
-	if err := c.WriteMessage(websocket.TextMessage, []byte(password)); err != nil { // Websocket password needs to be sent before WS terminal is active
-		return err
-	}
 
 	// Cleanup on exit
 	defer c.Close()
 
+	resizesDone := make(chan struct{})
+	defer close(resizesDone)
+	if tty != nil {
+		go p.forwardTerminalResizes(ctx, *cg.Name, container, tty, api.TermSize{Width: uint16(cols), Height: uint16(rows)}, attach.Resize(), resizesDone)
+	} else {
+		go discardTerminalResizes(ctx, attach.Resize(), resizesDone)
+	}
+
+	limits := newExecSessionLimits(p.execIdleTimeout, p.execMaxSessionDuration, time.Now())
+	if limits.enabled() {
+		done := make(chan struct{})
+		defer close(done)
+		go limits.watch(done, func(reason string) {
+			logger.Infof("closing exec session of pod %s/%s: %s", namespace, name, reason)
+			msg := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, reason)
+			if err := c.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second)); err != nil {
+				logger.WithError(err).Debug("failed to send the close message")
+			}
+			c.Close()
+		})
+	}
+
 	in := attach.Stdin()
 	if in != nil {
 		go func() {
@@ -553,6 +991,7 @@ func (p *ACIProvider) RunInContainer(ctx context.Context, namespace, name, conta
 					return
 				}
 				if n > 0 { // Only call WriteMessage if there is data to send
+					limits.touch(time.Now())
 					if err = c.WriteMessage(websocket.BinaryMessage, msg[:n]); err != nil {
 						logger.Errorf("an error has occurred while trying to write message")
 						return
@@ -565,7 +1004,14 @@ func (p *ACIProvider) RunInContainer(ctx context.Context, namespace, name, conta
 		return err
 	}
 
+	var readErr error
 	if out != nil {
+		if p.execKeepaliveInterval > 0 {
+			handleExecPongs(c, p.execKeepaliveInterval)
+			keepaliveDone := make(chan struct{})
+			defer close(keepaliveDone)
+			go pingExec(c, p.execKeepaliveInterval, keepaliveDone)
+		}
 		for {
 			select {
 			case <-ctx.Done():
@@ -573,12 +1019,18 @@ func (p *ACIProvider) RunInContainer(ctx context.Context, namespace, name, conta
 			default:
 			}
 
+			if p.execKeepaliveInterval > 0 {
+				extendExecReadDeadline(c, p.execKeepaliveInterval)
+			}
 			_, cr, err := c.NextReader()
 			if err != nil {
-				// Handle errors
+				readErr = err
 				break
 			}
-			if _, err := io.Copy(out, cr); err != nil {
+			n, err := io.Copy(out, cr)
+			metrics.AddInteractiveBytes(namespace, metrics.OperationExec, n)
+			limits.touch(time.Now())
+			if err != nil {
 				logger.Errorf("an error has occurred while trying to copy message")
 				break
 			}
@@ -587,6 +1039,18 @@ func (p *ACIProvider) RunInContainer(ctx context.Context, namespace, name, conta
 	if err != nil {
 		return err
 	}
+	if reason := limits.expiredReason(); reason != "" {
+		return errors.New(reason)
+	}
+	if err := execSessionError(readErr); err != nil && ctx.Err() == nil {
+		logger.WithError(err).Warnf("exec session of pod %s/%s ended", namespace, name)
+		return err
+	}
+	if exitCodes != nil {
+		if code, ok := exitCodes.ExitCode(); ok {
+			return execExitError(code)
+		}
+	}
 
 	return ctx.Err()
 }
@@ -607,7 +1071,21 @@ func (p *ACIProvider) GetPodStatus(ctx context.Context, namespace, name string)
 	if err != nil {
 		return nil, err
 	}
-	return p.getPodStatusFromContainerGroup(cg)
+	p.publishACIEvents(cg)
+	p.publishProvisioningState(cg)
+	p.observePlacement(ctx, cg)
+	if p.gpuZones != nil {
+		p.gpuZones.observe(cg, time.Now())
+	}
+	p.annotateContainerGroupID(ctx, namespace, name, cg)
+	status, err := p.getPodStatusFromContainerGroup(cg)
+	if err != nil {
+		return nil, err
+	}
+	if condition, ok := p.advisoryCondition(namespace, name); ok {
+		status.Conditions = append(status.Conditions, condition)
+	}
+	return status, nil
 }
 
 // GetPods returns a list of all pods known to be running within ACI.
@@ -616,33 +1094,39 @@ func (p *ACIProvider) GetPods(ctx context.Context) ([]*v1.Pod, error) {
 	defer span.End()
 	ctx = addAzureAttributes(ctx, span, p)
 
-	cgs, err := p.azClientsAPIs.GetContainerGroupListResult(ctx, p.resourceGroup)
+	cgs, err := p.azClientsAPIs.ListContainerGroupsByNodeName(ctx, p.resourceGroup, p.nodeName)
 	if err != nil {
 		return nil, err
 	}
-
 	if cgs == nil {
 		log.G(ctx).Infof("no container groups found for resource group %s", p.resourceGroup)
-		return nil, nil
+		return []*v1.Pod{}, nil
 	}
 	pods := make([]*v1.Pod, 0, len(*cgs))
 
-	for cgIndex := range *cgs {
-		validation.ValidateContainerGroup(&(*cgs)[cgIndex])
+	for _, listed := range *cgs {
+		id, ok := podIdentifierFromTags(listed.Tags)
+		if !ok {
+			continue
+		}
+		// The list only has the tags of the container groups, their instance view is fetched.
+		cg, err := p.azClientsAPIs.GetContainerGroupInfo(ctx, p.resourceGroup, id.namespace, id.name, p.nodeName)
 		if err != nil {
-			return nil, err
+			if !errdefs.IsNotFound(err) {
+				log.G(ctx).WithError(err).Errorf("failed to get container group of pod %s/%s", id.namespace, id.name)
+			}
+			continue
 		}
-
-		if (*cgs)[cgIndex].Tags["NodeName"] != &p.nodeName {
+		if cg == nil {
 			continue
 		}
 
-		pod, err := p.containerGroupToPod(&(*cgs)[cgIndex])
+		pod, err := p.containerGroupToPod(cg)
 		if err != nil {
 			log.G(ctx).WithFields(log.Fields{
-				"name": (*cgs)[cgIndex].Name,
-				"id":   (*cgs)[cgIndex].ID,
-			}).WithError(err).Errorf("error converting container group %s to pod", (*cgs)[cgIndex].Name)
+				"name": cg.Name,
+				"id":   cg.ID,
+			}).WithError(err).Errorf("error converting container group %s to pod", *cg.Name)
 
 			continue
 		}
@@ -652,6 +1136,23 @@ func (p *ACIProvider) GetPods(ctx context.Context) ([]*v1.Pod, error) {
 	return pods, nil
 }
 
+// podIdentifierFromTags returns the pod of a container group from its tags.
+func podIdentifierFromTags(tags map[string]*string) (PodIdentifier, bool) {
+	namespace, name := tags["Namespace"], tags["PodName"]
+	if namespace == nil || name == nil || *namespace == "" || *name == "" {
+		return PodIdentifier{}, false
+	}
+	return PodIdentifier{namespace: *namespace, name: *name}, true
+}
+
+// podsTracker returns the tracker of the pods, nil until NotifyPods is called. It is read by the
+// requests of the admin API and of the status notifications, concurrently with NotifyPods.
+func (p *ACIProvider) podsTracker() *PodsTracker {
+	p.trackerMutex.RLock()
+	defer p.trackerMutex.RUnlock()
+	return p.tracker
+}
+
 // NotifyPods instructs the notifier to call the passed in function when
 // the pod status changes.
 // The provided pointer to a Pod is guaranteed to be used in a read-only
@@ -661,33 +1162,53 @@ func (p *ACIProvider) NotifyPods(ctx context.Context, notifierCb func(*v1.Pod))
 	defer span.End()
 
 	// Capture the notifier to be used for communicating updates to VK
-	p.tracker = &PodsTracker{
-		rm:       p.resourceManager,
-		updateCb: notifierCb,
-		handler:  p,
+	tracker := &PodsTracker{
+		rm:                      p.resourceManager,
+		updateCb:                notifierCb,
+		handler:                 p,
+		eventRecorder:           p.eventRecorder,
+		statusUpdatesInterval:   p.statusUpdatesInterval,
+		statusUpdateParallelism: p.statusUpdateParallelism,
+		cleanupInterval:         p.cleanupInterval,
+		orphanGracePeriod:       p.orphanGracePeriod,
+		outage:                  p.outage,
+		resync:                  make(chan struct{}, 1),
+		cleanup:                 make(chan struct{}, 1),
+		updates:                 make(chan PodIdentifier, podUpdateRequestsBuffer),
+	}
+
+	p.trackerMutex.Lock()
+	p.tracker = tracker
+	p.trackerMutex.Unlock()
+
+	go tracker.StartTracking(ctx)
+	if p.logVolumeSampleInterval > 0 {
+		go p.trackLogVolume(ctx)
+	}
+	if p.migrationEnabled() {
+		go p.runMigrations(ctx)
 	}
-
-	go p.tracker.StartTracking(ctx)
 }
 
-// ListActivePods interface impl.
+// ListActivePods interface impl. The pods are read from the tags of the container groups of the
+// node, including container groups whose pod is gone.
 func (p *ACIProvider) ListActivePods(ctx context.Context) ([]PodIdentifier, error) {
 	ctx, span := trace.StartSpan(ctx, "ACIProvider.ListActivePods")
 	defer span.End()
 
-	providerPods, err := p.GetPods(ctx)
+	cgs, err := p.azClientsAPIs.ListContainerGroupsByNodeName(ctx, p.resourceGroup, p.nodeName)
 	if err != nil {
 		return nil, err
 	}
-	podsIdentifiers := make([]PodIdentifier, 0, len(providerPods))
+	if cgs == nil {
+		return []PodIdentifier{}, nil
+	}
+	podsIdentifiers := make([]PodIdentifier, 0, len(*cgs))
 
-	for _, pod := range providerPods {
-		podsIdentifiers = append(
-			podsIdentifiers,
-			PodIdentifier{
-				namespace: pod.Namespace,
-				name:      pod.Name,
-			})
+	for _, cg := range *cgs {
+		if id, ok := podIdentifierFromTags(cg.Tags); ok {
+			podsIdentifiers = append(podsIdentifiers, id)
+		}
 	}
 
 	return podsIdentifiers, nil
@@ -706,47 +1227,74 @@ func (p *ACIProvider) CleanupPod(ctx context.Context, ns, name string) error {
 	ctx, span := trace.StartSpan(ctx, "ACIProvider.CleanupPod")
 	defer span.End()
 
-	return p.deleteContainerGroup(ctx, ns, name)
+	return p.deleteContainerGroup(ctx, ns, name, nil)
 }
 
 // implement NodeProvider
 
 // Ping checks if the node is still active/ready.
+// It fails while the ACI API is degraded so the node lease is not renewed, unless the outage policy
+// keeps the node Ready.
 func (p *ACIProvider) Ping(ctx context.Context) error {
-	return nil
+	return p.outage.nodeError(time.Now())
 }
 
-func (p *ACIProvider) getImagePullSecrets(pod *v1.Pod) (*[]azaci.ImageRegistryCredential, error) {
-	ips := make([]azaci.ImageRegistryCredential, 0, len(pod.Spec.ImagePullSecrets))
-	for _, ref := range pod.Spec.ImagePullSecrets {
-		secret, err := p.resourceManager.GetSecret(ref.Name, pod.Namespace)
-		if err != nil {
-			return &ips, err
-		}
-		if secret == nil {
-			return nil, fmt.Errorf("error getting image pull secret")
-		}
-		switch secret.Type {
-		case v1.SecretTypeDockercfg:
-			ips, err = readDockerCfgSecret(secret, ips)
-		case v1.SecretTypeDockerConfigJson:
-			ips, err = readDockerConfigJSONSecret(secret, ips)
-		default:
-			return nil, fmt.Errorf("image pull secret type is not one of kubernetes.io/dockercfg or kubernetes.io/dockerconfigjson")
-		}
+// getPodImagePullSecrets returns the image pull secrets of the pod merged with the ones of its service
+// account, like the kubelet sees them once the ServiceAccount admission plugin ran. The service account
+// is looked up as well so secrets added to it after the pod was admitted are used too.
+func (p *ACIProvider) getPodImagePullSecrets(ctx context.Context, pod *v1.Pod) []v1.LocalObjectReference {
+	refs := append([]v1.LocalObjectReference{}, pod.Spec.ImagePullSecrets...)
+	if p.kubeClient == nil {
+		return refs
+	}
 
-		if err != nil {
-			return &ips, err
+	serviceAccountName := pod.Spec.ServiceAccountName
+	if serviceAccountName == "" {
+		serviceAccountName = "default"
+	}
+	serviceAccount, err := p.getServiceAccount(ctx, pod.Namespace, serviceAccountName)
+	if err != nil {
+		log.G(ctx).WithError(err).Warnf("failed to get service account %s of pod %s, using the image pull secrets of the pod only", serviceAccountName, pod.Name)
+		return refs
+	}
+
+	names := make(map[string]bool, len(refs))
+	for _, ref := range refs {
+		names[ref.Name] = true
+	}
+	for _, ref := range serviceAccount.ImagePullSecrets {
+		if !names[ref.Name] {
+			names[ref.Name] = true
+			refs = append(refs, ref)
 		}
+	}
+	return refs
+}
+
+// makeIdentityTokenCredential turns a docker identity token into a registry credential. The identity
+// token issued by ACR is a refresh token, which the registry exchanges for an access token on every
+// pull when it is presented as the password of the ACR token user. Unlike a short lived access token
+// it stays valid for container restarts.
+func makeIdentityTokenCredential(server, username, identityToken string) *azaci.ImageRegistryCredential {
+	if username == "" {
+		username = acrTokenUsername
+	}
 
+	return &azaci.ImageRegistryCredential{
+		Server:   &server,
+		Username: &username,
+		Password: &identityToken,
 	}
-	return &ips, nil
 }
 
 func makeRegistryCredential(server string, authConfig AuthConfig) (*azaci.ImageRegistryCredential, error) {
 	username := authConfig.Username
 	password := authConfig.Password
 
+	if authConfig.IdentityToken != "" {
+		return makeIdentityTokenCredential(server, username, authConfig.IdentityToken), nil
+	}
+
 	if username == "" {
 		if authConfig.Auth == "" {
 			return nil, fmt.Errorf("no username present in auth config for server: %s", server)
@@ -757,8 +1305,9 @@ func makeRegistryCredential(server string, authConfig AuthConfig) (*azaci.ImageR
 			return nil, fmt.Errorf("error decoding the auth for server: %s Error: %v", server, err)
 		}
 
-		parts := strings.Split(string(decoded), ":")
-		if len(parts) != 2 {
+		// Passwords and tokens may contain colons, only the first one separates the username.
+		parts := strings.SplitN(string(decoded), ":", 2)
+		if len(parts) != 2 || parts[0] == "" {
 			return nil, fmt.Errorf("malformed auth for server: %s", server)
 		}
 
@@ -776,6 +1325,10 @@ func makeRegistryCredential(server string, authConfig AuthConfig) (*azaci.ImageR
 }
 
 func makeRegistryCredentialFromDockerConfig(server string, configEntry DockerConfigEntry) (*azaci.ImageRegistryCredential, error) {
+	if configEntry.IdentityToken != "" {
+		return makeIdentityTokenCredential(server, configEntry.Username, configEntry.IdentityToken), nil
+	}
+
 	if configEntry.Username == "" {
 		return nil, fmt.Errorf("no username present in auth config for server: %s", server)
 	}
@@ -848,21 +1401,41 @@ func readDockerConfigJSONSecret(secret *v1.Secret, ips []azaci.ImageRegistryCred
 	return ips, err
 }
 
-//verify if Container is properly declared for the use on ACI
+// verify if Container is properly declared for the use on ACI
 func (p *ACIProvider) verifyContainer(container *v1.Container) error {
 	if len(container.Command) == 0 && len(container.Args) > 0 {
-		return errdefs.InvalidInput("ACI does not support providing args without specifying the command. Please supply both command and args to the pod spec.")
+		return errcodes.Wrap(errcodes.UnsupportedFields, errdefs.InvalidInput("ACI does not support providing args without specifying the command. Please supply both command and args to the pod spec."))
+	}
+	// ACI always mounts the root of a volume, mounting it anyway would silently expose the wrong data.
+	for _, mount := range container.VolumeMounts {
+		if mount.SubPath != "" || mount.SubPathExpr != "" {
+			return errcodes.Wrap(errcodes.UnsupportedFields, errdefs.InvalidInputf("volume mount %s of container %s uses a subPath, which ACI does not support. Mount the whole volume instead, or use items to select the keys of a secret or configMap", mount.Name, container.Name))
+		}
+	}
+	for _, probe := range []*v1.Probe{container.StartupProbe, container.LivenessProbe, container.ReadinessProbe} {
+		if probe == nil || probe.Handler.TCPSocket == nil {
+			continue
+		}
+		// TCP socket probes are emulated with /bin/sh, which Windows images do not have, so the probe
+		// would never pass and the container would be restarted forever.
+		if p.isWindows() {
+			return errcodes.Wrap(errcodes.InvalidProbe, errdefs.InvalidInputf("container %s has a tcpSocket probe, which is not supported in Windows pods. Use an httpGet or exec probe instead", container.Name))
+		}
+		// The host ends up in the shell command emulating the probe.
+		if host := probe.Handler.TCPSocket.Host; host != "" && net.ParseIP(host) == nil && len(utilvalidation.IsDNS1123Subdomain(host)) > 0 {
+			return errcodes.Wrap(errcodes.InvalidProbe, errdefs.InvalidInputf("container %s has a tcpSocket probe with host %q, which is neither an IP address nor a DNS name", container.Name, host))
+		}
 	}
 	return nil
 }
 
-//this method is used for both initConainers and containers
+// this method is used for both initConainers and containers
 func (p *ACIProvider) getCommand(container *v1.Container) *[]string {
 	command := append(container.Command, container.Args...)
 	return &command
 }
 
-//get VolumeMounts declared on Container as []aci.VolumeMount
+// get VolumeMounts declared on Container as []aci.VolumeMount
 func (p *ACIProvider) getVolumeMounts(container *v1.Container) *[]azaci.VolumeMount {
 	volumeMounts := make([]azaci.VolumeMount, 0, len(container.VolumeMounts))
 	for i := range container.VolumeMounts {
@@ -875,21 +1448,16 @@ func (p *ACIProvider) getVolumeMounts(container *v1.Container) *[]azaci.VolumeMo
 	return &volumeMounts
 }
 
-//get EnvironmentVariables declared on Container as []aci.EnvironmentVariable
-func (p *ACIProvider) getEnvironmentVariables(container *v1.Container) *[]azaci.EnvironmentVariable {
-	environmentVariable := make([]azaci.EnvironmentVariable, 0, len(container.Env))
-	for i := range container.Env {
-		if container.Env[i].Value != "" {
-			envVar := getACIEnvVar(container.Env[i])
-			environmentVariable = append(environmentVariable, envVar)
-		}
-	}
-	return &environmentVariable
-}
-
-//get InitContainers defined in Pod as []aci.InitContainerDefinition
+// get InitContainers defined in Pod as []aci.InitContainerDefinition
 func (p *ACIProvider) getInitContainers(ctx context.Context, pod *v1.Pod) ([]azaci.InitContainerDefinition, error) {
 	initContainers := make([]azaci.InitContainerDefinition, 0, len(pod.Spec.InitContainers))
+	if len(pod.Spec.InitContainers) == 0 {
+		return initContainers, nil
+	}
+	serviceEnvs, err := p.getServiceEnvironmentVariables(pod)
+	if err != nil {
+		return nil, err
+	}
 	for i, initContainer := range pod.Spec.InitContainers {
 		err := p.verifyContainer(&initContainer)
 		if err != nil {
@@ -899,32 +1467,42 @@ func (p *ACIProvider) getInitContainers(ctx context.Context, pod *v1.Pod) ([]aza
 
 		if initContainer.Ports != nil {
 			log.G(ctx).Errorf("azure container instances initcontainers do not support ports")
-			return nil, errdefs.InvalidInput("azure container instances initContainers do not support ports")
+			return nil, errcodes.Wrap(errcodes.UnsupportedFields, errdefs.InvalidInput("azure container instances initContainers do not support ports"))
 		}
 		if initContainer.Resources.Requests != nil {
 			log.G(ctx).Errorf("azure container instances initcontainers do not support resources requests")
-			return nil, errdefs.InvalidInput("azure container instances initContainers do not support resources requests")
+			return nil, errcodes.Wrap(errcodes.UnsupportedFields, errdefs.InvalidInput("azure container instances initContainers do not support resources requests"))
 		}
 		if initContainer.Resources.Limits != nil {
 			log.G(ctx).Errorf("azure container instances initcontainers do not support resources limits")
-			return nil, errdefs.InvalidInput("azure container instances initContainers do not support resources limits")
+			return nil, errcodes.Wrap(errcodes.UnsupportedFields, errdefs.InvalidInput("azure container instances initContainers do not support resources limits"))
 		}
 		if initContainer.LivenessProbe != nil {
 			log.G(ctx).Errorf("azure container instances initcontainers do not support livenessProbe")
-			return nil, errdefs.InvalidInput("azure container instances initContainers do not support livenessProbe")
+			return nil, errcodes.Wrap(errcodes.UnsupportedFields, errdefs.InvalidInput("azure container instances initContainers do not support livenessProbe"))
 		}
 		if initContainer.ReadinessProbe != nil {
 			log.G(ctx).Errorf("azure container instances initcontainers do not support readinessProbe")
-			return nil, errdefs.InvalidInput("azure container instances initContainers do not support readinessProbe")
+			return nil, errcodes.Wrap(errcodes.UnsupportedFields, errdefs.InvalidInput("azure container instances initContainers do not support readinessProbe"))
+		}
+		if initContainer.StartupProbe != nil {
+			log.G(ctx).Errorf("azure container instances initcontainers do not support startupProbe")
+			return nil, errcodes.Wrap(errcodes.UnsupportedFields, errdefs.InvalidInput("azure container instances initContainers do not support startupProbe"))
 		}
 
+		environmentVariables, err := p.getEnvironmentVariables(pod, &pod.Spec.InitContainers[i])
+		if err != nil {
+			return nil, err
+		}
+		environmentVariables = addServiceEnvironmentVariables(environmentVariables, serviceEnvs)
+
 		newInitContainer := azaci.InitContainerDefinition{
 			Name: &pod.Spec.InitContainers[i].Name,
-			InitContainerPropertiesDefinition: &azaci.InitContainerPropertiesDefinition {
-				Image: &pod.Spec.InitContainers[i].Image,
-				Command: p.getCommand(&pod.Spec.InitContainers[i]),
-				VolumeMounts: p.getVolumeMounts(&pod.Spec.InitContainers[i]),
-				EnvironmentVariables: p.getEnvironmentVariables(&pod.Spec.InitContainers[i]),
+			InitContainerPropertiesDefinition: &azaci.InitContainerPropertiesDefinition{
+				Image:                &pod.Spec.InitContainers[i].Image,
+				Command:              p.getCommand(&pod.Spec.InitContainers[i]),
+				VolumeMounts:         p.getVolumeMounts(&pod.Spec.InitContainers[i]),
+				EnvironmentVariables: environmentVariables,
 			},
 		}
 
@@ -935,12 +1513,16 @@ func (p *ACIProvider) getInitContainers(ctx context.Context, pod *v1.Pod) ([]aza
 
 func (p *ACIProvider) getContainers(pod *v1.Pod) (*[]azaci.Container, error) {
 	containers := make([]azaci.Container, 0, len(pod.Spec.Containers))
+	serviceEnvs, err := p.getServiceEnvironmentVariables(pod)
+	if err != nil {
+		return nil, err
+	}
 
 	podContainers := pod.Spec.Containers
 	for c := range podContainers {
 
-		if len(podContainers[c].Command) == 0 && len(podContainers[c].Args) > 0 {
-			return nil, errdefs.InvalidInput("ACI does not support providing args without specifying the command. Please supply both command and args to the pod spec.")
+		if err := p.verifyContainer(&podContainers[c]); err != nil {
+			return nil, err
 		}
 		cmd := append(podContainers[c].Command, podContainers[c].Args...)
 		ports := make([]azaci.ContainerPort, 0, len(podContainers[c].Ports))
@@ -974,16 +1556,11 @@ func (p *ACIProvider) getContainers(pod *v1.Pod) (*[]azaci.Container, error) {
 			aciContainer.VolumeMounts = &volList
 		}
 
-		initEnv := make([]azaci.EnvironmentVariable, 0, len(podContainers[c].Env))
-		aciContainer.EnvironmentVariables = &initEnv
-		for _, e := range podContainers[c].Env {
-			env := aciContainer.EnvironmentVariables
-			if e.Value != "" {
-				envVar := getACIEnvVar(e)
-				envList := append(*env, envVar)
-				aciContainer.EnvironmentVariables = &envList
-			}
+		environmentVariables, err := p.getEnvironmentVariables(pod, &podContainers[c])
+		if err != nil {
+			return nil, err
 		}
+		aciContainer.EnvironmentVariables = addServiceEnvironmentVariables(environmentVariables, serviceEnvs)
 
 		// NOTE(robbiezhang): ACI CPU request must be times of 10m
 		cpuRequest := 1.00
@@ -1033,7 +1610,7 @@ func (p *ACIProvider) getContainers(pod *v1.Pod) (*[]azaci.Container, error) {
 				}
 
 				if gpu.Value() == 0 {
-					return nil, errors.New("GPU must be a integer number")
+					return nil, errcodes.Errorf(errcodes.GPUNotAvailable, "GPU must be a integer number")
 				}
 
 				count := int32(gpu.Value())
@@ -1048,16 +1625,17 @@ func (p *ACIProvider) getContainers(pod *v1.Pod) (*[]azaci.Container, error) {
 			}
 		}
 
-		if podContainers[c].LivenessProbe != nil {
-			probe, err := getProbe(podContainers[c].LivenessProbe, podContainers[c].Ports)
+		livenessProbe, readinessProbe := getEffectiveProbes(podContainers[c].StartupProbe, podContainers[c].LivenessProbe, podContainers[c].ReadinessProbe)
+		if livenessProbe != nil {
+			probe, err := getProbe(livenessProbe, podContainers[c].Ports)
 			if err != nil {
 				return nil, err
 			}
 			aciContainer.LivenessProbe = probe
 		}
 
-		if podContainers[c].ReadinessProbe != nil {
-			probe, err := getProbe(podContainers[c].ReadinessProbe, podContainers[c].Ports)
+		if readinessProbe != nil {
+			probe, err := getProbe(readinessProbe, podContainers[c].Ports)
 			if err != nil {
 				return nil, err
 			}
@@ -1069,35 +1647,103 @@ func (p *ACIProvider) getContainers(pod *v1.Pod) (*[]azaci.Container, error) {
 	return &containers, nil
 }
 
+// getResourceAdjustments describes every place where the effective ACI resources differ
+// from what the pod asked for, because of defaulting or ACI's rounding rules.
+func getResourceAdjustments(pod *v1.Pod, containers []azaci.Container) []string {
+	adjustments := make([]string, 0)
+	for c := range pod.Spec.Containers {
+		if c >= len(containers) || containers[c].Resources == nil || containers[c].Resources.Requests == nil {
+			continue
+		}
+		podContainer := pod.Spec.Containers[c]
+		requests := containers[c].Resources.Requests
+
+		if _, ok := podContainer.Resources.Requests[v1.ResourceCPU]; !ok {
+			adjustments = append(adjustments, fmt.Sprintf("container %s cpu request defaulted to %.2f", podContainer.Name, *requests.CPU))
+		} else if original := podContainer.Resources.Requests.Cpu(); int64(math.Round(*requests.CPU*1000)) != original.MilliValue() {
+			adjustments = append(adjustments, fmt.Sprintf("container %s cpu request %s rounded to %.2f", podContainer.Name, original.String(), *requests.CPU))
+		}
+
+		if _, ok := podContainer.Resources.Requests[v1.ResourceMemory]; !ok {
+			adjustments = append(adjustments, fmt.Sprintf("container %s memory request defaulted to %.1fGB", podContainer.Name, *requests.MemoryInGB))
+		} else if original := podContainer.Resources.Requests.Memory(); int64(math.Round(*requests.MemoryInGB*10))*100000000 != original.Value() {
+			adjustments = append(adjustments, fmt.Sprintf("container %s memory request %s rounded to %.1fGB", podContainer.Name, original.String(), *requests.MemoryInGB))
+		}
+
+		limits := containers[c].Resources.Limits
+		if limits == nil {
+			continue
+		}
+		if _, ok := podContainer.Resources.Limits[v1.ResourceMemory]; ok {
+			if original := podContainer.Resources.Limits.Memory(); int64(math.Round(*limits.MemoryInGB*10))*100000000 != original.Value() {
+				adjustments = append(adjustments, fmt.Sprintf("container %s memory limit %s rounded to %.1fGB", podContainer.Name, original.String(), *limits.MemoryInGB))
+			}
+		}
+	}
+	return adjustments
+}
+
 func (p *ACIProvider) getGPUSKU(pod *v1.Pod) (azaci.GpuSku, error) {
-	if len(p.gpuSKUs) == 0 {
-		return "", fmt.Errorf("the pod requires GPU resource, but ACI doesn't provide GPU enabled container group in region %s", p.region)
+	gpuSKUs := p.getGPUSKUs()
+	if len(gpuSKUs) == 0 {
+		return "", errcodes.Errorf(errcodes.GPUNotAvailable, "the pod requires GPU resource, but ACI doesn't provide GPU enabled container group in region %s", p.region)
 	}
 
 	if desiredSKU, ok := pod.Annotations[gpuTypeAnnotation]; ok {
-		for _, supportedSKU := range p.gpuSKUs {
+		for _, supportedSKU := range gpuSKUs {
 			if strings.EqualFold(desiredSKU, string(supportedSKU)) {
 				return supportedSKU, nil
 			}
 		}
 
-		return "", fmt.Errorf("the pod requires GPU SKU %s, but ACI only supports SKUs %v in region %s", desiredSKU, p.region, p.gpuSKUs)
+		return "", errcodes.Errorf(errcodes.GPUNotAvailable, "the pod requires GPU SKU %s, but ACI only supports SKUs %v in region %s", desiredSKU, gpuSKUs, p.region)
 	}
 
-	return p.gpuSKUs[0], nil
+	return gpuSKUs[0], nil
+}
+
+// getEffectiveProbes folds a startup probe into the liveness and readiness probes, since ACI has
+// no startup probe. Both probes are delayed until the startup probe would have given up, so a slow
+// starting container is neither restarted nor reported ready before then. Without a readiness
+// probe the startup probe gates the readiness instead. It never becomes a liveness probe: the
+// startup probe only runs until the container started in Kubernetes, and a failing liveness probe
+// would restart the container for the rest of its life.
+func getEffectiveProbes(startupProbe, livenessProbe, readinessProbe *v1.Probe) (*v1.Probe, *v1.Probe) {
+	if startupProbe == nil {
+		return livenessProbe, readinessProbe
+	}
+
+	delay := startupProbe.InitialDelaySeconds + startupProbe.PeriodSeconds*startupProbe.FailureThreshold
+	if livenessProbe != nil {
+		livenessProbe = livenessProbe.DeepCopy()
+		livenessProbe.InitialDelaySeconds += delay
+	}
+	if readinessProbe != nil {
+		readinessProbe = readinessProbe.DeepCopy()
+		readinessProbe.InitialDelaySeconds += delay
+	} else {
+		readinessProbe = startupProbe.DeepCopy()
+	}
+	return livenessProbe, readinessProbe
 }
 
 func getProbe(probe *v1.Probe, ports []v1.ContainerPort) (*azaci.ContainerProbe, error) {
+	handlers := 0
+	for _, set := range []bool{probe.Handler.Exec != nil, probe.Handler.HTTPGet != nil, probe.Handler.TCPSocket != nil} {
+		if set {
+			handlers++
+		}
+	}
 
-	if probe.Handler.Exec != nil && probe.Handler.HTTPGet != nil {
-		return nil, fmt.Errorf("probe may not specify more than one of \"exec\" and \"httpGet\"")
+	if handlers > 1 {
+		return nil, errcodes.Errorf(errcodes.InvalidProbe, "probe may not specify more than one of \"exec\", \"httpGet\" and \"tcpSocket\"")
 	}
 
-	if probe.Handler.Exec == nil && probe.Handler.HTTPGet == nil {
-		return nil, fmt.Errorf("probe must specify one of \"exec\" and \"httpGet\"")
+	if handlers == 0 {
+		return nil, errcodes.Errorf(errcodes.InvalidProbe, "probe must specify one of \"exec\", \"httpGet\" and \"tcpSocket\"")
 	}
 
-	// Probes have can have an Exec or HTTP Get Handler.
+	// Probes have can have an Exec, HTTP Get or TCP Socket Handler.
 	// Create those if they exist, then add to the
 	// ContainerProbe struct
 	var exec *azaci.ContainerExec
@@ -1107,30 +1753,40 @@ func getProbe(probe *v1.Probe, ports []v1.ContainerPort) (*azaci.ContainerProbe,
 		}
 	}
 
+	// ACI has no TCP socket probe, so it is emulated with an exec probe that opens the port.
+	if probe.Handler.TCPSocket != nil {
+		portValue, err := getProbePort(probe.Handler.TCPSocket.Port, ports)
+		if err != nil {
+			return nil, err
+		}
+		command := getTCPSocketProbeCommand(probe.Handler.TCPSocket.Host, portValue, probe.TimeoutSeconds)
+		exec = &azaci.ContainerExec{
+			Command: &command,
+		}
+	}
+
 	var httpGET *azaci.ContainerHTTPGet
 	if probe.Handler.HTTPGet != nil {
-		var portValue int32
-		port := probe.Handler.HTTPGet.Port
-		switch port.Type {
-		case intstr.Int:
-			portValue = int32(port.IntValue())
-		case intstr.String:
-			portName := port.String()
-			for _, p := range ports {
-				if portName == p.Name {
-					portValue = p.ContainerPort
-					break
-				}
-			}
-			if portValue == 0 {
-				return nil, fmt.Errorf("unable to find named port: %s", portName)
-			}
+		portValue, err := getProbePort(probe.Handler.HTTPGet.Port, ports)
+		if err != nil {
+			return nil, err
 		}
 
 		httpGET = &azaci.ContainerHTTPGet{
 			Port:   &portValue,
 			Path:   &probe.Handler.HTTPGet.Path,
-			Scheme: azaci.Scheme(probe.Handler.HTTPGet.Scheme),
+			Scheme: getProbeScheme(probe.Handler.HTTPGet.Scheme),
+		}
+
+		if len(probe.Handler.HTTPGet.HTTPHeaders) > 0 {
+			headers := make([]azaci.HTTPHeader, 0, len(probe.Handler.HTTPGet.HTTPHeaders))
+			for i := range probe.Handler.HTTPGet.HTTPHeaders {
+				headers = append(headers, azaci.HTTPHeader{
+					Name:  &probe.Handler.HTTPGet.HTTPHeaders[i].Name,
+					Value: &probe.Handler.HTTPGet.HTTPHeaders[i].Value,
+				})
+			}
+			httpGET.HTTPHeaders = &headers
 		}
 	}
 
@@ -1145,11 +1801,61 @@ func getProbe(probe *v1.Probe, ports []v1.ContainerPort) (*azaci.ContainerProbe,
 	}, nil
 }
 
-// Filters service account secret volume for Windows.
+// getProbeScheme converts the Kubernetes URI scheme ("HTTP"/"HTTPS") to the lower case values ACI accepts.
+func getProbeScheme(scheme v1.URIScheme) azaci.Scheme {
+	switch scheme {
+	case v1.URISchemeHTTPS:
+		return azaci.SchemeHTTPS
+	case v1.URISchemeHTTP:
+		return azaci.SchemeHTTP
+	default:
+		return ""
+	}
+}
+
+// getProbePort resolves a numeric or named probe port against the container ports.
+func getProbePort(port intstr.IntOrString, ports []v1.ContainerPort) (int32, error) {
+	var portValue int32
+	switch port.Type {
+	case intstr.Int:
+		portValue = int32(port.IntValue())
+	case intstr.String:
+		portName := port.String()
+		for _, p := range ports {
+			if portName == p.Name {
+				portValue = p.ContainerPort
+				break
+			}
+		}
+		if portValue == 0 {
+			return 0, errcodes.Errorf(errcodes.InvalidProbe, "unable to find named port: %s", portName)
+		}
+	}
+	return portValue, nil
+}
+
+// getTCPSocketProbeCommand builds a shell command which succeeds when a TCP connection
+// can be opened, using nc when the image ships it and bash's /dev/tcp otherwise. The image
+// needs /bin/sh and either nc, or bash and timeout: distroless and scratch images cannot be
+// probed this way. The host is quoted on top of verifyContainer only accepting IP addresses and
+// DNS names.
+func getTCPSocketProbeCommand(host string, port int32, timeoutSeconds int32) []string {
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	if timeoutSeconds <= 0 {
+		timeoutSeconds = 1
+	}
+	redirect := fmt.Sprintf("</dev/tcp/%s/%d", shellQuote(host), port)
+	script := fmt.Sprintf("nc -z -w %[3]d %[1]s %[2]d || timeout %[3]d bash -c %[4]s", shellQuote(host), port, timeoutSeconds, shellQuote(redirect))
+	return []string{"/bin/sh", "-c", script}
+}
+
+// Filters service account secret volume for Windows and for pods opting out of the token.
 // Service account secret volume gets automatically turned on if not specified otherwise.
 // ACI doesn't support secret volume for Windows, so we need to filter it.
-func filterWindowsServiceAccountSecretVolume(ctx context.Context, osType string, cgw *client2.ContainerGroupWrapper) {
-	if strings.EqualFold(osType, "Windows") {
+func filterServiceAccountSecretVolume(ctx context.Context, pod *v1.Pod, osType string, cgw *client2.ContainerGroupWrapper) {
+	if strings.EqualFold(osType, "Windows") || !automountServiceAccountToken(pod) {
 		serviceAccountSecretVolumeName := make(map[string]bool)
 
 		for index, container := range *cgw.ContainerGroupPropertiesWrapper.ContainerGroupProperties.Containers {
@@ -1163,13 +1869,29 @@ func filterWindowsServiceAccountSecretVolume(ctx context.Context, osType string,
 			}
 			(*cgw.ContainerGroupPropertiesWrapper.ContainerGroupProperties.Containers)[index].VolumeMounts = &volumeMounts
 		}
+		if initContainers := cgw.ContainerGroupPropertiesWrapper.ContainerGroupProperties.InitContainers; initContainers != nil {
+			for index, container := range *initContainers {
+				if container.VolumeMounts == nil {
+					continue
+				}
+				volumeMounts := make([]azaci.VolumeMount, 0, len(*container.VolumeMounts))
+				for _, volumeMount := range *container.VolumeMounts {
+					if !strings.EqualFold(serviceAccountSecretMountPath, *volumeMount.MountPath) {
+						volumeMounts = append(volumeMounts, volumeMount)
+					} else {
+						serviceAccountSecretVolumeName[*volumeMount.Name] = true
+					}
+				}
+				(*initContainers)[index].VolumeMounts = &volumeMounts
+			}
+		}
 
 		if len(serviceAccountSecretVolumeName) == 0 {
 			return
 		}
 
 		l := log.G(ctx).WithField("containerGroup", cgw.Name)
-		l.Infof("Ignoring service account secret volumes '%v' for Windows", reflect.ValueOf(serviceAccountSecretVolumeName).MapKeys())
+		l.Infof("Ignoring service account secret volumes '%v' for %s", reflect.ValueOf(serviceAccountSecretVolumeName).MapKeys(), pod.Name)
 
 		volumes := make([]azaci.Volume, 0, len(*cgw.ContainerGroupPropertiesWrapper.ContainerGroupProperties.Volumes))
 		for _, volume := range *cgw.ContainerGroupPropertiesWrapper.ContainerGroupProperties.Volumes {
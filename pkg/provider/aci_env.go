@@ -0,0 +1,303 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	v1 "k8s.io/api/core/v1"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// getEnvironmentVariables resolves the environment declared on a container as []aci.EnvironmentVariable.
+// Values sourced from secrets are passed as SecureValue so ACI never returns them. Like the kubelet,
+// envFrom is expanded first and env entries override keys with the same name.
+func (p *ACIProvider) getEnvironmentVariables(pod *v1.Pod, container *v1.Container) (*[]azaci.EnvironmentVariable, error) {
+	environmentVariables := make([]azaci.EnvironmentVariable, 0, len(container.Env))
+	indexes := make(map[string]int)
+	setEnv := func(env azaci.EnvironmentVariable) {
+		if i, ok := indexes[*env.Name]; ok {
+			environmentVariables[i] = env
+			return
+		}
+		indexes[*env.Name] = len(environmentVariables)
+		environmentVariables = append(environmentVariables, env)
+	}
+
+	for _, envFrom := range container.EnvFrom {
+		envs, err := p.getEnvFromSource(pod, envFrom)
+		if err != nil {
+			return nil, fmt.Errorf("envFrom of container %s: %v", container.Name, err)
+		}
+		for _, env := range envs {
+			setEnv(env)
+		}
+	}
+
+	for i := range container.Env {
+		e := container.Env[i]
+
+		if e.ValueFrom != nil && e.ValueFrom.SecretKeyRef != nil {
+			value, ok, err := p.getSecretKeyRefValue(pod, e.ValueFrom.SecretKeyRef)
+			if err != nil {
+				return nil, fmt.Errorf("env %s of container %s: %v", e.Name, container.Name, err)
+			}
+			if ok {
+				setEnv(azaci.EnvironmentVariable{
+					Name:        &container.Env[i].Name,
+					SecureValue: &value,
+				})
+			}
+			continue
+		}
+
+		if e.ValueFrom != nil && e.ValueFrom.ConfigMapKeyRef != nil {
+			value, ok, err := p.getConfigMapKeyRefValue(pod, e.ValueFrom.ConfigMapKeyRef)
+			if err != nil {
+				return nil, fmt.Errorf("env %s of container %s: %v", e.Name, container.Name, err)
+			}
+			if ok {
+				setEnv(azaci.EnvironmentVariable{
+					Name:  &container.Env[i].Name,
+					Value: &value,
+				})
+			}
+			continue
+		}
+
+		if e.ValueFrom != nil && (e.ValueFrom.FieldRef != nil || e.ValueFrom.ResourceFieldRef != nil) {
+			value, ok, err := p.getDownwardAPIEnvValue(pod, container, e.ValueFrom)
+			if err != nil {
+				return nil, fmt.Errorf("env %s of container %s: %v", e.Name, container.Name, err)
+			}
+			if ok {
+				setEnv(azaci.EnvironmentVariable{
+					Name:  &container.Env[i].Name,
+					Value: &value,
+				})
+			} else {
+				p.recordEvent(pod, v1.EventTypeWarning, "DownwardAPIFieldUnavailable", "Env %s of container %s is not set, %s is only known after ACI creates the container group", e.Name, container.Name, e.ValueFrom.FieldRef.FieldPath)
+			}
+			continue
+		}
+
+		if e.Value != "" {
+			setEnv(getACIEnvVar(e))
+		}
+	}
+	return &environmentVariables, nil
+}
+
+// getServiceEnvironmentVariables returns the env vars of the services the pod sees, like the kubelet
+// sets them: the kubernetes service of the default namespace, and the services of the namespace of
+// the pod unless enableServiceLinks is false.
+func (p *ACIProvider) getServiceEnvironmentVariables(pod *v1.Pod) ([]azaci.EnvironmentVariable, error) {
+	services, err := p.resourceManager.ListServices()
+	if err != nil {
+		return nil, fmt.Errorf("unable to list the services for the environment of pod %s: %v", pod.Name, err)
+	}
+	enableServiceLinks := pod.Spec.EnableServiceLinks == nil || *pod.Spec.EnableServiceLinks
+
+	visible := make([]*v1.Service, 0, len(services))
+	for _, service := range services {
+		if service.Spec.ClusterIP == "" || service.Spec.ClusterIP == v1.ClusterIPNone || len(service.Spec.Ports) == 0 {
+			continue
+		}
+		master := service.Namespace == metav1.NamespaceDefault && service.Name == "kubernetes"
+		if master || (service.Namespace == pod.Namespace && enableServiceLinks) {
+			visible = append(visible, service)
+		}
+	}
+	sort.Slice(visible, func(i, j int) bool {
+		return visible[i].Namespace+"/"+visible[i].Name < visible[j].Namespace+"/"+visible[j].Name
+	})
+
+	var envs []azaci.EnvironmentVariable
+	add := func(name, value string) {
+		envs = append(envs, azaci.EnvironmentVariable{Name: &name, Value: &value})
+	}
+	for _, service := range visible {
+		prefix := serviceEnvVarName(service.Name)
+		ip := service.Spec.ClusterIP
+		add(prefix+"_SERVICE_HOST", ip)
+		add(prefix+"_SERVICE_PORT", strconv.Itoa(int(service.Spec.Ports[0].Port)))
+		for _, port := range service.Spec.Ports {
+			if port.Name != "" {
+				add(prefix+"_SERVICE_PORT_"+serviceEnvVarName(port.Name), strconv.Itoa(int(port.Port)))
+			}
+		}
+
+		// The Docker link variables.
+		for i, port := range service.Spec.Ports {
+			protocol := string(v1.ProtocolTCP)
+			if port.Protocol != "" {
+				protocol = string(port.Protocol)
+			}
+			url := strings.ToLower(protocol) + "://" + net.JoinHostPort(ip, strconv.Itoa(int(port.Port)))
+			if i == 0 {
+				add(prefix+"_PORT", url)
+			}
+			portPrefix := fmt.Sprintf("%s_PORT_%d_%s", prefix, port.Port, strings.ToUpper(protocol))
+			add(portPrefix, url)
+			add(portPrefix+"_PROTO", strings.ToLower(protocol))
+			add(portPrefix+"_PORT", strconv.Itoa(int(port.Port)))
+			add(portPrefix+"_ADDR", ip)
+		}
+	}
+	return envs, nil
+}
+
+func serviceEnvVarName(name string) string {
+	return strings.ToUpper(strings.Replace(name, "-", "_", -1))
+}
+
+// addServiceEnvironmentVariables appends the service env vars the container does not set itself.
+func addServiceEnvironmentVariables(envs *[]azaci.EnvironmentVariable, serviceEnvs []azaci.EnvironmentVariable) *[]azaci.EnvironmentVariable {
+	set := make(map[string]bool, len(*envs))
+	for _, env := range *envs {
+		set[*env.Name] = true
+	}
+	for _, env := range serviceEnvs {
+		if !set[*env.Name] {
+			*envs = append(*envs, env)
+		}
+	}
+	return envs
+}
+
+// getDownwardAPIEnvValue computes a fieldRef or resourceFieldRef env var at creation time. The pod IPs
+// are assigned by ACI when the container group is created, so ok is false for them.
+func (p *ACIProvider) getDownwardAPIEnvValue(pod *v1.Pod, container *v1.Container, source *v1.EnvVarSource) (string, bool, error) {
+	if source.ResourceFieldRef != nil {
+		value, err := getResourceFieldValue(pod, container.Name, source.ResourceFieldRef)
+		return value, err == nil, err
+	}
+
+	switch source.FieldRef.FieldPath {
+	case "status.hostIP":
+		return p.internalIP, true, nil
+	case "status.podIP", "status.podIPs":
+		return "", false, nil
+	}
+	value, err := getPodFieldValue(pod, source.FieldRef.FieldPath)
+	return value, err == nil, err
+}
+
+// getEnvFromSource expands every key of the referenced configMap or secret into env vars, in key order.
+// Keys that are not valid env var names are skipped with an event, as the kubelet does.
+func (p *ACIProvider) getEnvFromSource(pod *v1.Pod, source v1.EnvFromSource) ([]azaci.EnvironmentVariable, error) {
+	var data map[string][]byte
+	secure := false
+	switch {
+	case source.ConfigMapRef != nil:
+		ref := source.ConfigMapRef
+		configMap, err := p.resourceManager.GetConfigMap(ref.Name, pod.Namespace)
+		if err != nil || configMap == nil {
+			if ref.Optional != nil && *ref.Optional && (configMap == nil || k8serr.IsNotFound(err)) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("configMap %s is required by pod %s and could not be read: %v", ref.Name, pod.Name, err)
+		}
+		data = configMapData(configMap)
+	case source.SecretRef != nil:
+		ref := source.SecretRef
+		secret, err := p.resourceManager.GetSecret(ref.Name, pod.Namespace)
+		if err != nil || secret == nil {
+			if ref.Optional != nil && *ref.Optional && (secret == nil || k8serr.IsNotFound(err)) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("secret %s is required by pod %s and could not be read: %v", ref.Name, pod.Name, err)
+		}
+		data = secretData(secret)
+		secure = true
+	default:
+		return nil, nil
+	}
+
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var invalidKeys []string
+	envs := make([]azaci.EnvironmentVariable, 0, len(keys))
+	for _, k := range keys {
+		name := source.Prefix + k
+		if errs := validation.IsEnvVarName(name); len(errs) != 0 {
+			invalidKeys = append(invalidKeys, k)
+			continue
+		}
+		value := string(data[k])
+		env := azaci.EnvironmentVariable{Name: &name}
+		if secure {
+			env.SecureValue = &value
+		} else {
+			env.Value = &value
+		}
+		envs = append(envs, env)
+	}
+	if len(invalidKeys) > 0 {
+		p.recordEvent(pod, v1.EventTypeWarning, "InvalidEnvironmentVariableNames", "Keys [%s] from envFrom were skipped since they are considered invalid environment variable names", strings.Join(invalidKeys, ", "))
+	}
+	return envs, nil
+}
+
+// getSecretKeyRefValue returns the value of a secret key. A missing secret or key is
+// an error unless the reference is optional, in which case ok is false.
+func (p *ACIProvider) getSecretKeyRefValue(pod *v1.Pod, ref *v1.SecretKeySelector) (string, bool, error) {
+	optional := ref.Optional != nil && *ref.Optional
+
+	secret, err := p.resourceManager.GetSecret(ref.Name, pod.Namespace)
+	if err != nil || secret == nil {
+		if optional && (secret == nil || k8serr.IsNotFound(err)) {
+			return "", false, nil
+		}
+		return "", false, fmt.Errorf("secret %s is required by pod %s and could not be read: %v", ref.Name, pod.Name, err)
+	}
+
+	if value, ok := secret.Data[ref.Key]; ok {
+		return string(value), true, nil
+	}
+	if value, ok := secret.StringData[ref.Key]; ok {
+		return value, true, nil
+	}
+	if optional {
+		return "", false, nil
+	}
+	return "", false, fmt.Errorf("key %s does not exist in secret %s", ref.Key, ref.Name)
+}
+
+// getConfigMapKeyRefValue returns the value of a configMap key. A missing configMap or key is
+// an error unless the reference is optional, in which case ok is false.
+func (p *ACIProvider) getConfigMapKeyRefValue(pod *v1.Pod, ref *v1.ConfigMapKeySelector) (string, bool, error) {
+	optional := ref.Optional != nil && *ref.Optional
+
+	configMap, err := p.resourceManager.GetConfigMap(ref.Name, pod.Namespace)
+	if err != nil || configMap == nil {
+		if optional && (configMap == nil || k8serr.IsNotFound(err)) {
+			return "", false, nil
+		}
+		return "", false, fmt.Errorf("configMap %s is required by pod %s and could not be read: %v", ref.Name, pod.Name, err)
+	}
+
+	if value, ok := configMap.Data[ref.Key]; ok {
+		return value, true, nil
+	}
+	if value, ok := configMap.BinaryData[ref.Key]; ok {
+		return string(value), true, nil
+	}
+	if optional {
+		return "", false, nil
+	}
+	return "", false, fmt.Errorf("key %s does not exist in configMap %s", ref.Key, ref.Name)
+}
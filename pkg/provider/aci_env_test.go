@@ -0,0 +1,292 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"testing"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	"github.com/golang/mock/gomock"
+	testsutil "github.com/virtual-kubelet/azure-aci/pkg/tests"
+	"github.com/virtual-kubelet/node-cli/manager"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	v1 "k8s.io/api/core/v1"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// newServiceLister returns a lister of the services, for the tests that create container groups.
+func newServiceLister(services ...*v1.Service) corev1listers.ServiceLister {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, service := range services {
+		_ = indexer.Add(service)
+	}
+	return corev1listers.NewServiceLister(indexer)
+}
+
+func TestGetEnvironmentVariablesFromSecretKeyRef(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	secretLister := NewMockSecretLister(mockCtrl)
+	secretNamespaceLister := NewMockSecretNamespaceLister(mockCtrl)
+	secretLister.EXPECT().Secrets("ns").Return(secretNamespaceLister).AnyTimes()
+	secretNamespaceLister.EXPECT().Get("db").Return(&v1.Secret{
+		Data: map[string][]byte{"password": []byte("s3cret")},
+	}, nil).AnyTimes()
+	secretNamespaceLister.EXPECT().Get("missing").Return(nil, k8serr.NewNotFound(schema.GroupResource{Resource: "secrets"}, "missing")).AnyTimes()
+
+	rm, err := manager.NewResourceManager(
+		NewMockPodLister(mockCtrl),
+		secretLister,
+		NewMockConfigMapLister(mockCtrl),
+		NewMockServiceLister(mockCtrl),
+		NewMockPersistentVolumeClaimLister(mockCtrl),
+		NewMockPersistentVolumeLister(mockCtrl))
+	if err != nil {
+		t.Fatal("Unable to prepare the mocks for resourceManager", err)
+	}
+	p := &ACIProvider{resourceManager: rm}
+
+	optional := true
+	pod := testsutil.CreatePodObj("pod", "ns")
+	container := &v1.Container{
+		Name: "app",
+		Env: []v1.EnvVar{
+			{Name: "PLAIN", Value: "value"},
+			{Name: "DB_PASSWORD", ValueFrom: &v1.EnvVarSource{SecretKeyRef: &v1.SecretKeySelector{
+				LocalObjectReference: v1.LocalObjectReference{Name: "db"},
+				Key:                  "password",
+			}}},
+			{Name: "OPTIONAL", ValueFrom: &v1.EnvVarSource{SecretKeyRef: &v1.SecretKeySelector{
+				LocalObjectReference: v1.LocalObjectReference{Name: "missing"},
+				Key:                  "password",
+				Optional:             &optional,
+			}}},
+		},
+	}
+
+	envs, err := p.getEnvironmentVariables(pod, container)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(2, len(*envs)), "optional missing secret should be skipped")
+	assert.Check(t, is.Equal("DB_PASSWORD", *(*envs)[1].Name))
+	assert.Check(t, is.Equal("s3cret", *(*envs)[1].SecureValue), "secret value should be resolved")
+	assert.Check(t, (*envs)[1].Value == nil, "secret value should not be plain")
+
+	container.Env[1].ValueFrom.SecretKeyRef.Key = "user"
+	_, err = p.getEnvironmentVariables(pod, container)
+	assert.Check(t, err != nil, "missing key of a required secret should fail")
+}
+
+func TestGetEnvironmentVariablesFromConfigMapKeyRef(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	configMapLister := NewMockConfigMapLister(mockCtrl)
+	configMapNamespaceLister := NewMockConfigMapNamespaceLister(mockCtrl)
+	configMapLister.EXPECT().ConfigMaps("ns").Return(configMapNamespaceLister).AnyTimes()
+	configMapNamespaceLister.EXPECT().Get("settings").Return(&v1.ConfigMap{
+		Data: map[string]string{"level": "debug"},
+	}, nil).AnyTimes()
+	configMapNamespaceLister.EXPECT().Get("missing").Return(nil, k8serr.NewNotFound(schema.GroupResource{Resource: "configmaps"}, "missing")).AnyTimes()
+
+	rm, err := manager.NewResourceManager(
+		NewMockPodLister(mockCtrl),
+		NewMockSecretLister(mockCtrl),
+		configMapLister,
+		NewMockServiceLister(mockCtrl),
+		NewMockPersistentVolumeClaimLister(mockCtrl),
+		NewMockPersistentVolumeLister(mockCtrl))
+	if err != nil {
+		t.Fatal("Unable to prepare the mocks for resourceManager", err)
+	}
+	p := &ACIProvider{resourceManager: rm}
+
+	optional := true
+	pod := testsutil.CreatePodObj("pod", "ns")
+	container := &v1.Container{
+		Name: "app",
+		Env: []v1.EnvVar{
+			{Name: "LOG_LEVEL", ValueFrom: &v1.EnvVarSource{ConfigMapKeyRef: &v1.ConfigMapKeySelector{
+				LocalObjectReference: v1.LocalObjectReference{Name: "settings"},
+				Key:                  "level",
+			}}},
+			{Name: "OPTIONAL", ValueFrom: &v1.EnvVarSource{ConfigMapKeyRef: &v1.ConfigMapKeySelector{
+				LocalObjectReference: v1.LocalObjectReference{Name: "missing"},
+				Key:                  "level",
+				Optional:             &optional,
+			}}},
+		},
+	}
+
+	envs, err := p.getEnvironmentVariables(pod, container)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(1, len(*envs)), "optional missing configMap should be skipped")
+	assert.Check(t, is.Equal("LOG_LEVEL", *(*envs)[0].Name))
+	assert.Check(t, is.Equal("debug", *(*envs)[0].Value))
+
+	container.Env[1].ValueFrom.ConfigMapKeyRef.Optional = nil
+	_, err = p.getEnvironmentVariables(pod, container)
+	assert.Check(t, err != nil, "missing required configMap should fail")
+}
+
+func TestGetEnvironmentVariablesFromEnvFrom(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	secretLister := NewMockSecretLister(mockCtrl)
+	secretNamespaceLister := NewMockSecretNamespaceLister(mockCtrl)
+	secretLister.EXPECT().Secrets("ns").Return(secretNamespaceLister).AnyTimes()
+	secretNamespaceLister.EXPECT().Get("db").Return(&v1.Secret{
+		Data: map[string][]byte{"PASSWORD": []byte("s3cret")},
+	}, nil).AnyTimes()
+
+	configMapLister := NewMockConfigMapLister(mockCtrl)
+	configMapNamespaceLister := NewMockConfigMapNamespaceLister(mockCtrl)
+	configMapLister.EXPECT().ConfigMaps("ns").Return(configMapNamespaceLister).AnyTimes()
+	configMapNamespaceLister.EXPECT().Get("settings").Return(&v1.ConfigMap{
+		Data: map[string]string{"LEVEL": "debug", "MODE": "fast", "not-valid=": "x"},
+	}, nil).AnyTimes()
+	configMapNamespaceLister.EXPECT().Get("missing").Return(nil, k8serr.NewNotFound(schema.GroupResource{Resource: "configmaps"}, "missing")).AnyTimes()
+
+	rm, err := manager.NewResourceManager(
+		NewMockPodLister(mockCtrl),
+		secretLister,
+		configMapLister,
+		NewMockServiceLister(mockCtrl),
+		NewMockPersistentVolumeClaimLister(mockCtrl),
+		NewMockPersistentVolumeLister(mockCtrl))
+	if err != nil {
+		t.Fatal("Unable to prepare the mocks for resourceManager", err)
+	}
+	p := &ACIProvider{resourceManager: rm}
+
+	optional := true
+	pod := testsutil.CreatePodObj("pod", "ns")
+	container := &v1.Container{
+		Name: "app",
+		EnvFrom: []v1.EnvFromSource{
+			{ConfigMapRef: &v1.ConfigMapEnvSource{LocalObjectReference: v1.LocalObjectReference{Name: "settings"}}},
+			{Prefix: "DB_", SecretRef: &v1.SecretEnvSource{LocalObjectReference: v1.LocalObjectReference{Name: "db"}}},
+			{ConfigMapRef: &v1.ConfigMapEnvSource{LocalObjectReference: v1.LocalObjectReference{Name: "missing"}, Optional: &optional}},
+		},
+		Env: []v1.EnvVar{
+			{Name: "MODE", Value: "slow"},
+		},
+	}
+
+	envs, err := p.getEnvironmentVariables(pod, container)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(3, len(*envs)), "invalid keys should be skipped")
+	assert.Check(t, is.Equal("LEVEL", *(*envs)[0].Name))
+	assert.Check(t, is.Equal("MODE", *(*envs)[1].Name))
+	assert.Check(t, is.Equal("slow", *(*envs)[1].Value), "env should override envFrom")
+	assert.Check(t, is.Equal("DB_PASSWORD", *(*envs)[2].Name))
+	assert.Check(t, is.Equal("s3cret", *(*envs)[2].SecureValue), "secret keys should be secure")
+
+	container.EnvFrom[2].ConfigMapRef.Optional = nil
+	_, err = p.getEnvironmentVariables(pod, container)
+	assert.Check(t, err != nil, "missing required configMap should fail")
+}
+
+func TestGetEnvironmentVariablesFromDownwardAPI(t *testing.T) {
+	p := &ACIProvider{internalIP: "10.0.0.4"}
+
+	pod := testsutil.CreatePodObj("pod", "ns")
+	container := &pod.Spec.Containers[0]
+	container.Resources = v1.ResourceRequirements{
+		Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("500m")},
+	}
+	container.Env = []v1.EnvVar{
+		{Name: "POD_NAME", ValueFrom: &v1.EnvVarSource{FieldRef: &v1.ObjectFieldSelector{FieldPath: "metadata.name"}}},
+		{Name: "POD_NAMESPACE", ValueFrom: &v1.EnvVarSource{FieldRef: &v1.ObjectFieldSelector{FieldPath: "metadata.namespace"}}},
+		{Name: "HOST_IP", ValueFrom: &v1.EnvVarSource{FieldRef: &v1.ObjectFieldSelector{FieldPath: "status.hostIP"}}},
+		{Name: "POD_IP", ValueFrom: &v1.EnvVarSource{FieldRef: &v1.ObjectFieldSelector{FieldPath: "status.podIP"}}},
+		{Name: "CPU_LIMIT", ValueFrom: &v1.EnvVarSource{ResourceFieldRef: &v1.ResourceFieldSelector{
+			Resource: "limits.cpu",
+			Divisor:  resource.MustParse("1m"),
+		}}},
+	}
+
+	envs, err := p.getEnvironmentVariables(pod, container)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(4, len(*envs)), "pod IP is not known at creation time")
+	assert.Check(t, is.Equal("pod", *(*envs)[0].Value))
+	assert.Check(t, is.Equal("ns", *(*envs)[1].Value))
+	assert.Check(t, is.Equal("10.0.0.4", *(*envs)[2].Value))
+	assert.Check(t, is.Equal("CPU_LIMIT", *(*envs)[3].Name))
+	assert.Check(t, is.Equal("500", *(*envs)[3].Value), "limit should fall back to the request")
+
+	container.Env[0].ValueFrom.FieldRef.FieldPath = "status.phase"
+	_, err = p.getEnvironmentVariables(pod, container)
+	assert.Check(t, err != nil, "unsupported field path should fail")
+}
+
+func TestGetServiceEnvironmentVariables(t *testing.T) {
+	service := func(namespace, name, clusterIP string, ports ...v1.ServicePort) *v1.Service {
+		return &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+			Spec:       v1.ServiceSpec{ClusterIP: clusterIP, Ports: ports},
+		}
+	}
+	rm, err := manager.NewResourceManager(nil, nil, nil, newServiceLister(
+		service("default", "kubernetes", "10.0.0.1", v1.ServicePort{Name: "https", Port: 443, Protocol: v1.ProtocolTCP}),
+		service("ns", "my-db", "10.0.0.20", v1.ServicePort{Port: 5432}, v1.ServicePort{Name: "metrics", Port: 9187, Protocol: v1.ProtocolTCP}),
+		service("ns", "headless", v1.ClusterIPNone, v1.ServicePort{Port: 80}),
+		service("other", "web", "10.0.0.30", v1.ServicePort{Port: 80}),
+	), nil, nil)
+	if err != nil {
+		t.Fatal("Unable to prepare the resourceManager", err)
+	}
+	p := &ACIProvider{resourceManager: rm}
+
+	values := func(envs []azaci.EnvironmentVariable) map[string]string {
+		m := make(map[string]string, len(envs))
+		for _, env := range envs {
+			m[*env.Name] = *env.Value
+		}
+		return m
+	}
+
+	pod := testsutil.CreatePodObj("pod", "ns")
+	envs, err := p.getServiceEnvironmentVariables(pod)
+	assert.NilError(t, err)
+	got := values(envs)
+	assert.Check(t, is.Equal("10.0.0.1", got["KUBERNETES_SERVICE_HOST"]))
+	assert.Check(t, is.Equal("443", got["KUBERNETES_SERVICE_PORT"]))
+	assert.Check(t, is.Equal("443", got["KUBERNETES_SERVICE_PORT_HTTPS"]))
+	assert.Check(t, is.Equal("tcp://10.0.0.1:443", got["KUBERNETES_PORT"]))
+	assert.Check(t, is.Equal("10.0.0.20", got["MY_DB_SERVICE_HOST"]))
+	assert.Check(t, is.Equal("5432", got["MY_DB_SERVICE_PORT"]))
+	assert.Check(t, is.Equal("9187", got["MY_DB_SERVICE_PORT_METRICS"]))
+	assert.Check(t, is.Equal("tcp://10.0.0.20:5432", got["MY_DB_PORT"]))
+	assert.Check(t, is.Equal("tcp://10.0.0.20:9187", got["MY_DB_PORT_9187_TCP"]))
+	assert.Check(t, is.Equal("tcp", got["MY_DB_PORT_9187_TCP_PROTO"]))
+	assert.Check(t, is.Equal("9187", got["MY_DB_PORT_9187_TCP_PORT"]))
+	assert.Check(t, is.Equal("10.0.0.20", got["MY_DB_PORT_9187_TCP_ADDR"]))
+	_, ok := got["HEADLESS_SERVICE_HOST"]
+	assert.Check(t, !ok, "headless services should be skipped")
+	_, ok = got["WEB_SERVICE_HOST"]
+	assert.Check(t, !ok, "services of other namespaces should be skipped")
+
+	disabled := false
+	pod.Spec.EnableServiceLinks = &disabled
+	envs, err = p.getServiceEnvironmentVariables(pod)
+	assert.NilError(t, err)
+	got = values(envs)
+	assert.Check(t, is.Equal("10.0.0.1", got["KUBERNETES_SERVICE_HOST"]), "the kubernetes service should always be set")
+	_, ok = got["MY_DB_SERVICE_HOST"]
+	assert.Check(t, !ok, "the services of the namespace should not be set without service links")
+
+	name, value := "KUBERNETES_SERVICE_HOST", "api.example.com"
+	merged := addServiceEnvironmentVariables(&[]azaci.EnvironmentVariable{{Name: &name, Value: &value}}, envs)
+	assert.Check(t, is.Equal(len(envs), len(*merged)))
+	assert.Check(t, is.Equal("api.example.com", values(*merged)["KUBERNETES_SERVICE_HOST"]), "the env of the container should take precedence")
+}
@@ -0,0 +1,162 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"sync"
+	"time"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// aciEventMark is the last occurrence of an ACI event that was published.
+type aciEventMark struct {
+	count int32
+	last  time.Time
+}
+
+// aciEvents remembers the ACI events of the container groups and their containers that were
+// published as events of their pods, so each occurrence is published once. ACI repeats its events in
+// every instance view, aggregated by name with a count.
+type aciEvents struct {
+	since time.Time
+
+	mu   sync.Mutex
+	seen map[PodIdentifier]map[string]aciEventMark
+	// states are the last provisioning states of the container groups.
+	states map[PodIdentifier]string
+}
+
+// newACIEvents publishes the events occurring after since, the events of container groups created
+// before the provider started were published by its previous run.
+func newACIEvents(since time.Time) *aciEvents {
+	return &aciEvents{since: since, seen: make(map[PodIdentifier]map[string]aciEventMark), states: make(map[PodIdentifier]string)}
+}
+
+// unseen returns the events of the source, the container group or one of its containers, that
+// occurred since they were last published, and marks them published.
+func (e *aciEvents) unseen(pod PodIdentifier, source string, events *[]azaci.Event) []azaci.Event {
+	if events == nil {
+		return nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	marks, ok := e.seen[pod]
+	if !ok {
+		marks = make(map[string]aciEventMark)
+		e.seen[pod] = marks
+	}
+
+	var unseen []azaci.Event
+	for _, event := range *events {
+		if event.Name == nil {
+			continue
+		}
+		mark := aciEventMark{last: aciEventTime(event)}
+		if event.Count != nil {
+			mark.count = *event.Count
+		}
+		key := source + "/" + *event.Name
+		if !mark.last.After(e.since) {
+			continue
+		}
+		if previous, ok := marks[key]; ok && mark.count <= previous.count && !mark.last.After(previous.last) {
+			continue
+		}
+		marks[key] = mark
+		unseen = append(unseen, event)
+	}
+	return unseen
+}
+
+// transition records the provisioning state of the container group of the pod, and returns the
+// previous one if it changed.
+func (e *aciEvents) transition(pod PodIdentifier, state string) (string, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	previous, ok := e.states[pod]
+	if ok && previous == state {
+		return previous, false
+	}
+	e.states[pod] = state
+	return previous, true
+}
+
+// forget drops the events of a deleted pod.
+func (e *aciEvents) forget(pod PodIdentifier) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.seen, pod)
+	delete(e.states, pod)
+}
+
+func aciEventTime(event azaci.Event) time.Time {
+	if event.LastTimestamp != nil {
+		return event.LastTimestamp.Time
+	}
+	if event.FirstTimestamp != nil {
+		return event.FirstTimestamp.Time
+	}
+	return time.Time{}
+}
+
+// publishACIEvents publishes the new events of the container group and its containers, e.g. image
+// pull failures or killed containers, as events of the pod so they show in kubectl describe.
+func (p *ACIProvider) publishACIEvents(cg *azaci.ContainerGroup) {
+	if p.aciEvents == nil || p.eventRecorder == nil || cg.Tags == nil {
+		return
+	}
+	tag := func(name string) string {
+		if value := cg.Tags[name]; value != nil {
+			return *value
+		}
+		return ""
+	}
+	id := PodIdentifier{namespace: tag("Namespace"), name: tag("PodName")}
+	if id.namespace == "" || id.name == "" {
+		return
+	}
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: id.namespace, Name: id.name, UID: types.UID(tag("UID"))}}
+
+	publish := func(source string, events []azaci.Event) {
+		for _, event := range events {
+			eventType := v1.EventTypeNormal
+			if event.Type != nil && *event.Type == v1.EventTypeWarning {
+				eventType = v1.EventTypeWarning
+			}
+			message := ""
+			if event.Message != nil {
+				message = *event.Message
+			}
+			if source != "" {
+				message = "container " + source + ": " + message
+			}
+			p.recordEvent(pod, eventType, *event.Name, "%s", message)
+		}
+	}
+
+	if cg.ContainerGroupProperties == nil {
+		return
+	}
+	if cg.InstanceView != nil {
+		publish("", p.aciEvents.unseen(id, "", cg.InstanceView.Events))
+	}
+	if cg.Containers == nil {
+		return
+	}
+	for _, container := range *cg.Containers {
+		if container.Name == nil || container.ContainerProperties == nil || container.InstanceView == nil {
+			continue
+		}
+		publish(*container.Name, p.aciEvents.unseen(id, *container.Name, container.InstanceView.Events))
+	}
+}
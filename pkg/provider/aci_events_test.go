@@ -0,0 +1,52 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"testing"
+	"time"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	"github.com/Azure/go-autorest/autorest/date"
+	testsutil "github.com/virtual-kubelet/azure-aci/pkg/tests"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	"k8s.io/client-go/tools/record"
+)
+
+func aciEvent(name, eventType, message string, count int32, last time.Time) azaci.Event {
+	return azaci.Event{Name: &name, Type: &eventType, Message: &message, Count: &count, LastTimestamp: &date.Time{Time: last}}
+}
+
+func TestPublishACIEvents(t *testing.T) {
+	started := time.Now()
+	recorder := record.NewFakeRecorder(10)
+	p := &ACIProvider{eventRecorder: recorder, aciEvents: newACIEvents(started)}
+
+	containers := testsutil.CreateACIContainersListObj("Waiting", "Running", started, started, false, false, false)
+	cg := testsutil.CreateContainerGroupObj("web", "ns", "Pending", containers, "Succeeded")
+	cgEvents := []azaci.Event{aciEvent("Scheduled", "Normal", "scheduled on a host", 1, started.Add(-time.Minute))}
+	cg.InstanceView.Events = &cgEvents
+	containerEvents := []azaci.Event{aciEvent("Pulling", "Normal", "pulling image nginx", 1, started.Add(time.Second))}
+	(*containers)[0].InstanceView.Events = &containerEvents
+
+	p.publishACIEvents(cg)
+	assert.Assert(t, is.Len(recorder.Events, 1), "events before the provider started should not be published")
+	assert.Check(t, is.Equal("Normal Pulling container "+testsutil.TestContainerName+": pulling image nginx", <-recorder.Events))
+
+	p.publishACIEvents(cg)
+	assert.Check(t, is.Len(recorder.Events, 0), "published events should not be published again")
+
+	containerEvents = append(containerEvents, aciEvent("Failed", "Warning", "failed to pull image", 1, started.Add(2*time.Second)))
+	containerEvents[0] = aciEvent("Pulling", "Normal", "pulling image nginx", 2, started.Add(2*time.Second))
+	p.publishACIEvents(cg)
+	assert.Assert(t, is.Len(recorder.Events, 2))
+	assert.Check(t, is.Contains(<-recorder.Events, "Normal Pulling"), "repeated events should be published again")
+	assert.Check(t, is.Contains(<-recorder.Events, "Warning Failed"))
+
+	p.aciEvents.forget(PodIdentifier{namespace: "ns", name: "web"})
+	p.publishACIEvents(cg)
+	assert.Check(t, is.Len(recorder.Events, 2), "the events of a recreated pod should be published")
+}
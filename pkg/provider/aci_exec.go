@@ -0,0 +1,166 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	"github.com/virtual-kubelet/virtual-kubelet/node/api"
+)
+
+const (
+	windowsExecShellCmd        = "cmd.exe"
+	windowsExecShellPowerShell = "powershell.exe"
+
+	// defaultExecTerminalCols and defaultExecTerminalRows are the terminal size of exec sessions whose
+	// client sends none.
+	defaultExecTerminalCols int32 = 120
+	defaultExecTerminalRows int32 = 60
+	// execTerminalSizeWait is how long the size of the terminal of the client is waited for before the
+	// exec is started with the default size. Clients send it right after the session is set up.
+	execTerminalSizeWait = time.Second
+)
+
+// posixShells are the shells users usually exec into, which do not exist in Windows containers.
+var posixShells = map[string]bool{
+	"sh":        true,
+	"bash":      true,
+	"/bin/sh":   true,
+	"/bin/bash": true,
+}
+
+func (p *ACIProvider) isWindows() bool {
+	return strings.EqualFold(p.operatingSystem, "Windows")
+}
+
+// getExecCommand renders the exec command as the single command line ACI expects. On Windows a
+// POSIX shell is replaced by the configured Windows shell started with the UTF-8 code page, and
+// arguments with spaces are quoted the way the Windows command line parser splits them.
+func (p *ACIProvider) getExecCommand(cmd []string) string {
+	if !p.isWindows() {
+		return strings.Join(cmd, " ")
+	}
+
+	if len(cmd) == 1 && posixShells[cmd[0]] {
+		shell := p.windowsExecShell
+		if shell == "" {
+			shell = windowsExecShellCmd
+		}
+		if strings.EqualFold(shell, windowsExecShellCmd) {
+			return "cmd.exe /K chcp 65001 >NUL"
+		}
+		return shell + " -NoLogo -NoExit -Command [Console]::OutputEncoding=[Text.Encoding]::UTF8"
+	}
+
+	args := make([]string, 0, len(cmd))
+	for _, arg := range cmd {
+		if arg == "" || strings.ContainsAny(arg, " \t\"") {
+			arg = `"` + strings.ReplaceAll(arg, `"`, `\"`) + `"`
+		}
+		args = append(args, arg)
+	}
+	return strings.Join(args, " ")
+}
+
+// execTerminalSize returns the size of the terminal of the client, in columns and rows, once it sent it.
+// ACI only sizes the terminal when the exec starts, so the size of a session is the first one the
+// client sends, unless ExecTerminalResize forwards the later ones.
+func execTerminalSize(ctx context.Context, attach api.AttachIO, wait time.Duration) (int32, int32) {
+	cols, rows := defaultExecTerminalCols, defaultExecTerminalRows
+	resize := attach.Resize()
+	if !attach.TTY() || resize == nil {
+		return cols, rows
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case size, ok := <-resize:
+		if ok && size.Width > 0 && size.Height > 0 {
+			cols, rows = int32(size.Width), int32(size.Height)
+		}
+	case <-timer.C:
+		log.G(ctx).Debugf("no terminal size received in %s, using %dx%d", wait, cols, rows)
+	case <-ctx.Done():
+	}
+	return cols, rows
+}
+
+// discardTerminalResizes consumes the resizes of the terminal of the client until done is closed, so
+// the client is not blocked sending them. The websocket of ACI exec sessions has no message to resize
+// the terminal, so sessions keep their initial size unless ExecTerminalResize forwards the resizes.
+func discardTerminalResizes(ctx context.Context, resize <-chan api.TermSize, done <-chan struct{}) {
+	if resize == nil {
+		return
+	}
+	for {
+		select {
+		case <-done:
+			return
+		case size, ok := <-resize:
+			if !ok {
+				return
+			}
+			log.G(ctx).Debugf("ignoring the resize of the exec terminal to %dx%d, ACI cannot resize a running exec", size.Width, size.Height)
+		}
+	}
+}
+
+// crlfWriter converts the CRLF line endings of Windows containers to LF, so the output of a
+// non interactive exec can be piped into tools on the client. A trailing CR is held back until
+// the next write shows whether it starts a line ending.
+type crlfWriter struct {
+	w         io.WriteCloser
+	pendingCR bool
+}
+
+func newCRLFWriter(w io.WriteCloser) *crlfWriter {
+	return &crlfWriter{w: w}
+}
+
+func (c *crlfWriter) Write(b []byte) (int, error) {
+	buf := make([]byte, 0, len(b)+1)
+	if c.pendingCR {
+		if len(b) == 0 || b[0] != '\n' {
+			buf = append(buf, '\r')
+		}
+		c.pendingCR = false
+	}
+
+	data := b
+	if len(data) > 0 && data[len(data)-1] == '\r' {
+		c.pendingCR = true
+		data = data[:len(data)-1]
+	}
+	buf = append(buf, bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))...)
+
+	if _, err := c.w.Write(buf); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (c *crlfWriter) Close() error {
+	if c.pendingCR {
+		c.pendingCR = false
+		if _, err := c.w.Write([]byte{'\r'}); err != nil {
+			return err
+		}
+	}
+	return c.w.Close()
+}
+
+// normalizeLogLineEndings converts the CRLF line endings of Windows container logs to LF.
+func (p *ACIProvider) normalizeLogLineEndings(logs string) string {
+	if !p.isWindows() {
+		return logs
+	}
+	return strings.ReplaceAll(logs, "\r\n", "\n")
+}
@@ -0,0 +1,168 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	"github.com/gorilla/websocket"
+	testsutil "github.com/virtual-kubelet/azure-aci/pkg/tests"
+	"github.com/virtual-kubelet/virtual-kubelet/node/api"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+type bufferWriteCloser struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (b *bufferWriteCloser) Close() error {
+	b.closed = true
+	return nil
+}
+
+type fakeAttachIO struct {
+	stdout *bufferWriteCloser
+}
+
+func (f *fakeAttachIO) Stdin() io.Reader            { return nil }
+func (f *fakeAttachIO) Stdout() io.WriteCloser      { return f.stdout }
+func (f *fakeAttachIO) Stderr() io.WriteCloser      { return nil }
+func (f *fakeAttachIO) TTY() bool                   { return false }
+func (f *fakeAttachIO) Resize() <-chan api.TermSize { return nil }
+
+func TestRunInContainerWithExecDialer(t *testing.T) {
+	passwords := make(chan string, 1)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		_, password, err := c.ReadMessage()
+		if err != nil {
+			return
+		}
+		passwords <- string(password)
+		_ = c.WriteMessage(websocket.BinaryMessage, []byte("hello"))
+		_ = c.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	}))
+	defer server.Close()
+
+	aciMocks := createNewACIMock()
+	aciMocks.MockGetContainerGroupInfo = func(ctx context.Context, resourceGroup, namespace, name, nodeName string) (*azaci.ContainerGroup, error) {
+		return testsutil.CreateContainerGroupObj(name, namespace, "Running", &[]azaci.Container{}, "Succeeded"), nil
+	}
+	aciMocks.MockExecuteContainerCommand = func(ctx context.Context, resourceGroup, cgName, containerName string, containerReq azaci.ContainerExecRequest) (azaci.ContainerExecResponse, error) {
+		uri, password := "wss"+strings.TrimPrefix(server.URL, "https"), "s3cret"
+		return azaci.ContainerExecResponse{WebSocketURI: &uri, Password: &password}, nil
+	}
+
+	exec := func(p *ACIProvider) (string, error) {
+		out := &bufferWriteCloser{}
+		err := p.RunInContainer(context.Background(), "ns", "web", "nginx", []string{"/bin/sh"}, &fakeAttachIO{stdout: out})
+		return out.String(), err
+	}
+
+	_, err := exec(&ACIProvider{azClientsAPIs: aciMocks, operatingSystem: "Linux"})
+	assert.Check(t, err != nil, "the default dialer should not trust the test certificate")
+
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+	p := &ACIProvider{azClientsAPIs: aciMocks, operatingSystem: "Linux"}
+	WithExecDialer(&websocket.Dialer{TLSClientConfig: &tls.Config{RootCAs: pool}})(p)
+	out, err := exec(p)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("hello", out))
+	assert.Check(t, is.Equal("s3cret", <-passwords))
+}
+
+// ttyAttachIO is an interactive session whose client sends the size of its terminal.
+type ttyAttachIO struct {
+	fakeAttachIO
+	resize chan api.TermSize
+}
+
+func (f *ttyAttachIO) TTY() bool                   { return true }
+func (f *ttyAttachIO) Resize() <-chan api.TermSize { return f.resize }
+
+func TestExecTerminalSize(t *testing.T) {
+	ctx := context.Background()
+
+	cols, rows := execTerminalSize(ctx, &fakeAttachIO{}, time.Second)
+	assert.Check(t, is.Equal(defaultExecTerminalCols, cols))
+	assert.Check(t, is.Equal(defaultExecTerminalRows, rows))
+
+	attach := &ttyAttachIO{resize: make(chan api.TermSize, 2)}
+	attach.resize <- api.TermSize{Width: 200, Height: 50}
+	cols, rows = execTerminalSize(ctx, attach, time.Second)
+	assert.Check(t, is.Equal(int32(200), cols))
+	assert.Check(t, is.Equal(int32(50), rows))
+
+	cols, rows = execTerminalSize(ctx, attach, time.Millisecond)
+	assert.Check(t, is.Equal(defaultExecTerminalCols, cols), "the default size should be used when the client sends none")
+	assert.Check(t, is.Equal(defaultExecTerminalRows, rows))
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		discardTerminalResizes(ctx, attach.resize, done)
+		close(stopped)
+	}()
+	for i := 0; i < 5; i++ {
+		select {
+		case attach.resize <- api.TermSize{Width: 80, Height: 24}:
+		case <-time.After(time.Second):
+			t.Fatal("the resizes of the session should be consumed")
+		}
+	}
+	close(done)
+	<-stopped
+}
+
+func TestGetExecCommand(t *testing.T) {
+	linux := &ACIProvider{operatingSystem: "Linux"}
+	assert.Check(t, is.Equal("/bin/sh", linux.getExecCommand([]string{"/bin/sh"})))
+	assert.Check(t, is.Equal("ls -la /tmp", linux.getExecCommand([]string{"ls", "-la", "/tmp"})))
+
+	windows := &ACIProvider{operatingSystem: "Windows"}
+	assert.Check(t, is.Equal("cmd.exe /K chcp 65001 >NUL", windows.getExecCommand([]string{"sh"})), "POSIX shell should be replaced by cmd.exe")
+	assert.Check(t, is.Equal(`cmd.exe /c "dir C:\Program Files"`, windows.getExecCommand([]string{"cmd.exe", "/c", `dir C:\Program Files`})), "arguments with spaces should be quoted")
+
+	windows.windowsExecShell = windowsExecShellPowerShell
+	assert.Check(t, is.Equal("powershell.exe -NoLogo -NoExit -Command [Console]::OutputEncoding=[Text.Encoding]::UTF8", windows.getExecCommand([]string{"/bin/bash"})))
+}
+
+func TestCRLFWriter(t *testing.T) {
+	buf := &bufferWriteCloser{}
+	w := newCRLFWriter(buf)
+
+	for _, chunk := range []string{"line1\r\nline2\r", "\nline3\r", "x\r"} {
+		n, err := w.Write([]byte(chunk))
+		assert.NilError(t, err)
+		assert.Check(t, is.Equal(len(chunk), n), "written count should match the input")
+	}
+	assert.NilError(t, w.Close())
+
+	assert.Check(t, is.Equal("line1\nline2\nline3\rx\r", buf.String()))
+	assert.Check(t, buf.closed, "underlying writer should be closed")
+}
+
+func TestNormalizeLogLineEndings(t *testing.T) {
+	logs := "first\r\nsecond\r\n"
+	assert.Check(t, is.Equal(logs, (&ACIProvider{operatingSystem: "Linux"}).normalizeLogLineEndings(logs)))
+	assert.Check(t, is.Equal("first\nsecond\n", (&ACIProvider{operatingSystem: "Windows"}).normalizeLogLineEndings(logs)))
+}
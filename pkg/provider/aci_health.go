@@ -0,0 +1,206 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	client2 "github.com/virtual-kubelet/azure-aci/pkg/client"
+	"github.com/virtual-kubelet/azure-aci/pkg/errcodes"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+)
+
+const (
+	defaultNodeStatusUpdateInterval = 1 * time.Minute
+	defaultHealthWindow             = 5 * time.Minute
+	defaultHealthBreakerCooldown    = 1 * time.Minute
+	defaultHealthErrorRateThreshold = 0.5
+	defaultHealthMinRequests        = 10
+)
+
+type armCallResult struct {
+	timestamp time.Time
+	failed    bool
+}
+
+// aciHealthMonitor keeps a sliding window of ARM call outcomes and opens a breaker
+// when the error rate crosses the configured threshold. The breaker stays open for
+// at least the cooldown period and closes again on the first successful call after it.
+type aciHealthMonitor struct {
+	mu sync.Mutex
+
+	window             time.Duration
+	cooldown           time.Duration
+	errorRateThreshold float64
+	minRequests        int
+
+	results     []armCallResult
+	breakerOpen bool
+	openedAt    time.Time
+	lastError   error
+
+	// now returns the current time, time.Now when unset.
+	now func() time.Time
+}
+
+func newACIHealthMonitor() (*aciHealthMonitor, error) {
+	h := &aciHealthMonitor{
+		window:             defaultHealthWindow,
+		cooldown:           defaultHealthBreakerCooldown,
+		errorRateThreshold: defaultHealthErrorRateThreshold,
+		minRequests:        defaultHealthMinRequests,
+		now:                time.Now,
+	}
+
+	if value := os.Getenv("ACI_HEALTH_WINDOW_IN_SECOND"); value != "" {
+		ret, err := strconv.Atoi(value)
+		if err != nil || ret <= 0 {
+			return nil, errcodes.Errorf(errcodes.InvalidConfig, "env ACI_HEALTH_WINDOW_IN_SECOND must be a positive integer, got %q", value)
+		}
+		h.window = time.Duration(ret) * time.Second
+	}
+
+	if value := os.Getenv("ACI_HEALTH_BREAKER_COOLDOWN_IN_SECOND"); value != "" {
+		ret, err := strconv.Atoi(value)
+		if err != nil || ret <= 0 {
+			return nil, errcodes.Errorf(errcodes.InvalidConfig, "env ACI_HEALTH_BREAKER_COOLDOWN_IN_SECOND must be a positive integer, got %q", value)
+		}
+		h.cooldown = time.Duration(ret) * time.Second
+	}
+
+	if value := os.Getenv("ACI_HEALTH_ERROR_RATE_THRESHOLD"); value != "" {
+		ret, err := strconv.ParseFloat(value, 64)
+		if err != nil || ret <= 0 || ret > 1 {
+			return nil, errcodes.Errorf(errcodes.InvalidConfig, "env ACI_HEALTH_ERROR_RATE_THRESHOLD must be a number in (0, 1], got %q", value)
+		}
+		h.errorRateThreshold = ret
+	}
+
+	if value := os.Getenv("ACI_HEALTH_MIN_REQUESTS"); value != "" {
+		ret, err := strconv.Atoi(value)
+		if err != nil || ret <= 0 {
+			return nil, errcodes.Errorf(errcodes.InvalidConfig, "env ACI_HEALTH_MIN_REQUESTS must be a positive integer, got %q", value)
+		}
+		h.minRequests = ret
+	}
+
+	return h, nil
+}
+
+// Record registers the outcome of an ARM call. NotFound responses are a healthy answer from ARM.
+func (h *aciHealthMonitor) Record(err error) {
+	failed := err != nil && !errdefs.IsNotFound(err)
+	now := h.clock()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.results = append(h.results, armCallResult{timestamp: now, failed: failed})
+	h.trim(now)
+
+	if failed {
+		h.lastError = err
+	}
+
+	if h.breakerOpen {
+		if !failed && now.Sub(h.openedAt) >= h.cooldown {
+			h.breakerOpen = false
+			h.results = nil
+		}
+		return
+	}
+
+	if rate, total := h.errorRate(); total >= h.minRequests && rate >= h.errorRateThreshold {
+		h.breakerOpen = true
+		h.openedAt = now
+	}
+}
+
+// Healthy returns an error describing the ACI backend state when the breaker is open.
+func (h *aciHealthMonitor) Healthy() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.breakerOpen {
+		return nil
+	}
+	rate, total := h.errorRate()
+	return fmt.Errorf("ACI API is degraded: %.0f%% of the last %d requests failed, last error: %v", rate*100, total, h.lastError)
+}
+
+// OutageSince returns when the breaker opened, while it is open.
+func (h *aciHealthMonitor) OutageSince() (time.Time, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.openedAt, h.breakerOpen
+}
+
+func (h *aciHealthMonitor) clock() time.Time {
+	if h.now == nil {
+		return time.Now()
+	}
+	return h.now()
+}
+
+func (h *aciHealthMonitor) trim(now time.Time) {
+	i := 0
+	for ; i < len(h.results); i++ {
+		if now.Sub(h.results[i].timestamp) <= h.window {
+			break
+		}
+	}
+	h.results = h.results[i:]
+}
+
+func (h *aciHealthMonitor) errorRate() (float64, int) {
+	if len(h.results) == 0 {
+		return 0, 0
+	}
+	failures := 0
+	for _, r := range h.results {
+		if r.failed {
+			failures++
+		}
+	}
+	return float64(failures) / float64(len(h.results)), len(h.results)
+}
+
+// healthTrackingClient records the outcome of the ARM calls the provider relies on
+// for its steady state, so the node heartbeat can reflect ACI health.
+type healthTrackingClient struct {
+	client2.AzClientsInterface
+	health *aciHealthMonitor
+}
+
+func (c *healthTrackingClient) CreateContainerGroup(ctx context.Context, resourceGroup, podNS, podName string, cg *client2.ContainerGroupWrapper) error {
+	err := c.AzClientsInterface.CreateContainerGroup(ctx, resourceGroup, podNS, podName, cg)
+	c.health.Record(err)
+	return err
+}
+
+func (c *healthTrackingClient) GetContainerGroupInfo(ctx context.Context, resourceGroup, namespace, name, nodeName string) (*azaci.ContainerGroup, error) {
+	cg, err := c.AzClientsInterface.GetContainerGroupInfo(ctx, resourceGroup, namespace, name, nodeName)
+	c.health.Record(err)
+	return cg, err
+}
+
+func (c *healthTrackingClient) GetContainerGroupListResult(ctx context.Context, resourceGroup string) (*[]azaci.ContainerGroup, error) {
+	cgs, err := c.AzClientsInterface.GetContainerGroupListResult(ctx, resourceGroup)
+	c.health.Record(err)
+	return cgs, err
+}
+
+func (c *healthTrackingClient) DeleteContainerGroup(ctx context.Context, resourceGroup, cgName string) error {
+	err := c.AzClientsInterface.DeleteContainerGroup(ctx, resourceGroup, cgName)
+	c.health.Record(err)
+	return err
+}
@@ -0,0 +1,63 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/virtual-kubelet/azure-aci/pkg/errcodes"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestACIHealthMonitorOpensAndClosesBreaker(t *testing.T) {
+	h := &aciHealthMonitor{
+		window:             time.Minute,
+		cooldown:           0,
+		errorRateThreshold: 0.5,
+		minRequests:        4,
+	}
+
+	h.Record(nil)
+	h.Record(errdefs.NotFound("cg not found"))
+	assert.NilError(t, h.Healthy(), "NotFound should not count as a failure")
+
+	h.Record(errors.New("throttled"))
+	h.Record(errors.New("throttled"))
+	assert.Check(t, h.Healthy() != nil, "breaker should be open")
+
+	h.Record(nil)
+	assert.NilError(t, h.Healthy(), "breaker should close after a success once the cooldown passed")
+}
+
+func TestACIHealthMonitorNeedsMinimumRequests(t *testing.T) {
+	h := &aciHealthMonitor{
+		window:             time.Minute,
+		cooldown:           time.Minute,
+		errorRateThreshold: 0.5,
+		minRequests:        10,
+	}
+
+	h.Record(errors.New("boom"))
+	h.Record(errors.New("boom"))
+	assert.NilError(t, h.Healthy(), "too few requests to open the breaker")
+}
+
+func TestNewACIHealthMonitorRejectsNonPositiveValues(t *testing.T) {
+	for _, env := range []string{"ACI_HEALTH_WINDOW_IN_SECOND", "ACI_HEALTH_BREAKER_COOLDOWN_IN_SECOND", "ACI_HEALTH_MIN_REQUESTS"} {
+		for _, value := range []string{"0", "-1", "ten"} {
+			t.Run(env+"="+value, func(t *testing.T) {
+				t.Setenv(env, value)
+				_, err := newACIHealthMonitor()
+				code, ok := errcodes.Of(err)
+				assert.Check(t, ok, "expected an error with a code, got %v", err)
+				assert.Check(t, is.Equal(errcodes.InvalidConfig, code))
+			})
+		}
+	}
+}
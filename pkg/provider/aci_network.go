@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"strconv"
 	"strings"
 
 	utilvalidation "k8s.io/apimachinery/pkg/util/validation"
@@ -27,6 +28,10 @@ const (
 	maxDNSNameservers     = 3
 	maxDNSSearchPaths     = 6
 	maxDNSSearchListChars = 256
+	// resolv.conf caps ndots at 15
+	maxDNSNdots = 15
+
+	dnsNdotsAnnotation = "virtual-kubelet.io/dns-ndots"
 )
 
 func (p *ACIProvider) setVNETConfig(ctx context.Context, azConfig *auth.Config) error {
@@ -55,23 +60,30 @@ func (p *ACIProvider) setVNETConfig(ctx context.Context, azConfig *auth.Config)
 		p.subnetCIDR = subnetCIDR
 	}
 
+	if len(p.subnetConfigs) > 0 && p.subnetName == "" {
+		return fmt.Errorf("additional subnets are configured but no subnet name, the virtual node needs a subnet of its own")
+	}
+
 	if p.subnetName != "" {
 		if err := p.setupNetwork(ctx, azConfig); err != nil {
 			return fmt.Errorf("error setting up network: %v", err)
 		}
+		if err := p.setupSubnets(ctx, azConfig); err != nil {
+			return fmt.Errorf("error setting up network: %v", err)
+		}
 
-		masterURI := os.Getenv("MASTER_URI")
-		if masterURI == "" {
-			masterURI = "10.0.0.1"
+		p.masterURI = os.Getenv("MASTER_URI")
+		if p.masterURI == "" {
+			p.masterURI = "10.0.0.1"
 		}
 
-		clusterCIDR := os.Getenv("CLUSTER_CIDR")
-		if clusterCIDR == "" {
-			clusterCIDR = "10.240.0.0/16"
+		p.clusterCIDR = os.Getenv("CLUSTER_CIDR")
+		if p.clusterCIDR == "" {
+			p.clusterCIDR = "10.240.0.0/16"
 		}
 
 		// setup aci extensions
-		kubeExtensions, err := client2.GetKubeProxyExtension(serviceAccountSecretMountPath, masterURI, clusterCIDR)
+		kubeExtensions, err := client2.GetKubeProxyExtension(serviceAccountSecretMountPath, p.masterURI, p.clusterCIDR)
 		if err != nil {
 			return fmt.Errorf("error creating kube proxy extension: %v", err)
 		}
@@ -79,7 +91,7 @@ func (p *ACIProvider) setVNETConfig(ctx context.Context, azConfig *auth.Config)
 		p.containerGroupExtensions = append(p.containerGroupExtensions, kubeExtensions)
 
 		enableRealTimeMetricsExtension := os.Getenv("ENABLE_REAL_TIME_METRICS")
-		if enableRealTimeMetricsExtension == "true" {
+		if enableRealTimeMetricsExtension == "true" || p.realtimeMetrics {
 			realtimeExtension := client2.GetRealtimeMetricsExtension()
 			p.containerGroupExtensions = append(p.containerGroupExtensions, realtimeExtension)
 		}
@@ -87,6 +99,13 @@ func (p *ACIProvider) setVNETConfig(ctx context.Context, azConfig *auth.Config)
 		if kubeDNSIP := os.Getenv("KUBE_DNS_IP"); kubeDNSIP != "" {
 			p.kubeDNSIP = kubeDNSIP
 		}
+
+		if ndots := os.Getenv("ACI_DNS_NDOTS"); ndots != "" {
+			if _, err := parseNdots(ndots); err != nil {
+				return fmt.Errorf("env ACI_DNS_NDOTS is invalid: %v", err)
+			}
+			p.dnsNdots = ndots
+		}
 	}
 	return nil
 }
@@ -164,13 +183,12 @@ func (p *ACIProvider) setupNetwork(ctx context.Context, azConfig *auth.Config) e
 	return nil
 }
 
-func (p *ACIProvider) amendVnetResources(ctx context.Context, cg client2.ContainerGroupWrapper, pod *v1.Pod) {
-	if p.subnetName == "" {
+func (p *ACIProvider) amendVnetResources(ctx context.Context, cg client2.ContainerGroupWrapper, pod *v1.Pod, subnet *delegatedSubnet) {
+	if subnet == nil {
 		return
 	}
 
-	subnetID := "/subscriptions/" + p.vnetSubscriptionID + "/resourceGroups/" + p.vnetResourceGroup + "/providers/Microsoft.Network/virtualNetworks/" + p.vnetName + "/subnets/" + p.subnetName
-	cgIDList := []azaci.ContainerGroupSubnetID{{ID: &subnetID}}
+	cgIDList := []azaci.ContainerGroupSubnetID{{ID: &subnet.id}}
 	cg.ContainerGroupPropertiesWrapper.ContainerGroupProperties.SubnetIds = &cgIDList
 	cg.ContainerGroupPropertiesWrapper.ContainerGroupProperties.DNSConfig = p.getDNSConfig(ctx, pod)
 	cg.ContainerGroupPropertiesWrapper.Extensions = p.containerGroupExtensions
@@ -203,6 +221,9 @@ func (p *ACIProvider) getDNSConfig(ctx context.Context, pod *v1.Pod) *azaci.DNSC
 	if len(nameServers) == 0 {
 		return nil
 	}
+	if ndots := p.getDNSNdots(ctx, pod); ndots != "" && !hasDNSOption(options, "ndots") {
+		options = append(options, "ndots:"+ndots)
+	}
 	nameServers = formDNSNameserversFitsLimits(ctx, nameServers)
 	domain := formDNSSearchFitsLimits(ctx, searchDomains)
 	opt := strings.Join(options, " ")
@@ -215,6 +236,39 @@ func (p *ACIProvider) getDNSConfig(ctx context.Context, pod *v1.Pod) *azaci.DNSC
 	return &result
 }
 
+// getDNSNdots returns the ndots value requested by the pod annotation, falling back to the provider default.
+// Options set in the pod dnsConfig always take precedence over both.
+func (p *ACIProvider) getDNSNdots(ctx context.Context, pod *v1.Pod) string {
+	if ndots, ok := pod.Annotations[dnsNdotsAnnotation]; ok {
+		if _, err := parseNdots(ndots); err != nil {
+			log.G(ctx).WithField("method", "getDNSNdots").Warnf("ignoring annotation %s on pod %s: %v", dnsNdotsAnnotation, pod.Name, err)
+		} else {
+			return ndots
+		}
+	}
+	return p.dnsNdots
+}
+
+func parseNdots(value string) (int, error) {
+	ndots, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("ndots %q is not an integer", value)
+	}
+	if ndots < 0 || ndots > maxDNSNdots {
+		return 0, fmt.Errorf("ndots %d must be between 0 and %d", ndots, maxDNSNdots)
+	}
+	return ndots, nil
+}
+
+func hasDNSOption(options []string, name string) bool {
+	for _, option := range options {
+		if option == name || strings.HasPrefix(option, name+":") {
+			return true
+		}
+	}
+	return false
+}
+
 // This is taken from the kubelet equivalent -  https://github.com/kubernetes/kubernetes/blob/d24fe8a801748953a5c34fd34faa8005c6ad1770/pkg/kubelet/network/dns/dns.go#L141-L151
 func (p *ACIProvider) generateSearchesForDNSClusterFirst(dnsConfig *v1.PodDNSConfig, pod *v1.Pod) []string {
 
@@ -0,0 +1,81 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"gotest.tools/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetDNSConfigNdots(t *testing.T) {
+	ndots := "2"
+	cases := []struct {
+		description     string
+		providerNdots   string
+		annotations     map[string]string
+		dnsConfig       *v1.PodDNSConfig
+		expectedOptions string
+	}{
+		{
+			description:     "no ndots configured",
+			expectedOptions: "",
+		},
+		{
+			description:     "provider default",
+			providerNdots:   "3",
+			expectedOptions: "ndots:3",
+		},
+		{
+			description:     "annotation overrides provider default",
+			providerNdots:   "3",
+			annotations:     map[string]string{dnsNdotsAnnotation: "1"},
+			expectedOptions: "ndots:1",
+		},
+		{
+			description:     "invalid annotation falls back to provider default",
+			providerNdots:   "3",
+			annotations:     map[string]string{dnsNdotsAnnotation: "20"},
+			expectedOptions: "ndots:3",
+		},
+		{
+			description:   "pod dnsConfig wins",
+			providerNdots: "3",
+			annotations:   map[string]string{dnsNdotsAnnotation: "1"},
+			dnsConfig: &v1.PodDNSConfig{
+				Options: []v1.PodDNSConfigOption{{Name: "ndots", Value: &ndots}},
+			},
+			expectedOptions: "ndots:2",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			p := &ACIProvider{
+				kubeDNSIP:     "10.0.0.10",
+				clusterDomain: "cluster.local",
+				dnsNdots:      tc.providerNdots,
+			}
+			pod := &v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "pod",
+					Namespace:   "ns",
+					Annotations: tc.annotations,
+				},
+				Spec: v1.PodSpec{
+					DNSPolicy: v1.DNSClusterFirst,
+					DNSConfig: tc.dnsConfig,
+				},
+			}
+
+			dnsConfig := p.getDNSConfig(context.Background(), pod)
+			assert.Check(t, dnsConfig != nil, "dns config expected")
+			assert.Equal(t, tc.expectedOptions, *dnsConfig.Options)
+		})
+	}
+}
@@ -18,14 +18,19 @@ import (
 	"github.com/google/uuid"
 	"github.com/virtual-kubelet/azure-aci/pkg/auth"
 	"github.com/virtual-kubelet/azure-aci/pkg/client"
+	"github.com/virtual-kubelet/azure-aci/pkg/errcodes"
 	testsutil "github.com/virtual-kubelet/azure-aci/pkg/tests"
 	"github.com/virtual-kubelet/node-cli/manager"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
 	"gotest.tools/assert"
 
 	is "gotest.tools/assert/cmp"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 const (
@@ -360,7 +365,7 @@ func TestCreatePodWithResourceRequestAndLimit(t *testing.T) {
 func TestGetPodsWithEmptyList(t *testing.T) {
 	aciMocks := createNewACIMock()
 
-	aciMocks.MockGetContainerGroupList = func(ctx context.Context, resourceGroup string) (*[]azaci.ContainerGroup, error) {
+	aciMocks.MockListContainerGroupsByNodeName = func(ctx context.Context, resourceGroup, nodeName string) (*[]azaci.ContainerGroup, error) {
 		var result []azaci.ContainerGroup
 		return &result, nil
 	}
@@ -383,7 +388,7 @@ func TestGetPodsWithEmptyList(t *testing.T) {
 func TestGetPodsWithoutResourceRequestsLimits(t *testing.T) {
 	aciMocks := createNewACIMock()
 
-	aciMocks.MockGetContainerGroupList = func(ctx context.Context, resourceGroup string) (*[]azaci.ContainerGroup, error) {
+	aciMocks.MockListContainerGroupsByNodeName = func(ctx context.Context, resourceGroup, nodeName string) (*[]azaci.ContainerGroup, error) {
 		cgName := "default-nginx"
 		node := fakeNodeName
 		provisioning := "Creating"
@@ -477,6 +482,77 @@ func TestGetPodWithoutResourceRequestsLimits(t *testing.T) {
 	assert.Equal(t, ptrQuantity(resource.MustParse("1.5G")).Value(), pod.Spec.Containers[0].Resources.Requests.Memory().Value(), "Containers[0].Resources.Requests.Memory doesn't match")
 }
 
+func TestGetPodsOfNode(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	podLister := NewMockPodLister(mockCtrl)
+	mockPodsNamespaceLister := NewMockPodNamespaceLister(mockCtrl)
+	podLister.EXPECT().Pods("ns").Return(mockPodsNamespaceLister)
+	mockPodsNamespaceLister.EXPECT().Get("web").Return(testsutil.CreatePodObj("web", "ns"), nil)
+	resourceManager, err := manager.NewResourceManager(podLister, nil, nil, newServiceLister(), nil, nil)
+	if err != nil {
+		t.Fatal("Unable to prepare the mocks for resourceManager", err)
+	}
+
+	containers := testsutil.CreateACIContainersListObj("Running", "Initializing", testsutil.CgCreationTime.Add(time.Second*2), testsutil.CgCreationTime.Add(time.Second*3), false, false, false)
+	aciMocks := createNewACIMock()
+	aciMocks.MockListContainerGroupsByNodeName = func(ctx context.Context, resourceGroup, nodeName string) (*[]azaci.ContainerGroup, error) {
+		assert.Check(t, is.Equal(fakeNodeName, nodeName))
+		web := testsutil.CreateContainerGroupObj("web", "ns", "Running", nil, "Succeeded")
+		orphan := testsutil.CreateContainerGroupObj("orphan", "ns", "Running", nil, "Succeeded")
+		// The list has no properties, only the tags of the container groups.
+		web.ContainerGroupProperties, orphan.ContainerGroupProperties = nil, nil
+		return &[]azaci.ContainerGroup{*web, *orphan, {Name: &podName}}, nil
+	}
+	aciMocks.MockGetContainerGroupInfo = func(ctx context.Context, resourceGroup, namespace, name, nodeName string) (*azaci.ContainerGroup, error) {
+		if name != "web" {
+			return nil, errdefs.NotFound("container group not found")
+		}
+		return testsutil.CreateContainerGroupObj(name, namespace, "Running", containers, "Succeeded"), nil
+	}
+
+	provider, err := createTestProvider(aciMocks, resourceManager)
+	if err != nil {
+		t.Fatal("failed to create the test provider", err)
+	}
+
+	pods, err := provider.GetPods(context.Background())
+	assert.NilError(t, err)
+	assert.Assert(t, is.Len(pods, 1))
+	assert.Check(t, is.Equal("web", pods[0].Name))
+	assert.Check(t, is.Equal(v1.PodRunning, pods[0].Status.Phase))
+
+	ids, err := provider.ListActivePods(context.Background())
+	assert.NilError(t, err)
+	assert.Assert(t, is.Len(ids, 2), "untagged container groups should be skipped")
+	assert.Check(t, ids[0] == PodIdentifier{namespace: "ns", name: "web"})
+	assert.Check(t, ids[1] == PodIdentifier{namespace: "ns", name: "orphan"}, "container groups without pod should be listed")
+}
+
+func TestGetResourceAdjustments(t *testing.T) {
+	provider, err := createTestProvider(createNewACIMock(), nil)
+	if err != nil {
+		t.Fatal("failed to create the test provider", err)
+	}
+
+	pod := testsutil.CreatePodObj("pod", "ns")
+	pod.Spec.Containers[0].Resources.Limits = nil
+	containers, err := provider.getContainers(pod)
+	assert.NilError(t, err, "getContainers should not fail")
+	assert.Check(t, is.Equal(0, len(getResourceAdjustments(pod, *containers))), "no adjustment expected for ACI compatible requests")
+
+	pod.Spec.Containers[0].Resources.Requests = v1.ResourceList{
+		v1.ResourceCPU: resource.MustParse("1234m"),
+	}
+	containers, err = provider.getContainers(pod)
+	assert.NilError(t, err, "getContainers should not fail")
+	adjustments := getResourceAdjustments(pod, *containers)
+	assert.Check(t, is.Equal(2, len(adjustments)), "cpu rounding and memory defaulting expected")
+	assert.Check(t, is.Contains(adjustments[0], "cpu request 1234m rounded to 1.23"))
+	assert.Check(t, is.Contains(adjustments[1], "memory request defaulted to 1.5GB"))
+}
+
 func TestPodToACISecretEnvVar(t *testing.T) {
 
 	testKey := "testVar"
@@ -559,7 +635,7 @@ func createTestProvider(aciMocks *MockACIProvider, resourceManager *manager.Reso
 	}
 
 	if resourceManager == nil {
-		resourceManager, err = manager.NewResourceManager(nil, nil, nil, nil, nil, nil)
+		resourceManager, err = manager.NewResourceManager(nil, nil, nil, newServiceLister(), nil, nil)
 		if err != nil {
 			return nil, err
 		}
@@ -615,6 +691,7 @@ func TestConfigureNode(t *testing.T) {
 	assert.Equal(t, "true", node.ObjectMeta.Labels["alpha.service-controller.kubernetes.io/exclude-balancer"], "exclude-balancer label doesn't match")
 	assert.Equal(t, "true", node.ObjectMeta.Labels["node.kubernetes.io/exclude-from-external-load-balancers"], "exclude-from-external-load-balancers label doesn't match")
 	assert.Equal(t, "false", node.ObjectMeta.Labels["kubernetes.azure.com/managed"], "kubernetes.azure.com/managed label doesn't match")
+	assert.Equal(t, "true", node.ObjectMeta.Labels[gpuSKULabelPrefix+"p100"], "GPU SKU label doesn't match")
 }
 
 func TestCreatePodWithNamedLivenessProbe(t *testing.T) {
@@ -718,6 +795,195 @@ func TestCreatePodWithReadinessProbe(t *testing.T) {
 	}
 }
 
+func TestCreatePodWithStartupProbe(t *testing.T) {
+	podName := "pod-" + uuid.New().String()
+	podNamespace := "ns-" + uuid.New().String()
+
+	aciMocks := createNewACIMock()
+
+	aciMocks.MockCreateContainerGroup = func(ctx context.Context, resourceGroup, podNS, podName string, cg *client.ContainerGroupWrapper) error {
+		containers := *cg.ContainerGroupPropertiesWrapper.ContainerGroupProperties.Containers
+		assert.Check(t, (containers)[0].LivenessProbe != nil, "Liveness probe expected")
+		// 10s liveness delay + 5s startup delay + 30 failures * 10s startup period
+		assert.Check(t, is.Equal(int32(315), *(containers)[0].LivenessProbe.InitialDelaySeconds), "Initial Probe Delay doesn't match")
+		assert.Check(t, is.Equal(int32(5), *(containers)[0].LivenessProbe.PeriodSeconds), "Probe Period doesn't match")
+		assert.Check(t, is.Equal(int32(5), *(containers)[0].LivenessProbe.FailureThreshold), "Probe Failure Threshold doesn't match")
+		assert.Check(t, (containers)[0].ReadinessProbe != nil, "Readiness probe expected")
+		// 10s readiness delay + 5s startup delay + 30 failures * 10s startup period
+		assert.Check(t, is.Equal(int32(315), *(containers)[0].ReadinessProbe.InitialDelaySeconds), "Readiness Probe Delay doesn't match")
+		return nil
+	}
+
+	pod := testsutil.CreatePodObj(podName, podNamespace)
+	pod.Spec.Containers[0].StartupProbe = &v1.Probe{
+		Handler: v1.Handler{
+			Exec: &v1.ExecAction{
+				Command: []string{"cat", "/tmp/started"},
+			},
+		},
+		InitialDelaySeconds: 5,
+		PeriodSeconds:       10,
+		FailureThreshold:    30,
+	}
+
+	provider, err := createTestProvider(aciMocks, nil)
+	if err != nil {
+		t.Fatal("failed to create the test provider", err)
+	}
+
+	if err := provider.CreatePod(context.Background(), pod); err != nil {
+		t.Fatal("Failed to create pod", err)
+	}
+}
+
+func TestGetEffectiveProbesWithStartupProbeOnly(t *testing.T) {
+	startupProbe := &v1.Probe{
+		PeriodSeconds:    10,
+		FailureThreshold: 30,
+	}
+
+	livenessProbe, readinessProbe := getEffectiveProbes(startupProbe, nil, nil)
+	assert.Check(t, is.Nil(livenessProbe), "the startup probe should not become a liveness probe")
+	assert.Check(t, is.DeepEqual(startupProbe, readinessProbe), "the startup probe should gate the readiness")
+
+	livenessProbe, readinessProbe = getEffectiveProbes(nil, nil, nil)
+	assert.Check(t, is.Nil(livenessProbe), "no liveness probe expected")
+	assert.Check(t, is.Nil(readinessProbe), "no readiness probe expected")
+}
+
+func TestCreatePodWithTCPSocketProbe(t *testing.T) {
+	podName := "pod-" + uuid.New().String()
+	podNamespace := "ns-" + uuid.New().String()
+
+	aciMocks := createNewACIMock()
+
+	aciMocks.MockCreateContainerGroup = func(ctx context.Context, resourceGroup, podNS, podName string, cg *client.ContainerGroupWrapper) error {
+		containers := *cg.ContainerGroupPropertiesWrapper.ContainerGroupProperties.Containers
+		assert.Check(t, (containers)[0].ReadinessProbe != nil, "Readiness probe expected")
+		assert.Check(t, (containers)[0].ReadinessProbe.Exec != nil, "Expected an Exec Probe")
+		assert.Check(t, is.Nil((containers)[0].ReadinessProbe.HTTPGet), "HTTP Get Probe is not expected")
+		command := *(containers)[0].ReadinessProbe.Exec.Command
+		assert.Check(t, is.Equal(3, len(command)), "Exec command doesn't match")
+		assert.Check(t, is.Contains(command[2], "'127.0.0.1' 8080"), "Exec command should connect to the named port")
+		return nil
+	}
+
+	pod := testsutil.CreatePodObj(podName, podNamespace)
+	pod.Spec.Containers[0].ReadinessProbe.Handler = v1.Handler{
+		TCPSocket: &v1.TCPSocketAction{
+			Port: intstr.FromString("http"),
+		},
+	}
+
+	provider, err := createTestProvider(aciMocks, nil)
+	if err != nil {
+		t.Fatal("failed to create the test provider", err)
+	}
+
+	if err := provider.CreatePod(context.Background(), pod); err != nil {
+		t.Fatal("Failed to create pod", err)
+	}
+}
+
+func TestCreatePodWithHTTPSProbeHeaders(t *testing.T) {
+	podName := "pod-" + uuid.New().String()
+	podNamespace := "ns-" + uuid.New().String()
+
+	aciMocks := createNewACIMock()
+
+	aciMocks.MockCreateContainerGroup = func(ctx context.Context, resourceGroup, podNS, podName string, cg *client.ContainerGroupWrapper) error {
+		containers := *cg.ContainerGroupPropertiesWrapper.ContainerGroupProperties.Containers
+		httpGet := (containers)[0].LivenessProbe.HTTPGet
+		assert.Check(t, httpGet != nil, "Expected an HTTP Get Probe")
+		assert.Check(t, is.Equal(azaci.SchemeHTTPS, httpGet.Scheme), "Probe scheme doesn't match")
+		assert.Check(t, httpGet.HTTPHeaders != nil, "Probe headers expected")
+		assert.Check(t, is.Equal(1, len(*httpGet.HTTPHeaders)), "1 Probe header is expected")
+		assert.Check(t, is.Equal("Host", *(*httpGet.HTTPHeaders)[0].Name), "Probe header name doesn't match")
+		assert.Check(t, is.Equal("example.com", *(*httpGet.HTTPHeaders)[0].Value), "Probe header value doesn't match")
+		return nil
+	}
+
+	pod := testsutil.CreatePodObj(podName, podNamespace)
+	pod.Spec.Containers[0].LivenessProbe.Handler.HTTPGet.Scheme = v1.URISchemeHTTPS
+	pod.Spec.Containers[0].LivenessProbe.Handler.HTTPGet.HTTPHeaders = []v1.HTTPHeader{
+		{
+			Name:  "Host",
+			Value: "example.com",
+		},
+	}
+
+	provider, err := createTestProvider(aciMocks, nil)
+	if err != nil {
+		t.Fatal("failed to create the test provider", err)
+	}
+
+	if err := provider.CreatePod(context.Background(), pod); err != nil {
+		t.Fatal("Failed to create pod", err)
+	}
+}
+
+func TestCreatePodWithTCPSocketProbeOnWindows(t *testing.T) {
+	podName := "pod-" + uuid.New().String()
+	podNamespace := "ns-" + uuid.New().String()
+
+	aciMocks := createNewACIMock()
+	aciMocks.MockCreateContainerGroup = func(ctx context.Context, resourceGroup, podNS, podName string, cg *client.ContainerGroupWrapper) error {
+		t.Error("the container group should not be created")
+		return nil
+	}
+
+	pod := testsutil.CreatePodObj(podName, podNamespace)
+	pod.Spec.Containers[0].LivenessProbe.Handler = v1.Handler{
+		TCPSocket: &v1.TCPSocketAction{
+			Port: intstr.FromInt(8080),
+		},
+	}
+
+	provider, err := createTestProvider(aciMocks, nil)
+	if err != nil {
+		t.Fatal("failed to create the test provider", err)
+	}
+	provider.operatingSystem = "Windows"
+
+	err = provider.CreatePod(context.Background(), pod)
+	code, ok := errcodes.Of(err)
+	assert.Check(t, ok, "expected an error with a code, got %v", err)
+	assert.Check(t, is.Equal(errcodes.InvalidProbe, code))
+}
+
+func TestCreatePodWithTCPSocketProbeInvalidHost(t *testing.T) {
+	aciMocks := createNewACIMock()
+	aciMocks.MockCreateContainerGroup = func(ctx context.Context, resourceGroup, podNS, podName string, cg *client.ContainerGroupWrapper) error {
+		t.Error("the container group should not be created")
+		return nil
+	}
+
+	pod := testsutil.CreatePodObj("pod-"+uuid.New().String(), "ns-"+uuid.New().String())
+	pod.Spec.Containers[0].LivenessProbe.Handler = v1.Handler{
+		TCPSocket: &v1.TCPSocketAction{
+			Host: "db'; rm -rf /; '",
+			Port: intstr.FromInt(5432),
+		},
+	}
+
+	provider, err := createTestProvider(aciMocks, nil)
+	if err != nil {
+		t.Fatal("failed to create the test provider", err)
+	}
+
+	err = provider.CreatePod(context.Background(), pod)
+	code, ok := errcodes.Of(err)
+	assert.Check(t, ok, "expected an error with a code, got %v", err)
+	assert.Check(t, is.Equal(errcodes.InvalidProbe, code))
+}
+
+func TestGetTCPSocketProbeCommand(t *testing.T) {
+	assert.Check(t, is.DeepEqual([]string{"/bin/sh", "-c", `nc -z -w 3 'db.ns.svc' 5432 || timeout 3 bash -c '</dev/tcp/'\''db.ns.svc'\''/5432'`},
+		getTCPSocketProbeCommand("db.ns.svc", 5432, 3)))
+	assert.Check(t, is.DeepEqual([]string{"/bin/sh", "-c", `nc -z -w 1 '127.0.0.1' 80 || timeout 1 bash -c '</dev/tcp/'\''127.0.0.1'\''/80'`},
+		getTCPSocketProbeCommand("", 80, 0)))
+}
+
 func TestCreatedPodWithContainerPort(t *testing.T) {
 	port4040 := int32(4040)
 	port5050 := int32(5050)
@@ -845,7 +1111,7 @@ func TestCreatedPodWithContainerPort(t *testing.T) {
 				NewMockPodLister(mockCtrl),
 				NewMockSecretLister(mockCtrl),
 				NewMockConfigMapLister(mockCtrl),
-				NewMockServiceLister(mockCtrl),
+				newServiceLister(),
 				NewMockPersistentVolumeClaimLister(mockCtrl),
 				NewMockPersistentVolumeLister(mockCtrl))
 			if err != nil {
@@ -863,3 +1129,153 @@ func TestCreatedPodWithContainerPort(t *testing.T) {
 		})
 	}
 }
+
+func TestCreatePodWithUnsupportedPodPolicy(t *testing.T) {
+	aciMocks := createNewACIMock()
+	created := false
+	aciMocks.MockCreateContainerGroup = func(ctx context.Context, resourceGroup, podNS, podName string, cg *client.ContainerGroupWrapper) error {
+		created = true
+		return nil
+	}
+
+	provider, err := createTestProvider(aciMocks, nil)
+	if err != nil {
+		t.Fatal("failed to create the test provider", err)
+	}
+
+	pod := testsutil.CreatePodObj("pod-"+uuid.New().String(), "ns-"+uuid.New().String())
+	pod.OwnerReferences = []metav1.OwnerReference{{Kind: "DaemonSet", Name: "kube-proxy"}}
+
+	provider.unsupportedPodPolicy = unsupportedPodPolicyIgnore
+	assert.NilError(t, provider.CreatePod(context.Background(), pod))
+	assert.Check(t, !created, "container group should not be created for an ignored pod")
+
+	provider.unsupportedPodPolicy = unsupportedPodPolicyReject
+	err = provider.CreatePod(context.Background(), pod)
+	assert.Check(t, errdefs.IsInvalidInput(err), "pod should be rejected")
+	assert.Check(t, !created, "container group should not be created for a rejected pod")
+
+	provider.unsupportedPodPolicy = ""
+	assert.NilError(t, provider.CreatePod(context.Background(), pod))
+	assert.Check(t, created, "container group should be created without a policy")
+}
+
+func TestIgnoredUnsupportedPodIsNotTracked(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	pod := testsutil.CreatePodObj("kube-proxy-abcde", "kube-system")
+	pod.Status.Phase = v1.PodPending
+	pod.OwnerReferences = []metav1.OwnerReference{{Kind: "DaemonSet", Name: "kube-proxy"}}
+	podLister := NewMockPodLister(mockCtrl)
+	podLister.EXPECT().List(labels.Everything()).Return([]*v1.Pod{pod}, nil).AnyTimes()
+	rm, err := manager.NewResourceManager(podLister, nil, nil, newServiceLister(), nil, nil)
+	if err != nil {
+		t.Fatal("Unable to prepare the mocks for resourceManager", err)
+	}
+
+	var updated *v1.Pod
+	handler := &fakePodsTrackerHandler{}
+	provider := &ACIProvider{
+		unsupportedPodPolicy: unsupportedPodPolicyIgnore,
+		tracker:              &PodsTracker{rm: rm, handler: handler, updateCb: func(pod *v1.Pod) { updated = pod }},
+	}
+	handled, err := provider.handleUnsupportedPod(context.Background(), pod)
+	assert.NilError(t, err)
+	assert.Check(t, handled)
+	assert.Assert(t, updated != nil, "the status of the ignored pod should be updated")
+	assert.Check(t, is.Equal(v1.PodPending, updated.Status.Phase), "ignored pods should not be failed")
+	assert.Check(t, is.Equal(podStatusReasonUnsupported, updated.Status.Reason))
+
+	assert.Check(t, !provider.tracker.processPodUpdates(context.Background(), updated))
+	assert.Check(t, is.Equal(0, handler.fetches), "the status of ignored pods should not be fetched")
+}
+
+func TestIgnoredUnsupportedPodIsTrackedOncePolicyNoLongerMatches(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	pod := testsutil.CreatePodObj("web-abcde", "ops")
+	pod.Status.Phase = v1.PodPending
+	pod.Status.Reason = podStatusReasonUnsupported
+	pod.Status.Message = "pods of namespace ops are not run on ACI"
+	podLister := NewMockPodLister(mockCtrl)
+	podLister.EXPECT().List(labels.Everything()).Return([]*v1.Pod{pod}, nil).AnyTimes()
+	rm, err := manager.NewResourceManager(podLister, nil, nil, newServiceLister(), nil, nil)
+	if err != nil {
+		t.Fatal("Unable to prepare the mocks for resourceManager", err)
+	}
+
+	var updated *v1.Pod
+	provider := &ACIProvider{
+		unsupportedPodPolicy: unsupportedPodPolicyIgnore,
+		tracker:              &PodsTracker{rm: rm, handler: &fakePodsTrackerHandler{}, updateCb: func(pod *v1.Pod) { updated = pod }},
+	}
+	handled, err := provider.handleUnsupportedPod(context.Background(), pod)
+	assert.NilError(t, err)
+	assert.Check(t, !handled, "pods of namespaces no longer excluded should be created")
+	assert.Assert(t, updated != nil, "the reason of the pod should be cleared")
+	assert.Check(t, is.Equal(v1.PodPending, updated.Status.Phase))
+	assert.Check(t, is.Equal("", updated.Status.Reason))
+	assert.Check(t, is.Equal("", updated.Status.Message))
+	assert.Check(t, !provider.tracker.shouldSkipPodStatusUpdate(updated), "the status of the pod should be updated again")
+
+	running := pod.DeepCopy()
+	running.Status = v1.PodStatus{Phase: v1.PodRunning}
+	updated = nil
+	handled, err = provider.handleUnsupportedPod(context.Background(), running)
+	assert.NilError(t, err)
+	assert.Check(t, !handled)
+	assert.Check(t, updated == nil, "pods that were not ignored should not be updated")
+}
+
+func TestCreatePodWithSubPathVolumeMount(t *testing.T) {
+	aciMocks := createNewACIMock()
+	provider, err := createTestProvider(aciMocks, nil)
+	if err != nil {
+		t.Fatal("failed to create the test provider", err)
+	}
+
+	pod := testsutil.CreatePodObj("pod-"+uuid.New().String(), "ns-"+uuid.New().String())
+	pod.Spec.Volumes = []v1.Volume{
+		{
+			Name:         "data",
+			VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}},
+		},
+	}
+	pod.Spec.Containers[0].VolumeMounts = []v1.VolumeMount{
+		{Name: "data", MountPath: "/etc/app/config.yaml", SubPath: "config.yaml"},
+	}
+
+	err = provider.CreatePod(context.Background(), pod)
+	assert.Check(t, errdefs.IsInvalidInput(err), "subPath should be rejected")
+	assert.Check(t, strings.Contains(err.Error(), "subPath"), "error should mention subPath")
+}
+
+func TestDeletePodWithUIDPrecondition(t *testing.T) {
+	currentUID := "current-uid"
+	deleted := 0
+	aciMocks := createNewACIMock()
+	aciMocks.MockGetContainerGroupInfo = func(ctx context.Context, resourceGroup, namespace, name, nodeName string) (*azaci.ContainerGroup, error) {
+		return &azaci.ContainerGroup{Tags: map[string]*string{"UID": &currentUID}}, nil
+	}
+	aciMocks.MockDeleteContainerGroup = func(ctx context.Context, resourceGroup, cgName string) error {
+		deleted++
+		return nil
+	}
+
+	provider, err := createTestProvider(aciMocks, nil)
+	if err != nil {
+		t.Fatal("failed to create the test provider", err)
+	}
+
+	pod := testsutil.CreatePodObj(podName, podNamespace)
+	pod.UID = "old-uid"
+	err = provider.DeletePod(context.Background(), pod)
+	assert.Check(t, errdefs.IsNotFound(err), "container group of another pod incarnation should not be deleted")
+	assert.Check(t, is.Equal(0, deleted))
+
+	pod.UID = types.UID(currentUID)
+	assert.NilError(t, provider.DeletePod(context.Background(), pod))
+	assert.Check(t, is.Equal(1, deleted))
+}
@@ -8,13 +8,25 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"strings"
+	"sync"
+	"time"
 
 	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	client2 "github.com/virtual-kubelet/azure-aci/pkg/client"
+	"github.com/virtual-kubelet/azure-aci/pkg/metrics"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
 	"github.com/virtual-kubelet/virtual-kubelet/log"
+	authv1 "k8s.io/api/authentication/v1"
 	v1 "k8s.io/api/core/v1"
 	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 )
 
+// volumeResolutionParallelism bounds the volumes of a pod resolved concurrently.
+const volumeResolutionParallelism = 8
+
 func (p *ACIProvider) getAzureFileCSI(volume v1.Volume, namespace string) (*azaci.Volume, error) {
 	var secretName, shareName string
 	if volume.CSI.VolumeAttributes != nil && len(volume.CSI.VolumeAttributes) != 0 {
@@ -46,188 +58,489 @@ func (p *ACIProvider) getAzureFileCSI(volume v1.Volume, namespace string) (*azac
 
 	storageAccountNameStr := string(secret.Data[azureFileStorageAccountName])
 	storageAccountKeyStr := string(secret.Data[azureFileStorageAccountKey])
+	readOnly := volume.CSI.ReadOnly != nil && *volume.CSI.ReadOnly
 
 	return &azaci.Volume{
 		Name: &volume.Name,
 		AzureFile: &azaci.AzureFileVolume{
 			ShareName:          &shareName,
+			ReadOnly:           &readOnly,
 			StorageAccountName: &storageAccountNameStr,
 			StorageAccountKey:  &storageAccountKeyStr,
 		}}, nil
 }
 
-func (p *ACIProvider) getVolumes(ctx context.Context, pod *v1.Pod) ([]azaci.Volume, error) {
-	volumes := make([]azaci.Volume, 0, len(pod.Spec.Volumes))
-	podVolumes := pod.Spec.Volumes
-	for i := range podVolumes {
-		// Handle the case for Azure File CSI driver
-		if podVolumes[i].CSI != nil {
-			// Check if the CSI driver is file (Disk is not supported by ACI)
-			if podVolumes[i].CSI.Driver == AzureFileDriverName {
-				csiVolume, err := p.getAzureFileCSI(podVolumes[i], pod.Namespace)
-				if err != nil {
-					return nil, err
-				}
-				volumes = append(volumes, *csiVolume)
-				continue
-			} else {
-				return nil, fmt.Errorf("pod %s requires volume %s which is of an unsupported type %s", pod.Name, podVolumes[i].Name, podVolumes[i].CSI.Driver)
-			}
-		}
+// translateInTreeAzureFile converts a legacy in-tree azureFile volume to the equivalent
+// inline volume of the Azure File CSI driver, as the Kubernetes CSI migration does.
+func translateInTreeAzureFile(volume v1.Volume) v1.Volume {
+	readOnly := volume.AzureFile.ReadOnly
+	return v1.Volume{
+		Name: volume.Name,
+		VolumeSource: v1.VolumeSource{
+			CSI: &v1.CSIVolumeSource{
+				Driver:   AzureFileDriverName,
+				ReadOnly: &readOnly,
+				VolumeAttributes: map[string]string{
+					azureFileShareName:  volume.AzureFile.ShareName,
+					azureFileSecretName: volume.AzureFile.SecretName,
+				},
+			},
+		},
+	}
+}
 
-		// Handle the case for the AzureFile volume.
-		if podVolumes[i].AzureFile != nil {
-			secret, err := p.resourceManager.GetSecret(podVolumes[i].AzureFile.SecretName, pod.Namespace)
-			if err != nil {
-				return volumes, err
-			}
+// translateInTreeAzureFilePersistentVolume converts a legacy in-tree azureFile persistent volume
+// to the Azure File CSI driver. The secret namespace defaults to the pod namespace.
+func translateInTreeAzureFilePersistentVolume(pv *v1.PersistentVolume) *v1.PersistentVolume {
+	translated := pv.DeepCopy()
+	secretRef := &v1.SecretReference{Name: pv.Spec.AzureFile.SecretName}
+	if pv.Spec.AzureFile.SecretNamespace != nil {
+		secretRef.Namespace = *pv.Spec.AzureFile.SecretNamespace
+	}
 
-			if secret == nil {
-				return nil, fmt.Errorf("getting secret for AzureFile volume returned an empty secret")
-			}
-			storageAccountNameStr := string(secret.Data[azureFileStorageAccountName])
-			storageAccountKeyStr := string(secret.Data[azureFileStorageAccountKey])
+	translated.Spec.AzureFile = nil
+	translated.Spec.CSI = &v1.CSIPersistentVolumeSource{
+		Driver:             AzureFileDriverName,
+		ReadOnly:           pv.Spec.AzureFile.ReadOnly,
+		VolumeAttributes:   map[string]string{azureFileShareName: pv.Spec.AzureFile.ShareName},
+		NodeStageSecretRef: secretRef,
+	}
+	return translated
+}
 
-			volumes = append(volumes, azaci.Volume{
-				Name: &podVolumes[i].Name,
-				AzureFile: &azaci.AzureFileVolume{
-					ShareName:          &podVolumes[i].AzureFile.ShareName,
-					ReadOnly:           &podVolumes[i].AzureFile.ReadOnly,
-					StorageAccountName: &storageAccountNameStr,
-					StorageAccountKey:  &storageAccountKeyStr,
-				},
-			})
-			continue
+// getPersistentVolumeClaimVolume resolves a PVC to its bound persistent volume and maps it when
+// it is backed by a driver ACI can mount.
+func (p *ACIProvider) getPersistentVolumeClaimVolume(ctx context.Context, pod *v1.Pod, volume v1.Volume) (*azaci.Volume, error) {
+	pv, err := p.getBoundPersistentVolume(pod, volume.PersistentVolumeClaim.ClaimName)
+	if err != nil {
+		return nil, err
+	}
+	if pv.Spec.CSI == nil {
+		return nil, fmt.Errorf("pod %s requires persistent volume %s which is of an unsupported type", pod.Name, pv.Name)
+	}
+
+	switch pv.Spec.CSI.Driver {
+	case AzureFileDriverName:
+		return p.getAzureFilePersistentVolume(pod, volume, pv)
+	case AzureBlobDriverName:
+		if _, err := p.getBlobPersistentVolumeSource(pod, volume.Name, pv); err != nil {
+			return nil, err
 		}
+		return p.getBlobVolume(pod, volume.Name)
+	default:
+		return nil, fmt.Errorf("pod %s requires persistent volume %s which is of an unsupported type %s", pod.Name, pv.Name, pv.Spec.CSI.Driver)
+	}
+}
 
-		// Handle the case for the EmptyDir.
-		if podVolumes[i].EmptyDir != nil {
-			log.G(ctx).Info("empty volume name ", podVolumes[i].Name)
-			volumes = append(volumes, azaci.Volume{
-				Name:     &podVolumes[i].Name,
-				EmptyDir: map[string]interface{}{},
-			})
-			continue
+// getBoundPersistentVolume returns the persistent volume a PVC of the pod is bound to, with in-tree
+// Azure Files volumes translated to the CSI driver.
+func (p *ACIProvider) getBoundPersistentVolume(pod *v1.Pod, claimName string) (*v1.PersistentVolume, error) {
+	pvc, err := p.resourceManager.GetPersistentVolumeClaim(claimName, pod.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get persistent volume claim %s for pod %s: %v", claimName, pod.Name, err)
+	}
+	if pvc.Status.Phase != v1.ClaimBound || pvc.Spec.VolumeName == "" {
+		return nil, fmt.Errorf("persistent volume claim %s for pod %s is not bound", pvc.Name, pod.Name)
+	}
+
+	pv, err := p.resourceManager.GetPersistentVolume(pvc.Spec.VolumeName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get persistent volume %s for pod %s: %v", pvc.Spec.VolumeName, pod.Name, err)
+	}
+	if pv.Spec.AzureFile != nil {
+		pv = translateInTreeAzureFilePersistentVolume(pv)
+	}
+	return pv, nil
+}
+
+// getAzureFilePersistentVolume maps a persistent volume of the Azure File CSI driver. Dynamically
+// provisioned volumes carry the share in their handle, formatted as rg#account#share#...
+func (p *ACIProvider) getAzureFilePersistentVolume(pod *v1.Pod, volume v1.Volume, pv *v1.PersistentVolume) (*azaci.Volume, error) {
+	attributes := pv.Spec.CSI.VolumeAttributes
+	shareName := attributes[azureFileShareName]
+	if shareName == "" {
+		if parts := strings.Split(pv.Spec.CSI.VolumeHandle, "#"); len(parts) > 2 {
+			shareName = parts[2]
 		}
+	}
+	if shareName == "" {
+		return nil, fmt.Errorf("share name for persistent volume %s cannot be empty", pv.Name)
+	}
+
+	secretName, secretNamespace := attributes[azureFileSecretName], attributes["secretNamespace"]
+	if ref := pv.Spec.CSI.NodeStageSecretRef; ref != nil {
+		secretName, secretNamespace = ref.Name, ref.Namespace
+	}
+	if secretNamespace == "" {
+		secretNamespace = pod.Namespace
+	}
+	if secretName == "" {
+		return nil, fmt.Errorf("secret name for persistent volume %s cannot be empty", pv.Name)
+	}
+
+	secret, err := p.resourceManager.GetSecret(secretName, secretNamespace)
+	if err != nil || secret == nil {
+		return nil, fmt.Errorf("the secret %s/%s for persistent volume %s is not found", secretNamespace, secretName, pv.Name)
+	}
+
+	storageAccountNameStr := string(secret.Data[azureFileStorageAccountName])
+	storageAccountKeyStr := string(secret.Data[azureFileStorageAccountKey])
+	readOnly := volume.PersistentVolumeClaim.ReadOnly || pv.Spec.CSI.ReadOnly
 
-		// Handle the case for GitRepo volume.
-		if podVolumes[i].GitRepo != nil {
+	return &azaci.Volume{
+		Name: &volume.Name,
+		AzureFile: &azaci.AzureFileVolume{
+			ShareName:          &shareName,
+			ReadOnly:           &readOnly,
+			StorageAccountName: &storageAccountNameStr,
+			StorageAccountKey:  &storageAccountKeyStr,
+		}}, nil
+}
+
+// getEmptyDirVolume maps an emptyDir to an ACI EmptyDir volume. ACI only offers disk backed
+// scratch space without size enforcement, so memory medium and size limits are reported on the pod.
+func (p *ACIProvider) getEmptyDirVolume(ctx context.Context, pod *v1.Pod, volume v1.Volume) azaci.Volume {
+	log.G(ctx).Infof("empty volume name %s", volume.Name)
+
+	if volume.EmptyDir.Medium == v1.StorageMediumMemory {
+		p.recordEvent(pod, v1.EventTypeWarning, "EmptyDirMediumUnsupported",
+			"emptyDir volume %s requests medium Memory, ACI provides a disk backed emptyDir instead", volume.Name)
+	}
+	if volume.EmptyDir.SizeLimit != nil && !volume.EmptyDir.SizeLimit.IsZero() {
+		p.recordEvent(pod, v1.EventTypeNormal, "EmptyDirSizeLimitIgnored",
+			"emptyDir volume %s sizeLimit %s is not enforced by ACI", volume.Name, volume.EmptyDir.SizeLimit.String())
+	}
+
+	return azaci.Volume{
+		Name:     &volume.Name,
+		EmptyDir: map[string]interface{}{},
+	}
+}
+
+// resolveVolumeMounts makes the volume mounts of the init and main containers reference a volume of
+// the container group. ACI shares a volume between all the containers mounting it by name, so files
+// written by init containers to an emptyDir are visible to the main containers. Pod volumes that
+// rendered no ACI volume, e.g. an optional secret that does not exist, are mounted as an empty
+// directory as the kubelet does, instead of failing the container group.
+func resolveVolumeMounts(pod *v1.Pod, cgw *client2.ContainerGroupWrapper) {
+	cgProperties := cgw.ContainerGroupPropertiesWrapper.ContainerGroupProperties
+	volumes := []azaci.Volume{}
+	if cgProperties.Volumes != nil {
+		volumes = *cgProperties.Volumes
+	}
+
+	cgVolumes := make(map[string]bool, len(volumes))
+	for _, volume := range volumes {
+		cgVolumes[*volume.Name] = true
+	}
+	podVolumes := make(map[string]bool, len(pod.Spec.Volumes))
+	for _, volume := range pod.Spec.Volumes {
+		podVolumes[volume.Name] = true
+	}
+
+	resolve := func(mounts *[]azaci.VolumeMount) {
+		if mounts == nil {
+			return
+		}
+		for _, mount := range *mounts {
+			if cgVolumes[*mount.Name] || !podVolumes[*mount.Name] {
+				continue
+			}
+			name := *mount.Name
 			volumes = append(volumes, azaci.Volume{
-				Name: &podVolumes[i].Name,
-				GitRepo: &azaci.GitRepoVolume{
-					Directory:  &podVolumes[i].GitRepo.Directory,
-					Repository: &podVolumes[i].GitRepo.Repository,
-					Revision:   &podVolumes[i].GitRepo.Revision,
-				},
+				Name:     &name,
+				EmptyDir: map[string]interface{}{},
 			})
-			continue
+			cgVolumes[name] = true
 		}
+	}
 
-		// Handle the case for Secret volume.
-		if podVolumes[i].Secret != nil {
-			paths := make(map[string]*string)
-			secret, err := p.resourceManager.GetSecret(podVolumes[i].Secret.SecretName, pod.Namespace)
-			if podVolumes[i].Secret.Optional != nil && !*podVolumes[i].Secret.Optional && k8serr.IsNotFound(err) {
-				return nil, fmt.Errorf("Secret %s is required by Pod %s and does not exist", podVolumes[i].Secret.SecretName, pod.Name)
+	if cgProperties.InitContainers != nil {
+		for _, container := range *cgProperties.InitContainers {
+			if container.InitContainerPropertiesDefinition != nil {
+				resolve(container.VolumeMounts)
 			}
-			if secret == nil {
-				continue
+		}
+	}
+	if cgProperties.Containers != nil {
+		for _, container := range *cgProperties.Containers {
+			if container.ContainerProperties != nil {
+				resolve(container.VolumeMounts)
 			}
+		}
+	}
 
-			for k, v := range secret.Data {
-				strV := base64.StdEncoding.EncodeToString(v)
-				paths[k] = &strV
-			}
+	cgProperties.Volumes = &volumes
+}
+
+// automountServiceAccountToken reports whether the pod wants the default service account token.
+func automountServiceAccountToken(pod *v1.Pod) bool {
+	return pod.Spec.AutomountServiceAccountToken == nil || *pod.Spec.AutomountServiceAccountToken
+}
 
-			if len(paths) != 0 {
-				volumes = append(volumes, azaci.Volume{
-					Name:   &podVolumes[i].Name,
-					Secret: paths,
-				})
+// serviceAccountSecretVolumes returns the names of the volumes mounted at the default service account path.
+func serviceAccountSecretVolumes(pod *v1.Pod) map[string]bool {
+	names := make(map[string]bool)
+	for _, containers := range [][]v1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for _, container := range containers {
+			for _, mount := range container.VolumeMounts {
+				if strings.EqualFold(serviceAccountSecretMountPath, mount.MountPath) {
+					names[mount.Name] = true
+				}
 			}
-			continue
 		}
+	}
+	return names
+}
 
-		// Handle the case for ConfigMap volume.
-		if podVolumes[i].ConfigMap != nil {
-			paths := make(map[string]*string)
-			configMap, err := p.resourceManager.GetConfigMap(podVolumes[i].ConfigMap.Name, pod.Namespace)
-			if podVolumes[i].ConfigMap.Optional != nil && !*podVolumes[i].ConfigMap.Optional && k8serr.IsNotFound(err) {
-				return nil, fmt.Errorf("ConfigMap %s is required by Pod %s and does not exist", podVolumes[i].ConfigMap.Name, pod.Name)
-			}
-			if configMap == nil {
+// requestServiceAccountToken issues a token for the pod service account through the TokenRequest API,
+// bound to the pod and scoped to the requested audience. ACI cannot rotate the mounted file, so the
+// token is valid for the requested lifetime only and an event records when it expires.
+func (p *ACIProvider) requestServiceAccountToken(ctx context.Context, pod *v1.Pod, source *v1.ServiceAccountTokenProjection) (string, error) {
+	serviceAccountName := pod.Spec.ServiceAccountName
+	if serviceAccountName == "" {
+		serviceAccountName = "default"
+	}
+
+	tokenRequest := &authv1.TokenRequest{
+		Spec: authv1.TokenRequestSpec{
+			ExpirationSeconds: source.ExpirationSeconds,
+			BoundObjectRef: &authv1.BoundObjectReference{
+				APIVersion: "v1",
+				Kind:       "Pod",
+				Name:       pod.Name,
+				UID:        pod.UID,
+			},
+		},
+	}
+	if source.Audience != "" {
+		tokenRequest.Spec.Audiences = []string{source.Audience}
+	}
+
+	tr, err := p.kubeClient.CoreV1().ServiceAccounts(pod.Namespace).CreateToken(ctx, serviceAccountName, tokenRequest, metav1.CreateOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to request a token for service account %s of pod %s: %v", serviceAccountName, pod.Name, err)
+	}
+
+	p.recordEvent(pod, v1.EventTypeNormal, "ServiceAccountTokenNotRotated",
+		"service account token at %s is not rotated on ACI and expires at %s", source.Path, tr.Status.ExpirationTimestamp.String())
+	return tr.Status.Token, nil
+}
+
+// secretData returns the secret content by key. StringData is only set on objects that
+// were not round-tripped through the API server, it holds plain text and wins over Data.
+func secretData(secret *v1.Secret) map[string][]byte {
+	data := make(map[string][]byte, len(secret.Data)+len(secret.StringData))
+	for k, v := range secret.Data {
+		data[k] = v
+	}
+	for k, v := range secret.StringData {
+		data[k] = []byte(v)
+	}
+	return data
+}
+
+func configMapData(configMap *v1.ConfigMap) map[string][]byte {
+	data := make(map[string][]byte, len(configMap.Data)+len(configMap.BinaryData))
+	for k, v := range configMap.Data {
+		data[k] = []byte(v)
+	}
+	for k, v := range configMap.BinaryData {
+		data[k] = v
+	}
+	return data
+}
+
+// projectKeysToPaths builds the base64 encoded file contents of an ACI secret volume. Without items
+// every key is mounted under its own name, otherwise only the listed keys are mounted at their paths.
+// A listed key that is missing is an error unless the source is optional.
+func projectKeysToPaths(data map[string][]byte, items []v1.KeyToPath, optional bool) (map[string]*string, error) {
+	paths := make(map[string]*string)
+	if len(items) == 0 {
+		for k, v := range data {
+			strV := base64.StdEncoding.EncodeToString(v)
+			paths[k] = &strV
+		}
+		return paths, nil
+	}
+
+	for _, item := range items {
+		v, ok := data[item.Key]
+		if !ok {
+			if optional {
 				continue
 			}
+			return nil, fmt.Errorf("key %s does not exist", item.Key)
+		}
+		// ACI secret volumes are flat, every entry is a file at the root of the mount.
+		if strings.Contains(item.Path, "/") {
+			return nil, fmt.Errorf("path %s for key %s is nested, ACI only supports files at the root of the volume", item.Path, item.Key)
+		}
+		strV := base64.StdEncoding.EncodeToString(v)
+		paths[item.Path] = &strV
+	}
+	return paths, nil
+}
 
-			for k, v := range configMap.Data {
-				strV := base64.StdEncoding.EncodeToString([]byte(v))
-				paths[k] = &strV
-			}
-			for k, v := range configMap.BinaryData {
-				strV := base64.StdEncoding.EncodeToString(v)
-				paths[k] = &strV
-			}
+// reportIgnoredFileModes emits an event when a volume asks for file modes, ACI mounts secret
+// volume files with fixed permissions.
+func (p *ACIProvider) reportIgnoredFileModes(pod *v1.Pod, volumeName string, defaultMode *int32, items []v1.KeyToPath) {
+	hasMode := defaultMode != nil
+	for _, item := range items {
+		hasMode = hasMode || item.Mode != nil
+	}
+	if hasMode {
+		p.recordEvent(pod, v1.EventTypeNormal, "VolumeFileModeIgnored",
+			"file modes of volume %s are not supported by ACI and are ignored", volumeName)
+	}
+}
+
+// getVolumes resolves the volumes of the pod concurrently, as each one may read secrets, config
+// maps, claims or request tokens from the API server. All the volumes that cannot be resolved are
+// reported at once instead of one per attempt.
+func (p *ACIProvider) getVolumes(ctx context.Context, pod *v1.Pod) ([]azaci.Volume, error) {
+	podVolumes := pod.Spec.Volumes
+	resolved := make([]*azaci.Volume, len(podVolumes))
+	errs := make([]error, len(podVolumes))
+	sem := make(chan struct{}, volumeResolutionParallelism)
+	var wg sync.WaitGroup
+	for i := range podVolumes {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			start := time.Now()
+			resolved[i], errs[i] = p.getVolume(ctx, pod, &podVolumes[i])
+			metrics.RecordVolumeResolution(p.volumeType(&podVolumes[i]), errs[i], time.Since(start))
+		}(i)
+	}
+	wg.Wait()
+
+	var failed []error
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+	switch len(failed) {
+	case 0:
+	case 1:
+		// A single failure keeps its type, e.g. an invalid input.
+		return nil, failed[0]
+	default:
+		return nil, fmt.Errorf("%d volumes of pod %s cannot be resolved: %v", len(failed), pod.Name, utilerrors.NewAggregate(failed))
+	}
 
-			if len(paths) != 0 {
-				volumes = append(volumes, azaci.Volume{
-					Name:   &podVolumes[i].Name,
-					Secret: paths,
-				})
+	volumes := make([]azaci.Volume, 0, len(podVolumes))
+	for _, volume := range resolved {
+		if volume != nil {
+			volumes = append(volumes, *volume)
+		}
+	}
+	return volumes, nil
+}
+
+// getVolume translates a pod volume, it returns nil when the volume renders nothing to mount.
+func (p *ACIProvider) getVolume(ctx context.Context, pod *v1.Pod, volume *v1.Volume) (*azaci.Volume, error) {
+	// Handle the volume types with a registered or built-in handler.
+	if handler := p.getVolumeHandler(volume); handler != nil {
+		return handler.GetVolume(ctx, p.resourceManager, pod, volume)
+	}
+
+	// Azure File CSI volumes have a handler, Azure Blob CSI volumes are downloaded by an init
+	// container, other drivers are not supported by ACI.
+	if volume.CSI != nil {
+		if isBlobVolume(volume) {
+			if _, err := p.getInlineBlobVolumeSource(pod, volume); err != nil {
+				return nil, err
 			}
-			continue
+			return p.getBlobVolume(pod, volume.Name)
 		}
+		return nil, fmt.Errorf("pod %s requires volume %s which is of an unsupported type %s", pod.Name, volume.Name, volume.CSI.Driver)
+	}
 
-		if podVolumes[i].Projected != nil {
-			log.G(ctx).Info("Found projected volume")
-			paths := make(map[string]*string)
+	// Handle the case for PersistentVolumeClaim volume.
+	if volume.PersistentVolumeClaim != nil {
+		return p.getPersistentVolumeClaimVolume(ctx, pod, *volume)
+	}
 
-			for _, source := range podVolumes[i].Projected.Sources {
-				switch {
-				case source.ServiceAccountToken != nil:
-					// This is still stored in a secret, hence the dance to figure out what secret.
-					secrets, err := p.resourceManager.GetSecrets(pod.Namespace)
-					if err != nil {
-						return nil, err
-					}
-				Secrets:
-					for _, secret := range secrets {
-						if secret.Type != v1.SecretTypeServiceAccountToken {
-							continue
-						}
-						// annotation now needs to match the pod.ServiceAccountName
-						for k, a := range secret.ObjectMeta.Annotations {
-							if k == "kubernetes.io/service-account.name" && a == pod.Spec.ServiceAccountName {
-								for k, v := range secret.StringData {
-									data, err := base64.StdEncoding.DecodeString(v)
-									if err != nil {
-										return nil, err
-									}
-									dataStr := string(data)
-									paths[k] = &dataStr
-								}
+	// Handle the case for the EmptyDir.
+	if volume.EmptyDir != nil {
+		emptyDir := p.getEmptyDirVolume(ctx, pod, *volume)
+		return &emptyDir, nil
+	}
 
-								for k, v := range secret.Data {
-									strV := base64.StdEncoding.EncodeToString(v)
-									paths[k] = &strV
-								}
+	// Handle the case for GitRepo volume.
+	if volume.GitRepo != nil {
+		return &azaci.Volume{
+			Name: &volume.Name,
+			GitRepo: &azaci.GitRepoVolume{
+				Directory:  &volume.GitRepo.Directory,
+				Repository: &volume.GitRepo.Repository,
+				Revision:   &volume.GitRepo.Revision,
+			},
+		}, nil
+	}
 
-								break Secrets
-							}
-						}
-					}
+	// Handle the case for DownwardAPI volume.
+	if volume.DownwardAPI != nil {
+		paths, err := getDownwardAPIPaths(pod, volume.DownwardAPI.Items)
+		if err != nil {
+			return nil, fmt.Errorf("downwardAPI volume %s of pod %s: %v", volume.Name, pod.Name, err)
+		}
+		p.reportIgnoredFileModes(pod, volume.Name, volume.DownwardAPI.DefaultMode, nil)
+
+		if len(paths) == 0 {
+			return nil, nil
+		}
+		return &azaci.Volume{
+			Name:   &volume.Name,
+			Secret: paths,
+		}, nil
+	}
 
-				case source.Secret != nil:
-					secret, err := p.resourceManager.GetSecret(source.Secret.Name, pod.Namespace)
-					if source.Secret.Optional != nil && !*source.Secret.Optional && k8serr.IsNotFound(err) {
-						return nil, fmt.Errorf("projected secret %s is required by pod %s and does not exist", source.Secret.Name, pod.Name)
+	if volume.Projected != nil {
+		log.G(ctx).Info("Found projected volume")
+		paths := make(map[string]*string)
+		var modeItems []v1.KeyToPath
+
+		for _, source := range volume.Projected.Sources {
+			switch {
+			case source.ServiceAccountToken != nil:
+				if !automountServiceAccountToken(pod) && serviceAccountSecretVolumes(pod)[volume.Name] {
+					continue
+				}
+
+				if p.kubeClient != nil {
+					token, err := p.requestServiceAccountToken(ctx, pod, source.ServiceAccountToken)
+					if err != nil {
+						return nil, err
 					}
-					if secret == nil {
+					strV := base64.StdEncoding.EncodeToString([]byte(token))
+					paths[source.ServiceAccountToken.Path] = &strV
+					continue
+				}
+				if source.ServiceAccountToken.Audience != "" {
+					return nil, fmt.Errorf("pod %s requests a service account token for audience %s, which requires access to the TokenRequest API", pod.Name, source.ServiceAccountToken.Audience)
+				}
+
+				// This is still stored in a secret, hence the dance to figure out what secret.
+				secrets, err := p.resourceManager.GetSecrets(pod.Namespace)
+				if err != nil {
+					return nil, err
+				}
+			Secrets:
+				for _, secret := range secrets {
+					if secret.Type != v1.SecretTypeServiceAccountToken {
 						continue
 					}
-
-					for _, keyToPath := range source.Secret.Items {
-						for k, v := range secret.StringData {
-							if keyToPath.Key == k {
+					// annotation now needs to match the pod.ServiceAccountName
+					for k, a := range secret.ObjectMeta.Annotations {
+						if k == "kubernetes.io/service-account.name" && a == pod.Spec.ServiceAccountName {
+							for k, v := range secret.StringData {
 								data, err := base64.StdEncoding.DecodeString(v)
 								if err != nil {
 									return nil, err
@@ -235,53 +548,99 @@ func (p *ACIProvider) getVolumes(ctx context.Context, pod *v1.Pod) ([]azaci.Volu
 								dataStr := string(data)
 								paths[k] = &dataStr
 							}
-						}
 
-						for k, v := range secret.Data {
-							if keyToPath.Key == k {
+							for k, v := range secret.Data {
 								strV := base64.StdEncoding.EncodeToString(v)
 								paths[k] = &strV
 							}
+
+							break Secrets
 						}
 					}
+				}
 
-				case source.ConfigMap != nil:
-					configMap, err := p.resourceManager.GetConfigMap(source.ConfigMap.Name, pod.Namespace)
-					if source.ConfigMap.Optional != nil && !*source.ConfigMap.Optional && k8serr.IsNotFound(err) {
-						return nil, fmt.Errorf("projected configMap %s is required by pod %s and does not exist", source.ConfigMap.Name, pod.Name)
-					}
-					if configMap == nil {
-						continue
-					}
+			case source.Secret != nil:
+				secret, err := p.resourceManager.GetSecret(source.Secret.Name, pod.Namespace)
+				if source.Secret.Optional != nil && !*source.Secret.Optional && k8serr.IsNotFound(err) {
+					return nil, fmt.Errorf("projected secret %s is required by pod %s and does not exist", source.Secret.Name, pod.Name)
+				}
+				if secret == nil {
+					continue
+				}
 
-					for _, keyToPath := range source.ConfigMap.Items {
-						for k, v := range configMap.Data {
-							if keyToPath.Key == k {
-								strV := base64.StdEncoding.EncodeToString([]byte(v))
-								paths[k] = &strV
-							}
-						}
-						for k, v := range configMap.BinaryData {
-							if keyToPath.Key == k {
-								strV := base64.StdEncoding.EncodeToString(v)
-								paths[k] = &strV
-							}
-						}
-					}
+				optional := source.Secret.Optional != nil && *source.Secret.Optional
+				projected, err := projectKeysToPaths(secretData(secret), source.Secret.Items, optional)
+				if err != nil {
+					return nil, fmt.Errorf("projected secret %s for volume %s of pod %s: %v", source.Secret.Name, volume.Name, pod.Name, err)
+				}
+				for k, v := range projected {
+					paths[k] = v
+				}
+				modeItems = append(modeItems, source.Secret.Items...)
+
+			case source.ConfigMap != nil:
+				configMap, err := p.resourceManager.GetConfigMap(source.ConfigMap.Name, pod.Namespace)
+				if source.ConfigMap.Optional != nil && !*source.ConfigMap.Optional && k8serr.IsNotFound(err) {
+					return nil, fmt.Errorf("projected configMap %s is required by pod %s and does not exist", source.ConfigMap.Name, pod.Name)
+				}
+				if configMap == nil {
+					continue
+				}
+
+				optional := source.ConfigMap.Optional != nil && *source.ConfigMap.Optional
+				projected, err := projectKeysToPaths(configMapData(configMap), source.ConfigMap.Items, optional)
+				if err != nil {
+					return nil, fmt.Errorf("projected configMap %s for volume %s of pod %s: %v", source.ConfigMap.Name, volume.Name, pod.Name, err)
+				}
+				for k, v := range projected {
+					paths[k] = v
+				}
+				modeItems = append(modeItems, source.ConfigMap.Items...)
+
+			case source.DownwardAPI != nil:
+				projected, err := getDownwardAPIPaths(pod, source.DownwardAPI.Items)
+				if err != nil {
+					return nil, fmt.Errorf("projected downwardAPI for volume %s of pod %s: %v", volume.Name, pod.Name, err)
+				}
+				for k, v := range projected {
+					paths[k] = v
 				}
 			}
-			if len(paths) != 0 {
-				volumes = append(volumes, azaci.Volume{
-					Name:   &podVolumes[i].Name,
-					Secret: paths,
-				})
-			}
-			continue
 		}
+		p.reportIgnoredFileModes(pod, volume.Name, volume.Projected.DefaultMode, modeItems)
 
-		// If we've made it this far we have found a volume type that isn't supported
-		return nil, fmt.Errorf("pod %s requires volume %s which is of an unsupported type", pod.Name, podVolumes[i].Name)
+		if len(paths) == 0 {
+			return nil, nil
+		}
+		return &azaci.Volume{
+			Name:   &volume.Name,
+			Secret: paths,
+		}, nil
 	}
 
-	return volumes, nil
+	// If we've made it this far we have found a volume type that isn't supported
+	return nil, fmt.Errorf("pod %s requires volume %s which is of an unsupported type", pod.Name, volume.Name)
+}
+
+// volumeType names the type of a volume in the volume resolution metrics.
+func (p *ACIProvider) volumeType(volume *v1.Volume) string {
+	if handler := p.getVolumeHandler(volume); handler != nil {
+		return handler.Name()
+	}
+	switch {
+	case volume.CSI != nil:
+		return "csi"
+	case volume.PersistentVolumeClaim != nil:
+		return "persistentVolumeClaim"
+	case volume.EmptyDir != nil:
+		return "emptyDir"
+	case volume.GitRepo != nil:
+		return "gitRepo"
+	case volume.DownwardAPI != nil:
+		return "downwardAPI"
+	case volume.Projected != nil:
+		return "projected"
+	default:
+		return "unsupported"
+	}
 }
@@ -14,12 +14,21 @@ import (
 	"github.com/golang/mock/gomock"
 	"github.com/google/uuid"
 	"github.com/virtual-kubelet/azure-aci/pkg/client"
+	"github.com/virtual-kubelet/azure-aci/pkg/errcodes"
 	testsutil "github.com/virtual-kubelet/azure-aci/pkg/tests"
 	"github.com/virtual-kubelet/node-cli/manager"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
 	"gotest.tools/assert"
 	is "gotest.tools/assert/cmp"
+	authv1 "k8s.io/api/authentication/v1"
 	v1 "k8s.io/api/core/v1"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 )
 
 var (
@@ -121,14 +130,18 @@ func TestCreatedPodWithAzureFilesVolume(t *testing.T) {
 			volumes:      fakeVolumes,
 			callSecretMocks: func(secretMock *MockSecretLister) {
 				for _, volume := range fakeVolumes {
-					if volume.Name == azureFileVolumeName1 {
+					if volume.Name == azureFileVolumeName1 || volume.Name == azureFileVolumeName2 {
 						mockSecretNamespaceLister := NewMockSecretNamespaceLister(mockCtrl)
 						secretMock.EXPECT().Secrets(podNamespace).Return(mockSecretNamespaceLister)
 						mockSecretNamespaceLister.EXPECT().Get(volume.AzureFile.SecretName).Return(nil, nil)
 					}
 				}
 			},
-			expectedError: fmt.Errorf("getting secret for AzureFile volume returned an empty secret"),
+			// Every volume is resolved, the failures are reported in the order of the volumes.
+			expectedError: errcodes.Wrap(errcodes.InvalidVolume, fmt.Errorf("2 volumes of pod %s cannot be resolved: %v", podName, utilerrors.NewAggregate([]error{
+				fmt.Errorf("the secret %s for AzureFile CSI driver %s is not found", fakeSecretName, azureFileVolumeName1),
+				fmt.Errorf("the secret %s for AzureFile CSI driver %s is not found", fakeSecretName, azureFileVolumeName2),
+			}))),
 		},
 		{
 			description:  "Volume has a secret with a valid value",
@@ -154,19 +167,19 @@ func TestCreatedPodWithAzureFilesVolume(t *testing.T) {
 			pod.Spec.Containers[0].VolumeMounts = fakeVolumeMount
 			pod.Spec.InitContainers = []v1.Container{
 				v1.Container{
-					Name: initContainerName,
+					Name:  initContainerName,
 					Image: "alpine",
 					VolumeMounts: []v1.VolumeMount{
 						v1.VolumeMount{
-							Name: "fakeVolume",
+							Name:      "fakeVolume",
 							MountPath: "/mnt/azure",
 						},
 					},
 					Command: []string{"/bin/bash"},
-					Args: []string{"-c echo test"},
+					Args:    []string{"-c echo test"},
 					Env: []v1.EnvVar{
 						v1.EnvVar{
-							Name: "TEST_ENV",
+							Name:  "TEST_ENV",
 							Value: "testvalue",
 						},
 					},
@@ -180,7 +193,7 @@ func TestCreatedPodWithAzureFilesVolume(t *testing.T) {
 				NewMockPodLister(mockCtrl),
 				mockSecretLister,
 				NewMockConfigMapLister(mockCtrl),
-				NewMockServiceLister(mockCtrl),
+				newServiceLister(),
 				NewMockPersistentVolumeClaimLister(mockCtrl),
 				NewMockPersistentVolumeLister(mockCtrl))
 			if err != nil {
@@ -234,7 +247,7 @@ func TestCreatePodWithProjectedVolume(t *testing.T) {
 		NewMockPodLister(mockCtrl),
 		secretLister,
 		configMapLister,
-		NewMockServiceLister(mockCtrl),
+		newServiceLister(),
 		NewMockPersistentVolumeClaimLister(mockCtrl),
 		NewMockPersistentVolumeLister(mockCtrl))
 	if err != nil {
@@ -408,7 +421,7 @@ func TestCreatePodWithCSIVolume(t *testing.T) {
 					}
 				}
 			},
-			expectedError: fmt.Errorf("the secret %s for AzureFile CSI driver %s is not found", fakeSecret.Name, fakePodVolumes[1].Name),
+			expectedError: errcodes.Wrap(errcodes.InvalidVolume, fmt.Errorf("the secret %s for AzureFile CSI driver %s is not found", fakeSecret.Name, fakePodVolumes[1].Name)),
 		},
 		{
 			description:  "Volume has a secret with a valid value",
@@ -440,7 +453,7 @@ func TestCreatePodWithCSIVolume(t *testing.T) {
 				},
 			}},
 			callSecretMocks: func(secretMock *MockSecretLister) {},
-			expectedError:   fmt.Errorf("secret volume attribute for AzureFile CSI driver %s cannot be empty or nil", azureFileVolumeName),
+			expectedError:   errcodes.Wrap(errcodes.InvalidVolume, fmt.Errorf("secret volume attribute for AzureFile CSI driver %s cannot be empty or nil", azureFileVolumeName)),
 		},
 		{
 			description:  "Volume has no share name",
@@ -458,7 +471,7 @@ func TestCreatePodWithCSIVolume(t *testing.T) {
 					},
 				}},
 			callSecretMocks: func(secretMock *MockSecretLister) {},
-			expectedError:   fmt.Errorf("share name for AzureFile CSI driver %s cannot be empty or nil", fakePodVolumes[1].Name),
+			expectedError:   errcodes.Wrap(errcodes.InvalidVolume, fmt.Errorf("share name for AzureFile CSI driver %s cannot be empty or nil", fakePodVolumes[1].Name)),
 		},
 		{
 			description:  "Volume is Disk Driver",
@@ -478,7 +491,7 @@ func TestCreatePodWithCSIVolume(t *testing.T) {
 				},
 			},
 			callSecretMocks: func(secretMock *MockSecretLister) {},
-			expectedError:   fmt.Errorf("pod %s requires volume %s which is of an unsupported type %s", podName, azureFileVolumeName, "disk.csi.azure.com"),
+			expectedError:   errcodes.Wrap(errcodes.InvalidVolume, fmt.Errorf("pod %s requires volume %s which is of an unsupported type %s", podName, azureFileVolumeName, "disk.csi.azure.com")),
 		},
 	}
 	for _, tc := range cases {
@@ -495,7 +508,7 @@ func TestCreatePodWithCSIVolume(t *testing.T) {
 				NewMockPodLister(mockCtrl),
 				mockSecretLister,
 				NewMockConfigMapLister(mockCtrl),
-				NewMockServiceLister(mockCtrl),
+				newServiceLister(),
 				NewMockPersistentVolumeClaimLister(mockCtrl),
 				NewMockPersistentVolumeLister(mockCtrl))
 			if err != nil {
@@ -521,3 +534,403 @@ func TestCreatePodWithCSIVolume(t *testing.T) {
 		})
 	}
 }
+
+func TestCreatePodWithMemoryEmptyDirVolume(t *testing.T) {
+	aciMocks := createNewACIMock()
+	aciMocks.MockCreateContainerGroup = func(ctx context.Context, resourceGroup, podNS, podName string, cg *client.ContainerGroupWrapper) error {
+		volumes := *cg.ContainerGroupPropertiesWrapper.ContainerGroupProperties.Volumes
+		assert.Check(t, is.Equal(1, len(volumes)), "volume count not match")
+		assert.Check(t, is.Equal(emptyVolumeName, *volumes[0].Name), "volume name doesn't match")
+		assert.Check(t, volumes[0].EmptyDir != nil, "emptyDir volume expected")
+		return nil
+	}
+
+	pod := testsutil.CreatePodObj(podName, podNamespace)
+	pod.Spec.Volumes = []v1.Volume{
+		{
+			Name: emptyVolumeName,
+			VolumeSource: v1.VolumeSource{
+				EmptyDir: &v1.EmptyDirVolumeSource{
+					Medium: v1.StorageMediumMemory,
+				},
+			},
+		},
+	}
+	pod.Spec.Containers[0].VolumeMounts = []v1.VolumeMount{
+		{
+			Name:      emptyVolumeName,
+			MountPath: "/cache",
+		},
+	}
+
+	provider, err := createTestProvider(aciMocks, nil)
+	if err != nil {
+		t.Fatal("Unable to create test provider", err)
+	}
+
+	if err := provider.CreatePod(context.Background(), pod); err != nil {
+		t.Fatal("Failed to create pod", err)
+	}
+}
+
+func TestProjectKeysToPaths(t *testing.T) {
+	data := map[string][]byte{
+		"config.yaml": []byte("a: b"),
+		"other":       []byte("other"),
+	}
+	encodedConfig := base64.StdEncoding.EncodeToString(data["config.yaml"])
+
+	cases := []struct {
+		description   string
+		items         []v1.KeyToPath
+		optional      bool
+		expectedPaths []string
+		shouldFail    bool
+	}{
+		{
+			description:   "all keys without items",
+			expectedPaths: []string{"config.yaml", "other"},
+		},
+		{
+			description:   "subset of keys at custom path",
+			items:         []v1.KeyToPath{{Key: "config.yaml", Path: "app.yaml"}},
+			expectedPaths: []string{"app.yaml"},
+		},
+		{
+			description: "missing key is an error",
+			items:       []v1.KeyToPath{{Key: "missing", Path: "missing"}},
+			shouldFail:  true,
+		},
+		{
+			description:   "missing key is skipped when optional",
+			items:         []v1.KeyToPath{{Key: "missing", Path: "missing"}, {Key: "config.yaml", Path: "config.yaml"}},
+			optional:      true,
+			expectedPaths: []string{"config.yaml"},
+		},
+		{
+			description: "nested path is rejected",
+			items:       []v1.KeyToPath{{Key: "config.yaml", Path: "conf/app.yaml"}},
+			shouldFail:  true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			paths, err := projectKeysToPaths(data, tc.items, tc.optional)
+			if tc.shouldFail {
+				assert.Check(t, err != nil, "projection should fail")
+				return
+			}
+			assert.NilError(t, err)
+			assert.Check(t, is.Equal(len(tc.expectedPaths), len(paths)), "path count doesn't match")
+			for _, path := range tc.expectedPaths {
+				assert.Check(t, paths[path] != nil, "path %s expected", path)
+			}
+		})
+	}
+
+	paths, err := projectKeysToPaths(data, []v1.KeyToPath{{Key: "config.yaml", Path: "app.yaml"}}, false)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(encodedConfig, *paths["app.yaml"]), "projected content doesn't match")
+}
+
+func TestCreatePodWithServiceAccountTokenProjection(t *testing.T) {
+	tokenVolumeName := "azure-identity-token"
+	audience := "api://AzureADTokenExchange"
+	expiration := int64(3600)
+
+	kubeClient := fake.NewSimpleClientset()
+	kubeClient.PrependReactor("create", "serviceaccounts", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		tr := action.(k8stesting.CreateAction).GetObject().(*authv1.TokenRequest)
+		assert.Check(t, is.DeepEqual([]string{audience}, tr.Spec.Audiences), "audience doesn't match")
+		assert.Check(t, is.Equal(expiration, *tr.Spec.ExpirationSeconds), "expiration doesn't match")
+		assert.Check(t, is.Equal("Pod", tr.Spec.BoundObjectRef.Kind), "token should be bound to the pod")
+		tr.Status.Token = "fake-token"
+		return true, tr, nil
+	})
+
+	aciMocks := createNewACIMock()
+	aciMocks.MockCreateContainerGroup = func(ctx context.Context, resourceGroup, podNS, podName string, cg *client.ContainerGroupWrapper) error {
+		volumes := *cg.ContainerGroupPropertiesWrapper.ContainerGroupProperties.Volumes
+		assert.Check(t, is.Equal(1, len(volumes)), "volume count not match")
+		assert.Check(t, is.Equal(tokenVolumeName, *volumes[0].Name), "volume name doesn't match")
+		assert.Check(t, is.Equal(base64.StdEncoding.EncodeToString([]byte("fake-token")), *volumes[0].Secret["token"]), "token doesn't match")
+		return nil
+	}
+
+	automount := false
+	pod := testsutil.CreatePodObj(podName, podNamespace)
+	pod.Spec.AutomountServiceAccountToken = &automount
+	pod.Spec.Volumes = []v1.Volume{
+		{
+			Name: tokenVolumeName,
+			VolumeSource: v1.VolumeSource{
+				Projected: &v1.ProjectedVolumeSource{
+					Sources: []v1.VolumeProjection{{
+						ServiceAccountToken: &v1.ServiceAccountTokenProjection{
+							Audience:          audience,
+							ExpirationSeconds: &expiration,
+							Path:              "token",
+						},
+					}},
+				},
+			},
+		},
+		{
+			Name: "kube-api-access",
+			VolumeSource: v1.VolumeSource{
+				Projected: &v1.ProjectedVolumeSource{
+					Sources: []v1.VolumeProjection{{
+						ServiceAccountToken: &v1.ServiceAccountTokenProjection{Path: "token"},
+					}},
+				},
+			},
+		},
+	}
+	pod.Spec.Containers[0].VolumeMounts = []v1.VolumeMount{
+		{Name: tokenVolumeName, MountPath: "/var/run/secrets/azure/tokens"},
+		{Name: "kube-api-access", MountPath: serviceAccountSecretMountPath},
+	}
+
+	provider, err := createTestProvider(aciMocks, nil)
+	if err != nil {
+		t.Fatal("Unable to create test provider", err)
+	}
+	provider.kubeClient = kubeClient
+
+	if err := provider.CreatePod(context.Background(), pod); err != nil {
+		t.Fatal("Failed to create pod", err)
+	}
+}
+
+func TestGetPersistentVolumeClaimVolume(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	secretLister := NewMockSecretLister(mockCtrl)
+	mockSecretNamespaceLister := NewMockSecretNamespaceLister(mockCtrl)
+	secretLister.EXPECT().Secrets(podNamespace).Return(mockSecretNamespaceLister).AnyTimes()
+	mockSecretNamespaceLister.EXPECT().Get("azure-secret").Return(&v1.Secret{
+		Data: map[string][]byte{
+			azureFileStorageAccountName: []byte("account"),
+			azureFileStorageAccountKey:  []byte("key"),
+		},
+	}, nil).AnyTimes()
+	mockSecretNamespaceLister.EXPECT().Get("azure-storage-account-account-secret").Return(&v1.Secret{
+		Data: map[string][]byte{
+			azureFileStorageAccountName: []byte("account"),
+			azureFileStorageAccountKey:  []byte("key"),
+		},
+	}, nil).AnyTimes()
+
+	newClaim := func(name, volumeName string) *v1.PersistentVolumeClaim {
+		return &v1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: podNamespace},
+			Spec:       v1.PersistentVolumeClaimSpec{VolumeName: volumeName},
+			Status:     v1.PersistentVolumeClaimStatus{Phase: v1.ClaimBound},
+		}
+	}
+	pvcLister := NewMockPersistentVolumeClaimLister(mockCtrl)
+	pvcNamespaceLister := NewMockPersistentVolumeClaimNamespaceLister(mockCtrl)
+	pvcLister.EXPECT().PersistentVolumeClaims(podNamespace).Return(pvcNamespaceLister).AnyTimes()
+	pvcNamespaceLister.EXPECT().Get("files").Return(newClaim("files", "pv-files"), nil)
+	pvcNamespaceLister.EXPECT().Get("blobs").Return(newClaim("blobs", "pv-blobs"), nil).AnyTimes()
+
+	pvLister := NewMockPersistentVolumeLister(mockCtrl)
+	pvLister.EXPECT().Get("pv-files").Return(&v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "pv-files"},
+		Spec: v1.PersistentVolumeSpec{PersistentVolumeSource: v1.PersistentVolumeSource{
+			CSI: &v1.CSIPersistentVolumeSource{
+				Driver:             AzureFileDriverName,
+				VolumeHandle:       "rg#account#share1#",
+				NodeStageSecretRef: &v1.SecretReference{Name: "azure-secret", Namespace: podNamespace},
+			},
+		}},
+	}, nil)
+	pvLister.EXPECT().Get("pv-blobs").Return(&v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "pv-blobs"},
+		Spec: v1.PersistentVolumeSpec{PersistentVolumeSource: v1.PersistentVolumeSource{
+			CSI: &v1.CSIPersistentVolumeSource{Driver: AzureBlobDriverName, VolumeHandle: "rg#account#container"},
+		}},
+	}, nil).AnyTimes()
+
+	resourceManager, err := manager.NewResourceManager(
+		NewMockPodLister(mockCtrl),
+		secretLister,
+		NewMockConfigMapLister(mockCtrl),
+		NewMockServiceLister(mockCtrl),
+		pvcLister,
+		pvLister)
+	if err != nil {
+		t.Fatal("Unable to prepare the mocks for resourceManager", err)
+	}
+
+	provider, err := createTestProvider(createNewACIMock(), resourceManager)
+	if err != nil {
+		t.Fatal("Unable to create test provider", err)
+	}
+
+	pod := testsutil.CreatePodObj(podName, podNamespace)
+	newVolume := func(claimName string) v1.Volume {
+		return v1.Volume{
+			Name: claimName,
+			VolumeSource: v1.VolumeSource{
+				PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: claimName},
+			},
+		}
+	}
+
+	volume, err := provider.getPersistentVolumeClaimVolume(context.Background(), pod, newVolume("files"))
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("share1", *volume.AzureFile.ShareName), "share name doesn't match")
+	assert.Check(t, is.Equal("account", *volume.AzureFile.StorageAccountName), "storage account doesn't match")
+
+	pod.Spec.Containers[0].VolumeMounts = []v1.VolumeMount{{Name: "blobs", MountPath: "/data", ReadOnly: true}}
+	volume, err = provider.getPersistentVolumeClaimVolume(context.Background(), pod, newVolume("blobs"))
+	assert.NilError(t, err)
+	assert.Check(t, volume.EmptyDir != nil, "blob volumes should be downloaded into an empty dir")
+
+	pod.Spec.Containers[0].VolumeMounts[0].ReadOnly = false
+	_, err = provider.getPersistentVolumeClaimVolume(context.Background(), pod, newVolume("blobs"))
+	assert.Check(t, errdefs.IsInvalidInput(err), "writable blob volumes should be rejected")
+}
+
+func TestGetVolumesAggregatesErrors(t *testing.T) {
+	provider, err := createTestProvider(createNewACIMock(), nil)
+	if err != nil {
+		t.Fatal("Unable to create test provider", err)
+	}
+
+	pod := testsutil.CreatePodObj(podName, podNamespace)
+	pod.Spec.Volumes = []v1.Volume{
+		{Name: emptyVolumeName, VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}}},
+		{Name: "blobs", VolumeSource: v1.VolumeSource{CSI: &v1.CSIVolumeSource{Driver: AzureBlobDriverName}}},
+		{Name: "disks", VolumeSource: v1.VolumeSource{CSI: &v1.CSIVolumeSource{Driver: "disk.csi.azure.com"}}},
+		{Name: "host", VolumeSource: v1.VolumeSource{HostPath: &v1.HostPathVolumeSource{Path: "/var/log"}}},
+	}
+
+	_, err = provider.getVolumes(context.Background(), pod)
+	assert.ErrorContains(t, err, "3 volumes of pod")
+	for _, name := range []string{"blobs", "disks", "host"} {
+		assert.Check(t, is.Contains(err.Error(), "volume "+name), "volume %s should be reported", name)
+	}
+
+	// A single broken volume keeps the type of its error.
+	pod.Spec.Volumes = pod.Spec.Volumes[:2]
+	_, err = provider.getVolumes(context.Background(), pod)
+	assert.Check(t, errdefs.IsInvalidInput(err), "blob volumes without a container should be rejected as invalid input")
+
+	pod.Spec.Volumes = pod.Spec.Volumes[:1]
+	volumes, err := provider.getVolumes(context.Background(), pod)
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(volumes, 1))
+}
+
+func TestTranslateInTreeAzureFile(t *testing.T) {
+	volume := translateInTreeAzureFile(v1.Volume{
+		Name: "azurefile",
+		VolumeSource: v1.VolumeSource{
+			AzureFile: &v1.AzureFileVolumeSource{
+				ShareName:  fakeShareName1,
+				SecretName: "azure-secret",
+				ReadOnly:   true,
+			},
+		},
+	})
+
+	assert.Check(t, volume.AzureFile == nil, "in-tree source should be replaced")
+	assert.Check(t, is.Equal(AzureFileDriverName, volume.CSI.Driver), "driver doesn't match")
+	assert.Check(t, is.Equal(fakeShareName1, volume.CSI.VolumeAttributes[azureFileShareName]), "share name doesn't match")
+	assert.Check(t, is.Equal("azure-secret", volume.CSI.VolumeAttributes[azureFileSecretName]), "secret name doesn't match")
+	assert.Check(t, *volume.CSI.ReadOnly, "read only should be kept")
+
+	secretNamespace := "storage"
+	pv := translateInTreeAzureFilePersistentVolume(&v1.PersistentVolume{
+		Spec: v1.PersistentVolumeSpec{PersistentVolumeSource: v1.PersistentVolumeSource{
+			AzureFile: &v1.AzureFilePersistentVolumeSource{
+				ShareName:       fakeShareName2,
+				SecretName:      "azure-secret",
+				SecretNamespace: &secretNamespace,
+			},
+		}},
+	})
+	assert.Check(t, pv.Spec.AzureFile == nil, "in-tree source should be replaced")
+	assert.Check(t, is.Equal(fakeShareName2, pv.Spec.CSI.VolumeAttributes[azureFileShareName]), "share name doesn't match")
+	assert.Check(t, is.Equal(secretNamespace, pv.Spec.CSI.NodeStageSecretRef.Namespace), "secret namespace doesn't match")
+}
+
+func TestCreatePodWithEmptyDirSharedWithInitContainer(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	optionalSecretName := "optional-secret"
+	aciMocks := createNewACIMock()
+	aciMocks.MockCreateContainerGroup = func(ctx context.Context, resourceGroup, podNS, podName string, cg *client.ContainerGroupWrapper) error {
+		cgProperties := cg.ContainerGroupPropertiesWrapper.ContainerGroupProperties
+		volumes := *cgProperties.Volumes
+		assert.Check(t, is.Equal(2, len(volumes)), "the shared emptyDir should be declared once")
+		assert.Check(t, is.Equal(emptyVolumeName, *volumes[0].Name))
+		assert.Check(t, volumes[0].EmptyDir != nil, "emptyDir volume expected")
+		assert.Check(t, is.Equal(optionalSecretName, *volumes[1].Name))
+		assert.Check(t, volumes[1].EmptyDir != nil, "missing optional secret should be mounted as an empty directory")
+
+		initMounts := *(*cgProperties.InitContainers)[0].VolumeMounts
+		mainMounts := *(*cgProperties.Containers)[0].VolumeMounts
+		assert.Check(t, is.Equal(emptyVolumeName, *initMounts[0].Name))
+		assert.Check(t, is.Equal(emptyVolumeName, *mainMounts[0].Name), "init and main containers should mount the same volume")
+		return nil
+	}
+
+	secretLister := NewMockSecretLister(mockCtrl)
+	secretNamespaceLister := NewMockSecretNamespaceLister(mockCtrl)
+	secretLister.EXPECT().Secrets(podNamespace).Return(secretNamespaceLister).AnyTimes()
+	secretNamespaceLister.EXPECT().Get(optionalSecretName).Return(nil, k8serr.NewNotFound(schema.GroupResource{Resource: "secrets"}, optionalSecretName)).AnyTimes()
+
+	rm, err := manager.NewResourceManager(
+		NewMockPodLister(mockCtrl),
+		secretLister,
+		NewMockConfigMapLister(mockCtrl),
+		newServiceLister(),
+		NewMockPersistentVolumeClaimLister(mockCtrl),
+		NewMockPersistentVolumeLister(mockCtrl))
+	if err != nil {
+		t.Fatal("Unable to prepare the mocks for resourceManager", err)
+	}
+
+	optional := true
+	pod := testsutil.CreatePodObj(podName, podNamespace)
+	pod.Spec.Volumes = []v1.Volume{
+		{
+			Name:         emptyVolumeName,
+			VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}},
+		},
+		{
+			Name: optionalSecretName,
+			VolumeSource: v1.VolumeSource{Secret: &v1.SecretVolumeSource{
+				SecretName: optionalSecretName,
+				Optional:   &optional,
+			}},
+		},
+	}
+	pod.Spec.InitContainers = []v1.Container{
+		{
+			Name:         "fetch",
+			Image:        "alpine",
+			Command:      []string{"/bin/sh", "-c", "echo ready > /work/artifact"},
+			VolumeMounts: []v1.VolumeMount{{Name: emptyVolumeName, MountPath: "/work"}},
+		},
+	}
+	pod.Spec.Containers[0].VolumeMounts = []v1.VolumeMount{
+		{Name: emptyVolumeName, MountPath: "/usr/share/nginx/html"},
+		{Name: optionalSecretName, MountPath: "/etc/secret"},
+	}
+
+	provider, err := createTestProvider(aciMocks, rm)
+	if err != nil {
+		t.Fatal("Unable to create test provider", err)
+	}
+
+	if err := provider.CreatePod(context.Background(), pod); err != nil {
+		t.Fatal("Failed to create pod", err)
+	}
+}
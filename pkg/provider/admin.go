@@ -0,0 +1,319 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/virtual-kubelet/azure-aci/pkg/errcodes"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	adminReadTimeout  = 30 * time.Second
+	adminWriteTimeout = 5 * time.Minute
+	adminIdleTimeout  = 2 * time.Minute
+)
+
+// cacheFlusher is implemented by the ARM clients that cache container groups.
+type cacheFlusher interface {
+	FlushCache()
+}
+
+// managedContainerGroup describes a container group of the node in the admin API.
+type managedContainerGroup struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Pod       string `json:"pod"`
+	UID       string `json:"uid,omitempty"`
+}
+
+// drainResult lists the pods evicted by a drain and the evictions that failed, e.g. because of a
+// pod disruption budget, and when the provider stops waiting for the pods to terminate.
+type drainResult struct {
+	Evicted  []string          `json:"evicted"`
+	Failed   map[string]string `json:"failed,omitempty"`
+	Deadline time.Time         `json:"deadline"`
+}
+
+// AdminHandler serves the admin API of the provider, so operators can automate maintenance
+// without exec'ing into the virtual kubelet. Every request must carry the token as a bearer token.
+//
+//	GET  /containergroups  list the container groups of the node
+//	POST /resync           update the status of every pod now
+//	POST /gc               delete the orphaned container groups past their grace period now
+//	POST /drain            stop accepting pods, cordon the node and evict its pods, the
+//	                       ?timeout= duration bounds the wait for the pods to terminate
+//	POST /caches/flush     drop the cached container groups and registry credentials
+//	GET  /network/rules    list the network rules the delegated subnets need
+//	POST /tags/migrate     add the tags of the current schema missing on the container groups of
+//	                       the pods of the node, ?dryRun=true only lists them
+func (p *ACIProvider) AdminHandler(token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/containergroups", adminMethod(http.MethodGet, p.adminListContainerGroups))
+	mux.HandleFunc("/resync", adminMethod(http.MethodPost, p.adminResync))
+	mux.HandleFunc("/gc", adminMethod(http.MethodPost, p.adminGC))
+	mux.HandleFunc("/drain", adminMethod(http.MethodPost, p.adminDrain))
+	mux.HandleFunc("/caches/flush", adminMethod(http.MethodPost, p.adminFlushCaches))
+	mux.HandleFunc("/network/rules", adminMethod(http.MethodGet, p.adminNetworkRules))
+	mux.HandleFunc("/tags/migrate", adminMethod(http.MethodPost, p.adminMigrateTags))
+	return adminAuth(token, mux)
+}
+
+// ServeAdmin serves the admin API on addr until the context is done, with TLS when a certificate
+// and key file are given. An address without host, e.g. ":10260", only listens on the loopback
+// interface, other hosts have to be set explicitly to expose the admin API, and need TLS.
+func (p *ACIProvider) ServeAdmin(ctx context.Context, addr, token, certFile, keyFile string) {
+	if err := ValidateAdminAddr(addr, certFile, keyFile); err != nil {
+		log.G(ctx).WithError(err).Error("failed to serve the admin API")
+		return
+	}
+	server := &http.Server{
+		Addr:              loopbackByDefault(addr),
+		Handler:           p.AdminHandler(token),
+		ReadHeaderTimeout: adminReadTimeout,
+		ReadTimeout:       adminReadTimeout,
+		// Drains and tag migrations call ARM and the API server for every pod of the node.
+		WriteTimeout: adminWriteTimeout,
+		IdleTimeout:  adminIdleTimeout,
+	}
+	if err := serveEndpoint(ctx, server, certFile, keyFile); err != nil {
+		log.G(ctx).WithError(err).Error("failed to serve the admin API")
+	}
+}
+
+// ValidateAdminAddr checks that the admin API is served with TLS unless it only listens on the
+// loopback interface, so the bearer token never travels in cleartext over the network.
+func ValidateAdminAddr(addr, certFile, keyFile string) error {
+	if certFile != "" && keyFile != "" {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(loopbackByDefault(addr))
+	if err != nil {
+		return errcodes.Errorf(errcodes.InvalidConfig, "invalid admin API address %q: %v", addr, err)
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return errcodes.Errorf(errcodes.InvalidConfig, "the admin API listens on %s, which is not a loopback address, set ACI_ADMIN_TLS_CERT_FILE and ACI_ADMIN_TLS_KEY_FILE to serve it with TLS", addr)
+	}
+	return nil
+}
+
+// loopbackByDefault binds an address without host to the loopback interface.
+func loopbackByDefault(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || host != "" {
+		return addr
+	}
+	return net.JoinHostPort("127.0.0.1", port)
+}
+
+// serveEndpoint serves an HTTP endpoint of the virtual kubelet until the context is done, with TLS
+// when a certificate and key file are given.
+func serveEndpoint(ctx context.Context, server *http.Server, certFile, keyFile string) error {
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	var err error
+	if certFile != "" || keyFile != "" {
+		server.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		err = server.ListenAndServeTLS(certFile, keyFile)
+	} else {
+		err = server.ListenAndServe()
+	}
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+func adminAuth(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		given, ok := bearerToken(r)
+		if !ok || token == "" || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// bearerToken returns the token of the Authorization header of the request, when it is a bearer token.
+func bearerToken(r *http.Request) (string, bool) {
+	const prefix = "Bearer "
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, prefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(header, prefix), true
+}
+
+func adminMethod(method string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+func writeAdminJSON(ctx context.Context, w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.G(ctx).WithError(err).Warn("failed to write the admin API response")
+	}
+}
+
+func (p *ACIProvider) adminListContainerGroups(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	// Only the container groups tagged with the node are listed, the other virtual nodes sharing the
+	// resource group are none of its business.
+	cgs, err := p.azClientsAPIs.ListContainerGroupsByNodeName(ctx, p.resourceGroup, p.nodeName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	tag := func(tags map[string]*string, name string) string {
+		if value := tags[name]; value != nil {
+			return *value
+		}
+		return ""
+	}
+	groups := make([]managedContainerGroup, 0)
+	if cgs != nil {
+		for _, cg := range *cgs {
+			if cg.Name == nil {
+				continue
+			}
+			groups = append(groups, managedContainerGroup{
+				Name:      *cg.Name,
+				Namespace: tag(cg.Tags, "Namespace"),
+				Pod:       tag(cg.Tags, "PodName"),
+				UID:       tag(cg.Tags, "UID"),
+			})
+		}
+	}
+	writeAdminJSON(ctx, w, http.StatusOK, groups)
+}
+
+func (p *ACIProvider) adminResync(w http.ResponseWriter, r *http.Request) {
+	tracker := p.podsTracker()
+	if tracker == nil {
+		http.Error(w, "pods are not tracked yet", http.StatusServiceUnavailable)
+		return
+	}
+	tracker.requestResync()
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (p *ACIProvider) adminGC(w http.ResponseWriter, r *http.Request) {
+	tracker := p.podsTracker()
+	if tracker == nil {
+		http.Error(w, "pods are not tracked yet", http.StatusServiceUnavailable)
+		return
+	}
+	tracker.requestCleanup()
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (p *ACIProvider) adminDrain(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var timeout time.Duration
+	if value := r.URL.Query().Get("timeout"); value != "" {
+		var err error
+		if timeout, err = time.ParseDuration(value); err != nil || timeout <= 0 {
+			http.Error(w, fmt.Sprintf("invalid timeout %q, a positive duration is required", value), http.StatusBadRequest)
+			return
+		}
+	}
+	result, err := p.startDrain(ctx, timeout)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeAdminJSON(ctx, w, http.StatusOK, result)
+}
+
+// drain cordons the node and evicts its pods through the eviction API, so pod disruption budgets
+// are honored. Evictions that fail are reported, the drain can be requested again.
+func (p *ACIProvider) drain(ctx context.Context) (*drainResult, error) {
+	patch := []byte(`{"spec":{"unschedulable":true}}`)
+	if _, err := p.kubeClient.CoreV1().Nodes().Patch(ctx, p.nodeName, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		return nil, err
+	}
+	log.G(ctx).Infof("node %s cordoned for drain", p.nodeName)
+
+	result := &drainResult{Evicted: make([]string, 0)}
+	for _, pod := range p.resourceManager.GetPods() {
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+		name := pod.Namespace + "/" + pod.Name
+		err := p.kubeClient.PolicyV1beta1().Evictions(pod.Namespace).Evict(ctx, &policyv1beta1.Eviction{
+			ObjectMeta: metav1.ObjectMeta{Name: pod.Name, Namespace: pod.Namespace},
+		})
+		if err != nil {
+			if result.Failed == nil {
+				result.Failed = make(map[string]string)
+			}
+			result.Failed[name] = err.Error()
+			continue
+		}
+		result.Evicted = append(result.Evicted, name)
+	}
+	return result, nil
+}
+
+func (p *ACIProvider) adminFlushCaches(w http.ResponseWriter, r *http.Request) {
+	p.flushCaches(r.Context())
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// flushCaches drops the caches of the provider, e.g. after credentials were rotated outside of
+// Kubernetes.
+func (p *ACIProvider) flushCaches(ctx context.Context) {
+	if p.clientCache != nil {
+		p.clientCache.FlushCache()
+	}
+	if p.registryCredentials != nil {
+		p.registryCredentials.flush()
+	}
+	log.G(ctx).Info("provider caches flushed")
+}
+
+func (p *ACIProvider) adminMigrateTags(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	dryRun := false
+	if value := r.URL.Query().Get("dryRun"); value != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			http.Error(w, fmt.Sprintf("invalid dryRun %q, a boolean is required", value), http.StatusBadRequest)
+			return
+		}
+	}
+	result, err := p.migrateTags(ctx, dryRun)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeAdminJSON(ctx, w, http.StatusOK, result)
+}
@@ -0,0 +1,145 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	"github.com/golang/mock/gomock"
+	testsutil "github.com/virtual-kubelet/azure-aci/pkg/tests"
+	"github.com/virtual-kubelet/node-cli/manager"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+type fakeCacheFlusher struct {
+	flushed int
+}
+
+func (f *fakeCacheFlusher) FlushCache() {
+	f.flushed++
+}
+
+func adminRequest(handler http.Handler, method, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAdminAPI(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	ours := testsutil.CreateContainerGroupObj("web", "ns", "Running", &[]azaci.Container{}, "Succeeded")
+	other := testsutil.CreateContainerGroupObj("api", "ns", "Running", &[]azaci.Container{}, "Succeeded")
+	otherNode := "other-vk"
+	other.Tags["NodeName"] = &otherNode
+	aciMocks := createNewACIMock()
+	aciMocks.MockGetContainerGroupList = func(ctx context.Context, resourceGroup string) (*[]azaci.ContainerGroup, error) {
+		t.Error("the container groups of the other nodes of the resource group should not be listed")
+		return &[]azaci.ContainerGroup{*ours, *other}, nil
+	}
+	aciMocks.MockListContainerGroupsByNodeName = func(ctx context.Context, resourceGroup, nodeName string) (*[]azaci.ContainerGroup, error) {
+		cgs := []azaci.ContainerGroup{}
+		for _, cg := range []*azaci.ContainerGroup{ours, other} {
+			if *cg.Tags["NodeName"] == nodeName {
+				cgs = append(cgs, *cg)
+			}
+		}
+		return &cgs, nil
+	}
+
+	pod := testsutil.CreatePodObj("web", "ns")
+	podLister := NewMockPodLister(mockCtrl)
+	podLister.EXPECT().List(labels.Everything()).Return([]*v1.Pod{pod}, nil).AnyTimes()
+	rm, err := manager.NewResourceManager(
+		podLister,
+		NewMockSecretLister(mockCtrl),
+		NewMockConfigMapLister(mockCtrl),
+		NewMockServiceLister(mockCtrl),
+		NewMockPersistentVolumeClaimLister(mockCtrl),
+		NewMockPersistentVolumeLister(mockCtrl))
+	if err != nil {
+		t.Fatal("Unable to prepare the mocks for resourceManager", err)
+	}
+
+	flusher := &fakeCacheFlusher{}
+	p := &ACIProvider{
+		azClientsAPIs:       aciMocks,
+		resourceManager:     rm,
+		nodeName:            "vk",
+		kubeClient:          fake.NewSimpleClientset(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "vk"}}, pod),
+		registryCredentials: newRegistryCredentialCache(),
+		clientCache:         flusher,
+		tracker:             &PodsTracker{resync: make(chan struct{}, 1), cleanup: make(chan struct{}, 1)},
+	}
+	handler := p.AdminHandler("s3cret")
+
+	assert.Check(t, is.Equal(http.StatusUnauthorized, adminRequest(handler, http.MethodGet, "/containergroups", "").Code))
+	assert.Check(t, is.Equal(http.StatusUnauthorized, adminRequest(handler, http.MethodGet, "/containergroups", "wrong").Code))
+	assert.Check(t, is.Equal(http.StatusUnauthorized, adminRequest(p.AdminHandler(""), http.MethodGet, "/containergroups", "").Code), "an empty token should never authorize")
+	req := httptest.NewRequest(http.MethodGet, "/containergroups", nil)
+	req.Header.Set("Authorization", "s3cret")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Check(t, is.Equal(http.StatusUnauthorized, rec.Code), "the token should be a bearer token")
+	assert.Check(t, is.Equal(http.StatusMethodNotAllowed, adminRequest(handler, http.MethodGet, "/resync", "s3cret").Code))
+
+	rec = adminRequest(handler, http.MethodGet, "/containergroups", "s3cret")
+	assert.Assert(t, is.Equal(http.StatusOK, rec.Code))
+	var groups []managedContainerGroup
+	assert.NilError(t, json.NewDecoder(rec.Body).Decode(&groups))
+	assert.Check(t, is.DeepEqual([]managedContainerGroup{{Name: "web", Namespace: "ns", Pod: "web", UID: "web"}}, groups), "only the container groups of the node should be listed")
+
+	assert.Check(t, is.Equal(http.StatusAccepted, adminRequest(handler, http.MethodPost, "/resync", "s3cret").Code))
+	assert.Check(t, is.Len(p.tracker.resync, 1))
+	assert.Check(t, is.Equal(http.StatusAccepted, adminRequest(handler, http.MethodPost, "/gc", "s3cret").Code))
+	assert.Check(t, is.Len(p.tracker.cleanup, 1))
+
+	assert.Check(t, is.Equal(http.StatusNoContent, adminRequest(handler, http.MethodPost, "/caches/flush", "s3cret").Code))
+	assert.Check(t, is.Equal(1, flusher.flushed))
+
+	assert.Check(t, is.Equal(http.StatusBadRequest, adminRequest(handler, http.MethodPost, "/drain?timeout=soon", "s3cret").Code))
+	rec = adminRequest(handler, http.MethodPost, "/drain?timeout=1h", "s3cret")
+	assert.Assert(t, is.Equal(http.StatusOK, rec.Code))
+	var result drainResult
+	assert.NilError(t, json.NewDecoder(rec.Body).Decode(&result))
+	assert.Check(t, is.DeepEqual([]string{"ns/web"}, result.Evicted))
+	assert.Check(t, result.Deadline.After(time.Now().Add(59*time.Minute)), "the drain should wait for the pods until the timeout")
+	assert.Check(t, p.drainMode.active(), "the node should stop accepting pods")
+	node, err := p.kubeClient.CoreV1().Nodes().Get(context.Background(), "vk", metav1.GetOptions{})
+	assert.NilError(t, err)
+	assert.Check(t, node.Spec.Unschedulable, "the node should be cordoned")
+}
+
+func TestLoopbackByDefault(t *testing.T) {
+	assert.Check(t, is.Equal("127.0.0.1:10260", loopbackByDefault(":10260")))
+	assert.Check(t, is.Equal("0.0.0.0:10260", loopbackByDefault("0.0.0.0:10260")))
+	assert.Check(t, is.Equal("[::1]:10260", loopbackByDefault("[::1]:10260")))
+}
+
+func TestValidateAdminAddr(t *testing.T) {
+	for _, addr := range []string{":10260", "127.0.0.1:10260", "[::1]:10260", "localhost:10260"} {
+		assert.Check(t, ValidateAdminAddr(addr, "", ""), "%s only listens on the loopback interface", addr)
+	}
+	for _, addr := range []string{"0.0.0.0:10260", "10.0.0.4:10260", "vk.example.com:10260"} {
+		assert.Check(t, ValidateAdminAddr(addr, "", "") != nil, "%s should need TLS", addr)
+		assert.Check(t, ValidateAdminAddr(addr, "tls.crt", "tls.key"), "%s is served with TLS", addr)
+	}
+}
@@ -0,0 +1,74 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/virtual-kubelet/azure-aci/pkg/errcodes"
+	"github.com/virtual-kubelet/azure-aci/pkg/metrics"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	v1 "k8s.io/api/core/v1"
+)
+
+// PodAdmissionCheck validates a pod before its container group is rendered. Embedders register
+// checks with RegisterPodAdmissionCheck for organization specific rules, e.g. image allowlists.
+type PodAdmissionCheck interface {
+	// Name identifies the check in events, logs and metrics.
+	Name() string
+	// Admit returns an error to reject the pod, which stops the chain.
+	Admit(ctx context.Context, pod *v1.Pod) error
+}
+
+type orderedAdmissionCheck struct {
+	order int
+	check PodAdmissionCheck
+}
+
+var (
+	admissionChecksMutex sync.Mutex
+	admissionChecks      []orderedAdmissionCheck
+)
+
+// RegisterPodAdmissionCheck adds a check run by providers created afterwards. Checks run by
+// ascending order, checks with the same order run in registration order.
+func RegisterPodAdmissionCheck(order int, check PodAdmissionCheck) {
+	admissionChecksMutex.Lock()
+	defer admissionChecksMutex.Unlock()
+
+	admissionChecks = append(admissionChecks, orderedAdmissionCheck{order: order, check: check})
+	sort.SliceStable(admissionChecks, func(i, j int) bool {
+		return admissionChecks[i].order < admissionChecks[j].order
+	})
+}
+
+func (p *ACIProvider) setupAdmissionChecks() {
+	admissionChecksMutex.Lock()
+	defer admissionChecksMutex.Unlock()
+
+	p.admissionChecks = make([]PodAdmissionCheck, 0, len(admissionChecks))
+	for _, c := range admissionChecks {
+		p.admissionChecks = append(p.admissionChecks, c.check)
+	}
+}
+
+// admitPod runs the admission checks in order and stops at the first rejection.
+func (p *ACIProvider) admitPod(ctx context.Context, pod *v1.Pod) error {
+	for _, check := range p.admissionChecks {
+		start := time.Now()
+		err := check.Admit(ctx, pod)
+		metrics.RecordAdmissionCheck(check.Name(), err, time.Since(start))
+		if err != nil {
+			log.G(ctx).WithError(err).Infof("pod %s/%s rejected by admission check %s", pod.Namespace, pod.Name, check.Name())
+			p.recordEvent(pod, v1.EventTypeWarning, "AdmissionRejected", "Pod is rejected by admission check %s: %v", check.Name(), err)
+			return errcodes.Wrap(errcodes.AdmissionRejected, errdefs.InvalidInputf("pod %s is rejected by admission check %s: %v", pod.Name, check.Name(), err))
+		}
+	}
+	return nil
+}
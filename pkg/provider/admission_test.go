@@ -0,0 +1,69 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/virtual-kubelet/azure-aci/pkg/client"
+	testsutil "github.com/virtual-kubelet/azure-aci/pkg/tests"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	v1 "k8s.io/api/core/v1"
+)
+
+type fakeAdmissionCheck struct {
+	name  string
+	calls *[]string
+	err   error
+}
+
+func (c *fakeAdmissionCheck) Name() string {
+	return c.name
+}
+
+func (c *fakeAdmissionCheck) Admit(ctx context.Context, pod *v1.Pod) error {
+	*c.calls = append(*c.calls, c.name)
+	return c.err
+}
+
+func TestAdmitPodRunsChecksInOrder(t *testing.T) {
+	var calls []string
+	p := &ACIProvider{
+		admissionChecks: []PodAdmissionCheck{
+			&fakeAdmissionCheck{name: "required-annotations", calls: &calls},
+			&fakeAdmissionCheck{name: "image-allowlist", calls: &calls, err: fmt.Errorf("image is not allowed")},
+			&fakeAdmissionCheck{name: "never-called", calls: &calls},
+		},
+	}
+
+	err := p.admitPod(context.Background(), testsutil.CreatePodObj("pod", "ns"))
+	assert.Check(t, errdefs.IsInvalidInput(err), "rejection should be invalid input")
+	assert.Check(t, strings.Contains(err.Error(), "image-allowlist"), "error should name the check")
+	assert.Check(t, is.DeepEqual([]string{"required-annotations", "image-allowlist"}, calls), "chain should stop at the first rejection")
+}
+
+func TestCreatePodWithAdmissionCheck(t *testing.T) {
+	var calls []string
+	RegisterPodAdmissionCheck(10, &fakeAdmissionCheck{name: "second", calls: &calls})
+	RegisterPodAdmissionCheck(-10, &fakeAdmissionCheck{name: "first", calls: &calls})
+
+	aciMocks := createNewACIMock()
+	aciMocks.MockCreateContainerGroup = func(ctx context.Context, resourceGroup, podNS, podName string, cg *client.ContainerGroupWrapper) error {
+		return nil
+	}
+
+	provider, err := createTestProvider(aciMocks, nil)
+	if err != nil {
+		t.Fatal("Unable to create test provider", err)
+	}
+
+	assert.NilError(t, provider.CreatePod(context.Background(), testsutil.CreatePodObj(podName, podNamespace)))
+	assert.Check(t, is.DeepEqual([]string{"first", "second"}, calls), "checks should run by order")
+}
@@ -0,0 +1,252 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/services/resourcehealth/mgmt/2020-05-01/resourcehealth"
+	client2 "github.com/virtual-kubelet/azure-aci/pkg/client"
+	"github.com/virtual-kubelet/azure-aci/pkg/errcodes"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// advisoryRefreshInterval is how often the Resource Health of the container groups is checked.
+	advisoryRefreshInterval = 5 * time.Minute
+
+	// podConditionContainerGroupAvailable reports the Resource Health of the container group of the
+	// pod. It is only set while the container group is impacted by maintenance or a service issue.
+	podConditionContainerGroupAvailable v1.PodConditionType = "virtualkubelet.io/ContainerGroupAvailable"
+
+	advisoryReasonPlannedMaintenance = "PlannedMaintenance"
+	advisoryReasonServiceIssue       = "ServiceIssue"
+)
+
+// containerGroupAdvisory is the maintenance or service issue impacting a container group.
+type containerGroupAdvisory struct {
+	// available is set when the container group is still available, e.g. for upcoming maintenance.
+	available bool
+	reason    string
+	message   string
+	since     time.Time
+}
+
+// serviceIncident is a planned maintenance or regional service issue reported by Resource Health.
+type serviceIncident struct {
+	incidentType string
+	region       string
+	title        string
+}
+
+// advisories keeps the advisories of the container groups of the node and the service incidents
+// already published on the node.
+type advisories struct {
+	mu        sync.Mutex
+	pods      map[PodIdentifier]containerGroupAdvisory
+	incidents map[string]serviceIncident
+}
+
+// get returns the advisory of the container group of a pod.
+func (a *advisories) get(id PodIdentifier) (containerGroupAdvisory, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	advisory, ok := a.pods[id]
+	return advisory, ok
+}
+
+// update replaces the advisories and incidents, and returns the ones that changed. Advisories that
+// stay the same keep the time they were first seen.
+func (a *advisories) update(pods map[PodIdentifier]containerGroupAdvisory, incidents map[string]serviceIncident) (changed map[PodIdentifier]containerGroupAdvisory, cleared []PodIdentifier, newIncidents []serviceIncident) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	changed = make(map[PodIdentifier]containerGroupAdvisory)
+	for id, advisory := range pods {
+		previous, ok := a.pods[id]
+		if ok && previous.reason == advisory.reason && previous.message == advisory.message {
+			advisory.since = previous.since
+			pods[id] = advisory
+			continue
+		}
+		changed[id] = advisory
+	}
+	for id := range a.pods {
+		if _, ok := pods[id]; !ok {
+			cleared = append(cleared, id)
+		}
+	}
+	for key, incident := range incidents {
+		if _, ok := a.incidents[key]; !ok {
+			newIncidents = append(newIncidents, incident)
+		}
+	}
+	a.pods = pods
+	a.incidents = incidents
+	return changed, cleared, newIncidents
+}
+
+// refreshAdvisories checks the Resource Health of the container groups of the node. Maintenance and
+// service issues are published as node events and pod events, and surfaced as a pod condition, so
+// operators can move sensitive workloads ahead of them.
+func (p *ACIProvider) refreshAdvisories(ctx context.Context) {
+	statuses, err := p.azClientsAPIs.ListAvailabilityStatuses(ctx, p.resourceGroup)
+	if err != nil {
+		log.G(ctx).WithError(err).Warn("unable to check the resource health of the container groups")
+		return
+	}
+
+	pods := make(map[string]*v1.Pod)
+	for _, pod := range p.resourceManager.GetPods() {
+		pods[client2.ContainerGroupName(pod.Namespace, pod.Name)] = pod
+	}
+
+	now := time.Now()
+	current := make(map[PodIdentifier]containerGroupAdvisory)
+	incidents := make(map[string]serviceIncident)
+	if statuses != nil {
+		for _, status := range *statuses {
+			if status.ID == nil || status.Properties == nil {
+				continue
+			}
+			resourceID := *status.ID
+			if i := strings.Index(strings.ToLower(resourceID), "/providers/microsoft.resourcehealth/"); i >= 0 {
+				resourceID = resourceID[:i]
+			}
+			name, ok := containerGroupNameFromID(p.resourceGroup, resourceID)
+			if !ok || pods[name] == nil {
+				continue
+			}
+			for key, incident := range serviceIncidents(status.Properties) {
+				incidents[key] = incident
+			}
+			if advisory, ok := advisoryFromStatus(status.Properties); ok {
+				if advisory.since.IsZero() {
+					advisory.since = now
+				}
+				current[PodIdentifier{namespace: pods[name].Namespace, name: pods[name].Name}] = advisory
+			}
+		}
+	}
+
+	changed, cleared, newIncidents := p.advisories.update(current, incidents)
+	for _, incident := range newIncidents {
+		reason := "ServiceAdvisory"
+		if strings.EqualFold(incident.incidentType, "Maintenance") {
+			reason = "MaintenanceScheduled"
+		}
+		kind := incident.incidentType
+		if incident.region != "" {
+			kind += " in " + incident.region
+		}
+		p.recordNodeEvent(v1.EventTypeWarning, reason, "%s impacting container groups of the node: %s", kind, incident.title)
+	}
+	for id, advisory := range changed {
+		p.recordEvent(pods[client2.ContainerGroupName(id.namespace, id.name)], v1.EventTypeWarning, "ContainerGroup"+advisory.reason, "%s", errcodes.ServiceAdvisory.Message(advisory.message))
+	}
+	for _, id := range cleared {
+		if pod := pods[client2.ContainerGroupName(id.namespace, id.name)]; pod != nil {
+			p.recordEvent(pod, v1.EventTypeNormal, "ContainerGroupAvailable", "container group is no longer impacted by maintenance or service issues")
+		}
+	}
+}
+
+// advisoryCondition returns the condition of the pod for the advisory of its container group.
+func (p *ACIProvider) advisoryCondition(namespace, name string) (v1.PodCondition, bool) {
+	advisory, ok := p.advisories.get(PodIdentifier{namespace: namespace, name: name})
+	if !ok {
+		return v1.PodCondition{}, false
+	}
+	status := v1.ConditionFalse
+	if advisory.available {
+		status = v1.ConditionTrue
+	}
+	return v1.PodCondition{
+		Type:               podConditionContainerGroupAvailable,
+		Status:             status,
+		Reason:             advisory.reason,
+		Message:            advisory.message,
+		LastTransitionTime: metav1.NewTime(advisory.since),
+	}, true
+}
+
+// advisoryFromStatus returns the advisory of a container group that is not available, or that is
+// impacted by a service incident.
+func advisoryFromStatus(props *resourcehealth.AvailabilityStatusProperties) (containerGroupAdvisory, bool) {
+	var incidents []string
+	maintenance := false
+	for _, incident := range serviceIncidents(props) {
+		incidents = append(incidents, incident.title)
+		maintenance = maintenance || strings.EqualFold(incident.incidentType, "Maintenance")
+	}
+
+	advisory := containerGroupAdvisory{}
+	if props.OccurredTime != nil {
+		advisory.since = props.OccurredTime.Time
+	}
+	switch props.AvailabilityState {
+	case resourcehealth.AvailabilityStateValuesAvailable, "":
+		if len(incidents) == 0 {
+			return advisory, false
+		}
+		advisory.available = true
+		advisory.reason = advisoryReasonServiceIssue
+		if maintenance {
+			advisory.reason = advisoryReasonPlannedMaintenance
+		}
+		advisory.message = strings.Join(incidents, "; ")
+		return advisory, true
+	}
+
+	advisory.reason = string(props.AvailabilityState)
+	if (props.ReasonType != nil && *props.ReasonType == string(resourcehealth.ReasonTypeValuesPlanned)) ||
+		(props.HealthEventCategory != nil && *props.HealthEventCategory == string(resourcehealth.ReasonTypeValuesPlanned)) {
+		advisory.reason = advisoryReasonPlannedMaintenance
+	}
+	advisory.message = fmt.Sprintf("container group is %s", strings.ToLower(string(props.AvailabilityState)))
+	if props.Summary != nil && *props.Summary != "" {
+		advisory.message = *props.Summary
+	} else if props.Title != nil && *props.Title != "" {
+		advisory.message = *props.Title
+	}
+	if props.ResolutionETA != nil {
+		advisory.message += fmt.Sprintf(" (expected to be resolved by %s)", props.ResolutionETA.Time.UTC().Format(time.RFC3339))
+	}
+	if len(incidents) > 0 {
+		advisory.message += ": " + strings.Join(incidents, "; ")
+	}
+	return advisory, true
+}
+
+// serviceIncidents returns the unresolved service incidents impacting a resource by correlation ID.
+func serviceIncidents(props *resourcehealth.AvailabilityStatusProperties) map[string]serviceIncident {
+	incidents := make(map[string]serviceIncident)
+	if props.ServiceImpactingEvents == nil {
+		return incidents
+	}
+	for _, event := range *props.ServiceImpactingEvents {
+		if event.CorrelationID == nil || event.IncidentProperties == nil || event.IncidentProperties.Title == nil {
+			continue
+		}
+		if event.Status != nil && event.Status.Value != nil && strings.EqualFold(*event.Status.Value, "Resolved") {
+			continue
+		}
+		incident := serviceIncident{incidentType: "Service issue", title: *event.IncidentProperties.Title}
+		if event.IncidentProperties.IncidentType != nil {
+			incident.incidentType = *event.IncidentProperties.IncidentType
+		}
+		if event.IncidentProperties.Region != nil {
+			incident.region = *event.IncidentProperties.Region
+		}
+		incidents[*event.CorrelationID] = incident
+	}
+	return incidents
+}
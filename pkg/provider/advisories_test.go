@@ -0,0 +1,99 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/services/resourcehealth/mgmt/2020-05-01/resourcehealth"
+	"github.com/Azure/go-autorest/autorest/date"
+	"github.com/golang/mock/gomock"
+	testsutil "github.com/virtual-kubelet/azure-aci/pkg/tests"
+	"github.com/virtual-kubelet/node-cli/manager"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/record"
+)
+
+func availabilityStatus(cgName string, props resourcehealth.AvailabilityStatusProperties) resourcehealth.AvailabilityStatus {
+	id := "/subscriptions/sub/resourceGroups/vk-rg/providers/Microsoft.ContainerInstance/containerGroups/" + cgName +
+		"/providers/Microsoft.ResourceHealth/availabilityStatuses/current"
+	return resourcehealth.AvailabilityStatus{ID: &id, Properties: &props}
+}
+
+func TestRefreshAdvisories(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	web := testsutil.CreatePodObj("web", "ns")
+	api := testsutil.CreatePodObj("api", "ns")
+	podLister := NewMockPodLister(mockCtrl)
+	podLister.EXPECT().List(labels.Everything()).Return([]*v1.Pod{web, api}, nil).AnyTimes()
+	rm, err := manager.NewResourceManager(podLister, nil, nil, newServiceLister(), nil, nil)
+	if err != nil {
+		t.Fatal("Unable to prepare the mocks for resourceManager", err)
+	}
+
+	planned, summary, title, region, maintenance, correlationID := "Planned", "The container group is being moved for maintenance", "Planned maintenance of Azure Container Instances", "West US", "Maintenance", "incident-1"
+	occurred := date.Time{Time: time.Now().Add(-time.Minute).Truncate(time.Second)}
+	statuses := []resourcehealth.AvailabilityStatus{
+		availabilityStatus("ns-web", resourcehealth.AvailabilityStatusProperties{
+			AvailabilityState: resourcehealth.AvailabilityStateValuesUnavailable,
+			ReasonType:        &planned,
+			Summary:           &summary,
+			OccurredTime:      &occurred,
+		}),
+		availabilityStatus("ns-api", resourcehealth.AvailabilityStatusProperties{
+			AvailabilityState: resourcehealth.AvailabilityStateValuesAvailable,
+			ServiceImpactingEvents: &[]resourcehealth.ServiceImpactingEvent{{
+				CorrelationID:      &correlationID,
+				IncidentProperties: &resourcehealth.ServiceImpactingEventIncidentProperties{Title: &title, Region: &region, IncidentType: &maintenance},
+			}},
+		}),
+		availabilityStatus("ns-other", resourcehealth.AvailabilityStatusProperties{AvailabilityState: resourcehealth.AvailabilityStateValuesDegraded}),
+	}
+	aciMocks := createNewACIMock()
+	aciMocks.MockListAvailabilityStatuses = func(ctx context.Context, resourceGroup string) (*[]resourcehealth.AvailabilityStatus, error) {
+		return &statuses, nil
+	}
+
+	recorder := record.NewFakeRecorder(10)
+	p := &ACIProvider{azClientsAPIs: aciMocks, resourceManager: rm, resourceGroup: "vk-rg", nodeName: "vk", eventRecorder: recorder}
+	p.refreshAdvisories(context.Background())
+
+	assert.Assert(t, is.Len(recorder.Events, 3))
+	events := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		events[<-recorder.Events] = true
+	}
+	assert.Check(t, events["Warning MaintenanceScheduled ACIP-031: Maintenance in West US impacting container groups of the node: "+title], "events: %v", events)
+	assert.Check(t, events["Warning ContainerGroupPlannedMaintenance ACIP-031: "+summary], "events: %v", events)
+	assert.Check(t, events["Warning ContainerGroupPlannedMaintenance ACIP-031: "+title], "events: %v", events)
+
+	condition, ok := p.advisoryCondition("ns", "web")
+	assert.Assert(t, ok)
+	assert.Check(t, is.Equal(podConditionContainerGroupAvailable, condition.Type))
+	assert.Check(t, is.Equal(v1.ConditionFalse, condition.Status))
+	assert.Check(t, is.Equal(advisoryReasonPlannedMaintenance, condition.Reason))
+	assert.Check(t, condition.LastTransitionTime.Time.Equal(occurred.Time))
+
+	condition, ok = p.advisoryCondition("ns", "api")
+	assert.Assert(t, ok)
+	assert.Check(t, is.Equal(v1.ConditionTrue, condition.Status), "upcoming maintenance should not make the container group unavailable")
+
+	p.refreshAdvisories(context.Background())
+	assert.Check(t, is.Len(recorder.Events, 0), "unchanged advisories should not be published again")
+
+	statuses = statuses[:1]
+	p.refreshAdvisories(context.Background())
+	assert.Assert(t, is.Len(recorder.Events, 1))
+	assert.Check(t, is.Equal("Normal ContainerGroupAvailable container group is no longer impacted by maintenance or service issues", <-recorder.Events))
+	_, ok = p.advisoryCondition("ns", "api")
+	assert.Check(t, !ok)
+}
@@ -0,0 +1,298 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	"github.com/Azure/azure-sdk-for-go/services/preview/monitor/mgmt/2021-07-01-preview/insights"
+	"github.com/Azure/azure-sdk-for-go/services/resourcehealth/mgmt/2020-05-01/resourcehealth"
+	client2 "github.com/virtual-kubelet/azure-aci/pkg/client"
+	"github.com/virtual-kubelet/azure-aci/pkg/metrics"
+	"github.com/virtual-kubelet/virtual-kubelet/node/api"
+)
+
+const (
+	defaultMaxConcurrentARMReads  = 32
+	defaultMaxConcurrentARMWrites = 16
+)
+
+// armNamespaceKey is the context key of the namespace ARM operations are attributed to.
+type armNamespaceKey struct{}
+
+// withARMNamespace attributes the ARM operations of the context to a namespace, for the calls whose
+// arguments do not name it, e.g. the deletion of a container group.
+func withARMNamespace(ctx context.Context, namespace string) context.Context {
+	return context.WithValue(ctx, armNamespaceKey{}, namespace)
+}
+
+func armNamespace(ctx context.Context) string {
+	namespace, _ := ctx.Value(armNamespaceKey{}).(string)
+	return namespace
+}
+
+// fairSemaphore caps the number of concurrent operations. Operations over the cap are queued per
+// namespace and the free slots are handed to the namespaces in turn, so a burst of pods in one
+// namespace does not starve the others. A namespace gets as many slots in a row as its share, 1 by
+// default.
+type fairSemaphore struct {
+	kind     string
+	capacity int
+	shares   map[string]int
+
+	mu       sync.Mutex
+	inFlight int
+	queued   int
+	waiters  map[string][]chan struct{}
+	// turns lists the namespaces with waiters in the order they get the next free slot.
+	turns []string
+	// granted counts the slots handed in a row to the namespace whose turn it is.
+	granted int
+}
+
+func newFairSemaphore(kind string, capacity int, shares map[string]int) *fairSemaphore {
+	return &fairSemaphore{
+		kind:     kind,
+		capacity: capacity,
+		shares:   shares,
+		waiters:  make(map[string][]chan struct{}),
+	}
+}
+
+func (s *fairSemaphore) share(namespace string) int {
+	if share, ok := s.shares[namespace]; ok && share > 0 {
+		return share
+	}
+	return 1
+}
+
+// acquire waits for a free slot. A zero capacity means no limit.
+func (s *fairSemaphore) acquire(ctx context.Context, namespace string) error {
+	if s == nil || s.capacity <= 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	if s.inFlight < s.capacity && s.queued == 0 {
+		s.inFlight++
+		s.report()
+		s.mu.Unlock()
+		return nil
+	}
+
+	ready := make(chan struct{})
+	if len(s.waiters[namespace]) == 0 {
+		s.turns = append(s.turns, namespace)
+	}
+	s.waiters[namespace] = append(s.waiters[namespace], ready)
+	s.queued++
+	s.report()
+	s.reportNamespace(namespace)
+	s.mu.Unlock()
+
+	start := time.Now()
+	select {
+	case <-ready:
+		metrics.ObserveARMOperationWait(s.kind, time.Since(start))
+		metrics.AddARMNamespaceWait(s.kind, namespace, time.Since(start))
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.removeWaiter(namespace, ready) {
+			s.report()
+			s.reportNamespace(namespace)
+			return ctx.Err()
+		}
+		// The slot was handed over while giving up, pass it on.
+		s.releaseLocked()
+		return ctx.Err()
+	}
+}
+
+func (s *fairSemaphore) release() {
+	if s == nil || s.capacity <= 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.releaseLocked()
+}
+
+// releaseLocked hands the slot to the first waiter of the namespace whose turn it is. The turn
+// passes to the next namespace once the namespace got its share of slots in a row.
+func (s *fairSemaphore) releaseLocked() {
+	defer s.report()
+
+	if len(s.turns) == 0 {
+		s.inFlight--
+		return
+	}
+
+	namespace := s.turns[0]
+	ready := s.waiters[namespace][0]
+	s.waiters[namespace] = s.waiters[namespace][1:]
+	s.granted++
+	if len(s.waiters[namespace]) == 0 {
+		delete(s.waiters, namespace)
+		s.turns = s.turns[1:]
+		s.granted = 0
+	} else if s.granted >= s.share(namespace) {
+		s.turns = append(s.turns[1:], namespace)
+		s.granted = 0
+	}
+	s.queued--
+	s.reportNamespace(namespace)
+	close(ready)
+}
+
+func (s *fairSemaphore) removeWaiter(namespace string, ready chan struct{}) bool {
+	waiters := s.waiters[namespace]
+	for i := range waiters {
+		if waiters[i] != ready {
+			continue
+		}
+		s.waiters[namespace] = append(waiters[:i], waiters[i+1:]...)
+		s.queued--
+		if len(s.waiters[namespace]) == 0 {
+			delete(s.waiters, namespace)
+			for j := range s.turns {
+				if s.turns[j] == namespace {
+					if j == 0 {
+						s.granted = 0
+					}
+					s.turns = append(s.turns[:j], s.turns[j+1:]...)
+					break
+				}
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func (s *fairSemaphore) report() {
+	metrics.SetARMOperations(s.kind, s.queued, s.inFlight)
+}
+
+func (s *fairSemaphore) reportNamespace(namespace string) {
+	metrics.SetARMNamespaceOperationsQueued(s.kind, namespace, len(s.waiters[namespace]))
+}
+
+// armLimitedClient caps the concurrent ARM reads and writes of the provider, so a burst of pod
+// creations queues instead of opening hundreds of simultaneous ARM calls and getting throttled.
+// Queued writes are handed out by the write shares of the namespaces.
+type armLimitedClient struct {
+	client2.AzClientsInterface
+	reads  *fairSemaphore
+	writes *fairSemaphore
+}
+
+func newARMLimitedClient(azAPIs client2.AzClientsInterface, maxReads, maxWrites int, writeShares map[string]int) *armLimitedClient {
+	return &armLimitedClient{
+		AzClientsInterface: azAPIs,
+		reads:              newFairSemaphore(metrics.ARMOperationRead, maxReads, nil),
+		writes:             newFairSemaphore(metrics.ARMOperationWrite, maxWrites, writeShares),
+	}
+}
+
+func (c *armLimitedClient) GetContainerGroup(ctx context.Context, resourceGroup, containerGroupName string) (*client2.ContainerGroupWrapper, error) {
+	if err := c.reads.acquire(ctx, ""); err != nil {
+		return nil, err
+	}
+	defer c.reads.release()
+	return c.AzClientsInterface.GetContainerGroup(ctx, resourceGroup, containerGroupName)
+}
+
+func (c *armLimitedClient) CreateContainerGroup(ctx context.Context, resourceGroup, podNS, podName string, cg *client2.ContainerGroupWrapper) error {
+	if err := c.writes.acquire(ctx, podNS); err != nil {
+		return err
+	}
+	defer c.writes.release()
+	return c.AzClientsInterface.CreateContainerGroup(ctx, resourceGroup, podNS, podName, cg)
+}
+
+func (c *armLimitedClient) GetContainerGroupInfo(ctx context.Context, resourceGroup, namespace, name, nodeName string) (*azaci.ContainerGroup, error) {
+	if err := c.reads.acquire(ctx, namespace); err != nil {
+		return nil, err
+	}
+	defer c.reads.release()
+	return c.AzClientsInterface.GetContainerGroupInfo(ctx, resourceGroup, namespace, name, nodeName)
+}
+
+func (c *armLimitedClient) GetContainerGroupListResult(ctx context.Context, resourceGroup string) (*[]azaci.ContainerGroup, error) {
+	if err := c.reads.acquire(ctx, ""); err != nil {
+		return nil, err
+	}
+	defer c.reads.release()
+	return c.AzClientsInterface.GetContainerGroupListResult(ctx, resourceGroup)
+}
+
+func (c *armLimitedClient) ListContainerGroupsByNodeName(ctx context.Context, resourceGroup, nodeName string) (*[]azaci.ContainerGroup, error) {
+	if err := c.reads.acquire(ctx, ""); err != nil {
+		return nil, err
+	}
+	defer c.reads.release()
+	return c.AzClientsInterface.ListContainerGroupsByNodeName(ctx, resourceGroup, nodeName)
+}
+
+func (c *armLimitedClient) ListCapabilities(ctx context.Context, region string) (*[]azaci.Capabilities, error) {
+	if err := c.reads.acquire(ctx, ""); err != nil {
+		return nil, err
+	}
+	defer c.reads.release()
+	return c.AzClientsInterface.ListCapabilities(ctx, region)
+}
+
+func (c *armLimitedClient) DeleteContainerGroup(ctx context.Context, resourceGroup, cgName string) error {
+	if err := c.writes.acquire(ctx, armNamespace(ctx)); err != nil {
+		return err
+	}
+	defer c.writes.release()
+	return c.AzClientsInterface.DeleteContainerGroup(ctx, resourceGroup, cgName)
+}
+
+func (c *armLimitedClient) UpdateContainerGroupTags(ctx context.Context, resourceGroup, cgName string, tags map[string]*string) error {
+	if err := c.writes.acquire(ctx, armNamespace(ctx)); err != nil {
+		return err
+	}
+	defer c.writes.release()
+	return c.AzClientsInterface.UpdateContainerGroupTags(ctx, resourceGroup, cgName, tags)
+}
+
+func (c *armLimitedClient) ListLogs(ctx context.Context, resourceGroup, cgName, containerName string, opts api.ContainerLogOpts) (*string, error) {
+	if err := c.reads.acquire(ctx, ""); err != nil {
+		return nil, err
+	}
+	defer c.reads.release()
+	return c.AzClientsInterface.ListLogs(ctx, resourceGroup, cgName, containerName, opts)
+}
+
+func (c *armLimitedClient) ExecuteContainerCommand(ctx context.Context, resourceGroup, cgName, containerName string, containerReq azaci.ContainerExecRequest) (*azaci.ContainerExecResponse, error) {
+	if err := c.writes.acquire(ctx, armNamespace(ctx)); err != nil {
+		return nil, err
+	}
+	defer c.writes.release()
+	return c.AzClientsInterface.ExecuteContainerCommand(ctx, resourceGroup, cgName, containerName, containerReq)
+}
+
+func (c *armLimitedClient) ListAvailabilityStatuses(ctx context.Context, resourceGroup string) (*[]resourcehealth.AvailabilityStatus, error) {
+	if err := c.reads.acquire(ctx, ""); err != nil {
+		return nil, err
+	}
+	defer c.reads.release()
+	return c.AzClientsInterface.ListAvailabilityStatuses(ctx, resourceGroup)
+}
+
+func (c *armLimitedClient) GetContainerGroupMetrics(ctx context.Context, resourceGroup, containerGroupName string, metricNames []string, start, end time.Time) (*insights.Response, error) {
+	if err := c.reads.acquire(ctx, ""); err != nil {
+		return nil, err
+	}
+	defer c.reads.release()
+	return c.AzClientsInterface.GetContainerGroupMetrics(ctx, resourceGroup, containerGroupName, metricNames, start, end)
+}
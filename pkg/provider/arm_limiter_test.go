@@ -0,0 +1,101 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func waitForQueued(t *testing.T, s *fairSemaphore, queued int) {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		s.mu.Lock()
+		n := s.queued
+		s.mu.Unlock()
+		if n == queued {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d queued operations", queued)
+}
+
+func TestFairSemaphoreAlternatesNamespaces(t *testing.T) {
+	s := newFairSemaphore("write", 1, nil)
+	assert.NilError(t, s.acquire(context.Background(), "busy"))
+
+	granted := make(chan string, 4)
+	for i, ns := range []string{"busy", "busy", "busy", "quiet"} {
+		ns := ns
+		go func() {
+			if err := s.acquire(context.Background(), ns); err == nil {
+				granted <- ns
+			}
+		}()
+		waitForQueued(t, s, i+1)
+	}
+
+	var order []string
+	for i := 0; i < 4; i++ {
+		s.release()
+		order = append(order, <-granted)
+	}
+	assert.Check(t, is.DeepEqual([]string{"busy", "quiet", "busy", "busy"}, order), "namespaces should get slots in turn")
+
+	s.release()
+	assert.Check(t, is.Equal(0, s.inFlight))
+}
+
+func TestFairSemaphoreShares(t *testing.T) {
+	s := newFairSemaphore("write", 1, map[string]int{"jobs": 2})
+	assert.NilError(t, s.acquire(context.Background(), "jobs"))
+
+	granted := make(chan string, 5)
+	for i, ns := range []string{"jobs", "jobs", "jobs", "web", "web"} {
+		ns := ns
+		go func() {
+			if err := s.acquire(context.Background(), ns); err == nil {
+				granted <- ns
+			}
+		}()
+		waitForQueued(t, s, i+1)
+	}
+
+	var order []string
+	for i := 0; i < 5; i++ {
+		s.release()
+		order = append(order, <-granted)
+	}
+	assert.Check(t, is.DeepEqual([]string{"jobs", "jobs", "web", "jobs", "web"}, order), "namespaces should get their share of slots in a row")
+
+	s.release()
+	assert.Check(t, is.Equal(0, s.inFlight))
+	assert.Check(t, is.Equal("jobs", armNamespace(withARMNamespace(context.Background(), "jobs"))))
+	assert.Check(t, is.Equal("", armNamespace(context.Background())))
+}
+
+func TestFairSemaphoreCancelledWaiter(t *testing.T) {
+	s := newFairSemaphore("read", 1, nil)
+	assert.NilError(t, s.acquire(context.Background(), "ns"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error)
+	go func() {
+		errs <- s.acquire(ctx, "ns")
+	}()
+	waitForQueued(t, s, 1)
+	cancel()
+	assert.Check(t, is.Equal(context.Canceled, <-errs))
+	assert.Check(t, is.Equal(0, s.queued), "cancelled operation should leave the queue")
+
+	s.release()
+	assert.Check(t, is.Equal(0, s.inFlight))
+	assert.NilError(t, newFairSemaphore("read", 0, nil).acquire(context.Background(), "ns"), "zero capacity should not limit")
+}
@@ -0,0 +1,237 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"fmt"
+	"strings"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// blobVolumeImage runs the Azure CLI downloading the blob containers of the Azure Blob CSI
+	// volumes into the container group.
+	blobVolumeImage = "mcr.microsoft.com/azure-cli:2.40.0"
+	// blobVolumeMountPath is where the download init containers mount the volume.
+	blobVolumeMountPath = "/mnt/blob"
+	// blobVolumeInitContainerPrefix prefixes the names of the download init containers.
+	blobVolumeInitContainerPrefix = "blob-"
+
+	// The volume attributes of the Azure Blob CSI driver, matched case insensitively like the driver
+	// does. https://github.com/kubernetes-sigs/blob-csi-driver/blob/master/docs/driver-parameters.md
+	blobContainerName         = "containerName"
+	blobStorageAccount        = "storageAccount"
+	blobStorageEndpointSuffix = "storageEndpointSuffix"
+	blobSecretName            = "secretName"
+	blobSecretNamespace       = "secretNamespace"
+	blobDefaultSecretNameFmt  = "azure-storage-account-%s-secret"
+	maxACIContainerNameLength = 63
+)
+
+// blobVolumeSource is the blob container of an Azure Blob CSI volume, with the credentials to
+// download it.
+type blobVolumeSource struct {
+	container      string
+	account        string
+	key            string
+	endpointSuffix string
+}
+
+// blobAttribute returns a volume attribute of the Azure Blob CSI driver.
+func blobAttribute(attributes map[string]string, key string) string {
+	for k, v := range attributes {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return ""
+}
+
+// isBlobVolume reports whether a pod volume is an inline volume of the Azure Blob CSI driver.
+func isBlobVolume(volume *v1.Volume) bool {
+	return volume.CSI != nil && volume.CSI.Driver == AzureBlobDriverName
+}
+
+// getBlobVolumeSource resolves the blob container and the storage account key of a volume of the
+// Azure Blob CSI driver. Dynamically provisioned volumes carry the account and the container in their
+// handle, formatted as rg#account#container#..., and the key in the secret the driver created.
+func (p *ACIProvider) getBlobVolumeSource(pod *v1.Pod, volumeName string, attributes map[string]string, handle string, secretRef *v1.SecretReference) (*blobVolumeSource, error) {
+	source := &blobVolumeSource{
+		container:      blobAttribute(attributes, blobContainerName),
+		account:        blobAttribute(attributes, blobStorageAccount),
+		endpointSuffix: blobAttribute(attributes, blobStorageEndpointSuffix),
+	}
+	if parts := strings.Split(handle, "#"); len(parts) > 2 {
+		if source.account == "" {
+			source.account = parts[1]
+		}
+		if source.container == "" {
+			source.container = parts[2]
+		}
+	}
+	if source.container == "" {
+		return nil, errdefs.InvalidInputf("container name for Azure Blob volume %s of pod %s cannot be empty", volumeName, pod.Name)
+	}
+
+	secretName, secretNamespace := blobAttribute(attributes, blobSecretName), blobAttribute(attributes, blobSecretNamespace)
+	if secretRef != nil {
+		secretName, secretNamespace = secretRef.Name, secretRef.Namespace
+	}
+	if secretName == "" && source.account != "" {
+		secretName = fmt.Sprintf(blobDefaultSecretNameFmt, source.account)
+	}
+	if secretNamespace == "" {
+		secretNamespace = pod.Namespace
+	}
+	if secretName == "" {
+		return nil, errdefs.InvalidInputf("secret name for Azure Blob volume %s of pod %s cannot be empty", volumeName, pod.Name)
+	}
+
+	secret, err := p.resourceManager.GetSecret(secretName, secretNamespace)
+	if err != nil || secret == nil {
+		return nil, fmt.Errorf("the secret %s/%s for Azure Blob volume %s is not found", secretNamespace, secretName, volumeName)
+	}
+	data := secretData(secret)
+	if account := string(data[azureFileStorageAccountName]); account != "" {
+		source.account = account
+	}
+	source.key = string(data[azureFileStorageAccountKey])
+	if source.account == "" || source.key == "" {
+		return nil, errdefs.InvalidInputf("the secret %s/%s for Azure Blob volume %s must have the %s and %s keys", secretNamespace, secretName, volumeName, azureFileStorageAccountName, azureFileStorageAccountKey)
+	}
+	return source, nil
+}
+
+// getInlineBlobVolumeSource resolves an inline volume of the Azure Blob CSI driver.
+func (p *ACIProvider) getInlineBlobVolumeSource(pod *v1.Pod, volume *v1.Volume) (*blobVolumeSource, error) {
+	var secretRef *v1.SecretReference
+	if ref := volume.CSI.NodePublishSecretRef; ref != nil {
+		secretRef = &v1.SecretReference{Name: ref.Name}
+	}
+	return p.getBlobVolumeSource(pod, volume.Name, volume.CSI.VolumeAttributes, "", secretRef)
+}
+
+// getBlobPersistentVolumeSource resolves a persistent volume of the Azure Blob CSI driver.
+func (p *ACIProvider) getBlobPersistentVolumeSource(pod *v1.Pod, volumeName string, pv *v1.PersistentVolume) (*blobVolumeSource, error) {
+	return p.getBlobVolumeSource(pod, volumeName, pv.Spec.CSI.VolumeAttributes, pv.Spec.CSI.VolumeHandle, pv.Spec.CSI.NodeStageSecretRef)
+}
+
+// getBlobVolume maps a volume of the Azure Blob CSI driver. ACI has no blob volume type and cannot
+// mount FUSE file systems, so the volume is an emptyDir the blob container is downloaded into by an
+// init container before the containers of the pod start. Writes would never reach the blob
+// container, so every mount of the volume must be read-only.
+func (p *ACIProvider) getBlobVolume(pod *v1.Pod, volumeName string) (*azaci.Volume, error) {
+	if p.isWindows() {
+		return nil, p.unsupportedBlobVolume(pod, volumeName, "Windows pods have no init containers to download the blob container")
+	}
+	for _, containers := range [][]v1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for _, container := range containers {
+			for _, mount := range container.VolumeMounts {
+				if mount.Name == volumeName && !mount.ReadOnly {
+					return nil, p.unsupportedBlobVolume(pod, volumeName, fmt.Sprintf("container %s mounts it writable, but the blob container is downloaded when the pod starts and changes are not uploaded back. Mount it with readOnly: true", container.Name))
+				}
+			}
+		}
+	}
+	return &azaci.Volume{
+		Name:     &volumeName,
+		EmptyDir: map[string]interface{}{},
+	}, nil
+}
+
+// unsupportedBlobVolume reports an Azure Blob CSI volume the pod cannot use.
+func (p *ACIProvider) unsupportedBlobVolume(pod *v1.Pod, volumeName, reason string) error {
+	msg := fmt.Sprintf("Azure Blob volume %s of pod %s is not supported: %s", volumeName, pod.Name, reason)
+	p.recordEvent(pod, v1.EventTypeWarning, "UnsupportedVolume", "%s", msg)
+	return errdefs.InvalidInput(msg)
+}
+
+// getBlobVolumeInitContainers returns the init containers downloading the Azure Blob CSI volumes of
+// the pod, which run before the init containers of the pod.
+func (p *ACIProvider) getBlobVolumeInitContainers(pod *v1.Pod) ([]azaci.InitContainerDefinition, error) {
+	names := make(map[string]bool, len(pod.Spec.InitContainers)+len(pod.Spec.Containers))
+	for _, containers := range [][]v1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for _, container := range containers {
+			names[container.Name] = true
+		}
+	}
+
+	var initContainers []azaci.InitContainerDefinition
+	for i := range pod.Spec.Volumes {
+		volume := &pod.Spec.Volumes[i]
+		var source *blobVolumeSource
+		var err error
+		switch {
+		case isBlobVolume(volume):
+			source, err = p.getInlineBlobVolumeSource(pod, volume)
+		case volume.PersistentVolumeClaim != nil:
+			pv, pvErr := p.getBoundPersistentVolume(pod, volume.PersistentVolumeClaim.ClaimName)
+			if pvErr != nil {
+				return nil, pvErr
+			}
+			if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != AzureBlobDriverName {
+				continue
+			}
+			source, err = p.getBlobPersistentVolumeSource(pod, volume.Name, pv)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		name := blobVolumeInitContainerName(volume.Name)
+		if names[name] {
+			return nil, errdefs.InvalidInputf("Azure Blob volume %s of pod %s is downloaded by init container %s, which is also a container of the pod", volume.Name, pod.Name, name)
+		}
+		names[name] = true
+		initContainers = append(initContainers, newBlobVolumeInitContainer(name, volume.Name, source))
+	}
+	return initContainers, nil
+}
+
+// blobVolumeInitContainerName names the init container downloading a volume, within the length ACI
+// allows.
+func blobVolumeInitContainerName(volumeName string) string {
+	name := blobVolumeInitContainerPrefix + volumeName
+	if len(name) > maxACIContainerNameLength {
+		name = strings.TrimRight(name[:maxACIContainerNameLength], "-.")
+	}
+	return name
+}
+
+// newBlobVolumeInitContainer downloads the blob container into the volume with the Azure CLI. The
+// account key is passed as a secure environment variable, so ACI never returns it.
+func newBlobVolumeInitContainer(name, volumeName string, source *blobVolumeSource) azaci.InitContainerDefinition {
+	command := []string{"az", "storage", "blob", "download-batch",
+		"--source", source.container,
+		"--destination", blobVolumeMountPath,
+		"--no-progress", "--only-show-errors"}
+	if source.endpointSuffix != "" {
+		command = append(command, "--blob-endpoint", fmt.Sprintf("https://%s.blob.%s", source.account, source.endpointSuffix))
+	}
+
+	image := blobVolumeImage
+	mountPath := blobVolumeMountPath
+	accountEnv, keyEnv := "AZURE_STORAGE_ACCOUNT", "AZURE_STORAGE_KEY"
+	account, key := source.account, source.key
+	return azaci.InitContainerDefinition{
+		Name: &name,
+		InitContainerPropertiesDefinition: &azaci.InitContainerPropertiesDefinition{
+			Image:   &image,
+			Command: &command,
+			VolumeMounts: &[]azaci.VolumeMount{
+				{Name: &volumeName, MountPath: &mountPath},
+			},
+			EnvironmentVariables: &[]azaci.EnvironmentVariable{
+				{Name: &accountEnv, Value: &account},
+				{Name: &keyEnv, SecureValue: &key},
+			},
+		},
+	}
+}
@@ -0,0 +1,91 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	testsutil "github.com/virtual-kubelet/azure-aci/pkg/tests"
+	"github.com/virtual-kubelet/node-cli/manager"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestGetBlobVolumeInitContainers(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	secretLister := NewMockSecretLister(mockCtrl)
+	mockSecretNamespaceLister := NewMockSecretNamespaceLister(mockCtrl)
+	secretLister.EXPECT().Secrets(podNamespace).Return(mockSecretNamespaceLister).AnyTimes()
+	mockSecretNamespaceLister.EXPECT().Get("blob-secret").Return(&v1.Secret{
+		Data: map[string][]byte{
+			azureFileStorageAccountName: []byte("account"),
+			azureFileStorageAccountKey:  []byte("key"),
+		},
+	}, nil).AnyTimes()
+
+	resourceManager, err := manager.NewResourceManager(
+		NewMockPodLister(mockCtrl),
+		secretLister,
+		NewMockConfigMapLister(mockCtrl),
+		NewMockServiceLister(mockCtrl),
+		NewMockPersistentVolumeClaimLister(mockCtrl),
+		NewMockPersistentVolumeLister(mockCtrl))
+	if err != nil {
+		t.Fatal("Unable to prepare the mocks for resourceManager", err)
+	}
+
+	provider, err := createTestProvider(createNewACIMock(), resourceManager)
+	if err != nil {
+		t.Fatal("Unable to create test provider", err)
+	}
+
+	pod := testsutil.CreatePodObj(podName, podNamespace)
+	pod.Spec.Volumes = []v1.Volume{
+		{Name: "data", VolumeSource: v1.VolumeSource{CSI: &v1.CSIVolumeSource{
+			Driver: AzureBlobDriverName,
+			VolumeAttributes: map[string]string{
+				"ContainerName":         "models",
+				"storageEndpointSuffix": "core.chinacloudapi.cn",
+			},
+			NodePublishSecretRef: &v1.LocalObjectReference{Name: "blob-secret"},
+		}}},
+		{Name: emptyVolumeName, VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}}},
+	}
+	pod.Spec.Containers[0].VolumeMounts = []v1.VolumeMount{{Name: "data", MountPath: "/data", ReadOnly: true}}
+
+	volume, err := provider.getVolume(context.Background(), pod, &pod.Spec.Volumes[0])
+	assert.NilError(t, err)
+	assert.Check(t, volume.EmptyDir != nil, "blob volumes should be downloaded into an empty dir")
+
+	initContainers, err := provider.getBlobVolumeInitContainers(pod)
+	assert.NilError(t, err)
+	assert.Assert(t, is.Len(initContainers, 1))
+	initContainer := initContainers[0]
+	assert.Check(t, is.Equal("blob-data", *initContainer.Name))
+	assert.Check(t, is.Equal(blobVolumeImage, *initContainer.Image))
+	assert.Check(t, is.Equal("az storage blob download-batch --source models --destination /mnt/blob --no-progress --only-show-errors --blob-endpoint https://account.blob.core.chinacloudapi.cn", strings.Join(*initContainer.Command, " ")))
+	assert.Check(t, is.Equal("data", *(*initContainer.VolumeMounts)[0].Name))
+	envs := *initContainer.EnvironmentVariables
+	assert.Check(t, is.Equal("account", *envs[0].Value))
+	assert.Check(t, is.Equal("key", *envs[1].SecureValue), "the storage account key should be secure")
+	assert.Check(t, envs[1].Value == nil)
+
+	pod.Spec.InitContainers = []v1.Container{{Name: "blob-data"}}
+	_, err = provider.getBlobVolumeInitContainers(pod)
+	assert.Check(t, errdefs.IsInvalidInput(err), "init container names should not collide with the containers of the pod")
+}
+
+func TestBlobVolumeInitContainerName(t *testing.T) {
+	assert.Check(t, is.Equal("blob-data", blobVolumeInitContainerName("data")))
+	name := blobVolumeInitContainerName(strings.Repeat("a", 57) + "-" + strings.Repeat("b", 10))
+	assert.Check(t, is.Equal("blob-"+strings.Repeat("a", 57), name), "names should fit in 63 characters without a trailing dash")
+}
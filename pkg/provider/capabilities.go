@@ -0,0 +1,340 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	client2 "github.com/virtual-kubelet/azure-aci/pkg/client"
+	"github.com/virtual-kubelet/azure-aci/pkg/errcodes"
+	"github.com/virtual-kubelet/azure-aci/pkg/metrics"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	v1 "k8s.io/api/core/v1"
+)
+
+const defaultCapabilityRefreshInterval = 1 * time.Hour
+
+// Kinds of changes of the capabilities between two refreshes.
+const (
+	capabilityAdded     = "added"
+	capabilityRemoved   = "removed"
+	capabilityReduced   = "reduced"
+	capabilityIncreased = "increased"
+)
+
+// capabilityChangeReasons are the reasons of the node events for the kinds of changes.
+var capabilityChangeReasons = map[string]string{
+	capabilityAdded:     "CapabilityAdded",
+	capabilityRemoved:   "CapabilityRemoved",
+	capabilityReduced:   "CapabilityReduced",
+	capabilityIncreased: "CapabilityIncreased",
+}
+
+// capabilityKey indexes the capabilities by OS type and GPU SKU, the GPU SKU is empty for container
+// groups without GPU. The ACI API reports neither availability zones nor container group SKUs.
+type capabilityKey struct {
+	osType string
+	gpu    string
+}
+
+func newCapabilityKey(osType string, gpu azaci.GpuSku) capabilityKey {
+	return capabilityKey{osType: strings.ToLower(osType), gpu: strings.ToUpper(string(gpu))}
+}
+
+// capabilityLimits are the largest resources of a container group.
+type capabilityLimits struct {
+	maxCPU        float64
+	maxMemoryInGB float64
+	maxGPUCount   float64
+}
+
+// capabilityService indexes the ACI capabilities of the region, for pod admission, GPU selection
+// and node labeling. It is refreshed periodically, lookups use the last successful refresh.
+type capabilityService struct {
+	client client2.AzClientsInterface
+	region string
+
+	mu          sync.RWMutex
+	limits      map[capabilityKey]capabilityLimits
+	refreshedAt time.Time
+}
+
+func newCapabilityService(client client2.AzClientsInterface, region string) *capabilityService {
+	return &capabilityService{
+		client: client,
+		region: region,
+		limits: make(map[capabilityKey]capabilityLimits),
+	}
+}
+
+// refresh reloads the capabilities of the region. Capabilities without an OS type apply to every
+// OS. When several capabilities match a key, e.g. for public and private IP addresses, the largest
+// limits are kept so valid pods are never rejected.
+func (s *capabilityService) refresh(ctx context.Context) error {
+	capabilities, err := s.client.ListCapabilities(ctx, s.region)
+	metrics.RecordCapabilitiesRefresh(err, time.Now())
+	if err != nil {
+		return fmt.Errorf("unable to fetch the ACI capabilities for the location %s: %v", s.region, err)
+	}
+
+	limits := make(map[capabilityKey]capabilityLimits)
+	if capabilities != nil {
+		for _, capability := range *capabilities {
+			if capability.Location == nil || !strings.EqualFold(strings.ReplaceAll(*capability.Location, " ", ""), s.region) {
+				continue
+			}
+			osType, gpu := "", azaci.GpuSku("")
+			if capability.OsType != nil {
+				osType = *capability.OsType
+			}
+			if capability.Gpu != nil {
+				gpu = azaci.GpuSku(*capability.Gpu)
+			}
+
+			key := newCapabilityKey(osType, gpu)
+			current := limits[key]
+			if c := capability.Capabilities; c != nil {
+				if c.MaxCPU != nil && *c.MaxCPU > current.maxCPU {
+					current.maxCPU = *c.MaxCPU
+				}
+				if c.MaxMemoryInGB != nil && *c.MaxMemoryInGB > current.maxMemoryInGB {
+					current.maxMemoryInGB = *c.MaxMemoryInGB
+				}
+				if c.MaxGpuCount != nil && *c.MaxGpuCount > current.maxGPUCount {
+					current.maxGPUCount = *c.MaxGpuCount
+				}
+			}
+			limits[key] = current
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.limits = limits
+	s.refreshedAt = time.Now()
+	return nil
+}
+
+// lookup returns the limits of container groups of the OS with the GPU SKU.
+func (s *capabilityService) lookup(osType string, gpu azaci.GpuSku) (capabilityLimits, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if limits, ok := s.limits[newCapabilityKey(osType, gpu)]; ok {
+		return limits, true
+	}
+	limits, ok := s.limits[newCapabilityKey("", gpu)]
+	return limits, ok
+}
+
+// snapshot returns a copy of the limits of the last successful refresh, it is nil before the first
+// refresh.
+func (s *capabilityService) snapshot() map[capabilityKey]capabilityLimits {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.refreshedAt.IsZero() {
+		return nil
+	}
+	limits := make(map[capabilityKey]capabilityLimits, len(s.limits))
+	for key, l := range s.limits {
+		limits[key] = l
+	}
+	return limits
+}
+
+// gpuSKUs returns the GPU SKUs offered to the OS, in the order of knownGPUSKUs.
+func (s *capabilityService) gpuSKUs(osType string) []azaci.GpuSku {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var skus []azaci.GpuSku
+	for key := range s.limits {
+		if key.gpu == "" || (key.osType != "" && key.osType != strings.ToLower(osType)) {
+			continue
+		}
+		sku := azaci.GpuSku(key.gpu)
+		for _, known := range knownGPUSKUs {
+			if strings.EqualFold(string(known), key.gpu) {
+				sku = known
+			}
+		}
+		duplicate := false
+		for _, existing := range skus {
+			duplicate = duplicate || existing == sku
+		}
+		if !duplicate {
+			skus = append(skus, sku)
+		}
+	}
+
+	sort.SliceStable(skus, func(i, j int) bool {
+		ri, rj := gpuSKURank(skus[i]), gpuSKURank(skus[j])
+		if ri != rj {
+			return ri < rj
+		}
+		return skus[i] < skus[j]
+	})
+	return skus
+}
+
+// maxContainersPerGroup is the number of containers ACI allows in a container group, init
+// containers included.
+const maxContainersPerGroup = 60
+
+// validateContainerCount rejects container groups with more containers than ACI allows, counting
+// the containers added by the provider, since ACI fails them with an opaque error.
+func validateContainerCount(pod *v1.Pod, cg *azaci.ContainerGroupProperties) error {
+	containers, initContainers := 0, 0
+	if cg.Containers != nil {
+		containers = len(*cg.Containers)
+	}
+	if cg.InitContainers != nil {
+		initContainers = len(*cg.InitContainers)
+	}
+	if total := containers + initContainers; total > maxContainersPerGroup {
+		return errcodes.Wrap(errcodes.ExceedsCapabilities, errdefs.InvalidInputf("pod %s has %d containers (%d containers and %d init containers), but ACI allows at most %d containers per container group", pod.Name, total, containers, initContainers, maxContainersPerGroup))
+	}
+	return nil
+}
+
+// validateCapabilities rejects container groups larger than the region allows, before ACI rejects
+// them. Container groups are admitted when the capabilities have no limits for them.
+func (p *ACIProvider) validateCapabilities(pod *v1.Pod, containers []azaci.Container) error {
+	if p.capabilities == nil {
+		return nil
+	}
+
+	var cpu, memoryInGB float64
+	var gpuCount int32
+	var gpu azaci.GpuSku
+	for _, container := range containers {
+		if container.Resources == nil || container.Resources.Requests == nil {
+			continue
+		}
+		requests := container.Resources.Requests
+		if requests.CPU != nil {
+			cpu += *requests.CPU
+		}
+		if requests.MemoryInGB != nil {
+			memoryInGB += *requests.MemoryInGB
+		}
+		if requests.Gpu != nil && requests.Gpu.Count != nil {
+			gpuCount += *requests.Gpu.Count
+			gpu = requests.Gpu.Sku
+		}
+	}
+
+	limits, ok := p.capabilities.lookup(p.operatingSystem, gpu)
+	if !ok {
+		return nil
+	}
+	if limits.maxCPU > 0 && cpu > limits.maxCPU {
+		return errcodes.Wrap(errcodes.ExceedsCapabilities, errdefs.InvalidInputf("pod %s requests %g CPU, but ACI allows at most %g CPU per container group in region %s", pod.Name, cpu, limits.maxCPU, p.region))
+	}
+	if limits.maxMemoryInGB > 0 && memoryInGB > limits.maxMemoryInGB {
+		return errcodes.Wrap(errcodes.ExceedsCapabilities, errdefs.InvalidInputf("pod %s requests %gGB of memory, but ACI allows at most %gGB per container group in region %s", pod.Name, memoryInGB, limits.maxMemoryInGB, p.region))
+	}
+	if limits.maxGPUCount > 0 && float64(gpuCount) > limits.maxGPUCount {
+		return errcodes.Wrap(errcodes.ExceedsCapabilities, errdefs.InvalidInputf("pod %s requests %d %s GPUs, but ACI allows at most %g per container group in region %s", pod.Name, gpuCount, gpu, limits.maxGPUCount, p.region))
+	}
+	return nil
+}
+
+// capabilityChange is a difference of the capabilities of the region between two refreshes.
+type capabilityChange struct {
+	kind    string
+	key     capabilityKey
+	message string
+}
+
+func (k capabilityKey) String() string {
+	osType := k.osType
+	if osType == "" {
+		osType = "every OS"
+	}
+	if k.gpu == "" {
+		return fmt.Sprintf("container groups without GPU (%s)", osType)
+	}
+	return fmt.Sprintf("GPU SKU %s (%s)", k.gpu, osType)
+}
+
+// diffCapabilities returns the changes of the capabilities that apply to the OS, ordered by key.
+// Capabilities the region gained or lost change the GPU SKU labels of the node, lower limits reject
+// pods that were admitted before.
+func diffCapabilities(osType string, before, after map[capabilityKey]capabilityLimits) []capabilityChange {
+	osType = strings.ToLower(osType)
+	keys := make(map[capabilityKey]bool)
+	for key := range before {
+		keys[key] = true
+	}
+	for key := range after {
+		keys[key] = true
+	}
+	sorted := make([]capabilityKey, 0, len(keys))
+	for key := range keys {
+		if key.osType == "" || key.osType == osType {
+			sorted = append(sorted, key)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].gpu != sorted[j].gpu {
+			return sorted[i].gpu < sorted[j].gpu
+		}
+		return sorted[i].osType < sorted[j].osType
+	})
+
+	var changes []capabilityChange
+	for _, key := range sorted {
+		old, hadIt := before[key]
+		current, hasIt := after[key]
+		switch {
+		case !hadIt:
+			changes = append(changes, capabilityChange{kind: capabilityAdded, key: key,
+				message: fmt.Sprintf("%s are now offered, up to %s", key, current)})
+		case !hasIt:
+			changes = append(changes, capabilityChange{kind: capabilityRemoved, key: key,
+				message: fmt.Sprintf("%s are no longer offered, pods requiring them will fail", key)})
+		case current.maxCPU < old.maxCPU || current.maxMemoryInGB < old.maxMemoryInGB || current.maxGPUCount < old.maxGPUCount:
+			changes = append(changes, capabilityChange{kind: capabilityReduced, key: key,
+				message: fmt.Sprintf("%s are reduced from %s to %s, larger pods will be rejected", key, old, current)})
+		case current != old:
+			changes = append(changes, capabilityChange{kind: capabilityIncreased, key: key,
+				message: fmt.Sprintf("%s are increased from %s to %s", key, old, current)})
+		}
+	}
+	return changes
+}
+
+func (l capabilityLimits) String() string {
+	s := fmt.Sprintf("%g CPU and %gGB of memory", l.maxCPU, l.maxMemoryInGB)
+	if l.maxGPUCount > 0 {
+		s += fmt.Sprintf(" with %g GPUs", l.maxGPUCount)
+	}
+	return s
+}
+
+// reportCapabilityDrift publishes node events and metrics for the changes of the capabilities of
+// the region, so operators learn when workloads that were schedulable start failing. Nothing is
+// reported for the first refresh.
+func (p *ACIProvider) reportCapabilityDrift(ctx context.Context, before, after map[capabilityKey]capabilityLimits) {
+	if before == nil {
+		return
+	}
+	for _, change := range diffCapabilities(p.operatingSystem, before, after) {
+		metrics.RecordCapabilityChange(change.kind)
+		eventType := v1.EventTypeNormal
+		if change.kind == capabilityRemoved || change.kind == capabilityReduced {
+			eventType = v1.EventTypeWarning
+		}
+		log.G(ctx).Infof("ACI capabilities of region %s changed: %s", p.region, change.message)
+		p.recordNodeEvent(eventType, capabilityChangeReasons[change.kind], "ACI capabilities of region %s changed: %s", p.region, change.message)
+	}
+}
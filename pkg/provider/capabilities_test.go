@@ -0,0 +1,200 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	testsutil "github.com/virtual-kubelet/azure-aci/pkg/tests"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	"k8s.io/client-go/tools/record"
+)
+
+func capability(location, osType, gpu string, cpu, memoryInGB, gpuCount float64) azaci.Capabilities {
+	c := azaci.Capabilities{
+		Location: &location,
+		Capabilities: &azaci.CapabilitiesCapabilities{
+			MaxCPU:        &cpu,
+			MaxMemoryInGB: &memoryInGB,
+			MaxGpuCount:   &gpuCount,
+		},
+	}
+	if osType != "" {
+		c.OsType = &osType
+	}
+	if gpu != "" {
+		c.Gpu = &gpu
+	}
+	return c
+}
+
+func TestCapabilityService(t *testing.T) {
+	capabilities := []azaci.Capabilities{
+		capability("West US 2", "Linux", "", 4, 16, 0),
+		capability("West US 2", "Linux", "", 4, 14, 0),
+		capability("West US 2", "Windows", "", 2, 8, 0),
+		capability("West US 2", "Linux", "A100", 24, 220, 4),
+		capability("West US 2", "Linux", "V100", 6, 112, 4),
+		capability("West US 2", "", "T4", 8, 56, 2),
+		capability("West US 2", "Linux", "H100", 24, 220, 8),
+		capability("East US", "Linux", "K80", 6, 56, 4),
+	}
+	var listErr error
+	s := newCapabilityService(NewMockACIProvider(func(ctx context.Context, region string) (*[]azaci.Capabilities, error) {
+		return &capabilities, listErr
+	}), "westus2")
+
+	_, ok := s.lookup("Linux", "")
+	assert.Check(t, !ok, "nothing should be known before the first refresh")
+
+	assert.NilError(t, s.refresh(context.Background()))
+
+	limits, ok := s.lookup("linux", "")
+	assert.Check(t, ok)
+	assert.Check(t, is.Equal(float64(4), limits.maxCPU), "the largest limits should be kept")
+	assert.Check(t, is.Equal(float64(16), limits.maxMemoryInGB), "the largest limits should be kept")
+	assert.Check(t, is.Equal(float64(0), limits.maxGPUCount))
+	limits, ok = s.lookup("Windows", "")
+	assert.Check(t, ok)
+	assert.Check(t, is.Equal(float64(2), limits.maxCPU))
+	limits, ok = s.lookup("Windows", gpuSKUT4)
+	assert.Check(t, ok, "capabilities without an OS type should apply to every OS")
+	assert.Check(t, is.Equal(float64(2), limits.maxGPUCount))
+	_, ok = s.lookup("Linux", azaci.GpuSkuK80)
+	assert.Check(t, !ok, "capabilities of other regions should be ignored")
+
+	assert.Check(t, is.DeepEqual([]azaci.GpuSku{azaci.GpuSkuV100, gpuSKUT4, gpuSKUA100, "H100"}, s.gpuSKUs("Linux")))
+	assert.Check(t, is.DeepEqual([]azaci.GpuSku{gpuSKUT4}, s.gpuSKUs("Windows")))
+
+	listErr = errors.New("service unavailable")
+	assert.Check(t, s.refresh(context.Background()) != nil)
+	_, ok = s.lookup("Linux", "")
+	assert.Check(t, ok, "a failed refresh should keep the known capabilities")
+}
+
+func TestValidateCapabilities(t *testing.T) {
+	capabilities := []azaci.Capabilities{
+		capability("westus2", "Linux", "", 4, 16, 0),
+		capability("westus2", "Linux", "V100", 6, 112, 4),
+	}
+	p := &ACIProvider{
+		region:          "westus2",
+		operatingSystem: "Linux",
+		capabilities: newCapabilityService(NewMockACIProvider(func(ctx context.Context, region string) (*[]azaci.Capabilities, error) {
+			return &capabilities, nil
+		}), "westus2"),
+	}
+	assert.NilError(t, p.capabilities.refresh(context.Background()))
+
+	container := func(cpu, memoryInGB float64, gpuCount int32, gpu azaci.GpuSku) azaci.Container {
+		requests := &azaci.ResourceRequests{CPU: &cpu, MemoryInGB: &memoryInGB}
+		if gpuCount > 0 {
+			requests.Gpu = &azaci.GpuResource{Count: &gpuCount, Sku: gpu}
+		}
+		return azaci.Container{ContainerProperties: &azaci.ContainerProperties{Resources: &azaci.ResourceRequirements{Requests: requests}}}
+	}
+	pod := testsutil.CreatePodObj("pod", "ns")
+
+	cases := []struct {
+		name       string
+		containers []azaci.Container
+		wantErr    bool
+	}{
+		{"fits", []azaci.Container{container(2, 8, 0, ""), container(2, 8, 0, "")}, false},
+		{"too much CPU", []azaci.Container{container(3, 1, 0, ""), container(2, 1, 0, "")}, true},
+		{"too much memory", []azaci.Container{container(1, 17, 0, "")}, true},
+		{"GPU fits", []azaci.Container{container(6, 100, 4, azaci.GpuSkuV100)}, false},
+		{"too many GPUs", []azaci.Container{container(6, 100, 2, azaci.GpuSkuV100), container(0, 1, 3, azaci.GpuSkuV100)}, true},
+		{"unknown GPU SKU", []azaci.Container{container(24, 200, 8, gpuSKUA100)}, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := p.validateCapabilities(pod, tc.containers)
+			if tc.wantErr {
+				assert.Check(t, errdefs.IsInvalidInput(err), "expected an invalid input error, got %v", err)
+			} else {
+				assert.NilError(t, err)
+			}
+		})
+	}
+}
+
+func TestDiffCapabilities(t *testing.T) {
+	before := map[capabilityKey]capabilityLimits{
+		newCapabilityKey("Linux", ""):               {maxCPU: 4, maxMemoryInGB: 16},
+		newCapabilityKey("Linux", azaci.GpuSkuV100): {maxCPU: 6, maxMemoryInGB: 112, maxGPUCount: 4},
+		newCapabilityKey("Linux", azaci.GpuSkuK80):  {maxCPU: 6, maxMemoryInGB: 56, maxGPUCount: 4},
+		newCapabilityKey("Windows", ""):             {maxCPU: 2, maxMemoryInGB: 8},
+		newCapabilityKey("", gpuSKUT4):              {maxCPU: 8, maxMemoryInGB: 56, maxGPUCount: 2},
+		newCapabilityKey("Linux", azaci.GpuSkuP100): {maxCPU: 6, maxMemoryInGB: 112, maxGPUCount: 4},
+	}
+	after := map[capabilityKey]capabilityLimits{
+		newCapabilityKey("Linux", ""):               {maxCPU: 4, maxMemoryInGB: 16},
+		newCapabilityKey("Linux", azaci.GpuSkuV100): {maxCPU: 6, maxMemoryInGB: 56, maxGPUCount: 4},
+		newCapabilityKey("Linux", gpuSKUA100):       {maxCPU: 24, maxMemoryInGB: 220, maxGPUCount: 4},
+		newCapabilityKey("", gpuSKUT4):              {maxCPU: 8, maxMemoryInGB: 56, maxGPUCount: 4},
+		newCapabilityKey("Linux", azaci.GpuSkuP100): {maxCPU: 6, maxMemoryInGB: 112, maxGPUCount: 4},
+	}
+
+	var got []string
+	for _, change := range diffCapabilities("Linux", before, after) {
+		got = append(got, change.kind+" "+change.key.gpu)
+	}
+	want := []string{
+		capabilityAdded + " A100",
+		capabilityRemoved + " K80",
+		capabilityIncreased + " T4",
+		capabilityReduced + " V100",
+	}
+	assert.Check(t, is.DeepEqual(want, got), "changes of other OS types should be ignored")
+	assert.Check(t, is.Len(diffCapabilities("Linux", after, after), 0))
+}
+
+func TestReportCapabilityDrift(t *testing.T) {
+	capabilities := []azaci.Capabilities{
+		capability("westus2", "Linux", "", 4, 16, 0),
+		capability("westus2", "Linux", "V100", 6, 112, 4),
+	}
+	recorder := record.NewFakeRecorder(10)
+	p := &ACIProvider{
+		region:          "westus2",
+		operatingSystem: "Linux",
+		nodeName:        "vk",
+		eventRecorder:   recorder,
+		capabilities: newCapabilityService(NewMockACIProvider(func(ctx context.Context, region string) (*[]azaci.Capabilities, error) {
+			return &capabilities, nil
+		}), "westus2"),
+	}
+
+	assert.Check(t, p.refreshGPUSKUs(context.Background()))
+	assert.Check(t, is.Len(recorder.Events, 0), "the first refresh should not be reported as a change")
+
+	capabilities = capabilities[:1]
+	assert.Check(t, p.refreshGPUSKUs(context.Background()))
+	assert.Assert(t, is.Len(recorder.Events, 1))
+	event := <-recorder.Events
+	assert.Check(t, strings.HasPrefix(event, "Warning CapabilityRemoved "), event)
+	assert.Check(t, is.Contains(event, "V100"))
+}
+
+func TestValidateContainerCount(t *testing.T) {
+	pod := testsutil.CreatePodObj("pod", "ns")
+	containers := make([]azaci.Container, 55)
+	initContainers := make([]azaci.InitContainerDefinition, 5)
+	cg := &azaci.ContainerGroupProperties{Containers: &containers, InitContainers: &initContainers}
+	assert.NilError(t, validateContainerCount(pod, cg))
+
+	initContainers = append(initContainers, azaci.InitContainerDefinition{})
+	err := validateContainerCount(pod, cg)
+	assert.Check(t, errdefs.IsInvalidInput(err))
+	assert.Check(t, is.ErrorContains(err, "has 61 containers (55 containers and 6 init containers)"))
+	assert.Check(t, is.ErrorContains(err, "at most 60 containers"))
+}
@@ -0,0 +1,63 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"strings"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	v1 "k8s.io/api/core/v1"
+)
+
+// containerGroupSKUAnnotation chooses the SKU of the container group of a pod, "Standard" or
+// "Dedicated", overriding the namespace and provider SKUs.
+const containerGroupSKUAnnotation = "virtual-kubelet.io/container-group-sku"
+
+// parseContainerGroupSKU returns the SKU named case insensitively, or false when ACI has no such SKU.
+func parseContainerGroupSKU(value string) (azaci.ContainerGroupSku, bool) {
+	for _, sku := range azaci.PossibleContainerGroupSkuValues() {
+		if strings.EqualFold(string(sku), strings.TrimSpace(value)) {
+			return sku, true
+		}
+	}
+	return "", false
+}
+
+// getContainerGroupSKU returns the SKU of the container group of the pod, from its annotation, the
+// SKU of its namespace or the provider SKU. It is empty when none is set, in which case ACI uses
+// the Standard SKU.
+func (p *ACIProvider) getContainerGroupSKU(pod *v1.Pod) (azaci.ContainerGroupSku, error) {
+	if value, ok := pod.Annotations[containerGroupSKUAnnotation]; ok {
+		sku, ok := parseContainerGroupSKU(value)
+		if !ok {
+			return "", errdefs.InvalidInputf("annotation %s has invalid value %q, try one of the following instead: %s | %s", containerGroupSKUAnnotation, value, azaci.ContainerGroupSkuStandard, azaci.ContainerGroupSkuDedicated)
+		}
+		return sku, nil
+	}
+	if sku, ok := p.namespaceContainerGroupSKUs[pod.Namespace]; ok {
+		return sku, nil
+	}
+	return p.containerGroupSKU, nil
+}
+
+// setContainerGroupSKU sets the SKU of the container group. The region capabilities do not list
+// the SKUs, so a SKU the region does not offer is reported by ACI when the container group is
+// created. Dedicated container groups run on hosts of their own at a higher price, which is
+// recorded on the pod so the cost is not a surprise.
+func (p *ACIProvider) setContainerGroupSKU(pod *v1.Pod, cgProperties *azaci.ContainerGroupProperties) error {
+	sku, err := p.getContainerGroupSKU(pod)
+	if err != nil {
+		return err
+	}
+	if sku == "" {
+		return nil
+	}
+	cgProperties.Sku = sku
+	if sku == azaci.ContainerGroupSkuDedicated {
+		p.recordEvent(pod, v1.EventTypeNormal, "DedicatedSKU", "Container group uses the %s SKU, which is billed at a higher price than %s", azaci.ContainerGroupSkuDedicated, azaci.ContainerGroupSkuStandard)
+	}
+	return nil
+}
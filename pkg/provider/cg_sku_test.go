@@ -0,0 +1,41 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"testing"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	testsutil "github.com/virtual-kubelet/azure-aci/pkg/tests"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestSetContainerGroupSKU(t *testing.T) {
+	p := &ACIProvider{
+		namespaceContainerGroupSKUs: map[string]azaci.ContainerGroupSku{"isolated": azaci.ContainerGroupSkuDedicated},
+	}
+
+	pod := testsutil.CreatePodObj("pod", "ns")
+	cgProperties := &azaci.ContainerGroupProperties{}
+	assert.NilError(t, p.setContainerGroupSKU(pod, cgProperties))
+	assert.Check(t, is.Equal(azaci.ContainerGroupSku(""), cgProperties.Sku), "the ACI default should be used when no SKU is set")
+
+	p.containerGroupSKU = azaci.ContainerGroupSkuStandard
+	assert.NilError(t, p.setContainerGroupSKU(pod, cgProperties))
+	assert.Check(t, is.Equal(azaci.ContainerGroupSkuStandard, cgProperties.Sku))
+
+	pod = testsutil.CreatePodObj("pod", "isolated")
+	assert.NilError(t, p.setContainerGroupSKU(pod, cgProperties))
+	assert.Check(t, is.Equal(azaci.ContainerGroupSkuDedicated, cgProperties.Sku), "the namespace SKU should override the provider one")
+
+	pod.Annotations = map[string]string{containerGroupSKUAnnotation: "standard"}
+	assert.NilError(t, p.setContainerGroupSKU(pod, cgProperties))
+	assert.Check(t, is.Equal(azaci.ContainerGroupSkuStandard, cgProperties.Sku), "the annotation should override the namespace SKU")
+
+	pod.Annotations[containerGroupSKUAnnotation] = "Confidential"
+	assert.Check(t, errdefs.IsInvalidInput(p.setContainerGroupSKU(pod, cgProperties)))
+}
@@ -0,0 +1,107 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	client2 "github.com/virtual-kubelet/azure-aci/pkg/client"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// Azure limits on resource tags.
+	maxTagsPerResource = 50
+	maxTagNameLength   = 512
+	maxTagValueLength  = 256
+	invalidTagChars    = `<>%&\?/`
+
+	// podTagCount is the number of tags the provider may set for a pod: PodName, ClusterName,
+	// NodeName, Namespace, UID, CreationTimestamp, WindowsVersion, WorkloadKind and WorkloadName.
+	podTagCount = 9
+)
+
+// validateTagName checks a tag name against the Azure restrictions on resource tags.
+func validateTagName(name string) error {
+	if name == "" || len(name) > maxTagNameLength {
+		return fmt.Errorf("tag name %q must have between 1 and %d characters", name, maxTagNameLength)
+	}
+	if strings.ContainsAny(name, invalidTagChars) {
+		return fmt.Errorf("tag name %q must not contain any of %s", name, invalidTagChars)
+	}
+	return nil
+}
+
+// validateProviderTags checks the tags added to every container group against the Azure limits,
+// leaving room for the tags set for each pod.
+func validateProviderTags(tags map[string]string, reserved int) error {
+	if len(tags)+reserved > maxTagsPerResource {
+		return fmt.Errorf("%d tags are configured, but container groups can have at most %d tags besides the %d set for each pod", len(tags), maxTagsPerResource-reserved, reserved)
+	}
+	for name, value := range tags {
+		if err := validateTagName(name); err != nil {
+			return err
+		}
+		if len(value) > maxTagValueLength {
+			return fmt.Errorf("value of tag %q must have at most %d characters", name, maxTagValueLength)
+		}
+	}
+	return nil
+}
+
+// validateTagMapping checks that a pod metadata to tag mapping produces valid tags.
+func validateTagMapping(kind string, mapping map[string]string) error {
+	for key, tag := range mapping {
+		if err := validateTagName(tag); err != nil {
+			return fmt.Errorf("invalid tag for pod %s %q: %v", kind, key, err)
+		}
+	}
+	return nil
+}
+
+// addMappedTags copies the pod annotations and labels configured by the operator into container
+// group tags, e.g. for chargeback or Azure Policy. The tags set by the provider are never
+// overwritten and values too long for a tag are skipped.
+func (p *ACIProvider) addMappedTags(ctx context.Context, pod *v1.Pod, cg *client2.ContainerGroupWrapper) {
+	addTags := func(kind string, values, mapping map[string]string) {
+		for key, tag := range mapping {
+			value, ok := values[key]
+			if !ok {
+				continue
+			}
+			if _, exists := cg.Tags[tag]; exists {
+				log.G(ctx).Warnf("pod %s %q is not copied into tag %s already set by the provider", kind, key, tag)
+				continue
+			}
+			if len(value) > maxTagValueLength {
+				log.G(ctx).Warnf("pod %s %q is not copied into tag %s, its value is longer than %d characters", kind, key, tag, maxTagValueLength)
+				continue
+			}
+			cg.Tags[tag] = &value
+		}
+	}
+	addTags("label", pod.Labels, p.labelTags)
+	addTags("annotation", pod.Annotations, p.annotationTags)
+}
+
+// addProviderTags adds the tags configured for every container group, e.g. for cost allocation.
+// The tags set for the pod take precedence over them.
+func (p *ACIProvider) addProviderTags(pod *v1.Pod, cg *client2.ContainerGroupWrapper) error {
+	for name, value := range p.tags {
+		if _, exists := cg.Tags[name]; exists {
+			continue
+		}
+		value := value
+		cg.Tags[name] = &value
+	}
+	if len(cg.Tags) > maxTagsPerResource {
+		return errdefs.InvalidInputf("container group of pod %s would have %d tags, but Azure allows at most %d", pod.Name, len(cg.Tags), maxTagsPerResource)
+	}
+	return nil
+}
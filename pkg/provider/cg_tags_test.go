@@ -0,0 +1,81 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	client2 "github.com/virtual-kubelet/azure-aci/pkg/client"
+	testsutil "github.com/virtual-kubelet/azure-aci/pkg/tests"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestAddMappedTags(t *testing.T) {
+	p := &ACIProvider{
+		annotationTags: map[string]string{
+			"example.com/cost-center": "CostCenter",
+			"example.com/owner":       "PodName",
+			"example.com/notes":       "Notes",
+		},
+		labelTags: map[string]string{"app": "App", "tier": "Tier"},
+	}
+	pod := testsutil.CreatePodObj("pod", "ns")
+	pod.Labels = map[string]string{"app": "web"}
+	pod.Annotations = map[string]string{
+		"example.com/cost-center": "cc-42",
+		"example.com/owner":       "team-a",
+		"example.com/notes":       strings.Repeat("x", maxTagValueLength+1),
+	}
+
+	podName := pod.Name
+	cg := &client2.ContainerGroupWrapper{Tags: map[string]*string{"PodName": &podName}}
+	p.addMappedTags(context.Background(), pod, cg)
+
+	assert.Check(t, is.Equal("cc-42", *cg.Tags["CostCenter"]))
+	assert.Check(t, is.Equal("web", *cg.Tags["App"]))
+	assert.Check(t, is.Equal(podName, *cg.Tags["PodName"]), "provider tags should not be overwritten")
+	_, hasNotes := cg.Tags["Notes"]
+	assert.Check(t, !hasNotes, "values longer than a tag allows should be skipped")
+	_, hasTier := cg.Tags["Tier"]
+	assert.Check(t, !hasTier, "missing labels should not be tagged")
+}
+
+func TestValidateTagMapping(t *testing.T) {
+	assert.NilError(t, validateTagMapping("annotation", map[string]string{"example.com/cost-center": "CostCenter"}))
+	assert.Check(t, validateTagMapping("annotation", map[string]string{"example.com/cost-center": "cost/center"}) != nil)
+	assert.Check(t, validateTagMapping("label", map[string]string{"app": ""}) != nil)
+}
+
+func TestAddProviderTags(t *testing.T) {
+	p := &ACIProvider{tags: map[string]string{"CostCenter": "platform", "PodName": "ignored"}}
+	pod := testsutil.CreatePodObj("pod", "ns")
+	podName := pod.Name
+	cg := &client2.ContainerGroupWrapper{Tags: map[string]*string{"PodName": &podName}}
+
+	assert.NilError(t, p.addProviderTags(pod, cg))
+	assert.Check(t, is.Equal("platform", *cg.Tags["CostCenter"]))
+	assert.Check(t, is.Equal(podName, *cg.Tags["PodName"]), "pod tags should take precedence over provider tags")
+
+	for i := 0; i < maxTagsPerResource; i++ {
+		p.tags[fmt.Sprintf("Tag%d", i)] = "value"
+	}
+	assert.Check(t, errdefs.IsInvalidInput(p.addProviderTags(pod, cg)), "container groups should not exceed the Azure tag limit")
+}
+
+func TestValidateProviderTags(t *testing.T) {
+	assert.NilError(t, validateProviderTags(map[string]string{"Owner": "team-a"}, podTagCount))
+	assert.Check(t, validateProviderTags(map[string]string{"Owner": strings.Repeat("x", maxTagValueLength+1)}, podTagCount) != nil)
+
+	tags := make(map[string]string)
+	for i := 0; i <= maxTagsPerResource-podTagCount; i++ {
+		tags[fmt.Sprintf("Tag%d", i)] = "value"
+	}
+	assert.Check(t, validateProviderTags(tags, podTagCount) != nil, "provider tags should leave room for the pod tags")
+}
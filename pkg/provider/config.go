@@ -5,9 +5,13 @@ import (
 	"io"
 	"net"
 	"strings"
+	"time"
 
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
 	"github.com/BurntSushi/toml"
 	"github.com/virtual-kubelet/node-cli/provider"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 type providerConfig struct {
@@ -19,6 +23,161 @@ type providerConfig struct {
 	Pods            string
 	SubnetName      string
 	SubnetCIDR      string
+	// Subnets are additional delegated subnets pods can select with an annotation, or pods are
+	// spread across when they are pooled.
+	Subnets []subnetConfig
+	// AllowVNetPublicIPs lets pods request a public IP address with an annotation although the
+	// virtual node has a subnet. Their container groups are deployed outside of the virtual network.
+	AllowVNetPublicIPs bool
+
+	// UnsupportedPodPolicy decides what happens to pods that can never run on ACI, either "Reject",
+	// which fails them, or "Ignore", which leaves them Pending and untracked. They are created as
+	// usual when unset.
+	UnsupportedPodPolicy string
+	// UnsupportedPodNamespaces lists the namespaces the policy applies to, kube-system by default.
+	UnsupportedPodNamespaces []string
+
+	// StrictPodValidation rejects pods using fields ACI never supports, e.g. hostNetwork or hostPort,
+	// instead of silently dropping them.
+	StrictPodValidation bool
+	// AllowPrivilegedContainers lets strict validation accept privileged containers, which then run
+	// unprivileged.
+	AllowPrivilegedContainers bool
+	// NetworkPolicyCheck warns about NetworkPolicies selecting pods of the virtual node, which are not
+	// enforced on ACI, either "Warn" or "Deny" to reject the pods. Pods are not checked when unset.
+	NetworkPolicyCheck string
+
+	// Tags are added to every container group, e.g. for cost allocation or ownership tracking.
+	Tags map[string]string
+	// AnnotationTags and LabelTags copy pod annotations and labels into container group tags,
+	// keyed by the annotation or label with the tag name as value.
+	AnnotationTags map[string]string
+	LabelTags      map[string]string
+
+	// ContainerGroupSKU is the SKU of the container groups, "Standard" or "Dedicated". ACI uses
+	// Standard when unset. NamespaceContainerGroupSKUs overrides it for the pods of a namespace.
+	ContainerGroupSKU           string
+	NamespaceContainerGroupSKUs map[string]string
+	// RuntimeClasses maps the runtimeClassName of the pods to the SKU and the confidential
+	// computing policy of their container groups, e.g. kata-cc to the Confidential SKU. Pods of
+	// another runtime class are rejected.
+	RuntimeClasses map[string]runtimeClassConfig
+
+	// SecretDeliveryPolicy decides how secret values referenced by env vars reach the containers,
+	// either "EnvironmentVariable" (default) or "File".
+	SecretDeliveryPolicy string
+	// SecretFileNamespaces lists namespaces whose pods always get secret env vars as files.
+	SecretFileNamespaces []string
+
+	// RequireImageDigests rejects pods referencing images by tag instead of digest, e.g.
+	// "image@sha256:...", so the image that runs is the one that was reviewed. ImageDigestNamespaces
+	// requires digests for the pods of these namespaces only.
+	RequireImageDigests   bool
+	ImageDigestNamespaces []string
+
+	// OrphanGracePeriod is how long a container group without a pod is kept before it is deleted,
+	// as a duration like "10m".
+	OrphanGracePeriod string
+	// StatusUpdatesInterval and CleanupInterval are how often the pod statuses are updated and the
+	// container groups without a pod are cleaned up, as durations like "5s" and "5m". Longer
+	// intervals make fewer ARM calls, at the cost of staler pod statuses.
+	StatusUpdatesInterval string
+	CleanupInterval       string
+	// StatusUpdateParallelism is the number of pod statuses fetched concurrently by each poll.
+	StatusUpdateParallelism int
+
+	// MigrationZones and MigrationFallbackRegions opt in to moving the container groups that fail to
+	// provision in their placement, e.g. for lack of capacity in their zone. They are deleted and
+	// recreated in the zones of the region in order, then in the fallback regions, once they failed
+	// MigrationFailureThreshold status updates in a row, 3 by default.
+	MigrationZones            []string
+	MigrationFallbackRegions  []string
+	MigrationFailureThreshold int
+
+	// GPUZones opts in to spreading the GPU container groups over these zones of the region. Each one
+	// is created in the zone with the fewest capacity failures for its GPU SKU in the last 30 minutes,
+	// and in the next zones when ARM reports a lack of capacity.
+	GPUZones []string
+
+	// OutagePolicy decides how the node and the pods are reported once ARM has been unreachable for
+	// OutageThreshold, a duration like "10m", 0 by default. "NodeNotReady" (default) marks the node
+	// NotReady and keeps the last known pod statuses, "FreezeStatus" keeps the node Ready and the last
+	// known pod statuses, and "MarkPodsUnknown" keeps the node Ready and marks the pods Unknown.
+	OutagePolicy    string
+	OutageThreshold string
+
+	// LogVolumeSampleInterval is how often the log volume of the containers is sampled for the
+	// aci_container_log_bytes_total metric, as a duration like "5m". Logs are not sampled when unset.
+	LogVolumeSampleInterval string
+	// NoisyContainerLogRate publishes an event on the pods with a container logging more bytes per
+	// second, as a quantity like "100Ki".
+	NoisyContainerLogRate string
+
+	// ACRIdentity is the resource ID of a user-assigned managed identity attached to every container
+	// group that pulls from ACR, e.g. the identity of the virtual node. ACR images are then pulled
+	// with it and need no image pull secret.
+	ACRIdentity string
+	// ACRIdentityRegistries limits the ACR identity to these login servers, every ACR by default.
+	ACRIdentityRegistries []string
+
+	// WindowsExecShell replaces the POSIX shells exec'ed into Windows pods, either "cmd.exe" (default)
+	// or "powershell.exe".
+	WindowsExecShell string
+	// WindowsVersion is the Windows version of Windows pods, either "LTSC2019" or "LTSC2022". Images
+	// built for another version are rejected. ACI picks the host version when unset.
+	WindowsVersion string
+
+	// ExecIdleTimeout closes exec sessions without input or output for this long, and
+	// ExecMaxSessionDuration closes sessions lasting longer, as durations like "15m". Unset by default.
+	ExecIdleTimeout        string
+	ExecMaxSessionDuration string
+	// ExecExitCodes runs the non interactive exec commands of Linux pods in /bin/sh to report their
+	// exit code, which ACI does not, so kubectl exec fails when they do. Images without /bin/sh cannot
+	// be exec'ed into without a TTY then. ACI merges stderr into stdout either way.
+	ExecExitCodes bool
+	// ExecTerminalResize runs the interactive exec commands of Linux pods in /bin/sh to find their
+	// terminal, and applies the resizes of the client to it with stty in another exec, since ACI only
+	// sizes the terminal when the exec starts. Every resize is an exec call to ARM. Interactive execs
+	// into images without /bin/sh, e.g. distroless or scratch images, then fail outright, and images
+	// without tty and stty are not resized, so leave it off for nodes running such images.
+	ExecTerminalResize bool
+	// ExecKeepaliveInterval is how often exec sessions are pinged to keep them open while idle, as a
+	// duration like "30s" (default). Sessions whose connection misses two pings end with an error.
+	// "0s" disables the pings.
+	ExecKeepaliveInterval string
+
+	// RealtimeMetrics adds the realtime metrics extension to the container groups, whose CPU, memory
+	// and network stats are then scraped from their IP address for /stats/summary instead of lagging
+	// minutes behind in Azure Monitor. It requires a subnet, like the ENABLE_REAL_TIME_METRICS env.
+	RealtimeMetrics bool
+
+	// DeletionFinalizer adds a finalizer to the pods, removed once ARM confirmed the deletion of their
+	// container group, so a deleted pod stays Terminating while ACI deletes it and its name is not
+	// reused by a new pod in the meantime.
+	DeletionFinalizer bool
+
+	// CapabilityRefreshInterval is how often the ACI capabilities of the region, e.g. the GPU SKUs,
+	// are reloaded, as a duration like "1h".
+	CapabilityRefreshInterval string
+
+	// DefaultRegistryCredentials are used by every pod pulling from their registries, so clusters with
+	// a single private registry need no image pull secret in every namespace.
+	DefaultRegistryCredentials []registryCredentialConfig
+
+	// MaxConcurrentARMReads and MaxConcurrentARMWrites cap the ARM operations in flight, operations
+	// over the cap are queued fairly across namespaces. A negative value disables the cap.
+	MaxConcurrentARMReads  int
+	MaxConcurrentARMWrites int
+	// ARMWriteShares are the shares of the queued ARM writes of namespaces, e.g. { team-a = 4 } hands
+	// team-a 4 write slots in a row for every slot of another namespace waiting. Namespaces not listed
+	// have a share of 1.
+	ARMWriteShares map[string]int
+
+	// MaxConcurrentDeletions and DeletionsPerSecond pace the pod deletions, oldest pods first, so
+	// deleting a namespace with many pods leaves ARM writes for the rest of the node. A negative
+	// value disables the limit.
+	MaxConcurrentDeletions int
+	DeletionsPerSecond     float64
 }
 
 func (p *ACIProvider) loadConfig(r io.Reader) error {
@@ -69,6 +228,265 @@ func (p *ACIProvider) loadConfig(r io.Reader) error {
 		}
 	}
 
+	switch config.UnsupportedPodPolicy {
+	case "", unsupportedPodPolicyReject, unsupportedPodPolicyIgnore:
+		p.unsupportedPodPolicy = config.UnsupportedPodPolicy
+	default:
+		return fmt.Errorf("%q is not a valid unsupported pod policy, try one of the following instead: %s | %s", config.UnsupportedPodPolicy, unsupportedPodPolicyReject, unsupportedPodPolicyIgnore)
+	}
+	p.unsupportedPodNamespaces = []string{metav1.NamespaceSystem}
+	if len(config.UnsupportedPodNamespaces) != 0 {
+		p.unsupportedPodNamespaces = config.UnsupportedPodNamespaces
+	}
+
+	p.strictPodValidation = config.StrictPodValidation
+	p.allowPrivilegedContainers = config.AllowPrivilegedContainers
+
+	switch config.NetworkPolicyCheck {
+	case "", networkPolicyCheckWarn, networkPolicyCheckDeny:
+		p.networkPolicyCheck = config.NetworkPolicyCheck
+	default:
+		return fmt.Errorf("%q is not a valid network policy check, try one of the following instead: %s | %s", config.NetworkPolicyCheck, networkPolicyCheckWarn, networkPolicyCheckDeny)
+	}
+
+	if err := validateProviderTags(config.Tags, podTagCount+len(config.AnnotationTags)+len(config.LabelTags)); err != nil {
+		return err
+	}
+	p.tags = config.Tags
+	if err := validateTagMapping("annotation", config.AnnotationTags); err != nil {
+		return err
+	}
+	if err := validateTagMapping("label", config.LabelTags); err != nil {
+		return err
+	}
+	p.annotationTags = config.AnnotationTags
+	p.labelTags = config.LabelTags
+
+	if config.ContainerGroupSKU != "" {
+		sku, ok := parseContainerGroupSKU(config.ContainerGroupSKU)
+		if !ok {
+			return fmt.Errorf("%q is not a valid container group SKU, try one of the following instead: %s | %s", config.ContainerGroupSKU, azaci.ContainerGroupSkuStandard, azaci.ContainerGroupSkuDedicated)
+		}
+		p.containerGroupSKU = sku
+	}
+	p.namespaceContainerGroupSKUs = make(map[string]azaci.ContainerGroupSku, len(config.NamespaceContainerGroupSKUs))
+	for ns, value := range config.NamespaceContainerGroupSKUs {
+		sku, ok := parseContainerGroupSKU(value)
+		if !ok {
+			return fmt.Errorf("%q is not a valid container group SKU for namespace %s, try one of the following instead: %s | %s", value, ns, azaci.ContainerGroupSkuStandard, azaci.ContainerGroupSkuDedicated)
+		}
+		p.namespaceContainerGroupSKUs[ns] = sku
+	}
+	for name, class := range config.RuntimeClasses {
+		if err := class.validate(name); err != nil {
+			return err
+		}
+	}
+	p.runtimeClasses = config.RuntimeClasses
+
+	switch config.SecretDeliveryPolicy {
+	case "":
+		p.secretDeliveryPolicy = secretDeliveryEnvironmentVariable
+	case secretDeliveryEnvironmentVariable, secretDeliveryFile:
+		p.secretDeliveryPolicy = config.SecretDeliveryPolicy
+	default:
+		return fmt.Errorf("%q is not a valid secret delivery policy, try one of the following instead: %s | %s", config.SecretDeliveryPolicy, secretDeliveryEnvironmentVariable, secretDeliveryFile)
+	}
+	p.secretDeliveryNamespaces = config.SecretFileNamespaces
+
+	p.requireImageDigests = config.RequireImageDigests
+	p.imageDigestNamespaces = config.ImageDigestNamespaces
+
+	if config.ACRIdentity != "" && !strings.Contains(strings.ToLower(config.ACRIdentity), "/providers/microsoft.managedidentity/userassignedidentities/") {
+		return fmt.Errorf("%q is not the resource ID of a user-assigned managed identity", config.ACRIdentity)
+	}
+	p.acrIdentity = config.ACRIdentity
+	p.acrIdentityRegistries = config.ACRIdentityRegistries
+
+	for _, cred := range config.DefaultRegistryCredentials {
+		if err := cred.validate(); err != nil {
+			return err
+		}
+	}
+	p.defaultRegistryCredentials = config.DefaultRegistryCredentials
+
+	subnetNames := make(map[string]bool, len(config.Subnets))
+	for _, subnet := range config.Subnets {
+		if err := subnet.validate(); err != nil {
+			return err
+		}
+		if subnetNames[subnet.Name] {
+			return fmt.Errorf("subnet %s is configured more than once", subnet.Name)
+		}
+		subnetNames[subnet.Name] = true
+	}
+	p.subnetConfigs = config.Subnets
+	p.allowVNetPublicIPs = config.AllowVNetPublicIPs
+
+	switch {
+	case config.WindowsExecShell == "", strings.EqualFold(config.WindowsExecShell, windowsExecShellCmd), strings.EqualFold(config.WindowsExecShell, windowsExecShellPowerShell):
+		p.windowsExecShell = config.WindowsExecShell
+	default:
+		return fmt.Errorf("%q is not a valid Windows exec shell, try one of the following instead: %s | %s", config.WindowsExecShell, windowsExecShellCmd, windowsExecShellPowerShell)
+	}
+
+	if config.WindowsVersion != "" {
+		version, ok := normalizeWindowsVersion(config.WindowsVersion)
+		if !ok {
+			return fmt.Errorf("%q is not a valid Windows version, try one of the following instead: %s | %s", config.WindowsVersion, windowsVersionLTSC2019, windowsVersionLTSC2022)
+		}
+		p.windowsVersion = version
+	}
+
+	if config.ExecIdleTimeout != "" {
+		timeout, err := time.ParseDuration(config.ExecIdleTimeout)
+		if err != nil || timeout < 0 {
+			return fmt.Errorf("%q is not a valid exec idle timeout", config.ExecIdleTimeout)
+		}
+		p.execIdleTimeout = timeout
+	}
+	if config.ExecMaxSessionDuration != "" {
+		duration, err := time.ParseDuration(config.ExecMaxSessionDuration)
+		if err != nil || duration < 0 {
+			return fmt.Errorf("%q is not a valid exec max session duration", config.ExecMaxSessionDuration)
+		}
+		p.execMaxSessionDuration = duration
+	}
+	p.execExitCodes = config.ExecExitCodes
+	p.execTerminalResize = config.ExecTerminalResize
+	p.realtimeMetrics = config.RealtimeMetrics
+	p.deletionFinalizer = config.DeletionFinalizer
+	p.execKeepaliveInterval = defaultExecKeepaliveInterval
+	if config.ExecKeepaliveInterval != "" {
+		interval, err := time.ParseDuration(config.ExecKeepaliveInterval)
+		if err != nil || interval < 0 {
+			return fmt.Errorf("%q is not a valid exec keepalive interval", config.ExecKeepaliveInterval)
+		}
+		p.execKeepaliveInterval = interval
+	}
+
+	p.capabilityRefreshInterval = defaultCapabilityRefreshInterval
+	if config.CapabilityRefreshInterval != "" {
+		interval, err := time.ParseDuration(config.CapabilityRefreshInterval)
+		if err != nil || interval <= 0 {
+			return fmt.Errorf("%q is not a valid capability refresh interval", config.CapabilityRefreshInterval)
+		}
+		p.capabilityRefreshInterval = interval
+	}
+
+	p.maxConcurrentARMReads = defaultMaxConcurrentARMReads
+	if config.MaxConcurrentARMReads != 0 {
+		p.maxConcurrentARMReads = config.MaxConcurrentARMReads
+	}
+	p.maxConcurrentARMWrites = defaultMaxConcurrentARMWrites
+	if config.MaxConcurrentARMWrites != 0 {
+		p.maxConcurrentARMWrites = config.MaxConcurrentARMWrites
+	}
+	for namespace, share := range config.ARMWriteShares {
+		if share <= 0 {
+			return fmt.Errorf("%d is not a valid ARM write share for namespace %q", share, namespace)
+		}
+	}
+	p.armWriteShares = config.ARMWriteShares
+	p.maxConcurrentDeletions = defaultMaxConcurrentDeletions
+	if config.MaxConcurrentDeletions != 0 {
+		p.maxConcurrentDeletions = config.MaxConcurrentDeletions
+	}
+	p.deletionsPerSecond = defaultDeletionsPerSecond
+	if config.DeletionsPerSecond != 0 {
+		p.deletionsPerSecond = config.DeletionsPerSecond
+	}
+
+	p.orphanGracePeriod = defaultOrphanGracePeriod
+	if config.OrphanGracePeriod != "" {
+		gracePeriod, err := time.ParseDuration(config.OrphanGracePeriod)
+		if err != nil || gracePeriod < 0 {
+			return fmt.Errorf("%q is not a valid orphan grace period", config.OrphanGracePeriod)
+		}
+		p.orphanGracePeriod = gracePeriod
+	}
+
+	p.statusUpdatesInterval = defaultStatusUpdatesInterval
+	if config.StatusUpdatesInterval != "" {
+		interval, err := time.ParseDuration(config.StatusUpdatesInterval)
+		if err != nil || interval <= 0 {
+			return fmt.Errorf("%q is not a valid status updates interval", config.StatusUpdatesInterval)
+		}
+		p.statusUpdatesInterval = interval
+	}
+	p.statusUpdateParallelism = defaultStatusUpdateParallelism
+	if config.StatusUpdateParallelism < 0 {
+		return fmt.Errorf("%d is not a valid status update parallelism", config.StatusUpdateParallelism)
+	}
+	if config.StatusUpdateParallelism != 0 {
+		p.statusUpdateParallelism = config.StatusUpdateParallelism
+	}
+	p.cleanupInterval = defaultCleanupInterval
+	if config.CleanupInterval != "" {
+		interval, err := time.ParseDuration(config.CleanupInterval)
+		if err != nil || interval <= 0 {
+			return fmt.Errorf("%q is not a valid cleanup interval", config.CleanupInterval)
+		}
+		p.cleanupInterval = interval
+	}
+
+	if config.MigrationFailureThreshold < 0 {
+		return fmt.Errorf("%d is not a valid migration failure threshold", config.MigrationFailureThreshold)
+	}
+	for _, region := range config.MigrationFallbackRegions {
+		if !isValidACIRegion(region) {
+			return fmt.Errorf("%q is not a valid migration fallback region", region)
+		}
+	}
+	if len(config.MigrationFallbackRegions) != 0 && config.SubnetName != "" {
+		// The subnet of the virtual node is in its region, container groups can't be moved out of it.
+		return fmt.Errorf("migration fallback regions cannot be set with a subnet")
+	}
+	if len(config.MigrationZones) != 0 || len(config.MigrationFallbackRegions) != 0 {
+		p.migrationZones = config.MigrationZones
+		p.migrationRegions = config.MigrationFallbackRegions
+		p.migrations = newMigrations(config.MigrationFailureThreshold)
+	}
+
+	for _, zone := range config.GPUZones {
+		if strings.TrimSpace(zone) == "" {
+			return fmt.Errorf("%q is not a valid GPU zone", zone)
+		}
+	}
+	if len(config.GPUZones) != 0 {
+		p.gpuZones = newGPUZones(config.GPUZones)
+	}
+
+	if config.OutagePolicy != "" && !isValidOutagePolicy(config.OutagePolicy) {
+		return fmt.Errorf("%q is not a valid outage policy", config.OutagePolicy)
+	}
+	p.outagePolicy = config.OutagePolicy
+	if config.OutageThreshold != "" {
+		threshold, err := time.ParseDuration(config.OutageThreshold)
+		if err != nil || threshold < 0 {
+			return fmt.Errorf("%q is not a valid outage threshold", config.OutageThreshold)
+		}
+		p.outageThreshold = threshold
+	}
+
+	if config.LogVolumeSampleInterval != "" {
+		interval, err := time.ParseDuration(config.LogVolumeSampleInterval)
+		if err != nil || interval <= 0 {
+			return fmt.Errorf("%q is not a valid log volume sample interval", config.LogVolumeSampleInterval)
+		}
+		p.logVolumeSampleInterval = interval
+	}
+	if config.NoisyContainerLogRate != "" {
+		rate, err := resource.ParseQuantity(config.NoisyContainerLogRate)
+		if err != nil || rate.Sign() <= 0 {
+			return fmt.Errorf("%q is not a valid noisy container log rate", config.NoisyContainerLogRate)
+		}
+		if p.logVolumeSampleInterval == 0 {
+			return fmt.Errorf("NoisyContainerLogRate requires a LogVolumeSampleInterval")
+		}
+		p.noisyLogBytesPerSecond = rate.Value()
+	}
+
 	p.operatingSystem = config.OperatingSystem
 	return nil
 }
@@ -4,6 +4,9 @@ import (
 	"bytes"
 	"strings"
 	"testing"
+	"time"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
 )
 
 const cfg = `
@@ -92,3 +95,336 @@ func TestDefaultedConfig(t *testing.T) {
 		t.Errorf("Wanted default %s, got %s.", wanted, p.pods)
 	}
 }
+
+const unsupportedPodCfg = `
+Region = "westus"
+ResourceGroup = "virtual-kubeletrg"
+UnsupportedPodPolicy = "Ignore"
+UnsupportedPodNamespaces = ["kube-system", "monitoring"]`
+
+func TestUnsupportedPodConfig(t *testing.T) {
+	br := bytes.NewReader([]byte(unsupportedPodCfg))
+	var p ACIProvider
+	err := p.loadConfig(br)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.unsupportedPodPolicy != unsupportedPodPolicyIgnore {
+		t.Errorf("Wanted %s, got %s.", unsupportedPodPolicyIgnore, p.unsupportedPodPolicy)
+	}
+	if len(p.unsupportedPodNamespaces) != 2 || p.unsupportedPodNamespaces[1] != "monitoring" {
+		t.Errorf("Wanted namespaces [kube-system monitoring], got %v.", p.unsupportedPodNamespaces)
+	}
+
+	br = bytes.NewReader([]byte(defCfg + `
+UnsupportedPodPolicy = "Drop"`))
+	if err := p.loadConfig(br); err == nil {
+		t.Fatal("expected loadConfig to fail with bad unsupported pod policy")
+	}
+}
+
+func TestNetworkPolicyCheckConfig(t *testing.T) {
+	br := bytes.NewReader([]byte(defCfg + `
+NetworkPolicyCheck = "Deny"`))
+	var p ACIProvider
+	if err := p.loadConfig(br); err != nil {
+		t.Fatal(err)
+	}
+	if p.networkPolicyCheck != networkPolicyCheckDeny {
+		t.Errorf("Wanted %s, got %s.", networkPolicyCheckDeny, p.networkPolicyCheck)
+	}
+
+	br = bytes.NewReader([]byte(defCfg + `
+NetworkPolicyCheck = "Enforce"`))
+	if err := p.loadConfig(br); err == nil {
+		t.Fatal("expected loadConfig to fail with bad network policy check")
+	}
+}
+
+const secretDeliveryCfg = `
+Region = "westus"
+ResourceGroup = "virtual-kubeletrg"
+SecretFileNamespaces = ["payments"]`
+
+func TestSecretDeliveryConfig(t *testing.T) {
+	br := bytes.NewReader([]byte(secretDeliveryCfg))
+	var p ACIProvider
+	err := p.loadConfig(br)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.secretDeliveryPolicy != secretDeliveryEnvironmentVariable {
+		t.Errorf("Wanted %s, got %s.", secretDeliveryEnvironmentVariable, p.secretDeliveryPolicy)
+	}
+	if len(p.secretDeliveryNamespaces) != 1 || p.secretDeliveryNamespaces[0] != "payments" {
+		t.Errorf("Wanted namespaces [payments], got %v.", p.secretDeliveryNamespaces)
+	}
+
+	br = bytes.NewReader([]byte(defCfg + `
+SecretDeliveryPolicy = "Volume"`))
+	if err := p.loadConfig(br); err == nil {
+		t.Fatal("expected loadConfig to fail with bad secret delivery policy")
+	}
+}
+
+func TestOrphanGracePeriodConfig(t *testing.T) {
+	br := bytes.NewReader([]byte(defCfg + `
+OrphanGracePeriod = "30m"`))
+	var p ACIProvider
+	if err := p.loadConfig(br); err != nil {
+		t.Fatal(err)
+	}
+	if p.orphanGracePeriod != 30*time.Minute {
+		t.Errorf("Wanted 30m, got %v.", p.orphanGracePeriod)
+	}
+
+	br = bytes.NewReader([]byte(defCfg + `
+OrphanGracePeriod = "soon"`))
+	if err := p.loadConfig(br); err == nil {
+		t.Fatal("expected loadConfig to fail with bad orphan grace period")
+	}
+}
+
+func TestTrackerIntervalsConfig(t *testing.T) {
+	var p ACIProvider
+	if err := p.loadConfig(bytes.NewReader([]byte(defCfg))); err != nil {
+		t.Fatal(err)
+	}
+	if p.statusUpdatesInterval != defaultStatusUpdatesInterval || p.cleanupInterval != defaultCleanupInterval {
+		t.Errorf("Wanted default intervals %s and %s, got %s and %s.", defaultStatusUpdatesInterval, defaultCleanupInterval, p.statusUpdatesInterval, p.cleanupInterval)
+	}
+
+	br := bytes.NewReader([]byte(defCfg + `
+StatusUpdatesInterval = "30s"
+CleanupInterval = "15m"`))
+	if err := p.loadConfig(br); err != nil {
+		t.Fatal(err)
+	}
+	if p.statusUpdatesInterval != 30*time.Second || p.cleanupInterval != 15*time.Minute {
+		t.Errorf("Wanted 30s and 15m, got %s and %s.", p.statusUpdatesInterval, p.cleanupInterval)
+	}
+
+	br = bytes.NewReader([]byte(defCfg + `
+StatusUpdatesInterval = "0s"`))
+	if err := p.loadConfig(br); err == nil {
+		t.Fatal("expected loadConfig to fail with a zero status updates interval")
+	}
+
+	br = bytes.NewReader([]byte(defCfg + `
+StatusUpdateParallelism = 25`))
+	if err := p.loadConfig(br); err != nil {
+		t.Fatal(err)
+	}
+	if p.statusUpdateParallelism != 25 {
+		t.Errorf("Wanted a status update parallelism of 25, got %d.", p.statusUpdateParallelism)
+	}
+
+	br = bytes.NewReader([]byte(defCfg + `
+StatusUpdateParallelism = -1`))
+	if err := p.loadConfig(br); err == nil {
+		t.Fatal("expected loadConfig to fail with a negative status update parallelism")
+	}
+}
+
+func TestOutageConfig(t *testing.T) {
+	br := bytes.NewReader([]byte(defCfg + `
+OutagePolicy = "MarkPodsUnknown"
+OutageThreshold = "10m"`))
+	var p ACIProvider
+	if err := p.loadConfig(br); err != nil {
+		t.Fatal(err)
+	}
+	if p.outagePolicy != outagePolicyMarkPodsUnknown || p.outageThreshold != 10*time.Minute {
+		t.Errorf("Wanted MarkPodsUnknown after 10m, got %s after %s.", p.outagePolicy, p.outageThreshold)
+	}
+
+	br = bytes.NewReader([]byte(defCfg + `
+OutagePolicy = "Evict"`))
+	if err := p.loadConfig(br); err == nil {
+		t.Fatal("expected loadConfig to fail with bad outage policy")
+	}
+
+	br = bytes.NewReader([]byte(defCfg + `
+OutageThreshold = "-1m"`))
+	if err := p.loadConfig(br); err == nil {
+		t.Fatal("expected loadConfig to fail with a negative outage threshold")
+	}
+}
+
+func TestGPUZonesConfig(t *testing.T) {
+	br := bytes.NewReader([]byte(defCfg + `
+GPUZones = ["1", "2"]`))
+	var p ACIProvider
+	if err := p.loadConfig(br); err != nil {
+		t.Fatal(err)
+	}
+	if p.gpuZones == nil || len(p.gpuZones.zones) != 2 {
+		t.Errorf("Wanted GPU zones 1 and 2, got %v.", p.gpuZones)
+	}
+
+	br = bytes.NewReader([]byte(defCfg + `
+GPUZones = [""]`))
+	if err := p.loadConfig(br); err == nil {
+		t.Fatal("expected loadConfig to fail with an empty GPU zone")
+	}
+}
+
+func TestARMWriteSharesConfig(t *testing.T) {
+	br := bytes.NewReader([]byte(defCfg + `
+[ARMWriteShares]
+jobs = 4`))
+	var p ACIProvider
+	if err := p.loadConfig(br); err != nil {
+		t.Fatal(err)
+	}
+	if p.armWriteShares["jobs"] != 4 {
+		t.Errorf("Wanted a write share of 4 for jobs, got %v.", p.armWriteShares)
+	}
+
+	br = bytes.NewReader([]byte(defCfg + `
+[ARMWriteShares]
+jobs = 0`))
+	if err := p.loadConfig(br); err == nil {
+		t.Fatal("expected loadConfig to fail with a zero write share")
+	}
+}
+
+func TestACRIdentityConfig(t *testing.T) {
+	br := bytes.NewReader([]byte(defCfg + `
+ACRIdentity = "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.ManagedIdentity/userAssignedIdentities/vk"
+ACRIdentityRegistries = ["myacr.azurecr.io"]`))
+	var p ACIProvider
+	if err := p.loadConfig(br); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.acrIdentityRegistries) != 1 || p.acrIdentityRegistries[0] != "myacr.azurecr.io" {
+		t.Errorf("Wanted registries [myacr.azurecr.io], got %v.", p.acrIdentityRegistries)
+	}
+
+	br = bytes.NewReader([]byte(defCfg + `
+ACRIdentity = "00000000-0000-0000-0000-000000000000"`))
+	if err := p.loadConfig(br); err == nil {
+		t.Fatal("expected loadConfig to fail with an ACR identity that is not a resource ID")
+	}
+}
+
+func TestWindowsVersionConfig(t *testing.T) {
+	br := bytes.NewReader([]byte(defCfg + `
+WindowsVersion = "ltsc2022"`))
+	var p ACIProvider
+	if err := p.loadConfig(br); err != nil {
+		t.Fatal(err)
+	}
+	if p.windowsVersion != windowsVersionLTSC2022 {
+		t.Errorf("Wanted Windows version %s, got %s.", windowsVersionLTSC2022, p.windowsVersion)
+	}
+
+	br = bytes.NewReader([]byte(defCfg + `
+WindowsVersion = "1903"`))
+	if err := p.loadConfig(br); err == nil {
+		t.Fatal("expected loadConfig to fail with an unsupported Windows version")
+	}
+}
+
+func TestExecSessionLimitsConfig(t *testing.T) {
+	br := bytes.NewReader([]byte(defCfg + `
+ExecIdleTimeout = "15m"
+ExecMaxSessionDuration = "8h"`))
+	var p ACIProvider
+	if err := p.loadConfig(br); err != nil {
+		t.Fatal(err)
+	}
+	if p.execIdleTimeout != 15*time.Minute || p.execMaxSessionDuration != 8*time.Hour {
+		t.Errorf("Wanted exec limits 15m/8h, got %s/%s.", p.execIdleTimeout, p.execMaxSessionDuration)
+	}
+
+	br = bytes.NewReader([]byte(defCfg + `
+ExecIdleTimeout = "forever"`))
+	if err := p.loadConfig(br); err == nil {
+		t.Fatal("expected loadConfig to fail with an invalid exec idle timeout")
+	}
+}
+
+func TestCapabilityRefreshIntervalConfig(t *testing.T) {
+	var p ACIProvider
+	if err := p.loadConfig(bytes.NewReader([]byte(defCfg))); err != nil {
+		t.Fatal(err)
+	}
+	if p.capabilityRefreshInterval != defaultCapabilityRefreshInterval {
+		t.Errorf("Wanted default capability refresh interval %s, got %s.", defaultCapabilityRefreshInterval, p.capabilityRefreshInterval)
+	}
+
+	br := bytes.NewReader([]byte(defCfg + `
+CapabilityRefreshInterval = "0s"`))
+	if err := p.loadConfig(br); err == nil {
+		t.Fatal("expected loadConfig to fail with a zero capability refresh interval")
+	}
+}
+
+func TestContainerGroupSKUConfig(t *testing.T) {
+	br := bytes.NewReader([]byte(defCfg + `
+ContainerGroupSKU = "standard"
+
+[NamespaceContainerGroupSKUs]
+isolated = "Dedicated"`))
+	var p ACIProvider
+	if err := p.loadConfig(br); err != nil {
+		t.Fatal(err)
+	}
+	if p.containerGroupSKU != azaci.ContainerGroupSkuStandard || p.namespaceContainerGroupSKUs["isolated"] != azaci.ContainerGroupSkuDedicated {
+		t.Errorf("Wanted SKUs Standard and Dedicated for isolated, got %s and %v.", p.containerGroupSKU, p.namespaceContainerGroupSKUs)
+	}
+
+	br = bytes.NewReader([]byte(defCfg + `
+ContainerGroupSKU = "Premium"`))
+	if err := p.loadConfig(br); err == nil {
+		t.Fatal("expected loadConfig to fail with an invalid container group SKU")
+	}
+}
+
+func TestSubnetsConfig(t *testing.T) {
+	br := bytes.NewReader([]byte(defCfg + `
+[[Subnets]]
+Name = "isolated"
+VNetName = "vnet"
+SubnetName = "team-a"
+Namespaces = ["team-a"]
+[[Subnets]]
+Name = "overflow"
+VNetName = "vnet"
+SubnetName = "overflow"
+Pool = true`))
+	var p ACIProvider
+	if err := p.loadConfig(br); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.subnetConfigs) != 2 || p.subnetConfigs[0].Name != "isolated" || p.subnetConfigs[0].Namespaces[0] != "team-a" {
+		t.Errorf("Wanted subnet isolated for namespace team-a, got %v.", p.subnetConfigs)
+	}
+	if p.subnetConfigs[0].Pool || !p.subnetConfigs[1].Pool {
+		t.Errorf("Wanted only subnet overflow pooled, got %v.", p.subnetConfigs)
+	}
+
+	br = bytes.NewReader([]byte(defCfg + `
+[[Subnets]]
+Name = "isolated"
+VNetName = "vnet"`))
+	if err := p.loadConfig(br); err == nil {
+		t.Fatal("expected loadConfig to fail with a subnet without subnet name")
+	}
+
+	br = bytes.NewReader([]byte(defCfg + `
+[[Subnets]]
+Name = "isolated"
+VNetName = "vnet"
+SubnetName = "team-a"
+[[Subnets]]
+Name = "isolated"
+VNetName = "vnet"
+SubnetName = "team-b"`))
+	if err := p.loadConfig(br); err == nil {
+		t.Fatal("expected loadConfig to fail with a subnet configured twice")
+	}
+}
@@ -0,0 +1,92 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// containerGroupIDAnnotation is the ARM resource ID of the container group of the pod, e.g. for
+// az resource show --ids, written once the container group exists.
+const containerGroupIDAnnotation = "virtual-kubelet.io/container-group-id"
+
+// containerGroupIDs remembers the container group IDs the pods were annotated with, so the pods
+// the informer has not caught up with yet are not patched again on every status poll.
+type containerGroupIDs struct {
+	mu  sync.Mutex
+	ids map[PodIdentifier]string
+}
+
+func newContainerGroupIDs() *containerGroupIDs {
+	return &containerGroupIDs{ids: make(map[PodIdentifier]string)}
+}
+
+// annotated reports whether the pod was already annotated with the ID.
+func (c *containerGroupIDs) annotated(pod PodIdentifier, id string) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ids[pod] == id
+}
+
+func (c *containerGroupIDs) remember(pod PodIdentifier, id string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ids[pod] = id
+}
+
+// forget drops the ID of a deleted pod.
+func (c *containerGroupIDs) forget(pod PodIdentifier) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.ids, pod)
+}
+
+// annotateContainerGroupID writes the ARM resource ID ACI reports for the container group onto its
+// pod, unless the pod already has it or it was already patched with it. GetPodStatus runs on every
+// poll, so the pod is only patched when the annotation is missing or the ID changed. The status
+// updates only update the pod status, so the annotation is patched separately.
+func (p *ACIProvider) annotateContainerGroupID(ctx context.Context, namespace, name string, cg *azaci.ContainerGroup) {
+	if p.kubeClient == nil || p.resourceManager == nil || cg.ID == nil || *cg.ID == "" {
+		return
+	}
+	pod, err := p.resourceManager.GetPod(name, namespace)
+	if err != nil || pod == nil || pod.DeletionTimestamp != nil || pod.Annotations[containerGroupIDAnnotation] == *cg.ID {
+		return
+	}
+	id := PodIdentifier{namespace: namespace, name: name}
+	if p.containerGroupIDs.annotated(id, *cg.ID) {
+		return
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{containerGroupIDAnnotation: *cg.ID},
+		},
+	})
+	if err == nil {
+		_, err = p.kubeClient.CoreV1().Pods(namespace).Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+	}
+	if err == nil {
+		p.containerGroupIDs.remember(id, *cg.ID)
+		return
+	}
+	log.G(ctx).WithError(err).Warnf("failed to annotate pod %s/%s with the ID of its container group", namespace, name)
+}
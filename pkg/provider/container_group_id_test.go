@@ -0,0 +1,58 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"testing"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	"github.com/golang/mock/gomock"
+	testsutil "github.com/virtual-kubelet/azure-aci/pkg/tests"
+	"github.com/virtual-kubelet/node-cli/manager"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestAnnotateContainerGroupID(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	id := "/subscriptions/sub/resourceGroups/vk-rg/providers/Microsoft.ContainerInstance/containerGroups/ns-web"
+	pod := testsutil.CreatePodObj("web", "ns")
+	annotated := pod.DeepCopy()
+	annotated.Annotations = map[string]string{containerGroupIDAnnotation: id}
+
+	podLister := NewMockPodLister(mockCtrl)
+	mockPodsNamespaceLister := NewMockPodNamespaceLister(mockCtrl)
+	podLister.EXPECT().Pods("ns").Return(mockPodsNamespaceLister).Times(3)
+	gomock.InOrder(
+		mockPodsNamespaceLister.EXPECT().Get("web").Return(pod, nil).Times(2),
+		mockPodsNamespaceLister.EXPECT().Get("web").Return(annotated, nil),
+	)
+	resourceManager, err := manager.NewResourceManager(podLister, nil, nil, newServiceLister(), nil, nil)
+	if err != nil {
+		t.Fatal("Unable to prepare the mocks for resourceManager", err)
+	}
+	kubeClient := fake.NewSimpleClientset(pod)
+	p := &ACIProvider{resourceManager: resourceManager, kubeClient: kubeClient, containerGroupIDs: newContainerGroupIDs()}
+
+	cg := &azaci.ContainerGroup{ID: &id}
+	p.annotateContainerGroupID(context.Background(), "ns", "web", cg)
+	patched, err := kubeClient.CoreV1().Pods("ns").Get(context.Background(), "web", metav1.GetOptions{})
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(id, patched.Annotations[containerGroupIDAnnotation]))
+
+	kubeClient.ClearActions()
+	p.annotateContainerGroupID(context.Background(), "ns", "web", cg)
+	assert.Check(t, is.Len(kubeClient.Actions(), 0), "pods the informer has not caught up with should not be patched again")
+
+	p.annotateContainerGroupID(context.Background(), "ns", "web", cg)
+	assert.Check(t, is.Len(kubeClient.Actions(), 0), "pods already annotated should not be patched again")
+
+	p.annotateContainerGroupID(context.Background(), "ns", "web", &azaci.ContainerGroup{})
+}
@@ -5,6 +5,7 @@ Licensed under the Apache 2.0 license.
 package provider
 
 import (
+	"fmt"
 	"time"
 
 	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
@@ -17,7 +18,11 @@ import (
 
 func (p *ACIProvider) containerGroupToPod(cg *azaci.ContainerGroup) (*v1.Pod, error) {
 	//cg is validated
-	pod, err := p.resourceManager.GetPod(*cg.Name, *cg.Tags["Namespace"])
+	podName := *cg.Name
+	if name, ok := cg.Tags["PodName"]; ok && name != nil {
+		podName = *name
+	}
+	pod, err := p.resourceManager.GetPod(podName, *cg.Tags["Namespace"])
 	if err != nil {
 		return nil, err
 	}
@@ -36,9 +41,6 @@ func (p *ACIProvider) containerGroupToPod(cg *azaci.ContainerGroup) (*v1.Pod, er
 
 func (p *ACIProvider) getPodStatusFromContainerGroup(cg *azaci.ContainerGroup) (*v1.PodStatus, error) {
 	// cg is validated
-	allReady := true
-	var firstContainerStartTime, lastUpdateTime metav1.Time
-
 	containerStatuses := make([]v1.ContainerStatus, 0, len(*cg.Containers))
 	containersList := *cg.Containers
 
@@ -47,8 +49,6 @@ func (p *ACIProvider) getPodStatusFromContainerGroup(cg *azaci.ContainerGroup) (
 		if err != nil {
 			return nil, err
 		}
-		firstContainerStartTime := metav1.NewTime(containersList[0].InstanceView.CurrentState.StartTime.Time)
-		lastUpdateTime := firstContainerStartTime
 
 		containerStatus := v1.ContainerStatus{
 			Name:                 *containersList[i].Name,
@@ -61,16 +61,6 @@ func (p *ACIProvider) getPodStatusFromContainerGroup(cg *azaci.ContainerGroup) (
 			ContainerID:          getContainerID(cg.ID, containersList[i].Name),
 		}
 
-		if getPodPhaseFromACIState(*containersList[i].InstanceView.CurrentState.State) != v1.PodRunning &&
-			getPodPhaseFromACIState(*containersList[i].InstanceView.CurrentState.State) != v1.PodSucceeded {
-			allReady = false
-		}
-
-		containerStartTime := metav1.NewTime(containersList[i].ContainerProperties.InstanceView.CurrentState.StartTime.Time)
-		if containerStartTime.Time.After(lastUpdateTime.Time) {
-			lastUpdateTime = containerStartTime
-		}
-
 		// Add to containerStatuses
 		containerStatuses = append(containerStatuses, containerStatus)
 	}
@@ -79,15 +69,21 @@ func (p *ACIProvider) getPodStatusFromContainerGroup(cg *azaci.ContainerGroup) (
 	if err != nil {
 		return nil, err
 	}
+	phase := getPodPhaseFromACIState(*aciState)
+
+	var startTime *metav1.Time
+	if firstContainerStartTime, ok := getFirstContainerStartTime(cg); ok {
+		startTime = &firstContainerStartTime
+	}
 
 	return &v1.PodStatus{
-		Phase:             getPodPhaseFromACIState(*aciState),
-		Conditions:        getPodConditionsFromACIState(*aciState, creationTime, lastUpdateTime, allReady),
+		Phase:             phase,
+		Conditions:        append(getPodConditions(cg, phase, creationTime), getProvisionedCondition(cg, creationTime)),
 		Message:           "",
 		Reason:            "",
 		HostIP:            p.internalIP,
 		PodIP:             *cg.IPAddress.IP,
-		StartTime:         &firstContainerStartTime,
+		StartTime:         startTime,
 		ContainerStatuses: containerStatuses,
 	}, nil
 }
@@ -156,34 +152,114 @@ func getPodPhaseFromACIState(state string) v1.PodPhase {
 	return v1.PodUnknown
 }
 
-func getPodConditionsFromACIState(state string, creationTime, lastUpdateTime metav1.Time, allReady bool) []v1.PodCondition {
-	// cg state is validated
-	switch state {
-	case "Running", "Succeeded":
-		readyConditionStatus := v1.ConditionFalse
-		readyConditionTime := creationTime
-		if allReady {
-			readyConditionStatus = v1.ConditionTrue
-			readyConditionTime = lastUpdateTime
+const (
+	podConditionReasonNotInitialized = "ContainersNotInitialized"
+	podConditionReasonNotReady       = "ContainersNotReady"
+	podConditionReasonCompleted      = "PodCompleted"
+)
+
+// getPodConditions derives the conditions of the pod from the instance views of the container group
+// and its containers like the kubelet does, with the times the containers changed state as
+// transition times. ACI has no readiness probe results, running containers are ready.
+func getPodConditions(cg *azaci.ContainerGroup, phase v1.PodPhase, creationTime metav1.Time) []v1.PodCondition {
+	scheduled := v1.PodCondition{Type: v1.PodScheduled, Status: v1.ConditionTrue, LastTransitionTime: creationTime}
+	if phase == v1.PodUnknown {
+		return []v1.PodCondition{scheduled}
+	}
+
+	containersReady := getContainersReadyCondition(cg, phase, creationTime)
+	ready := containersReady
+	ready.Type = v1.PodReady
+	return []v1.PodCondition{ready, getInitializedCondition(cg, creationTime), containersReady, scheduled}
+}
+
+// getInitializedCondition is true once every init container completed, or any container started.
+func getInitializedCondition(cg *azaci.ContainerGroup, creationTime metav1.Time) v1.PodCondition {
+	condition := v1.PodCondition{Type: v1.PodInitialized, Status: v1.ConditionTrue, LastTransitionTime: creationTime}
+	if cg.InitContainers == nil || len(*cg.InitContainers) == 0 {
+		return condition
+	}
+
+	var incomplete []string
+	for _, container := range *cg.InitContainers {
+		var state *azaci.ContainerState
+		if container.InitContainerPropertiesDefinition != nil && container.InstanceView != nil {
+			state = container.InstanceView.CurrentState
+		}
+		if state == nil || state.State == nil || *state.State != "Terminated" || state.ExitCode == nil || *state.ExitCode != 0 {
+			if container.Name != nil {
+				incomplete = append(incomplete, *container.Name)
+			}
+			continue
 		}
+		if state.FinishTime != nil && state.FinishTime.Time.After(condition.LastTransitionTime.Time) {
+			condition.LastTransitionTime = metav1.NewTime(state.FinishTime.Time)
+		}
+	}
+	if len(incomplete) == 0 {
+		return condition
+	}
 
-		return []v1.PodCondition{
-			{
-				Type:               v1.PodReady,
-				Status:             readyConditionStatus,
-				LastTransitionTime: readyConditionTime,
-			}, {
-				Type:               v1.PodInitialized,
-				Status:             v1.ConditionTrue,
-				LastTransitionTime: creationTime,
-			}, {
-				Type:               v1.PodScheduled,
-				Status:             v1.ConditionTrue,
-				LastTransitionTime: creationTime,
-			},
+	// The init containers of a running container group may no longer be reported.
+	if started, ok := getFirstContainerStartTime(cg); ok {
+		condition.LastTransitionTime = started
+		return condition
+	}
+	condition.Status = v1.ConditionFalse
+	condition.Reason = podConditionReasonNotInitialized
+	condition.Message = fmt.Sprintf("containers with incomplete status: %v", incomplete)
+	condition.LastTransitionTime = creationTime
+	return condition
+}
+
+// getContainersReadyCondition is true while every container runs, since the last one started.
+func getContainersReadyCondition(cg *azaci.ContainerGroup, phase v1.PodPhase, creationTime metav1.Time) v1.PodCondition {
+	condition := v1.PodCondition{Type: v1.ContainersReady, Status: v1.ConditionFalse, LastTransitionTime: creationTime}
+
+	var unready []string
+	lastStarted, lastFinished := creationTime, creationTime
+	for _, container := range *cg.Containers {
+		state := container.InstanceView.CurrentState
+		if state.StartTime != nil && state.StartTime.Time.After(lastStarted.Time) {
+			lastStarted = metav1.NewTime(state.StartTime.Time)
+		}
+		if state.FinishTime != nil && state.FinishTime.Time.After(lastFinished.Time) {
+			lastFinished = metav1.NewTime(state.FinishTime.Time)
+		}
+		if *state.State != "Running" {
+			unready = append(unready, *container.Name)
+		}
+	}
+
+	switch {
+	case phase == v1.PodSucceeded || phase == v1.PodFailed:
+		condition.Reason = podConditionReasonCompleted
+		condition.LastTransitionTime = lastFinished
+	case len(unready) == 0:
+		condition.Status = v1.ConditionTrue
+		condition.LastTransitionTime = lastStarted
+	default:
+		condition.Reason = podConditionReasonNotReady
+		condition.Message = fmt.Sprintf("containers with unready status: %v", unready)
+		condition.LastTransitionTime = lastFinished
+	}
+	return condition
+}
+
+// getFirstContainerStartTime returns when the first container of the container group started. The
+// start time of a waiting container is when it started waiting.
+func getFirstContainerStartTime(cg *azaci.ContainerGroup) (metav1.Time, bool) {
+	var first metav1.Time
+	for _, container := range *cg.Containers {
+		state := container.InstanceView.CurrentState
+		if state.StartTime == nil || state.StartTime.Time.IsZero() || *state.State == "Waiting" {
+			continue
+		}
+		if first.IsZero() || state.StartTime.Time.Before(first.Time) {
+			first = metav1.NewTime(state.StartTime.Time)
 		}
 	}
-	return []v1.PodCondition{}
+	return first, !first.IsZero()
 }
 
 func getACIResourceMetaFromContainerGroup(cg *azaci.ContainerGroup) (*string, metav1.Time, error) {
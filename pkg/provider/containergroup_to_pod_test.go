@@ -34,17 +34,29 @@ func TestContainerGroupToPodStatus(t *testing.T) {
 		expectedPodPhase      v1.PodPhase
 		expectedPodConditions []v1.PodCondition
 	}{
+		{
+			description:           "Container is Running",
+			containerGroup:        testutil.CreateContainerGroupObj(cgName, cgName, "Running", testutil.CreateACIContainersListObj("Running", "Initializing", startTime, finishTime, false, false, false), "Succeeded"),
+			expectedPodPhase:      getPodPhaseFromACIState("Running"),
+			expectedPodConditions: testutil.GetPodConditions(metav1.NewTime(cgCreationTime), metav1.NewTime(startTime), v1.ConditionTrue),
+		},
 		{
 			description:           "Container is Running/Succeeded",
 			containerGroup:        testutil.CreateContainerGroupObj(cgName, cgName, "Succeeded", testutil.CreateACIContainersListObj("Running", "Initializing", startTime, finishTime, false, false, false), "Succeeded"),
 			expectedPodPhase:      getPodPhaseFromACIState("Succeeded"),
-			expectedPodConditions: testutil.GetPodConditions(metav1.NewTime(cgCreationTime), metav1.NewTime(finishTime), v1.ConditionTrue),
+			expectedPodConditions: testutil.GetPodConditions(metav1.NewTime(cgCreationTime), metav1.NewTime(finishTime), v1.ConditionFalse),
 		},
 		{
 			description:           "Container Failed",
 			containerGroup:        testutil.CreateContainerGroupObj(cgName, cgName, "Failed", testutil.CreateACIContainersListObj("Failed", "Running", startTime, finishTime, false, false, false), "Succeeded"),
 			expectedPodPhase:      getPodPhaseFromACIState("Failed"),
-			expectedPodConditions: []v1.PodCondition{},
+			expectedPodConditions: testutil.GetPodConditions(metav1.NewTime(cgCreationTime), metav1.NewTime(finishTime), v1.ConditionFalse),
+		},
+		{
+			description:           "Container is Waiting",
+			containerGroup:        testutil.CreateContainerGroupObj(cgName, cgName, "Pending", testutil.CreateACIContainersListObj("Waiting", "Initializing", startTime, time.Time{}, false, false, false), "Succeeded"),
+			expectedPodPhase:      getPodPhaseFromACIState("Pending"),
+			expectedPodConditions: testutil.GetPodConditions(metav1.NewTime(cgCreationTime), metav1.NewTime(cgCreationTime), v1.ConditionFalse),
 		},
 	}
 	for _, tc := range cases {
@@ -53,6 +65,13 @@ func TestContainerGroupToPodStatus(t *testing.T) {
 			assert.NilError(t, err, "no errors should be returned")
 			assert.Equal(t, tc.expectedPodPhase, expectedStatus.Phase, "Pod phase is not as expected as current container group phase")
 			assert.Equal(t, len(tc.expectedPodConditions), len(expectedStatus.Conditions), "Pod conditions are not as expected")
+			for i, condition := range expectedStatus.Conditions {
+				assert.Equal(t, tc.expectedPodConditions[i].Type, condition.Type)
+				assert.Equal(t, tc.expectedPodConditions[i].Status, condition.Status, "condition %s", condition.Type)
+				if condition.Type == v1.PodReady || condition.Type == v1.ContainersReady {
+					assert.Check(t, tc.expectedPodConditions[i].LastTransitionTime.Equal(&condition.LastTransitionTime), "condition %s", condition.Type)
+				}
+			}
 		})
 	}
 }
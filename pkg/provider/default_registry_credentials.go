@@ -0,0 +1,115 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	client2 "github.com/virtual-kubelet/azure-aci/pkg/client"
+	"github.com/virtual-kubelet/azure-aci/pkg/secrets"
+	v1 "k8s.io/api/core/v1"
+)
+
+// registryCredentialConfig is a registry credential configured for every pod of the virtual node,
+// authenticated either by username and password or by a user-assigned managed identity.
+type registryCredentialConfig struct {
+	Server   string
+	Username string
+	Password string
+	// PasswordSecret is the name of the password in the secret provider of the deployment, used
+	// instead of Password to keep it out of the config file.
+	PasswordSecret string
+	// Identity is the resource ID of a user-assigned managed identity allowed to pull from the registry.
+	Identity string
+}
+
+func (c registryCredentialConfig) validate() error {
+	if c.Server == "" {
+		return fmt.Errorf("default registry credential is missing the server")
+	}
+	if c.Identity != "" {
+		if c.Username != "" || c.Password != "" || c.PasswordSecret != "" {
+			return fmt.Errorf("default registry credential for %s sets both an identity and a username or password", c.Server)
+		}
+		return nil
+	}
+	if c.Password != "" && c.PasswordSecret != "" {
+		return fmt.Errorf("default registry credential for %s sets both a password and a password secret", c.Server)
+	}
+	if c.Username == "" || (c.Password == "" && c.PasswordSecret == "") {
+		return fmt.Errorf("default registry credential for %s needs a username and password, or an identity", c.Server)
+	}
+	return nil
+}
+
+// resolveDefaultRegistryPasswords reads the passwords of the default registry credentials kept in
+// the secret provider. They are read once at startup.
+func (p *ACIProvider) resolveDefaultRegistryPasswords(ctx context.Context, provider secrets.Provider) error {
+	for i := range p.defaultRegistryCredentials {
+		cred := &p.defaultRegistryCredentials[i]
+		if cred.PasswordSecret == "" {
+			continue
+		}
+		if provider == nil {
+			return fmt.Errorf("default registry credential for %s uses password secret %s, but no secret provider is configured", cred.Server, cred.PasswordSecret)
+		}
+		password, err := provider.GetSecret(ctx, cred.PasswordSecret)
+		if err != nil {
+			return fmt.Errorf("failed to get the password of the default registry credential for %s: %v", cred.Server, err)
+		}
+		cred.Password = password
+	}
+	return nil
+}
+
+// addDefaultRegistryCredentials adds the configured default credentials of the registries the pod
+// pulls from. Credentials of the pod image pull secrets take precedence over them.
+func (p *ACIProvider) addDefaultRegistryCredentials(pod *v1.Pod, cg *client2.ContainerGroupWrapper) {
+	if len(p.defaultRegistryCredentials) == 0 {
+		return
+	}
+
+	cgProperties := cg.ContainerGroupPropertiesWrapper.ContainerGroupProperties
+	creds := []azaci.ImageRegistryCredential{}
+	if cgProperties.ImageRegistryCredentials != nil {
+		creds = *cgProperties.ImageRegistryCredentials
+	}
+	servers := make(map[string]bool, len(creds))
+	for _, cred := range creds {
+		if cred.Server != nil {
+			servers[strings.ToLower(*cred.Server)] = true
+		}
+	}
+
+	registries := make(map[string]bool)
+	for _, containers := range [][]v1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for _, container := range containers {
+			registries[strings.ToLower(getImageRegistry(container.Image))] = true
+		}
+	}
+
+	for i := range p.defaultRegistryCredentials {
+		config := &p.defaultRegistryCredentials[i]
+		server := strings.ToLower(config.Server)
+		if !registries[server] || servers[server] {
+			continue
+		}
+		servers[server] = true
+
+		cred := azaci.ImageRegistryCredential{Server: &config.Server}
+		if config.Identity != "" {
+			cred.Identity = &config.Identity
+			attachUserAssignedIdentity(cg, config.Identity)
+		} else {
+			cred.Username = &config.Username
+			cred.Password = &config.Password
+		}
+		creds = append(creds, cred)
+	}
+	cgProperties.ImageRegistryCredentials = &creds
+}
@@ -0,0 +1,103 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	client2 "github.com/virtual-kubelet/azure-aci/pkg/client"
+	"github.com/virtual-kubelet/azure-aci/pkg/secrets"
+	testsutil "github.com/virtual-kubelet/azure-aci/pkg/tests"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	v1 "k8s.io/api/core/v1"
+)
+
+const defaultRegistryCredentialsCfg = `
+Region = "westus"
+ResourceGroup = "virtual-kubeletrg"
+
+[[DefaultRegistryCredentials]]
+Server = "registry.contoso.com"
+Username = "puller"
+Password = "secret"
+
+[[DefaultRegistryCredentials]]
+Server = "contoso.azurecr.io"
+Identity = "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.ManagedIdentity/userAssignedIdentities/puller"`
+
+func TestAddDefaultRegistryCredentials(t *testing.T) {
+	var p ACIProvider
+	if err := p.loadConfig(bytes.NewReader([]byte(defaultRegistryCredentialsCfg))); err != nil {
+		t.Fatal(err)
+	}
+	assert.Assert(t, is.Equal(2, len(p.defaultRegistryCredentials)))
+
+	server, user, password := "registry.contoso.com", "pod-user", "pod-password"
+	cg := &client2.ContainerGroupWrapper{
+		ContainerGroupPropertiesWrapper: &client2.ContainerGroupPropertiesWrapper{
+			ContainerGroupProperties: &azaci.ContainerGroupProperties{
+				ImageRegistryCredentials: &[]azaci.ImageRegistryCredential{
+					{Server: &server, Username: &user, Password: &password},
+				},
+			},
+		},
+	}
+
+	pod := testsutil.CreatePodObj("pod", "ns")
+	pod.Spec.Containers = []v1.Container{
+		{Name: "app", Image: "registry.contoso.com/app:v1"},
+		{Name: "sidecar", Image: "contoso.azurecr.io/sidecar:v1"},
+	}
+	p.addDefaultRegistryCredentials(pod, cg)
+
+	creds := *cg.ContainerGroupPropertiesWrapper.ContainerGroupProperties.ImageRegistryCredentials
+	assert.Assert(t, is.Equal(2, len(creds)))
+	assert.Check(t, is.Equal("pod-user", *creds[0].Username), "image pull secrets should take precedence")
+	assert.Check(t, is.Equal("contoso.azurecr.io", *creds[1].Server))
+	assert.Check(t, creds[1].Identity != nil, "the identity credential should be used")
+	assert.Assert(t, cg.Identity != nil, "the identity should be attached to the container group")
+	assert.Check(t, is.Equal(1, len(cg.Identity.UserAssignedIdentities)))
+
+	pod.Spec.Containers = []v1.Container{{Name: "app", Image: "nginx"}}
+	cg.ContainerGroupPropertiesWrapper.ContainerGroupProperties.ImageRegistryCredentials = nil
+	cg.Identity = nil
+	p.addDefaultRegistryCredentials(pod, cg)
+	assert.Check(t, is.Equal(0, len(*cg.ContainerGroupPropertiesWrapper.ContainerGroupProperties.ImageRegistryCredentials)), "unused registries should not get credentials")
+	assert.Check(t, cg.Identity == nil)
+}
+
+func TestDefaultRegistryCredentialsConfigValidation(t *testing.T) {
+	var p ACIProvider
+	err := p.loadConfig(bytes.NewReader([]byte(defCfg + `
+
+[[DefaultRegistryCredentials]]
+Server = "registry.contoso.com"
+Username = "puller"`)))
+	assert.Check(t, err != nil, "a credential without password should be rejected")
+}
+
+func TestResolveDefaultRegistryPasswords(t *testing.T) {
+	dir := t.TempDir()
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "REGISTRY_PASSWORD"), []byte("s3cret\n"), 0600))
+
+	p := &ACIProvider{defaultRegistryCredentials: []registryCredentialConfig{
+		{Server: "registry.example.com", Username: "puller", PasswordSecret: "REGISTRY_PASSWORD"},
+		{Server: "other.example.com", Username: "puller", Password: "inline"},
+	}}
+	assert.Check(t, p.resolveDefaultRegistryPasswords(context.Background(), nil) != nil, "password secrets need a secret provider")
+
+	assert.NilError(t, p.resolveDefaultRegistryPasswords(context.Background(), secrets.File{Dir: dir}))
+	assert.Check(t, is.Equal("s3cret", p.defaultRegistryCredentials[0].Password))
+	assert.Check(t, is.Equal("inline", p.defaultRegistryCredentials[1].Password))
+
+	p.defaultRegistryCredentials[0].PasswordSecret = "MISSING"
+	assert.Check(t, p.resolveDefaultRegistryPasswords(context.Background(), secrets.File{Dir: dir}) != nil)
+}
@@ -0,0 +1,73 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// deletionFinalizer keeps a deleted pod until ARM confirmed the deletion of its container group.
+// Without it the pod object is gone as soon as the virtual kubelet asked for the deletion, and a
+// StatefulSet may create the next pod of the same name while ACI still deletes the container group.
+const deletionFinalizer = "virtual-kubelet.io/container-group-deletion"
+
+// addDeletionFinalizer adds the deletion finalizer to the pod before its container group is
+// created, when the provider is configured with DeletionFinalizer.
+func (p *ACIProvider) addDeletionFinalizer(ctx context.Context, pod *v1.Pod) error {
+	if !p.deletionFinalizer || p.kubeClient == nil || pod.DeletionTimestamp != nil || hasDeletionFinalizer(pod) {
+		return nil
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"finalizers": []string{deletionFinalizer},
+		},
+	})
+	if err == nil {
+		_, err = p.kubeClient.CoreV1().Pods(pod.Namespace).Patch(ctx, pod.Name, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to add the deletion finalizer to pod %s/%s", pod.Namespace, pod.Name)
+	}
+	return nil
+}
+
+// removeDeletionFinalizer removes the deletion finalizer once the container group of the pod is
+// gone. It is removed whether DeletionFinalizer is set or not, so pods created while it was set are
+// not left Terminating after it was unset. The deletion of the pod is retried when it fails.
+func (p *ACIProvider) removeDeletionFinalizer(ctx context.Context, pod *v1.Pod) error {
+	if p.kubeClient == nil || !hasDeletionFinalizer(pod) {
+		return nil
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"$deleteFromPrimitiveList/finalizers": []string{deletionFinalizer},
+		},
+	})
+	if err == nil {
+		_, err = p.kubeClient.CoreV1().Pods(pod.Namespace).Patch(ctx, pod.Name, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+	}
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "failed to remove the deletion finalizer of pod %s/%s", pod.Namespace, pod.Name)
+	}
+	log.G(ctx).Infof("removed the deletion finalizer of pod %s/%s", pod.Namespace, pod.Name)
+	return nil
+}
+
+func hasDeletionFinalizer(pod *v1.Pod) bool {
+	for _, finalizer := range pod.Finalizers {
+		if finalizer == deletionFinalizer {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,85 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	testsutil "github.com/virtual-kubelet/azure-aci/pkg/tests"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestDeletionFinalizer(t *testing.T) {
+	deleteErr := errors.New("Conflict")
+	aciMocks := createNewACIMock()
+	aciMocks.MockDeleteContainerGroup = func(ctx context.Context, resourceGroup, cgName string) error {
+		return deleteErr
+	}
+	provider, err := createTestProvider(aciMocks, nil)
+	if err != nil {
+		t.Fatal("failed to create the test provider", err)
+	}
+
+	pod := testsutil.CreatePodObj(podName, podNamespace)
+	pod.Finalizers = []string{"example.com/other"}
+	kubeClient := fake.NewSimpleClientset(pod)
+	provider.kubeClient = kubeClient
+	getPod := func() *v1.Pod {
+		current, err := kubeClient.CoreV1().Pods(podNamespace).Get(context.Background(), podName, metav1.GetOptions{})
+		assert.NilError(t, err)
+		return current
+	}
+
+	assert.NilError(t, provider.addDeletionFinalizer(context.Background(), pod))
+	assert.Check(t, is.Len(getPod().Finalizers, 1), "the finalizer should only be added with DeletionFinalizer")
+
+	provider.deletionFinalizer = true
+	assert.NilError(t, provider.addDeletionFinalizer(context.Background(), pod))
+	finalizers := getPod().Finalizers
+	assert.Check(t, is.Len(finalizers, 2))
+	assert.Check(t, is.Contains(finalizers, "example.com/other"))
+	assert.Check(t, is.Contains(finalizers, deletionFinalizer))
+
+	pod = getPod()
+	err = provider.DeletePod(context.Background(), pod)
+	assert.Check(t, is.ErrorContains(err, "Conflict"))
+	assert.Check(t, hasDeletionFinalizer(getPod()), "the finalizer should be kept until the container group is deleted")
+
+	deleteErr = nil
+	assert.NilError(t, provider.DeletePod(context.Background(), pod))
+	assert.Check(t, is.DeepEqual([]string{"example.com/other"}, getPod().Finalizers), "other finalizers should be kept")
+}
+
+func TestDeletionFinalizerContainerGroupNotFound(t *testing.T) {
+	otherUID := "other-uid"
+	aciMocks := createNewACIMock()
+	aciMocks.MockGetContainerGroupInfo = func(ctx context.Context, resourceGroup, namespace, name, nodeName string) (*azaci.ContainerGroup, error) {
+		return &azaci.ContainerGroup{Tags: map[string]*string{"UID": &otherUID}}, nil
+	}
+	provider, err := createTestProvider(aciMocks, nil)
+	if err != nil {
+		t.Fatal("failed to create the test provider", err)
+	}
+
+	pod := testsutil.CreatePodObj(podName, podNamespace)
+	pod.UID = "uid"
+	pod.Finalizers = []string{deletionFinalizer}
+	kubeClient := fake.NewSimpleClientset(pod)
+	provider.kubeClient = kubeClient
+
+	err = provider.DeletePod(context.Background(), pod)
+	assert.Check(t, errdefs.IsNotFound(err))
+	current, err := kubeClient.CoreV1().Pods(podNamespace).Get(context.Background(), podName, metav1.GetOptions{})
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(current.Finalizers, 0), "pods without a container group should not be kept")
+}
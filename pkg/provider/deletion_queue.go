@@ -0,0 +1,160 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/virtual-kubelet/azure-aci/pkg/metrics"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+const (
+	// defaultMaxConcurrentDeletions keeps half of the ARM write slots for the other operations of
+	// the node while many pods are deleted.
+	defaultMaxConcurrentDeletions = defaultMaxConcurrentARMWrites / 2
+	defaultDeletionsPerSecond     = 5
+)
+
+// deletionWaiter is a pod deletion waiting for its turn.
+type deletionWaiter struct {
+	created time.Time
+	seq     uint64
+	ready   chan struct{}
+	index   int
+}
+
+// deletionHeap orders the waiting deletions by pod age, oldest first, then by arrival.
+type deletionHeap []*deletionWaiter
+
+func (h deletionHeap) Len() int { return len(h) }
+
+func (h deletionHeap) Less(i, j int) bool {
+	if !h[i].created.Equal(h[j].created) {
+		return h[i].created.Before(h[j].created)
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h deletionHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *deletionHeap) Push(x interface{}) {
+	w := x.(*deletionWaiter)
+	w.index = len(*h)
+	*h = append(*h, w)
+}
+
+func (h *deletionHeap) Pop() interface{} {
+	old := *h
+	w := old[len(old)-1]
+	old[len(old)-1] = nil
+	w.index = -1
+	*h = old[:len(old)-1]
+	return w
+}
+
+// deletionQueue paces the container group deletions, so deleting a namespace with hundreds of pods
+// does not use up the ARM write budget of the node and starve the pods that keep running. At most
+// capacity deletions are in flight, at most qps start per second, and the oldest pods go first.
+type deletionQueue struct {
+	capacity int
+	limiter  flowcontrol.RateLimiter
+
+	mu       sync.Mutex
+	inFlight int
+	seq      uint64
+	waiting  deletionHeap
+}
+
+// newDeletionQueue creates a deletion queue. A capacity or qps that is not positive means no limit.
+func newDeletionQueue(capacity int, qps float64) *deletionQueue {
+	q := &deletionQueue{capacity: capacity}
+	if qps > 0 {
+		burst := int(qps)
+		if burst < 1 {
+			burst = 1
+		}
+		q.limiter = flowcontrol.NewTokenBucketRateLimiter(float32(qps), burst)
+	}
+	return q
+}
+
+// acquire waits for the turn of the deletion of a pod created at the given time.
+func (q *deletionQueue) acquire(ctx context.Context, created time.Time) error {
+	if q == nil {
+		return nil
+	}
+	if err := q.acquireSlot(ctx, created); err != nil {
+		return err
+	}
+	if q.limiter != nil {
+		if err := q.limiter.Wait(ctx); err != nil {
+			q.release()
+			return err
+		}
+	}
+	return nil
+}
+
+func (q *deletionQueue) acquireSlot(ctx context.Context, created time.Time) error {
+	if q.capacity <= 0 {
+		return nil
+	}
+
+	q.mu.Lock()
+	if q.inFlight < q.capacity && len(q.waiting) == 0 {
+		q.inFlight++
+		q.mu.Unlock()
+		return nil
+	}
+	q.seq++
+	w := &deletionWaiter{created: created, seq: q.seq, ready: make(chan struct{})}
+	heap.Push(&q.waiting, w)
+	metrics.SetPodDeletionsPending(len(q.waiting))
+	q.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		if w.index >= 0 {
+			heap.Remove(&q.waiting, w.index)
+			metrics.SetPodDeletionsPending(len(q.waiting))
+			return ctx.Err()
+		}
+		// The slot was handed over while giving up, pass it on.
+		q.releaseLocked()
+		return ctx.Err()
+	}
+}
+
+func (q *deletionQueue) release() {
+	if q == nil || q.capacity <= 0 {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.releaseLocked()
+}
+
+// releaseLocked hands the slot to the deletion of the oldest waiting pod.
+func (q *deletionQueue) releaseLocked() {
+	if len(q.waiting) == 0 {
+		q.inFlight--
+		return
+	}
+	w := heap.Pop(&q.waiting).(*deletionWaiter)
+	metrics.SetPodDeletionsPending(len(q.waiting))
+	close(w.ready)
+}
@@ -0,0 +1,92 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func waitForPendingDeletions(t *testing.T, q *deletionQueue, pending int) {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		q.mu.Lock()
+		n := len(q.waiting)
+		q.mu.Unlock()
+		if n == pending {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d pending deletions", pending)
+}
+
+func TestDeletionQueueOldestFirst(t *testing.T) {
+	q := newDeletionQueue(1, 0)
+	assert.NilError(t, q.acquire(context.Background(), time.Now()))
+
+	now := time.Now()
+	granted := make(chan string, 3)
+	pods := []struct {
+		name    string
+		created time.Time
+	}{
+		{"new", now},
+		{"old", now.Add(-time.Hour)},
+		{"older", now.Add(-2 * time.Hour)},
+	}
+	for i, pod := range pods {
+		pod := pod
+		go func() {
+			if err := q.acquire(context.Background(), pod.created); err == nil {
+				granted <- pod.name
+			}
+		}()
+		waitForPendingDeletions(t, q, i+1)
+	}
+
+	var order []string
+	for range pods {
+		q.release()
+		order = append(order, <-granted)
+	}
+	assert.Check(t, is.DeepEqual([]string{"older", "old", "new"}, order), "the oldest pods should be deleted first")
+
+	q.release()
+	assert.Check(t, is.Equal(0, q.inFlight))
+}
+
+func TestDeletionQueueCancelledWaiter(t *testing.T) {
+	q := newDeletionQueue(1, 0)
+	assert.NilError(t, q.acquire(context.Background(), time.Now()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error)
+	go func() {
+		errs <- q.acquire(ctx, time.Now())
+	}()
+	waitForPendingDeletions(t, q, 1)
+	cancel()
+	assert.Check(t, is.Equal(context.Canceled, <-errs))
+	assert.Check(t, is.Equal(0, len(q.waiting)), "cancelled deletion should leave the queue")
+
+	q.release()
+	assert.Check(t, is.Equal(0, q.inFlight))
+	assert.NilError(t, newDeletionQueue(-1, -1).acquire(context.Background(), time.Now()), "negative limits should not limit")
+}
+
+func TestDeletionQueueRateLimit(t *testing.T) {
+	q := newDeletionQueue(-1, 20)
+	start := time.Now()
+	for i := 0; i < 25; i++ {
+		assert.NilError(t, q.acquire(context.Background(), start))
+		q.release()
+	}
+	assert.Check(t, time.Since(start) >= 200*time.Millisecond, "deletions over the burst should be paced")
+}
@@ -0,0 +1,52 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"strings"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	v1 "k8s.io/api/core/v1"
+)
+
+// virtualKubeletDNSNameLabelReusePolicy chooses who may reuse the DNS name label of the pod after
+// its container group is deleted: TenantReuse, SubscriptionReuse, ResourceGroupReuse, Noreuse or
+// Unsecure. Anything but Unsecure adds a hash to the FQDN, so a deleted label cannot be taken over.
+const virtualKubeletDNSNameLabelReusePolicy = "virtualkubelet.io/dnsnamelabel-reuse-policy"
+
+// getDNSNameLabelReusePolicy returns the DNS name label reuse policy of the pod, named case
+// insensitively. It is empty when the pod sets none, in which case ACI uses Unsecure.
+func getDNSNameLabelReusePolicy(pod *v1.Pod) (azaci.AutoGeneratedDomainNameLabelScope, error) {
+	value, ok := pod.Annotations[virtualKubeletDNSNameLabelReusePolicy]
+	if !ok {
+		return "", nil
+	}
+	scopes := azaci.PossibleAutoGeneratedDomainNameLabelScopeValues()
+	names := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if strings.EqualFold(string(scope), strings.TrimSpace(value)) {
+			return scope, nil
+		}
+		names = append(names, string(scope))
+	}
+	return "", errdefs.InvalidInputf("annotation %s has invalid value %q, try one of the following instead: %s", virtualKubeletDNSNameLabelReusePolicy, value, strings.Join(names, " | "))
+}
+
+// setDNSNameLabel sets the DNS name label of the public IP address of the container group and its
+// reuse policy. An invalid policy is rejected even when the pod has no DNS name label.
+func setDNSNameLabel(pod *v1.Pod, ipAddress *azaci.IPAddress) error {
+	policy, err := getDNSNameLabelReusePolicy(pod)
+	if err != nil {
+		return err
+	}
+	dnsNameLabel := pod.Annotations[virtualKubeletDNSNameLabel]
+	if dnsNameLabel == "" || ipAddress == nil {
+		return nil
+	}
+	ipAddress.DNSNameLabel = &dnsNameLabel
+	ipAddress.DNSNameLabelReusePolicy = policy
+	return nil
+}
@@ -0,0 +1,72 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"testing"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	testsutil "github.com/virtual-kubelet/azure-aci/pkg/tests"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestSetDNSNameLabel(t *testing.T) {
+	cases := []struct {
+		name        string
+		annotations map[string]string
+		wantLabel   string
+		wantPolicy  azaci.AutoGeneratedDomainNameLabelScope
+		wantErr     bool
+	}{
+		{
+			name:        "label only",
+			annotations: map[string]string{virtualKubeletDNSNameLabel: "helloworld"},
+			wantLabel:   "helloworld",
+		},
+		{
+			name: "label with reuse policy",
+			annotations: map[string]string{
+				virtualKubeletDNSNameLabel:            "helloworld",
+				virtualKubeletDNSNameLabelReusePolicy: "tenantreuse",
+			},
+			wantLabel:  "helloworld",
+			wantPolicy: azaci.AutoGeneratedDomainNameLabelScopeTenantReuse,
+		},
+		{
+			name:        "reuse policy without label",
+			annotations: map[string]string{virtualKubeletDNSNameLabelReusePolicy: "SubscriptionReuse"},
+		},
+		{
+			name: "invalid reuse policy",
+			annotations: map[string]string{
+				virtualKubeletDNSNameLabel:            "helloworld",
+				virtualKubeletDNSNameLabelReusePolicy: "Global",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			pod := testsutil.CreatePodObj("pod", "ns")
+			pod.Annotations = tc.annotations
+			ipAddress := &azaci.IPAddress{}
+
+			err := setDNSNameLabel(pod, ipAddress)
+			if tc.wantErr {
+				assert.Check(t, errdefs.IsInvalidInput(err), "expected an invalid input error, got %v", err)
+				return
+			}
+			assert.NilError(t, err)
+			if tc.wantLabel == "" {
+				assert.Check(t, ipAddress.DNSNameLabel == nil)
+			} else {
+				assert.Check(t, is.Equal(tc.wantLabel, *ipAddress.DNSNameLabel))
+			}
+			assert.Check(t, is.Equal(tc.wantPolicy, ipAddress.DNSNameLabelReusePolicy))
+		})
+	}
+}
@@ -22,9 +22,10 @@ type DockerConfig map[string]DockerConfigEntry
 
 // DockerConfigEntry wraps a docker config as a entry
 type DockerConfigEntry struct {
-	Username string
-	Password string
-	Email    string
+	Username      string
+	Password      string
+	Email         string
+	IdentityToken string
 }
 
 // dockerConfigEntryWithAuth is used solely for deserializing the Auth field
@@ -38,6 +39,8 @@ type dockerConfigEntryWithAuth struct {
 	Email string `json:"email,omitempty"`
 	// +optional
 	Auth string `json:"auth,omitempty"`
+	// +optional
+	IdentityToken string `json:"identitytoken,omitempty"`
 }
 
 // UnmarshalJSON implements the json.Unmarshaler interface.
@@ -51,6 +54,7 @@ func (ident *DockerConfigEntry) UnmarshalJSON(data []byte) error {
 	ident.Username = tmp.Username
 	ident.Password = tmp.Password
 	ident.Email = tmp.Email
+	ident.IdentityToken = tmp.IdentityToken
 
 	if len(tmp.Auth) == 0 {
 		return nil
@@ -62,7 +66,7 @@ func (ident *DockerConfigEntry) UnmarshalJSON(data []byte) error {
 
 // MarshalJSON implements the json.Marshaler interface.
 func (ident DockerConfigEntry) MarshalJSON() ([]byte, error) {
-	toEncode := dockerConfigEntryWithAuth{ident.Username, ident.Password, ident.Email, ""}
+	toEncode := dockerConfigEntryWithAuth{ident.Username, ident.Password, ident.Email, "", ident.IdentityToken}
 	toEncode.Auth = encodeDockerConfigFieldAuth(ident.Username, ident.Password)
 
 	return json.Marshal(toEncode)
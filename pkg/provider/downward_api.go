@@ -0,0 +1,149 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// getPodFieldValue resolves a downward API field path against the pod as it is known at creation time.
+func getPodFieldValue(pod *v1.Pod, fieldPath string) (string, error) {
+	if key, ok := getSubscriptedKey(fieldPath, "metadata.labels"); ok {
+		return pod.Labels[key], nil
+	}
+	if key, ok := getSubscriptedKey(fieldPath, "metadata.annotations"); ok {
+		return pod.Annotations[key], nil
+	}
+
+	switch fieldPath {
+	case "metadata.name":
+		return pod.Name, nil
+	case "metadata.namespace":
+		return pod.Namespace, nil
+	case "metadata.uid":
+		return string(pod.UID), nil
+	case "metadata.labels":
+		return formatMap(pod.Labels), nil
+	case "metadata.annotations":
+		return formatMap(pod.Annotations), nil
+	case "spec.nodeName":
+		return pod.Spec.NodeName, nil
+	case "spec.serviceAccountName":
+		return pod.Spec.ServiceAccountName, nil
+	}
+
+	return "", fmt.Errorf("field path %s is not supported by the ACI provider", fieldPath)
+}
+
+// getSubscriptedKey parses paths like metadata.labels['key'].
+func getSubscriptedKey(fieldPath, prefix string) (string, bool) {
+	if !strings.HasPrefix(fieldPath, prefix+"['") || !strings.HasSuffix(fieldPath, "']") {
+		return "", false
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(fieldPath, prefix+"['"), "']"), true
+}
+
+// formatMap renders labels and annotations the way the kubelet does, one sorted key="value" per line.
+func formatMap(m map[string]string) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	lines := make([]string, 0, len(keys))
+	for _, k := range keys {
+		lines = append(lines, fmt.Sprintf("%s=%q", k, m[k]))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// findPodContainer returns the container of the pod with the name, looking at the app containers
+// before the init containers.
+func findPodContainer(pod *v1.Pod, name string) *v1.Container {
+	for _, containers := range [][]v1.Container{pod.Spec.Containers, pod.Spec.InitContainers} {
+		for i := range containers {
+			if containers[i].Name == name {
+				return &containers[i]
+			}
+		}
+	}
+	return nil
+}
+
+// getResourceFieldValue resolves a resource field of a container. Limits that are not set fall back
+// to the requests, as ACI sizes the container by its requests.
+func getResourceFieldValue(pod *v1.Pod, containerName string, ref *v1.ResourceFieldSelector) (string, error) {
+	if ref.ContainerName != "" {
+		containerName = ref.ContainerName
+	}
+
+	container := findPodContainer(pod, containerName)
+	if container == nil {
+		return "", fmt.Errorf("container %s referenced by resource field %s does not exist", containerName, ref.Resource)
+	}
+
+	var quantity resource.Quantity
+	var resourceName v1.ResourceName
+	switch {
+	case strings.HasPrefix(ref.Resource, "limits."):
+		resourceName = v1.ResourceName(strings.TrimPrefix(ref.Resource, "limits."))
+		var ok bool
+		if quantity, ok = container.Resources.Limits[resourceName]; !ok {
+			quantity = container.Resources.Requests[resourceName]
+		}
+	case strings.HasPrefix(ref.Resource, "requests."):
+		resourceName = v1.ResourceName(strings.TrimPrefix(ref.Resource, "requests."))
+		quantity = container.Resources.Requests[resourceName]
+	default:
+		return "", fmt.Errorf("resource field %s is not supported by the ACI provider", ref.Resource)
+	}
+
+	divisor := ref.Divisor
+	if divisor.IsZero() {
+		divisor = resource.MustParse("1")
+	}
+
+	if resourceName == v1.ResourceCPU {
+		return fmt.Sprintf("%d", int64(math.Ceil(float64(quantity.MilliValue())/float64(divisor.MilliValue())))), nil
+	}
+	return fmt.Sprintf("%d", int64(math.Ceil(float64(quantity.Value())/float64(divisor.Value())))), nil
+}
+
+// getDownwardAPIPaths renders the downward API files into the base64 encoded content of an ACI
+// secret volume. The values are captured once at creation time, ACI cannot refresh them.
+func getDownwardAPIPaths(pod *v1.Pod, items []v1.DownwardAPIVolumeFile) (map[string]*string, error) {
+	paths := make(map[string]*string, len(items))
+	for _, item := range items {
+		if strings.Contains(item.Path, "/") {
+			return nil, fmt.Errorf("path %s is nested, ACI only supports files at the root of the volume", item.Path)
+		}
+
+		var value string
+		var err error
+		switch {
+		case item.FieldRef != nil:
+			value, err = getPodFieldValue(pod, item.FieldRef.FieldPath)
+		case item.ResourceFieldRef != nil:
+			value, err = getResourceFieldValue(pod, "", item.ResourceFieldRef)
+		default:
+			err = fmt.Errorf("downward API file %s has no field or resource reference", item.Path)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		strV := base64.StdEncoding.EncodeToString([]byte(value))
+		paths[item.Path] = &strV
+	}
+	return paths, nil
+}
@@ -0,0 +1,87 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"encoding/base64"
+	"testing"
+
+	testsutil "github.com/virtual-kubelet/azure-aci/pkg/tests"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestGetDownwardAPIPaths(t *testing.T) {
+	pod := testsutil.CreatePodObj("pod", "ns")
+	pod.Labels = map[string]string{"app": "web", "tier": "frontend"}
+	pod.Spec.Containers[0].Resources.Requests = v1.ResourceList{
+		v1.ResourceCPU:    resource.MustParse("1.5"),
+		v1.ResourceMemory: resource.MustParse("1Gi"),
+	}
+	pod.Spec.Containers[0].Resources.Limits = nil
+
+	items := []v1.DownwardAPIVolumeFile{
+		{Path: "name", FieldRef: &v1.ObjectFieldSelector{FieldPath: "metadata.name"}},
+		{Path: "labels", FieldRef: &v1.ObjectFieldSelector{FieldPath: "metadata.labels"}},
+		{Path: "app", FieldRef: &v1.ObjectFieldSelector{FieldPath: "metadata.labels['app']"}},
+		{Path: "cpu", ResourceFieldRef: &v1.ResourceFieldSelector{
+			ContainerName: pod.Spec.Containers[0].Name,
+			Resource:      "limits.cpu",
+			Divisor:       resource.MustParse("1m"),
+		}},
+		{Path: "mem", ResourceFieldRef: &v1.ResourceFieldSelector{
+			ContainerName: pod.Spec.Containers[0].Name,
+			Resource:      "requests.memory",
+			Divisor:       resource.MustParse("1Mi"),
+		}},
+	}
+
+	paths, err := getDownwardAPIPaths(pod, items)
+	assert.NilError(t, err)
+
+	expected := map[string]string{
+		"name":   "pod",
+		"labels": "app=\"web\"\ntier=\"frontend\"",
+		"app":    "web",
+		"cpu":    "1500",
+		"mem":    "1024",
+	}
+	for path, value := range expected {
+		assert.Check(t, paths[path] != nil, "path %s expected", path)
+		decoded, err := base64.StdEncoding.DecodeString(*paths[path])
+		assert.NilError(t, err)
+		assert.Check(t, is.Equal(value, string(decoded)), "value of %s doesn't match", path)
+	}
+
+	_, err = getDownwardAPIPaths(pod, []v1.DownwardAPIVolumeFile{
+		{Path: "ip", FieldRef: &v1.ObjectFieldSelector{FieldPath: "status.podIP"}},
+	})
+	assert.Check(t, err != nil, "status fields are unknown at creation time")
+}
+
+func TestGetResourceFieldValuePrefersAppContainers(t *testing.T) {
+	pod := testsutil.CreatePodObj("pod", "ns")
+	name := pod.Spec.Containers[0].Name
+	pod.Spec.Containers[0].Resources.Requests = v1.ResourceList{v1.ResourceMemory: resource.MustParse("2Gi")}
+	pod.Spec.InitContainers = []v1.Container{{
+		Name: name,
+		Resources: v1.ResourceRequirements{
+			Requests: v1.ResourceList{v1.ResourceMemory: resource.MustParse("128Mi")},
+		},
+	}}
+
+	value, err := getResourceFieldValue(pod, "", &v1.ResourceFieldSelector{
+		ContainerName: name,
+		Resource:      "requests.memory",
+		Divisor:       resource.MustParse("1Mi"),
+	})
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("2048", value), "the app container should be resolved before the init container of the same name")
+
+	_, err = getResourceFieldValue(pod, "missing", &v1.ResourceFieldSelector{Resource: "requests.memory"})
+	assert.Check(t, is.ErrorContains(err, "container missing referenced by resource field requests.memory does not exist"))
+}
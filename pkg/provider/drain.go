@@ -0,0 +1,139 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/virtual-kubelet/azure-aci/pkg/errcodes"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// DefaultDrainTimeout is how long a drain waits for the pods of the node to terminate.
+	DefaultDrainTimeout = 10 * time.Minute
+
+	drainPollInterval = 5 * time.Second
+)
+
+// drainMode stops the provider from accepting pods, so it can be decommissioned once the pods it
+// runs terminated. It is done when no pod is left or at its deadline, whichever comes first.
+type drainMode struct {
+	mu       sync.Mutex
+	deadline time.Time
+	done     chan struct{}
+}
+
+func (d *drainMode) doneChan() chan struct{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.done == nil {
+		d.done = make(chan struct{})
+	}
+	return d.done
+}
+
+// start enters drain mode with the timeout. It reports false when the provider was already
+// draining, the first deadline is kept.
+func (d *drainMode) start(timeout time.Duration) (time.Time, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.deadline.IsZero() {
+		return d.deadline, false
+	}
+	d.deadline = time.Now().Add(timeout)
+	return d.deadline, true
+}
+
+func (d *drainMode) active() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return !d.deadline.IsZero()
+}
+
+// Drained is closed once a drain completed, the process can exit then.
+func (p *ACIProvider) Drained() <-chan struct{} {
+	return p.drainMode.doneChan()
+}
+
+// StartDrain stops the provider from accepting pods, cordons the node and evicts its pods. The
+// pods keep being tracked until they terminate or the timeout passes, then Drained is closed.
+func (p *ACIProvider) StartDrain(ctx context.Context, timeout time.Duration) error {
+	_, err := p.startDrain(ctx, timeout)
+	return err
+}
+
+func (p *ACIProvider) startDrain(ctx context.Context, timeout time.Duration) (*drainResult, error) {
+	if timeout <= 0 {
+		timeout = DefaultDrainTimeout
+	}
+	deadline, started := p.drainMode.start(timeout)
+	if started {
+		log.G(ctx).Infof("node %s draining, new pods are rejected until %s", p.nodeName, deadline.Format(time.RFC3339))
+		p.recordNodeEvent(v1.EventTypeNormal, "Draining", "Node is draining until %s", deadline.Format(time.RFC3339))
+		// The drain outlives the admin API request that may have started it.
+		go p.waitDrained(log.WithLogger(context.Background(), log.G(ctx)), deadline)
+	}
+
+	result := &drainResult{Evicted: make([]string, 0)}
+	if p.kubeClient != nil {
+		var err error
+		if result, err = p.drain(ctx); err != nil {
+			return nil, err
+		}
+	}
+	result.Deadline = deadline
+	return result, nil
+}
+
+// checkDraining rejects the pod while the provider drains. The error is not an invalid input, the
+// pod controller keeps retrying the pod while its owner replaces it on another node.
+func (p *ACIProvider) checkDraining(pod *v1.Pod) error {
+	if !p.drainMode.active() {
+		return nil
+	}
+	p.recordEvent(pod, v1.EventTypeWarning, "NodeDraining", "Node %s is draining and does not accept pods", p.nodeName)
+	return errcodes.Errorf(errcodes.NodeDraining, "node %s is draining, pod %s is not accepted", p.nodeName, pod.Name)
+}
+
+// waitDrained closes Drained once the pods of the node terminated or the deadline passed.
+func (p *ACIProvider) waitDrained(ctx context.Context, deadline time.Time) {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+
+	done := p.drainMode.doneChan()
+	defer close(done)
+	for {
+		remaining := p.remainingPods()
+		if remaining == 0 {
+			log.G(ctx).Infof("node %s drained", p.nodeName)
+			p.recordNodeEvent(v1.EventTypeNormal, "Drained", "Node is drained")
+			return
+		}
+		select {
+		case <-ticker.C:
+		case <-timer.C:
+			log.G(ctx).Warnf("drain deadline of node %s passed with %d pods left running", p.nodeName, remaining)
+			p.recordNodeEvent(v1.EventTypeWarning, "DrainTimeout", "Drain deadline passed with %d pods left running", remaining)
+			return
+		}
+	}
+}
+
+// remainingPods counts the pods of the node that have not terminated.
+func (p *ACIProvider) remainingPods() int {
+	remaining := 0
+	for _, pod := range p.resourceManager.GetPods() {
+		if pod.Status.Phase != v1.PodSucceeded && pod.Status.Phase != v1.PodFailed {
+			remaining++
+		}
+	}
+	return remaining
+}
@@ -0,0 +1,75 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	testsutil "github.com/virtual-kubelet/azure-aci/pkg/tests"
+	"github.com/virtual-kubelet/node-cli/manager"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func TestDrain(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	running := testsutil.CreatePodObj("running", "ns")
+	running.Status.Phase = v1.PodRunning
+	completed := testsutil.CreatePodObj("completed", "ns")
+	completed.Status.Phase = v1.PodSucceeded
+	pods := []*v1.Pod{running, completed}
+
+	podLister := NewMockPodLister(mockCtrl)
+	podLister.EXPECT().List(labels.Everything()).DoAndReturn(func(labels.Selector) ([]*v1.Pod, error) {
+		return pods, nil
+	}).AnyTimes()
+	rm, err := manager.NewResourceManager(
+		podLister,
+		NewMockSecretLister(mockCtrl),
+		NewMockConfigMapLister(mockCtrl),
+		NewMockServiceLister(mockCtrl),
+		NewMockPersistentVolumeClaimLister(mockCtrl),
+		NewMockPersistentVolumeLister(mockCtrl))
+	if err != nil {
+		t.Fatal("Unable to prepare the mocks for resourceManager", err)
+	}
+
+	p := &ACIProvider{resourceManager: rm, nodeName: "vk"}
+	assert.Check(t, is.Nil(p.checkDraining(running)))
+	assert.Check(t, is.Equal(1, p.remainingPods()), "completed pods should not hold the drain")
+
+	ctx := context.Background()
+	result, err := p.startDrain(ctx, 50*time.Millisecond)
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(result.Evicted, 0), "no pods are evicted without a kubernetes client")
+	assert.Check(t, p.checkDraining(testsutil.CreatePodObj("new", "ns")) != nil, "pods should be rejected while draining")
+
+	again, err := p.startDrain(ctx, time.Hour)
+	assert.NilError(t, err)
+	assert.Check(t, again.Deadline.Equal(result.Deadline), "draining again should keep the deadline")
+
+	select {
+	case <-p.Drained():
+	case <-time.After(5 * time.Second):
+		t.Fatal("the drain should be done at its deadline")
+	}
+
+	p = &ACIProvider{resourceManager: rm, nodeName: "vk"}
+	pods = []*v1.Pod{completed}
+	_, err = p.startDrain(ctx, time.Hour)
+	assert.NilError(t, err)
+	select {
+	case <-p.Drained():
+	case <-time.After(5 * time.Second):
+		t.Fatal("the drain should be done once the pods terminated")
+	}
+}
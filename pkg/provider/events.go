@@ -0,0 +1,102 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/virtual-kubelet/azure-aci/pkg/errcodes"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/record"
+)
+
+const eventComponentName = "virtual-kubelet"
+
+// eventCodes are the error codes of the warning event reasons, prefixed to the event messages.
+var eventCodes = map[string]errcodes.Code{
+	"NodeDraining":                     errcodes.NodeDraining,
+	"UnsupportedFields":                errcodes.UnsupportedFields,
+	"ImageNotPinned":                   errcodes.ImageNotPinned,
+	"RuntimeClassNotSupported":         errcodes.RuntimeClassNotSupported,
+	"ExceedsCapabilities":              errcodes.ExceedsCapabilities,
+	"TooManyContainers":                errcodes.ExceedsCapabilities,
+	"IncompatibleWindowsImage":         errcodes.IncompatibleWindowsImage,
+	"GMSANotSupported":                 errcodes.GMSANotSupported,
+	"AdmissionRejected":                errcodes.AdmissionRejected,
+	"UnsupportedVolume":                errcodes.InvalidVolume,
+	"EmptyDirMediumUnsupported":        errcodes.InvalidVolume,
+	"InvalidEnvironmentVariableNames":  errcodes.InvalidEnvironmentVariableNames,
+	"DownwardAPIFieldUnavailable":      errcodes.DownwardAPIFieldUnavailable,
+	"ContainerGroupProvisioningFailed": errcodes.ProvisioningFailed,
+	"ContainerGroupMigrationFailed":    errcodes.ContainerGroupMigrationFailed,
+	"ContainerGroupMigrationExhausted": errcodes.ContainerGroupMigrationFailed,
+	"StatusUnavailable":                errcodes.StatusUnavailable,
+	"DrainTimeout":                     errcodes.DrainTimeout,
+	"NoisyContainerLogs":               errcodes.NoisyContainerLogs,
+	"ServiceAdvisory":                  errcodes.ServiceAdvisory,
+	"MaintenanceScheduled":             errcodes.ServiceAdvisory,
+	"CapabilityRemoved":                errcodes.CapabilityReduced,
+	"CapabilityReduced":                errcodes.CapabilityReduced,
+	eventReasonNetworkPolicyBypassed:   errcodes.NetworkPolicyNotSupported,
+	podStatusReasonUnsupported:         errcodes.UnsupportedPod,
+}
+
+// eventMessage formats the message of an event, prefixed with the error code of its reason.
+func eventMessage(eventType, reason, messageFmt string, args ...interface{}) string {
+	message := fmt.Sprintf(messageFmt, args...)
+	if code, ok := eventCodes[reason]; ok && eventType == v1.EventTypeWarning {
+		return code.Message(message)
+	}
+	return message
+}
+
+// setupKubeClient creates the Kubernetes client used for events and for objects the
+// resource manager does not cache, and the cache of the service accounts. The provider keeps working without it, so failures
+// are only logged.
+func (p *ACIProvider) setupKubeClient(ctx context.Context) {
+	config, err := clientcmd.BuildConfigFromFlags("", os.Getenv("KUBECONFIG"))
+	if err != nil {
+		log.G(ctx).WithError(err).Warn("unable to load kubernetes client config, pod events will not be published")
+		return
+	}
+
+	kubeClient, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		log.G(ctx).WithError(err).Warn("unable to create kubernetes client, pod events will not be published")
+		return
+	}
+	p.kubeClient = kubeClient
+	p.serviceAccounts = newServiceAccountCache(ctx, kubeClient)
+
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: kubeClient.CoreV1().Events("")})
+	p.eventRecorder = broadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: eventComponentName, Host: p.nodeName})
+}
+
+// recordEvent publishes an event on the pod if an event recorder is available.
+func (p *ACIProvider) recordEvent(pod *v1.Pod, eventType, reason, messageFmt string, args ...interface{}) {
+	if p.eventRecorder == nil || pod == nil {
+		return
+	}
+	p.eventRecorder.Event(pod, eventType, reason, eventMessage(eventType, reason, messageFmt, args...))
+}
+
+// recordNodeEvent publishes an event on the virtual node if an event recorder is available.
+func (p *ACIProvider) recordNodeEvent(eventType, reason, messageFmt string, args ...interface{}) {
+	if p.eventRecorder == nil {
+		return
+	}
+	// Like the kubelet, the node is referenced by name, which is also used as its UID.
+	ref := &v1.ObjectReference{Kind: "Node", Name: p.nodeName, UID: types.UID(p.nodeName)}
+	p.eventRecorder.Event(ref, eventType, reason, eventMessage(eventType, reason, messageFmt, args...))
+}
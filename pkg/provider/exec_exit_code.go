@@ -0,0 +1,138 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	utilexec "k8s.io/utils/exec"
+)
+
+// execExitCodeMarker prefixes the exit code the wrapped exec commands print when they exit. It starts
+// with a record separator so it does not show up in the output of commands.
+const execExitCodeMarker = "\x1eaci-exit-code:"
+
+// getExitCodeExecCommand wraps a non interactive exec command in a POSIX shell printing its exit code
+// after its output, since ACI does not report the exit code of exec commands. The arguments are
+// single quoted so the shell passes them unchanged.
+func getExitCodeExecCommand(cmd []string) string {
+	args := make([]string, 0, len(cmd))
+	for _, arg := range cmd {
+		args = append(args, shellQuote(arg))
+	}
+	script := strings.Join(args, " ") + `; printf '\036aci-exit-code:%d\n' $?`
+	return "/bin/sh -c " + shellQuote(script)
+}
+
+// shellQuote single quotes a word for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// exitCodeWriter removes the exit code printed by a wrapped exec command from its output. Output that
+// may be the start of the exit code is held back until the next write tells.
+type exitCodeWriter struct {
+	w io.WriteCloser
+
+	mu      sync.Mutex
+	pending []byte
+	exited  bool
+}
+
+func newExitCodeWriter(w io.WriteCloser) *exitCodeWriter {
+	return &exitCodeWriter{w: w}
+}
+
+func (e *exitCodeWriter) Write(b []byte) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.exited {
+		// Nothing is written after the exit code but the line ending of the terminal.
+		return len(b), nil
+	}
+	data := append(e.pending, b...)
+	e.pending = nil
+
+	if i := bytes.Index(data, []byte(execExitCodeMarker)); i >= 0 {
+		e.exited = true
+		e.pending = data[i+len(execExitCodeMarker):]
+		data = data[:i]
+	} else {
+		keep := markerPrefixLen(data)
+		e.pending = append([]byte(nil), data[len(data)-keep:]...)
+		data = data[:len(data)-keep]
+	}
+
+	if len(data) > 0 {
+		if _, err := e.w.Write(data); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// markerPrefixLen returns the length of the longest end of the data the exit code marker starts with.
+func markerPrefixLen(data []byte) int {
+	n := len(execExitCodeMarker) - 1
+	if n > len(data) {
+		n = len(data)
+	}
+	for ; n > 0; n-- {
+		if strings.HasPrefix(execExitCodeMarker, string(data[len(data)-n:])) {
+			return n
+		}
+	}
+	return 0
+}
+
+// ExitCode returns the exit code the command printed, if it did.
+func (e *exitCodeWriter) ExitCode() (int, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.exited {
+		return 0, false
+	}
+	digits := strings.TrimSpace(string(e.pending))
+	if i := strings.IndexAny(digits, "\r\n"); i >= 0 {
+		digits = digits[:i]
+	}
+	code, err := strconv.Atoi(digits)
+	if err != nil {
+		return 0, false
+	}
+	return code, true
+}
+
+// Close writes the output held back and closes the underlying writer.
+func (e *exitCodeWriter) Close() error {
+	e.mu.Lock()
+	var pending []byte
+	if !e.exited {
+		pending, e.pending = e.pending, nil
+	}
+	e.mu.Unlock()
+
+	if len(pending) > 0 {
+		if _, err := e.w.Write(pending); err != nil {
+			return err
+		}
+	}
+	return e.w.Close()
+}
+
+// execExitError returns the error kubectl exec reports a non zero exit code with, like the kubelet.
+func execExitError(code int) error {
+	if code == 0 {
+		return nil
+	}
+	return utilexec.CodeExitError{Err: fmt.Errorf("command terminated with exit code %d", code), Code: code}
+}
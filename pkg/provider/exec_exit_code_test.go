@@ -0,0 +1,74 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	utilexec "k8s.io/utils/exec"
+)
+
+func TestGetExitCodeExecCommand(t *testing.T) {
+	assert.Check(t, is.Equal(`/bin/sh -c ''\''ls'\'' '\''-l'\''; printf '\''\036aci-exit-code:%d\n'\'' $?'`,
+		getExitCodeExecCommand([]string{"ls", "-l"})))
+	assert.Check(t, is.Equal(`'it'\''s'`, shellQuote("it's")))
+}
+
+func TestExitCodeWriter(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		writes []string
+		out    string
+		code   int
+		exited bool
+	}{
+		{
+			name:   "exit code after the output",
+			writes: []string{"hello\r\n", "\x1eaci-exit-code:2\r\n"},
+			out:    "hello\r\n",
+			code:   2,
+			exited: true,
+		},
+		{
+			name:   "exit code split across writes",
+			writes: []string{"no newline\x1eaci-", "exit-co", "de:0", "\r\n"},
+			out:    "no newline",
+			exited: true,
+		},
+		{
+			name:   "output looking like the exit code",
+			writes: []string{"a\x1e", "b\x1eaci", "-exit"},
+			out:    "a\x1eb\x1eaci-exit",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			out := &bufferWriteCloser{}
+			w := newExitCodeWriter(out)
+			for _, write := range tc.writes {
+				n, err := w.Write([]byte(write))
+				assert.NilError(t, err)
+				assert.Check(t, is.Equal(len(write), n))
+			}
+			code, exited := w.ExitCode()
+			assert.NilError(t, w.Close())
+			assert.Check(t, is.Equal(tc.out, out.String()))
+			assert.Check(t, is.Equal(tc.exited, exited))
+			assert.Check(t, is.Equal(tc.code, code))
+			assert.Check(t, out.closed)
+		})
+	}
+}
+
+func TestExecExitError(t *testing.T) {
+	assert.NilError(t, execExitError(0))
+
+	err := execExitError(3)
+	exitErr, ok := err.(utilexec.ExitError)
+	assert.Assert(t, ok, "kubectl exec only reports the exit codes of utilexec.ExitError")
+	assert.Check(t, exitErr.Exited())
+	assert.Check(t, is.Equal(3, exitErr.ExitStatus()))
+}
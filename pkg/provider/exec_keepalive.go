@@ -0,0 +1,112 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	"github.com/gorilla/websocket"
+	"github.com/virtual-kubelet/azure-aci/pkg/errcodes"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+)
+
+const (
+	// defaultExecKeepaliveInterval is how often the websocket of an exec session is pinged, so idle
+	// sessions are not dropped by the load balancers in front of ACI.
+	defaultExecKeepaliveInterval = 30 * time.Second
+	// execConnectAttempts is how many times the websocket of an exec is connected. ACI starts the
+	// command once its websocket is connected and cannot resume it on another connection, so only
+	// the connections that failed to open are retried.
+	execConnectAttempts = 3
+	execConnectBackoff  = time.Second
+)
+
+// connectExec starts an exec in ACI and connects its websocket, retrying the connections that fail
+// to open with a new exec. The returned connection is ready for the terminal input.
+func (p *ACIProvider) connectExec(ctx context.Context, cgName, container string, req azaci.ContainerExecRequest) (*websocket.Conn, error) {
+	for attempt := 1; ; attempt++ {
+		xcrsp, err := p.azClientsAPIs.ExecuteContainerCommand(ctx, p.resourceGroup, cgName, container, req)
+		if err != nil {
+			return nil, err
+		}
+
+		c, _, err := p.getExecDialer().DialContext(ctx, *xcrsp.WebSocketURI, nil)
+		if err == nil {
+			// Websocket password needs to be sent before WS terminal is active
+			if err := c.WriteMessage(websocket.TextMessage, []byte(*xcrsp.Password)); err != nil {
+				c.Close()
+				return nil, err
+			}
+			return c, nil
+		}
+		if attempt == execConnectAttempts {
+			return nil, err
+		}
+		log.G(ctx).WithError(err).Warnf("failed to connect to the exec session of container %s in %s, retrying", container, cgName)
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(execConnectBackoff):
+		}
+	}
+}
+
+// handleExecPongs makes the reads of the websocket fail once the connection answered no ping for
+// two intervals, so a dead connection ends the session instead of leaving it hanging. It sets the
+// read side of the connection, which gorilla/websocket only allows from one goroutine, so it must be
+// called by the goroutine reading the output before its reads start. Pongs are handled by the reads.
+func handleExecPongs(c *websocket.Conn, interval time.Duration) {
+	extendExecReadDeadline(c, interval)
+	c.SetPongHandler(func(string) error {
+		return extendExecReadDeadline(c, interval)
+	})
+}
+
+// pingExec pings the websocket every interval until done is closed. It only writes control
+// messages, which may be written concurrently with the reads and writes of the session.
+func pingExec(c *websocket.Conn, interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := c.WriteControl(websocket.PingMessage, nil, time.Now().Add(interval)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// extendExecReadDeadline gives the connection two keepalive intervals to answer a ping or send
+// output.
+func extendExecReadDeadline(c *websocket.Conn, interval time.Duration) error {
+	return c.SetReadDeadline(time.Now().Add(2 * interval))
+}
+
+// execSessionError returns the error ending an exec session, nil when the websocket was closed at
+// the end of the command. ACI does not always send a close frame then, so only connections that
+// timed out or were closed with an error code are reported.
+func execSessionError(err error) error {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return errcodes.Errorf(errcodes.ExecSessionLost, "exec session lost its connection to ACI: no answer to the keepalive pings")
+	}
+	var closeErr *websocket.CloseError
+	if errors.As(err, &closeErr) {
+		switch closeErr.Code {
+		case websocket.CloseNormalClosure, websocket.CloseGoingAway, websocket.CloseNoStatusReceived, websocket.CloseAbnormalClosure:
+			return nil
+		}
+		return errcodes.Errorf(errcodes.ExecSessionLost, "exec session was closed by ACI: %v", closeErr)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	"github.com/gorilla/websocket"
+	testsutil "github.com/virtual-kubelet/azure-aci/pkg/tests"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+// newExecTestProvider returns a provider whose execs connect to the websocket server, and counts
+// the execs started.
+func newExecTestProvider(server *httptest.Server, execs *int) *ACIProvider {
+	aciMocks := createNewACIMock()
+	aciMocks.MockGetContainerGroupInfo = func(ctx context.Context, resourceGroup, namespace, name, nodeName string) (*azaci.ContainerGroup, error) {
+		return testsutil.CreateContainerGroupObj(name, namespace, "Running", &[]azaci.Container{}, "Succeeded"), nil
+	}
+	aciMocks.MockExecuteContainerCommand = func(ctx context.Context, resourceGroup, cgName, containerName string, containerReq azaci.ContainerExecRequest) (azaci.ContainerExecResponse, error) {
+		*execs++
+		uri, password := "wss"+strings.TrimPrefix(server.URL, "https"), "s3cret"
+		return azaci.ContainerExecResponse{WebSocketURI: &uri, Password: &password}, nil
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+	p := &ACIProvider{azClientsAPIs: aciMocks, operatingSystem: "Linux", execKeepaliveInterval: 50 * time.Millisecond}
+	WithExecDialer(&websocket.Dialer{TLSClientConfig: &tls.Config{RootCAs: pool}})(p)
+	return p
+}
+
+// newKeepaliveServer is an exec that outputs after an idle period, on a connection answering the
+// pings or not.
+func newKeepaliveServer(answerPings bool) *httptest.Server {
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		if _, _, err := c.ReadMessage(); err != nil {
+			return
+		}
+		if answerPings {
+			// Pings are answered while the connection is read.
+			go func() {
+				for {
+					if _, _, err := c.ReadMessage(); err != nil {
+						return
+					}
+				}
+			}()
+		}
+		time.Sleep(300 * time.Millisecond)
+		_ = c.WriteMessage(websocket.BinaryMessage, []byte("done"))
+		_ = c.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	}))
+}
+
+func TestExecKeepalive(t *testing.T) {
+	server := newKeepaliveServer(true)
+	defer server.Close()
+	execs := 0
+	out := &bufferWriteCloser{}
+	err := newExecTestProvider(server, &execs).RunInContainer(context.Background(), "ns", "web", "nginx", []string{"/bin/sh"}, &fakeAttachIO{stdout: out})
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("done", out.String()), "idle sessions answering the pings should stay open")
+
+	deadServer := newKeepaliveServer(false)
+	defer deadServer.Close()
+	out = &bufferWriteCloser{}
+	err = newExecTestProvider(deadServer, &execs).RunInContainer(context.Background(), "ns", "web", "nginx", []string{"/bin/sh"}, &fakeAttachIO{stdout: out})
+	assert.Check(t, is.ErrorContains(err, "ACIP-035: exec session lost its connection to ACI"))
+	assert.Check(t, is.Equal("", out.String()))
+}
+
+func TestConnectExecRetries(t *testing.T) {
+	var requests int32
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		c, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		if _, _, err := c.ReadMessage(); err != nil {
+			return
+		}
+		_ = c.WriteMessage(websocket.BinaryMessage, []byte("hello"))
+		_ = c.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	}))
+	defer server.Close()
+
+	execs := 0
+	p := newExecTestProvider(server, &execs)
+	out := &bufferWriteCloser{}
+	err := p.RunInContainer(context.Background(), "ns", "web", "nginx", []string{"/bin/sh"}, &fakeAttachIO{stdout: out})
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("hello", out.String()))
+	assert.Check(t, is.Equal(2, execs), "failed connections should be retried with a new exec")
+}
+
+func TestExecSessionError(t *testing.T) {
+	assert.Check(t, execSessionError(nil) == nil)
+	assert.Check(t, execSessionError(errors.New("use of closed network connection")) == nil)
+	assert.Check(t, execSessionError(&websocket.CloseError{Code: websocket.CloseNormalClosure}) == nil)
+	assert.Check(t, execSessionError(&websocket.CloseError{Code: websocket.CloseAbnormalClosure}) == nil, "ACI may end sessions without a close frame")
+	assert.Check(t, is.ErrorContains(execSessionError(&websocket.CloseError{Code: websocket.CloseInternalServerErr, Text: "boom"}), "ACIP-035: exec session was closed by ACI"))
+}
@@ -0,0 +1,199 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	"github.com/gorilla/websocket"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	"github.com/virtual-kubelet/virtual-kubelet/node/api"
+)
+
+// execResizeTimeout is how long the exec resizing the terminal of a session may take.
+const execResizeTimeout = 30 * time.Second
+
+// execTTYMarker prefixes the terminal device the wrapped interactive exec commands print when they
+// start. It starts with a record separator so it does not show up in the output of commands.
+const execTTYMarker = "\x1eaci-exec-tty:"
+
+// ttyDeviceRegexp matches the terminal devices the resizes are applied to, so the output of the
+// container is never run as part of a command.
+var ttyDeviceRegexp = regexp.MustCompile(`^/dev/[a-zA-Z0-9/]+$`)
+
+// getResizableExecCommand wraps an interactive exec command in a POSIX shell printing the terminal
+// device of the session before it starts the command, so the resizes of the client can be applied to
+// that terminal from another exec. ACI only sizes the terminal when the exec starts.
+func getResizableExecCommand(cmd []string) string {
+	args := make([]string, 0, len(cmd))
+	for _, arg := range cmd {
+		args = append(args, shellQuote(arg))
+	}
+	script := `printf '\036aci-exec-tty:%s\n' "$(tty)"; exec ` + strings.Join(args, " ")
+	return "/bin/sh -c " + shellQuote(script)
+}
+
+// getExecResizeCommand sets the size of the terminal device, which signals SIGWINCH to the programs
+// running in it.
+func getExecResizeCommand(tty string, size api.TermSize) string {
+	return "/bin/sh -c " + shellQuote(fmt.Sprintf("stty cols %d rows %d < %s", size.Width, size.Height, tty))
+}
+
+// ttyWriter removes the terminal device printed by a wrapped interactive exec command from the start
+// of its output. Output that may be the start of the device is held back until the next write tells.
+type ttyWriter struct {
+	w io.WriteCloser
+
+	mu      sync.Mutex
+	pending []byte
+	started bool
+	tty     string
+}
+
+func newTTYWriter(w io.WriteCloser) *ttyWriter {
+	return &ttyWriter{w: w}
+}
+
+func (t *ttyWriter) Write(b []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.started {
+		if _, err := t.w.Write(b); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	data := append(t.pending, b...)
+	t.pending = nil
+
+	if bytes.HasPrefix(data, []byte(execTTYMarker)) {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			t.pending = data
+			return len(b), nil
+		}
+		if tty := strings.TrimSpace(string(data[len(execTTYMarker):i])); ttyDeviceRegexp.MatchString(tty) {
+			t.tty = tty
+		}
+		data = data[i+1:]
+	} else if strings.HasPrefix(execTTYMarker, string(data)) {
+		t.pending = data
+		return len(b), nil
+	}
+	t.started = true
+
+	if len(data) > 0 {
+		if _, err := t.w.Write(data); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// TTY returns the terminal device the command printed, if it did.
+func (t *ttyWriter) TTY() (string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.tty, t.tty != ""
+}
+
+// Close writes the output held back and closes the underlying writer.
+func (t *ttyWriter) Close() error {
+	t.mu.Lock()
+	pending := t.pending
+	t.pending = nil
+	t.mu.Unlock()
+
+	if len(pending) > 0 {
+		if _, err := t.w.Write(pending); err != nil {
+			return err
+		}
+	}
+	return t.w.Close()
+}
+
+// forwardTerminalResizes applies the resizes of the terminal of the client to the terminal of the
+// session until done is closed, each with an exec running stty. Resizes sent while one is applied are
+// coalesced into the last one, since every exec is an ARM call. The session starts with the initial size.
+func (p *ACIProvider) forwardTerminalResizes(ctx context.Context, cgName, container string, tty *ttyWriter, initial api.TermSize, resize <-chan api.TermSize, done <-chan struct{}) {
+	if resize == nil {
+		return
+	}
+	applied := initial
+	for {
+		var size api.TermSize
+		select {
+		case <-done:
+			return
+		case s, ok := <-resize:
+			if !ok {
+				return
+			}
+			size = s
+		}
+	coalesce:
+		for {
+			select {
+			case s, ok := <-resize:
+				if !ok {
+					return
+				}
+				size = s
+			default:
+				break coalesce
+			}
+		}
+
+		device, ok := tty.TTY()
+		if !ok || size == applied || size.Width == 0 || size.Height == 0 {
+			continue
+		}
+		if err := p.resizeExecTerminal(ctx, cgName, container, device, size); err != nil {
+			log.G(ctx).WithError(err).Warnf("failed to resize the exec terminal to %dx%d", size.Width, size.Height)
+			continue
+		}
+		applied = size
+	}
+}
+
+// resizeExecTerminal runs stty in another exec of the container and waits for it to end.
+func (p *ACIProvider) resizeExecTerminal(ctx context.Context, cgName, container, tty string, size api.TermSize) error {
+	cmd := getExecResizeCommand(tty, size)
+	cols, rows := defaultExecTerminalCols, defaultExecTerminalRows
+	c, err := p.connectExec(ctx, cgName, container, azaci.ContainerExecRequest{
+		Command: &cmd,
+		TerminalSize: &azaci.ContainerExecRequestTerminalSize{
+			Cols: &cols,
+			Rows: &rows,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if err := c.SetReadDeadline(time.Now().Add(execResizeTimeout)); err != nil {
+		return err
+	}
+	for {
+		if _, _, err := c.NextReader(); err != nil {
+			var closeErr *websocket.CloseError
+			if errors.As(err, &closeErr) {
+				return nil
+			}
+			return err
+		}
+	}
+}
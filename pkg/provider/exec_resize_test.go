@@ -0,0 +1,139 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	"github.com/gorilla/websocket"
+	"github.com/virtual-kubelet/virtual-kubelet/node/api"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestGetResizableExecCommand(t *testing.T) {
+	assert.Check(t, is.Equal(`/bin/sh -c 'printf '\''\036aci-exec-tty:%s\n'\'' "$(tty)"; exec '\''bash'\'''`,
+		getResizableExecCommand([]string{"bash"})))
+	assert.Check(t, is.Equal(`/bin/sh -c 'stty cols 200 rows 50 < /dev/pts/3'`,
+		getExecResizeCommand("/dev/pts/3", api.TermSize{Width: 200, Height: 50})))
+}
+
+func TestTTYWriter(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		writes []string
+		out    string
+		tty    string
+	}{
+		{
+			name:   "terminal before the output",
+			writes: []string{"\x1eaci-exec-tty:/dev/pts/0\r\n", "$ "},
+			out:    "$ ",
+			tty:    "/dev/pts/0",
+		},
+		{
+			name:   "terminal split across writes",
+			writes: []string{"\x1eaci-", "exec-tty:/dev/", "pts/1\r\n$ "},
+			out:    "$ ",
+			tty:    "/dev/pts/1",
+		},
+		{
+			name:   "no terminal",
+			writes: []string{"\x1eaci-exec", "-tty? not a tty\r\n"},
+			out:    "\x1eaci-exec-tty? not a tty\r\n",
+		},
+		{
+			name:   "not a terminal device",
+			writes: []string{"\x1eaci-exec-tty:not a tty\r\n", "$ "},
+			out:    "$ ",
+		},
+		{
+			name:   "terminal printed later",
+			writes: []string{"$ ", "\x1eaci-exec-tty:/dev/pts/2\r\n"},
+			out:    "$ \x1eaci-exec-tty:/dev/pts/2\r\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			out := &bufferWriteCloser{}
+			w := newTTYWriter(out)
+			for _, write := range tc.writes {
+				n, err := w.Write([]byte(write))
+				assert.NilError(t, err)
+				assert.Check(t, is.Equal(len(write), n))
+			}
+			tty, ok := w.TTY()
+			assert.NilError(t, w.Close())
+			assert.Check(t, is.Equal(tc.out, out.String()))
+			assert.Check(t, is.Equal(tc.tty, tty))
+			assert.Check(t, is.Equal(tc.tty != "", ok))
+			assert.Check(t, out.closed)
+		})
+	}
+}
+
+func TestForwardTerminalResizes(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		if _, _, err := c.ReadMessage(); err != nil {
+			return
+		}
+		_ = c.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	}))
+	defer server.Close()
+
+	commands := make(chan string, 10)
+	aciMocks := createNewACIMock()
+	aciMocks.MockExecuteContainerCommand = func(ctx context.Context, resourceGroup, cgName, containerName string, containerReq azaci.ContainerExecRequest) (azaci.ContainerExecResponse, error) {
+		commands <- *containerReq.Command
+		uri, password := "wss"+strings.TrimPrefix(server.URL, "https"), "s3cret"
+		return azaci.ContainerExecResponse{WebSocketURI: &uri, Password: &password}, nil
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+	p := &ACIProvider{azClientsAPIs: aciMocks, operatingSystem: "Linux"}
+	WithExecDialer(&websocket.Dialer{TLSClientConfig: &tls.Config{RootCAs: pool}})(p)
+
+	tty := newTTYWriter(&bufferWriteCloser{})
+	_, err := tty.Write([]byte("\x1eaci-exec-tty:/dev/pts/0\r\n"))
+	assert.NilError(t, err)
+
+	resize := make(chan api.TermSize)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		p.forwardTerminalResizes(context.Background(), "cg", "app", tty, api.TermSize{Width: 80, Height: 24}, resize, done)
+		close(stopped)
+	}()
+
+	resize <- api.TermSize{Width: 80, Height: 24}
+	resize <- api.TermSize{Width: 200, Height: 50}
+	select {
+	case cmd := <-commands:
+		assert.Check(t, is.Equal(getExecResizeCommand("/dev/pts/0", api.TermSize{Width: 200, Height: 50}), cmd),
+			"the initial size should not be applied again")
+	case <-time.After(5 * time.Second):
+		t.Fatal("the resize should be applied")
+	}
+
+	close(done)
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the resizes should stop being forwarded once the session ended")
+	}
+}
@@ -0,0 +1,97 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// execSessionLimits closes exec sessions left unattended. A session expires when neither input
+// nor output went through for the idle timeout, or when it lasted the max duration. A zero limit
+// is disabled.
+type execSessionLimits struct {
+	idleTimeout time.Duration
+	maxDuration time.Duration
+
+	mu           sync.Mutex
+	start        time.Time
+	lastActivity time.Time
+	expired      string
+}
+
+func newExecSessionLimits(idleTimeout, maxDuration time.Duration, now time.Time) *execSessionLimits {
+	return &execSessionLimits{
+		idleTimeout:  idleTimeout,
+		maxDuration:  maxDuration,
+		start:        now,
+		lastActivity: now,
+	}
+}
+
+func (l *execSessionLimits) enabled() bool {
+	return l.idleTimeout > 0 || l.maxDuration > 0
+}
+
+// touch records input or output going through the session.
+func (l *execSessionLimits) touch(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lastActivity = now
+}
+
+// check returns when the session expires and, if it already did at now, why.
+func (l *execSessionLimits) check(now time.Time) (time.Time, string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var deadline time.Time
+	if l.maxDuration > 0 {
+		deadline = l.start.Add(l.maxDuration)
+		if !now.Before(deadline) {
+			return deadline, fmt.Sprintf("exec session reached the maximum duration of %s", l.maxDuration)
+		}
+	}
+	if l.idleTimeout > 0 {
+		idleDeadline := l.lastActivity.Add(l.idleTimeout)
+		if !now.Before(idleDeadline) {
+			return idleDeadline, fmt.Sprintf("exec session was idle for %s", l.idleTimeout)
+		}
+		if deadline.IsZero() || idleDeadline.Before(deadline) {
+			deadline = idleDeadline
+		}
+	}
+	return deadline, ""
+}
+
+// watch calls expire once the session expires, unless done is closed first.
+func (l *execSessionLimits) watch(done <-chan struct{}, expire func(reason string)) {
+	for {
+		deadline, reason := l.check(time.Now())
+		if reason != "" {
+			l.mu.Lock()
+			l.expired = reason
+			l.mu.Unlock()
+			expire(reason)
+			return
+		}
+
+		timer := time.NewTimer(time.Until(deadline))
+		select {
+		case <-done:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// expiredReason returns why the session was closed, or an empty string if it did not expire.
+func (l *execSessionLimits) expiredReason() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.expired
+}
@@ -0,0 +1,67 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestExecSessionLimitsCheck(t *testing.T) {
+	start := time.Now()
+	limits := newExecSessionLimits(time.Minute, time.Hour, start)
+	assert.Check(t, limits.enabled())
+
+	deadline, reason := limits.check(start.Add(30 * time.Second))
+	assert.Check(t, is.Equal("", reason))
+	assert.Check(t, deadline.Equal(start.Add(time.Minute)), "the idle timeout should come first")
+
+	limits.touch(start.Add(50 * time.Second))
+	_, reason = limits.check(start.Add(90 * time.Second))
+	assert.Check(t, is.Equal("", reason), "activity should reset the idle timeout")
+
+	_, reason = limits.check(start.Add(2 * time.Minute))
+	assert.Check(t, strings.Contains(reason, "idle"), reason)
+
+	limits.touch(start.Add(time.Hour - time.Second))
+	_, reason = limits.check(start.Add(time.Hour))
+	assert.Check(t, strings.Contains(reason, "maximum duration"), reason)
+
+	assert.Check(t, !newExecSessionLimits(0, 0, start).enabled())
+}
+
+func TestExecSessionLimitsWatch(t *testing.T) {
+	limits := newExecSessionLimits(20*time.Millisecond, 0, time.Now())
+	expired := make(chan string, 1)
+	go limits.watch(make(chan struct{}), func(reason string) {
+		expired <- reason
+	})
+
+	select {
+	case reason := <-expired:
+		assert.Check(t, is.Equal(reason, limits.expiredReason()))
+	case <-time.After(5 * time.Second):
+		t.Fatal("the idle session should have expired")
+	}
+
+	limits = newExecSessionLimits(time.Hour, 0, time.Now())
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		limits.watch(done, func(string) {})
+		close(stopped)
+	}()
+	close(done)
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("closing done should stop the watch")
+	}
+	assert.Check(t, is.Equal("", limits.expiredReason()))
+}
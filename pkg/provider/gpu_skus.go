@@ -0,0 +1,147 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// GPU SKUs offered by ACI that are newer than the SDK enums.
+	gpuSKUT4   azaci.GpuSku = "T4"
+	gpuSKUA100 azaci.GpuSku = "A100"
+
+	// gpuSKULabelPrefix prefixes the node labels advertising the GPU SKUs of the region,
+	// e.g. virtual-kubelet.io/gpu-sku-v100=true.
+	gpuSKULabelPrefix = "virtual-kubelet.io/gpu-sku-"
+
+	defaultGPUQuota = "100"
+)
+
+// knownGPUSKUs orders the GPU SKUs. The first SKU of the region is used by pods that do not
+// choose one, SKUs unknown to the provider come last.
+var knownGPUSKUs = []azaci.GpuSku{
+	azaci.GpuSkuK80,
+	azaci.GpuSkuP100,
+	azaci.GpuSkuV100,
+	gpuSKUT4,
+	gpuSKUA100,
+}
+
+func gpuSKURank(sku azaci.GpuSku) int {
+	for i, known := range knownGPUSKUs {
+		if strings.EqualFold(string(known), string(sku)) {
+			return i
+		}
+	}
+	return len(knownGPUSKUs)
+}
+
+// refreshGPUSKUs refreshes the capabilities of the region and reloads the GPU SKUs offered to the
+// OS of the node. It reports whether they changed, failures keep the known SKUs.
+func (p *ACIProvider) refreshGPUSKUs(ctx context.Context) bool {
+	before := p.capabilities.snapshot()
+	if err := p.capabilities.refresh(ctx); err != nil {
+		log.G(ctx).WithError(err).Warnf("keeping the GPU SKUs %v", p.getGPUSKUs())
+		return false
+	}
+	p.reportCapabilityDrift(ctx, before, p.capabilities.snapshot())
+	skus := p.capabilities.gpuSKUs(p.operatingSystem)
+
+	gpu := ""
+	if len(skus) != 0 {
+		gpu = defaultGPUQuota
+		if quota := os.Getenv("ACI_QUOTA_GPU"); quota != "" {
+			gpu = quota
+		}
+	}
+
+	p.gpuMutex.Lock()
+	defer p.gpuMutex.Unlock()
+	changed := p.gpu != gpu || len(p.gpuSKUs) != len(skus)
+	for i := 0; !changed && i < len(skus); i++ {
+		changed = p.gpuSKUs[i] != skus[i]
+	}
+	if changed {
+		log.G(ctx).Infof("GPU SKUs of the location %s changed from %v to %v", p.region, p.gpuSKUs, skus)
+	}
+	p.gpu = gpu
+	p.gpuSKUs = skus
+	return changed
+}
+
+func (p *ACIProvider) getGPUSKUs() []azaci.GpuSku {
+	p.gpuMutex.RLock()
+	defer p.gpuMutex.RUnlock()
+	return p.gpuSKUs
+}
+
+// getGPUSKULabels returns the node labels for the GPU SKUs of the region. Labels of SKUs that are
+// no longer offered are set to nil, so they can be removed with a merge patch.
+func (p *ACIProvider) getGPUSKULabels(current map[string]string) map[string]*string {
+	labels := make(map[string]*string)
+	for key := range current {
+		if strings.HasPrefix(key, gpuSKULabelPrefix) {
+			labels[key] = nil
+		}
+	}
+	value := "true"
+	for _, sku := range p.getGPUSKUs() {
+		labels[gpuSKULabelPrefix+strings.ToLower(string(sku))] = &value
+	}
+	return labels
+}
+
+func applyLabels(node *v1.Node, labels map[string]*string) {
+	if node.Labels == nil {
+		node.Labels = make(map[string]string)
+	}
+	for key, value := range labels {
+		if value == nil {
+			delete(node.Labels, key)
+			continue
+		}
+		node.Labels[key] = *value
+	}
+}
+
+// updateNodeGPUSKUs pushes the GPU capacity and labels after the SKUs of the region changed. The
+// node status notification only updates the status, so the labels are patched separately.
+func (p *ACIProvider) updateNodeGPUSKUs(ctx context.Context, notifierCb func(*v1.Node)) {
+	p.nodeMutex.Lock()
+	if p.node == nil {
+		p.nodeMutex.Unlock()
+		return
+	}
+	node := p.node.DeepCopy()
+	labels := p.getGPUSKULabels(node.Labels)
+	applyLabels(node, labels)
+	node.Status.Capacity = p.capacity()
+	node.Status.Allocatable = p.capacity()
+	p.node = node.DeepCopy()
+	p.nodeMutex.Unlock()
+
+	if p.kubeClient != nil {
+		patch, err := json.Marshal(map[string]interface{}{
+			"metadata": map[string]interface{}{"labels": labels},
+		})
+		if err == nil {
+			_, err = p.kubeClient.CoreV1().Nodes().Patch(ctx, node.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+		}
+		if err != nil {
+			log.G(ctx).WithError(err).Warnf("failed to update the GPU SKU labels of node %s", node.Name)
+		}
+	}
+	notifierCb(node)
+}
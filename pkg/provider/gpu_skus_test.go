@@ -0,0 +1,83 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func gpuCapabilities(location string, skus ...string) *[]azaci.Capabilities {
+	capabilities := make([]azaci.Capabilities, 0, len(skus))
+	for i := range skus {
+		capabilities = append(capabilities, azaci.Capabilities{Location: &location, Gpu: &skus[i]})
+	}
+	return &capabilities
+}
+
+func TestRefreshGPUSKUs(t *testing.T) {
+	capabilities := gpuCapabilities("westus2", "V100")
+	var listErr error
+	aciMocks := NewMockACIProvider(func(ctx context.Context, region string) (*[]azaci.Capabilities, error) {
+		return capabilities, listErr
+	})
+	p := &ACIProvider{region: "westus2", capabilities: newCapabilityService(aciMocks, "westus2")}
+
+	assert.Check(t, p.refreshGPUSKUs(context.Background()))
+	assert.Check(t, is.DeepEqual([]azaci.GpuSku{azaci.GpuSkuV100}, p.getGPUSKUs()))
+	assert.Check(t, is.Equal(defaultGPUQuota, p.gpu))
+	assert.Check(t, !p.refreshGPUSKUs(context.Background()), "unchanged SKUs should not be reported")
+
+	listErr = errors.New("service unavailable")
+	capabilities = nil
+	assert.Check(t, !p.refreshGPUSKUs(context.Background()))
+	assert.Check(t, is.Len(p.getGPUSKUs(), 1), "a failed refresh should keep the known SKUs")
+
+	listErr = nil
+	capabilities = gpuCapabilities("westus2", "T4")
+	assert.Check(t, p.refreshGPUSKUs(context.Background()))
+
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{gpuTypeAnnotation: "t4"}}}
+	sku, err := p.getGPUSKU(pod)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(gpuSKUT4, sku))
+}
+
+func TestUpdateNodeGPUSKUs(t *testing.T) {
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{
+		Name:   "vk",
+		Labels: map[string]string{gpuSKULabelPrefix + "k80": "true", "type": "virtual-kubelet"},
+	}}
+	p := &ACIProvider{
+		cpu:        "10",
+		memory:     "10Gi",
+		pods:       "10",
+		gpu:        defaultGPUQuota,
+		gpuSKUs:    []azaci.GpuSku{gpuSKUT4},
+		node:       node.DeepCopy(),
+		kubeClient: fake.NewSimpleClientset(node),
+	}
+
+	var notified *v1.Node
+	p.updateNodeGPUSKUs(context.Background(), func(n *v1.Node) { notified = n })
+	assert.Assert(t, notified != nil)
+	_, hasK80 := notified.Labels[gpuSKULabelPrefix+"k80"]
+	assert.Check(t, !hasK80, "labels of SKUs no longer offered should be removed")
+	assert.Check(t, is.Equal("true", notified.Labels[gpuSKULabelPrefix+"t4"]))
+	gpu := notified.Status.Capacity[gpuResourceName]
+	assert.Check(t, is.Equal(int64(100), gpu.Value()))
+
+	patched, err := p.kubeClient.CoreV1().Nodes().Get(context.Background(), "vk", metav1.GetOptions{})
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(map[string]string{gpuSKULabelPrefix + "t4": "true", "type": "virtual-kubelet"}, patched.Labels))
+}
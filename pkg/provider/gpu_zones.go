@@ -0,0 +1,197 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	client2 "github.com/virtual-kubelet/azure-aci/pkg/client"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	v1 "k8s.io/api/core/v1"
+)
+
+// gpuZoneFailureWindow is how long a capacity failure counts against a zone. ACI does not report
+// the GPU capacity of the zones, so recent failures are the only signal.
+const gpuZoneFailureWindow = 30 * time.Minute
+
+// capacityErrors are the ARM error codes and messages of a zone or region out of capacity.
+var capacityErrors = []string{
+	"ServiceUnavailable",
+	"SkuNotAvailable",
+	"InsufficientCapacity",
+	"ZonalAllocationFailed",
+	"not available in the location",
+	"not have enough capacity",
+}
+
+type gpuZoneKey struct {
+	zone string
+	sku  azaci.GpuSku
+}
+
+// gpuZones spreads the GPU container groups over the zones of the region, picking the zone with the
+// fewest recent capacity failures for their GPU SKU. Failures are recorded by the creations and by
+// the status updates of the container groups failing to provision.
+type gpuZones struct {
+	zones []string
+
+	mu       sync.Mutex
+	failures map[gpuZoneKey][]time.Time
+	// observed are the container groups whose provisioning failure was recorded, by zone.
+	observed map[string]string
+	// next rotates the zones tied on failures.
+	next int
+}
+
+func newGPUZones(zones []string) *gpuZones {
+	return &gpuZones{
+		zones:    zones,
+		failures: make(map[gpuZoneKey][]time.Time),
+		observed: make(map[string]string),
+	}
+}
+
+// rank returns the zones from the most to the least likely to have capacity for the SKU. Zones with
+// as many recent failures are rotated so consecutive container groups spread over them.
+func (g *gpuZones) rank(sku azaci.GpuSku, now time.Time) []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	counts := make(map[string]int, len(g.zones))
+	ranked := make([]string, 0, len(g.zones))
+	for i := range g.zones {
+		zone := g.zones[(g.next+i)%len(g.zones)]
+		counts[zone] = g.recentFailures(gpuZoneKey{zone: zone, sku: sku}, now)
+		ranked = append(ranked, zone)
+	}
+	g.next = (g.next + 1) % len(g.zones)
+
+	// Insertion sort keeps the rotation among the zones with as many failures.
+	for i := 1; i < len(ranked); i++ {
+		for j := i; j > 0 && counts[ranked[j]] < counts[ranked[j-1]]; j-- {
+			ranked[j], ranked[j-1] = ranked[j-1], ranked[j]
+		}
+	}
+	return ranked
+}
+
+// recordFailure counts a capacity failure of the zone for the SKU.
+func (g *gpuZones) recordFailure(zone string, sku azaci.GpuSku, now time.Time) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	key := gpuZoneKey{zone: zone, sku: sku}
+	g.recentFailures(key, now)
+	g.failures[key] = append(g.failures[key], now)
+}
+
+// recentFailures drops the failures out of the window and returns the others. Callers hold the lock.
+func (g *gpuZones) recentFailures(key gpuZoneKey, now time.Time) int {
+	failures := g.failures[key]
+	i := 0
+	for ; i < len(failures); i++ {
+		if now.Sub(failures[i]) <= gpuZoneFailureWindow {
+			break
+		}
+	}
+	if i == len(failures) {
+		delete(g.failures, key)
+		return 0
+	}
+	g.failures[key] = failures[i:]
+	return len(failures) - i
+}
+
+// observe records the provisioning failure of a container group once per zone it was created in.
+func (g *gpuZones) observe(cg *azaci.ContainerGroup, now time.Time) {
+	if cg.Name == nil || cg.Zones == nil || len(*cg.Zones) != 1 || cg.ContainerGroupProperties == nil {
+		return
+	}
+	sku, ok := containersGPUSKU(cg.Containers)
+	if !ok {
+		return
+	}
+	zone := (*cg.Zones)[0]
+	state, reason, _ := provisioningState(cg)
+
+	g.mu.Lock()
+	failed := state == "Failed" && reason == provisioningReasonFailed
+	recorded := g.observed[*cg.Name] == zone
+	if !failed {
+		delete(g.observed, *cg.Name)
+	} else if !recorded {
+		g.observed[*cg.Name] = zone
+	}
+	g.mu.Unlock()
+
+	if failed && !recorded {
+		g.recordFailure(zone, sku, now)
+	}
+}
+
+// forget drops the container group of a deleted pod.
+func (g *gpuZones) forget(cgName string) {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.observed, cgName)
+}
+
+// containersGPUSKU returns the GPU SKU requested by the containers, if any.
+func containersGPUSKU(containers *[]azaci.Container) (azaci.GpuSku, bool) {
+	if containers == nil {
+		return "", false
+	}
+	for _, container := range *containers {
+		if container.Resources == nil || container.Resources.Requests == nil || container.Resources.Requests.Gpu == nil {
+			continue
+		}
+		return container.Resources.Requests.Gpu.Sku, true
+	}
+	return "", false
+}
+
+// isCapacityError reports whether a creation failed for lack of capacity, which another zone may have.
+func isCapacityError(err error) bool {
+	if err == nil {
+		return false
+	}
+	message := err.Error()
+	for _, capacityError := range capacityErrors {
+		if strings.Contains(message, capacityError) {
+			return true
+		}
+	}
+	return false
+}
+
+// createContainerGroup creates the container group of a pod. GPU container groups are created in the
+// zone most likely to have capacity when GPU zones are configured, and in the next zones on capacity
+// errors. Container groups already placed in a zone by a migration stay there.
+func (p *ACIProvider) createContainerGroup(ctx context.Context, pod *v1.Pod, cg *client2.ContainerGroupWrapper) error {
+	sku, ok := containersGPUSKU(cg.ContainerGroupPropertiesWrapper.ContainerGroupProperties.Containers)
+	if p.gpuZones == nil || !ok || cg.Zones != nil {
+		return p.azClientsAPIs.CreateContainerGroup(ctx, p.resourceGroup, pod.Namespace, pod.Name, cg)
+	}
+
+	var err error
+	for _, zone := range p.gpuZones.rank(sku, time.Now()) {
+		cg.Zones = &[]string{zone}
+		err = p.azClientsAPIs.CreateContainerGroup(ctx, p.resourceGroup, pod.Namespace, pod.Name, cg)
+		if !isCapacityError(err) {
+			return err
+		}
+		p.gpuZones.recordFailure(zone, sku, time.Now())
+		log.G(ctx).WithError(err).Warnf("no %s capacity in zone %s for pod %s/%s", sku, zone, pod.Namespace, pod.Name)
+		p.recordEvent(pod, v1.EventTypeWarning, "GPUZoneUnavailable", "No %s GPU capacity in zone %s", sku, zone)
+	}
+	return err
+}
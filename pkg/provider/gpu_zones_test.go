@@ -0,0 +1,128 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	"github.com/virtual-kubelet/azure-aci/pkg/client"
+	testsutil "github.com/virtual-kubelet/azure-aci/pkg/tests"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	"k8s.io/client-go/tools/record"
+)
+
+func gpuContainers(sku azaci.GpuSku) *[]azaci.Container {
+	name, count := "gpu", int32(1)
+	return &[]azaci.Container{{
+		Name: &name,
+		ContainerProperties: &azaci.ContainerProperties{
+			Resources: &azaci.ResourceRequirements{
+				Requests: &azaci.ResourceRequests{Gpu: &azaci.GpuResource{Count: &count, Sku: sku}},
+			},
+		},
+	}}
+}
+
+func TestGPUZonesRank(t *testing.T) {
+	now := time.Now()
+	g := newGPUZones([]string{"1", "2", "3"})
+
+	assert.Check(t, is.DeepEqual([]string{"1", "2", "3"}, g.rank(azaci.GpuSkuV100, now)))
+	assert.Check(t, is.DeepEqual([]string{"2", "3", "1"}, g.rank(azaci.GpuSkuV100, now)), "tied zones should be rotated")
+
+	g.recordFailure("3", azaci.GpuSkuV100, now.Add(-time.Hour))
+	g.recordFailure("3", azaci.GpuSkuV100, now)
+	g.recordFailure("1", azaci.GpuSkuV100, now)
+	g.recordFailure("1", azaci.GpuSkuV100, now)
+	assert.Check(t, is.DeepEqual([]string{"2", "3", "1"}, g.rank(azaci.GpuSkuV100, now)), "zones should be ranked by recent failures")
+	assert.Check(t, is.DeepEqual([]string{"1", "2", "3"}, g.rank(azaci.GpuSkuK80, now)), "failures should be counted per SKU")
+	assert.Check(t, is.DeepEqual([]string{"2", "3", "1"}, g.rank(azaci.GpuSkuV100, now.Add(gpuZoneFailureWindow))))
+	assert.Check(t, is.DeepEqual([]string{"3", "1", "2"}, g.rank(azaci.GpuSkuV100, now.Add(gpuZoneFailureWindow+time.Minute))), "old failures should expire")
+}
+
+func TestGPUZonesObserve(t *testing.T) {
+	now := time.Now()
+	g := newGPUZones([]string{"1", "2"})
+	containers := testsutil.CreateACIContainersListObj("Waiting", "Waiting", now, now, false, false, false)
+	failed := testsutil.CreateContainerGroupObj("web", "ns", "Failed", containers, "Failed")
+	failed.Containers = gpuContainers(azaci.GpuSkuV100)
+	failed.Zones = &[]string{"1"}
+
+	g.observe(failed, now)
+	g.observe(failed, now)
+	assert.Check(t, is.Equal(1, g.recentFailures(gpuZoneKey{zone: "1", sku: azaci.GpuSkuV100}, now)), "a failure should be recorded once")
+
+	failed.Zones = &[]string{"2"}
+	g.observe(failed, now)
+	assert.Check(t, is.Equal(1, g.recentFailures(gpuZoneKey{zone: "2", sku: azaci.GpuSkuV100}, now)), "a failure in another zone should be recorded")
+
+	cpu := testsutil.CreateContainerGroupObj("api", "ns", "Failed", containers, "Failed")
+	cpu.Zones = &[]string{"1"}
+	g.observe(cpu, now)
+	assert.Check(t, is.Equal(1, g.recentFailures(gpuZoneKey{zone: "1", sku: azaci.GpuSkuV100}, now)), "container groups without GPU should be ignored")
+}
+
+func TestCreateContainerGroupGPUZones(t *testing.T) {
+	var zones []string
+	invalid := false
+	aciMocks := createNewACIMock()
+	aciMocks.MockCreateContainerGroup = func(ctx context.Context, resourceGroup, podNS, podName string, cg *client.ContainerGroupWrapper) error {
+		zone := ""
+		if cg.Zones != nil {
+			zone = (*cg.Zones)[0]
+		}
+		zones = append(zones, zone)
+		if invalid {
+			return errors.New("InvalidImage")
+		}
+		if zone == "1" {
+			return errors.New("Code=\"ServiceUnavailable\" Message=\"The requested resource is not available in the location 'westus2' at this moment.\"")
+		}
+		return nil
+	}
+	recorder := record.NewFakeRecorder(10)
+	p := &ACIProvider{azClientsAPIs: aciMocks, eventRecorder: recorder, gpuZones: newGPUZones([]string{"1", "2", "3"})}
+	pod := testsutil.CreatePodObj("web", "ns")
+	newCG := func(containers *[]azaci.Container) *client.ContainerGroupWrapper {
+		return &client.ContainerGroupWrapper{
+			ContainerGroupPropertiesWrapper: &client.ContainerGroupPropertiesWrapper{
+				ContainerGroupProperties: &azaci.ContainerGroupProperties{Containers: containers},
+			},
+		}
+	}
+
+	err := p.createContainerGroup(context.Background(), pod, newCG(gpuContainers(azaci.GpuSkuV100)))
+	assert.Check(t, err == nil)
+	assert.Check(t, is.DeepEqual([]string{"1", "2"}, zones), "capacity errors should be retried in the next zone")
+	assert.Assert(t, is.Len(recorder.Events, 1))
+	assert.Check(t, is.Contains(<-recorder.Events, "Warning GPUZoneUnavailable No V100 GPU capacity in zone 1"))
+
+	zones = nil
+	p.createContainerGroup(context.Background(), pod, newCG(gpuContainers(azaci.GpuSkuV100)))
+	p.createContainerGroup(context.Background(), pod, newCG(gpuContainers(azaci.GpuSkuV100)))
+	assert.Check(t, is.DeepEqual([]string{"2", "3"}, zones), "the zone out of capacity should be tried last")
+
+	zones = nil
+	err = p.createContainerGroup(context.Background(), pod, newCG(nil))
+	assert.Check(t, err == nil)
+	assert.Check(t, is.DeepEqual([]string{""}, zones), "container groups without GPU should not be placed")
+
+	zones = nil
+	placed := newCG(gpuContainers(azaci.GpuSkuV100))
+	placed.Zones = &[]string{"3"}
+	p.createContainerGroup(context.Background(), pod, placed)
+	assert.Check(t, is.DeepEqual([]string{"3"}, zones), "migrated container groups should stay in their zone")
+
+	zones = nil
+	invalid = true
+	err = p.createContainerGroup(context.Background(), pod, newCG(gpuContainers(azaci.GpuSkuV100)))
+	assert.Check(t, err != nil)
+	assert.Check(t, is.Len(zones, 1), "errors other than capacity should not be retried")
+}
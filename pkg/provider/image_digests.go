@@ -0,0 +1,57 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"regexp"
+
+	"github.com/virtual-kubelet/azure-aci/pkg/errcodes"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// imageDigestRegexp matches the digest suffix of an image reference, e.g. "@sha256:4c1e...", as
+// defined by the distribution reference grammar.
+var imageDigestRegexp = regexp.MustCompile(`@[A-Za-z][A-Za-z0-9]*(?:[-_+.][A-Za-z][A-Za-z0-9]*)*:[0-9a-fA-F]{32,}$`)
+
+// requiresImageDigests reports whether the images of the pod must be pinned by digest, either
+// because the provider or the namespace of the pod requires it.
+func (p *ACIProvider) requiresImageDigests(pod *v1.Pod) bool {
+	if p.requireImageDigests {
+		return true
+	}
+	for _, ns := range p.imageDigestNamespaces {
+		if pod.Namespace == ns {
+			return true
+		}
+	}
+	return false
+}
+
+// validateImageDigests rejects pods referencing images by tag when digests are required, listing
+// every unpinned image at once. A tag next to the digest is accepted, the digest wins.
+func (p *ACIProvider) validateImageDigests(pod *v1.Pod) error {
+	if !p.requiresImageDigests(pod) {
+		return nil
+	}
+
+	var errs field.ErrorList
+	spec := field.NewPath("spec")
+	check := func(path *field.Path, containers []v1.Container) {
+		for i, container := range containers {
+			if !imageDigestRegexp.MatchString(container.Image) {
+				errs = append(errs, field.Invalid(path.Index(i).Child("image"), container.Image, "image must be referenced by digest, e.g. image@sha256:<digest>"))
+			}
+		}
+	}
+	check(spec.Child("initContainers"), pod.Spec.InitContainers)
+	check(spec.Child("containers"), pod.Spec.Containers)
+
+	if len(errs) != 0 {
+		return errcodes.Wrap(errcodes.ImageNotPinned, errdefs.InvalidInputf("pod %s references images that are not pinned by digest: %v", pod.Name, errs.ToAggregate()))
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"strings"
+	"testing"
+
+	testsutil "github.com/virtual-kubelet/azure-aci/pkg/tests"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestValidateImageDigests(t *testing.T) {
+	digest := "@sha256:" + strings.Repeat("a1", 32)
+	p := &ACIProvider{imageDigestNamespaces: []string{"payments"}}
+
+	pod := testsutil.CreatePodObj("pod", "ns")
+	pod.Spec.Containers[0].Image = "nginx:1.21"
+	assert.NilError(t, p.validateImageDigests(pod), "tags should be accepted outside of the namespaces requiring digests")
+
+	pod = testsutil.CreatePodObj("pod", "payments")
+	pod.Spec.InitContainers = []v1.Container{{Name: "init", Image: "busybox"}}
+	pod.Spec.Containers[0].Image = "nginx:1.21"
+	err := p.validateImageDigests(pod)
+	assert.Check(t, errdefs.IsInvalidInput(err), "unpinned images should be rejected")
+	assert.Check(t, is.Contains(err.Error(), "spec.initContainers[0].image"))
+	assert.Check(t, is.Contains(err.Error(), "spec.containers[0].image"))
+
+	pod.Spec.InitContainers[0].Image = "busybox" + digest
+	pod.Spec.Containers[0].Image = "myacr.azurecr.io/nginx:1.21" + digest
+	assert.NilError(t, p.validateImageDigests(pod), "images pinned by digest should be accepted")
+
+	p = &ACIProvider{requireImageDigests: true}
+	pod = testsutil.CreatePodObj("pod", "ns")
+	pod.Spec.Containers[0].Image = "nginx@sha256:short"
+	assert.Check(t, errdefs.IsInvalidInput(p.validateImageDigests(pod)), "the provider policy should apply to every namespace")
+}
@@ -0,0 +1,148 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	v1 "k8s.io/api/core/v1"
+)
+
+// imagePullSecretAnnotation forces the image pull secret of registries, as comma separated
+// registry=secret pairs, e.g. "myacr.azurecr.io=acr-pull,docker.io=hub-pull". The secrets do not
+// need to be image pull secrets of the pod.
+const imagePullSecretAnnotation = "virtual-kubelet.io/image-pull-secret"
+
+// getImagePullSecretOverrides returns the image pull secrets the pod forces, by registry.
+func getImagePullSecretOverrides(pod *v1.Pod) (map[string]string, error) {
+	value, ok := pod.Annotations[imagePullSecretAnnotation]
+	if !ok {
+		return nil, nil
+	}
+	overrides := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			return nil, errdefs.InvalidInputf("%q of annotation %s is not a registry=secret pair", pair, imagePullSecretAnnotation)
+		}
+		overrides[registryKey(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return overrides, nil
+}
+
+// registryKey returns the login server of a registry the way credentials are matched, without the
+// scheme and path docker config files may have, e.g. https://index.docker.io/v1/.
+func registryKey(server string) string {
+	server = strings.ToLower(strings.TrimSpace(server))
+	server = strings.TrimPrefix(strings.TrimPrefix(server, "https://"), "http://")
+	if i := strings.IndexByte(server, '/'); i >= 0 {
+		server = server[:i]
+	}
+	return server
+}
+
+// getImagePullSecrets returns one credential per registry from the image pull secrets of the pod. When
+// several secrets have credentials for a registry, the secret forced by the annotation of the pod wins,
+// then the first image pull secret of the pod, then the first one of its service account.
+func (p *ACIProvider) getImagePullSecrets(ctx context.Context, pod *v1.Pod) (*[]azaci.ImageRegistryCredential, error) {
+	overrides, err := getImagePullSecretOverrides(pod)
+	if err != nil {
+		return nil, err
+	}
+
+	ips := make([]azaci.ImageRegistryCredential, 0)
+	sources := make(map[string]string)
+	indexes := make(map[string]int)
+	add := func(cred azaci.ImageRegistryCredential, secret string, force bool) {
+		if cred.Server == nil {
+			return
+		}
+		key := registryKey(*cred.Server)
+		i, ok := indexes[key]
+		switch {
+		case !ok:
+			indexes[key] = len(ips)
+			ips = append(ips, cred)
+		case force:
+			ips[i] = cred
+		default:
+			log.G(ctx).Debugf("ignoring the credential of image pull secret %s for registry %s of pod %s/%s, secret %s takes precedence", secret, *cred.Server, pod.Namespace, pod.Name, sources[key])
+			return
+		}
+		sources[key] = secret
+	}
+
+	for _, ref := range p.getPodImagePullSecrets(ctx, pod) {
+		creds, err := p.readImagePullSecretCredentials(pod.Namespace, ref.Name)
+		if err != nil {
+			return &ips, err
+		}
+		for _, cred := range creds {
+			add(cred, ref.Name, false)
+		}
+	}
+
+	registries := make([]string, 0, len(overrides))
+	for registry := range overrides {
+		registries = append(registries, registry)
+	}
+	sort.Strings(registries)
+	for _, registry := range registries {
+		secret := overrides[registry]
+		creds, err := p.readImagePullSecretCredentials(pod.Namespace, secret)
+		if err != nil {
+			return &ips, err
+		}
+		found := false
+		for _, cred := range creds {
+			if cred.Server != nil && registryKey(*cred.Server) == registry {
+				add(cred, secret, true)
+				found = true
+			}
+		}
+		if !found {
+			return &ips, errdefs.InvalidInputf("image pull secret %s forced by annotation %s has no credential for registry %s", secret, imagePullSecretAnnotation, registry)
+		}
+	}
+
+	for _, cred := range ips {
+		key := registryKey(*cred.Server)
+		log.G(ctx).Infof("pulling the images of registry %s for pod %s/%s with image pull secret %s", *cred.Server, pod.Namespace, pod.Name, sources[key])
+	}
+	return &ips, nil
+}
+
+// readImagePullSecretCredentials returns the registry credentials of an image pull secret, sorted by
+// registry so the credential chosen among the ones of a secret for the same registry is stable.
+func (p *ACIProvider) readImagePullSecretCredentials(namespace, name string) ([]azaci.ImageRegistryCredential, error) {
+	secret, err := p.resourceManager.GetSecret(name, namespace)
+	if err != nil {
+		p.registryCredentials.invalidate(namespace, name)
+		return nil, err
+	}
+	if secret == nil {
+		return nil, fmt.Errorf("error getting image pull secret")
+	}
+
+	creds, err := p.registryCredentials.get(secret)
+	if err != nil {
+		return nil, err
+	}
+	sorted := append([]azaci.ImageRegistryCredential(nil), creds...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return *sorted[i].Server < *sorted[j].Server
+	})
+	return sorted, nil
+}
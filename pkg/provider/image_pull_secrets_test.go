@@ -0,0 +1,98 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	testsutil "github.com/virtual-kubelet/azure-aci/pkg/tests"
+	"github.com/virtual-kubelet/node-cli/manager"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func dockerConfigSecret(name, auths string) *v1.Secret {
+	return &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns", UID: types.UID("uid-" + name), ResourceVersion: "1"},
+		Type:       v1.SecretTypeDockerConfigJson,
+		Data:       map[string][]byte{v1.DockerConfigJsonKey: []byte(`{"auths":{` + auths + `}}`)},
+	}
+}
+
+func TestGetImagePullSecretsPrecedence(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	secrets := []*v1.Secret{
+		dockerConfigSecret("first", `"myacr.azurecr.io":{"username":"first","password":"p"}`),
+		dockerConfigSecret("second", `"https://myacr.azurecr.io/v1/":{"username":"second","password":"p"},"docker.io":{"username":"second","password":"p"}`),
+		dockerConfigSecret("sa", `"docker.io":{"username":"sa","password":"p"},"quay.io":{"username":"sa","password":"p"}`),
+		dockerConfigSecret("forced", `"myacr.azurecr.io":{"username":"forced","password":"p"}`),
+	}
+	secretLister := NewMockSecretLister(mockCtrl)
+	secretNamespaceLister := NewMockSecretNamespaceLister(mockCtrl)
+	secretLister.EXPECT().Secrets("ns").Return(secretNamespaceLister).AnyTimes()
+	for _, secret := range secrets {
+		secretNamespaceLister.EXPECT().Get(secret.Name).Return(secret, nil).AnyTimes()
+	}
+	rm, err := manager.NewResourceManager(NewMockPodLister(mockCtrl), secretLister, NewMockConfigMapLister(mockCtrl),
+		NewMockServiceLister(mockCtrl), NewMockPersistentVolumeClaimLister(mockCtrl), NewMockPersistentVolumeLister(mockCtrl))
+	if err != nil {
+		t.Fatal("Unable to prepare the mocks for resourceManager", err)
+	}
+	p := &ACIProvider{
+		resourceManager:     rm,
+		registryCredentials: newRegistryCredentialCache(),
+		kubeClient: fake.NewSimpleClientset(&v1.ServiceAccount{
+			ObjectMeta:       metav1.ObjectMeta{Name: "default", Namespace: "ns"},
+			ImagePullSecrets: []v1.LocalObjectReference{{Name: "sa"}},
+		}),
+	}
+
+	usernames := func(pod *v1.Pod) map[string]string {
+		creds, err := p.getImagePullSecrets(context.Background(), pod)
+		assert.NilError(t, err)
+		users := make(map[string]string)
+		for _, cred := range *creds {
+			users[registryKey(*cred.Server)] = *cred.Username
+		}
+		return users
+	}
+
+	pod := testsutil.CreatePodObj("pod", "ns")
+	pod.Spec.ImagePullSecrets = []v1.LocalObjectReference{{Name: "first"}, {Name: "second"}}
+	assert.Check(t, is.DeepEqual(map[string]string{
+		"myacr.azurecr.io": "first",
+		"docker.io":        "second",
+		"quay.io":          "sa",
+	}, usernames(pod)), "the first pod secret should win, then the service account secrets")
+
+	pod.Annotations = map[string]string{imagePullSecretAnnotation: "MyACR.azurecr.io=forced, docker.io=sa"}
+	assert.Check(t, is.DeepEqual(map[string]string{
+		"myacr.azurecr.io": "forced",
+		"docker.io":        "sa",
+		"quay.io":          "sa",
+	}, usernames(pod)), "the secrets forced by the annotation should win")
+
+	pod.Annotations[imagePullSecretAnnotation] = "quay.io=forced"
+	_, err = p.getImagePullSecrets(context.Background(), pod)
+	assert.Check(t, errdefs.IsInvalidInput(err), "a forced secret without a credential for the registry should be rejected: %v", err)
+
+	pod.Annotations[imagePullSecretAnnotation] = "forced"
+	_, err = p.getImagePullSecrets(context.Background(), pod)
+	assert.Check(t, errdefs.IsInvalidInput(err), "a malformed annotation should be rejected: %v", err)
+}
+
+func TestRegistryKey(t *testing.T) {
+	assert.Check(t, is.Equal("myacr.azurecr.io", registryKey("https://MyACR.azurecr.io/v1/")))
+	assert.Check(t, is.Equal("localhost:5000", registryKey("localhost:5000")))
+}
@@ -0,0 +1,106 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/virtual-kubelet/azure-aci/pkg/metrics"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+)
+
+const (
+	// logFollowInterval is how often the logs of a followed container are polled, ACI has no
+	// streaming log API.
+	logFollowInterval = 2 * time.Second
+	// logFollowTail is the number of log lines fetched per poll after the first one. Containers
+	// writing more lines between two polls lose the oldest ones.
+	logFollowTail = 5000
+)
+
+// logPollFunc returns the last log lines of a container, all of them when tail is 0.
+type logPollFunc func(ctx context.Context, tail int) (string, error)
+
+// logStream is the reader of followed logs. Closing it stops the polling.
+type logStream struct {
+	*io.PipeReader
+	cancel context.CancelFunc
+}
+
+func (s *logStream) Close() error {
+	s.cancel()
+	return s.PipeReader.Close()
+}
+
+// followLogs streams the log lines of a container: the last tail lines first, then the lines
+// written after them, until the stream is closed, the context is done or polling fails.
+func followLogs(ctx context.Context, namespace string, tail int, interval time.Duration, poll logPollFunc) io.ReadCloser {
+	ctx, cancel := context.WithCancel(ctx)
+	r, w := io.Pipe()
+	go func() {
+		defer cancel()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		var since time.Time
+		for {
+			logs, err := poll(ctx, tail)
+			if err != nil {
+				if ctx.Err() != nil {
+					w.Close()
+					return
+				}
+				log.G(ctx).WithError(err).Debug("unable to poll the followed container logs")
+				w.CloseWithError(err)
+				return
+			}
+			lines, latest := logLinesSince(logs, since)
+			since = latest
+			if lines != "" {
+				if _, err := io.WriteString(w, lines); err != nil {
+					// The stream was closed.
+					return
+				}
+				metrics.AddInteractiveBytes(namespace, metrics.OperationLogs, int64(len(lines)))
+			}
+			tail = logFollowTail
+
+			select {
+			case <-ctx.Done():
+				w.Close()
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return &logStream{PipeReader: r, cancel: cancel}
+}
+
+// logLinesSince returns the complete log lines written after the timestamp ACI prefixes them with,
+// and the timestamp of the last one. Lines without a timestamp are continuations of the previous
+// line, and the last line is left to the next poll until it is terminated.
+func logLinesSince(logs string, since time.Time) (string, time.Time) {
+	var b strings.Builder
+	latest := since
+	include := since.IsZero()
+	for _, line := range strings.SplitAfter(logs, "\n") {
+		if !strings.HasSuffix(line, "\n") {
+			break
+		}
+		if timestamp, ok := logLineTimestamp(line); ok {
+			include = timestamp.After(since)
+			if include {
+				latest = timestamp
+			}
+		}
+		if include {
+			b.WriteString(line)
+		}
+	}
+	return b.String(), latest
+}
@@ -0,0 +1,110 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestLogLinesSince(t *testing.T) {
+	first, _ := time.Parse(time.RFC3339Nano, "2022-06-01T10:00:01.5Z")
+	logs := "2022-06-01T10:00:01.5Z started\n" +
+		"2022-06-01T10:00:02.5Z panic: boom\n" +
+		"goroutine 1 [running]:\n" +
+		"2022-06-01T10:00:03.5Z partial"
+
+	lines, latest := logLinesSince(logs, time.Time{})
+	assert.Check(t, is.Equal("2022-06-01T10:00:01.5Z started\n2022-06-01T10:00:02.5Z panic: boom\ngoroutine 1 [running]:\n", lines))
+	assert.Check(t, is.Equal(first.Add(time.Second), latest), "the unterminated line should be left to the next poll")
+
+	lines, latest = logLinesSince(logs, first)
+	assert.Check(t, is.Equal("2022-06-01T10:00:02.5Z panic: boom\ngoroutine 1 [running]:\n", lines))
+	assert.Check(t, is.Equal(first.Add(time.Second), latest))
+
+	lines, latest = logLinesSince(logs, first.Add(time.Second))
+	assert.Check(t, is.Equal("", lines), "continuations of sent lines should not be sent again")
+	assert.Check(t, is.Equal(first.Add(time.Second), latest))
+}
+
+func TestFollowLogs(t *testing.T) {
+	polls := []string{
+		"2022-06-01T10:00:01Z a\n2022-06-01T10:00:02Z b\n",
+		"2022-06-01T10:00:01Z a\n2022-06-01T10:00:02Z b\n2022-06-01T10:00:03Z c\n2022-06-01T10:00:04Z par",
+		"2022-06-01T10:00:02Z b\n2022-06-01T10:00:03Z c\n2022-06-01T10:00:04Z partial\n",
+	}
+	var mu sync.Mutex
+	var tails []int
+	poll := func(ctx context.Context, tail int) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		tails = append(tails, tail)
+		if len(tails) > len(polls) {
+			return polls[len(polls)-1], nil
+		}
+		return polls[len(tails)-1], nil
+	}
+
+	stream := followLogs(context.Background(), "ns", 2, time.Millisecond, poll)
+	reader := bufio.NewReader(stream)
+	for _, expected := range []string{
+		"2022-06-01T10:00:01Z a\n",
+		"2022-06-01T10:00:02Z b\n",
+		"2022-06-01T10:00:03Z c\n",
+		"2022-06-01T10:00:04Z partial\n",
+	} {
+		line, err := reader.ReadString('\n')
+		assert.NilError(t, err)
+		assert.Check(t, is.Equal(expected, line))
+	}
+
+	assert.NilError(t, stream.Close())
+	_, err := reader.ReadString('\n')
+	assert.Check(t, is.Equal(io.ErrClosedPipe, err))
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Check(t, is.Equal(2, tails[0]), "the first poll should return the requested tail")
+	assert.Check(t, is.Equal(logFollowTail, tails[1]))
+}
+
+func TestFollowLogsPollError(t *testing.T) {
+	pollErr := errors.New("container group not found")
+	calls := 0
+	poll := func(ctx context.Context, tail int) (string, error) {
+		calls++
+		if calls > 1 {
+			return "", pollErr
+		}
+		return "2022-06-01T10:00:01Z a\n", nil
+	}
+
+	stream := followLogs(context.Background(), "ns", 0, time.Millisecond, poll)
+	defer stream.Close()
+	content, err := io.ReadAll(stream)
+	assert.Check(t, is.Equal(pollErr, err))
+	assert.Check(t, is.Equal("2022-06-01T10:00:01Z a\n", string(content)))
+}
+
+func TestFollowLogsContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	poll := func(ctx context.Context, tail int) (string, error) {
+		return "", nil
+	}
+
+	stream := followLogs(ctx, "ns", 0, time.Millisecond, poll)
+	defer stream.Close()
+	cancel()
+	content, err := io.ReadAll(stream)
+	assert.NilError(t, err, "the stream should end when the context is done")
+	assert.Check(t, is.Equal("", string(content)))
+}
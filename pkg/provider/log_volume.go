@@ -0,0 +1,149 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	client2 "github.com/virtual-kubelet/azure-aci/pkg/client"
+	"github.com/virtual-kubelet/azure-aci/pkg/metrics"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	"github.com/virtual-kubelet/virtual-kubelet/node/api"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// logVolumeSampleTail is the number of log lines fetched per container and sample. Containers
+// writing more lines between two samples are undercounted.
+const logVolumeSampleTail = 5000
+
+// logVolumeMark is the last log line of a container counted by the previous sample.
+type logVolumeMark struct {
+	timestamp time.Time
+	sampledAt time.Time
+}
+
+// logVolume tracks the log volume of the containers of the node. It is only accessed from the
+// sampling loop.
+type logVolume struct {
+	marks map[PodIdentifier]map[string]logVolumeMark
+	noisy map[PodIdentifier]map[string]bool
+}
+
+// trackLogVolume samples the log volume of the containers until the context is done.
+func (p *ACIProvider) trackLogVolume(ctx context.Context) {
+	ticker := time.NewTicker(p.logVolumeSampleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.sampleLogVolume(ctx, time.Now())
+		}
+	}
+}
+
+// sampleLogVolume counts the bytes the running containers logged since the previous sample, and
+// publishes an event on the pods whose containers log faster than the noisy pod threshold. The
+// first sample of a container only marks where the next one starts.
+func (p *ACIProvider) sampleLogVolume(ctx context.Context, now time.Time) {
+	if p.logVolume.marks == nil {
+		p.logVolume.marks = make(map[PodIdentifier]map[string]logVolumeMark)
+		p.logVolume.noisy = make(map[PodIdentifier]map[string]bool)
+	}
+
+	seen := make(map[PodIdentifier]bool)
+	for _, pod := range p.resourceManager.GetPods() {
+		if pod.Status.Phase != v1.PodRunning || pod.DeletionTimestamp != nil {
+			continue
+		}
+		id := PodIdentifier{namespace: pod.Namespace, name: pod.Name}
+		seen[id] = true
+		if p.logVolume.marks[id] == nil {
+			p.logVolume.marks[id] = make(map[string]logVolumeMark)
+			p.logVolume.noisy[id] = make(map[string]bool)
+		}
+
+		for _, container := range pod.Spec.Containers {
+			content, err := p.azClientsAPIs.ListLogs(ctx, p.resourceGroup, client2.ContainerGroupName(pod.Namespace, pod.Name), container.Name, api.ContainerLogOpts{Tail: logVolumeSampleTail})
+			if err != nil {
+				log.G(ctx).WithError(err).Debugf("unable to sample the log volume of container %s of pod %s/%s", container.Name, pod.Namespace, pod.Name)
+				continue
+			}
+			logs := ""
+			if content != nil {
+				logs = *content
+			}
+
+			previous, ok := p.logVolume.marks[id][container.Name]
+			bytes, latest := logBytesSince(logs, previous.timestamp)
+			if latest.IsZero() {
+				latest = previous.timestamp
+			}
+			p.logVolume.marks[id][container.Name] = logVolumeMark{timestamp: latest, sampledAt: now}
+			if !ok {
+				continue
+			}
+			metrics.AddContainerLogBytes(pod.Namespace, pod.Name, container.Name, bytes)
+
+			elapsed := now.Sub(previous.sampledAt).Seconds()
+			if p.noisyLogBytesPerSecond <= 0 || elapsed <= 0 {
+				continue
+			}
+			rate := int64(float64(bytes) / elapsed)
+			noisy := rate > p.noisyLogBytesPerSecond
+			if noisy && !p.logVolume.noisy[id][container.Name] {
+				p.recordEvent(pod, v1.EventTypeWarning, "NoisyContainerLogs",
+					"container %s logged %s per second over the last %s, above the limit of %s per second",
+					container.Name, resource.NewQuantity(rate, resource.BinarySI), now.Sub(previous.sampledAt).Round(time.Second),
+					resource.NewQuantity(p.noisyLogBytesPerSecond, resource.BinarySI))
+			}
+			p.logVolume.noisy[id][container.Name] = noisy
+		}
+	}
+
+	for id, containers := range p.logVolume.marks {
+		if seen[id] {
+			continue
+		}
+		for name := range containers {
+			metrics.ForgetContainerLogBytes(id.namespace, id.name, name)
+		}
+		delete(p.logVolume.marks, id)
+		delete(p.logVolume.noisy, id)
+	}
+}
+
+// logBytesSince returns the bytes of the log lines written after the timestamp, without the
+// timestamps ACI prefixes them with, and the timestamp of the last line.
+func logBytesSince(logs string, since time.Time) (int64, time.Time) {
+	var bytes int64
+	var latest time.Time
+	for _, line := range strings.SplitAfter(logs, "\n") {
+		if line == "" {
+			continue
+		}
+		prefix := strings.TrimRight(line, "\r\n")
+		if i := strings.IndexByte(prefix, ' '); i >= 0 {
+			prefix = prefix[:i]
+		}
+		timestamp, err := time.Parse(time.RFC3339Nano, prefix)
+		if err != nil {
+			// Lines without a timestamp are continuations of the previous line.
+			if !latest.IsZero() && latest.After(since) {
+				bytes += int64(len(line))
+			}
+			continue
+		}
+		latest = timestamp
+		if timestamp.After(since) {
+			bytes += int64(len(strings.TrimPrefix(line[len(prefix):], " ")))
+		}
+	}
+	return bytes, latest
+}
@@ -0,0 +1,74 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	testsutil "github.com/virtual-kubelet/azure-aci/pkg/tests"
+	"github.com/virtual-kubelet/node-cli/manager"
+	"github.com/virtual-kubelet/virtual-kubelet/node/api"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestLogBytesSince(t *testing.T) {
+	since := time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)
+	logs := "2022-06-01T09:59:59.000000000Z old line\n" +
+		"2022-06-01T10:00:01.000000000Z new line\n" +
+		"  continued\n" +
+		"2022-06-01T10:00:02.500000000Z last\n"
+
+	bytes, latest := logBytesSince(logs, since)
+	assert.Check(t, is.Equal(int64(len("new line\n")+len("  continued\n")+len("last\n")), bytes))
+	assert.Check(t, latest.Equal(since.Add(2500*time.Millisecond)), "unexpected latest timestamp %s", latest)
+
+	bytes, latest = logBytesSince("", since)
+	assert.Check(t, is.Equal(int64(0), bytes))
+	assert.Check(t, latest.IsZero())
+}
+
+func TestSampleLogVolume(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	pod := testsutil.CreatePodObj("web", "ns")
+	pod.Status.Phase = v1.PodRunning
+	podLister := NewMockPodLister(mockCtrl)
+	podLister.EXPECT().List(labels.Everything()).Return([]*v1.Pod{pod}, nil).AnyTimes()
+	rm, err := manager.NewResourceManager(podLister, nil, nil, newServiceLister(), nil, nil)
+	if err != nil {
+		t.Fatal("Unable to prepare the mocks for resourceManager", err)
+	}
+
+	start := time.Now().Truncate(time.Second)
+	logs := start.Add(-time.Second).UTC().Format(time.RFC3339Nano) + " before the first sample\n"
+	aciMocks := createNewACIMock()
+	aciMocks.MockListLogs = func(ctx context.Context, resourceGroup, cgName, containerName string, opts api.ContainerLogOpts) (*string, error) {
+		assert.Check(t, is.Equal(logVolumeSampleTail, opts.Tail))
+		return &logs, nil
+	}
+
+	recorder := record.NewFakeRecorder(10)
+	p := &ACIProvider{azClientsAPIs: aciMocks, resourceManager: rm, eventRecorder: recorder, noisyLogBytesPerSecond: 100}
+
+	p.sampleLogVolume(context.Background(), start)
+	assert.Check(t, is.Len(recorder.Events, 0), "the first sample should only mark the logs")
+
+	logs += start.Add(time.Second).UTC().Format(time.RFC3339Nano) + " " + strings.Repeat("x", 2000) + "\n"
+	p.sampleLogVolume(context.Background(), start.Add(10*time.Second))
+	assert.Assert(t, is.Len(recorder.Events, 1))
+	assert.Check(t, strings.HasPrefix(<-recorder.Events, "Warning NoisyContainerLogs ACIP-030: container nginx logged 200 per second over the last 10s"))
+
+	p.sampleLogVolume(context.Background(), start.Add(20*time.Second))
+	assert.Check(t, is.Len(recorder.Events, 0), "quiet containers should not be published")
+}
@@ -0,0 +1,212 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"sync"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	client2 "github.com/virtual-kubelet/azure-aci/pkg/client"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// defaultMigrationFailureThreshold is the number of status updates in a row a container group
+	// is seen failed in its placement before it is moved.
+	defaultMigrationFailureThreshold = 3
+	// migrationQueueSize bounds the migrations waiting to run, more are retried on the next failed
+	// status update.
+	migrationQueueSize = 64
+)
+
+// placement is where a container group runs. The zone is empty to let ACI pick one.
+type placement struct {
+	region string
+	zone   string
+}
+
+func (pl placement) String() string {
+	if pl.zone == "" {
+		return pl.region
+	}
+	return pl.region + " zone " + pl.zone
+}
+
+// migrations moves the container groups failing in their placement, e.g. for lack of capacity in
+// their zone, to the next placement: the zones of the region in order, then the fallback regions.
+// Failures are observed by the concurrent status updates, the moves run one at a time.
+type migrations struct {
+	threshold int
+	queue     chan PodIdentifier
+
+	mu       sync.Mutex
+	failures map[PodIdentifier]int
+	// placements are the indexes of the current placement of the pods that were moved.
+	placements map[PodIdentifier]int
+	queued     map[PodIdentifier]bool
+}
+
+func newMigrations(threshold int) *migrations {
+	if threshold <= 0 {
+		threshold = defaultMigrationFailureThreshold
+	}
+	return &migrations{
+		threshold:  threshold,
+		queue:      make(chan PodIdentifier, migrationQueueSize),
+		failures:   make(map[PodIdentifier]int),
+		placements: make(map[PodIdentifier]int),
+		queued:     make(map[PodIdentifier]bool),
+	}
+}
+
+// migrationEnabled reports whether container groups are moved, which requires an alternate zone
+// or region.
+func (p *ACIProvider) migrationEnabled() bool {
+	return p.migrations != nil && (len(p.migrationZones) != 0 || len(p.migrationRegions) != 0)
+}
+
+// placements returns the placements of the container groups in the order they are tried. The first
+// one is the default placement of the virtual node.
+func (p *ACIProvider) placements() []placement {
+	placements := []placement{{region: p.region}}
+	for _, zone := range p.migrationZones {
+		placements = append(placements, placement{region: p.region, zone: zone})
+	}
+	for _, region := range p.migrationRegions {
+		placements = append(placements, placement{region: region})
+	}
+	return placements
+}
+
+// applyPlacement sets the region and zone of the container group of a pod that was moved.
+func (p *ACIProvider) applyPlacement(pod *v1.Pod, cg *client2.ContainerGroupWrapper) {
+	if !p.migrationEnabled() {
+		return
+	}
+	p.migrations.mu.Lock()
+	index, ok := p.migrations.placements[PodIdentifier{namespace: pod.Namespace, name: pod.Name}]
+	p.migrations.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	pl := p.placements()[index]
+	region := pl.region
+	cg.Location = &region
+	if pl.zone != "" {
+		cg.Zones = &[]string{pl.zone}
+	}
+}
+
+// observePlacement counts the status updates in a row the container group failed to provision,
+// and queues its move once the threshold is reached. Image pull failures are not solved by another
+// placement and are ignored.
+func (p *ACIProvider) observePlacement(ctx context.Context, cg *azaci.ContainerGroup) {
+	if !p.migrationEnabled() || cg.Tags == nil {
+		return
+	}
+	id, ok := podIdentifierFromTags(cg.Tags)
+	if !ok {
+		return
+	}
+
+	m := p.migrations
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	state, reason, _ := provisioningState(cg)
+	if state != "Failed" || reason != provisioningReasonFailed {
+		delete(m.failures, id)
+		return
+	}
+	m.failures[id]++
+	if m.failures[id] < m.threshold || m.queued[id] {
+		return
+	}
+	select {
+	case m.queue <- id:
+		m.queued[id] = true
+	default:
+		log.G(ctx).Warnf("migration queue is full, the container group of pod %s/%s is moved later", id.namespace, id.name)
+	}
+}
+
+// runMigrations moves the queued container groups until the context is done.
+func (p *ACIProvider) runMigrations(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case id := <-p.migrations.queue:
+			p.migrate(ctx, id)
+		}
+	}
+}
+
+// migrate deletes the container group of the pod and recreates it in the next placement, or the
+// ones after it when ACI rejects the container group. The container group is rendered from the pod
+// again, so it keeps its name and identity tags.
+func (p *ACIProvider) migrate(ctx context.Context, id PodIdentifier) {
+	m := p.migrations
+	defer func() {
+		m.mu.Lock()
+		delete(m.queued, id)
+		delete(m.failures, id)
+		m.mu.Unlock()
+	}()
+
+	pod, err := p.resourceManager.GetPod(id.name, id.namespace)
+	if err != nil || pod == nil || pod.DeletionTimestamp != nil {
+		return
+	}
+
+	placements := p.placements()
+	m.mu.Lock()
+	current := m.placements[id]
+	failures := m.failures[id]
+	m.mu.Unlock()
+	if current+1 >= len(placements) {
+		p.recordEvent(pod, v1.EventTypeWarning, "ContainerGroupMigrationExhausted",
+			"container group failed %d times in a row in %s, the last of %d placements", failures, placements[current], len(placements))
+		return
+	}
+
+	if err := p.azClientsAPIs.DeleteContainerGroup(ctx, p.resourceGroup, client2.ContainerGroupName(id.namespace, id.name)); err != nil {
+		p.recordEvent(pod, v1.EventTypeWarning, "ContainerGroupMigrationFailed",
+			"unable to delete the container group failing in %s: %v", placements[current], err)
+		return
+	}
+	p.aciEvents.forget(id)
+
+	for next := current + 1; next < len(placements); next++ {
+		log.G(ctx).Infof("moving the container group of pod %s/%s from %s to %s", id.namespace, id.name, placements[current], placements[next])
+		m.mu.Lock()
+		m.placements[id] = next
+		m.mu.Unlock()
+		if err := p.CreatePod(ctx, pod); err != nil {
+			p.recordEvent(pod, v1.EventTypeWarning, "ContainerGroupMigrationFailed",
+				"unable to recreate the container group in %s: %v", placements[next], err)
+			continue
+		}
+		p.recordEvent(pod, v1.EventTypeNormal, "ContainerGroupMigrated",
+			"container group failed %d times in a row in %s, it is recreated in %s", failures, placements[current], placements[next])
+		return
+	}
+	p.recordEvent(pod, v1.EventTypeWarning, "ContainerGroupMigrationExhausted",
+		"container group could not be recreated in any of the %d placements", len(placements))
+}
+
+// forget drops the placement of a deleted pod, a pod recreated with the same name starts
+// over in the default placement.
+func (m *migrations) forget(id PodIdentifier) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.failures, id)
+	delete(m.placements, id)
+}
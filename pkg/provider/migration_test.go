@@ -0,0 +1,118 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	"github.com/golang/mock/gomock"
+	"github.com/virtual-kubelet/azure-aci/pkg/client"
+	testsutil "github.com/virtual-kubelet/azure-aci/pkg/tests"
+	"github.com/virtual-kubelet/node-cli/manager"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestObservePlacement(t *testing.T) {
+	p := &ACIProvider{region: "westus2", migrationZones: []string{"1"}, migrations: newMigrations(2)}
+	containers := testsutil.CreateACIContainersListObj("Waiting", "Waiting", time.Now(), time.Now(), false, false, false)
+	failed := testsutil.CreateContainerGroupObj("web", "ns", "Failed", containers, "Failed")
+	id := PodIdentifier{namespace: "ns", name: "web"}
+
+	p.observePlacement(context.Background(), failed)
+	assert.Check(t, is.Len(p.migrations.queue, 0), "the move should wait for the threshold")
+
+	p.observePlacement(context.Background(), testsutil.CreateContainerGroupObj("web", "ns", "Pending", containers, "Creating"))
+	p.observePlacement(context.Background(), failed)
+	assert.Check(t, is.Len(p.migrations.queue, 0), "the failures should be counted in a row")
+
+	p.observePlacement(context.Background(), failed)
+	assert.Assert(t, is.Len(p.migrations.queue, 1))
+	assert.Check(t, is.Equal(id, <-p.migrations.queue))
+
+	p.observePlacement(context.Background(), failed)
+	assert.Check(t, is.Len(p.migrations.queue, 0), "queued moves should not be queued again")
+
+	pullFailed := testsutil.CreateContainerGroupObj("api", "ns", "Failed", containers, "Failed")
+	(*pullFailed.Containers)[0].InstanceView.Events = &[]azaci.Event{aciEvent("Failed", "Warning", "Failed to pull image \"nginx:missing\"", 1, time.Now())}
+	for i := 0; i < 3; i++ {
+		p.observePlacement(context.Background(), pullFailed)
+	}
+	assert.Check(t, is.Len(p.migrations.queue, 0), "image pull failures should not be moved")
+}
+
+func TestPlacements(t *testing.T) {
+	p := &ACIProvider{region: "westus2", migrationZones: []string{"1", "2"}, migrationRegions: []string{"eastus"}}
+
+	placements := p.placements()
+	assert.Assert(t, is.Len(placements, 4))
+	assert.Check(t, is.Equal("westus2", placements[0].String()))
+	assert.Check(t, is.Equal("westus2 zone 1", placements[1].String()))
+	assert.Check(t, is.Equal("westus2 zone 2", placements[2].String()))
+	assert.Check(t, is.Equal("eastus", placements[3].String()))
+}
+
+func TestMigrate(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	podLister := NewMockPodLister(mockCtrl)
+	mockPodsNamespaceLister := NewMockPodNamespaceLister(mockCtrl)
+	podLister.EXPECT().Pods("ns").Return(mockPodsNamespaceLister)
+	mockPodsNamespaceLister.EXPECT().Get("web").Return(testsutil.CreatePodObj("web", "ns"), nil)
+	resourceManager, err := manager.NewResourceManager(podLister, nil, nil, newServiceLister(), nil, nil)
+	if err != nil {
+		t.Fatal("Unable to prepare the mocks for resourceManager", err)
+	}
+
+	var deleted []string
+	var placements []string
+	aciMocks := createNewACIMock()
+	aciMocks.MockDeleteContainerGroup = func(ctx context.Context, resourceGroup, cgName string) error {
+		deleted = append(deleted, cgName)
+		return nil
+	}
+	aciMocks.MockCreateContainerGroup = func(ctx context.Context, resourceGroup, podNS, podName string, cg *client.ContainerGroupWrapper) error {
+		placement := *cg.Location
+		if cg.Zones != nil {
+			placement += " zone " + (*cg.Zones)[0]
+		}
+		placements = append(placements, placement)
+		assert.Check(t, is.Equal("web", *cg.Tags["PodName"]), "identity tags should be preserved")
+		if cg.Zones != nil && (*cg.Zones)[0] == "1" {
+			return errors.New("the requested resource is not available in the location")
+		}
+		return nil
+	}
+
+	provider, err := createTestProvider(aciMocks, resourceManager)
+	if err != nil {
+		t.Fatal("failed to create the test provider", err)
+	}
+	recorder := record.NewFakeRecorder(20)
+	provider.eventRecorder = recorder
+	provider.migrationZones = []string{"1", "2"}
+	provider.migrations = newMigrations(0)
+
+	provider.migrate(context.Background(), PodIdentifier{namespace: "ns", name: "web"})
+
+	assert.Check(t, is.DeepEqual([]string{client.ContainerGroupName("ns", "web")}, deleted))
+	assert.Check(t, is.DeepEqual([]string{fakeRegion + " zone 1", fakeRegion + " zone 2"}, placements))
+	var events []string
+	for len(recorder.Events) > 0 {
+		if event := <-recorder.Events; strings.Contains(event, "ContainerGroupMigrat") {
+			events = append(events, event)
+		}
+	}
+	assert.Assert(t, is.Len(events, 2))
+	assert.Check(t, is.Contains(events[0], "Warning ContainerGroupMigrationFailed ACIP-027: unable to recreate the container group in "+fakeRegion+" zone 1"))
+	assert.Check(t, is.Equal("Normal ContainerGroupMigrated container group failed 0 times in a row in "+fakeRegion+", it is recreated in "+fakeRegion+" zone 2", events[1]))
+}
@@ -2,8 +2,11 @@ package provider
 
 import (
 	"context"
+	"time"
 
 	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	"github.com/Azure/azure-sdk-for-go/services/preview/monitor/mgmt/2021-07-01-preview/insights"
+	"github.com/Azure/azure-sdk-for-go/services/resourcehealth/mgmt/2020-05-01/resourcehealth"
 	"github.com/virtual-kubelet/azure-aci/pkg/client"
 	"github.com/virtual-kubelet/virtual-kubelet/node/api"
 )
@@ -11,21 +14,29 @@ import (
 type CreateContainerGroupFunc func(ctx context.Context, resourceGroup, podNS, podName string, cg *client.ContainerGroupWrapper) error
 type GetContainerGroupInfoFunc func(ctx context.Context, resourceGroup, namespace, name, nodeName string) (*azaci.ContainerGroup, error)
 type GetContainerGroupListFunc func(ctx context.Context, resourceGroup string) (*[]azaci.ContainerGroup, error)
+type ListContainerGroupsByNodeNameFunc func(ctx context.Context, resourceGroup, nodeName string) (*[]azaci.ContainerGroup, error)
 type ListCapabilitiesFunc func(ctx context.Context, region string) (*[]azaci.Capabilities, error)
 type DeleteContainerGroupFunc func(ctx context.Context, resourceGroup, cgName string) error
+type UpdateContainerGroupTagsFunc func(ctx context.Context, resourceGroup, cgName string, tags map[string]*string) error
 type ListLogsFunc func(ctx context.Context, resourceGroup, cgName, containerName string, opts api.ContainerLogOpts) (*string, error)
 type ExecuteContainerCommandFunc func(ctx context.Context, resourceGroup, cgName, containerName string, containerReq azaci.ContainerExecRequest) (azaci.ContainerExecResponse, error)
+type ListAvailabilityStatusesFunc func(ctx context.Context, resourceGroup string) (*[]resourcehealth.AvailabilityStatus, error)
+type GetContainerGroupMetricsFunc func(ctx context.Context, resourceGroup, containerGroupName string, metricNames []string, start, end time.Time) (*insights.Response, error)
 
 type GetContainerGroupFunc func(ctx context.Context, resourceGroup, containerGroupName string) (*client.ContainerGroupWrapper, error)
 
 type MockACIProvider struct {
-	MockCreateContainerGroup    CreateContainerGroupFunc
-	MockGetContainerGroupInfo   GetContainerGroupInfoFunc
-	MockGetContainerGroupList   GetContainerGroupListFunc
-	MockListCapabilities        ListCapabilitiesFunc
-	MockDeleteContainerGroup    DeleteContainerGroupFunc
-	MockListLogs                ListLogsFunc
-	MockExecuteContainerCommand ExecuteContainerCommandFunc
+	MockCreateContainerGroup          CreateContainerGroupFunc
+	MockGetContainerGroupInfo         GetContainerGroupInfoFunc
+	MockGetContainerGroupList         GetContainerGroupListFunc
+	MockListContainerGroupsByNodeName ListContainerGroupsByNodeNameFunc
+	MockListCapabilities              ListCapabilitiesFunc
+	MockDeleteContainerGroup          DeleteContainerGroupFunc
+	MockUpdateContainerGroupTags      UpdateContainerGroupTagsFunc
+	MockListLogs                      ListLogsFunc
+	MockExecuteContainerCommand       ExecuteContainerCommandFunc
+	MockListAvailabilityStatuses      ListAvailabilityStatusesFunc
+	MockGetContainerGroupMetrics      GetContainerGroupMetricsFunc
 
 	MockGetContainerGroup GetContainerGroupFunc
 }
@@ -50,6 +61,13 @@ func (m *MockACIProvider) GetContainerGroupListResult(ctx context.Context, resou
 	return nil, nil
 }
 
+func (m *MockACIProvider) ListContainerGroupsByNodeName(ctx context.Context, resourceGroup, nodeName string) (*[]azaci.ContainerGroup, error) {
+	if m.MockListContainerGroupsByNodeName != nil {
+		return m.MockListContainerGroupsByNodeName(ctx, resourceGroup, nodeName)
+	}
+	return nil, nil
+}
+
 func (m *MockACIProvider) GetContainerGroupInfo(ctx context.Context, resourceGroup, namespace, name, nodeName string) (*azaci.ContainerGroup, error) {
 	if m.MockGetContainerGroupInfo != nil {
 		return m.MockGetContainerGroupInfo(ctx, resourceGroup, namespace, name, nodeName)
@@ -70,6 +88,13 @@ func (m *MockACIProvider) DeleteContainerGroup(ctx context.Context, resourceGrou
 	return nil
 }
 
+func (m *MockACIProvider) UpdateContainerGroupTags(ctx context.Context, resourceGroup, cgName string, tags map[string]*string) error {
+	if m.MockUpdateContainerGroupTags != nil {
+		return m.MockUpdateContainerGroupTags(ctx, resourceGroup, cgName, tags)
+	}
+	return nil
+}
+
 func (m *MockACIProvider) ListLogs(ctx context.Context, resourceGroup, cgName, containerName string, opts api.ContainerLogOpts) (*string, error) {
 	if m.MockListLogs != nil {
 		return m.MockListLogs(ctx, resourceGroup, cgName, containerName, opts)
@@ -91,3 +116,17 @@ func (m *MockACIProvider) GetContainerGroup(ctx context.Context, resourceGroup,
 	}
 	return nil, nil
 }
+
+func (m *MockACIProvider) ListAvailabilityStatuses(ctx context.Context, resourceGroup string) (*[]resourcehealth.AvailabilityStatus, error) {
+	if m.MockListAvailabilityStatuses != nil {
+		return m.MockListAvailabilityStatuses(ctx, resourceGroup)
+	}
+	return nil, nil
+}
+
+func (m *MockACIProvider) GetContainerGroupMetrics(ctx context.Context, resourceGroup, containerGroupName string, metricNames []string, start, end time.Time) (*insights.Response, error) {
+	if m.MockGetContainerGroupMetrics != nil {
+		return m.MockGetContainerGroupMetrics(ctx, resourceGroup, containerGroupName, metricNames, start, end)
+	}
+	return nil, nil
+}
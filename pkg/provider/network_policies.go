@@ -0,0 +1,75 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/virtual-kubelet/azure-aci/pkg/errcodes"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	networkPolicyCheckWarn = "Warn"
+	networkPolicyCheckDeny = "Deny"
+
+	eventReasonNetworkPolicyBypassed = "NetworkPolicyBypassed"
+)
+
+// selectingNetworkPolicies returns the names of the NetworkPolicies selecting the pod. Policies with
+// an invalid pod selector select nothing, like in the network plugins.
+func selectingNetworkPolicies(ctx context.Context, pod *v1.Pod, policies []networkingv1.NetworkPolicy) []string {
+	var names []string
+	for _, policy := range policies {
+		if policy.Namespace != pod.Namespace {
+			continue
+		}
+		selector, err := metav1.LabelSelectorAsSelector(&policy.Spec.PodSelector)
+		if err != nil {
+			log.G(ctx).WithError(err).Warnf("ignoring NetworkPolicy %s/%s with an invalid pod selector", policy.Namespace, policy.Name)
+			continue
+		}
+		if selector.Matches(labels.Set(pod.Labels)) {
+			names = append(names, policy.Name)
+		}
+	}
+	return names
+}
+
+// checkNetworkPolicies warns about the NetworkPolicies selecting the pod, or rejects the pod when the
+// check denies them. Pods on ACI are not behind the network plugin of the cluster, so the policies
+// silently do not protect them.
+func (p *ACIProvider) checkNetworkPolicies(ctx context.Context, pod *v1.Pod) error {
+	if p.networkPolicyCheck == "" || p.kubeClient == nil {
+		return nil
+	}
+
+	policies, err := p.kubeClient.NetworkingV1().NetworkPolicies(pod.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		if p.networkPolicyCheck == networkPolicyCheckDeny {
+			return fmt.Errorf("unable to check the NetworkPolicies of pod %s: %v", pod.Name, err)
+		}
+		log.G(ctx).WithError(err).Warnf("unable to check the NetworkPolicies of pod %s/%s", pod.Namespace, pod.Name)
+		return nil
+	}
+
+	names := selectingNetworkPolicies(ctx, pod, policies.Items)
+	if len(names) == 0 {
+		return nil
+	}
+	message := fmt.Sprintf("NetworkPolicies %s select the pod, but are not enforced on the virtual node", strings.Join(names, ", "))
+	p.recordEvent(pod, v1.EventTypeWarning, eventReasonNetworkPolicyBypassed, "%s", message)
+	if p.networkPolicyCheck == networkPolicyCheckDeny {
+		return errcodes.Wrap(errcodes.NetworkPolicyNotSupported, errdefs.InvalidInput(message))
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"testing"
+
+	testsutil "github.com/virtual-kubelet/azure-aci/pkg/tests"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func networkPolicy(namespace, name string, selector metav1.LabelSelector) *networkingv1.NetworkPolicy {
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec:       networkingv1.NetworkPolicySpec{PodSelector: selector},
+	}
+}
+
+func TestSelectingNetworkPolicies(t *testing.T) {
+	pod := testsutil.CreatePodObj("web", "ns")
+	pod.Labels = map[string]string{"app": "web"}
+
+	policies := []networkingv1.NetworkPolicy{
+		*networkPolicy("ns", "default-deny", metav1.LabelSelector{}),
+		*networkPolicy("ns", "web", metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}),
+		*networkPolicy("ns", "db", metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}}),
+		*networkPolicy("other", "default-deny", metav1.LabelSelector{}),
+		*networkPolicy("ns", "invalid", metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "app", Operator: "Near"}}}),
+	}
+	assert.Check(t, is.DeepEqual([]string{"default-deny", "web"}, selectingNetworkPolicies(context.Background(), pod, policies)))
+}
+
+func TestCheckNetworkPolicies(t *testing.T) {
+	pod := testsutil.CreatePodObj("web", "ns")
+	pod.Labels = map[string]string{"app": "web"}
+	kubeClient := fake.NewSimpleClientset(networkPolicy("ns", "web", metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}))
+
+	p := &ACIProvider{kubeClient: kubeClient}
+	assert.NilError(t, p.checkNetworkPolicies(context.Background(), pod), "pods should not be checked by default")
+
+	p.networkPolicyCheck = networkPolicyCheckWarn
+	assert.NilError(t, p.checkNetworkPolicies(context.Background(), pod))
+
+	p.networkPolicyCheck = networkPolicyCheckDeny
+	err := p.checkNetworkPolicies(context.Background(), pod)
+	assert.Check(t, errdefs.IsInvalidInput(err), "expected an invalid input error, got %v", err)
+
+	other := testsutil.CreatePodObj("db", "ns")
+	other.Labels = map[string]string{"app": "db"}
+	assert.NilError(t, p.checkNetworkPolicies(context.Background(), other))
+}
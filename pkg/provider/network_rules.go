@@ -0,0 +1,101 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	client2 "github.com/virtual-kubelet/azure-aci/pkg/client"
+)
+
+const (
+	networkRuleInbound  = "Inbound"
+	networkRuleOutbound = "Outbound"
+)
+
+// networkRule is a rule the network security group or firewall of the delegated subnets must allow
+// for the container groups of the virtual node.
+type networkRule struct {
+	Direction   string `json:"direction"`
+	Protocol    string `json:"protocol"`
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Ports       string `json:"ports"`
+	Purpose     string `json:"purpose"`
+}
+
+// apiServerEndpoint returns the host and port of the API server the kube-proxy extension connects to.
+func apiServerEndpoint(masterURI string) (string, string) {
+	if !strings.Contains(masterURI, "://") {
+		masterURI = "https://" + masterURI
+	}
+	u, err := url.Parse(masterURI)
+	if err != nil || u.Hostname() == "" {
+		return masterURI, "443"
+	}
+	port := u.Port()
+	if port == "" {
+		port = "443"
+		if u.Scheme == "http" {
+			port = "80"
+		}
+	}
+	return u.Hostname(), port
+}
+
+// requiredNetworkRules returns the rules the delegated subnets need with the current configuration
+// of the provider. It is empty when the virtual node has no subnet.
+func (p *ACIProvider) requiredNetworkRules() []networkRule {
+	if p.subnetName == "" {
+		return nil
+	}
+
+	subnets := []string{p.subnetCIDR}
+	for _, subnet := range p.subnets {
+		if subnet.cidr != "" {
+			subnets = append(subnets, subnet.cidr)
+		}
+	}
+	sort.Strings(subnets[1:])
+	source := strings.Join(subnets, ",")
+
+	host, port := apiServerEndpoint(p.masterURI)
+	rules := []networkRule{
+		{networkRuleOutbound, "TCP", source, host, port, "Kubernetes API server, for the kube-proxy extension"},
+		{networkRuleOutbound, "TCP", source, "mcr.microsoft.com,*.data.mcr.microsoft.com", "443", "Microsoft Container Registry, for the images of the container group extensions"},
+	}
+	if p.kubeDNSIP != "" {
+		rules = append(rules,
+			networkRule{networkRuleOutbound, "UDP", source, p.kubeDNSIP, "53", "cluster DNS"},
+			networkRule{networkRuleOutbound, "TCP", source, p.kubeDNSIP, "53", "cluster DNS"})
+	}
+	for _, cred := range p.defaultRegistryCredentials {
+		rules = append(rules, networkRule{networkRuleOutbound, "TCP", source, cred.Server, "443", "default registry of the pod images"})
+	}
+	if p.diagnostics != nil {
+		rules = append(rules, networkRule{networkRuleOutbound, "TCP", source, "AzureMonitor", "443", "Log Analytics workspace of the container logs"})
+	}
+	for _, extension := range p.containerGroupExtensions {
+		if extension.Properties != nil && extension.Properties.Type == client2.ExtensionTypeRealtimeMetrics {
+			rules = append(rules, networkRule{networkRuleOutbound, "TCP", source, "AzureMonitor", "443", "realtime metrics extension"})
+		}
+	}
+	if _, _, err := net.ParseCIDR(p.clusterCIDR); err == nil {
+		rules = append(rules, networkRule{networkRuleInbound, "Any", p.clusterCIDR, source, "*", "pods of the cluster connecting to the container ports"})
+	}
+	return rules
+}
+
+func (p *ACIProvider) adminNetworkRules(w http.ResponseWriter, r *http.Request) {
+	if p.subnetName == "" {
+		http.Error(w, "the virtual node has no subnet", http.StatusNotFound)
+		return
+	}
+	writeAdminJSON(r.Context(), w, http.StatusOK, p.requiredNetworkRules())
+}
@@ -0,0 +1,69 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	client2 "github.com/virtual-kubelet/azure-aci/pkg/client"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestAPIServerEndpoint(t *testing.T) {
+	cases := map[string][2]string{
+		"10.0.0.1":                        {"10.0.0.1", "443"},
+		"https://myaks.hcp.azmk8s.io:443": {"myaks.hcp.azmk8s.io", "443"},
+		"https://10.0.0.1:6443":           {"10.0.0.1", "6443"},
+		"http://10.0.0.1":                 {"10.0.0.1", "80"},
+	}
+	for masterURI, want := range cases {
+		host, port := apiServerEndpoint(masterURI)
+		assert.Check(t, is.DeepEqual(want, [2]string{host, port}), masterURI)
+	}
+}
+
+func TestRequiredNetworkRules(t *testing.T) {
+	assert.Check(t, is.Len((&ACIProvider{}).requiredNetworkRules(), 0), "nodes without subnet need no rules")
+
+	p := &ACIProvider{
+		subnetName:                 "aci",
+		subnetCIDR:                 "10.241.0.0/24",
+		subnets:                    map[string]*delegatedSubnet{"team-a": {name: "team-a", cidr: "10.242.0.0/24"}},
+		masterURI:                  "https://myaks.hcp.azmk8s.io:443",
+		clusterCIDR:                "10.240.0.0/16",
+		kubeDNSIP:                  "10.0.0.10",
+		diagnostics:                &azaci.ContainerGroupDiagnostics{},
+		defaultRegistryCredentials: []registryCredentialConfig{{Server: "myregistry.azurecr.io"}},
+		containerGroupExtensions:   []*client2.Extension{client2.GetRealtimeMetricsExtension()},
+	}
+	rules := p.requiredNetworkRules()
+
+	source := "10.241.0.0/24,10.242.0.0/24"
+	want := []networkRule{
+		{networkRuleOutbound, "TCP", source, "myaks.hcp.azmk8s.io", "443", "Kubernetes API server, for the kube-proxy extension"},
+		{networkRuleOutbound, "TCP", source, "mcr.microsoft.com,*.data.mcr.microsoft.com", "443", "Microsoft Container Registry, for the images of the container group extensions"},
+		{networkRuleOutbound, "UDP", source, "10.0.0.10", "53", "cluster DNS"},
+		{networkRuleOutbound, "TCP", source, "10.0.0.10", "53", "cluster DNS"},
+		{networkRuleOutbound, "TCP", source, "myregistry.azurecr.io", "443", "default registry of the pod images"},
+		{networkRuleOutbound, "TCP", source, "AzureMonitor", "443", "Log Analytics workspace of the container logs"},
+		{networkRuleOutbound, "TCP", source, "AzureMonitor", "443", "realtime metrics extension"},
+		{networkRuleInbound, "Any", "10.240.0.0/16", source, "*", "pods of the cluster connecting to the container ports"},
+	}
+	assert.Check(t, is.DeepEqual(want, rules))
+
+	handler := p.AdminHandler("secret")
+	rec := adminRequest(handler, http.MethodGet, "/network/rules", "secret")
+	assert.Check(t, is.Equal(http.StatusOK, rec.Code))
+	var served []networkRule
+	assert.NilError(t, json.NewDecoder(rec.Body).Decode(&served))
+	assert.Check(t, is.DeepEqual(want, served))
+
+	rec = adminRequest((&ACIProvider{}).AdminHandler("secret"), http.MethodGet, "/network/rules", "secret")
+	assert.Check(t, is.Equal(http.StatusNotFound, rec.Code))
+}
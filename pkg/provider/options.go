@@ -0,0 +1,29 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"github.com/gorilla/websocket"
+)
+
+// ProviderOption customizes the provider created by NewACIProvider.
+type ProviderOption func(*ACIProvider)
+
+// WithExecDialer sets the websocket dialer of the exec sessions, e.g. with a proxy, a TLS config or
+// a handshake timeout for private or proxied environments. websocket.DefaultDialer is used by
+// default.
+func WithExecDialer(dialer *websocket.Dialer) ProviderOption {
+	return func(p *ACIProvider) {
+		p.execDialer = dialer
+	}
+}
+
+// getExecDialer returns the websocket dialer of the exec sessions.
+func (p *ACIProvider) getExecDialer() *websocket.Dialer {
+	if p.execDialer != nil {
+		return p.execDialer
+	}
+	return websocket.DefaultDialer
+}
@@ -0,0 +1,114 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// outagePolicyNodeNotReady marks the node NotReady and keeps the last known pod statuses, the
+	// node controller then marks the pods not ready and evicts them once their tolerations expire.
+	outagePolicyNodeNotReady = "NodeNotReady"
+	// outagePolicyFreezeStatus keeps the node Ready and the last known pod statuses, so nothing is
+	// evicted while the container groups likely keep running.
+	outagePolicyFreezeStatus = "FreezeStatus"
+	// outagePolicyMarkPodsUnknown keeps the node Ready and reports the pods whose status fails to be
+	// fetched as Unknown and not ready, so they leave their services without being evicted.
+	outagePolicyMarkPodsUnknown = "MarkPodsUnknown"
+
+	podStatusReasonProviderUnreachable = "ProviderUnreachable"
+)
+
+// outageHandling decides how the node and the pods are reported once ARM has been unreachable for
+// longer than the threshold. ARM is unreachable while the breaker of the health monitor is open.
+type outageHandling struct {
+	policy    string
+	threshold time.Duration
+	health    *aciHealthMonitor
+}
+
+// newOutageHandling returns the outage handling of the policy, NodeNotReady when unset. The node
+// goes NotReady as soon as the breaker opens by default, like it did before outages were configurable.
+func newOutageHandling(policy string, threshold time.Duration, health *aciHealthMonitor) *outageHandling {
+	if policy == "" {
+		policy = outagePolicyNodeNotReady
+	}
+	return &outageHandling{policy: policy, threshold: threshold, health: health}
+}
+
+func isValidOutagePolicy(policy string) bool {
+	switch policy {
+	case outagePolicyNodeNotReady, outagePolicyFreezeStatus, outagePolicyMarkPodsUnknown:
+		return true
+	}
+	return false
+}
+
+// exceeded returns how long ARM has been unreachable when it is past the threshold.
+func (o *outageHandling) exceeded(now time.Time) (time.Duration, bool) {
+	if o == nil || o.health == nil {
+		return 0, false
+	}
+	since, ok := o.health.OutageSince()
+	if !ok {
+		return 0, false
+	}
+	duration := now.Sub(since)
+	return duration, duration >= o.threshold
+}
+
+// nodeError returns the error the node is reported NotReady with, nil while it stays Ready.
+func (o *outageHandling) nodeError(now time.Time) error {
+	if o == nil || o.policy != outagePolicyNodeNotReady {
+		return nil
+	}
+	if _, ok := o.exceeded(now); !ok {
+		return nil
+	}
+	return o.health.Healthy()
+}
+
+// marksPodsUnknown returns how long ARM has been unreachable when the pods are to be marked Unknown.
+func (o *outageHandling) marksPodsUnknown(now time.Time) (time.Duration, bool) {
+	if o == nil || o.policy != outagePolicyMarkPodsUnknown {
+		return 0, false
+	}
+	return o.exceeded(now)
+}
+
+// setPodUnknown marks the pod Unknown and its containers not ready, their last known states are kept.
+// It returns false when the pod was already marked.
+func setPodUnknown(pod *v1.Pod, outage time.Duration) bool {
+	if pod.Status.Phase == v1.PodUnknown && pod.Status.Reason == podStatusReasonProviderUnreachable {
+		return false
+	}
+
+	message := fmt.Sprintf("ARM has been unreachable for %s, the status of the pod is unknown", outage.Round(time.Second))
+	pod.Status.Phase = v1.PodUnknown
+	pod.Status.Reason = podStatusReasonProviderUnreachable
+	pod.Status.Message = message
+	now := metav1.NewTime(time.Now())
+	for i := range pod.Status.Conditions {
+		condition := &pod.Status.Conditions[i]
+		if condition.Type != v1.PodReady && condition.Type != v1.ContainersReady {
+			continue
+		}
+		if condition.Status != v1.ConditionFalse {
+			condition.LastTransitionTime = now
+		}
+		condition.Status = v1.ConditionFalse
+		condition.Reason = podStatusReasonProviderUnreachable
+		condition.Message = message
+	}
+	for i := range pod.Status.ContainerStatuses {
+		pod.Status.ContainerStatuses[i].Ready = false
+	}
+	return true
+}
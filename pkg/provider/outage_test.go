@@ -0,0 +1,111 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	testsutil "github.com/virtual-kubelet/azure-aci/pkg/tests"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	v1 "k8s.io/api/core/v1"
+)
+
+// openHealthMonitor returns a health monitor whose breaker opened for a while before now.
+func openHealthMonitor(now time.Time, since time.Duration) *aciHealthMonitor {
+	clock := now.Add(-since)
+	h := &aciHealthMonitor{
+		window:             time.Minute,
+		errorRateThreshold: 0.5,
+		minRequests:        1,
+		now:                func() time.Time { return clock },
+	}
+	h.Record(errors.New("dial tcp: i/o timeout"))
+	clock = now
+	return h
+}
+
+func TestOutagePolicies(t *testing.T) {
+	now := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, tc := range []struct {
+		policy       string
+		nodeNotReady bool
+		podsUnknown  bool
+	}{
+		{policy: "", nodeNotReady: true},
+		{policy: outagePolicyNodeNotReady, nodeNotReady: true},
+		{policy: outagePolicyFreezeStatus},
+		{policy: outagePolicyMarkPodsUnknown, podsUnknown: true},
+	} {
+		healthy := newOutageHandling(tc.policy, 0, &aciHealthMonitor{})
+		assert.Check(t, healthy.nodeError(now) == nil, "%q: the node should be ready while ARM is reachable", tc.policy)
+		_, ok := healthy.marksPodsUnknown(now)
+		assert.Check(t, !ok, "%q: the pods should be kept while ARM is reachable", tc.policy)
+
+		within := newOutageHandling(tc.policy, 10*time.Minute, openHealthMonitor(now, 5*time.Minute))
+		assert.Check(t, within.nodeError(now) == nil, "%q: the node should be ready within the threshold", tc.policy)
+		_, ok = within.marksPodsUnknown(now)
+		assert.Check(t, !ok, "%q: the pods should be kept within the threshold", tc.policy)
+
+		past := newOutageHandling(tc.policy, 10*time.Minute, openHealthMonitor(now, 15*time.Minute))
+		assert.Check(t, is.Equal(tc.nodeNotReady, past.nodeError(now) != nil), "%q: unexpected node readiness past the threshold", tc.policy)
+		outage, ok := past.marksPodsUnknown(now)
+		assert.Check(t, is.Equal(tc.podsUnknown, ok), "%q: unexpected pod statuses past the threshold", tc.policy)
+		if ok {
+			assert.Check(t, is.Equal(15*time.Minute, outage))
+		}
+	}
+
+	var unset *outageHandling
+	assert.Check(t, unset.nodeError(now) == nil)
+}
+
+func TestPodsTrackerOutage(t *testing.T) {
+	newPod := func() *v1.Pod {
+		pod := testsutil.CreatePodObj("web", "ns")
+		pod.Status.Phase = v1.PodRunning
+		pod.Status.Conditions = []v1.PodCondition{
+			{Type: v1.PodScheduled, Status: v1.ConditionTrue},
+			{Type: v1.PodReady, Status: v1.ConditionTrue},
+			{Type: v1.ContainersReady, Status: v1.ConditionTrue},
+		}
+		pod.Status.ContainerStatuses = []v1.ContainerStatus{{
+			Name:  "nginx",
+			Ready: true,
+			State: v1.ContainerState{Running: &v1.ContainerStateRunning{}},
+		}}
+		return pod
+	}
+	handler := &fakePodsTrackerHandler{fetchErr: errors.New("dial tcp: i/o timeout")}
+	id := PodIdentifier{namespace: "ns", name: "web"}
+
+	pt := &PodsTracker{handler: handler, outage: newOutageHandling(outagePolicyFreezeStatus, 0, openHealthMonitor(time.Now(), time.Minute))}
+	pod := newPod()
+	assert.Check(t, !pt.processPodUpdates(context.Background(), pod), "the last known status should be frozen")
+	assert.Check(t, is.DeepEqual(newPod().Status, pod.Status))
+
+	pt = &PodsTracker{handler: handler, outage: newOutageHandling(outagePolicyMarkPodsUnknown, 0, openHealthMonitor(time.Now(), time.Minute))}
+	assert.Check(t, pt.processPodUpdates(context.Background(), pod), "the pod should be marked unknown")
+	assert.Check(t, is.Equal(v1.PodUnknown, pod.Status.Phase))
+	assert.Check(t, is.Equal(podStatusReasonProviderUnreachable, pod.Status.Reason))
+	assert.Check(t, is.Equal(v1.ConditionTrue, pod.Status.Conditions[0].Status), "the pod is still scheduled")
+	assert.Check(t, is.Equal(v1.ConditionFalse, pod.Status.Conditions[1].Status))
+	assert.Check(t, is.Equal(v1.ConditionFalse, pod.Status.Conditions[2].Status))
+	assert.Check(t, !pod.Status.ContainerStatuses[0].Ready)
+	assert.Check(t, pod.Status.ContainerStatuses[0].State.Running != nil, "the last known container state should be kept")
+
+	backoff := pt.backoffs[id]
+	backoff.next = time.Time{}
+	pt.backoffs[id] = backoff
+	assert.Check(t, !pt.processPodUpdates(context.Background(), pod), "pods already marked unknown should not be updated again")
+
+	pt.outage.health = &aciHealthMonitor{}
+	pt.backoffs[id] = statusFetchBackoff{}
+	handler.fetchErr = errors.New("500 Internal Server Error")
+	assert.Check(t, !pt.processPodUpdates(context.Background(), newPod()), "pods should not be marked unknown without an outage")
+}
@@ -0,0 +1,54 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// getPlacementNotes explains how the scheduling constraints of a pod apply once it runs on ACI.
+// The scheduler evaluates them to pick the virtual node, but the virtual node is a single host
+// backed by a whole region, so ACI places the container group without regard to them.
+func getPlacementNotes(pod *v1.Pod) []string {
+	affinity := pod.Spec.Affinity
+	var notes []string
+
+	if affinity != nil && affinity.NodeAffinity != nil {
+		notes = append(notes, "node affinity only selected the virtual node, ACI does not place the container group by node labels")
+	}
+
+	if affinity != nil && affinity.PodAffinity != nil {
+		for _, term := range getPodAffinityTerms(affinity.PodAffinity.RequiredDuringSchedulingIgnoredDuringExecution, affinity.PodAffinity.PreferredDuringSchedulingIgnoredDuringExecution) {
+			notes = append(notes, fmt.Sprintf("pod affinity on %s is not enforced, ACI does not co-locate container groups", term.TopologyKey))
+		}
+	}
+
+	if affinity != nil && affinity.PodAntiAffinity != nil {
+		for _, term := range getPodAffinityTerms(affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution, affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution) {
+			if term.TopologyKey == v1.LabelHostname {
+				notes = append(notes, fmt.Sprintf("pod anti-affinity on %s treats all ACI pods as one host", term.TopologyKey))
+				continue
+			}
+			notes = append(notes, fmt.Sprintf("pod anti-affinity on %s is not enforced, ACI does not spread container groups", term.TopologyKey))
+		}
+	}
+
+	for _, constraint := range pod.Spec.TopologySpreadConstraints {
+		notes = append(notes, fmt.Sprintf("topology spread constraint on %s only counts the virtual node as a single domain", constraint.TopologyKey))
+	}
+
+	return notes
+}
+
+func getPodAffinityTerms(required []v1.PodAffinityTerm, preferred []v1.WeightedPodAffinityTerm) []v1.PodAffinityTerm {
+	terms := make([]v1.PodAffinityTerm, 0, len(required)+len(preferred))
+	terms = append(terms, required...)
+	for _, weighted := range preferred {
+		terms = append(terms, weighted.PodAffinityTerm)
+	}
+	return terms
+}
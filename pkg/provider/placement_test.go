@@ -0,0 +1,36 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"strings"
+	"testing"
+
+	testsutil "github.com/virtual-kubelet/azure-aci/pkg/tests"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestGetPlacementNotes(t *testing.T) {
+	pod := testsutil.CreatePodObj("pod", "ns")
+	assert.Check(t, is.Equal(0, len(getPlacementNotes(pod))), "no notes expected without constraints")
+
+	pod.Spec.Affinity = &v1.Affinity{
+		NodeAffinity: &v1.NodeAffinity{},
+		PodAntiAffinity: &v1.PodAntiAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: []v1.PodAffinityTerm{{TopologyKey: v1.LabelHostname}},
+			PreferredDuringSchedulingIgnoredDuringExecution: []v1.WeightedPodAffinityTerm{
+				{Weight: 1, PodAffinityTerm: v1.PodAffinityTerm{TopologyKey: v1.LabelZoneFailureDomainStable}},
+			},
+		},
+	}
+	pod.Spec.TopologySpreadConstraints = []v1.TopologySpreadConstraint{{TopologyKey: v1.LabelZoneFailureDomainStable}}
+
+	notes := getPlacementNotes(pod)
+	assert.Check(t, is.Equal(4, len(notes)), "notes count doesn't match")
+	assert.Check(t, strings.Contains(notes[1], "one host"), "hostname anti-affinity note expected")
+	assert.Check(t, strings.Contains(notes[2], "not enforced"), "zone anti-affinity note expected")
+}
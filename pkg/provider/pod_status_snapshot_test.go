@@ -0,0 +1,171 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	"github.com/Azure/go-autorest/autorest/date"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// updateSnapshots rewrites the snapshots with the current statuses, e.g.
+// go test ./pkg/provider -run TestPodStatusSnapshots -update
+var updateSnapshots = flag.Bool("update", false, "update the pod status snapshots")
+
+const podStatusSnapshotsFile = "testdata/pod_status_snapshots.txt"
+
+// snapshotInstance is the instance view of a container group with a single container.
+type snapshotInstance struct {
+	name           string
+	groupState     string
+	containerState string
+	exitCode       *int32
+	detailStatus   string
+	started        time.Duration
+	finished       time.Duration
+}
+
+// TestPodStatusSnapshots renders the pod statuses of a matrix of provisioning states, instance views
+// and container events, and compares them to the snapshots in testdata. Changes to the statuses
+// users depend on show up as diffs of the snapshots, which are updated with -update once reviewed.
+func TestPodStatusSnapshots(t *testing.T) {
+	created := time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)
+	exitCode := func(code int32) *int32 { return &code }
+
+	provisioningStates := []string{"Creating", "Succeeded", "Failed"}
+	instances := []snapshotInstance{
+		{name: "Pending", groupState: "Pending", containerState: "Waiting", started: time.Second},
+		{name: "Running", groupState: "Running", containerState: "Running", started: 2 * time.Second},
+		{name: "Succeeded", groupState: "Succeeded", containerState: "Terminated", exitCode: exitCode(0), detailStatus: "Completed", started: 2 * time.Second, finished: 5 * time.Second},
+		{name: "Failed", groupState: "Failed", containerState: "Terminated", exitCode: exitCode(1), detailStatus: "Error", started: 2 * time.Second, finished: 5 * time.Second},
+	}
+	events := map[string][]azaci.Event{
+		"none":      {},
+		"imagePull": {aciEvent("Failed", "Warning", "Failed to pull image \"nginx\"", 1, created.Add(time.Second))},
+	}
+
+	p := &ACIProvider{}
+	var snapshots strings.Builder
+	for _, provisioningState := range provisioningStates {
+		for _, instance := range instances {
+			for _, eventsName := range []string{"none", "imagePull"} {
+				cg := snapshotContainerGroup(created, provisioningState, instance, events[eventsName])
+				status, err := p.getPodStatusFromContainerGroup(cg)
+				assert.NilError(t, err)
+				fmt.Fprintf(&snapshots, "== provisioning=%s instance=%s events=%s\n", provisioningState, instance.name, eventsName)
+				snapshots.WriteString(renderPodStatus(status, created))
+				snapshots.WriteString("\n")
+			}
+		}
+	}
+
+	if *updateSnapshots {
+		assert.NilError(t, os.MkdirAll(filepath.Dir(podStatusSnapshotsFile), 0755))
+		assert.NilError(t, os.WriteFile(podStatusSnapshotsFile, []byte(snapshots.String()), 0644))
+		return
+	}
+	expected, err := os.ReadFile(podStatusSnapshotsFile)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(string(expected), snapshots.String()), "pod statuses changed, review them and run the test with -update")
+}
+
+func snapshotContainerGroup(created time.Time, provisioningState string, instance snapshotInstance, events []azaci.Event) *azaci.ContainerGroup {
+	name, namespace, ip, image := "web", "ns", "10.0.0.4", "nginx"
+	creationTimestamp := metav1.NewTime(created).String()
+	containerState := instance.containerState
+	detailStatus := instance.detailStatus
+	restartCount := int32(0)
+	state := &azaci.ContainerState{
+		State:        &containerState,
+		StartTime:    &date.Time{Time: created.Add(instance.started)},
+		ExitCode:     instance.exitCode,
+		DetailStatus: &detailStatus,
+	}
+	if instance.finished != 0 {
+		state.FinishTime = &date.Time{Time: created.Add(instance.finished)}
+	}
+	containerName := "app"
+	groupState := instance.groupState
+	containerEvents := append([]azaci.Event(nil), events...)
+
+	return &azaci.ContainerGroup{
+		Name: &name,
+		ID:   &name,
+		Tags: map[string]*string{
+			"CreationTimestamp": &creationTimestamp,
+			"PodName":           &name,
+			"Namespace":         &namespace,
+		},
+		ContainerGroupProperties: &azaci.ContainerGroupProperties{
+			ProvisioningState: &provisioningState,
+			InstanceView:      &azaci.ContainerGroupPropertiesInstanceView{State: &groupState},
+			IPAddress:         &azaci.IPAddress{IP: &ip},
+			Containers: &[]azaci.Container{{
+				Name: &containerName,
+				ContainerProperties: &azaci.ContainerProperties{
+					Image: &image,
+					Ports: &[]azaci.ContainerPort{},
+					InstanceView: &azaci.ContainerPropertiesInstanceView{
+						CurrentState: state,
+						RestartCount: &restartCount,
+						Events:       &containerEvents,
+					},
+				},
+			}},
+		},
+	}
+}
+
+// renderPodStatus renders the fields of the status users depend on, with the times relative to the
+// creation of the pod.
+func renderPodStatus(status *v1.PodStatus, created time.Time) string {
+	since := func(t metav1.Time) string {
+		return "+" + t.Sub(created).String()
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "phase: %s\n", status.Phase)
+	if status.StartTime != nil {
+		fmt.Fprintf(&b, "startTime: %s\n", since(*status.StartTime))
+	} else {
+		b.WriteString("startTime: <none>\n")
+	}
+	for _, condition := range status.Conditions {
+		fmt.Fprintf(&b, "condition %s: %s", condition.Type, condition.Status)
+		if condition.Reason != "" {
+			fmt.Fprintf(&b, " %s", condition.Reason)
+		}
+		if condition.Message != "" {
+			fmt.Fprintf(&b, " %q", condition.Message)
+		}
+		fmt.Fprintf(&b, " %s\n", since(condition.LastTransitionTime))
+	}
+	for _, container := range status.ContainerStatuses {
+		fmt.Fprintf(&b, "container %s: ready=%t restarts=%d ", container.Name, container.Ready, container.RestartCount)
+		switch state := container.State; {
+		case state.Running != nil:
+			fmt.Fprintf(&b, "running %s\n", since(state.Running.StartedAt))
+		case state.Terminated != nil:
+			fmt.Fprintf(&b, "terminated %d %s %q %s..%s\n", state.Terminated.ExitCode, state.Terminated.Reason, state.Terminated.Message,
+				since(state.Terminated.StartedAt), since(state.Terminated.FinishedAt))
+		case state.Waiting != nil:
+			fmt.Fprintf(&b, "waiting %s %q\n", state.Waiting.Reason, state.Waiting.Message)
+		default:
+			b.WriteString("unknown\n")
+		}
+	}
+	return b.String()
+}
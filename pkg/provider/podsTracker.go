@@ -2,24 +2,50 @@ package provider
 
 import (
 	"context"
+	"fmt"
+	"sync"
 	"time"
 
+	"github.com/virtual-kubelet/azure-aci/pkg/errcodes"
 	"github.com/virtual-kubelet/node-cli/manager"
 	errdef "github.com/virtual-kubelet/virtual-kubelet/errdefs"
 	"github.com/virtual-kubelet/virtual-kubelet/log"
 	"github.com/virtual-kubelet/virtual-kubelet/trace"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/record"
 )
 
 const (
 	podStatusReasonProviderFailed       = "ProviderFailed"
 	statusReasonNotFound                = "NotFound"
 	statusMessageNotFound               = "The pod may have been deleted from the provider"
+	statusReasonDeadlineExceeded        = "DeadlineExceeded"
+	statusMessageDeadlineExceeded       = "Pod was active on the node longer than the specified deadline"
 	containerExitCodeNotFound     int32 = -137
+	containerExitCodeKilled       int32 = 137 // SIGKILL
 
-	statusUpdatesInterval = 5 * time.Second
-	cleanupInterval       = 5 * time.Minute
+	// defaultStatusUpdatesInterval and defaultCleanupInterval are how often the pod statuses are
+	// updated and the dangling container groups are cleaned up by default.
+	defaultStatusUpdatesInterval = 5 * time.Second
+	defaultCleanupInterval       = 5 * time.Minute
+
+	// defaultStatusUpdateParallelism is the number of pod statuses fetched concurrently by default.
+	defaultStatusUpdateParallelism = 10
+
+	// podUpdateRequestsBuffer is the number of pod status update requests queued for the tracking loop.
+	podUpdateRequestsBuffer = 100
+
+	// statusFetchBackoffBase and statusFetchBackoffMax bound the jittered exponential backoff of the
+	// status of a pod that repeatedly fails to be fetched. After statusFetchMaxFailures failures in a
+	// row an event is published on the pod and it is only retried every statusFetchBackoffMax.
+	statusFetchBackoffBase = 5 * time.Second
+	statusFetchBackoffMax  = 5 * time.Minute
+	statusFetchMaxFailures = 10
+
+	// defaultOrphanGracePeriod is how long a container group without a pod is kept by default.
+	defaultOrphanGracePeriod = 10 * time.Minute
 )
 
 type PodIdentifier struct {
@@ -37,6 +63,38 @@ type PodsTracker struct {
 	rm       *manager.ResourceManager
 	updateCb func(*v1.Pod)
 	handler  PodsTrackerHandler
+
+	// statusUpdatesInterval and cleanupInterval are how often the pod statuses are updated and the
+	// dangling container groups are cleaned up, the defaults when unset.
+	statusUpdatesInterval time.Duration
+	cleanupInterval       time.Duration
+	// statusUpdateParallelism is the number of pod statuses fetched concurrently, the default when
+	// unset.
+	statusUpdateParallelism int
+	// orphanGracePeriod is how long a container group without a pod is kept before it is deleted,
+	// so pods that are still being synced by the informers are not mistaken for orphans.
+	orphanGracePeriod time.Duration
+	// orphans records when the container groups without a pod were first seen. It is only
+	// accessed from the tracking loop.
+	orphans map[PodIdentifier]time.Time
+	// restarts records the restart counts of the containers of the pods. It is only accessed from
+	// the tracking loop.
+	restarts map[PodIdentifier]map[string]restartCount
+	// backoffs records the pods whose status failed to be fetched. It is only accessed from the
+	// tracking loop.
+	backoffs map[PodIdentifier]statusFetchBackoff
+	// eventRecorder publishes the events of the pods, when set.
+	eventRecorder record.EventRecorder
+	// outage decides whether the pods whose status fails to be fetched during an ARM outage are
+	// marked Unknown, when set.
+	outage *outageHandling
+
+	// resync and cleanup request an immediate status update or cleanup from the tracking loop.
+	resync  chan struct{}
+	cleanup chan struct{}
+	// updates requests an immediate status update of a pod, e.g. on a notification that its
+	// container group changed.
+	updates chan PodIdentifier
 }
 
 // StartTracking starts the background tracking for created pods.
@@ -44,11 +102,21 @@ func (pt *PodsTracker) StartTracking(ctx context.Context) {
 	ctx, span := trace.StartSpan(ctx, "PodsTracker.StartTracking")
 	defer span.End()
 
+	statusUpdatesInterval := pt.statusUpdatesInterval
+	if statusUpdatesInterval <= 0 {
+		statusUpdatesInterval = defaultStatusUpdatesInterval
+	}
+	cleanupInterval := pt.cleanupInterval
+	if cleanupInterval <= 0 {
+		cleanupInterval = defaultCleanupInterval
+	}
 	statusUpdatesTimer := time.NewTimer(statusUpdatesInterval)
 	cleanupTimer := time.NewTimer(cleanupInterval)
 	defer statusUpdatesTimer.Stop()
 	defer cleanupTimer.Stop()
 
+	pt.reconcileOnStartup(ctx)
+
 	for {
 		log.G(ctx).Debug("Pod status updates & cleanup loop start")
 
@@ -59,13 +127,49 @@ func (pt *PodsTracker) StartTracking(ctx context.Context) {
 		case <-statusUpdatesTimer.C:
 			pt.updatePodsLoop(ctx)
 			statusUpdatesTimer.Reset(statusUpdatesInterval)
+		case <-pt.resync:
+			pt.updatePodsLoop(ctx)
+		case id := <-pt.updates:
+			pt.updatePod(ctx, id)
 		case <-cleanupTimer.C:
 			pt.cleanupDanglingPods(ctx)
 			cleanupTimer.Reset(cleanupInterval)
+		case <-pt.cleanup:
+			pt.cleanupDanglingPods(ctx)
 		}
 	}
 }
 
+// requestResync asks the tracking loop to update the status of every pod now.
+func (pt *PodsTracker) requestResync() {
+	trigger(pt.resync)
+}
+
+// requestCleanup asks the tracking loop to delete the orphaned container groups now, those still
+// in their grace period are kept.
+func (pt *PodsTracker) requestCleanup() {
+	trigger(pt.cleanup)
+}
+
+// requestPodUpdate asks the tracking loop to update the status of the pod now. Requests are dropped
+// while the loop is busy, the pod is updated by the next poll then.
+func (pt *PodsTracker) requestPodUpdate(id PodIdentifier) bool {
+	select {
+	case pt.updates <- id:
+		return true
+	default:
+		return false
+	}
+}
+
+// trigger sends a request without blocking, a pending request already covers a new one.
+func trigger(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
 // UpdatePodStatus updates the status of a pod, by posting to update callback.
 func (pt *PodsTracker) UpdatePodStatus(ctx context.Context, ns, name string, updateHandler func(*v1.PodStatus), forceUpdate bool) error {
 	k8sPods := pt.rm.GetPods()
@@ -90,13 +194,108 @@ func (pt *PodsTracker) updatePodsLoop(ctx context.Context) {
 	defer span.End()
 
 	k8sPods := pt.rm.GetPods()
-	for _, pod := range k8sPods {
+	now := time.Now()
+	results := pt.fetchPodStatuses(ctx, k8sPods, now)
+	for i, pod := range k8sPods {
 		updatedPod := pod.DeepCopy()
-		ok := pt.processPodUpdates(ctx, updatedPod)
+		ok := pt.applyPodStatus(ctx, updatedPod, results[i], now)
 		if ok {
 			pt.updateCb(updatedPod)
 		}
 	}
+
+	for id := range pt.restarts {
+		if getPodFromList(k8sPods, id.namespace, id.name) == nil {
+			delete(pt.restarts, id)
+		}
+	}
+	for id := range pt.backoffs {
+		if getPodFromList(k8sPods, id.namespace, id.name) == nil {
+			delete(pt.backoffs, id)
+		}
+	}
+}
+
+// fetchPodStatuses fetches the statuses of the pods with up to statusUpdateParallelism fetches in
+// flight, so the statuses of large nodes do not go stale behind a serial poll.
+func (pt *PodsTracker) fetchPodStatuses(ctx context.Context, pods []*v1.Pod, now time.Time) []podStatusResult {
+	parallelism := pt.statusUpdateParallelism
+	if parallelism <= 0 {
+		parallelism = defaultStatusUpdateParallelism
+	}
+
+	results := make([]podStatusResult, len(pods))
+	sem := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	for i, pod := range pods {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int, pod *v1.Pod) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			results[i] = pt.fetchPodStatus(ctx, pod, now)
+		}(i, pod)
+	}
+	wg.Wait()
+	return results
+}
+
+func (pt *PodsTracker) updatePod(ctx context.Context, id PodIdentifier) {
+	ctx, span := trace.StartSpan(ctx, "PodsTracker.updatePod")
+	defer span.End()
+
+	pod := getPodFromList(pt.rm.GetPods(), id.namespace, id.name)
+	if pod == nil {
+		return
+	}
+	// The container group changed, the pod is worth a retry even while it backs off.
+	if backoff, ok := pt.backoffs[id]; ok {
+		backoff.next = time.Time{}
+		pt.backoffs[id] = backoff
+	}
+	updatedPod := pod.DeepCopy()
+	if pt.processPodUpdates(ctx, updatedPod) {
+		pt.updateCb(updatedPod)
+	}
+}
+
+// reconcileOnStartup converges the state left behind by a previous run of the provider. Container
+// groups without a pod become orphans pending deletion, and pods whose container group vanished
+// while the provider was down are failed so their controllers replace them.
+func (pt *PodsTracker) reconcileOnStartup(ctx context.Context) {
+	ctx, span := trace.StartSpan(ctx, "PodsTracker.reconcileOnStartup")
+	defer span.End()
+
+	k8sPods := pt.rm.GetPods()
+	activePods, err := pt.handler.ListActivePods(ctx)
+	if err != nil {
+		log.G(ctx).WithError(err).Errorf("failed to retrieve active container groups list")
+		return
+	}
+
+	pt.trackOrphans(ctx, k8sPods, activePods)
+
+	active := make(map[PodIdentifier]bool, len(activePods))
+	for _, id := range activePods {
+		active[id] = true
+	}
+	for _, pod := range k8sPods {
+		if active[PodIdentifier{namespace: pod.Namespace, name: pod.Name}] || pt.shouldSkipPodStatusUpdate(pod) {
+			continue
+		}
+		// Pods without container statuses were never reported by the provider, they are still
+		// to be created by the pod controller.
+		if pod.Status.Phase != v1.PodRunning && len(pod.Status.ContainerStatuses) == 0 {
+			continue
+		}
+
+		log.G(ctx).Infof("container group of pod %s/%s vanished while the provider was down", pod.Namespace, pod.Name)
+		updatedPod := pod.DeepCopy()
+		setPodNotFound(updatedPod)
+		pt.updateCb(updatedPod)
+	}
 }
 
 func (pt *PodsTracker) cleanupDanglingPods(ctx context.Context) {
@@ -110,33 +309,112 @@ func (pt *PodsTracker) cleanupDanglingPods(ctx context.Context) {
 		return
 	}
 
-	if len(activePods) > 0 {
-		for i := range activePods {
-			pod := getPodFromList(k8sPods, activePods[i].namespace, activePods[i].name)
-			if pod != nil {
-				continue
-			}
+	for _, id := range pt.trackOrphans(ctx, k8sPods, activePods) {
+		log.G(ctx).Errorf("cleaning up dangling pod %v", id.name)
 
-			log.G(ctx).Errorf("cleaning up dangling pod %v", activePods[i].name)
+		err := pt.handler.CleanupPod(ctx, id.namespace, id.name)
+		if err != nil && !errdef.IsNotFound(err) {
+			log.G(ctx).WithError(err).Errorf("failed to cleanup pod %v", id.name)
+			continue
+		}
+		delete(pt.orphans, id)
+	}
+}
 
-			err := pt.handler.CleanupPod(ctx, activePods[i].namespace, activePods[i].name)
-			if err != nil && !errdef.IsNotFound(err) {
-				log.G(ctx).WithError(err).Errorf("failed to cleanup pod %v", activePods[i].name)
-			}
+// trackOrphans records the container groups that have no pod and returns the ones whose grace
+// period is over. Container groups that got a pod back or are gone are forgotten.
+func (pt *PodsTracker) trackOrphans(ctx context.Context, k8sPods []*v1.Pod, activePods []PodIdentifier) []PodIdentifier {
+	if pt.orphans == nil {
+		pt.orphans = make(map[PodIdentifier]time.Time)
+	}
+
+	now := time.Now()
+	seen := make(map[PodIdentifier]bool, len(activePods))
+	var expired []PodIdentifier
+	for _, id := range activePods {
+		if getPodFromList(k8sPods, id.namespace, id.name) != nil {
+			continue
+		}
+
+		seen[id] = true
+		firstSeen, ok := pt.orphans[id]
+		if !ok {
+			log.G(ctx).Infof("container group of pod %s/%s has no pod, it is deleted after %v", id.namespace, id.name, pt.orphanGracePeriod)
+			pt.orphans[id] = now
+			firstSeen = now
+		}
+		if now.Sub(firstSeen) >= pt.orphanGracePeriod {
+			expired = append(expired, id)
 		}
 	}
+
+	for id := range pt.orphans {
+		if !seen[id] {
+			delete(pt.orphans, id)
+		}
+	}
+	return expired
 }
 
 func (pt *PodsTracker) processPodUpdates(ctx context.Context, pod *v1.Pod) bool {
 	ctx, span := trace.StartSpan(ctx, "PodsTracker.processPodUpdates")
 	defer span.End()
 
+	now := time.Now()
+	return pt.applyPodStatus(ctx, pod, pt.fetchPodStatus(ctx, pod, now), now)
+}
+
+// podStatusResult is the outcome of fetching the status of a pod from the provider.
+type podStatusResult struct {
+	// fetched is false when the pod was not fetched, e.g. it completed or backs off.
+	fetched          bool
+	deadlineExceeded bool
+	status           *v1.PodStatus
+	err              error
+}
+
+// fetchPodStatus fetches the status of the pod. It runs concurrently for the pods of a poll, so it
+// only reads the state of the tracker.
+func (pt *PodsTracker) fetchPodStatus(ctx context.Context, pod *v1.Pod, now time.Time) podStatusResult {
 	if pt.shouldSkipPodStatusUpdate(pod) {
+		return podStatusResult{}
+	}
+
+	if pastActiveDeadline(pod, now) {
+		// ACI does not enforce activeDeadlineSeconds, the container group is deleted before the
+		// pod is failed so it stops running and billing.
+		log.G(ctx).Infof("pod %s/%s exceeded its active deadline of %ds", pod.Namespace, pod.Name, *pod.Spec.ActiveDeadlineSeconds)
+		if err := pt.handler.CleanupPod(ctx, pod.Namespace, pod.Name); err != nil && !errdef.IsNotFound(err) {
+			log.G(ctx).WithError(err).Errorf("failed to delete the container group of pod %s/%s past its active deadline", pod.Namespace, pod.Name)
+			return podStatusResult{}
+		}
+		return podStatusResult{deadlineExceeded: true}
+	}
+
+	if backoff, ok := pt.backoffs[PodIdentifier{namespace: pod.Namespace, name: pod.Name}]; ok && now.Before(backoff.next) {
+		return podStatusResult{}
+	}
+
+	status, err := pt.handler.FetchPodStatus(ctx, pod.Namespace, pod.Name)
+	return podStatusResult{fetched: true, status: status, err: err}
+}
+
+// applyPodStatus updates the pod with the status fetched from the provider, and returns whether it
+// changed.
+func (pt *PodsTracker) applyPodStatus(ctx context.Context, pod *v1.Pod, result podStatusResult, now time.Time) bool {
+	if result.deadlineExceeded {
+		setPodDeadlineExceeded(pod)
+		return true
+	}
+	if !result.fetched {
 		return false
 	}
 
-	podStatusFromProvider, err := pt.handler.FetchPodStatus(ctx, pod.Namespace, pod.Name)
+	id := PodIdentifier{namespace: pod.Namespace, name: pod.Name}
+	podStatusFromProvider, err := result.status, result.err
 	if err == nil && podStatusFromProvider != nil {
+		delete(pt.backoffs, id)
+		pt.aggregateRestartCounts(pod, podStatusFromProvider)
 		podStatusFromProvider.DeepCopyInto(&pod.Status)
 		return true
 	}
@@ -144,44 +422,174 @@ func (pt *PodsTracker) processPodUpdates(ctx context.Context, pod *v1.Pod) bool
 	if errdef.IsNotFound(err) || (err == nil && podStatusFromProvider == nil) {
 		// Only change the status when the pod was already up
 		if pod.Status.Phase == v1.PodRunning {
-			// Set the pod to failed, this makes sure if the underlying container implementation is gone that a new pod will be created.
-			pod.Status.Phase = v1.PodFailed
-			pod.Status.Reason = statusReasonNotFound
-			pod.Status.Message = statusMessageNotFound
-			now := metav1.NewTime(time.Now())
-			for i := range pod.Status.ContainerStatuses {
-				if pod.Status.ContainerStatuses[i].State.Running == nil {
-					continue
-				}
-
-				pod.Status.ContainerStatuses[i].State.Terminated = &v1.ContainerStateTerminated{
-					ExitCode:    containerExitCodeNotFound,
-					Reason:      statusReasonNotFound,
-					Message:     statusMessageNotFound,
-					FinishedAt:  now,
-					StartedAt:   pod.Status.ContainerStatuses[i].State.Running.StartedAt,
-					ContainerID: pod.Status.ContainerStatuses[i].ContainerID,
-				}
-				pod.Status.ContainerStatuses[i].State.Running = nil
-			}
-
+			delete(pt.backoffs, id)
+			setPodNotFound(pod)
 			return true
 		}
 
+		if err == nil {
+			err = errdef.NotFound("container group not found")
+		}
+		pt.statusFetchFailed(ctx, pod, now, err)
 		return false
 	}
 
 	if err != nil {
 		log.G(ctx).WithError(err).Errorf("failed to retrieve pod %v status from provider", pod.Name)
+		pt.statusFetchFailed(ctx, pod, now, err)
+		if outage, ok := pt.outage.marksPodsUnknown(now); ok {
+			return setPodUnknown(pod, outage)
+		}
 	}
 
 	return false
 }
 
+// statusFetchBackoff is the backoff of a pod whose status failed to be fetched.
+type statusFetchBackoff struct {
+	failures int
+	next     time.Time
+}
+
+// statusFetchFailed backs off the status updates of a pod whose status failed to be fetched, so pods
+// that keep failing, e.g. throttled or whose container group is gone, do not use up the ARM calls
+// of every poll.
+func (pt *PodsTracker) statusFetchFailed(ctx context.Context, pod *v1.Pod, now time.Time, err error) {
+	if pt.backoffs == nil {
+		pt.backoffs = make(map[PodIdentifier]statusFetchBackoff)
+	}
+	id := PodIdentifier{namespace: pod.Namespace, name: pod.Name}
+	backoff := pt.backoffs[id]
+	backoff.failures++
+
+	delay := statusFetchBackoffMax
+	if backoff.failures < statusFetchMaxFailures {
+		delay = statusFetchBackoffBase << (backoff.failures - 1)
+		if delay > statusFetchBackoffMax {
+			delay = statusFetchBackoffMax
+		}
+	}
+	backoff.next = now.Add(wait.Jitter(delay, 0.2))
+	pt.backoffs[id] = backoff
+
+	if backoff.failures == statusFetchMaxFailures {
+		log.G(ctx).WithError(err).Warnf("giving up on the status of pod %s/%s after %d failures, it is retried every %s", pod.Namespace, pod.Name, backoff.failures, statusFetchBackoffMax)
+		if pt.eventRecorder != nil {
+			pt.eventRecorder.Event(pod, v1.EventTypeWarning, "StatusUnavailable", errcodes.StatusUnavailable.Message(fmt.Sprintf(
+				"the status of the pod failed to be fetched %d times in a row, it is now only retried every %s: %v", backoff.failures, statusFetchBackoffMax, err)))
+		}
+	}
+}
+
+// restartCount is the restart count ACI last reported for a container, and the restarts it no
+// longer counts.
+type restartCount struct {
+	reported int32
+	offset   int32
+}
+
+// aggregateRestartCounts keeps the restart counts of the containers growing across restarts of
+// their container group, e.g. when ACI repairs its host, which reset the counts ACI reports. The
+// counts already in the pod status are kept when the provider restarted.
+func (pt *PodsTracker) aggregateRestartCounts(pod *v1.Pod, status *v1.PodStatus) {
+	if pt.restarts == nil {
+		pt.restarts = make(map[PodIdentifier]map[string]restartCount)
+	}
+	id := PodIdentifier{namespace: pod.Namespace, name: pod.Name}
+	previous := pt.restarts[id]
+	counts := make(map[string]restartCount, len(status.ContainerStatuses))
+	for i := range status.ContainerStatuses {
+		containerStatus := &status.ContainerStatuses[i]
+		count, ok := previous[containerStatus.Name]
+		switch {
+		case !ok:
+			for _, known := range pod.Status.ContainerStatuses {
+				if known.Name == containerStatus.Name && known.RestartCount > containerStatus.RestartCount {
+					count.offset = known.RestartCount - containerStatus.RestartCount
+				}
+			}
+		case containerStatus.RestartCount < count.reported:
+			// Restarting the container group restarted the container as well.
+			count.offset += count.reported + 1
+		}
+		count.reported = containerStatus.RestartCount
+		containerStatus.RestartCount += count.offset
+		counts[containerStatus.Name] = count
+	}
+	pt.restarts[id] = counts
+}
+
+// setPodNotFound sets the pod to failed, this makes sure if the underlying container implementation
+// is gone that a new pod will be created.
+func setPodNotFound(pod *v1.Pod) {
+	pod.Status.Phase = v1.PodFailed
+	pod.Status.Reason = statusReasonNotFound
+	pod.Status.Message = statusMessageNotFound
+	now := metav1.NewTime(time.Now())
+	for i := range pod.Status.ContainerStatuses {
+		if pod.Status.ContainerStatuses[i].State.Running == nil {
+			continue
+		}
+
+		pod.Status.ContainerStatuses[i].State.Terminated = &v1.ContainerStateTerminated{
+			ExitCode:    containerExitCodeNotFound,
+			Reason:      statusReasonNotFound,
+			Message:     statusMessageNotFound,
+			FinishedAt:  now,
+			StartedAt:   pod.Status.ContainerStatuses[i].State.Running.StartedAt,
+			ContainerID: pod.Status.ContainerStatuses[i].ContainerID,
+		}
+		pod.Status.ContainerStatuses[i].State.Running = nil
+	}
+}
+
+// pastActiveDeadline reports whether the pod has been active longer than its activeDeadlineSeconds,
+// counted from its start time like the kubelet does, or from its creation before it started.
+func pastActiveDeadline(pod *v1.Pod, now time.Time) bool {
+	if pod.Spec.ActiveDeadlineSeconds == nil {
+		return false
+	}
+	start := pod.CreationTimestamp.Time
+	if pod.Status.StartTime != nil && !pod.Status.StartTime.IsZero() {
+		start = pod.Status.StartTime.Time
+	}
+	if start.IsZero() {
+		return false
+	}
+	return now.Sub(start) >= time.Duration(*pod.Spec.ActiveDeadlineSeconds)*time.Second
+}
+
+// setPodDeadlineExceeded fails the pod the way the kubelet does when activeDeadlineSeconds passes,
+// so Jobs count it as failed with reason DeadlineExceeded.
+func setPodDeadlineExceeded(pod *v1.Pod) {
+	pod.Status.Phase = v1.PodFailed
+	pod.Status.Reason = statusReasonDeadlineExceeded
+	pod.Status.Message = statusMessageDeadlineExceeded
+	now := metav1.NewTime(time.Now())
+	for i := range pod.Status.ContainerStatuses {
+		running := pod.Status.ContainerStatuses[i].State.Running
+		if running == nil {
+			continue
+		}
+
+		pod.Status.ContainerStatuses[i].State.Terminated = &v1.ContainerStateTerminated{
+			ExitCode:    containerExitCodeKilled,
+			Reason:      statusReasonDeadlineExceeded,
+			Message:     statusMessageDeadlineExceeded,
+			FinishedAt:  now,
+			StartedAt:   running.StartedAt,
+			ContainerID: pod.Status.ContainerStatuses[i].ContainerID,
+		}
+		pod.Status.ContainerStatuses[i].State.Running = nil
+		pod.Status.ContainerStatuses[i].Ready = false
+	}
+}
+
 func (pt *PodsTracker) shouldSkipPodStatusUpdate(pod *v1.Pod) bool {
 	return pod.Status.Phase == v1.PodSucceeded || // Pod completed its execution
 		pod.Status.Phase == v1.PodFailed ||
 		pod.Status.Reason == podStatusReasonProviderFailed || // Pending phase because of failure
+		pod.Status.Reason == podStatusReasonUnsupported || // Pending phase because the pod is ignored
 		pod.DeletionTimestamp != nil // Terminating
 }
 
@@ -0,0 +1,255 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	testsutil "github.com/virtual-kubelet/azure-aci/pkg/tests"
+	"github.com/virtual-kubelet/node-cli/manager"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/record"
+)
+
+type fakePodsTrackerHandler struct {
+	activePods []PodIdentifier
+	cleanedUp  []PodIdentifier
+	fetchErr   error
+	fetches    int
+}
+
+func (h *fakePodsTrackerHandler) ListActivePods(ctx context.Context) ([]PodIdentifier, error) {
+	return h.activePods, nil
+}
+
+func (h *fakePodsTrackerHandler) FetchPodStatus(ctx context.Context, ns, name string) (*v1.PodStatus, error) {
+	h.fetches++
+	return nil, h.fetchErr
+}
+
+func (h *fakePodsTrackerHandler) CleanupPod(ctx context.Context, ns, name string) error {
+	h.cleanedUp = append(h.cleanedUp, PodIdentifier{namespace: ns, name: name})
+	return nil
+}
+
+func TestPodsTrackerReconcileOnStartup(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	running := testsutil.CreatePodObj("running", "ns")
+	running.Status.Phase = v1.PodRunning
+	running.Status.ContainerStatuses = []v1.ContainerStatus{{Name: "nginx", State: v1.ContainerState{Running: &v1.ContainerStateRunning{}}}}
+	pending := testsutil.CreatePodObj("pending", "ns")
+	pending.Status.Phase = v1.PodPending
+	kept := testsutil.CreatePodObj("kept", "ns")
+	kept.Status.Phase = v1.PodRunning
+
+	podLister := NewMockPodLister(mockCtrl)
+	podLister.EXPECT().List(labels.Everything()).Return([]*v1.Pod{running, pending, kept}, nil).AnyTimes()
+	rm, err := manager.NewResourceManager(
+		podLister,
+		NewMockSecretLister(mockCtrl),
+		NewMockConfigMapLister(mockCtrl),
+		NewMockServiceLister(mockCtrl),
+		NewMockPersistentVolumeClaimLister(mockCtrl),
+		NewMockPersistentVolumeLister(mockCtrl))
+	if err != nil {
+		t.Fatal("Unable to prepare the mocks for resourceManager", err)
+	}
+
+	handler := &fakePodsTrackerHandler{
+		activePods: []PodIdentifier{{namespace: "ns", name: "kept"}, {namespace: "ns", name: "orphan"}},
+	}
+	var updated []*v1.Pod
+	pt := &PodsTracker{
+		rm:                rm,
+		updateCb:          func(pod *v1.Pod) { updated = append(updated, pod) },
+		handler:           handler,
+		orphanGracePeriod: time.Hour,
+	}
+
+	pt.reconcileOnStartup(context.Background())
+	assert.Check(t, is.Equal(1, len(updated)), "only the pod that was started should be failed")
+	assert.Check(t, is.Equal("running", updated[0].Name))
+	assert.Check(t, is.Equal(v1.PodFailed, updated[0].Status.Phase))
+	assert.Check(t, updated[0].Status.ContainerStatuses[0].State.Terminated != nil)
+	assert.Check(t, is.Equal(1, len(pt.orphans)), "the container group without pod should be pending deletion")
+
+	pt.cleanupDanglingPods(context.Background())
+	assert.Check(t, is.Equal(0, len(handler.cleanedUp)), "orphans should be kept during the grace period")
+
+	pt.orphanGracePeriod = 0
+	pt.cleanupDanglingPods(context.Background())
+	assert.Assert(t, is.Equal(1, len(handler.cleanedUp)))
+	assert.Check(t, is.Equal("orphan", handler.cleanedUp[0].name))
+	assert.Check(t, is.Equal(0, len(pt.orphans)))
+}
+
+func TestPodsTrackerActiveDeadline(t *testing.T) {
+	deadline := int64(60)
+	started := metav1.NewTime(time.Now().Add(-2 * time.Minute))
+
+	expired := testsutil.CreatePodObj("expired", "ns")
+	expired.Spec.ActiveDeadlineSeconds = &deadline
+	expired.Status.Phase = v1.PodRunning
+	expired.Status.StartTime = &started
+	expired.Status.ContainerStatuses = []v1.ContainerStatus{{Name: "job", Ready: true, State: v1.ContainerState{Running: &v1.ContainerStateRunning{StartedAt: started}}}}
+
+	handler := &fakePodsTrackerHandler{}
+	pt := &PodsTracker{handler: handler}
+
+	assert.Check(t, pt.processPodUpdates(context.Background(), expired))
+	assert.Assert(t, is.Equal(1, len(handler.cleanedUp)), "the container group should be deleted")
+	assert.Check(t, is.Equal("ns", handler.cleanedUp[0].namespace))
+	assert.Check(t, is.Equal("expired", handler.cleanedUp[0].name))
+	assert.Check(t, is.Equal(v1.PodFailed, expired.Status.Phase))
+	assert.Check(t, is.Equal(statusReasonDeadlineExceeded, expired.Status.Reason))
+	terminated := expired.Status.ContainerStatuses[0].State.Terminated
+	assert.Assert(t, terminated != nil)
+	assert.Check(t, is.Equal(statusReasonDeadlineExceeded, terminated.Reason))
+	assert.Check(t, !expired.Status.ContainerStatuses[0].Ready)
+
+	handler.cleanedUp = nil
+	assert.Check(t, !pt.processPodUpdates(context.Background(), expired), "failed pods should not be updated again")
+	assert.Check(t, is.Len(handler.cleanedUp, 0))
+
+	longer := int64(600)
+	active := testsutil.CreatePodObj("active", "ns")
+	active.Spec.ActiveDeadlineSeconds = &longer
+	active.Status.Phase = v1.PodPending
+	active.Status.StartTime = &started
+	assert.Check(t, !pt.processPodUpdates(context.Background(), active))
+	assert.Check(t, is.Len(handler.cleanedUp, 0), "pods within their deadline should keep running")
+	assert.Check(t, is.Equal(v1.PodPending, active.Status.Phase))
+}
+
+func TestPodsTrackerAggregateRestartCounts(t *testing.T) {
+	pt := &PodsTracker{}
+	pod := testsutil.CreatePodObj("pod", "ns")
+	status := func(counts ...int32) *v1.PodStatus {
+		status := &v1.PodStatus{}
+		for i, count := range counts {
+			status.ContainerStatuses = append(status.ContainerStatuses, v1.ContainerStatus{Name: []string{"app", "sidecar"}[i], RestartCount: count})
+		}
+		return status
+	}
+	aggregate := func(reported *v1.PodStatus) []int32 {
+		pt.aggregateRestartCounts(pod, reported)
+		reported.DeepCopyInto(&pod.Status)
+		var counts []int32
+		for _, containerStatus := range reported.ContainerStatuses {
+			counts = append(counts, containerStatus.RestartCount)
+		}
+		return counts
+	}
+
+	assert.Check(t, is.DeepEqual([]int32{0, 0}, aggregate(status(0, 0))))
+	assert.Check(t, is.DeepEqual([]int32{3, 2}, aggregate(status(3, 2))))
+	assert.Check(t, is.DeepEqual([]int32{4, 3}, aggregate(status(0, 0))), "a container group restart should count once and keep the previous restarts")
+	assert.Check(t, is.DeepEqual([]int32{4, 3}, aggregate(status(0, 0))), "the restart should not be counted again")
+	assert.Check(t, is.DeepEqual([]int32{6, 3}, aggregate(status(2, 0))))
+
+	pt = &PodsTracker{}
+	assert.Check(t, is.DeepEqual([]int32{6, 3}, aggregate(status(0, 0))), "the counts reported before the provider restarted should be kept")
+	assert.Check(t, is.DeepEqual([]int32{7, 3}, aggregate(status(1, 0))))
+}
+
+func TestPodsTrackerStatusFetchBackoff(t *testing.T) {
+	pod := testsutil.CreatePodObj("throttled", "ns")
+	pod.Status.Phase = v1.PodRunning
+	handler := &fakePodsTrackerHandler{fetchErr: errors.New("429 Too Many Requests")}
+	recorder := record.NewFakeRecorder(10)
+	pt := &PodsTracker{handler: handler, eventRecorder: recorder}
+	id := PodIdentifier{namespace: "ns", name: "throttled"}
+
+	assert.Check(t, !pt.processPodUpdates(context.Background(), pod))
+	assert.Check(t, !pt.processPodUpdates(context.Background(), pod))
+	assert.Check(t, is.Equal(1, handler.fetches), "the pod should not be fetched again during its backoff")
+	backoff := pt.backoffs[id]
+	assert.Check(t, is.Equal(1, backoff.failures))
+	delay := time.Until(backoff.next)
+	assert.Check(t, delay > 0 && delay <= statusFetchBackoffBase*6/5, "unexpected first backoff %s", delay)
+
+	for i := 1; i < statusFetchMaxFailures; i++ {
+		backoff := pt.backoffs[id]
+		backoff.next = time.Time{}
+		pt.backoffs[id] = backoff
+		pt.processPodUpdates(context.Background(), pod)
+	}
+	assert.Check(t, is.Equal(statusFetchMaxFailures, handler.fetches))
+	assert.Check(t, time.Until(pt.backoffs[id].next) > statusFetchBackoffMax*4/5, "pods given up on should be retried at the longest backoff")
+	assert.Assert(t, is.Len(recorder.Events, 1))
+	assert.Check(t, strings.HasPrefix(<-recorder.Events, "Warning StatusUnavailable ACIP-028: the status of the pod failed to be fetched 10 times in a row"))
+
+	handler.fetchErr = nil
+	backoff = pt.backoffs[id]
+	backoff.next = time.Time{}
+	pt.backoffs[id] = backoff
+	assert.Check(t, pt.processPodUpdates(context.Background(), pod), "a running pod without container group should be failed")
+	_, ok := pt.backoffs[id]
+	assert.Check(t, !ok, "the backoff should be reset")
+}
+
+// concurrentPodsTrackerHandler records the most status fetches in flight at once.
+type concurrentPodsTrackerHandler struct {
+	fakePodsTrackerHandler
+	mu          sync.Mutex
+	inFlight    int
+	maxInFlight int
+}
+
+func (h *concurrentPodsTrackerHandler) FetchPodStatus(ctx context.Context, ns, name string) (*v1.PodStatus, error) {
+	h.mu.Lock()
+	h.inFlight++
+	if h.inFlight > h.maxInFlight {
+		h.maxInFlight = h.inFlight
+	}
+	h.mu.Unlock()
+	time.Sleep(10 * time.Millisecond)
+	h.mu.Lock()
+	h.inFlight--
+	h.mu.Unlock()
+	return &v1.PodStatus{Phase: v1.PodRunning, Message: name}, nil
+}
+
+func TestPodsTrackerParallelStatusUpdates(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	var pods []*v1.Pod
+	for i := 0; i < 12; i++ {
+		pods = append(pods, testsutil.CreatePodObj(fmt.Sprintf("pod-%d", i), "ns"))
+	}
+	podLister := NewMockPodLister(mockCtrl)
+	podLister.EXPECT().List(labels.Everything()).Return(pods, nil).AnyTimes()
+	rm, err := manager.NewResourceManager(podLister, nil, nil, newServiceLister(), nil, nil)
+	if err != nil {
+		t.Fatal("Unable to prepare the mocks for resourceManager", err)
+	}
+
+	handler := &concurrentPodsTrackerHandler{}
+	updated := map[string]string{}
+	pt := &PodsTracker{
+		rm:                      rm,
+		handler:                 handler,
+		updateCb:                func(pod *v1.Pod) { updated[pod.Name] = pod.Status.Message },
+		statusUpdateParallelism: 4,
+	}
+	pt.updatePodsLoop(context.Background())
+
+	assert.Check(t, handler.maxInFlight > 1, "statuses should be fetched concurrently")
+	assert.Check(t, handler.maxInFlight <= 4, "fetches in flight should be bounded by the parallelism, got %d", handler.maxInFlight)
+	assert.Assert(t, is.Len(updated, len(pods)))
+	for _, pod := range pods {
+		assert.Check(t, is.Equal(pod.Name, updated[pod.Name]), "pod %s should get its own status", pod.Name)
+	}
+}
@@ -0,0 +1,101 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/virtual-kubelet/azure-aci/pkg/metrics"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	"github.com/virtual-kubelet/virtual-kubelet/trace"
+)
+
+// portForwardDialTimeout bounds the connection to the port of a container group.
+const portForwardDialTimeout = 10 * time.Second
+
+// PortForward forwards a connection to a port of the pod, like kubectl port-forward. ACI has no port
+// forwarding API, the virtual node relays the connection to the IP address of the container group
+// instead, which it reaches from the virtual network of the cluster. ACI only opens the container
+// ports of the pod on that address.
+func (p *ACIProvider) PortForward(ctx context.Context, namespace, name string, port int32, stream io.ReadWriteCloser) (err error) {
+	ctx, span := trace.StartSpan(ctx, "aci.PortForward")
+	defer span.End()
+	ctx = addAzureAttributes(ctx, span, p)
+	defer func() {
+		metrics.RecordInteractiveRequest(namespace, metrics.OperationPortForward, err)
+	}()
+	defer stream.Close()
+
+	cg, err := p.azClientsAPIs.GetContainerGroupInfo(ctx, p.resourceGroup, namespace, name, p.nodeName)
+	if err != nil {
+		return err
+	}
+	if cg.ContainerGroupProperties == nil || cg.IPAddress == nil || cg.IPAddress.IP == nil || *cg.IPAddress.IP == "" {
+		return errdefs.InvalidInputf("pod %s/%s has no IP address to forward port %d to, ACI only assigns one to pods with container ports", namespace, name, port)
+	}
+	exposed := false
+	if cg.IPAddress.Ports != nil {
+		for _, exposedPort := range *cg.IPAddress.Ports {
+			if exposedPort.Port != nil && *exposedPort.Port == port {
+				exposed = true
+			}
+		}
+	}
+	if !exposed {
+		return errdefs.InvalidInputf("port %d of pod %s/%s is not a container port, ACI does not expose it", port, namespace, name)
+	}
+
+	address := net.JoinHostPort(*cg.IPAddress.IP, strconv.Itoa(int(port)))
+	dial := p.portForwardDial
+	if dial == nil {
+		dial = (&net.Dialer{Timeout: portForwardDialTimeout}).DialContext
+	}
+	conn, err := dial(ctx, "tcp", address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	log.G(ctx).Debugf("forwarding a connection to %s for pod %s/%s", address, namespace, name)
+
+	// Closing both ends once either side is done unblocks the other copy.
+	var once sync.Once
+	closeBoth := func() {
+		once.Do(func() {
+			conn.Close()
+			stream.Close()
+		})
+	}
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-ctx.Done():
+			closeBoth()
+		case <-finished:
+		}
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = io.Copy(conn, stream)
+		if tcp, ok := conn.(*net.TCPConn); ok {
+			_ = tcp.CloseWrite()
+			return
+		}
+		closeBoth()
+	}()
+	n, _ := io.Copy(stream, conn)
+	metrics.AddInteractiveBytes(namespace, metrics.OperationPortForward, n)
+	closeBoth()
+	<-done
+	return nil
+}
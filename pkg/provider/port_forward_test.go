@@ -0,0 +1,68 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	testsutil "github.com/virtual-kubelet/azure-aci/pkg/tests"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestPortForward(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = io.Copy(conn, conn)
+	}()
+
+	ip := "10.0.0.4"
+	exposed := int32(8080)
+	aciMocks := createNewACIMock()
+	aciMocks.MockGetContainerGroupInfo = func(ctx context.Context, resourceGroup, namespace, name, nodeName string) (*azaci.ContainerGroup, error) {
+		cg := testsutil.CreateContainerGroupObj(name, namespace, "Running", &[]azaci.Container{}, "Succeeded")
+		cg.IPAddress = &azaci.IPAddress{IP: &ip, Ports: &[]azaci.Port{{Port: &exposed}}}
+		return cg, nil
+	}
+	var dialed string
+	p := &ACIProvider{
+		azClientsAPIs: aciMocks,
+		portForwardDial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			dialed = address
+			return (&net.Dialer{}).DialContext(ctx, network, listener.Addr().String())
+		},
+	}
+
+	client, stream := net.Pipe()
+	errs := make(chan error, 1)
+	go func() {
+		errs <- p.PortForward(context.Background(), "ns", "web", 8080, stream)
+	}()
+	_, err = client.Write([]byte("ping"))
+	assert.NilError(t, err)
+	buf := make([]byte, 4)
+	_, err = io.ReadFull(client, buf)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("ping", string(buf)))
+	client.Close()
+	assert.NilError(t, <-errs)
+	assert.Check(t, is.Equal("10.0.0.4:8080", dialed))
+
+	_, stream = net.Pipe()
+	err = p.PortForward(context.Background(), "ns", "web", 22, stream)
+	assert.Check(t, errdefs.IsInvalidInput(err), "ports ACI does not expose should be rejected: %v", err)
+}
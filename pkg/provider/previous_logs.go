@@ -0,0 +1,108 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"github.com/virtual-kubelet/virtual-kubelet/node/api"
+)
+
+// getPreviousContainerLogs returns the logs of the previous instance of a restarted container.
+// ACI has no API for them, the timestamped logs it returns are cut at the start of the current
+// instance instead.
+func (p *ACIProvider) getPreviousContainerLogs(ctx context.Context, cg *azaci.ContainerGroup, containerName string, opts api.ContainerLogOpts) (string, error) {
+	start, ok := currentInstanceStart(cg, containerName)
+	if !ok {
+		return "", errdefs.NotFoundf("previous terminated container %s in pod %s not found", containerName, *cg.Name)
+	}
+
+	allOpts := opts
+	allOpts.Tail = 0
+	content, err := p.azClientsAPIs.ListLogs(ctx, p.resourceGroup, *cg.Name, containerName, allOpts)
+	if err != nil {
+		return "", err
+	}
+	logs := ""
+	if content != nil {
+		logs = p.normalizeLogLineEndings(*content)
+	}
+
+	previous := logLinesBefore(logs, start)
+	if previous == "" {
+		return "", errdefs.NotFoundf("ACI retains no logs of the previous terminated container %s in pod %s", containerName, *cg.Name)
+	}
+	return tailLogLines(previous, opts.Tail), nil
+}
+
+// currentInstanceStart returns when the current instance of a container that was restarted started.
+func currentInstanceStart(cg *azaci.ContainerGroup, containerName string) (time.Time, bool) {
+	if cg.ContainerGroupProperties == nil || cg.Containers == nil {
+		return time.Time{}, false
+	}
+	for _, container := range *cg.Containers {
+		if container.Name == nil || *container.Name != containerName {
+			continue
+		}
+		if container.ContainerProperties == nil || container.InstanceView == nil {
+			return time.Time{}, false
+		}
+		view := container.InstanceView
+		if view.RestartCount == nil || *view.RestartCount == 0 || view.PreviousState == nil ||
+			view.CurrentState == nil || view.CurrentState.StartTime == nil {
+			return time.Time{}, false
+		}
+		return view.CurrentState.StartTime.Time, true
+	}
+	return time.Time{}, false
+}
+
+// logLinesBefore returns the log lines written before the timestamp ACI prefixes them with. Lines
+// without a timestamp are continuations of the previous line.
+func logLinesBefore(logs string, before time.Time) string {
+	var b strings.Builder
+	include := true
+	for _, line := range strings.SplitAfter(logs, "\n") {
+		if line == "" {
+			continue
+		}
+		if timestamp, ok := logLineTimestamp(line); ok {
+			include = timestamp.Before(before)
+		}
+		if include {
+			b.WriteString(line)
+		}
+	}
+	return b.String()
+}
+
+// tailLogLines returns the last lines of the logs, all of them when tail is 0.
+func tailLogLines(logs string, tail int) string {
+	if tail <= 0 {
+		return logs
+	}
+	lines := strings.SplitAfter(logs, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) <= tail {
+		return logs
+	}
+	return strings.Join(lines[len(lines)-tail:], "")
+}
+
+// logLineTimestamp returns the timestamp ACI prefixes a log line with.
+func logLineTimestamp(line string) (time.Time, bool) {
+	prefix := strings.TrimRight(line, "\r\n")
+	if i := strings.IndexByte(prefix, ' '); i >= 0 {
+		prefix = prefix[:i]
+	}
+	timestamp, err := time.Parse(time.RFC3339Nano, prefix)
+	return timestamp, err == nil
+}
@@ -0,0 +1,66 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	testsutil "github.com/virtual-kubelet/azure-aci/pkg/tests"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"github.com/virtual-kubelet/virtual-kubelet/node/api"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestGetPreviousContainerLogs(t *testing.T) {
+	restarted, _ := time.Parse(time.RFC3339, "2022-06-01T10:00:03Z")
+	logs := "2022-06-01T10:00:01Z starting\n" +
+		"2022-06-01T10:00:02Z panic: boom\n" +
+		"goroutine 1 [running]:\n" +
+		"2022-06-01T10:00:04Z starting\n"
+
+	restarts := int32(0)
+	aciMocks := createNewACIMock()
+	aciMocks.MockGetContainerGroupInfo = func(ctx context.Context, resourceGroup, namespace, name, nodeName string) (*azaci.ContainerGroup, error) {
+		containers := testsutil.CreateACIContainersListObj("Running", "Terminated", restarted, restarted, false, false, false)
+		(*containers)[0].InstanceView.RestartCount = &restarts
+		return testsutil.CreateContainerGroupObj(name, namespace, "Running", containers, "Succeeded"), nil
+	}
+	aciMocks.MockListLogs = func(ctx context.Context, resourceGroup, cgName, containerName string, opts api.ContainerLogOpts) (*string, error) {
+		assert.Check(t, is.Equal(0, opts.Tail), "the previous instance should be cut from all the logs")
+		return &logs, nil
+	}
+	provider, err := createTestProvider(aciMocks, nil)
+	if err != nil {
+		t.Fatal("failed to create the test provider", err)
+	}
+
+	_, err = provider.GetContainerLogs(context.Background(), "ns", "web", testsutil.TestContainerName, api.ContainerLogOpts{Previous: true})
+	assert.Check(t, errdefs.IsNotFound(err), "containers that were not restarted have no previous instance: %v", err)
+
+	restarts = 1
+	stream, err := provider.GetContainerLogs(context.Background(), "ns", "web", testsutil.TestContainerName, api.ContainerLogOpts{Previous: true})
+	assert.NilError(t, err)
+	content, err := io.ReadAll(stream)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("2022-06-01T10:00:01Z starting\n2022-06-01T10:00:02Z panic: boom\ngoroutine 1 [running]:\n", string(content)))
+
+	stream, err = provider.GetContainerLogs(context.Background(), "ns", "web", testsutil.TestContainerName, api.ContainerLogOpts{Previous: true, Tail: 2})
+	assert.NilError(t, err)
+	content, err = io.ReadAll(stream)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("2022-06-01T10:00:02Z panic: boom\ngoroutine 1 [running]:\n", string(content)))
+}
+
+func TestTailLogLines(t *testing.T) {
+	assert.Check(t, is.Equal("a\nb\n", tailLogLines("a\nb\n", 0)))
+	assert.Check(t, is.Equal("a\nb\n", tailLogLines("a\nb\n", 3)))
+	assert.Check(t, is.Equal("b\n", tailLogLines("a\nb\n", 1)))
+	assert.Check(t, is.Equal("b", tailLogLines("a\nb", 1)))
+}
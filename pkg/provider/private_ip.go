@@ -0,0 +1,138 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"encoding/binary"
+	"net"
+	"strconv"
+	"sync"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// privateIPAnnotation requests a specific IP address of the delegated subnet for the pod.
+	privateIPAnnotation = "virtualkubelet.io/private-ip"
+	// pinPrivateIPAnnotation keeps the IP address of the pod when it is recreated with the same
+	// name, e.g. a StatefulSet pod, without choosing the address upfront.
+	pinPrivateIPAnnotation = "virtualkubelet.io/pin-private-ip"
+)
+
+// pinnedIPs remembers the IP addresses of the deleted pods that pinned them, by namespace and name.
+// They are kept in memory, a pod recreated after a restart of the provider gets a new address.
+type pinnedIPs struct {
+	mu  sync.Mutex
+	ips map[PodIdentifier]string
+}
+
+func (p *pinnedIPs) get(namespace, name string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.ips[PodIdentifier{namespace: namespace, name: name}]
+}
+
+func (p *pinnedIPs) set(namespace, name, ip string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.ips == nil {
+		p.ips = make(map[PodIdentifier]string)
+	}
+	p.ips[PodIdentifier{namespace: namespace, name: name}] = ip
+}
+
+// validateSubnetIP checks that the address can be assigned in the subnet. Azure reserves the first
+// four and the last address of every subnet.
+func validateSubnetIP(ip, subnetCIDR string) error {
+	addr := net.ParseIP(ip).To4()
+	if addr == nil {
+		return errdefs.InvalidInputf("annotation %s has invalid value %q, an IPv4 address is required", privateIPAnnotation, ip)
+	}
+	if subnetCIDR == "" {
+		return nil
+	}
+	_, subnet, err := net.ParseCIDR(subnetCIDR)
+	if err != nil || subnet.IP.To4() == nil {
+		return nil
+	}
+	if !subnet.Contains(addr) {
+		return errdefs.InvalidInputf("annotation %s has value %s outside of the subnet %s", privateIPAnnotation, ip, subnetCIDR)
+	}
+	ones, bits := subnet.Mask.Size()
+	offset := binary.BigEndian.Uint32(addr) - binary.BigEndian.Uint32(subnet.IP.To4())
+	if offset < 4 || uint64(offset) == (uint64(1)<<uint(bits-ones))-1 {
+		return errdefs.InvalidInputf("annotation %s has value %s, which Azure reserves in the subnet %s", privateIPAnnotation, ip, subnetCIDR)
+	}
+	return nil
+}
+
+// wantedPrivateIP returns the IP address requested by the pod, or the address it had before it was
+// recreated when it pins its address. It reports whether the pod wants a private IP address at all,
+// the address is empty when ACI chooses it.
+func (p *ACIProvider) wantedPrivateIP(pod *v1.Pod) (string, bool, error) {
+	if ip, ok := pod.Annotations[privateIPAnnotation]; ok {
+		return ip, true, nil
+	}
+	value, ok := pod.Annotations[pinPrivateIPAnnotation]
+	if !ok {
+		return "", false, nil
+	}
+	pin, err := strconv.ParseBool(value)
+	if err != nil {
+		return "", false, errdefs.InvalidInputf("annotation %s has invalid value %q, true or false is required", pinPrivateIPAnnotation, value)
+	}
+	if !pin {
+		return "", false, nil
+	}
+	return p.pinnedIPs.get(pod.Namespace, pod.Name), true, nil
+}
+
+// getPrivateIP returns the IP address wanted by the pod after checking it belongs to the subnet of
+// the pod.
+func (p *ACIProvider) getPrivateIP(pod *v1.Pod, subnet *delegatedSubnet) (string, error) {
+	ip, wanted, err := p.wantedPrivateIP(pod)
+	if err != nil || !wanted {
+		return "", err
+	}
+	if subnet == nil {
+		return "", errdefs.InvalidInput("private IP addresses are only available to pods in the virtual network, the virtual node has no subnet configured")
+	}
+	if ip == "" {
+		return "", nil
+	}
+	if err := validateSubnetIP(ip, subnet.cidr); err != nil {
+		return "", err
+	}
+	return ip, nil
+}
+
+// setPrivateIP assigns the IP address requested by the pod to its container group in the subnet.
+// ACI only assigns an IP address that exposes ports.
+func (p *ACIProvider) setPrivateIP(pod *v1.Pod, subnet *delegatedSubnet, cgProperties *azaci.ContainerGroupProperties, ports []azaci.Port) error {
+	ip, err := p.getPrivateIP(pod, subnet)
+	if err != nil || ip == "" {
+		return err
+	}
+	if len(ports) == 0 {
+		return errdefs.InvalidInputf("pod %s requests the private IP address %s, which ACI only assigns to pods with container ports", pod.Name, ip)
+	}
+	cgProperties.IPAddress = &azaci.IPAddress{
+		IP:    &ip,
+		Ports: &ports,
+		Type:  azaci.ContainerGroupIPAddressTypePrivate,
+	}
+	return nil
+}
+
+// rememberPinnedIP keeps the IP address of a deleted pod that pins it, for the pod recreated with
+// the same name.
+func (p *ACIProvider) rememberPinnedIP(pod *v1.Pod) {
+	if pin, _ := strconv.ParseBool(pod.Annotations[pinPrivateIPAnnotation]); !pin || pod.Status.PodIP == "" {
+		return
+	}
+	p.pinnedIPs.set(pod.Namespace, pod.Name, pod.Status.PodIP)
+}
@@ -0,0 +1,60 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"testing"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	testsutil "github.com/virtual-kubelet/azure-aci/pkg/tests"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestValidateSubnetIP(t *testing.T) {
+	assert.NilError(t, validateSubnetIP("10.240.0.10", "10.240.0.0/24"))
+	assert.NilError(t, validateSubnetIP("10.240.0.254", "10.240.0.0/24"))
+	assert.NilError(t, validateSubnetIP("10.240.0.10", ""), "addresses cannot be checked without the subnet CIDR")
+
+	for _, ip := range []string{"10.240.1.10", "10.240.0.3", "10.240.0.255", "fd00::10", "not-an-ip"} {
+		assert.Check(t, errdefs.IsInvalidInput(validateSubnetIP(ip, "10.240.0.0/24")), "expected %s to be rejected", ip)
+	}
+}
+
+func TestSetPrivateIP(t *testing.T) {
+	p := &ACIProvider{subnetName: "aci", subnetCIDR: "10.240.0.0/24"}
+	subnet := p.nodeSubnet()
+	port := int32(80)
+	ports := []azaci.Port{{Port: &port, Protocol: azaci.ContainerGroupNetworkProtocolTCP}}
+
+	pod := testsutil.CreatePodObj("web-0", "ns")
+	pod.Annotations = map[string]string{privateIPAnnotation: "10.240.0.10"}
+	cgProperties := &azaci.ContainerGroupProperties{}
+	assert.NilError(t, p.setPrivateIP(pod, subnet, cgProperties, ports))
+	assert.Assert(t, cgProperties.IPAddress != nil)
+	assert.Check(t, is.Equal("10.240.0.10", *cgProperties.IPAddress.IP))
+	assert.Check(t, is.Equal(azaci.ContainerGroupIPAddressTypePrivate, cgProperties.IPAddress.Type))
+
+	assert.Check(t, errdefs.IsInvalidInput(p.setPrivateIP(pod, subnet, &azaci.ContainerGroupProperties{}, nil)), "an address without ports should be rejected")
+	assert.Check(t, errdefs.IsInvalidInput((&ACIProvider{}).setPrivateIP(pod, nil, &azaci.ContainerGroupProperties{}, ports)), "an address without subnet should be rejected")
+
+	pinned := testsutil.CreatePodObj("web-1", "ns")
+	pinned.Annotations = map[string]string{pinPrivateIPAnnotation: "true"}
+	cgProperties = &azaci.ContainerGroupProperties{}
+	assert.NilError(t, p.setPrivateIP(pinned, subnet, cgProperties, ports))
+	assert.Check(t, cgProperties.IPAddress == nil, "ACI should choose the address of a new pod")
+
+	pinned.Status.PodIP = "10.240.0.42"
+	p.rememberPinnedIP(pinned)
+	recreated := testsutil.CreatePodObj("web-1", "ns")
+	recreated.Annotations = map[string]string{pinPrivateIPAnnotation: "true"}
+	assert.NilError(t, p.setPrivateIP(recreated, subnet, cgProperties, ports))
+	assert.Assert(t, cgProperties.IPAddress != nil)
+	assert.Check(t, is.Equal("10.240.0.42", *cgProperties.IPAddress.IP), "the recreated pod should get its address back")
+
+	recreated.Annotations[pinPrivateIPAnnotation] = "sometimes"
+	assert.Check(t, errdefs.IsInvalidInput(p.setPrivateIP(recreated, subnet, &azaci.ContainerGroupProperties{}, ports)))
+}
@@ -0,0 +1,162 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"fmt"
+	"strings"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	"github.com/virtual-kubelet/azure-aci/pkg/errcodes"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// podConditionContainerGroupProvisioned reports the ARM provisioning state of the container group
+	// of the pod.
+	podConditionContainerGroupProvisioned v1.PodConditionType = "virtualkubelet.io/ContainerGroupProvisioned"
+
+	provisioningReasonImagePullFailed = "ImagePullFailed"
+	provisioningReasonFailed          = "ProvisioningFailed"
+)
+
+// provisioningEventReasons are the reasons of the pod events published when the provisioning state
+// of its container group changes.
+var provisioningEventReasons = map[string]string{
+	"Pending":   "ContainerGroupPending",
+	"Creating":  "ContainerGroupCreating",
+	"Succeeded": "ContainerGroupProvisioned",
+	"Failed":    "ContainerGroupProvisioningFailed",
+	"Repairing": "ContainerGroupRepairing",
+}
+
+// provisioningState describes why the container group is not provisioned: its ARM provisioning
+// state, or ImagePullFailed when a container image cannot be pulled, to tell it apart from capacity
+// or quota failures.
+func provisioningState(cg *azaci.ContainerGroup) (state, reason, message string) {
+	if cg.ContainerGroupProperties == nil || cg.ProvisioningState == nil {
+		return "", "", ""
+	}
+	state = *cg.ProvisioningState
+	if state == "Succeeded" {
+		return state, "", ""
+	}
+
+	if container, pullMessage, ok := imagePullFailure(cg); ok {
+		return state, provisioningReasonImagePullFailed, errcodes.ImagePullFailed.Message(fmt.Sprintf("container %s: %s", container, pullMessage))
+	}
+	reason = state
+	if state == "Failed" {
+		reason = provisioningReasonFailed
+	}
+	message = fmt.Sprintf("container group provisioning state is %s", state)
+	if event, ok := lastWarningEvent(cg); ok && event.Message != nil {
+		message += ": " + *event.Message
+	}
+	if state == "Failed" {
+		message = errcodes.ProvisioningFailed.Message(message)
+	}
+	return state, reason, message
+}
+
+// imagePullFailure returns the last image pull failure reported by the containers.
+func imagePullFailure(cg *azaci.ContainerGroup) (string, string, bool) {
+	if cg.Containers == nil {
+		return "", "", false
+	}
+	for _, container := range *cg.Containers {
+		if container.Name == nil || container.ContainerProperties == nil || container.InstanceView == nil || container.InstanceView.Events == nil {
+			continue
+		}
+		for _, event := range *container.InstanceView.Events {
+			if event.Message == nil || event.Type == nil || *event.Type != v1.EventTypeWarning {
+				continue
+			}
+			message := strings.ToLower(*event.Message)
+			if strings.Contains(message, "pull image") || strings.Contains(message, "pulling image") {
+				return *container.Name, *event.Message, true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// lastWarningEvent returns the latest warning event of the container group.
+func lastWarningEvent(cg *azaci.ContainerGroup) (azaci.Event, bool) {
+	var last azaci.Event
+	found := false
+	if cg.InstanceView == nil || cg.InstanceView.Events == nil {
+		return last, false
+	}
+	for _, event := range *cg.InstanceView.Events {
+		if event.Type == nil || *event.Type != v1.EventTypeWarning {
+			continue
+		}
+		if !found || aciEventTime(event).After(aciEventTime(last)) {
+			last, found = event, true
+		}
+	}
+	return last, found
+}
+
+// getProvisionedCondition reports whether the container group of the pod is provisioned, with the
+// reason it is not otherwise.
+func getProvisionedCondition(cg *azaci.ContainerGroup, creationTime metav1.Time) v1.PodCondition {
+	condition := v1.PodCondition{Type: podConditionContainerGroupProvisioned, Status: v1.ConditionTrue, LastTransitionTime: creationTime}
+	state, reason, message := provisioningState(cg)
+	if state == "" || state == "Succeeded" {
+		return condition
+	}
+	condition.Status = v1.ConditionFalse
+	condition.Reason = reason
+	condition.Message = message
+	if event, ok := lastWarningEvent(cg); ok && aciEventTime(event).After(creationTime.Time) {
+		condition.LastTransitionTime = metav1.NewTime(aciEventTime(event))
+	}
+	return condition
+}
+
+// publishProvisioningState publishes an event on the pod when the provisioning state of its
+// container group changed. Provisioned container groups seen for the first time, e.g. after a
+// restart of the provider, are not published again.
+func (p *ACIProvider) publishProvisioningState(cg *azaci.ContainerGroup) {
+	if p.aciEvents == nil || p.eventRecorder == nil || cg.Tags == nil {
+		return
+	}
+	state, reason, message := provisioningState(cg)
+	eventReason, ok := provisioningEventReasons[state]
+	if !ok {
+		return
+	}
+	tag := func(name string) string {
+		if value := cg.Tags[name]; value != nil {
+			return *value
+		}
+		return ""
+	}
+	id := PodIdentifier{namespace: tag("Namespace"), name: tag("PodName")}
+	if id.namespace == "" || id.name == "" {
+		return
+	}
+	previous, changed := p.aciEvents.transition(id, state)
+	if !changed || (previous == "" && state == "Succeeded") {
+		return
+	}
+
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: id.namespace, Name: id.name, UID: types.UID(tag("UID"))}}
+	eventType := v1.EventTypeNormal
+	if state == "Failed" || state == "Repairing" || reason == provisioningReasonImagePullFailed {
+		eventType = v1.EventTypeWarning
+	}
+	if message == "" {
+		message = fmt.Sprintf("container group provisioning state is %s", state)
+	}
+	if previous != "" {
+		message = fmt.Sprintf("%s, was %s", message, previous)
+	}
+	p.recordEvent(pod, eventType, eventReason, "%s", message)
+}
@@ -0,0 +1,97 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"testing"
+	"time"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	testsutil "github.com/virtual-kubelet/azure-aci/pkg/tests"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestGetProvisionedCondition(t *testing.T) {
+	created := metav1.NewTime(time.Now().Add(-time.Minute).Truncate(time.Second))
+	failedAt := created.Add(30 * time.Second)
+
+	cases := []struct {
+		description       string
+		provisioningState string
+		cgEvents          []azaci.Event
+		containerEvents   []azaci.Event
+		expectedStatus    v1.ConditionStatus
+		expectedReason    string
+		expectedTime      time.Time
+	}{
+		{
+			description:       "provisioned",
+			provisioningState: "Succeeded",
+			expectedStatus:    v1.ConditionTrue,
+			expectedTime:      created.Time,
+		},
+		{
+			description:       "creating",
+			provisioningState: "Creating",
+			expectedStatus:    v1.ConditionFalse,
+			expectedReason:    "Creating",
+			expectedTime:      created.Time,
+		},
+		{
+			description:       "image pull failure",
+			provisioningState: "Failed",
+			containerEvents:   []azaci.Event{aciEvent("Failed", "Warning", "Failed to pull image \"nginx:missing\"", 1, failedAt)},
+			expectedStatus:    v1.ConditionFalse,
+			expectedReason:    provisioningReasonImagePullFailed,
+			expectedTime:      created.Time,
+		},
+		{
+			description:       "capacity failure",
+			provisioningState: "Failed",
+			cgEvents:          []azaci.Event{aciEvent("ServiceUnavailable", "Warning", "the requested resource is not available in the location", 1, failedAt)},
+			expectedStatus:    v1.ConditionFalse,
+			expectedReason:    provisioningReasonFailed,
+			expectedTime:      failedAt,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			containers := testsutil.CreateACIContainersListObj("Waiting", "Waiting", created.Time, created.Time, false, false, false)
+			(*containers)[0].InstanceView.Events = &tc.containerEvents
+			cg := testsutil.CreateContainerGroupObj("web", "ns", "Pending", containers, tc.provisioningState)
+			cg.InstanceView.Events = &tc.cgEvents
+
+			condition := getProvisionedCondition(cg, created)
+			assert.Check(t, is.Equal(podConditionContainerGroupProvisioned, condition.Type))
+			assert.Check(t, is.Equal(tc.expectedStatus, condition.Status))
+			assert.Check(t, is.Equal(tc.expectedReason, condition.Reason))
+			assert.Check(t, condition.LastTransitionTime.Time.Equal(tc.expectedTime), "unexpected transition time %s", condition.LastTransitionTime)
+		})
+	}
+}
+
+func TestPublishProvisioningState(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	p := &ACIProvider{eventRecorder: recorder, aciEvents: newACIEvents(time.Now())}
+	containers := testsutil.CreateACIContainersListObj("Waiting", "Waiting", time.Now(), time.Now(), false, false, false)
+
+	p.publishProvisioningState(testsutil.CreateContainerGroupObj("old", "ns", "Running", containers, "Succeeded"))
+	assert.Check(t, is.Len(recorder.Events, 0), "provisioned container groups seen first should not be published")
+
+	p.publishProvisioningState(testsutil.CreateContainerGroupObj("web", "ns", "Pending", containers, "Creating"))
+	assert.Assert(t, is.Len(recorder.Events, 1))
+	assert.Check(t, is.Equal("Normal ContainerGroupCreating container group provisioning state is Creating", <-recorder.Events))
+
+	p.publishProvisioningState(testsutil.CreateContainerGroupObj("web", "ns", "Pending", containers, "Creating"))
+	assert.Check(t, is.Len(recorder.Events, 0), "unchanged states should not be published again")
+
+	p.publishProvisioningState(testsutil.CreateContainerGroupObj("web", "ns", "Failed", containers, "Failed"))
+	assert.Assert(t, is.Len(recorder.Events, 1))
+	assert.Check(t, is.Equal("Warning ContainerGroupProvisioningFailed ACIP-026: container group provisioning state is Failed, was Creating", <-recorder.Events))
+}
@@ -0,0 +1,47 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"strconv"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	v1 "k8s.io/api/core/v1"
+)
+
+// publicIPAnnotation requests a public IP address for the container ports of the pod, or opts out of
+// it on a virtual node without subnet.
+const publicIPAnnotation = "virtualkubelet.io/public-ip"
+
+// exposePublicIP reports whether the container group of the pod gets a public IP address. Pods of a
+// virtual node without subnet get one for their container ports. Pods of a virtual node with a
+// subnet only get one on request when the node allows it, ACI then deploys their container group
+// outside of the virtual network, since it does not assign public IP addresses in a subnet.
+func (p *ACIProvider) exposePublicIP(pod *v1.Pod, ports []azaci.Port) (bool, error) {
+	public := p.subnetName == ""
+	if value, ok := pod.Annotations[publicIPAnnotation]; ok {
+		var err error
+		if public, err = strconv.ParseBool(value); err != nil {
+			return false, errdefs.InvalidInputf("annotation %s has invalid value %q, true or false is required", publicIPAnnotation, value)
+		}
+	}
+	if p.subnetName == "" || !public {
+		return public && len(ports) > 0, nil
+	}
+
+	if !p.allowVNetPublicIPs {
+		return false, errdefs.InvalidInputf("pod %s requests a public IP address, which the virtual node does not allow for pods of its virtual network", pod.Name)
+	}
+	if len(ports) == 0 {
+		return false, errdefs.InvalidInputf("pod %s requests a public IP address, which ACI only assigns to pods with container ports", pod.Name)
+	}
+	for _, annotation := range []string{subnetAnnotation, privateIPAnnotation, pinPrivateIPAnnotation} {
+		if _, ok := pod.Annotations[annotation]; ok {
+			return false, errdefs.InvalidInputf("pod %s requests a public IP address, which cannot be combined with annotation %s", pod.Name, annotation)
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,53 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"testing"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	testsutil "github.com/virtual-kubelet/azure-aci/pkg/tests"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestExposePublicIP(t *testing.T) {
+	port := int32(80)
+	ports := []azaci.Port{{Port: &port, Protocol: azaci.ContainerGroupNetworkProtocolTCP}}
+
+	cases := []struct {
+		name        string
+		provider    *ACIProvider
+		annotations map[string]string
+		ports       []azaci.Port
+		want        bool
+		wantErr     bool
+	}{
+		{"node without subnet", &ACIProvider{}, nil, ports, true, false},
+		{"node without subnet and ports", &ACIProvider{}, nil, nil, false, false},
+		{"opted out", &ACIProvider{}, map[string]string{publicIPAnnotation: "false"}, ports, false, false},
+		{"vnet pod", &ACIProvider{subnetName: "aci"}, nil, ports, false, false},
+		{"requested", &ACIProvider{subnetName: "aci", allowVNetPublicIPs: true}, map[string]string{publicIPAnnotation: "true"}, ports, true, false},
+		{"not allowed", &ACIProvider{subnetName: "aci"}, map[string]string{publicIPAnnotation: "true"}, ports, false, true},
+		{"requested without ports", &ACIProvider{subnetName: "aci", allowVNetPublicIPs: true}, map[string]string{publicIPAnnotation: "true"}, nil, false, true},
+		{"requested with private IP", &ACIProvider{subnetName: "aci", allowVNetPublicIPs: true}, map[string]string{publicIPAnnotation: "true", privateIPAnnotation: "10.240.0.10"}, ports, false, true},
+		{"requested with subnet", &ACIProvider{subnetName: "aci", allowVNetPublicIPs: true}, map[string]string{publicIPAnnotation: "true", subnetAnnotation: "team-a"}, ports, false, true},
+		{"invalid value", &ACIProvider{}, map[string]string{publicIPAnnotation: "yes please"}, ports, false, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			pod := testsutil.CreatePodObj("pod", "ns")
+			pod.Annotations = tc.annotations
+			public, err := tc.provider.exposePublicIP(pod, tc.ports)
+			if tc.wantErr {
+				assert.Check(t, errdefs.IsInvalidInput(err), "expected an invalid input error, got %v", err)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Check(t, is.Equal(tc.want, public))
+		})
+	}
+}
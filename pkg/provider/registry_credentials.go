@@ -0,0 +1,88 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"fmt"
+	"sync"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+type cachedRegistryCredentials struct {
+	uid             types.UID
+	resourceVersion string
+	credentials     []azaci.ImageRegistryCredential
+}
+
+// registryCredentialCache keeps the parsed registry credentials of image pull secrets so
+// pods sharing a pull secret do not re-parse it. An entry is only reused while the secret
+// UID and resourceVersion match, so any update or re-creation of the secret invalidates it.
+type registryCredentialCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedRegistryCredentials
+}
+
+func newRegistryCredentialCache() *registryCredentialCache {
+	return &registryCredentialCache{
+		entries: make(map[string]cachedRegistryCredentials),
+	}
+}
+
+// get returns the registry credentials stored in the secret, parsing it only when the
+// cached entry is missing or stale.
+func (c *registryCredentialCache) get(secret *v1.Secret) ([]azaci.ImageRegistryCredential, error) {
+	key := secret.Namespace + "/" + secret.Name
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && entry.uid == secret.UID && entry.resourceVersion == secret.ResourceVersion {
+		return entry.credentials, nil
+	}
+
+	creds, err := readImagePullSecret(secret)
+	if err != nil {
+		c.invalidate(secret.Namespace, secret.Name)
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.entries[key] = cachedRegistryCredentials{
+		uid:             secret.UID,
+		resourceVersion: secret.ResourceVersion,
+		credentials:     creds,
+	}
+	c.mu.Unlock()
+
+	return creds, nil
+}
+
+// invalidate drops the cached credentials of a secret, e.g. once it no longer exists.
+func (c *registryCredentialCache) invalidate(namespace, name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, namespace+"/"+name)
+}
+
+// flush drops the cached credentials of every secret.
+func (c *registryCredentialCache) flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]cachedRegistryCredentials)
+}
+
+func readImagePullSecret(secret *v1.Secret) ([]azaci.ImageRegistryCredential, error) {
+	switch secret.Type {
+	case v1.SecretTypeDockercfg:
+		return readDockerCfgSecret(secret, nil)
+	case v1.SecretTypeDockerConfigJson:
+		return readDockerConfigJSONSecret(secret, nil)
+	default:
+		return nil, fmt.Errorf("image pull secret type is not one of kubernetes.io/dockercfg or kubernetes.io/dockerconfigjson")
+	}
+}
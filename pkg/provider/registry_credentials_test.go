@@ -0,0 +1,109 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	testsutil "github.com/virtual-kubelet/azure-aci/pkg/tests"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestMakeRegistryCredentialPasswordWithColons(t *testing.T) {
+	auth := base64.StdEncoding.EncodeToString([]byte("user:pa:ss:word"))
+
+	cred, err := makeRegistryCredential("myacr.azurecr.io", AuthConfig{Auth: auth})
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("user", *cred.Username))
+	assert.Check(t, is.Equal("pa:ss:word", *cred.Password))
+}
+
+func TestRegistryCredentialCacheInvalidatesOnChange(t *testing.T) {
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "pull-secret",
+			Namespace:       "ns",
+			UID:             "uid-1",
+			ResourceVersion: "1",
+		},
+		Type: v1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{
+			v1.DockerConfigJsonKey: []byte(`{"auths":{"myacr.azurecr.io":{"username":"user","password":"old"}}}`),
+		},
+	}
+
+	cache := newRegistryCredentialCache()
+	creds, err := cache.get(secret)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("old", *creds[0].Password))
+
+	// Same resourceVersion is served from the cache even if the data differs.
+	cached := secret.DeepCopy()
+	cached.Data[v1.DockerConfigJsonKey] = []byte(`{"auths":{"myacr.azurecr.io":{"username":"user","password":"new"}}}`)
+	creds, err = cache.get(cached)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("old", *creds[0].Password))
+
+	updated := cached.DeepCopy()
+	updated.ResourceVersion = "2"
+	creds, err = cache.get(updated)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("new", *creds[0].Password))
+
+	recreated := updated.DeepCopy()
+	recreated.UID = "uid-2"
+	recreated.Data[v1.DockerConfigJsonKey] = []byte(`{"auths":{"myacr.azurecr.io":{"username":"user","password":"recreated"}}}`)
+	creds, err = cache.get(recreated)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("recreated", *creds[0].Password))
+}
+
+func TestMakeRegistryCredentialWithIdentityToken(t *testing.T) {
+	cred, err := makeRegistryCredential("myacr.azurecr.io", AuthConfig{IdentityToken: "refresh-token"})
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(acrTokenUsername, *cred.Username))
+	assert.Check(t, is.Equal("refresh-token", *cred.Password))
+
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "acr", Namespace: "ns"},
+		Type:       v1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{
+			v1.DockerConfigJsonKey: []byte(`{"auths":{"myacr.azurecr.io":{"auth":"MDAwMDAwMDAtMDAwMC0wMDAwLTAwMDAtMDAwMDAwMDAwMDAwOg==","identitytoken":"refresh-token"}}}`),
+		},
+	}
+	creds, err := readImagePullSecret(secret)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(1, len(creds)))
+	assert.Check(t, is.Equal(acrTokenUsername, *creds[0].Username))
+	assert.Check(t, is.Equal("refresh-token", *creds[0].Password))
+}
+
+func TestGetPodImagePullSecretsFromServiceAccount(t *testing.T) {
+	p := &ACIProvider{}
+	pod := testsutil.CreatePodObj("pod", "ns")
+	pod.Spec.ImagePullSecrets = []v1.LocalObjectReference{{Name: "pod-secret"}}
+
+	refs := p.getPodImagePullSecrets(context.Background(), pod)
+	assert.Check(t, is.Equal(1, len(refs)), "only the pod secrets are known without a kube client")
+
+	p.kubeClient = fake.NewSimpleClientset(&v1.ServiceAccount{
+		ObjectMeta:       metav1.ObjectMeta{Name: "default", Namespace: "ns"},
+		ImagePullSecrets: []v1.LocalObjectReference{{Name: "pod-secret"}, {Name: "sa-secret"}},
+	})
+	refs = p.getPodImagePullSecrets(context.Background(), pod)
+	assert.Assert(t, is.Equal(2, len(refs)), "service account secrets should be merged without duplicates")
+	assert.Check(t, is.Equal("pod-secret", refs[0].Name))
+	assert.Check(t, is.Equal("sa-secret", refs[1].Name))
+
+	pod.Spec.ServiceAccountName = "missing"
+	refs = p.getPodImagePullSecrets(context.Background(), pod)
+	assert.Check(t, is.Equal(1, len(refs)), "a missing service account should not fail the pod")
+}
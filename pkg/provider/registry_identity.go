@@ -0,0 +1,113 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"strings"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	client2 "github.com/virtual-kubelet/azure-aci/pkg/client"
+	v1 "k8s.io/api/core/v1"
+)
+
+const dockerHubRegistry = "docker.io"
+
+// acrDomainSuffixes are the login server suffixes of ACR in the public and sovereign clouds.
+var acrDomainSuffixes = []string{".azurecr.io", ".azurecr.cn", ".azurecr.us", ".azurecr.de"}
+
+// getImageRegistry returns the registry host of an image reference, docker.io when it has none.
+func getImageRegistry(image string) string {
+	i := strings.IndexRune(image, '/')
+	if i == -1 {
+		return dockerHubRegistry
+	}
+	host := image[:i]
+	if !strings.ContainsAny(host, ".:") && host != "localhost" {
+		return dockerHubRegistry
+	}
+	return host
+}
+
+// isACRIdentityRegistry reports whether images of the registry are pulled with the ACR identity.
+// Without configured registries every ACR login server is.
+func (p *ACIProvider) isACRIdentityRegistry(registry string) bool {
+	if len(p.acrIdentityRegistries) != 0 {
+		for _, r := range p.acrIdentityRegistries {
+			if strings.EqualFold(r, registry) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, suffix := range acrDomainSuffixes {
+		if strings.HasSuffix(strings.ToLower(registry), suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// addACRIdentityCredentials attaches the configured user-assigned identity to the container group
+// and pulls the images of ACR registries with it, so pods need no image pull secret for ACR.
+// Registries that already have a credential from an image pull secret keep it.
+func (p *ACIProvider) addACRIdentityCredentials(pod *v1.Pod, cg *client2.ContainerGroupWrapper) {
+	if p.acrIdentity == "" {
+		return
+	}
+
+	cgProperties := cg.ContainerGroupPropertiesWrapper.ContainerGroupProperties
+	creds := []azaci.ImageRegistryCredential{}
+	if cgProperties.ImageRegistryCredentials != nil {
+		creds = *cgProperties.ImageRegistryCredentials
+	}
+	servers := make(map[string]bool, len(creds))
+	for _, cred := range creds {
+		if cred.Server != nil {
+			servers[strings.ToLower(*cred.Server)] = true
+		}
+	}
+
+	added := false
+	for _, containers := range [][]v1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for _, container := range containers {
+			registry := getImageRegistry(container.Image)
+			if servers[strings.ToLower(registry)] || !p.isACRIdentityRegistry(registry) {
+				continue
+			}
+			servers[strings.ToLower(registry)] = true
+			creds = append(creds, azaci.ImageRegistryCredential{
+				Server:   &registry,
+				Identity: &p.acrIdentity,
+			})
+			added = true
+		}
+	}
+	if !added {
+		return
+	}
+	cgProperties.ImageRegistryCredentials = &creds
+
+	attachUserAssignedIdentity(cg, p.acrIdentity)
+}
+
+// attachUserAssignedIdentity adds a user-assigned managed identity to the container group, keeping
+// the identities already attached.
+func attachUserAssignedIdentity(cg *client2.ContainerGroupWrapper, identity string) {
+	if cg.Identity == nil {
+		cg.Identity = &azaci.ContainerGroupIdentity{
+			Type: azaci.ResourceIdentityTypeUserAssigned,
+		}
+	}
+	if cg.Identity.UserAssignedIdentities == nil {
+		cg.Identity.UserAssignedIdentities = map[string]*azaci.ContainerGroupIdentityUserAssignedIdentitiesValue{}
+	}
+	switch cg.Identity.Type {
+	case azaci.ResourceIdentityTypeSystemAssigned:
+		cg.Identity.Type = azaci.ResourceIdentityTypeSystemAssignedUserAssigned
+	case "", azaci.ResourceIdentityTypeNone:
+		cg.Identity.Type = azaci.ResourceIdentityTypeUserAssigned
+	}
+	cg.Identity.UserAssignedIdentities[identity] = &azaci.ContainerGroupIdentityUserAssignedIdentitiesValue{}
+}
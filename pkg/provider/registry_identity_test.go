@@ -0,0 +1,72 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"testing"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	client2 "github.com/virtual-kubelet/azure-aci/pkg/client"
+	testsutil "github.com/virtual-kubelet/azure-aci/pkg/tests"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestGetImageRegistry(t *testing.T) {
+	cases := map[string]string{
+		"nginx":                          dockerHubRegistry,
+		"library/nginx:1.21":             dockerHubRegistry,
+		"myacr.azurecr.io/app:v1":        "myacr.azurecr.io",
+		"localhost:5000/app":             "localhost:5000",
+		"mcr.microsoft.com/oss/nginx:v1": "mcr.microsoft.com",
+	}
+	for image, registry := range cases {
+		assert.Check(t, is.Equal(registry, getImageRegistry(image)), image)
+	}
+}
+
+func TestAddACRIdentityCredentials(t *testing.T) {
+	identity := "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.ManagedIdentity/userAssignedIdentities/vk"
+	p := &ACIProvider{acrIdentity: identity}
+
+	secretServer, user, password := "private.azurecr.io", "user", "pass"
+	cg := &client2.ContainerGroupWrapper{
+		ContainerGroupPropertiesWrapper: &client2.ContainerGroupPropertiesWrapper{
+			ContainerGroupProperties: &azaci.ContainerGroupProperties{
+				ImageRegistryCredentials: &[]azaci.ImageRegistryCredential{
+					{Server: &secretServer, Username: &user, Password: &password},
+				},
+			},
+		},
+	}
+
+	pod := testsutil.CreatePodObj("pod", "ns")
+	pod.Spec.InitContainers = []v1.Container{{Name: "init", Image: "myacr.azurecr.io/init:v1"}}
+	pod.Spec.Containers = []v1.Container{
+		{Name: "app", Image: "myacr.azurecr.io/app:v1"},
+		{Name: "private", Image: "private.azurecr.io/app:v1"},
+		{Name: "hub", Image: "nginx"},
+	}
+
+	p.addACRIdentityCredentials(pod, cg)
+
+	creds := *cg.ContainerGroupPropertiesWrapper.ContainerGroupProperties.ImageRegistryCredentials
+	assert.Assert(t, is.Equal(2, len(creds)), "only the ACR registry without secret should use the identity")
+	assert.Check(t, is.Equal("myacr.azurecr.io", *creds[1].Server))
+	assert.Check(t, is.Equal(identity, *creds[1].Identity))
+	assert.Check(t, creds[1].Password == nil)
+
+	assert.Assert(t, cg.Identity != nil)
+	assert.Check(t, is.Equal(azaci.ResourceIdentityTypeUserAssigned, cg.Identity.Type))
+	_, ok := cg.Identity.UserAssignedIdentities[identity]
+	assert.Check(t, ok, "the identity should be attached to the container group")
+
+	p.acrIdentityRegistries = []string{"other.azurecr.io"}
+	cg.ContainerGroupPropertiesWrapper.ContainerGroupProperties.ImageRegistryCredentials = nil
+	cg.Identity = nil
+	p.addACRIdentityCredentials(pod, cg)
+	assert.Check(t, cg.Identity == nil, "registries outside the configured list should not use the identity")
+}
@@ -0,0 +1,83 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	client2 "github.com/virtual-kubelet/azure-aci/pkg/client"
+	"github.com/virtual-kubelet/azure-aci/pkg/errcodes"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	v1 "k8s.io/api/core/v1"
+)
+
+// runtimeClassConfig maps a runtimeClassName of the pods to their container groups, e.g. kata-cc
+// to confidential container groups.
+type runtimeClassConfig struct {
+	// SKU is the SKU of the container groups, "Standard", "Dedicated" or "Confidential".
+	SKU string
+	// CCEPolicy is the base64 encoded confidential computing enforcement policy of confidential
+	// container groups, e.g. generated with "az confcom acipolicygen". ACI applies a policy allowing
+	// everything when it is empty, which is only fit for development.
+	CCEPolicy string
+}
+
+// parseRuntimeClassSKU returns the SKU named case insensitively. Runtime classes are the only way
+// to get confidential container groups.
+func parseRuntimeClassSKU(value string) (azaci.ContainerGroupSku, bool) {
+	if strings.EqualFold(string(client2.ContainerGroupSkuConfidential), strings.TrimSpace(value)) {
+		return client2.ContainerGroupSkuConfidential, true
+	}
+	return parseContainerGroupSKU(value)
+}
+
+func (c runtimeClassConfig) validate(name string) error {
+	sku, ok := parseRuntimeClassSKU(c.SKU)
+	if !ok {
+		return fmt.Errorf("%q is not a valid container group SKU for runtime class %s, try one of the following instead: %s | %s | %s", c.SKU, name, azaci.ContainerGroupSkuStandard, azaci.ContainerGroupSkuDedicated, client2.ContainerGroupSkuConfidential)
+	}
+	if c.CCEPolicy == "" {
+		return nil
+	}
+	if sku != client2.ContainerGroupSkuConfidential {
+		return fmt.Errorf("runtime class %s sets a CCE policy, which requires the %s SKU", name, client2.ContainerGroupSkuConfidential)
+	}
+	if _, err := base64.StdEncoding.DecodeString(c.CCEPolicy); err != nil {
+		return fmt.Errorf("the CCE policy of runtime class %s is not base64 encoded: %v", name, err)
+	}
+	return nil
+}
+
+// applyRuntimeClass sets the SKU and the confidential computing properties of the container group
+// from the runtime class of the pod. The runtime class wins over the namespace and provider SKUs,
+// a SKU annotation asking for another SKU is an error. Like the kubelet, pods of an unknown
+// runtime class are rejected.
+func (p *ACIProvider) applyRuntimeClass(pod *v1.Pod, cg *client2.ContainerGroupWrapper) error {
+	if pod.Spec.RuntimeClassName == nil || *pod.Spec.RuntimeClassName == "" {
+		return nil
+	}
+	name := *pod.Spec.RuntimeClassName
+	class, ok := p.runtimeClasses[name]
+	if !ok {
+		return errcodes.Wrap(errcodes.RuntimeClassNotSupported, errdefs.InvalidInputf("runtime class %s of pod %s is not configured on the virtual node", name, pod.Name))
+	}
+
+	sku, _ := parseRuntimeClassSKU(class.SKU)
+	if value, ok := pod.Annotations[containerGroupSKUAnnotation]; ok {
+		if annotated, _ := parseContainerGroupSKU(value); annotated != sku {
+			return errcodes.Wrap(errcodes.RuntimeClassNotSupported, errdefs.InvalidInputf("annotation %s asks for the %s SKU, but runtime class %s uses the %s SKU", containerGroupSKUAnnotation, value, name, sku))
+		}
+	}
+
+	cg.ContainerGroupPropertiesWrapper.ContainerGroupProperties.Sku = sku
+	if class.CCEPolicy != "" {
+		policy := class.CCEPolicy
+		cg.ContainerGroupPropertiesWrapper.ConfidentialComputeProperties = &client2.ConfidentialComputeProperties{CcePolicy: &policy}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"testing"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	client2 "github.com/virtual-kubelet/azure-aci/pkg/client"
+	testsutil "github.com/virtual-kubelet/azure-aci/pkg/tests"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestApplyRuntimeClass(t *testing.T) {
+	p := &ACIProvider{
+		runtimeClasses: map[string]runtimeClassConfig{
+			"kata-cc":   {SKU: "confidential", CCEPolicy: "cG9saWN5"},
+			"dedicated": {SKU: "Dedicated"},
+		},
+	}
+	newContainerGroup := func() *client2.ContainerGroupWrapper {
+		return &client2.ContainerGroupWrapper{
+			ContainerGroupPropertiesWrapper: &client2.ContainerGroupPropertiesWrapper{
+				ContainerGroupProperties: &azaci.ContainerGroupProperties{Sku: azaci.ContainerGroupSkuStandard},
+			},
+		}
+	}
+	runtimeClass := func(name string) *string { return &name }
+
+	pod := testsutil.CreatePodObj("pod", "ns")
+	cg := newContainerGroup()
+	assert.NilError(t, p.applyRuntimeClass(pod, cg))
+	assert.Check(t, is.Equal(azaci.ContainerGroupSkuStandard, cg.ContainerGroupPropertiesWrapper.ContainerGroupProperties.Sku), "pods without runtime class should keep their SKU")
+
+	pod.Spec.RuntimeClassName = runtimeClass("kata-cc")
+	assert.NilError(t, p.applyRuntimeClass(pod, cg))
+	assert.Check(t, is.Equal(client2.ContainerGroupSkuConfidential, cg.ContainerGroupPropertiesWrapper.ContainerGroupProperties.Sku))
+	assert.Assert(t, cg.ContainerGroupPropertiesWrapper.ConfidentialComputeProperties != nil)
+	assert.Check(t, is.Equal("cG9saWN5", *cg.ContainerGroupPropertiesWrapper.ConfidentialComputeProperties.CcePolicy))
+
+	pod.Spec.RuntimeClassName = runtimeClass("dedicated")
+	pod.Annotations = map[string]string{containerGroupSKUAnnotation: "Standard"}
+	assert.Check(t, errdefs.IsInvalidInput(p.applyRuntimeClass(pod, newContainerGroup())), "a conflicting SKU annotation should be rejected")
+
+	pod.Annotations = nil
+	pod.Spec.RuntimeClassName = runtimeClass("gvisor")
+	assert.Check(t, errdefs.IsInvalidInput(p.applyRuntimeClass(pod, newContainerGroup())), "unknown runtime classes should be rejected")
+}
+
+func TestRuntimeClassConfigValidate(t *testing.T) {
+	assert.NilError(t, runtimeClassConfig{SKU: "Confidential"}.validate("kata-cc"))
+	assert.Check(t, runtimeClassConfig{SKU: "Spot"}.validate("spot") != nil)
+	assert.Check(t, runtimeClassConfig{SKU: "Standard", CCEPolicy: "cG9saWN5"}.validate("standard") != nil, "CCE policies require the confidential SKU")
+	assert.Check(t, runtimeClassConfig{SKU: "Confidential", CCEPolicy: "not base64!"}.validate("kata-cc") != nil)
+}
@@ -0,0 +1,134 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"encoding/base64"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	client2 "github.com/virtual-kubelet/azure-aci/pkg/client"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	secretDeliveryEnvironmentVariable = "EnvironmentVariable"
+	secretDeliveryFile                = "File"
+
+	// secretDeliveryAnnotation lets a pod ask for its secret env vars to be delivered as files.
+	secretDeliveryAnnotation = "virtual-kubelet.io/secret-delivery"
+	// secretEnvMountPath is where the files replacing secret env vars are mounted, one file per variable.
+	secretEnvMountPath = "/var/run/secrets/env"
+)
+
+// getSecretDeliveryPolicy returns how the secret env vars of the pod are delivered. The strictest
+// setting of the provider, the namespace and the pod wins, so a pod can not opt out of files.
+func (p *ACIProvider) getSecretDeliveryPolicy(pod *v1.Pod) (string, error) {
+	switch policy := pod.Annotations[secretDeliveryAnnotation]; policy {
+	case "", secretDeliveryEnvironmentVariable:
+	case secretDeliveryFile:
+		return secretDeliveryFile, nil
+	default:
+		return "", errdefs.InvalidInputf("%q is not a valid value of annotation %s, try one of the following instead: %s | %s", policy, secretDeliveryAnnotation, secretDeliveryEnvironmentVariable, secretDeliveryFile)
+	}
+
+	for _, ns := range p.secretDeliveryNamespaces {
+		if pod.Namespace == ns {
+			return secretDeliveryFile, nil
+		}
+	}
+	if p.secretDeliveryPolicy == secretDeliveryFile {
+		return secretDeliveryFile, nil
+	}
+	return secretDeliveryEnvironmentVariable, nil
+}
+
+// applySecretDeliveryPolicy moves the secure env vars of every container into a secret volume
+// mounted at secretEnvMountPath when the pod must not receive secrets as env vars.
+func (p *ACIProvider) applySecretDeliveryPolicy(pod *v1.Pod, cg *client2.ContainerGroupWrapper) error {
+	policy, err := p.getSecretDeliveryPolicy(pod)
+	if err != nil {
+		return err
+	}
+	if policy != secretDeliveryFile {
+		return nil
+	}
+
+	cgProperties := cg.ContainerGroupPropertiesWrapper.ContainerGroupProperties
+	volumes := []azaci.Volume{}
+	if cgProperties.Volumes != nil {
+		volumes = *cgProperties.Volumes
+	}
+
+	if cgProperties.InitContainers != nil {
+		for i := range *cgProperties.InitContainers {
+			container := &(*cgProperties.InitContainers)[i]
+			if container.InitContainerPropertiesDefinition == nil {
+				continue
+			}
+			volume := moveSecureEnvToVolume(*container.Name, &container.EnvironmentVariables, &container.VolumeMounts)
+			if volume != nil {
+				volumes = append(volumes, *volume)
+			}
+		}
+	}
+	if cgProperties.Containers != nil {
+		for i := range *cgProperties.Containers {
+			container := &(*cgProperties.Containers)[i]
+			if container.ContainerProperties == nil {
+				continue
+			}
+			volume := moveSecureEnvToVolume(*container.Name, &container.EnvironmentVariables, &container.VolumeMounts)
+			if volume != nil {
+				volumes = append(volumes, *volume)
+			}
+		}
+	}
+
+	cgProperties.Volumes = &volumes
+	return nil
+}
+
+// moveSecureEnvToVolume removes the secure env vars of a container and returns the secret volume
+// holding them, mounted into the container. It returns nil when the container has none.
+func moveSecureEnvToVolume(containerName string, envs **[]azaci.EnvironmentVariable, mounts **[]azaci.VolumeMount) *azaci.Volume {
+	if *envs == nil {
+		return nil
+	}
+
+	kept := make([]azaci.EnvironmentVariable, 0, len(**envs))
+	files := map[string]*string{}
+	for _, env := range **envs {
+		if env.SecureValue == nil {
+			kept = append(kept, env)
+			continue
+		}
+		encoded := base64.StdEncoding.EncodeToString([]byte(*env.SecureValue))
+		files[*env.Name] = &encoded
+	}
+	if len(files) == 0 {
+		return nil
+	}
+	*envs = &kept
+
+	volumeName := "secret-env-" + containerName
+	mountPath := secretEnvMountPath
+	readOnly := true
+	volumeMounts := []azaci.VolumeMount{}
+	if *mounts != nil {
+		volumeMounts = **mounts
+	}
+	volumeMounts = append(volumeMounts, azaci.VolumeMount{
+		Name:      &volumeName,
+		MountPath: &mountPath,
+		ReadOnly:  &readOnly,
+	})
+	*mounts = &volumeMounts
+
+	return &azaci.Volume{
+		Name:   &volumeName,
+		Secret: files,
+	}
+}
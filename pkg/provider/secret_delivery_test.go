@@ -0,0 +1,65 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"encoding/base64"
+	"testing"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	client2 "github.com/virtual-kubelet/azure-aci/pkg/client"
+	testsutil "github.com/virtual-kubelet/azure-aci/pkg/tests"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestApplySecretDeliveryPolicy(t *testing.T) {
+	newContainerGroup := func() *client2.ContainerGroupWrapper {
+		name, plainName, secretName := "app", "PLAIN", "DB_PASSWORD"
+		plain, secret := "value", "s3cret"
+		return &client2.ContainerGroupWrapper{
+			ContainerGroupPropertiesWrapper: &client2.ContainerGroupPropertiesWrapper{
+				ContainerGroupProperties: &azaci.ContainerGroupProperties{
+					Containers: &[]azaci.Container{{
+						Name: &name,
+						ContainerProperties: &azaci.ContainerProperties{
+							EnvironmentVariables: &[]azaci.EnvironmentVariable{
+								{Name: &plainName, Value: &plain},
+								{Name: &secretName, SecureValue: &secret},
+							},
+							VolumeMounts: &[]azaci.VolumeMount{},
+						},
+					}},
+				},
+			},
+		}
+	}
+
+	p := &ACIProvider{secretDeliveryPolicy: secretDeliveryEnvironmentVariable, secretDeliveryNamespaces: []string{"payments"}}
+
+	pod := testsutil.CreatePodObj("pod", "ns")
+	cg := newContainerGroup()
+	assert.NilError(t, p.applySecretDeliveryPolicy(pod, cg))
+	container := (*cg.ContainerGroupPropertiesWrapper.ContainerGroupProperties.Containers)[0]
+	assert.Check(t, is.Equal(2, len(*container.EnvironmentVariables)), "secrets should stay env vars by default")
+
+	pod = testsutil.CreatePodObj("pod", "payments")
+	pod.Annotations = map[string]string{secretDeliveryAnnotation: secretDeliveryEnvironmentVariable}
+	cg = newContainerGroup()
+	assert.NilError(t, p.applySecretDeliveryPolicy(pod, cg))
+	container = (*cg.ContainerGroupPropertiesWrapper.ContainerGroupProperties.Containers)[0]
+	assert.Check(t, is.Equal(1, len(*container.EnvironmentVariables)), "the namespace policy should win over the pod")
+	assert.Check(t, is.Equal("PLAIN", *(*container.EnvironmentVariables)[0].Name))
+	assert.Check(t, is.Equal(1, len(*container.VolumeMounts)))
+	assert.Check(t, is.Equal(secretEnvMountPath, *(*container.VolumeMounts)[0].MountPath))
+
+	volumes := *cg.ContainerGroupPropertiesWrapper.ContainerGroupProperties.Volumes
+	assert.Check(t, is.Equal(1, len(volumes)))
+	assert.Check(t, is.Equal("secret-env-app", *volumes[0].Name))
+	assert.Check(t, is.Equal(base64.StdEncoding.EncodeToString([]byte("s3cret")), *volumes[0].Secret["DB_PASSWORD"]))
+
+	pod.Annotations[secretDeliveryAnnotation] = "Disk"
+	assert.Check(t, p.applySecretDeliveryPolicy(pod, newContainerGroup()) != nil, "invalid annotation should fail")
+}
@@ -0,0 +1,53 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// serviceAccountsResyncPeriod is how often the cached service accounts are resynced.
+const serviceAccountsResyncPeriod = 10 * time.Minute
+
+// serviceAccountCache caches the service accounts like the resource manager caches the secrets and
+// config maps, which it does not do for service accounts, so creating a pod does not get its service
+// account from the API server.
+type serviceAccountCache struct {
+	lister corev1listers.ServiceAccountLister
+	synced cache.InformerSynced
+}
+
+// newServiceAccountCache starts watching the service accounts until ctx is done.
+func newServiceAccountCache(ctx context.Context, kubeClient kubernetes.Interface) *serviceAccountCache {
+	factory := informers.NewSharedInformerFactory(kubeClient, serviceAccountsResyncPeriod)
+	informer := factory.Core().V1().ServiceAccounts()
+	c := &serviceAccountCache{
+		lister: informer.Lister(),
+		synced: informer.Informer().HasSynced,
+	}
+	factory.Start(ctx.Done())
+	return c
+}
+
+// getServiceAccount returns a service account from the cache. Service accounts the cache has not
+// synced yet, or has not seen yet, are got from the API server.
+func (p *ACIProvider) getServiceAccount(ctx context.Context, namespace, name string) (*v1.ServiceAccount, error) {
+	if c := p.serviceAccounts; c != nil && c.synced() {
+		serviceAccount, err := c.lister.ServiceAccounts(namespace).Get(name)
+		if err == nil || !k8serr.IsNotFound(err) {
+			return serviceAccount, err
+		}
+	}
+	return p.kubeClient.CoreV1().ServiceAccounts(namespace).Get(ctx, name, metav1.GetOptions{})
+}
@@ -0,0 +1,49 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"testing"
+
+	testsutil "github.com/virtual-kubelet/azure-aci/pkg/tests"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestGetPodImagePullSecretsFromCachedServiceAccount(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	_ = indexer.Add(&v1.ServiceAccount{
+		ObjectMeta:       metav1.ObjectMeta{Name: "default", Namespace: "ns"},
+		ImagePullSecrets: []v1.LocalObjectReference{{Name: "acr-secret"}},
+	})
+	kubeClient := fake.NewSimpleClientset(&v1.ServiceAccount{
+		ObjectMeta:       metav1.ObjectMeta{Name: "builder", Namespace: "ns"},
+		ImagePullSecrets: []v1.LocalObjectReference{{Name: "builder-secret"}},
+	})
+	p := &ACIProvider{
+		kubeClient: kubeClient,
+		serviceAccounts: &serviceAccountCache{
+			lister: corev1listers.NewServiceAccountLister(indexer),
+			synced: func() bool { return true },
+		},
+	}
+	pod := testsutil.CreatePodObj("pod", "ns")
+
+	refs := p.getPodImagePullSecrets(context.Background(), pod)
+	assert.Assert(t, is.Len(refs, 1))
+	assert.Check(t, is.Equal("acr-secret", refs[0].Name))
+	assert.Check(t, is.Len(kubeClient.Actions(), 0), "cached service accounts should not be got from the API server")
+
+	pod.Spec.ServiceAccountName = "builder"
+	refs = p.getPodImagePullSecrets(context.Background(), pod)
+	assert.Assert(t, is.Len(refs, 1), "service accounts missing from the cache should be got from the API server")
+	assert.Check(t, is.Equal("builder-secret", refs[0].Name))
+}
@@ -0,0 +1,139 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	client2 "github.com/virtual-kubelet/azure-aci/pkg/client"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+)
+
+const (
+	eventGridEventTypeHeader           = "aeg-event-type"
+	eventGridSubscriptionValidation    = "SubscriptionValidation"
+	eventGridSubscriptionValidationKey = "Microsoft.EventGrid.SubscriptionValidationEvent"
+
+	// maxStatusNotificationBytes bounds the body of a notification, Event Grid batches up to 1MB.
+	maxStatusNotificationBytes = 1 << 20
+	// statusNotificationTimeout bounds reading a notification and answering it. Event Grid waits
+	// 30 seconds for the answer before retrying the delivery.
+	statusNotificationTimeout = 30 * time.Second
+)
+
+// eventGridEvent is an event in the Event Grid schema. The subject of the resource group events is
+// the ID of the resource that changed.
+type eventGridEvent struct {
+	ID        string          `json:"id"`
+	Subject   string          `json:"subject"`
+	EventType string          `json:"eventType"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// StatusNotificationHandler receives the Event Grid notifications of the resource group of the
+// container groups, a webhook subscription with the key as the key query parameter, and updates the
+// status of the pods whose container group changed right away. The pods are still polled, so
+// notifications that are lost or late only delay their status.
+func (p *ACIProvider) StatusNotificationHandler(key string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		given := r.URL.Query().Get("key")
+		if key == "" || subtle.ConstantTimeCompare([]byte(given), []byte(key)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := r.Context()
+		var events []eventGridEvent
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxStatusNotificationBytes)).Decode(&events); err != nil {
+			http.Error(w, "invalid Event Grid events: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if r.Header.Get(eventGridEventTypeHeader) == eventGridSubscriptionValidation {
+			for _, event := range events {
+				if event.EventType != eventGridSubscriptionValidationKey {
+					continue
+				}
+				var data struct {
+					ValidationCode string `json:"validationCode"`
+				}
+				if err := json.Unmarshal(event.Data, &data); err != nil || data.ValidationCode == "" {
+					http.Error(w, "invalid subscription validation event", http.StatusBadRequest)
+					return
+				}
+				log.G(ctx).Info("Event Grid subscription for container group status notifications validated")
+				writeAdminJSON(ctx, w, http.StatusOK, map[string]string{"validationResponse": data.ValidationCode})
+				return
+			}
+			http.Error(w, "no subscription validation event", http.StatusBadRequest)
+			return
+		}
+
+		for _, event := range events {
+			p.notifyContainerGroupChanged(ctx, event.Subject)
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// ServeStatusNotifications serves StatusNotificationHandler on addr until the context is done, with
+// TLS when a certificate and key file are given. Event Grid only delivers to HTTPS endpoints, so
+// without them a TLS terminating ingress has to forward the notifications.
+func (p *ACIProvider) ServeStatusNotifications(ctx context.Context, addr, key, certFile, keyFile string) {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           p.StatusNotificationHandler(key),
+		ReadHeaderTimeout: statusNotificationTimeout,
+		ReadTimeout:       statusNotificationTimeout,
+		WriteTimeout:      statusNotificationTimeout,
+		IdleTimeout:       2 * time.Minute,
+	}
+	if err := serveEndpoint(ctx, server, certFile, keyFile); err != nil {
+		log.G(ctx).WithError(err).Error("failed to serve the container group status notifications")
+	}
+}
+
+// notifyContainerGroupChanged requests a status update of the pod of the container group with the
+// resource ID. Other resources and container groups of other nodes are ignored.
+func (p *ACIProvider) notifyContainerGroupChanged(ctx context.Context, resourceID string) {
+	tracker := p.podsTracker()
+	if tracker == nil {
+		return
+	}
+	name, ok := containerGroupNameFromID(p.resourceGroup, resourceID)
+	if !ok {
+		return
+	}
+	for _, pod := range p.resourceManager.GetPods() {
+		if client2.ContainerGroupName(pod.Namespace, pod.Name) != name {
+			continue
+		}
+		if !tracker.requestPodUpdate(PodIdentifier{namespace: pod.Namespace, name: pod.Name}) {
+			log.G(ctx).Debugf("status update of pod %s/%s left to the next poll", pod.Namespace, pod.Name)
+		}
+		return
+	}
+}
+
+// containerGroupNameFromID returns the name of the container group with the resource ID, when it is
+// a container group of the resource group.
+func containerGroupNameFromID(resourceGroup, resourceID string) (string, bool) {
+	parts := strings.Split(strings.Trim(resourceID, "/"), "/")
+	if len(parts) != 8 || !strings.EqualFold(parts[2], "resourceGroups") || !strings.EqualFold(parts[3], resourceGroup) ||
+		!strings.EqualFold(parts[5], "Microsoft.ContainerInstance") || !strings.EqualFold(parts[6], "containerGroups") {
+		return "", false
+	}
+	return parts[7], true
+}
@@ -0,0 +1,69 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	testsutil "github.com/virtual-kubelet/azure-aci/pkg/tests"
+	"github.com/virtual-kubelet/node-cli/manager"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func notificationRequest(handler http.Handler, key, eventType, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/?key="+key, strings.NewReader(body))
+	if eventType != "" {
+		req.Header.Set(eventGridEventTypeHeader, eventType)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestStatusNotificationHandler(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	pod := testsutil.CreatePodObj("web", "ns")
+	podLister := NewMockPodLister(mockCtrl)
+	podLister.EXPECT().List(labels.Everything()).Return([]*v1.Pod{pod}, nil).AnyTimes()
+	rm, err := manager.NewResourceManager(podLister, nil, nil, newServiceLister(), nil, nil)
+	if err != nil {
+		t.Fatal("Unable to prepare the mocks for resourceManager", err)
+	}
+
+	tracker := &PodsTracker{updates: make(chan PodIdentifier, podUpdateRequestsBuffer)}
+	p := &ACIProvider{resourceManager: rm, resourceGroup: "vk-rg", tracker: tracker}
+	handler := p.StatusNotificationHandler("s3cret")
+
+	assert.Check(t, is.Equal(http.StatusUnauthorized, notificationRequest(handler, "wrong", "", "[]").Code))
+	assert.Check(t, is.Equal(http.StatusUnauthorized, notificationRequest(p.StatusNotificationHandler(""), "", "", "[]").Code), "an empty key should never authorize")
+	assert.Check(t, is.Equal(http.StatusBadRequest, notificationRequest(handler, "s3cret", "", "{").Code))
+
+	validation := `[{"id": "1", "eventType": "Microsoft.EventGrid.SubscriptionValidationEvent", "data": {"validationCode": "abc"}}]`
+	rec := notificationRequest(handler, "s3cret", eventGridSubscriptionValidation, validation)
+	assert.Assert(t, is.Equal(http.StatusOK, rec.Code))
+	var response map[string]string
+	assert.NilError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Check(t, is.Equal("abc", response["validationResponse"]))
+
+	notifications := `[
+		{"id": "2", "eventType": "Microsoft.Resources.ResourceWriteSuccess", "subject": "/subscriptions/sub/resourceGroups/other-rg/providers/Microsoft.ContainerInstance/containerGroups/ns-web"},
+		{"id": "3", "eventType": "Microsoft.Resources.ResourceWriteSuccess", "subject": "/subscriptions/sub/resourceGroups/vk-rg/providers/Microsoft.Network/networkProfiles/ns-web"},
+		{"id": "4", "eventType": "Microsoft.Resources.ResourceWriteSuccess", "subject": "/subscriptions/sub/resourceGroups/vk-rg/providers/Microsoft.ContainerInstance/containerGroups/ns-api"},
+		{"id": "5", "eventType": "Microsoft.Resources.ResourceActionSuccess", "subject": "/subscriptions/sub/resourceGroups/VK-RG/providers/Microsoft.ContainerInstance/containerGroups/ns-web"}
+	]`
+	assert.Check(t, is.Equal(http.StatusOK, notificationRequest(handler, "s3cret", "Notification", notifications).Code))
+	assert.Assert(t, is.Len(tracker.updates, 1), "only the container group of a pod of the node should be updated")
+	assert.Check(t, is.Equal(PodIdentifier{namespace: "ns", name: "web"}, <-tracker.updates))
+}
@@ -0,0 +1,200 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	aznetwork "github.com/Azure/azure-sdk-for-go/services/network/mgmt/2021-05-01/network"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/virtual-kubelet/azure-aci/pkg/errcodes"
+	"github.com/virtual-kubelet/azure-aci/pkg/metrics"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	defaultSubnetUsageRefreshInterval = 1 * time.Minute
+
+	// azureReservedSubnetIPs is the number of addresses Azure reserves in every subnet.
+	azureReservedSubnetIPs = 5
+)
+
+// subnetUsageLister lists the IP address usage of the subnets of a virtual network.
+type subnetUsageLister func(ctx context.Context, subscriptionID, resourceGroup, vnetName string) ([]aznetwork.VirtualNetworkUsage, error)
+
+func newSubnetUsageLister(authorizer autorest.Authorizer) subnetUsageLister {
+	return func(ctx context.Context, subscriptionID, resourceGroup, vnetName string) ([]aznetwork.VirtualNetworkUsage, error) {
+		client := aznetwork.NewVirtualNetworksClient(subscriptionID)
+		client.Authorizer = authorizer
+		iter, err := client.ListUsageComplete(ctx, resourceGroup, vnetName)
+		if err != nil {
+			return nil, err
+		}
+		var usages []aznetwork.VirtualNetworkUsage
+		for ; iter.NotDone(); err = iter.NextWithContext(ctx) {
+			if err != nil {
+				return nil, err
+			}
+			usages = append(usages, iter.Value())
+		}
+		return usages, nil
+	}
+}
+
+// subnetCapacity returns the number of addresses container groups can use in the subnet.
+func subnetCapacity(cidr string) int {
+	_, subnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return 0
+	}
+	ones, bits := subnet.Mask.Size()
+	if bits-ones > 24 {
+		// Far more addresses than container groups, e.g. an IPv6 subnet.
+		ones = bits - 24
+	}
+	if capacity := (1 << uint(bits-ones)) - azureReservedSubnetIPs; capacity > 0 {
+		return capacity
+	}
+	return 0
+}
+
+// subnetPool spreads the container groups of the pods selecting no subnet across the subnet of the
+// virtual node and the pool subnets, to the subnet with the most free IP addresses, so a full subnet
+// does not fail the pods. The free addresses are refreshed from the virtual network usage and
+// counted locally in between, they are approximate until the next refresh.
+type subnetPool struct {
+	list            subnetUsageLister
+	refreshInterval time.Duration
+	subnets         []*delegatedSubnet
+
+	mu          sync.Mutex
+	free        map[string]int
+	assigned    map[PodIdentifier]string
+	refreshedAt time.Time
+}
+
+// newSubnetPool creates a pool of the subnets, in the order they are preferred when they have as
+// many free addresses.
+func newSubnetPool(list subnetUsageLister, subnets []*delegatedSubnet) *subnetPool {
+	pool := &subnetPool{
+		list:            list,
+		refreshInterval: defaultSubnetUsageRefreshInterval,
+		subnets:         subnets,
+		free:            make(map[string]int, len(subnets)),
+		assigned:        make(map[PodIdentifier]string),
+	}
+	for _, subnet := range subnets {
+		pool.free[subnet.id] = subnetCapacity(subnet.cidr)
+	}
+	return pool
+}
+
+// refresh reloads the free addresses of the subnets from the usage of their virtual networks.
+// Subnets whose usage cannot be listed keep their counts.
+func (s *subnetPool) refresh(ctx context.Context) {
+	listed := make(map[string]bool)
+	for _, subnet := range s.subnets {
+		vnet := strings.ToLower(getSubnetID(subnet.subscriptionID, subnet.resourceGroup, subnet.vnetName, ""))
+		if listed[vnet] {
+			continue
+		}
+		listed[vnet] = true
+
+		usages, err := s.list(ctx, subnet.subscriptionID, subnet.resourceGroup, subnet.vnetName)
+		if err != nil {
+			log.G(ctx).WithError(err).Warnf("unable to list the IP address usage of vnet %s, keeping the free addresses of its subnets", subnet.vnetName)
+			continue
+		}
+		s.mu.Lock()
+		for _, usage := range usages {
+			if usage.ID == nil || usage.Limit == nil || usage.CurrentValue == nil {
+				continue
+			}
+			for _, poolSubnet := range s.subnets {
+				if strings.EqualFold(*usage.ID, poolSubnet.id) {
+					s.free[poolSubnet.id] = int(*usage.Limit - *usage.CurrentValue)
+				}
+			}
+		}
+		s.mu.Unlock()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refreshedAt = time.Now()
+	for _, subnet := range s.subnets {
+		metrics.SetSubnetFreeIPs(subnet.name, s.free[subnet.id])
+	}
+}
+
+// pick assigns a subnet of the pool to the pod. A pod requesting an IP address gets the subnet
+// containing it, otherwise the subnet with the most free addresses is taken.
+func (s *subnetPool) pick(ctx context.Context, pod *v1.Pod, ip string) (*delegatedSubnet, error) {
+	s.mu.Lock()
+	stale := time.Since(s.refreshedAt) > s.refreshInterval
+	s.mu.Unlock()
+	if stale {
+		s.refresh(ctx)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.releaseLocked(pod)
+
+	var chosen *delegatedSubnet
+	for _, subnet := range s.subnets {
+		if !subnet.allows(pod.Namespace) {
+			continue
+		}
+		if ip != "" {
+			if _, cidr, err := net.ParseCIDR(subnet.cidr); err == nil && cidr.Contains(net.ParseIP(ip)) {
+				chosen = subnet
+				break
+			}
+			continue
+		}
+		if s.free[subnet.id] > 0 && (chosen == nil || s.free[subnet.id] > s.free[chosen.id]) {
+			chosen = subnet
+		}
+	}
+	if chosen == nil && ip != "" {
+		// The address is validated against the subnet of the node and rejected.
+		chosen = s.subnets[0]
+	}
+	if chosen == nil {
+		return nil, errcodes.Errorf(errcodes.SubnetFull, "the subnets of the virtual node available to namespace %s have no IP addresses left", pod.Namespace)
+	}
+
+	s.free[chosen.id]--
+	s.assigned[PodIdentifier{namespace: pod.Namespace, name: pod.Name}] = chosen.id
+	metrics.SetSubnetFreeIPs(chosen.name, s.free[chosen.id])
+	return chosen, nil
+}
+
+// release returns the address of the pod to its subnet, after its container group was deleted or
+// could not be created.
+func (s *subnetPool) release(pod *v1.Pod) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.releaseLocked(pod)
+}
+
+func (s *subnetPool) releaseLocked(pod *v1.Pod) {
+	key := PodIdentifier{namespace: pod.Namespace, name: pod.Name}
+	id, ok := s.assigned[key]
+	if !ok {
+		return
+	}
+	delete(s.assigned, key)
+	s.free[id]++
+}
@@ -0,0 +1,81 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	aznetwork "github.com/Azure/azure-sdk-for-go/services/network/mgmt/2021-05-01/network"
+	testsutil "github.com/virtual-kubelet/azure-aci/pkg/tests"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestSubnetCapacity(t *testing.T) {
+	assert.Check(t, is.Equal(251, subnetCapacity("10.240.0.0/24")))
+	assert.Check(t, is.Equal(11, subnetCapacity("10.240.0.0/28")))
+	assert.Check(t, is.Equal(0, subnetCapacity("10.240.0.0/30")))
+	assert.Check(t, is.Equal(0, subnetCapacity("")))
+}
+
+func TestSubnetPool(t *testing.T) {
+	node := &delegatedSubnet{name: "aci", id: getSubnetID("sub", "rg", "vnet", "aci"), cidr: "10.240.0.0/24", subscriptionID: "sub", resourceGroup: "rg", vnetName: "vnet"}
+	overflow := &delegatedSubnet{name: "overflow", id: getSubnetID("sub", "rg", "vnet", "overflow"), cidr: "10.241.0.0/28", subscriptionID: "sub", resourceGroup: "rg", vnetName: "vnet"}
+	restricted := &delegatedSubnet{name: "team-a", id: getSubnetID("sub", "rg", "vnet", "team-a"), cidr: "10.242.0.0/24", namespaces: []string{"team-a"}, subscriptionID: "sub", resourceGroup: "rg", vnetName: "vnet"}
+
+	usage := func(subnet *delegatedSubnet, used, limit float64) aznetwork.VirtualNetworkUsage {
+		return aznetwork.VirtualNetworkUsage{ID: &subnet.id, CurrentValue: &used, Limit: &limit}
+	}
+	usages := []aznetwork.VirtualNetworkUsage{usage(node, 250, 251), usage(overflow, 9, 11), usage(restricted, 0, 251)}
+	var listErr error
+	lists := 0
+	pool := newSubnetPool(func(ctx context.Context, subscriptionID, resourceGroup, vnetName string) ([]aznetwork.VirtualNetworkUsage, error) {
+		lists++
+		return usages, listErr
+	}, []*delegatedSubnet{node, overflow, restricted})
+	ctx := context.Background()
+
+	first, err := pool.pick(ctx, testsutil.CreatePodObj("first", "ns"), "")
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("overflow", first.name), "the subnet with the most free addresses should be picked")
+	assert.Check(t, is.Equal(1, lists), "the subnets of a vnet should be listed once")
+
+	second, err := pool.pick(ctx, testsutil.CreatePodObj("second", "ns"), "")
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("aci", second.name), "the subnets should be preferred in order when they have as many free addresses")
+
+	team, err := pool.pick(ctx, testsutil.CreatePodObj("pod", "team-a"), "")
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("team-a", team.name))
+
+	third, err := pool.pick(ctx, testsutil.CreatePodObj("third", "ns"), "")
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("overflow", third.name))
+
+	_, err = pool.pick(ctx, testsutil.CreatePodObj("fourth", "ns"), "")
+	assert.Check(t, err != nil, "exhausted subnets should fail the pod")
+
+	requested, err := pool.pick(ctx, testsutil.CreatePodObj("requested", "ns"), "10.240.0.10")
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("aci", requested.name), "a pod requesting an address should get the subnet containing it")
+
+	pool.release(testsutil.CreatePodObj("first", "ns"))
+	fourth, err := pool.pick(ctx, testsutil.CreatePodObj("fourth", "ns"), "")
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("overflow", fourth.name), "released addresses should be reused")
+	_, err = pool.pick(ctx, testsutil.CreatePodObj("fourth", "ns"), "")
+	assert.NilError(t, err, "picking again for the same pod should not count its address twice")
+
+	listErr = errors.New("throttled")
+	pool.refreshedAt = pool.refreshedAt.Add(-2 * pool.refreshInterval)
+	_, err = pool.pick(ctx, testsutil.CreatePodObj("fifth", "ns"), "")
+	assert.Check(t, err != nil, "a failed refresh should keep the free addresses")
+	assert.Check(t, is.Equal(2, lists))
+
+	var nilPool *subnetPool
+	nilPool.release(testsutil.CreatePodObj("first", "ns"))
+}
@@ -0,0 +1,186 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	aznetwork "github.com/Azure/azure-sdk-for-go/services/network/mgmt/2021-05-01/network"
+	"github.com/virtual-kubelet/azure-aci/pkg/auth"
+	"github.com/virtual-kubelet/azure-aci/pkg/errcodes"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	v1 "k8s.io/api/core/v1"
+)
+
+// subnetAnnotation selects one of the configured subnets for the pod by name, instead of the
+// subnet of the virtual node.
+const subnetAnnotation = "virtual-kubelet.io/subnet"
+
+// subnetConfig is a delegated subnet pods can select with the subnet annotation, e.g. to isolate
+// the workloads of a team in a subnet of their own.
+type subnetConfig struct {
+	// Name is the value of the annotation selecting the subnet.
+	Name string
+	// VNetSubscriptionID and VNetResourceGroup default to the ones of the virtual node subnet.
+	VNetSubscriptionID string
+	VNetResourceGroup  string
+	VNetName           string
+	SubnetName         string
+	// Namespaces restricts the subnet to the pods of these namespaces, any namespace may use it
+	// when it is empty.
+	Namespaces []string
+	// Pool adds the subnet to the subnets the pods selecting no subnet are spread across, next to
+	// the subnet of the virtual node.
+	Pool bool
+}
+
+func (c subnetConfig) validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("subnet %s/%s has no name to select it with", c.VNetName, c.SubnetName)
+	}
+	if c.VNetName == "" || c.SubnetName == "" {
+		return fmt.Errorf("subnet %s needs a VNetName and a SubnetName", c.Name)
+	}
+	return nil
+}
+
+// delegatedSubnet is a subnet delegated to ACI that container groups are deployed into.
+type delegatedSubnet struct {
+	name           string
+	id             string
+	cidr           string
+	namespaces     []string
+	subscriptionID string
+	resourceGroup  string
+	vnetName       string
+}
+
+func (s *delegatedSubnet) allows(namespace string) bool {
+	if len(s.namespaces) == 0 {
+		return true
+	}
+	for _, ns := range s.namespaces {
+		if ns == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+func getSubnetID(subscriptionID, resourceGroup, vnetName, subnetName string) string {
+	return "/subscriptions/" + subscriptionID + "/resourceGroups/" + resourceGroup + "/providers/Microsoft.Network/virtualNetworks/" + vnetName + "/subnets/" + subnetName
+}
+
+// isDelegatedToACI reports whether container groups can be deployed into the subnet.
+func isDelegatedToACI(subnet aznetwork.Subnet) bool {
+	if subnet.SubnetPropertiesFormat == nil {
+		return false
+	}
+	if subnet.ServiceAssociationLinks != nil {
+		for _, l := range *subnet.ServiceAssociationLinks {
+			if l.ServiceAssociationLinkPropertiesFormat != nil && l.LinkedResourceType != nil && *l.LinkedResourceType == subnetDelegationService {
+				return true
+			}
+		}
+	}
+	if subnet.Delegations != nil {
+		for _, d := range *subnet.Delegations {
+			if d.ServiceDelegationPropertiesFormat != nil && d.ServiceName != nil && *d.ServiceName == subnetDelegationService {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// setupSubnets checks that the subnets pods can select exist and are delegated to ACI. Unlike the
+// subnet of the virtual node, they are never created or delegated by the provider.
+func (p *ACIProvider) setupSubnets(ctx context.Context, azConfig *auth.Config) error {
+	p.subnets = make(map[string]*delegatedSubnet, len(p.subnetConfigs))
+	pool := []*delegatedSubnet{p.nodeSubnet()}
+	for _, c := range p.subnetConfigs {
+		subscriptionID := c.VNetSubscriptionID
+		if subscriptionID == "" {
+			subscriptionID = p.vnetSubscriptionID
+		}
+		resourceGroup := c.VNetResourceGroup
+		if resourceGroup == "" {
+			resourceGroup = p.vnetResourceGroup
+		}
+
+		client := aznetwork.NewSubnetsClient(subscriptionID)
+		client.Authorizer = azConfig.Authorizer
+		subnet, err := client.Get(ctx, resourceGroup, c.VNetName, c.SubnetName, "")
+		if err != nil {
+			return fmt.Errorf("error while looking up subnet %s: %v", c.Name, err)
+		}
+		if !isDelegatedToACI(subnet) {
+			return errcodes.Errorf(errcodes.SubnetNotDelegated, "subnet %s '%s' in vnet '%s' is not delegated to %s", c.Name, c.SubnetName, c.VNetName, subnetDelegationService)
+		}
+
+		s := &delegatedSubnet{
+			name:           c.Name,
+			id:             getSubnetID(subscriptionID, resourceGroup, c.VNetName, c.SubnetName),
+			namespaces:     c.Namespaces,
+			subscriptionID: subscriptionID,
+			resourceGroup:  resourceGroup,
+			vnetName:       c.VNetName,
+		}
+		if subnet.AddressPrefix != nil {
+			s.cidr = *subnet.AddressPrefix
+		}
+		p.subnets[c.Name] = s
+		if c.Pool {
+			pool = append(pool, s)
+		}
+	}
+
+	if len(pool) > 1 {
+		p.subnetPool = newSubnetPool(newSubnetUsageLister(azConfig.Authorizer), pool)
+	}
+	return nil
+}
+
+// nodeSubnet returns the subnet of the virtual node.
+func (p *ACIProvider) nodeSubnet() *delegatedSubnet {
+	return &delegatedSubnet{
+		name:           p.subnetName,
+		id:             getSubnetID(p.vnetSubscriptionID, p.vnetResourceGroup, p.vnetName, p.subnetName),
+		cidr:           p.subnetCIDR,
+		subscriptionID: p.vnetSubscriptionID,
+		resourceGroup:  p.vnetResourceGroup,
+		vnetName:       p.vnetName,
+	}
+}
+
+// getPodSubnet returns the subnet the container group of the pod is deployed into, the one selected
+// by its annotation, otherwise a subnet of the pool or the subnet of the virtual node. It is nil
+// when the node has no subnet. A subnet of the pool is assigned to the pod until it is released.
+func (p *ACIProvider) getPodSubnet(ctx context.Context, pod *v1.Pod) (*delegatedSubnet, error) {
+	name, ok := pod.Annotations[subnetAnnotation]
+	if !ok {
+		if p.subnetName == "" {
+			return nil, nil
+		}
+		if p.subnetPool == nil {
+			return p.nodeSubnet(), nil
+		}
+		ip, _, err := p.wantedPrivateIP(pod)
+		if err != nil {
+			return nil, err
+		}
+		return p.subnetPool.pick(ctx, pod, ip)
+	}
+
+	subnet, ok := p.subnets[name]
+	if !ok {
+		return nil, errcodes.Wrap(errcodes.SubnetNotAvailable, errdefs.InvalidInputf("annotation %s selects the subnet %q, which is not configured on the virtual node", subnetAnnotation, name))
+	}
+	if !subnet.allows(pod.Namespace) {
+		return nil, errcodes.Wrap(errcodes.SubnetNotAvailable, errdefs.InvalidInputf("subnet %q is not available to the pods of namespace %s", name, pod.Namespace))
+	}
+	return subnet, nil
+}
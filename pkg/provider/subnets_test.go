@@ -0,0 +1,77 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"testing"
+
+	aznetwork "github.com/Azure/azure-sdk-for-go/services/network/mgmt/2021-05-01/network"
+	testsutil "github.com/virtual-kubelet/azure-aci/pkg/tests"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestGetPodSubnet(t *testing.T) {
+	p := &ACIProvider{
+		vnetSubscriptionID: "sub",
+		vnetResourceGroup:  "rg",
+		vnetName:           "vnet",
+		subnetName:         "aci",
+		subnetCIDR:         "10.240.0.0/24",
+		subnets: map[string]*delegatedSubnet{
+			"isolated": {name: "isolated", id: getSubnetID("sub", "rg", "vnet", "team-a"), cidr: "10.241.0.0/24", namespaces: []string{"team-a"}},
+			"shared":   {name: "shared", id: getSubnetID("sub", "rg", "vnet", "shared"), cidr: "10.242.0.0/24"},
+		},
+	}
+
+	pod := testsutil.CreatePodObj("pod", "team-a")
+	subnet, err := p.getPodSubnet(context.Background(), pod)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Network/virtualNetworks/vnet/subnets/aci", subnet.id), "pods without annotation should use the subnet of the node")
+	assert.Check(t, is.Equal("10.240.0.0/24", subnet.cidr))
+
+	pod.Annotations = map[string]string{subnetAnnotation: "isolated"}
+	subnet, err = p.getPodSubnet(context.Background(), pod)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("isolated", subnet.name))
+
+	other := testsutil.CreatePodObj("pod", "team-b")
+	other.Annotations = map[string]string{subnetAnnotation: "isolated"}
+	_, err = p.getPodSubnet(context.Background(), other)
+	assert.Check(t, errdefs.IsInvalidInput(err), "subnets restricted to other namespaces should be rejected")
+
+	other.Annotations[subnetAnnotation] = "shared"
+	subnet, err = p.getPodSubnet(context.Background(), other)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("shared", subnet.name))
+
+	other.Annotations[subnetAnnotation] = "unknown"
+	_, err = p.getPodSubnet(context.Background(), other)
+	assert.Check(t, errdefs.IsInvalidInput(err))
+
+	subnet, err = (&ACIProvider{}).getPodSubnet(context.Background(), testsutil.CreatePodObj("pod", "ns"))
+	assert.NilError(t, err)
+	assert.Check(t, subnet == nil, "nodes without subnet should not deploy into a virtual network")
+}
+
+func TestIsDelegatedToACI(t *testing.T) {
+	service := subnetDelegationService
+	other := "Microsoft.Web/serverFarms"
+	delegated := func(name string) aznetwork.Subnet {
+		return aznetwork.Subnet{SubnetPropertiesFormat: &aznetwork.SubnetPropertiesFormat{
+			Delegations: &[]aznetwork.Delegation{{ServiceDelegationPropertiesFormat: &aznetwork.ServiceDelegationPropertiesFormat{ServiceName: &name}}},
+		}}
+	}
+	assert.Check(t, isDelegatedToACI(delegated(service)))
+	assert.Check(t, !isDelegatedToACI(delegated(other)))
+	assert.Check(t, !isDelegatedToACI(aznetwork.Subnet{}))
+
+	linked := aznetwork.Subnet{SubnetPropertiesFormat: &aznetwork.SubnetPropertiesFormat{
+		ServiceAssociationLinks: &[]aznetwork.ServiceAssociationLink{{ServiceAssociationLinkPropertiesFormat: &aznetwork.ServiceAssociationLinkPropertiesFormat{LinkedResourceType: &service}}},
+	}}
+	assert.Check(t, isDelegatedToACI(linked))
+}
@@ -0,0 +1,146 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	client2 "github.com/virtual-kubelet/azure-aci/pkg/client"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	v1 "k8s.io/api/core/v1"
+)
+
+// identityTags are the tags matching a container group to its pod. A container group whose value
+// differs from the pod belongs to another pod, or another node, and is never migrated.
+var identityTags = map[string]bool{
+	"PodName":           true,
+	"Namespace":         true,
+	"NodeName":          true,
+	"UID":               true,
+	"CreationTimestamp": true,
+}
+
+// podTags returns the tags identifying the container group of a pod, in the current tag schema.
+func podTags(pod *v1.Pod) map[string]*string {
+	podUID := string(pod.UID)
+	podCreationTimestamp := pod.CreationTimestamp.String()
+	return map[string]*string{
+		"PodName":           &pod.Name,
+		"ClusterName":       &pod.ClusterName,
+		"NodeName":          &pod.Spec.NodeName,
+		"Namespace":         &pod.Namespace,
+		"UID":               &podUID,
+		"CreationTimestamp": &podCreationTimestamp,
+	}
+}
+
+// tagMigration describes the tags added to a container group, or why it was left unchanged.
+type tagMigration struct {
+	Name    string            `json:"name"`
+	Pod     string            `json:"pod"`
+	Added   map[string]string `json:"added,omitempty"`
+	Skipped string            `json:"skipped,omitempty"`
+	Error   string            `json:"error,omitempty"`
+}
+
+// tagMigrationResult lists the container groups of the node that were not in the current tag schema.
+type tagMigrationResult struct {
+	DryRun          bool           `json:"dryRun"`
+	Current         int            `json:"current"`
+	ContainerGroups []tagMigration `json:"containerGroups"`
+}
+
+// migrateTags brings the tags of the container groups of the pods of the node to the current schema,
+// e.g. the UID tag missing on container groups created by older versions, which hides them from the
+// UID checks, or the NodeName tag, which hides them from the node. Only missing tags are added:
+// container groups whose tags name another pod or node are reported and left unchanged, as are the
+// container groups without a pod on the node, which cannot be attributed safely. A dry run only
+// reports the tags it would add.
+func (p *ACIProvider) migrateTags(ctx context.Context, dryRun bool) (*tagMigrationResult, error) {
+	cgs, err := p.azClientsAPIs.GetContainerGroupListResult(ctx, p.resourceGroup)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]azaci.ContainerGroup)
+	if cgs != nil {
+		for _, cg := range *cgs {
+			if cg.Name != nil {
+				byName[*cg.Name] = cg
+			}
+		}
+	}
+
+	result := &tagMigrationResult{DryRun: dryRun, ContainerGroups: make([]tagMigration, 0)}
+	for _, pod := range p.resourceManager.GetPods() {
+		cgName := client2.ContainerGroupName(pod.Namespace, pod.Name)
+		cg, ok := byName[cgName]
+		if !ok {
+			continue
+		}
+		migration := tagMigration{Name: cgName, Pod: pod.Namespace + "/" + pod.Name}
+		added, conflict := missingPodTags(cg.Tags, podTags(pod))
+		switch {
+		case conflict != "":
+			migration.Skipped = conflict
+		case len(added) == 0:
+			result.Current++
+			continue
+		case len(cg.Tags)+len(added) > maxTagsPerResource:
+			migration.Skipped = fmt.Sprintf("the container group would have %d tags, but Azure allows at most %d", len(cg.Tags)+len(added), maxTagsPerResource)
+		default:
+			migration.Added = added
+			if !dryRun {
+				if err := p.updateTags(ctx, pod.Namespace, cgName, cg.Tags, added); err != nil {
+					migration.Error = err.Error()
+				}
+			}
+		}
+		result.ContainerGroups = append(result.ContainerGroups, migration)
+	}
+	sort.Slice(result.ContainerGroups, func(i, j int) bool {
+		return result.ContainerGroups[i].Name < result.ContainerGroups[j].Name
+	})
+	return result, nil
+}
+
+// missingPodTags returns the tags of the pod missing on the container group, or why the container
+// group does not belong to the pod.
+func missingPodTags(current, want map[string]*string) (map[string]string, string) {
+	added := make(map[string]string)
+	for name, value := range want {
+		if value == nil || *value == "" {
+			continue
+		}
+		existing := current[name]
+		if existing == nil || *existing == "" {
+			added[name] = *value
+			continue
+		}
+		if identityTags[name] && *existing != *value {
+			return nil, fmt.Sprintf("tag %s is %q, the pod has %q", name, *existing, *value)
+		}
+	}
+	return added, ""
+}
+
+func (p *ACIProvider) updateTags(ctx context.Context, namespace, cgName string, current map[string]*string, added map[string]string) error {
+	tags := make(map[string]*string, len(current)+len(added))
+	for name, value := range current {
+		tags[name] = value
+	}
+	for name, value := range added {
+		value := value
+		tags[name] = &value
+	}
+	if err := p.azClientsAPIs.UpdateContainerGroupTags(withARMNamespace(ctx, namespace), p.resourceGroup, cgName, tags); err != nil {
+		log.G(ctx).WithError(err).Warnf("failed to migrate the tags of container group %s", cgName)
+		return err
+	}
+	log.G(ctx).Infof("migrated the tags of container group %s, added %v", cgName, added)
+	return nil
+}
@@ -0,0 +1,117 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	"github.com/golang/mock/gomock"
+	"github.com/virtual-kubelet/node-cli/manager"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestMigrateTags(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	created := metav1.NewTime(time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC))
+	newPod := func(name string) *v1.Pod {
+		return &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns", UID: types.UID(name + "-uid"), CreationTimestamp: created},
+			Spec:       v1.PodSpec{NodeName: "vk"},
+		}
+	}
+	pods := []*v1.Pod{newPod("web"), newPod("api"), newPod("job"), newPod("new"), newPod("full")}
+
+	// web was created before the UID and CreationTimestamp tags, job belongs to an older pod of the
+	// same name and full has no room left for tags.
+	str := func(s string) *string { return &s }
+	web := azaci.ContainerGroup{Name: str("ns-web"), Tags: map[string]*string{"PodName": str("web"), "Namespace": str("ns"), "NodeName": str("vk")}}
+	api := azaci.ContainerGroup{Name: str("ns-api"), Tags: podTags(pods[1])}
+	job := azaci.ContainerGroup{Name: str("ns-job"), Tags: map[string]*string{"PodName": str("job"), "Namespace": str("ns"), "UID": str("old-uid")}}
+	full := azaci.ContainerGroup{Name: str("ns-full"), Tags: map[string]*string{}}
+	for i := 0; i < maxTagsPerResource; i++ {
+		full.Tags[string(rune('a'+i%26))+string(rune('a'+i/26))] = str("x")
+	}
+	orphan := azaci.ContainerGroup{Name: str("ns-gone"), Tags: map[string]*string{"PodName": str("gone")}}
+
+	updated := make(map[string]map[string]*string)
+	aciMocks := createNewACIMock()
+	aciMocks.MockGetContainerGroupList = func(ctx context.Context, resourceGroup string) (*[]azaci.ContainerGroup, error) {
+		return &[]azaci.ContainerGroup{web, api, job, full, orphan}, nil
+	}
+	aciMocks.MockUpdateContainerGroupTags = func(ctx context.Context, resourceGroup, cgName string, tags map[string]*string) error {
+		updated[cgName] = tags
+		return nil
+	}
+
+	podLister := NewMockPodLister(mockCtrl)
+	podLister.EXPECT().List(labels.Everything()).Return(pods, nil).AnyTimes()
+	rm, err := manager.NewResourceManager(podLister, nil, nil, newServiceLister(), nil, nil)
+	if err != nil {
+		t.Fatal("Unable to prepare the mocks for resourceManager", err)
+	}
+	p := &ACIProvider{azClientsAPIs: aciMocks, resourceManager: rm, nodeName: "vk"}
+
+	result, err := p.migrateTags(context.Background(), true)
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(updated, 0), "a dry run should not update the tags")
+	assert.Check(t, result.DryRun)
+	assert.Check(t, is.Equal(1, result.Current))
+	assert.Assert(t, is.Len(result.ContainerGroups, 3))
+	assert.Check(t, is.Equal("ns-full", result.ContainerGroups[0].Name))
+	assert.Check(t, is.Contains(result.ContainerGroups[0].Skipped, "Azure allows at most 50"))
+	assert.Check(t, is.Equal("ns-job", result.ContainerGroups[1].Name))
+	assert.Check(t, is.Equal(`tag UID is "old-uid", the pod has "job-uid"`, result.ContainerGroups[1].Skipped))
+	assert.Check(t, is.Equal("ns-web", result.ContainerGroups[2].Name))
+	assert.Check(t, is.DeepEqual(map[string]string{"UID": "web-uid", "CreationTimestamp": created.String()}, result.ContainerGroups[2].Added))
+
+	result, err = p.migrateTags(context.Background(), false)
+	assert.NilError(t, err)
+	assert.Assert(t, is.Len(updated, 1), "only the container groups of the pods with missing tags should be updated")
+	tags := updated["ns-web"]
+	assert.Check(t, is.Equal("web-uid", *tags["UID"]))
+	assert.Check(t, is.Equal("web", *tags["PodName"]), "existing tags should be kept")
+
+	aciMocks.MockUpdateContainerGroupTags = func(ctx context.Context, resourceGroup, cgName string, tags map[string]*string) error {
+		return errors.New("throttled")
+	}
+	result, err = p.migrateTags(context.Background(), false)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("throttled", result.ContainerGroups[2].Error))
+
+	handler := p.AdminHandler("s3cret")
+	rec := adminRequest(handler, http.MethodPost, "/tags/migrate?dryRun=true", "s3cret")
+	assert.Check(t, is.Equal(http.StatusOK, rec.Code))
+	var body tagMigrationResult
+	assert.NilError(t, json.NewDecoder(rec.Body).Decode(&body))
+	assert.Check(t, body.DryRun)
+	assert.Check(t, is.Len(body.ContainerGroups, 3))
+	rec = adminRequest(handler, http.MethodPost, "/tags/migrate?dryRun=maybe", "s3cret")
+	assert.Check(t, is.Equal(http.StatusBadRequest, rec.Code))
+}
+
+func TestPodTagsMatchCreatedContainerGroups(t *testing.T) {
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "ns", UID: "uid"}, Spec: v1.PodSpec{NodeName: "vk"}}
+	added, conflict := missingPodTags(podTags(pod), podTags(pod))
+	assert.Check(t, is.Len(added, 0))
+	assert.Check(t, is.Equal("", conflict))
+
+	otherNode := "other-vk"
+	other := map[string]*string{"NodeName": &otherNode}
+	_, conflict = missingPodTags(other, podTags(pod))
+	assert.Check(t, is.Equal(`tag NodeName is "other-vk", the pod has "vk"`, conflict))
+}
@@ -0,0 +1,82 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"fmt"
+
+	"github.com/virtual-kubelet/azure-aci/pkg/errcodes"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// virtualNodeLimitationsURL documents the pod features ACI does not support.
+const virtualNodeLimitationsURL = "https://learn.microsoft.com/azure/aks/virtual-nodes#known-limitations"
+
+func unsupportedField(path *field.Path, detail string) *field.Error {
+	return field.Forbidden(path, fmt.Sprintf("%s, see %s", detail, virtualNodeLimitationsURL))
+}
+
+// getUnsupportedFields returns every field of the pod spec ACI can never honor. Without strict
+// validation these fields are silently dropped from the container group.
+func (p *ACIProvider) getUnsupportedFields(pod *v1.Pod) field.ErrorList {
+	var errs field.ErrorList
+	spec := field.NewPath("spec")
+
+	if pod.Spec.HostNetwork {
+		errs = append(errs, unsupportedField(spec.Child("hostNetwork"), "container groups do not share the network namespace of a host"))
+	}
+	if pod.Spec.HostPID {
+		errs = append(errs, unsupportedField(spec.Child("hostPID"), "container groups do not share the PID namespace of a host"))
+	}
+	if pod.Spec.HostIPC {
+		errs = append(errs, unsupportedField(spec.Child("hostIPC"), "container groups do not share the IPC namespace of a host"))
+	}
+	if pod.Spec.ShareProcessNamespace != nil && *pod.Spec.ShareProcessNamespace {
+		errs = append(errs, unsupportedField(spec.Child("shareProcessNamespace"), "containers of a container group do not share a PID namespace"))
+	}
+	if len(pod.Spec.HostAliases) != 0 {
+		errs = append(errs, unsupportedField(spec.Child("hostAliases"), "the hosts file of ACI containers cannot be customized"))
+	}
+
+	for i, volume := range pod.Spec.Volumes {
+		if volume.HostPath != nil {
+			errs = append(errs, unsupportedField(spec.Child("volumes").Index(i).Child("hostPath"), "ACI hosts are not accessible to containers"))
+		}
+	}
+
+	checkContainer := func(path *field.Path, container v1.Container) {
+		if !p.allowPrivilegedContainers && container.SecurityContext != nil &&
+			container.SecurityContext.Privileged != nil && *container.SecurityContext.Privileged {
+			errs = append(errs, unsupportedField(path.Child("securityContext", "privileged"), "ACI does not run privileged containers"))
+		}
+		for i, port := range container.Ports {
+			if port.HostPort != 0 {
+				errs = append(errs, unsupportedField(path.Child("ports").Index(i).Child("hostPort"), "ACI hosts do not expose ports, use the container port"))
+			}
+		}
+	}
+	for i, container := range pod.Spec.InitContainers {
+		checkContainer(spec.Child("initContainers").Index(i), container)
+	}
+	for i, container := range pod.Spec.Containers {
+		checkContainer(spec.Child("containers").Index(i), container)
+	}
+
+	return errs
+}
+
+// validateSupportedFields rejects pods using fields ACI will never support, listing all of them
+// at once so they can be fixed in a single pass.
+func (p *ACIProvider) validateSupportedFields(pod *v1.Pod) error {
+	if !p.strictPodValidation {
+		return nil
+	}
+	if errs := p.getUnsupportedFields(pod); len(errs) != 0 {
+		return errcodes.Wrap(errcodes.UnsupportedFields, errdefs.InvalidInputf("pod %s uses fields not supported by ACI: %v", pod.Name, errs.ToAggregate()))
+	}
+	return nil
+}
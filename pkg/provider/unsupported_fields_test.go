@@ -0,0 +1,42 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"strings"
+	"testing"
+
+	testsutil "github.com/virtual-kubelet/azure-aci/pkg/tests"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestValidateSupportedFields(t *testing.T) {
+	privileged := true
+	pod := testsutil.CreatePodObj("pod", "ns")
+	pod.Spec.HostNetwork = true
+	pod.Spec.HostPID = true
+	pod.Spec.Containers[0].SecurityContext = &v1.SecurityContext{Privileged: &privileged}
+	pod.Spec.Containers[0].Ports = []v1.ContainerPort{{ContainerPort: 80, HostPort: 8080}}
+	pod.Spec.Volumes = []v1.Volume{{Name: "host", VolumeSource: v1.VolumeSource{HostPath: &v1.HostPathVolumeSource{Path: "/var/log"}}}}
+
+	p := &ACIProvider{}
+	assert.NilError(t, p.validateSupportedFields(pod), "fields should not be validated unless strict validation is enabled")
+
+	p.strictPodValidation = true
+	err := p.validateSupportedFields(pod)
+	assert.Check(t, errdefs.IsInvalidInput(err))
+	for _, path := range []string{"spec.hostNetwork", "spec.hostPID", "spec.containers[0].securityContext.privileged", "spec.containers[0].ports[0].hostPort", "spec.volumes[0].hostPath"} {
+		assert.Check(t, strings.Contains(err.Error(), path), "the error should list %s: %v", path, err)
+	}
+	assert.Check(t, strings.Contains(err.Error(), virtualNodeLimitationsURL))
+
+	p.allowPrivilegedContainers = true
+	assert.Check(t, is.Len(p.getUnsupportedFields(pod), 4), "privileged containers should be allowed by the flag")
+
+	assert.NilError(t, p.validateSupportedFields(testsutil.CreatePodObj("pod", "ns")))
+}
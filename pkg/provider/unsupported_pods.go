@@ -0,0 +1,110 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/virtual-kubelet/azure-aci/pkg/errcodes"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	unsupportedPodPolicyReject = "Reject"
+	unsupportedPodPolicyIgnore = "Ignore"
+
+	podStatusReasonUnsupported = "UnsupportedPod"
+)
+
+// getUnsupportedPodReason returns why a pod can never run on ACI, or an empty string.
+// DaemonSet pods and pods of the configured namespaces usually land on the virtual node
+// because a toleration or taint was forgotten.
+func (p *ACIProvider) getUnsupportedPodReason(pod *v1.Pod) string {
+	for _, ref := range pod.OwnerReferences {
+		if ref.Kind == "DaemonSet" {
+			return fmt.Sprintf("pod is owned by DaemonSet %s", ref.Name)
+		}
+	}
+	for _, ns := range p.unsupportedPodNamespaces {
+		if pod.Namespace == ns {
+			return fmt.Sprintf("pods of namespace %s are not run on ACI", ns)
+		}
+	}
+	return ""
+}
+
+// handleUnsupportedPod applies the unsupported pod policy. It reports whether the pod was handled,
+// in which case no container group must be created. CreatePod is only called once for a pod that
+// succeeds, so both policies emit a single event instead of failing creations repeatedly.
+//
+// Rejected pods are failed, so their controllers see them fail. Ignored pods stay Pending with the
+// UnsupportedPod reason and are no longer tracked, as a DaemonSet would recreate failed pods in a
+// loop. The pod controller creates the pods again when the provider restarts, so the reason of
+// ignored pods the policy or the namespaces no longer match is cleared and they are tracked again.
+func (p *ACIProvider) handleUnsupportedPod(ctx context.Context, pod *v1.Pod) (bool, error) {
+	reason := ""
+	if p.unsupportedPodPolicy != "" {
+		reason = p.getUnsupportedPodReason(pod)
+	}
+	if reason == "" {
+		p.clearUnsupportedPodReason(ctx, pod)
+		return false, nil
+	}
+
+	switch p.unsupportedPodPolicy {
+	case unsupportedPodPolicyIgnore:
+		log.G(ctx).Infof("ignoring pod %s/%s: %s", pod.Namespace, pod.Name, reason)
+		p.recordEvent(pod, v1.EventTypeNormal, podStatusReasonUnsupported, "Pod is ignored by the virtual node: %s", reason)
+		tracker := p.podsTracker()
+		if tracker == nil {
+			return true, nil
+		}
+		return true, tracker.UpdatePodStatus(ctx, pod.Namespace, pod.Name, func(status *v1.PodStatus) {
+			status.Phase = v1.PodPending
+			status.Reason = podStatusReasonUnsupported
+			status.Message = reason
+		}, false)
+
+	case unsupportedPodPolicyReject:
+		log.G(ctx).Infof("rejecting pod %s/%s: %s", pod.Namespace, pod.Name, reason)
+		p.recordEvent(pod, v1.EventTypeWarning, podStatusReasonUnsupported, "Pod is rejected by the virtual node: %s", reason)
+		tracker := p.podsTracker()
+		if tracker == nil {
+			return true, errcodes.Wrap(errcodes.UnsupportedPod, errdefs.InvalidInput(reason))
+		}
+		return true, tracker.UpdatePodStatus(ctx, pod.Namespace, pod.Name, func(status *v1.PodStatus) {
+			status.Phase = v1.PodFailed
+			status.Reason = podStatusReasonUnsupported
+			status.Message = reason
+		}, false)
+	}
+
+	return false, nil
+}
+
+// clearUnsupportedPodReason clears the UnsupportedPod reason of a pod that was ignored, so its
+// status is updated again once its container group is created.
+func (p *ACIProvider) clearUnsupportedPodReason(ctx context.Context, pod *v1.Pod) {
+	if pod.Status.Reason != podStatusReasonUnsupported {
+		return
+	}
+	tracker := p.podsTracker()
+	if tracker == nil {
+		return
+	}
+	log.G(ctx).Infof("pod %s/%s is no longer ignored", pod.Namespace, pod.Name)
+	err := tracker.UpdatePodStatus(ctx, pod.Namespace, pod.Name, func(status *v1.PodStatus) {
+		if status.Reason == podStatusReasonUnsupported {
+			status.Reason = ""
+			status.Message = ""
+		}
+	}, false)
+	if err != nil {
+		log.G(ctx).WithError(err).Warnf("failed to clear the %s reason of pod %s/%s", podStatusReasonUnsupported, pod.Namespace, pod.Name)
+	}
+}
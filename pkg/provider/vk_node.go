@@ -7,7 +7,9 @@ package provider
 import (
 	"context"
 	"os"
+	"time"
 
+	"github.com/virtual-kubelet/virtual-kubelet/log"
 	"github.com/virtual-kubelet/virtual-kubelet/trace"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
@@ -28,6 +30,69 @@ func (p *ACIProvider) ConfigureNode(ctx context.Context, node *v1.Node) {
 
 	// Virtual node would be skipped for cloud provider operations (e.g. CP should not add route).
 	node.ObjectMeta.Labels["kubernetes.azure.com/managed"] = "false"
+
+	// Let Windows pods select the virtual node by the build their images are made for.
+	if p.isWindows() && p.windowsVersion != "" {
+		node.ObjectMeta.Labels[windowsBuildLabel] = windowsBuilds[p.windowsVersion]
+	}
+	applyLabels(node, p.getGPUSKULabels(node.ObjectMeta.Labels))
+
+	p.nodeMutex.Lock()
+	p.node = node.DeepCopy()
+	p.nodeMutex.Unlock()
+}
+
+// NotifyNodeStatus periodically re-evaluates the node conditions and pushes the node
+// to Kubernetes whenever the readiness derived from the ACI API health and the outage policy changes.
+func (p *ACIProvider) NotifyNodeStatus(ctx context.Context, notifierCb func(*v1.Node)) {
+	go func() {
+		ticker := time.NewTicker(p.nodeStatusUpdateInterval)
+		defer ticker.Stop()
+		capabilityRefreshInterval := p.capabilityRefreshInterval
+		if capabilityRefreshInterval <= 0 {
+			capabilityRefreshInterval = defaultCapabilityRefreshInterval
+		}
+		capabilityTicker := time.NewTicker(capabilityRefreshInterval)
+		defer capabilityTicker.Stop()
+		advisoryTicker := time.NewTicker(advisoryRefreshInterval)
+		defer advisoryTicker.Stop()
+
+		lastReady := v1.ConditionTrue
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-capabilityTicker.C:
+				if p.refreshGPUSKUs(ctx) {
+					p.updateNodeGPUSKUs(ctx, notifierCb)
+				}
+				continue
+			case <-advisoryTicker.C:
+				p.refreshAdvisories(ctx)
+				continue
+			case <-ticker.C:
+			}
+
+			p.nodeMutex.Lock()
+			if p.node == nil {
+				p.nodeMutex.Unlock()
+				continue
+			}
+			node := p.node.DeepCopy()
+			p.nodeMutex.Unlock()
+
+			conditions := p.nodeConditions()
+			ready := conditions[0].Status
+			if ready == lastReady {
+				continue
+			}
+			lastReady = ready
+
+			log.G(ctx).Infof("node readiness changed to %s: %s", ready, conditions[0].Message)
+			node.Status.Conditions = conditions
+			notifierCb(node)
+		}
+	}()
 }
 
 // capacity returns a resource list containing the capacity limits set for ACI.
@@ -38,8 +103,11 @@ func (p *ACIProvider) capacity() v1.ResourceList {
 		v1.ResourcePods:   resource.MustParse(p.pods),
 	}
 
-	if p.gpu != "" {
-		resourceList[gpuResourceName] = resource.MustParse(p.gpu)
+	p.gpuMutex.RLock()
+	gpu := p.gpu
+	p.gpuMutex.RUnlock()
+	if gpu != "" {
+		resourceList[gpuResourceName] = resource.MustParse(gpu)
 	}
 
 	return resourceList
@@ -49,15 +117,22 @@ func (p *ACIProvider) capacity() v1.ResourceList {
 // within Kubernetes.
 func (p *ACIProvider) nodeConditions() []v1.NodeCondition {
 	// TODO: Make these dynamic and augment with custom ACI specific conditions of interest
+	readyCondition := v1.NodeCondition{
+		Type:               "Ready",
+		Status:             v1.ConditionTrue,
+		LastHeartbeatTime:  metav1.Now(),
+		LastTransitionTime: metav1.Now(),
+		Reason:             "KubeletReady",
+		Message:            "kubelet is ready.",
+	}
+	if err := p.outage.nodeError(time.Now()); err != nil {
+		readyCondition.Status = v1.ConditionFalse
+		readyCondition.Reason = "ACIAPIDegraded"
+		readyCondition.Message = err.Error()
+	}
+
 	return []v1.NodeCondition{
-		{
-			Type:               "Ready",
-			Status:             v1.ConditionTrue,
-			LastHeartbeatTime:  metav1.Now(),
-			LastTransitionTime: metav1.Now(),
-			Reason:             "KubeletReady",
-			Message:            "kubelet is ready.",
-		},
+		readyCondition,
 		{
 			Type:               "OutOfDisk",
 			Status:             v1.ConditionFalse,
@@ -137,21 +212,9 @@ func (p *ACIProvider) setupNodeCapacity(ctx context.Context) error {
 		p.pods = podsQuota
 	}
 
-	//TODO To be uncommented after Location API fix
-	//capabilities, err := p.azClientsAPIs.ListCapabilities(ctx, p.region)
-	//if err != nil {
-	//	return errors.Wrapf(err, "Unable to fetch the ACI capabilities for the location %s, skipping GPU availability check. GPU capacity will be disabled", p.region)
-	//}
-	//
-	//for _, capability := range *capabilities {
-	//	if strings.EqualFold(*capability.Location, p.region) && *capability.Gpu != "" {
-	//		p.gpu = "100"
-	//		if gpu := os.Getenv("ACI_QUOTA_GPU"); gpu != "" {
-	//			p.gpu = gpu
-	//		}
-	//		p.gpuSKUs = append(p.gpuSKUs, azaci.GpuSku(*capability.Gpu))
-	//	}
-	//}
+	// GPU capacity stays disabled until the capabilities of the region are known, they are
+	// refreshed periodically by NotifyNodeStatus.
+	p.refreshGPUSKUs(ctx)
 
 	return nil
 }
@@ -0,0 +1,162 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	"github.com/virtual-kubelet/node-cli/manager"
+	v1 "k8s.io/api/core/v1"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// VolumeHandler translates a pod volume into an ACI volume. Downstream builds register handlers
+// for custom volume types, e.g. proprietary CSI drivers, with RegisterVolumeHandler.
+type VolumeHandler interface {
+	// Name identifies the handler in logs and errors.
+	Name() string
+	// CanHandle reports whether the handler translates the volume.
+	CanHandle(volume *v1.Volume) bool
+	// GetVolume returns the ACI volume, or nil when there is nothing to mount. The volumes of a pod
+	// are resolved concurrently, so GetVolume must be safe for concurrent use.
+	GetVolume(ctx context.Context, rm *manager.ResourceManager, pod *v1.Pod, volume *v1.Volume) (*azaci.Volume, error)
+}
+
+var (
+	volumeHandlersMutex sync.Mutex
+	volumeHandlers      []VolumeHandler
+)
+
+// RegisterVolumeHandler adds a handler consulted by providers created afterwards. Registered
+// handlers take precedence over the built-in ones, in registration order.
+func RegisterVolumeHandler(handler VolumeHandler) {
+	volumeHandlersMutex.Lock()
+	defer volumeHandlersMutex.Unlock()
+	volumeHandlers = append(volumeHandlers, handler)
+}
+
+// setupVolumeHandlers combines the registered handlers with the built-in reference handlers.
+func (p *ACIProvider) setupVolumeHandlers() {
+	volumeHandlersMutex.Lock()
+	defer volumeHandlersMutex.Unlock()
+
+	p.volumeHandlers = append([]VolumeHandler{}, volumeHandlers...)
+	p.volumeHandlers = append(p.volumeHandlers,
+		&azureFileVolumeHandler{p: p},
+		&secretVolumeHandler{p: p},
+		&configMapVolumeHandler{p: p},
+	)
+}
+
+func (p *ACIProvider) getVolumeHandler(volume *v1.Volume) VolumeHandler {
+	for _, handler := range p.volumeHandlers {
+		if handler.CanHandle(volume) {
+			return handler
+		}
+	}
+	return nil
+}
+
+// azureFileVolumeHandler handles Azure File CSI volumes and legacy in-tree azureFile volumes.
+type azureFileVolumeHandler struct {
+	p *ACIProvider
+}
+
+func (h *azureFileVolumeHandler) Name() string {
+	return "azureFile"
+}
+
+func (h *azureFileVolumeHandler) CanHandle(volume *v1.Volume) bool {
+	return volume.AzureFile != nil || (volume.CSI != nil && volume.CSI.Driver == AzureFileDriverName)
+}
+
+func (h *azureFileVolumeHandler) GetVolume(ctx context.Context, rm *manager.ResourceManager, pod *v1.Pod, volume *v1.Volume) (*azaci.Volume, error) {
+	if volume.AzureFile != nil {
+		// Translate the in-tree volume to the CSI driver.
+		return h.p.getAzureFileCSI(translateInTreeAzureFile(*volume), pod.Namespace)
+	}
+	return h.p.getAzureFileCSI(*volume, pod.Namespace)
+}
+
+// secretVolumeHandler mounts the keys of a secret as an ACI secret volume.
+type secretVolumeHandler struct {
+	p *ACIProvider
+}
+
+func (h *secretVolumeHandler) Name() string {
+	return "secret"
+}
+
+func (h *secretVolumeHandler) CanHandle(volume *v1.Volume) bool {
+	return volume.Secret != nil
+}
+
+func (h *secretVolumeHandler) GetVolume(ctx context.Context, rm *manager.ResourceManager, pod *v1.Pod, volume *v1.Volume) (*azaci.Volume, error) {
+	source := volume.Secret
+	secret, err := rm.GetSecret(source.SecretName, pod.Namespace)
+	if source.Optional != nil && !*source.Optional && k8serr.IsNotFound(err) {
+		return nil, fmt.Errorf("Secret %s is required by Pod %s and does not exist", source.SecretName, pod.Name)
+	}
+	if secret == nil {
+		return nil, nil
+	}
+
+	optional := source.Optional != nil && *source.Optional
+	paths, err := projectKeysToPaths(secretData(secret), source.Items, optional)
+	if err != nil {
+		return nil, fmt.Errorf("secret %s for volume %s of pod %s: %v", source.SecretName, volume.Name, pod.Name, err)
+	}
+	h.p.reportIgnoredFileModes(pod, volume.Name, source.DefaultMode, source.Items)
+
+	if len(paths) == 0 {
+		return nil, nil
+	}
+	return &azaci.Volume{
+		Name:   &volume.Name,
+		Secret: paths,
+	}, nil
+}
+
+// configMapVolumeHandler mounts the keys of a configMap as an ACI secret volume.
+type configMapVolumeHandler struct {
+	p *ACIProvider
+}
+
+func (h *configMapVolumeHandler) Name() string {
+	return "configMap"
+}
+
+func (h *configMapVolumeHandler) CanHandle(volume *v1.Volume) bool {
+	return volume.ConfigMap != nil
+}
+
+func (h *configMapVolumeHandler) GetVolume(ctx context.Context, rm *manager.ResourceManager, pod *v1.Pod, volume *v1.Volume) (*azaci.Volume, error) {
+	source := volume.ConfigMap
+	configMap, err := rm.GetConfigMap(source.Name, pod.Namespace)
+	if source.Optional != nil && !*source.Optional && k8serr.IsNotFound(err) {
+		return nil, fmt.Errorf("ConfigMap %s is required by Pod %s and does not exist", source.Name, pod.Name)
+	}
+	if configMap == nil {
+		return nil, nil
+	}
+
+	optional := source.Optional != nil && *source.Optional
+	paths, err := projectKeysToPaths(configMapData(configMap), source.Items, optional)
+	if err != nil {
+		return nil, fmt.Errorf("configMap %s for volume %s of pod %s: %v", source.Name, volume.Name, pod.Name, err)
+	}
+	h.p.reportIgnoredFileModes(pod, volume.Name, source.DefaultMode, source.Items)
+
+	if len(paths) == 0 {
+		return nil, nil
+	}
+	return &azaci.Volume{
+		Name:   &volume.Name,
+		Secret: paths,
+	}, nil
+}
@@ -0,0 +1,69 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"testing"
+
+	azaci "github.com/Azure/azure-sdk-for-go/services/containerinstance/mgmt/2021-10-01/containerinstance"
+	"github.com/virtual-kubelet/azure-aci/pkg/client"
+	testsutil "github.com/virtual-kubelet/azure-aci/pkg/tests"
+	"github.com/virtual-kubelet/node-cli/manager"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	v1 "k8s.io/api/core/v1"
+)
+
+const fakeCustomDriverName = "scratch.csi.example.com"
+
+type fakeScratchVolumeHandler struct{}
+
+func (h *fakeScratchVolumeHandler) Name() string {
+	return "scratch"
+}
+
+func (h *fakeScratchVolumeHandler) CanHandle(volume *v1.Volume) bool {
+	return volume.CSI != nil && volume.CSI.Driver == fakeCustomDriverName
+}
+
+func (h *fakeScratchVolumeHandler) GetVolume(ctx context.Context, rm *manager.ResourceManager, pod *v1.Pod, volume *v1.Volume) (*azaci.Volume, error) {
+	return &azaci.Volume{
+		Name:     &volume.Name,
+		EmptyDir: map[string]interface{}{},
+	}, nil
+}
+
+func TestCreatePodWithRegisteredVolumeHandler(t *testing.T) {
+	RegisterVolumeHandler(&fakeScratchVolumeHandler{})
+
+	aciMocks := createNewACIMock()
+	aciMocks.MockCreateContainerGroup = func(ctx context.Context, resourceGroup, podNS, podName string, cg *client.ContainerGroupWrapper) error {
+		volumes := *cg.ContainerGroupPropertiesWrapper.ContainerGroupProperties.Volumes
+		assert.Check(t, is.Equal(1, len(volumes)), "volume count not match")
+		assert.Check(t, is.Equal("scratch", *volumes[0].Name), "volume name doesn't match")
+		assert.Check(t, volumes[0].EmptyDir != nil, "custom handler volume expected")
+		return nil
+	}
+
+	provider, err := createTestProvider(aciMocks, nil)
+	if err != nil {
+		t.Fatal("Unable to create test provider", err)
+	}
+
+	pod := testsutil.CreatePodObj(podName, podNamespace)
+	pod.Spec.Volumes = []v1.Volume{
+		{
+			Name: "scratch",
+			VolumeSource: v1.VolumeSource{
+				CSI: &v1.CSIVolumeSource{Driver: fakeCustomDriverName},
+			},
+		},
+	}
+
+	if err := provider.CreatePod(context.Background(), pod); err != nil {
+		t.Fatal("Failed to create pod", err)
+	}
+}
@@ -0,0 +1,38 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"github.com/virtual-kubelet/azure-aci/pkg/errcodes"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	v1 "k8s.io/api/core/v1"
+)
+
+// getGMSAContainer returns the name of the first container asking for a GMSA credential spec,
+// either in its own security context or through the pod one.
+func getGMSAContainer(pod *v1.Pod) (string, bool) {
+	hasGMSA := func(options *v1.WindowsSecurityContextOptions) bool {
+		return options != nil && (options.GMSACredentialSpec != nil || options.GMSACredentialSpecName != nil)
+	}
+
+	podGMSA := pod.Spec.SecurityContext != nil && hasGMSA(pod.Spec.SecurityContext.WindowsOptions)
+	containers := append(append([]v1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...)
+	for _, container := range containers {
+		if podGMSA || (container.SecurityContext != nil && hasGMSA(container.SecurityContext.WindowsOptions)) {
+			return container.Name, true
+		}
+	}
+	return "", false
+}
+
+// validateGMSA rejects pods using a GMSA credential spec. The ACI API has no field to pass a
+// credential spec to the container group, so these pods would run without their AD identity and
+// fail to authenticate at runtime instead of failing at creation.
+func validateGMSA(pod *v1.Pod) error {
+	if name, ok := getGMSAContainer(pod); ok {
+		return errcodes.Wrap(errcodes.GMSANotSupported, errdefs.InvalidInputf("container %s uses a GMSA credential spec, which is not supported by ACI", name))
+	}
+	return nil
+}
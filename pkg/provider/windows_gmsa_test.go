@@ -0,0 +1,32 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"testing"
+
+	testsutil "github.com/virtual-kubelet/azure-aci/pkg/tests"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"gotest.tools/assert"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestValidateGMSA(t *testing.T) {
+	spec := `{"CmsPlugins":["ActiveDirectory"]}`
+
+	pod := testsutil.CreatePodObj("pod", "ns")
+	assert.NilError(t, validateGMSA(pod))
+
+	pod.Spec.Containers[0].SecurityContext = &v1.SecurityContext{
+		WindowsOptions: &v1.WindowsSecurityContextOptions{GMSACredentialSpec: &spec},
+	}
+	assert.Check(t, errdefs.IsInvalidInput(validateGMSA(pod)), "a container credential spec should be rejected")
+
+	pod = testsutil.CreatePodObj("pod", "ns")
+	pod.Spec.SecurityContext = &v1.PodSecurityContext{
+		WindowsOptions: &v1.WindowsSecurityContextOptions{GMSACredentialSpec: &spec},
+	}
+	assert.Check(t, errdefs.IsInvalidInput(validateGMSA(pod)), "a pod credential spec applies to every container")
+}
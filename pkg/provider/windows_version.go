@@ -0,0 +1,96 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"strings"
+
+	"github.com/virtual-kubelet/azure-aci/pkg/errcodes"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	windowsVersionLTSC2019 = "LTSC2019"
+	windowsVersionLTSC2022 = "LTSC2022"
+
+	// windowsVersionAnnotation chooses the Windows version of a pod, overriding the provider config.
+	windowsVersionAnnotation = "virtual-kubelet.io/windows-version"
+	// windowsVersionTag records the Windows version a container group was validated against.
+	windowsVersionTag = "WindowsVersion"
+	// windowsBuildLabel is the well-known node label used to match Windows images to hosts.
+	windowsBuildLabel = "node.kubernetes.io/windows-build"
+)
+
+// windowsBuilds maps the supported Windows versions to their build numbers.
+var windowsBuilds = map[string]string{
+	windowsVersionLTSC2019: "10.0.17763",
+	windowsVersionLTSC2022: "10.0.20348",
+}
+
+// windowsImageTagMarkers are the image tag fragments that tie an image to a Windows version.
+var windowsImageTagMarkers = map[string][]string{
+	windowsVersionLTSC2019: {"ltsc2019", "1809", "17763"},
+	windowsVersionLTSC2022: {"ltsc2022", "20348"},
+}
+
+// normalizeWindowsVersion returns the canonical name of a Windows version, or false when it is
+// not supported.
+func normalizeWindowsVersion(version string) (string, bool) {
+	version = strings.ToUpper(strings.TrimSpace(version))
+	_, ok := windowsBuilds[version]
+	return version, ok
+}
+
+// getWindowsVersion returns the Windows version of the pod, from its annotation or the provider
+// config. It is empty when neither chooses one, in which case ACI picks the host version.
+func (p *ACIProvider) getWindowsVersion(pod *v1.Pod) (string, error) {
+	if value, ok := pod.Annotations[windowsVersionAnnotation]; ok {
+		version, ok := normalizeWindowsVersion(value)
+		if !ok {
+			return "", errdefs.InvalidInputf("annotation %s has invalid value %q, try one of the following instead: %s | %s", windowsVersionAnnotation, value, windowsVersionLTSC2019, windowsVersionLTSC2022)
+		}
+		return version, nil
+	}
+	return p.windowsVersion, nil
+}
+
+// getImageWindowsVersion returns the Windows version an image is built for, guessed from its tag.
+// It is empty when the tag does not name a version, e.g. for multi-arch manifests.
+func getImageWindowsVersion(image string) string {
+	// Images pinned by digest carry no version in their reference.
+	if strings.Contains(image, "@") {
+		return ""
+	}
+	i := strings.LastIndex(image, ":")
+	if i < 0 || strings.Contains(image[i:], "/") {
+		return ""
+	}
+	tag := strings.ToLower(image[i+1:])
+	for version, markers := range windowsImageTagMarkers {
+		for _, marker := range markers {
+			if strings.Contains(tag, marker) {
+				return version
+			}
+		}
+	}
+	return ""
+}
+
+// validateWindowsImages rejects pods with images built for another Windows version. ACI does not
+// let the container group choose its host OS version, so the chosen version is enforced by
+// rejecting images that would not start on it instead of failing on the ACI side after the pull.
+func validateWindowsImages(pod *v1.Pod, version string) error {
+	if version == "" {
+		return nil
+	}
+	containers := append(append([]v1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...)
+	for _, container := range containers {
+		if imageVersion := getImageWindowsVersion(container.Image); imageVersion != "" && imageVersion != version {
+			return errcodes.Wrap(errcodes.IncompatibleWindowsImage, errdefs.InvalidInputf("image %s of container %s is built for Windows %s, but the pod runs on Windows %s", container.Image, container.Name, imageVersion, version))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"testing"
+
+	testsutil "github.com/virtual-kubelet/azure-aci/pkg/tests"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestGetImageWindowsVersion(t *testing.T) {
+	cases := map[string]string{
+		"mcr.microsoft.com/windows/servercore:ltsc2019":                            windowsVersionLTSC2019,
+		"mcr.microsoft.com/windows/nanoserver:1809":                                windowsVersionLTSC2019,
+		"mcr.microsoft.com/dotnet/framework/aspnet:4.8-windowsservercore-ltsc2022": windowsVersionLTSC2022,
+		"myregistry.io:5000/app":                                                   "",
+		"mcr.microsoft.com/windows/servercore@sha256:0123456789abcdef":             "",
+		"nginx": "",
+	}
+	for image, want := range cases {
+		assert.Check(t, is.Equal(want, getImageWindowsVersion(image)), image)
+	}
+}
+
+func TestGetWindowsVersion(t *testing.T) {
+	p := &ACIProvider{operatingSystem: "Windows", windowsVersion: windowsVersionLTSC2019}
+	pod := testsutil.CreatePodObj("pod", "ns")
+
+	version, err := p.getWindowsVersion(pod)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(windowsVersionLTSC2019, version), "the provider version should be the default")
+
+	pod.Annotations = map[string]string{windowsVersionAnnotation: "ltsc2022"}
+	version, err = p.getWindowsVersion(pod)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(windowsVersionLTSC2022, version), "the annotation should override the provider version")
+
+	pod.Annotations[windowsVersionAnnotation] = "2004"
+	_, err = p.getWindowsVersion(pod)
+	assert.Check(t, errdefs.IsInvalidInput(err))
+}
+
+func TestValidateWindowsImages(t *testing.T) {
+	pod := testsutil.CreatePodObj("pod", "ns")
+	pod.Spec.Containers[0].Image = "mcr.microsoft.com/windows/servercore:ltsc2022"
+	pod.Spec.InitContainers = []v1.Container{{Name: "init", Image: "mcr.microsoft.com/windows/nanoserver:1809"}}
+
+	assert.NilError(t, validateWindowsImages(pod, ""), "pods without a chosen version should not be validated")
+	assert.Check(t, errdefs.IsInvalidInput(validateWindowsImages(pod, windowsVersionLTSC2022)), "the init container image is built for LTSC2019")
+
+	pod.Spec.InitContainers[0].Image = "mcr.microsoft.com/windows/nanoserver:ltsc2022"
+	assert.NilError(t, validateWindowsImages(pod, windowsVersionLTSC2022))
+	assert.Check(t, errdefs.IsInvalidInput(validateWindowsImages(pod, windowsVersionLTSC2019)))
+}
@@ -0,0 +1,63 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+
+	client2 "github.com/virtual-kubelet/azure-aci/pkg/client"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	workloadKindTag = "WorkloadKind"
+	workloadNameTag = "WorkloadName"
+)
+
+// getWorkloadOwner walks the controller ownerReferences of the pod up to its top-level controller,
+// e.g. the Deployment of a ReplicaSet or the CronJob of a Job. Owners that cannot be read stop the
+// walk at the last known one. It returns nil for pods without a controller.
+func (p *ACIProvider) getWorkloadOwner(ctx context.Context, pod *v1.Pod) *metav1.OwnerReference {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil || p.kubeClient == nil {
+		return owner
+	}
+
+	var (
+		object metav1.Object
+		err    error
+	)
+	switch owner.Kind {
+	case "ReplicaSet":
+		object, err = p.kubeClient.AppsV1().ReplicaSets(pod.Namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+	case "Job":
+		object, err = p.kubeClient.BatchV1().Jobs(pod.Namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+	default:
+		return owner
+	}
+	if err != nil {
+		log.G(ctx).WithError(err).Warnf("failed to get %s %s owning pod %s, tagging the container group with it", owner.Kind, owner.Name, pod.Name)
+		return owner
+	}
+
+	if parent := metav1.GetControllerOf(object); parent != nil {
+		return parent
+	}
+	return owner
+}
+
+// addWorkloadTags records the top-level controller of the pod in the container group tags, so
+// Azure views and cost tools can aggregate container groups by workload.
+func (p *ACIProvider) addWorkloadTags(ctx context.Context, pod *v1.Pod, cg *client2.ContainerGroupWrapper) {
+	owner := p.getWorkloadOwner(ctx, pod)
+	if owner == nil {
+		return
+	}
+	kind, name := owner.Kind, owner.Name
+	cg.Tags[workloadKindTag] = &kind
+	cg.Tags[workloadNameTag] = &name
+}
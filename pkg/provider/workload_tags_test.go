@@ -0,0 +1,55 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package provider
+
+import (
+	"context"
+	"testing"
+
+	testsutil "github.com/virtual-kubelet/azure-aci/pkg/tests"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func controllerRef(kind, name string) []metav1.OwnerReference {
+	isController := true
+	return []metav1.OwnerReference{{Kind: kind, Name: name, Controller: &isController}}
+}
+
+func TestGetWorkloadOwner(t *testing.T) {
+	p := &ACIProvider{}
+	pod := testsutil.CreatePodObj("pod", "ns")
+	assert.Check(t, p.getWorkloadOwner(context.Background(), pod) == nil, "a bare pod has no workload")
+
+	pod.OwnerReferences = controllerRef("ReplicaSet", "web-7d9f")
+	owner := p.getWorkloadOwner(context.Background(), pod)
+	assert.Assert(t, owner != nil)
+	assert.Check(t, is.Equal("ReplicaSet", owner.Kind), "the direct owner is used without a kube client")
+
+	p.kubeClient = fake.NewSimpleClientset(
+		&appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{Name: "web-7d9f", Namespace: "ns", OwnerReferences: controllerRef("Deployment", "web")}},
+		&batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "report-27", Namespace: "ns", OwnerReferences: controllerRef("CronJob", "report")}},
+	)
+	owner = p.getWorkloadOwner(context.Background(), pod)
+	assert.Check(t, is.Equal("Deployment", owner.Kind))
+	assert.Check(t, is.Equal("web", owner.Name))
+
+	pod.OwnerReferences = controllerRef("Job", "report-27")
+	owner = p.getWorkloadOwner(context.Background(), pod)
+	assert.Check(t, is.Equal("CronJob", owner.Kind))
+	assert.Check(t, is.Equal("report", owner.Name))
+
+	pod.OwnerReferences = controllerRef("StatefulSet", "db")
+	owner = p.getWorkloadOwner(context.Background(), pod)
+	assert.Check(t, is.Equal("StatefulSet", owner.Kind))
+
+	pod.OwnerReferences = controllerRef("ReplicaSet", "missing")
+	owner = p.getWorkloadOwner(context.Background(), pod)
+	assert.Check(t, is.Equal("missing", owner.Name), "an owner that cannot be read should stop the walk")
+}
@@ -0,0 +1,234 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+
+// Package secrets abstracts where the virtual node reads its own credentials from, e.g. the service
+// principal secret, the Log Analytics key or registry passwords, so a deployment can keep them in
+// environment variables, mounted files, a Kubernetes secret or Azure Key Vault.
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-sdk-for-go/services/keyvault/v7.1/keyvault"
+	"github.com/Azure/go-autorest/autorest"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ErrNotFound is returned when a provider has no secret with the requested name.
+var ErrNotFound = errors.New("secret not found")
+
+// IsNotFound reports whether the error means the secret does not exist.
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound)
+}
+
+// Provider retrieves secret values by name.
+type Provider interface {
+	GetSecret(ctx context.Context, name string) (string, error)
+}
+
+// Env reads secrets from the environment variables with the same name.
+type Env struct{}
+
+func (Env) GetSecret(ctx context.Context, name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok || value == "" {
+		return "", fmt.Errorf("environment variable %s: %w", name, ErrNotFound)
+	}
+	return value, nil
+}
+
+// File reads secrets from the files with the same name in a directory, e.g. a mounted secret.
+type File struct {
+	Dir string
+}
+
+func (f File) GetSecret(ctx context.Context, name string) (string, error) {
+	if strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("invalid secret name %q", name)
+	}
+	b, err := os.ReadFile(filepath.Join(f.Dir, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("file %s in %s: %w", name, f.Dir, ErrNotFound)
+		}
+		return "", err
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
+// Kubernetes reads secrets from the keys of a Kubernetes secret.
+type Kubernetes struct {
+	Client    kubernetes.Interface
+	Namespace string
+	Name      string
+}
+
+func (k Kubernetes) GetSecret(ctx context.Context, name string) (string, error) {
+	secret, err := k.Client.CoreV1().Secrets(k.Namespace).Get(ctx, k.Name, metav1.GetOptions{})
+	if err != nil {
+		if k8serr.IsNotFound(err) {
+			return "", fmt.Errorf("secret %s/%s: %w", k.Namespace, k.Name, ErrNotFound)
+		}
+		return "", err
+	}
+	value, ok := secret.Data[name]
+	if !ok {
+		return "", fmt.Errorf("key %s of secret %s/%s: %w", name, k.Namespace, k.Name, ErrNotFound)
+	}
+	return string(value), nil
+}
+
+// KeyVault reads the latest version of secrets from an Azure Key Vault. Key Vault secret names
+// only allow alphanumerics and dashes, so underscores in names are replaced by dashes, e.g.
+// LOG_ANALYTICS_KEY is read from the secret LOG-ANALYTICS-KEY.
+type KeyVault struct {
+	VaultURL string
+
+	mu            sync.Mutex
+	getAuthorizer func() (autorest.Authorizer, error)
+	client        *keyvault.BaseClient
+}
+
+// NewKeyVault creates a Key Vault provider. The authorizer must be issued for the Key Vault
+// resource, it is created on the first read so the provider can be set up before authentication.
+func NewKeyVault(vaultURL string, getAuthorizer func() (autorest.Authorizer, error)) *KeyVault {
+	return &KeyVault{VaultURL: vaultURL, getAuthorizer: getAuthorizer}
+}
+
+func (k *KeyVault) getClient() (*keyvault.BaseClient, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.client == nil {
+		authorizer, err := k.getAuthorizer()
+		if err != nil {
+			return nil, fmt.Errorf("failed to authenticate to Key Vault %s: %v", k.VaultURL, err)
+		}
+		client := keyvault.New()
+		client.Authorizer = authorizer
+		k.client = &client
+	}
+	return k.client, nil
+}
+
+func (k *KeyVault) GetSecret(ctx context.Context, name string) (string, error) {
+	client, err := k.getClient()
+	if err != nil {
+		return "", err
+	}
+	secretName := strings.ReplaceAll(name, "_", "-")
+	bundle, err := client.GetSecret(ctx, k.VaultURL, secretName, "")
+	if err != nil {
+		if bundle.Response.Response != nil && bundle.StatusCode == http.StatusNotFound {
+			return "", fmt.Errorf("secret %s in %s: %w", secretName, k.VaultURL, ErrNotFound)
+		}
+		return "", err
+	}
+	if bundle.Value == nil {
+		return "", fmt.Errorf("secret %s in %s: %w", secretName, k.VaultURL, ErrNotFound)
+	}
+	return *bundle.Value, nil
+}
+
+// Chain reads secrets from the first provider that has them.
+type Chain []Provider
+
+func (c Chain) GetSecret(ctx context.Context, name string) (string, error) {
+	for _, provider := range c {
+		value, err := provider.GetSecret(ctx, name)
+		if err == nil {
+			return value, nil
+		}
+		if !IsNotFound(err) {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("secret %s: %w", name, ErrNotFound)
+}
+
+// UsesKeyVault reports whether the provider reads secrets from an Azure Key Vault.
+func UsesKeyVault(provider Provider) bool {
+	switch p := provider.(type) {
+	case *KeyVault:
+		return true
+	case Chain:
+		for _, provider := range p {
+			if UsesKeyVault(provider) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Options holds what the providers selected by New may need.
+type Options struct {
+	// KubeClient is used by the kubernetes provider.
+	KubeClient kubernetes.Interface
+	// KeyVaultAuthorizer is used by the keyvault provider, it is only called on the first read.
+	KeyVaultAuthorizer func() (autorest.Authorizer, error)
+}
+
+// New creates the providers listed in spec, a comma separated list tried in order of:
+//
+//	env                        environment variables
+//	file=<dir>                 files in a directory
+//	kubernetes=<ns>/<secret>   keys of a Kubernetes secret
+//	keyvault=<vault URL>       secrets of an Azure Key Vault
+//
+// e.g. "keyvault=https://myvault.vault.azure.net,env".
+func New(spec string, opts Options) (Provider, error) {
+	var chain Chain
+	for _, entry := range strings.Split(spec, ",") {
+		kind, arg := strings.TrimSpace(entry), ""
+		if i := strings.Index(kind, "="); i >= 0 {
+			kind, arg = kind[:i], kind[i+1:]
+		}
+
+		switch kind {
+		case "":
+			continue
+		case "env":
+			chain = append(chain, Env{})
+		case "file":
+			if arg == "" {
+				return nil, fmt.Errorf("secret provider %q needs a directory", entry)
+			}
+			chain = append(chain, File{Dir: arg})
+		case "kubernetes":
+			parts := strings.Split(arg, "/")
+			if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+				return nil, fmt.Errorf("secret provider %q needs a <namespace>/<secret>", entry)
+			}
+			if opts.KubeClient == nil {
+				return nil, fmt.Errorf("secret provider %q needs a kubernetes client", entry)
+			}
+			chain = append(chain, Kubernetes{Client: opts.KubeClient, Namespace: parts[0], Name: parts[1]})
+		case "keyvault":
+			if arg == "" {
+				return nil, fmt.Errorf("secret provider %q needs a vault URL", entry)
+			}
+			if opts.KeyVaultAuthorizer == nil {
+				return nil, fmt.Errorf("secret provider %q needs a Key Vault authorizer", entry)
+			}
+			chain = append(chain, NewKeyVault(arg, opts.KeyVaultAuthorizer))
+		default:
+			return nil, fmt.Errorf("unknown secret provider %q, try one of the following instead: env | file | kubernetes | keyvault", kind)
+		}
+	}
+	if len(chain) == 0 {
+		return nil, errors.New("no secret provider configured")
+	}
+	return chain, nil
+}
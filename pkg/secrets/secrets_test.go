@@ -0,0 +1,65 @@
+/*
+Copyright (c) Microsoft Corporation.
+Licensed under the Apache 2.0 license.
+*/
+package secrets
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Azure/go-autorest/autorest"
+	"gotest.tools/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestChain(t *testing.T) {
+	dir := t.TempDir()
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "LOG_ANALYTICS_KEY"), []byte("from-file\n"), 0600))
+	os.Setenv("SECRETS_TEST_VALUE", "from-env")
+	defer os.Unsetenv("SECRETS_TEST_VALUE")
+
+	client := fake.NewSimpleClientset(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "vk-secrets", Namespace: "kube-system"},
+		Data:       map[string][]byte{"AZURE_CLIENT_SECRET": []byte("from-kubernetes")},
+	})
+	provider, err := New("file="+dir+", kubernetes=kube-system/vk-secrets, env", Options{KubeClient: client})
+	assert.NilError(t, err)
+
+	for name, want := range map[string]string{
+		"LOG_ANALYTICS_KEY":   "from-file",
+		"AZURE_CLIENT_SECRET": "from-kubernetes",
+		"SECRETS_TEST_VALUE":  "from-env",
+	} {
+		value, err := provider.GetSecret(context.Background(), name)
+		assert.NilError(t, err)
+		assert.Equal(t, want, value)
+	}
+
+	_, err = provider.GetSecret(context.Background(), "MISSING")
+	assert.Assert(t, IsNotFound(err))
+}
+
+func TestNewInvalidSpec(t *testing.T) {
+	for _, spec := range []string{"", "vault", "file", "kubernetes=vk-secrets", "keyvault=https://vault.vault.azure.net"} {
+		_, err := New(spec, Options{})
+		assert.Assert(t, err != nil, spec)
+	}
+}
+
+func TestUsesKeyVault(t *testing.T) {
+	provider, err := New("env,keyvault=https://myvault.vault.azure.net", Options{
+		KeyVaultAuthorizer: func() (autorest.Authorizer, error) { return autorest.NullAuthorizer{}, nil },
+	})
+	assert.NilError(t, err)
+	assert.Check(t, UsesKeyVault(provider))
+
+	provider, err = New("env", Options{})
+	assert.NilError(t, err)
+	assert.Check(t, !UsesKeyVault(provider))
+	assert.Check(t, !UsesKeyVault(nil))
+}
@@ -153,10 +153,18 @@ func GetPodConditions(creationTime, readyConditionTime v1.Time, readyConditionSt
 			Type:               v12.PodInitialized,
 			Status:             v12.ConditionTrue,
 			LastTransitionTime: creationTime,
+		}, {
+			Type:               v12.ContainersReady,
+			Status:             readyConditionStatus,
+			LastTransitionTime: readyConditionTime,
 		}, {
 			Type:               v12.PodScheduled,
 			Status:             v12.ConditionTrue,
 			LastTransitionTime: creationTime,
+		}, {
+			Type:               "virtualkubelet.io/ContainerGroupProvisioned",
+			Status:             v12.ConditionTrue,
+			LastTransitionTime: creationTime,
 		},
 	}
 }